@@ -18,6 +18,6 @@ func main() {
 	cli.SetVersionInfo(version, commit, date)
 
 	if err := cli.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
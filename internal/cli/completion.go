@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// completionCmd generates shell completion scripts.
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish|powershell",
+	Short: "Generate shell completion scripts",
+	Long: `Generate a completion script for your shell. Completions include the
+names of your indexed stores for --store flags and 'lgrep delete'.
+
+Bash:
+  # Current shell
+  source <(lgrep completion bash)
+
+  # All sessions (Linux)
+  lgrep completion bash > /etc/bash_completion.d/lgrep
+
+  # All sessions (macOS with Homebrew)
+  lgrep completion bash > $(brew --prefix)/etc/bash_completion.d/lgrep
+
+Zsh:
+  # Enable completion once if it is not already on
+  echo "autoload -U compinit; compinit" >> ~/.zshrc
+
+  lgrep completion zsh > "${fpath[1]}/_lgrep"
+
+Fish:
+  lgrep completion fish > ~/.config/fish/completions/lgrep.fish
+
+PowerShell:
+  lgrep completion powershell | Out-String | Invoke-Expression`,
+	Args:                  cobra.ExactArgs(1),
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			return rootCmd.GenFishCompletion(os.Stdout, true)
+		case "powershell":
+			return rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+		default:
+			return cmd.Help()
+		}
+	},
+}
+
+// completeStoreNames completes the names of indexed stores.
+func completeStoreNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// Completion runs without the root pre-run hook, so load config here
+	if err := config.Load(cfgFile); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	cfg := config.Get()
+
+	if _, err := os.Stat(cfg.Database.Path); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	st, err := store.NewSQLiteStoreReadOnly(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer st.Close()
+
+	stores, err := st.ListStores()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, s := range stores {
+		if strings.HasPrefix(s.Name, toComplete) {
+			names = append(names, s.Name+"\t"+s.RootPath)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeStoreArg completes a single store name positional argument.
+func completeStoreArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeStoreNames(cmd, args, toComplete)
+}
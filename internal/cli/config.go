@@ -55,6 +55,12 @@ func runConfig(cmd *cobra.Command, args []string) error {
 	if cfg.Embeddings.OpenAI.BaseURL != "" {
 		fmt.Printf("  OpenAI Base URL: %s\n", cfg.Embeddings.OpenAI.BaseURL)
 	}
+	fmt.Printf("  Voyage Model: %s\n", cfg.Embeddings.Voyage.Model)
+	fmt.Printf("  Cohere Model: %s\n", cfg.Embeddings.Cohere.Model)
+	if cfg.Embeddings.TruncateDimensions > 0 {
+		fmt.Printf("  Truncate Dimensions: %d\n", cfg.Embeddings.TruncateDimensions)
+	}
+	fmt.Printf("  Distance Metric: %s\n", cfg.Embeddings.DistanceMetric)
 	fmt.Println()
 
 	fmt.Println(ui.Bold.Render("LLM:"))
@@ -63,6 +69,7 @@ func runConfig(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  Ollama Model: %s\n", cfg.LLM.Ollama.Model)
 	fmt.Printf("  OpenAI Model: %s\n", cfg.LLM.OpenAI.Model)
 	fmt.Printf("  Anthropic Model: %s\n", cfg.LLM.Anthropic.Model)
+	fmt.Printf("  Max Context Tokens: %d\n", cfg.LLM.MaxContextTokens)
 	fmt.Println()
 
 	fmt.Println(ui.Bold.Render("Indexing:"))
@@ -70,6 +77,16 @@ func runConfig(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  Max File Count: %d\n", cfg.Indexing.MaxFileCount)
 	fmt.Printf("  Chunk Size: %d\n", cfg.Indexing.ChunkSize)
 	fmt.Printf("  Chunk Overlap: %d\n", cfg.Indexing.ChunkOverlap)
+	fmt.Printf("  Auto-Index Max Files: %d\n", cfg.Indexing.AutoIndexMaxFiles)
+	fmt.Printf("  Auto-Index Max Bytes: %d bytes\n", cfg.Indexing.AutoIndexMaxBytes)
+	fmt.Println()
+
+	fmt.Println(ui.Bold.Render("Budget:"))
+	if cfg.Budget.MonthlyUSD > 0 {
+		fmt.Printf("  Monthly: $%.2f\n", cfg.Budget.MonthlyUSD)
+	} else {
+		fmt.Println("  Monthly: unlimited")
+	}
 	fmt.Println()
 
 	fmt.Println(ui.Bold.Render("Database:"))
@@ -0,0 +1,313 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+var (
+	dbRestoreYes bool
+	dbRepair     bool
+	dbJSON       bool
+)
+
+// dbCmd groups commands that manage the index database as a whole.
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Back up, restore and check the index database",
+	Long: `Back up, restore and check the index database, with every store and namespace.
+
+Copying the database file is not safe while 'lgrep watch' or 'lgrep mcp'
+has it open: recent changes live in a separate write-ahead log, and a copy
+taken during a write is corrupt. These commands use SQLite's online backup
+API instead, which is safe while other lgrep processes run.
+
+Examples:
+  # Back up the database
+  lgrep db backup ~/backups/lgrep-$(date +%F).db
+
+  # Restore it
+  lgrep db restore ~/backups/lgrep-2024-05-01.db
+
+  # Check for corruption and inconsistencies, and repair them
+  lgrep db check --repair
+
+  # Rebuild the vector table from the stored embeddings
+  lgrep db rebuild-vectors`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var dbBackupCmd = &cobra.Command{
+	Use:   "backup <path>",
+	Short: "Copy the index database to a new file",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDBBackup,
+}
+
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore <path>",
+	Short: "Replace the index database with a backup",
+	Long: `Replace the contents of the index database with a backup made by
+'lgrep db backup'. Everything indexed since the backup is lost.
+
+Running watchers and MCP servers see the restored contents, but restart
+them so they do not write changes based on the replaced index.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDBRestore,
+}
+
+var dbCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the index database for corruption and inconsistencies",
+	Long: `Check the index database with SQLite's quick_check, and look for chunks
+whose file is gone, vectors whose chunk is gone, chunks without a vector
+(which searches never find) and a missing vector table. Set
+database.check_at_startup to run the same check when 'lgrep watch' or
+'lgrep mcp' starts.
+
+With --repair, orphaned chunks and vectors are dropped, a missing vector
+table is created again, and chunks that lack a vector get it back from the
+copy of their embedding stored with the chunk. Files with chunks that have
+no stored copy (indexed by an older lgrep) are dropped from their store so
+the next 'lgrep index' embeds them again. Set database.auto_repair to
+check and repair at startup too. A missing vector table is only created
+again when every store has the same embedding dimensions.
+
+Corruption found by quick_check cannot be repaired this way; restore a
+backup with 'lgrep db restore' or delete the database and index again.`,
+	Args: cobra.NoArgs,
+	RunE: runDBCheck,
+}
+
+var dbRebuildVectorsCmd = &cobra.Command{
+	Use:   "rebuild-vectors",
+	Short: "Rebuild the vector table from the stored embeddings",
+	Long: `Drop the vector table and create it again from the copy of each
+chunk's embedding stored alongside it, without calling the embedding
+provider. Use it when the vector table is damaged or 'lgrep db check'
+keeps finding chunks without a vector.
+
+Chunks indexed by an older lgrep have no stored copy and are left without a
+vector; run 'lgrep db check --repair' and 'lgrep index' to embed them again.`,
+	Args: cobra.NoArgs,
+	RunE: runDBRebuildVectors,
+}
+
+func init() {
+	dbRestoreCmd.Flags().BoolVarP(&dbRestoreYes, "yes", "y", false, "restore without confirmation")
+	dbCheckCmd.Flags().BoolVar(&dbRepair, "repair", false, "repair the problems found")
+	dbCheckCmd.Flags().BoolVar(&dbJSON, "json", false, "output the problems found as JSON")
+
+	dbCmd.AddCommand(dbBackupCmd)
+	dbCmd.AddCommand(dbRestoreCmd)
+	dbCmd.AddCommand(dbCheckCmd)
+	dbCmd.AddCommand(dbRebuildVectorsCmd)
+	rootCmd.AddCommand(dbCmd)
+}
+
+// signalContext returns a context cancelled on Ctrl+C.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+func runDBBackup(cmd *cobra.Command, args []string) error {
+	cfg := config.Get()
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	if err := store.BackupDatabase(ctx, cfg.Database.Path, args[0]); err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
+
+	if !quiet {
+		size := ""
+		if info, err := os.Stat(args[0]); err == nil {
+			size = " (" + formatBytes(info.Size()) + ")"
+		}
+		fmt.Println(ui.Success.Render(fmt.Sprintf("Backed up %s to %s%s.", cfg.Database.Path, args[0], size)))
+	}
+	return nil
+}
+
+func runDBRestore(cmd *cobra.Command, args []string) error {
+	cfg := config.Get()
+
+	if !dbRestoreYes {
+		ok, err := confirm(fmt.Sprintf("Replace %s with %s? Everything indexed since the backup is lost.", cfg.Database.Path, args[0]))
+		if err != nil {
+			return fmt.Errorf("not restoring: %w; pass --yes to restore without confirmation", err)
+		}
+		if !ok {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	if err := store.RestoreDatabase(ctx, args[0], cfg.Database.Path); err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+
+	if !quiet {
+		fmt.Println(ui.Success.Render(fmt.Sprintf("Restored %s from %s.", cfg.Database.Path, args[0])))
+		fmt.Println(ui.Dim.Render("Restart running 'lgrep watch' and 'lgrep mcp' processes."))
+	}
+	return nil
+}
+
+func runDBCheck(cmd *cobra.Command, args []string) error {
+	cfg := config.Get()
+
+	var st *store.SQLiteStore
+	var err error
+	if dbRepair {
+		st, err = store.NewSQLiteStore(cfg.Database.Path)
+	} else {
+		st, err = store.NewSQLiteStoreReadOnly(cfg.Database.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	var report *store.IntegrityReport
+	if dbRepair {
+		report, err = st.RepairIntegrity()
+	} else {
+		report, err = st.CheckIntegrity()
+	}
+	if report == nil {
+		return err
+	}
+
+	if dbJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printIntegrityReport(report)
+	}
+
+	switch {
+	case err != nil:
+		return err
+	case report.OK():
+		return nil
+	case dbRepair:
+		if !dbJSON && !quiet {
+			fmt.Println(ui.Success.Render("Repaired. Run 'lgrep index' to embed dropped files again."))
+		}
+		return nil
+	default:
+		return errors.New("problems found; run 'lgrep db check --repair' to repair them")
+	}
+}
+
+func runDBRebuildVectors(cmd *cobra.Command, args []string) error {
+	cfg := config.Get()
+
+	st, err := store.NewSQLiteStore(cfg.Database.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	rebuilt, err := st.RebuildVectors()
+	if err != nil {
+		return fmt.Errorf("rebuild failed: %w", err)
+	}
+
+	report, err := st.CheckIntegrity()
+	if err != nil {
+		return err
+	}
+	if !quiet {
+		fmt.Println(ui.Success.Render(fmt.Sprintf("Rebuilt %d vectors.", rebuilt)))
+		if report.MissingVectors > 0 {
+			fmt.Println(ui.Warning.Render(fmt.Sprintf("%d chunks have no stored embedding; run 'lgrep db check --repair' and 'lgrep index' to embed them again.", report.MissingVectors)))
+		}
+	}
+	return nil
+}
+
+// printIntegrityReport describes the problems in r.
+func printIntegrityReport(r *store.IntegrityReport) {
+	if r.OK() {
+		if !quiet {
+			fmt.Println(ui.Success.Render("No problems found."))
+		}
+		return
+	}
+
+	for _, message := range r.Corruption {
+		fmt.Println(ui.Error.Render("Corrupt: " + message))
+	}
+	if r.MissingVectorTable {
+		fmt.Println(ui.Warning.Render("The vector table is missing"))
+	}
+	problems := []struct {
+		count       int
+		description string
+	}{
+		{r.OrphanChunks, "chunks of files that no longer exist"},
+		{r.OrphanVectors, "vectors of chunks that no longer exist"},
+		{r.MissingVectors, "chunks without a vector, which searches never find"},
+		{r.StoredVectors, "of those can be restored from stored embeddings"},
+	}
+	for _, p := range problems {
+		if p.count > 0 {
+			fmt.Println(ui.Warning.Render(fmt.Sprintf("%d %s", p.count, p.description)))
+		}
+	}
+}
+
+// checkDatabase runs the integrity check when a long-running command
+// starts, if database.check_at_startup or database.auto_repair is set,
+// repairing what it finds for the latter and otherwise logging how to
+// repair it.
+func checkDatabase(st *store.SQLiteStore, cfg *config.Config) {
+	if !cfg.Database.CheckAtStartup && !cfg.Database.AutoRepair {
+		return
+	}
+
+	var report *store.IntegrityReport
+	var err error
+	if cfg.Database.AutoRepair {
+		report, err = st.RepairIntegrity()
+	} else {
+		report, err = st.CheckIntegrity()
+	}
+
+	switch {
+	case err != nil && !errors.Is(err, store.ErrCorrupt):
+		log.Warn("Failed to check the index database", "error", err)
+	case len(report.Corruption) > 0:
+		log.Error("The index database is corrupt; restore a backup with 'lgrep db restore' or delete it and index again",
+			"error", report.Corruption[0])
+	case report.OK():
+	case cfg.Database.AutoRepair:
+		log.Warn("Repaired the index database", "orphan_chunks", report.OrphanChunks, "orphan_vectors", report.OrphanVectors,
+			"missing_vectors", report.MissingVectors, "missing_vector_table", report.MissingVectorTable)
+	default:
+		log.Warn("The index database has problems; run 'lgrep db check --repair'", "orphan_chunks", report.OrphanChunks,
+			"orphan_vectors", report.OrphanVectors, "missing_vectors", report.MissingVectors, "missing_vector_table", report.MissingVectorTable)
+	}
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/search"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+var (
+	dupesThreshold float64
+	dupesMinLines  int
+	dupesLimit     int
+	dupesJSON      bool
+)
+
+// dupesCmd reports near-identical chunks in a store.
+var dupesCmd = &cobra.Command{
+	Use:   "dupes <store>",
+	Short: "Find near-duplicate code in a store",
+	Long: `Report groups of near-identical chunks, which are likely copy-pasted code.
+
+Each indexed chunk is compared with its nearest neighbours using the stored
+embeddings, so nothing is re-embedded. Chunks whose cosine similarity is at
+least --threshold are grouped together. Short chunks are skipped because
+closing braces, imports and other boilerplate are often identical.
+
+Examples:
+  # Find duplicates in a store
+  lgrep dupes myproject
+
+  # Include looser matches
+  lgrep dupes myproject --threshold 0.9
+
+  # Only report larger blocks
+  lgrep dupes myproject --min-lines 20`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeStoreArg,
+	RunE:              runDupes,
+}
+
+func init() {
+	dupesCmd.Flags().Float64Var(&dupesThreshold, "threshold", search.DefaultDuplicateScore, "minimum similarity (0-1) for chunks to count as duplicates")
+	dupesCmd.Flags().IntVar(&dupesMinLines, "min-lines", search.DefaultDuplicateMinLines, "skip chunks shorter than this many lines")
+	dupesCmd.Flags().IntVarP(&dupesLimit, "limit", "m", 20, "maximum number of groups to show (0 for all)")
+	dupesCmd.Flags().BoolVar(&dupesJSON, "json", false, "output groups as JSON")
+	rootCmd.AddCommand(dupesCmd)
+}
+
+func runDupes(cmd *cobra.Command, args []string) error {
+	if dupesThreshold <= 0 || dupesThreshold > 1 {
+		return withExitCode(ExitUsage, fmt.Errorf("--threshold must be between 0 and 1, got %g", dupesThreshold))
+	}
+	if dupesLimit < 0 {
+		return withExitCode(ExitUsage, fmt.Errorf("--limit must not be negative, got %d", dupesLimit))
+	}
+
+	st, err := store.NewSQLiteStoreReadOnly(config.Get().Database.Path, store.WithNamespace(config.Get().Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	storeRecord, err := st.GetStore(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to check store: %w", err)
+	}
+	if storeRecord == nil {
+		return withExitCode(ExitStoreMissing, fmt.Errorf("store not found: %s", args[0]))
+	}
+
+	minLines := dupesMinLines
+	if minLines <= 0 {
+		minLines = -1 // Zero means the default to FindDuplicates
+	}
+
+	// Stored vectors are compared directly, so no embedder is needed
+	searcher := search.New(st, nil)
+	showProgress := !quiet && !dupesJSON && isTerminal(os.Stderr)
+	lastUpdate := time.Now()
+	groups, err := searcher.FindDuplicates(context.Background(), search.DuplicateOptions{
+		StoreName: storeRecord.Name,
+		MinScore:  dupesThreshold,
+		MinLines:  minLines,
+		OnProgress: func(done, total int) {
+			if !showProgress || time.Since(lastUpdate) < 100*time.Millisecond {
+				return
+			}
+			lastUpdate = time.Now()
+			fmt.Fprintf(os.Stderr, "\r\033[KComparing chunks: %d/%d", done, total)
+		},
+	})
+	if showProgress {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+	if err != nil {
+		return err
+	}
+
+	total := len(groups)
+	if dupesLimit > 0 && len(groups) > dupesLimit {
+		groups = groups[:dupesLimit]
+	}
+
+	if dupesJSON {
+		if groups == nil {
+			groups = []search.DuplicateGroup{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(groups)
+	}
+
+	if total == 0 {
+		if !quiet {
+			fmt.Printf("No duplicates found in '%s' at %.0f%% similarity.\n", storeRecord.Name, dupesThreshold*100)
+		}
+		return nil
+	}
+
+	for i, g := range groups {
+		if quiet {
+			// One group per paragraph, one location per line
+			if i > 0 {
+				fmt.Println()
+			}
+			for _, c := range g.Chunks {
+				fmt.Printf("%s:%d-%d\n", c.RelativePath, c.StartLine, c.EndLine)
+			}
+			continue
+		}
+
+		fmt.Println(ui.Header.Render(fmt.Sprintf("Group %d", i+1)) + " " +
+			ui.Dim.Render(fmt.Sprintf("(%d chunks, %.0f%% similar)", len(g.Chunks), g.Score*100)))
+		for _, c := range g.Chunks {
+			fmt.Printf("  %s:%d-%d\n", ui.FilePath.Render(c.RelativePath), c.StartLine, c.EndLine)
+		}
+		fmt.Println()
+	}
+
+	if !quiet && total > len(groups) {
+		fmt.Println(ui.Dim.Render(fmt.Sprintf("Showing %d of %d groups; use --limit 0 to show all.", len(groups), total)))
+	}
+	return nil
+}
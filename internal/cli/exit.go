@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nickcecere/lgrep/internal/cost"
+	"github.com/nickcecere/lgrep/internal/search"
+)
+
+// Exit codes. Like grep, 1 means the command worked but found nothing, so
+// scripts can branch on the outcome of 'lgrep search'.
+const (
+	ExitOK                  = 0
+	ExitNoResults           = 1
+	ExitUsage               = 2 // Bad arguments or flags, or any other failure
+	ExitProviderUnavailable = 3 // The embedding or LLM provider could not be used
+	ExitStoreMissing        = 4 // The store does not exist and was not indexed
+)
+
+// exitError is an error with an exit code. err is nil for outcomes that are
+// reported with an exit code alone, like a search without results.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExitCode returns err with the given exit code, or nil if err is nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// errNoResults reports a search that found nothing. The command prints its
+// own message, if any, and silences cobra's.
+var errNoResults = &exitError{code: ExitNoResults}
+
+// providerUnavailable marks err as a provider failure if it is one: the
+// provider could not be created or reached, timed out, or the budget
+// refused the call.
+func providerUnavailable(err error) error {
+	if errors.Is(err, search.ErrEmbedQuery) || errors.Is(err, cost.ErrBudgetExceeded) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return withExitCode(ExitProviderUnavailable, err)
+	}
+	return err
+}
+
+// stopped returns the error for a command whose context has ended: a
+// provider failure once --timeout has passed, or nil after an interrupt,
+// which the command has already reported.
+func stopped(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return withExitCode(ExitProviderUnavailable, fmt.Errorf("timed out after %s", timeout))
+	}
+	return nil
+}
+
+// ExitCode returns the process exit code for an error returned by Execute.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return ExitUsage
+}
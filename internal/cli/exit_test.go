@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nickcecere/lgrep/internal/cost"
+	"github.com/nickcecere/lgrep/internal/search"
+)
+
+// TestExitCode tests the exit code of each kind of failure.
+func TestExitCode(t *testing.T) {
+	deadline, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-deadline.Done()
+	interrupted, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, ExitOK},
+		{"no results", errNoResults, ExitNoResults},
+		{"outdated index", errIndexOutdated, ExitNoResults},
+		{"usage", withExitCode(ExitUsage, errors.New("invalid --limit")), ExitUsage},
+		{"negative --per-file", checkPerFile(-1), ExitUsage},
+		{"other failure", errors.New("disk full"), ExitUsage},
+		{"store missing", withExitCode(ExitStoreMissing, errors.New("store not found: x")), ExitStoreMissing},
+		{"query not embedded", providerUnavailable(fmt.Errorf("search failed: %w", search.ErrEmbedQuery)), ExitProviderUnavailable},
+		{"over budget", providerUnavailable(fmt.Errorf("search failed: %w", cost.ErrBudgetExceeded)), ExitProviderUnavailable},
+		{"provider timeout", providerUnavailable(context.DeadlineExceeded), ExitProviderUnavailable},
+		{"not a provider failure", providerUnavailable(errors.New("store is corrupt")), ExitUsage},
+		{"timed out", stopped(deadline), ExitProviderUnavailable},
+		{"interrupted", stopped(interrupted), ExitOK},
+		{"wrapped", fmt.Errorf("search: %w", withExitCode(ExitStoreMissing, errors.New("gone"))), ExitStoreMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExitCode(tt.err))
+		})
+	}
+
+	// withExitCode keeps success a success
+	assert.NoError(t, withExitCode(ExitUsage, nil))
+	assert.NoError(t, checkPerFile(0))
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/export"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+var (
+	exportFormat string
+	exportOutput string
+)
+
+// exportEmbeddingsCmd writes a store's vectors for visualization tools.
+var exportEmbeddingsCmd = &cobra.Command{
+	Use:   "export-embeddings <store>",
+	Short: "Export a store's embeddings for visualization",
+	Long: `Export the stored embedding of every chunk with metadata about it, to
+inspect how a codebase clusters or sanity-check an embedding model.
+
+Formats:
+  tsv    Two files for the TensorFlow Embedding Projector
+         (https://projector.tensorflow.org): <store>-vectors.tsv and
+         <store>-metadata.tsv, written to the --output directory. Load the
+         vectors, then the metadata, and color by directory or language.
+  jsonl  One JSON object per chunk with its path, lines, language and
+         embedding, for UMAP and other tooling. Written to --output, or to
+         standard output if it is not given.
+
+Nothing is re-embedded.
+
+Examples:
+  # Write Projector files to the current directory
+  lgrep export-embeddings myproject
+
+  # Write them somewhere else
+  lgrep export-embeddings myproject -o /tmp/projector
+
+  # Stream JSON lines to another tool
+  lgrep export-embeddings myproject --format jsonl | python umap_plot.py`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeStoreArg,
+	RunE:              runExportEmbeddings,
+}
+
+func init() {
+	exportEmbeddingsCmd.Flags().StringVarP(&exportFormat, "format", "f", export.FormatTSV, "output format: tsv or jsonl")
+	exportEmbeddingsCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "directory for tsv files (default current directory) or file for jsonl (default stdout)")
+	_ = exportEmbeddingsCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(
+		[]string{export.FormatTSV, export.FormatJSONL}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.AddCommand(exportEmbeddingsCmd)
+}
+
+func runExportEmbeddings(cmd *cobra.Command, args []string) error {
+	if exportFormat != export.FormatTSV && exportFormat != export.FormatJSONL {
+		return withExitCode(ExitUsage, fmt.Errorf("unknown format %q: use tsv or jsonl", exportFormat))
+	}
+
+	st, err := store.NewSQLiteStoreReadOnly(config.Get().Database.Path, store.WithNamespace(config.Get().Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	storeRecord, err := st.GetStore(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to check store: %w", err)
+	}
+	if storeRecord == nil {
+		return withExitCode(ExitStoreMissing, fmt.Errorf("store not found: %s", args[0]))
+	}
+
+	if exportFormat == export.FormatJSONL {
+		return exportJSONL(st, storeRecord)
+	}
+	return exportTSV(st, storeRecord)
+}
+
+// exportTSV writes the Projector's vector and metadata files.
+func exportTSV(st store.Store, storeRecord *store.StoreRecord) error {
+	dir := exportOutput
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	vectorsPath := filepath.Join(dir, storeRecord.Name+"-vectors.tsv")
+	metadataPath := filepath.Join(dir, storeRecord.Name+"-metadata.tsv")
+	vectors, err := os.Create(vectorsPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", vectorsPath, err)
+	}
+	defer vectors.Close()
+	metadata, err := os.Create(metadataPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", metadataPath, err)
+	}
+	defer metadata.Close()
+
+	n, err := export.TSV(st, storeRecord.ID, vectors, metadata)
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	if err := vectors.Close(); err != nil {
+		return err
+	}
+	if err := metadata.Close(); err != nil {
+		return err
+	}
+
+	if quiet {
+		fmt.Println(vectorsPath)
+		fmt.Println(metadataPath)
+		return nil
+	}
+	fmt.Println(ui.Success.Render(fmt.Sprintf("Exported %d chunks from '%s' (%s %s, %d dimensions).",
+		n, storeRecord.Name, storeRecord.EmbeddingProvider, storeRecord.EmbeddingModel, storeRecord.EmbeddingDimensions)))
+	fmt.Printf("  Vectors:  %s\n", vectorsPath)
+	fmt.Printf("  Metadata: %s\n", metadataPath)
+	fmt.Println(ui.Dim.Render("Load both at https://projector.tensorflow.org (Load > Choose file)."))
+	return nil
+}
+
+// exportJSONL writes one JSON object per chunk.
+func exportJSONL(st store.Store, storeRecord *store.StoreRecord) error {
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if exportOutput != "" && exportOutput != "-" {
+		var err error
+		file, err = os.Create(exportOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", exportOutput, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	n, err := export.JSONL(st, storeRecord.ID, w)
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	if file == nil {
+		return nil
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if !quiet {
+		fmt.Println(ui.Success.Render(fmt.Sprintf("Exported %d chunks from '%s' to %s.", n, storeRecord.Name, exportOutput)))
+	}
+	return nil
+}
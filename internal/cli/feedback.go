@@ -0,0 +1,202 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/search"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+var (
+	feedbackGood  bool
+	feedbackBad   bool
+	feedbackQuery string
+	feedbackList  bool
+	feedbackStore string
+	feedbackJSON  bool
+)
+
+// feedbackCmd records whether a search result was useful.
+var feedbackCmd = &cobra.Command{
+	Use:   "feedback <result-id> --good|--bad",
+	Short: "Mark a search result as good or bad to tune ranking",
+	Long: `Record whether a search result was what you were looking for. The result
+ID is the #number shown after each result of 'lgrep search', or the "id" of
+its JSON output.
+
+Judgments are kept per file, and later searches of the store rank files
+judged good higher and files judged bad lower. Files in the same directory
+are moved too, by half as much. search.feedback_weight sets how far results
+can move, and 0 turns it off.
+
+Each judgment also records the query of the search that returned the
+result, when the search was logged, so --list --json gives labeled
+query/result pairs for evaluating search quality.
+
+Result IDs change when a file is re-indexed, so judge results of a recent
+search.
+
+Examples:
+  # The third result of the last search was spot on
+  lgrep search "jwt refresh"
+  lgrep feedback 4821 --good
+
+  # This one was noise
+  lgrep feedback 1377 --bad
+
+  # Export the judgments of this directory's store as JSON lines
+  lgrep feedback --list --json`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if feedbackList {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: runFeedback,
+}
+
+func init() {
+	feedbackCmd.Flags().BoolVar(&feedbackGood, "good", false, "the result was useful")
+	feedbackCmd.Flags().BoolVar(&feedbackBad, "bad", false, "the result was not useful")
+	feedbackCmd.Flags().StringVar(&feedbackQuery, "query", "", "the query that returned the result (default: the logged search that returned it)")
+	feedbackCmd.Flags().BoolVar(&feedbackList, "list", false, "list the judgments of a store instead")
+	feedbackCmd.Flags().StringVar(&feedbackStore, "store", "", "with --list, store name (auto-detected if not specified)")
+	_ = feedbackCmd.RegisterFlagCompletionFunc("store", completeStoreNames)
+	feedbackCmd.Flags().BoolVar(&feedbackJSON, "json", false, "with --list, output one judgment per line as JSON")
+	feedbackCmd.MarkFlagsMutuallyExclusive("good", "bad")
+	rootCmd.AddCommand(feedbackCmd)
+}
+
+func runFeedback(cmd *cobra.Command, args []string) error {
+	cfg := config.Get()
+
+	if feedbackList {
+		return listFeedback(cfg)
+	}
+	if !feedbackGood && !feedbackBad {
+		return withExitCode(ExitUsage, errors.New("pass --good or --bad"))
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		return withExitCode(ExitUsage, fmt.Errorf("invalid result id: %s", args[0]))
+	}
+
+	st, err := store.NewSQLiteStore(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	chunks, err := st.GetChunks([]int64{id})
+	if err != nil {
+		return err
+	}
+	var storeRecord *store.StoreRecord
+	if len(chunks) == 1 {
+		if storeRecord, err = st.GetStoreByID(chunks[0].File.StoreID); err != nil {
+			return fmt.Errorf("failed to check store: %w", err)
+		}
+	}
+	if storeRecord == nil {
+		return fmt.Errorf("result not found: %d (IDs change when files are re-indexed; search again)", id)
+	}
+	result := chunks[0]
+
+	query := feedbackQuery
+	if query == "" {
+		if query, err = st.FindSearchQuery(storeRecord.ID, id); err != nil {
+			return err
+		}
+	}
+
+	f := &store.Feedback{
+		StoreID:      storeRecord.ID,
+		RelativePath: result.File.RelativePath,
+		StartLine:    result.Chunk.StartLine,
+		EndLine:      result.Chunk.EndLine,
+		Query:        query,
+		Good:         feedbackGood,
+	}
+	if err := st.AddFeedback(f); err != nil {
+		return err
+	}
+
+	if !quiet {
+		judgment := "bad"
+		if f.Good {
+			judgment = "good"
+		}
+		fmt.Printf("Marked %s as %s", ui.FilePath.Render(fmt.Sprintf("%s:%d-%d", f.RelativePath, f.StartLine, f.EndLine)), judgment)
+		if query != "" {
+			fmt.Printf(" for %q", query)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// listFeedback prints the judgments of the store given by --store or
+// containing the current directory.
+func listFeedback(cfg *config.Config) error {
+	st, err := store.NewSQLiteStoreReadOnly(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	var storeRecord *store.StoreRecord
+	if feedbackStore != "" {
+		storeRecord, err = st.GetStore(feedbackStore)
+	} else {
+		storeRecord, err = search.New(st, nil).GetStoreForPath(".")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check store: %w", err)
+	}
+	if storeRecord == nil {
+		if feedbackStore != "" {
+			return withExitCode(ExitStoreMissing, fmt.Errorf("store not found: %s", feedbackStore))
+		}
+		return withExitCode(ExitStoreMissing, errors.New("no indexed store contains the current directory; pass --store"))
+	}
+
+	feedback, err := st.ListFeedback(storeRecord.ID)
+	if err != nil {
+		return err
+	}
+
+	if feedbackJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, f := range feedback {
+			if err := encoder.Encode(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(feedback) == 0 {
+		fmt.Printf("No feedback recorded for store '%s'.\n", storeRecord.Name)
+		return nil
+	}
+	for _, f := range feedback {
+		judgment := ui.Warning.Render("bad ")
+		if f.Good {
+			judgment = ui.Success.Render("good")
+		}
+		fmt.Printf("%s %s %s\n", judgment,
+			ui.FilePath.Render(fmt.Sprintf("%s:%d-%d", f.RelativePath, f.StartLine, f.EndLine)),
+			ui.Dim.Render(fmt.Sprintf("%s %s", formatTime(f.CreatedAt.Local()), f.Query)))
+	}
+	return nil
+}
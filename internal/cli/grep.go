@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/fallback"
+	"github.com/nickcecere/lgrep/internal/indexer"
+	"github.com/nickcecere/lgrep/internal/search"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+var (
+	grepContent   bool
+	grepLimit     int
+	grepPerFile   int
+	grepContext   contextFlags
+	grepJSON      bool
+	grepExclude   []string
+	grepGenerated bool
+)
+
+// grepCmd searches files for the words of a query without embeddings.
+var grepCmd = &cobra.Command{
+	Use:   "grep <query> [path]",
+	Short: "Search files for the words of a query, without embeddings",
+	Long: `Scan the files under a path for the words of a query, skipping short and
+common words, and list the lines containing the most of them. No index or
+embedding provider is needed, so it works right after installing lgrep.
+
+'lgrep search' falls back to this scan, with a notice, when the embedding
+provider cannot be used: Ollama is not running, or a cloud provider has no
+API key. Set search.keyword_fallback to false to fail instead.
+
+The files are chosen as 'lgrep index' would choose them, honouring
+.gitignore and the ignore and indexing settings.
+
+Examples:
+  # Lines mentioning retries and backoff
+  lgrep grep "retry backoff"
+
+  # With the matching lines, under ./internal
+  lgrep grep "retry backoff" ./internal -c
+
+  # With two lines before and after each match
+  lgrep grep "retry backoff" -C 2`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runGrep,
+}
+
+func init() {
+	grepCmd.Flags().BoolVarP(&grepContent, "content", "c", false, "show the matching lines")
+	grepCmd.Flags().IntVarP(&grepLimit, "limit", "m", 10, "maximum number of results (0 for up to 1000)")
+	grepCmd.Flags().IntVar(&grepPerFile, "per-file", 0, "maximum results from any one file (0 for no limit)")
+	grepContext.register(grepCmd)
+	grepCmd.Flags().BoolVar(&grepJSON, "json", false, "output results as JSON")
+	grepCmd.Flags().StringSliceVar(&grepExclude, "exclude-term", nil, "exclude lines containing this term (can be repeated)")
+	grepCmd.Flags().BoolVar(&grepGenerated, "include-generated", false, "include vendored and generated files")
+	rootCmd.AddCommand(grepCmd)
+}
+
+func runGrep(cmd *cobra.Command, args []string) error {
+	query, excludeTerms := search.ParseQuery(args[0])
+	if query == "" {
+		return fmt.Errorf("query cannot be empty")
+	}
+	path := "."
+	if len(args) > 1 {
+		path = args[1]
+	}
+	limit, err := resultLimit(grepLimit)
+	if err != nil {
+		return err
+	}
+	if err := checkPerFile(grepPerFile); err != nil {
+		return err
+	}
+
+	cfg := config.Get()
+	opts := search.SearchOptions{
+		TopK:             limit,
+		PerFile:          grepPerFile,
+		IncludeContent:   grepContent || grepContext.requested(cmd),
+		LinesBefore:      grepContext.linesBefore(cmd),
+		LinesAfter:       grepContext.linesAfter(cmd),
+		ExcludeTerms:     append(excludeTerms, grepExclude...),
+		ExcludeGenerated: !(grepGenerated || cfg.Search.IncludeGenerated),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	return keywordSearch(ctx, cmd, cfg, query, path, opts, grepJSON)
+}
+
+// keywordFallback reports whether a search that failed with err should fall
+// back to a keyword search: the embedding provider could not be created,
+// reached or paid for, and search.keyword_fallback is on.
+func keywordFallback(cfg *config.Config, err error) bool {
+	if !cfg.Search.KeywordFallback || err == nil {
+		return false
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) && exitErr.code == ExitProviderUnavailable {
+		return true
+	}
+	return errors.Is(err, search.ErrEmbedQuery) || fallback.ShouldFallback(err)
+}
+
+// keywordSearch scans the files under path for the keywords of query and
+// prints the results like a search does.
+func keywordSearch(ctx context.Context, cmd *cobra.Command, cfg *config.Config, query, path string, opts search.SearchOptions, asJSON bool) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	scan, err := indexer.Scan(cfg, absPath, nil, nil)
+	if err != nil {
+		return err
+	}
+	log.Debug("Scanning files for keywords", "files", len(scan.Files), "keywords", search.Keywords(query))
+
+	results, err := search.KeywordSearch(ctx, scan.Files, query, opts)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		if !quiet {
+			fmt.Println("No results found.")
+		}
+		cmd.SilenceErrors = true
+		return errNoResults
+	}
+
+	switch {
+	case asJSON:
+		return outputJSON(results, 0, nil)
+	case quiet:
+		displayQuiet(results)
+	default:
+		displayResults(results, 0, len(results), opts.IncludeContent, newSnippetRenderer(cfg, query))
+	}
+	return nil
+}
+
+// searchKeywordsInstead tells the user that a search falls back to keywords
+// because of err.
+func searchKeywordsInstead(err error) {
+	if quiet {
+		return
+	}
+	fmt.Fprintln(os.Stderr, ui.Warning.Render(fmt.Sprintf("Embedding provider unavailable (%v).", err)))
+	fmt.Fprintln(os.Stderr, ui.Dim.Render("Showing keyword matches instead; run 'lgrep selftest' to check the provider."))
+}
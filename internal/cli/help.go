@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/fs"
+)
+
+// helpTopic is a page shown by 'lgrep help <topic>' that is not tied to a
+// command.
+type helpTopic struct {
+	short  string
+	render func() string
+}
+
+// helpTopics are rendered from the code they describe, so they stay in sync
+// with the binary.
+var helpTopics = map[string]helpTopic{
+	"config":    {short: "Every configuration key, its default and environment variable", render: configTopic},
+	"chunking":  {short: "How files are split into chunks before embedding", render: chunkingTopic},
+	"languages": {short: "Language names for indexing.languages and their extensions", render: languagesTopic},
+}
+
+// helpCmd replaces cobra's default help command to add help topics.
+var helpCmd = &cobra.Command{
+	Use:   "help [command | topic]",
+	Short: "Help about any command or topic",
+	Long: `Help provides help for any command or topic in the application.
+
+Topics:
+` + topicList(),
+	ValidArgsFunction: completeHelp,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			if topic, ok := helpTopics[args[0]]; ok {
+				fmt.Print(topic.render())
+				return nil
+			}
+		}
+
+		target, _, err := rootCmd.Find(args)
+		if target == nil || err != nil || (target == rootCmd && len(args) > 0) {
+			return fmt.Errorf("unknown help topic %q", strings.Join(args, " "))
+		}
+		target.InitDefaultHelpFlag()
+		return target.Help()
+	},
+}
+
+func init() {
+	rootCmd.SetHelpCommand(helpCmd)
+}
+
+// topicNames returns the help topic names in sorted order.
+func topicNames() []string {
+	names := make([]string, 0, len(helpTopics))
+	for name := range helpTopics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// topicList formats the help topics for the help command's description.
+func topicList() string {
+	var b strings.Builder
+	for _, name := range topicNames() {
+		fmt.Fprintf(&b, "  %-10s %s\n", name, helpTopics[name].short)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// completeHelp completes topic and command names for 'lgrep help'.
+func completeHelp(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, name := range topicNames() {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name+"\t"+helpTopics[name].short)
+		}
+	}
+	for _, c := range rootCmd.Commands() {
+		// A topic takes precedence over a command of the same name
+		if _, ok := helpTopics[c.Name()]; ok {
+			continue
+		}
+		if c.IsAvailableCommand() && strings.HasPrefix(c.Name(), toComplete) {
+			names = append(names, c.Name()+"\t"+c.Short)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// configTopic describes every configuration key.
+func configTopic() string {
+	var b strings.Builder
+	b.WriteString(`Configuration
+
+lgrep reads the first configuration file it finds of:
+
+  1. The file given with --config
+  2. .lgreprc.yaml in the current directory or any parent
+  3. ` + config.GlobalConfigPath() + `
+
+Environment variables (LGREP_ followed by the key with dots replaced by
+underscores) override values from the file. Run 'lgrep config' to see the
+effective values.
+
+Keys:
+`)
+
+	for _, opt := range config.Reference() {
+		fmt.Fprintf(&b, "\n  %s (%s)\n", opt.Key, opt.Type)
+		if opt.Description != "" {
+			fmt.Fprintf(&b, "      %s\n", opt.Description)
+		}
+		fmt.Fprintf(&b, "      Default: %s\n", opt.Default)
+		fmt.Fprintf(&b, "      Env:     %s\n", opt.Env)
+	}
+	return b.String()
+}
+
+// chunkingTopic describes how the chunker splits files, using the current
+// configuration.
+func chunkingTopic() string {
+	cfg := config.Get()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `Chunking
+
+Each indexed file is split into chunks, and each chunk is embedded and
+searched separately. Results point at the lines of the matching chunk.
+
+Text files
+  Lines are collected until a chunk reaches indexing.chunk_size characters
+  (currently %d). The next chunk starts with up to indexing.chunk_overlap
+  characters (currently %d) of whole lines from the end of the previous one,
+  so code near a boundary appears in both. A trailing chunk shorter than %d
+  characters is merged into the chunk before it.
+
+Source code
+  Files in supported languages are split at function, method and class
+  boundaries instead, so a chunk usually holds one definition. A definition
+  longer than %d characters (twice the chunk size) is split further as text.
+  Files with no recognizable boundaries fall back to text chunking.
+
+  Supported languages:
+`, cfg.Indexing.ChunkSize, cfg.Indexing.ChunkOverlap, fs.DefaultChunkOptions().MinChunkSize, 2*cfg.Indexing.ChunkSize)
+
+	fmt.Fprintf(&b, "    %s\n", strings.Join(fs.CodeChunkingLanguages(), ", "))
+
+	fmt.Fprintf(&b, `
+Large files
+  At most indexing.max_chunks_per_file chunks (currently %d) are indexed
+  from one file: the first and last halves of that many, with a warning
+  naming the file. This keeps huge generated files from crowding out the
+  rest of the index; 0 removes the limit.
+`, cfg.Indexing.MaxChunksPerFile)
+
+	b.WriteString(`
+Tuning
+  Smaller chunks give more precise line ranges but less context per result;
+  larger chunks do the opposite. Changing the chunk size only affects files
+  indexed afterwards, so run 'lgrep index --force' to re-chunk everything.
+`)
+	return b.String()
+}
+
+// languagesTopic lists the languages indexing.languages accepts and the
+// extensions each selects.
+func languagesTopic() string {
+	cfg := config.Get()
+
+	var b strings.Builder
+	b.WriteString(`Languages
+
+indexing.languages.include limits indexing to files of some languages, and
+indexing.languages.exclude leaves out files of others, e.g.
+
+  indexing:
+    languages:
+      include: [go, sql]
+
+Files are selected by extension. Files recognized by name alone, such as
+Makefile, are left out by an include list.
+
+`)
+	if include := cfg.Indexing.Languages.Include; len(include) > 0 {
+		fmt.Fprintf(&b, "Currently included: %s\n\n", strings.Join(include, ", "))
+	}
+	if exclude := cfg.Indexing.Languages.Exclude; len(exclude) > 0 {
+		fmt.Fprintf(&b, "Currently excluded: %s\n\n", strings.Join(exclude, ", "))
+	}
+
+	b.WriteString("Languages:\n")
+	for _, lang := range fs.Languages() {
+		exts, _ := fs.LanguageExtensions([]string{lang})
+		sort.Strings(exts)
+		fmt.Fprintf(&b, "  %-12s %s\n", lang, strings.Join(exts, " "))
+	}
+	return b.String()
+}
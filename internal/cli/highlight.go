@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+
+	"github.com/nickcecere/lgrep/internal/search"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+// matchedToken offsets a token type to mark text that matched a query term.
+// The offset is far outside chroma's token type ranges.
+const matchedToken chroma.TokenType = 1 << 20
+
+// termMatcher returns a case-insensitive pattern matching any of the query's
+// terms, or nil if the query has none worth highlighting.
+func termMatcher(query string) *regexp.Regexp {
+	terms := search.QueryTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	// Prefer the longest term where several match at the same position
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	for i, term := range terms {
+		terms[i] = regexp.QuoteMeta(term)
+	}
+	return regexp.MustCompile("(?i)" + strings.Join(terms, "|"))
+}
+
+// markTerms splits tokens around matches of terms, giving the matched text
+// its own token type so it can be styled on top of the syntax colours.
+func markTerms(tokens []chroma.Token, terms *regexp.Regexp) []chroma.Token {
+	if terms == nil {
+		return tokens
+	}
+
+	var out []chroma.Token
+	for _, token := range tokens {
+		last := 0
+		for _, m := range terms.FindAllStringIndex(token.Value, -1) {
+			if m[0] > last {
+				out = append(out, chroma.Token{Type: token.Type, Value: token.Value[last:m[0]]})
+			}
+			out = append(out, chroma.Token{Type: token.Type + matchedToken, Value: token.Value[m[0]:m[1]]})
+			last = m[1]
+		}
+		if last < len(token.Value) {
+			out = append(out, chroma.Token{Type: token.Type, Value: token.Value[last:]})
+		}
+	}
+	return out
+}
+
+// matchStyle extends style with bold, underlined variants of the token types
+// that markTerms produced.
+func matchStyle(style *chroma.Style, tokens []chroma.Token) *chroma.Style {
+	builder := style.Builder()
+	added := false
+	for _, token := range tokens {
+		if token.Type < matchedToken {
+			continue
+		}
+		entry := style.Get(token.Type - matchedToken)
+		entry.Bold = chroma.Yes
+		entry.Underline = chroma.Yes
+		entry.Background = 0
+		builder.AddEntry(token.Type, entry)
+		added = true
+	}
+	if !added {
+		return style
+	}
+
+	matched, err := builder.Build()
+	if err != nil {
+		return style
+	}
+	return matched
+}
+
+// markTermsPlain highlights matches of terms in uncoloured text.
+func markTermsPlain(line string, terms *regexp.Regexp) string {
+	if terms == nil {
+		return line
+	}
+	return terms.ReplaceAllStringFunc(line, func(match string) string {
+		return ui.Match.Render(match)
+	})
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+var historyLimit int
+
+// historyCmd represents the history parent command.
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show previous activity",
+	Long:  `Show previously recorded activity such as Q&A transcripts.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+// historyQACmd lists or shows recorded Q&A transcripts.
+var historyQACmd = &cobra.Command{
+	Use:   "qa [id]",
+	Short: "Show Q&A transcripts",
+	Long: `List previous Q&A answers, or show a single transcript in full.
+
+Transcripts are recorded whenever 'lgrep search -a' generates an answer,
+unless --no-log is given. Each transcript includes the question, the sources
+sent to the LLM as context, the answer, the model and the latency.
+
+Examples:
+  # List recent answers
+  lgrep history qa
+
+  # Show a transcript in full
+  lgrep history qa 12`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runHistoryQA,
+}
+
+func init() {
+	historyQACmd.Flags().IntVarP(&historyLimit, "limit", "m", 20, "maximum number of transcripts to list")
+
+	historyCmd.AddCommand(historyQACmd)
+	rootCmd.AddCommand(historyCmd)
+}
+
+func runHistoryQA(cmd *cobra.Command, args []string) error {
+	cfg := config.Get()
+
+	st, err := store.NewSQLiteStoreReadOnly(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	// Show a single transcript
+	if len(args) == 1 {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid transcript id: %s", args[0])
+		}
+
+		t, err := st.GetQATranscript(id)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			return fmt.Errorf("transcript not found: %d", id)
+		}
+
+		displayTranscript(t)
+		return nil
+	}
+
+	transcripts, err := st.ListQATranscripts(historyLimit)
+	if err != nil {
+		return err
+	}
+
+	if len(transcripts) == 0 {
+		fmt.Println("No Q&A transcripts recorded.")
+		return nil
+	}
+
+	fmt.Println(ui.Header.Render("Q&A History"))
+	fmt.Println()
+
+	for _, t := range transcripts {
+		fmt.Printf("%s %s\n",
+			ui.Highlight.Render(fmt.Sprintf("#%d", t.ID)),
+			t.Question,
+		)
+		fmt.Printf("    %s\n", ui.Dim.Render(fmt.Sprintf("%s | %s | %s (%s) | %d sources",
+			formatTime(t.CreatedAt.Local()), t.StoreName, t.Model, t.Latency.Round(time.Millisecond), len(t.Sources))))
+	}
+
+	fmt.Println()
+	fmt.Println(ui.Dim.Render("Run 'lgrep history qa <id>' to show a transcript."))
+
+	return nil
+}
+
+// displayTranscript prints a full Q&A transcript.
+func displayTranscript(t *store.QATranscript) {
+	fmt.Println(ui.Header.Render(fmt.Sprintf("Transcript #%d", t.ID)))
+	fmt.Println()
+	fmt.Printf("  %s %s\n", ui.Dim.Render("Question:"), t.Question)
+	fmt.Printf("  %s %s\n", ui.Dim.Render("Store:"), t.StoreName)
+	fmt.Printf("  %s %s (%s)\n", ui.Dim.Render("Model:"), t.Model, t.Provider)
+	fmt.Printf("  %s %s\n", ui.Dim.Render("Latency:"), t.Latency)
+	fmt.Printf("  %s %s\n", ui.Dim.Render("Asked:"), formatTime(t.CreatedAt.Local()))
+	fmt.Println()
+
+	fmt.Println(ui.Dim.Render("Context sent to the LLM:"))
+	for i, s := range t.Sources {
+		// Transcripts recorded before calibration only have a score
+		score := fmt.Sprintf("%.1f%%", s.Score*100)
+		if s.Relevance > 0 {
+			score = fmt.Sprintf("relevance %.0f", s.Relevance)
+		}
+		fmt.Printf("  [%d] %s (lines %d-%d, %s)\n",
+			i+1, s.RelativePath, s.StartLine, s.EndLine, score)
+	}
+	fmt.Println()
+
+	fmt.Println(ui.Header.Render("Answer"))
+	fmt.Println()
+	rendered, err := renderMarkdown(t.Answer)
+	if err != nil {
+		fmt.Println(t.Answer)
+	} else {
+		fmt.Print(rendered)
+	}
+}
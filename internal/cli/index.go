@@ -14,6 +14,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/cost"
 	"github.com/nickcecere/lgrep/internal/embeddings"
 	"github.com/nickcecere/lgrep/internal/fs"
 	"github.com/nickcecere/lgrep/internal/indexer"
@@ -23,10 +24,15 @@ import (
 
 var (
 	indexForce      bool
+	indexBatch      bool
 	indexDryRun     bool
 	indexStore      string
 	indexExtensions []string
 	indexIgnore     []string
+
+	indexMinFileSize        int
+	indexTextExtensions     []string
+	indexIncludeBinaryNames bool
 )
 
 // indexCmd represents the index command
@@ -48,12 +54,18 @@ Examples:
   # Index a specific directory
   lgrep index ./src
 
-  # Force re-index (clear existing)
+  # Rebuild the index from scratch, dropping deleted files
   lgrep index --force
 
+  # Embed a large initial index through the provider's batch API
+  lgrep index --batch
+
   # Index only specific extensions
   lgrep index --ext .go --ext .ts
 
+  # Read SVGs as text and make images findable by name
+  lgrep index --text-ext .svg --include-binary-names
+
   # Preview what would be indexed
   lgrep index --dry-run`,
 	Args: cobra.MaximumNArgs(1),
@@ -61,11 +73,32 @@ Examples:
 }
 
 func init() {
-	indexCmd.Flags().BoolVarP(&indexForce, "force", "f", false, "force re-index all files")
+	indexCmd.Flags().BoolVarP(&indexForce, "force", "f", false, "rebuild the store from scratch, dropping deleted files")
+	indexCmd.Flags().BoolVar(&indexBatch, "batch", false, "embed through the provider's batch API at lower cost; may take hours (openai only)")
 	indexCmd.Flags().BoolVarP(&indexDryRun, "dry-run", "d", false, "preview without indexing")
 	indexCmd.Flags().StringVar(&indexStore, "store", "", "store name (defaults to directory name)")
+	_ = indexCmd.RegisterFlagCompletionFunc("store", completeStoreNames)
 	indexCmd.Flags().StringSliceVarP(&indexExtensions, "ext", "e", nil, "file extensions to include (e.g., .go, .ts)")
 	indexCmd.Flags().StringSliceVarP(&indexIgnore, "ignore", "i", nil, "additional patterns to ignore")
+	indexCmd.Flags().IntVar(&indexMinFileSize, "min-file-size", 0, "skip files smaller than this many bytes (overrides indexing.min_file_size)")
+	indexCmd.Flags().StringSliceVar(&indexTextExtensions, "text-ext", nil, "extensions to read as text even if they look binary (e.g., .svg)")
+	indexCmd.Flags().BoolVar(&indexIncludeBinaryNames, "include-binary-names", false, "index the paths of binary files so assets can be found by name")
+}
+
+// indexConfig returns cfg with the file selection flags of the index
+// command applied.
+func indexConfig(cmd *cobra.Command, cfg *config.Config) *config.Config {
+	c := *cfg
+	if cmd.Flags().Changed("min-file-size") {
+		c.Indexing.MinFileSize = indexMinFileSize
+	}
+	if len(indexTextExtensions) > 0 {
+		c.Indexing.TextExtensions = append(append([]string{}, cfg.Indexing.TextExtensions...), indexTextExtensions...)
+	}
+	if indexIncludeBinaryNames {
+		c.Indexing.IncludeBinaryNames = true
+	}
+	return &c
 }
 
 func runIndex(cmd *cobra.Command, args []string) error {
@@ -91,24 +124,47 @@ func runIndex(cmd *cobra.Command, args []string) error {
 	}
 
 	// Get configuration
-	cfg := config.Get()
+	cfg := indexConfig(cmd, config.Get())
 
-	// Determine store name
-	storeName := indexStore
-	if storeName == "" {
-		storeName = filepath.Base(absPath)
+	// Determine store name. The root of a partitioned monorepo is indexed
+	// into one store per partition.
+	targets := []indexer.Partition{{Store: indexStore, Path: absPath}}
+	if indexStore == "" {
+		partitions, err := indexer.Partitions(cfg, absPath)
+		if err != nil {
+			return err
+		}
+		if len(partitions) > 0 {
+			targets = partitions
+			indexer.LogRootFiles(cfg, absPath)
+		} else {
+			targets[0].Store = indexer.StoreName(cfg, absPath)
+		}
 	}
 
 	log.Debug("Starting index",
 		"path", absPath,
-		"store", storeName,
+		"stores", len(targets),
 		"force", indexForce,
+		"batch", indexBatch,
 		"dry-run", indexDryRun,
 	)
 
+	if indexBatch && indexForce {
+		return withExitCode(ExitUsage, fmt.Errorf("--batch cannot be combined with --force"))
+	}
+
 	// Dry run mode - just show what would be indexed
 	if indexDryRun {
-		return runDryRun(absPath, cfg)
+		for i, target := range targets {
+			if i > 0 {
+				fmt.Println()
+			}
+			if err := runDryRun(target.Path, target.Store, cfg); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
 	// Setup context with cancellation
@@ -125,26 +181,43 @@ func runIndex(cmd *cobra.Command, args []string) error {
 	}()
 
 	// Open store
-	st, err := store.NewSQLiteStore(cfg.Database.Path)
+	st, err := store.NewSQLiteStore(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
 	}
 	defer st.Close()
 
 	// Create embedding service
-	emb, err := embeddings.NewService(cfg)
+	emb, err := newMeteredEmbedder(st, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create embedding service: %w", err)
+		return err
 	}
 
 	// Create indexer
 	idx := indexer.New(st, emb, cfg)
 
+	for i, target := range targets {
+		if i > 0 && !quiet {
+			fmt.Println()
+		}
+		// Follow aliases so a renamed store keeps its name
+		if err := indexStoreAt(ctx, st, emb, idx, cfg, resolveStoreName(st, target.Store), target.Path); err != nil || ctx.Err() != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexStoreAt indexes absPath into the store storeName, showing progress
+// and the final stats. Cancelling ctx stops indexing without an error.
+func indexStoreAt(ctx context.Context, st store.Store, emb *cost.Embedder, idx *indexer.Indexer, cfg *config.Config, storeName, absPath string) error {
 	// Show progress
-	fmt.Println(ui.Header.Render("Indexing " + storeName))
-	fmt.Printf("Path: %s\n", absPath)
-	fmt.Printf("Provider: %s (%s)\n", cfg.Embeddings.Provider, emb.ModelName())
-	fmt.Println()
+	if !quiet {
+		fmt.Println(ui.Header.Render("Indexing " + storeName))
+		fmt.Printf("Path: %s\n", absPath)
+		fmt.Printf("Provider: %s (%s)\n", cfg.Embeddings.Provider, emb.ModelName())
+		fmt.Println()
+	}
 
 	startTime := time.Now()
 	lastUpdate := time.Now()
@@ -156,17 +229,21 @@ func runIndex(cmd *cobra.Command, args []string) error {
 		Extensions:     indexExtensions,
 		IgnorePatterns: indexIgnore,
 		Force:          indexForce,
+		Batch:          indexBatch,
 		BatchSize:      50,
+		Trigger:        store.TriggerCLI,
 		OnProgress: func(p indexer.Progress) {
 			// Throttle updates to every 100ms
-			if time.Since(lastUpdate) < 100*time.Millisecond {
+			if quiet || time.Since(lastUpdate) < 100*time.Millisecond {
 				return
 			}
 			lastUpdate = time.Now()
 
 			// Clear line and print progress
 			fmt.Printf("\r\033[K")
-			if p.TotalFiles > 0 {
+			if p.BatchStatus != "" {
+				fmt.Printf("Waiting: %s | Files: %d/%d", p.BatchStatus, p.ProcessedFiles, p.TotalFiles)
+			} else if p.TotalFiles > 0 {
 				pct := float64(p.ProcessedFiles) / float64(p.TotalFiles) * 100
 				fmt.Printf("Progress: %d/%d files (%.0f%%) | Chunks: %d | %s",
 					p.ProcessedFiles, p.TotalFiles, pct, p.ProcessedChunks,
@@ -175,18 +252,27 @@ func runIndex(cmd *cobra.Command, args []string) error {
 		},
 	}
 
-	err = idx.Index(ctx, opts)
+	err := idx.Index(ctx, opts)
+	emb.Flush(st, storeName, cost.OpIndex)
 
 	// Clear progress line
-	fmt.Printf("\r\033[K")
+	if !quiet {
+		fmt.Printf("\r\033[K")
+	}
 
 	if err != nil {
 		if ctx.Err() != nil {
 			fmt.Println(ui.Warning.Render("Indexing cancelled"))
+			if indexBatch {
+				fmt.Println(ui.Dim.Render("Submitted batches keep running; index again to collect them."))
+			}
 			return nil
 		}
 		return fmt.Errorf("indexing failed: %w", err)
 	}
+	if quiet {
+		return nil
+	}
 
 	// Show final stats
 	duration := time.Since(startTime).Round(time.Millisecond)
@@ -200,49 +286,39 @@ func runIndex(cmd *cobra.Command, args []string) error {
 		fmt.Printf("  Chunks:   %d\n", stats.ChunkCount)
 		fmt.Printf("  Size:     %s\n", formatBytes(stats.TotalSize))
 		fmt.Printf("  Duration: %s\n", duration)
+		if stats.Usage.InputTokens > 0 {
+			fmt.Printf("  Usage:    %d tokens, %s to date\n", stats.Usage.InputTokens+stats.Usage.OutputTokens, cost.FormatUSD(stats.Usage.Cost))
+		}
 	}
 
 	return nil
 }
 
-// runDryRun shows what would be indexed without actually indexing.
-func runDryRun(path string, cfg *config.Config) error {
+// runDryRun shows what would be indexed without actually indexing, and how
+// it differs from what storeName already holds.
+func runDryRun(path, storeName string, cfg *config.Config) error {
 	fmt.Println(ui.Header.Render("Dry Run - Preview"))
-	fmt.Printf("Path: %s\n\n", path)
-
-	walker, err := fs.NewFileWalker(fs.WalkOptions{
-		Root:           path,
-		MaxFileSize:    int64(cfg.Indexing.MaxFileSize),
-		MaxFileCount:   cfg.Indexing.MaxFileCount,
-		IgnorePatterns: append(cfg.Ignore, indexIgnore...),
-		UseGitignore:   true,
-		Extensions:     indexExtensions,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create file walker: %w", err)
-	}
+	fmt.Printf("Path:  %s\n", path)
+	fmt.Printf("Store: %s\n\n", storeName)
 
-	var files []fs.FileInfo
-	err = walker.Walk(func(fi fs.FileInfo) error {
-		files = append(files, fi)
-		return nil
-	})
+	scan, err := indexer.Scan(cfg, path, indexExtensions, indexIgnore)
 	if err != nil {
-		return fmt.Errorf("failed to walk directory: %w", err)
+		return err
 	}
-
-	stats := walker.Stats()
+	files := scan.Files
+	stats := scan.Stats
 
 	// Show files by language
 	byLang := make(map[string]int)
-	var totalSize int64
 	for _, f := range files {
 		lang := f.Language
-		if lang == "" {
+		switch {
+		case f.Binary:
+			lang = "binary (name)"
+		case lang == "":
 			lang = "other"
 		}
 		byLang[lang]++
-		totalSize += f.Size
 	}
 
 	fmt.Println("Files to index:")
@@ -251,29 +327,150 @@ func runDryRun(path string, cfg *config.Config) error {
 	}
 	fmt.Println()
 	fmt.Printf("Total files:   %d\n", len(files))
-	fmt.Printf("Total size:    %s\n", formatBytes(totalSize))
+	fmt.Printf("Total size:    %s\n", formatBytes(scan.TotalSize))
 	fmt.Printf("Skipped:       %d files, %d directories\n", stats.FilesSkipped, stats.DirsSkipped)
 
-	if len(files) > 0 {
-		fmt.Println("\nFirst 10 files:")
-		for i, f := range files {
+	// Usage of the throughput probe is recorded, so the store is opened
+	// for writing
+	st, err := store.NewSQLiteStore(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+	storeName = resolveStoreName(st, storeName)
+
+	storeRecord, err := st.GetStore(storeName)
+	if err != nil {
+		return fmt.Errorf("failed to get store: %w", err)
+	}
+	diff, err := scan.Diff(st, storeRecord, cfg, 50, indexForce)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Printf("Added:         %d\n", len(diff.Added))
+	fmt.Printf("Changed:       %d\n", len(diff.Changed))
+	fmt.Printf("Unchanged:     %d\n", diff.Unchanged)
+	if indexForce {
+		fmt.Printf("Removed:       %d\n", len(diff.Removed))
+	} else {
+		fmt.Printf("Removed:       %d (kept in the store; --force drops them)\n", len(diff.Removed))
+	}
+	fmt.Printf("To embed:      %s, ~%d chunks in ~%d requests\n", formatBytes(diff.Bytes), diff.Chunks, diff.Requests)
+	printCostEstimate(diff.Bytes, cfg)
+	if diff.Chunks > 0 {
+		printTimeEstimate(st, storeName, diff, cfg)
+	}
+
+	printDryRunFiles("Added", diff.Added)
+	printDryRunFiles("Changed", diff.Changed)
+	if len(diff.Removed) > 0 {
+		fmt.Println("\nRemoved:")
+		for i, f := range diff.Removed {
 			if i >= 10 {
-				fmt.Printf("  ... and %d more\n", len(files)-10)
+				fmt.Printf("  ... and %d more\n", len(diff.Removed)-10)
 				break
 			}
-			fmt.Printf("  %s (%s)\n", f.RelPath, formatBytes(f.Size))
+			fmt.Printf("  %s\n", f.RelativePath)
 		}
 	}
 
 	return nil
 }
 
-// truncatePath shortens a path for display.
-func truncatePath(path string, maxLen int) string {
-	if len(path) <= maxLen {
-		return path
+// probeTimeout bounds the sample embedding of a dry run, which includes
+// loading the model of a local provider.
+const probeTimeout = time.Minute
+
+// printTimeEstimate embeds a few of the chunks diff would index with the
+// configured provider and shows how long indexing all of them would take
+// at that rate. A provider that cannot be reached leaves the time unknown.
+func printTimeEstimate(st store.Store, storeName string, diff *indexer.ScanDiff, cfg *config.Config) {
+	emb, err := newMeteredEmbedder(st, cfg)
+	if err != nil {
+		fmt.Printf("Est. time:     unknown (%v)\n", err)
+		return
 	}
-	return "..." + path[len(path)-maxLen+3:]
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	perChunk, err := diff.MeasureThroughput(ctx, emb, cfg)
+	emb.Flush(st, storeName, cost.OpIndex)
+	if err != nil {
+		fmt.Printf("Est. time:     unknown (%v)\n", err)
+		return
+	}
+
+	eta := (perChunk * time.Duration(diff.Chunks)).Round(time.Second)
+	fmt.Printf("Est. time:     ~%s (%s per chunk, %.0f chunks/s)\n",
+		max(eta, time.Second), perChunk.Round(time.Millisecond), float64(time.Second)/float64(max(perChunk, 1)))
+}
+
+// printDryRunFiles lists the first files of a dry run group.
+func printDryRunFiles(title string, files []fs.FileInfo) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for i, f := range files {
+		if i >= 10 {
+			fmt.Printf("  ... and %d more\n", len(files)-10)
+			break
+		}
+		fmt.Printf("  %s (%s)\n", f.RelPath, formatBytes(f.Size))
+	}
+}
+
+// printCostEstimate shows the projected cost of embedding totalBytes with
+// the configured provider.
+func printCostEstimate(totalBytes int64, cfg *config.Config) {
+	provider := cfg.Embeddings.Provider
+	model := configuredEmbeddingModel(cfg)
+	tokens := cost.EstimateIndexTokens(totalBytes, cfg)
+
+	fmt.Printf("Est. tokens:   ~%d\n", tokens)
+	if cost.IsLocal(provider) {
+		fmt.Printf("Est. cost:     free (%s %s)\n", provider, model)
+		return
+	}
+	price, ok := cost.Lookup(provider, model)
+	if !ok {
+		fmt.Printf("Est. cost:     unknown (no price for %s %s)\n", provider, model)
+		return
+	}
+	fmt.Printf("Est. cost:     %s (%s %s)\n", cost.FormatUSD(price.Cost(tokens, 0)), provider, model)
+}
+
+// configuredEmbeddingModel returns the model of the configured embedding provider.
+func configuredEmbeddingModel(cfg *config.Config) string {
+	for _, m := range configuredModels(cfg) {
+		if m.use == "embeddings" {
+			return m.model
+		}
+	}
+	return ""
+}
+
+// newMeteredEmbedder creates the configured embedding service, metered so
+// that cloud usage is recorded and budget.monthly_usd is enforced.
+func newMeteredEmbedder(st store.Store, cfg *config.Config) (*cost.Embedder, error) {
+	emb, err := embeddings.NewService(cfg)
+	if err != nil {
+		return nil, withExitCode(ExitProviderUnavailable, fmt.Errorf("failed to create embedding service: %w", err))
+	}
+
+	budget, err := cost.LoadBudget(st, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load budget: %w", err)
+	}
+
+	return cost.NewEmbedder(emb, budget), nil
+}
+
+// truncatePath shortens a path to maxWidth terminal cells for display,
+// keeping its end.
+func truncatePath(path string, maxWidth int) string {
+	return ui.TruncateStart(path, maxWidth)
 }
 
 // formatBytes formats bytes as human-readable string.
@@ -305,7 +502,7 @@ func init() {
 func runList(cmd *cobra.Command, args []string) error {
 	cfg := config.Get()
 
-	st, err := store.NewSQLiteStore(cfg.Database.Path)
+	st, err := store.NewSQLiteStoreReadOnly(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
 	}
@@ -318,11 +515,18 @@ func runList(cmd *cobra.Command, args []string) error {
 
 	if len(stores) == 0 {
 		fmt.Println("No indexed stores found.")
+		if others, err := st.ListNamespaces(); err == nil && len(others) > 0 {
+			fmt.Printf("\nOther namespaces have stores: %s\n", formatNamespaces(others))
+		}
 		fmt.Println("\nRun 'lgrep index [path]' to create one.")
 		return nil
 	}
 
-	fmt.Println(ui.Header.Render("Indexed Stores"))
+	header := "Indexed Stores"
+	if cfg.Database.Namespace != "" {
+		header += " in namespace " + cfg.Database.Namespace
+	}
+	fmt.Println(ui.Header.Render(header))
 	fmt.Println()
 
 	for _, s := range stores {
@@ -333,11 +537,17 @@ func runList(cmd *cobra.Command, args []string) error {
 		}
 
 		fmt.Printf("%s\n", ui.Highlight.Render(s.Name))
+		if aliases, err := st.GetStoreAliases(s.ID); err == nil && len(aliases) > 0 {
+			fmt.Printf("  Aliases:  %s\n", strings.Join(aliases, ", "))
+		}
 		fmt.Printf("  Path:     %s\n", s.RootPath)
 		fmt.Printf("  Model:    %s (%s)\n", s.EmbeddingModel, s.EmbeddingProvider)
 		fmt.Printf("  Files:    %d\n", stats.FileCount)
 		fmt.Printf("  Chunks:   %d\n", stats.ChunkCount)
 		fmt.Printf("  Size:     %s\n", formatBytes(stats.TotalSize))
+		if stats.Usage.InputTokens > 0 || stats.Usage.OutputTokens > 0 {
+			fmt.Printf("  Usage:    %d tokens, %s\n", stats.Usage.InputTokens+stats.Usage.OutputTokens, cost.FormatUSD(stats.Usage.Cost))
+		}
 		fmt.Printf("  Updated:  %s\n", s.UpdatedAt.Format("2006-01-02 15:04:05"))
 		fmt.Println()
 	}
@@ -345,16 +555,32 @@ func runList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// formatNamespaces lists namespaces for display, naming the default one.
+func formatNamespaces(namespaces []string) string {
+	names := make([]string, len(namespaces))
+	for i, ns := range namespaces {
+		if ns == "" {
+			ns = "(default)"
+		}
+		names[i] = ns
+	}
+	return strings.Join(names, ", ")
+}
+
+var deleteYes bool
+
 // deleteCmd represents the delete command for stores
 var deleteCmd = &cobra.Command{
-	Use:   "delete <store>",
-	Short: "Delete an indexed store",
-	Long:  `Delete an indexed store and all its data.`,
-	Args:  cobra.ExactArgs(1),
-	RunE:  runDelete,
+	Use:               "delete <store>",
+	Short:             "Delete an indexed store",
+	Long:              `Delete an indexed store and all its data.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeStoreArg,
+	RunE:              runDelete,
 }
 
 func init() {
+	deleteCmd.Flags().BoolVarP(&deleteYes, "yes", "y", false, "delete without confirmation")
 	rootCmd.AddCommand(deleteCmd)
 }
 
@@ -362,25 +588,31 @@ func runDelete(cmd *cobra.Command, args []string) error {
 	storeName := args[0]
 	cfg := config.Get()
 
-	st, err := store.NewSQLiteStore(cfg.Database.Path)
+	st, err := store.NewSQLiteStore(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
 	}
 	defer st.Close()
 
 	// Check if store exists
-	_, err = st.GetStore(storeName)
+	storeRecord, err := st.GetStore(storeName)
 	if err != nil {
-		return fmt.Errorf("store not found: %s", storeName)
+		return fmt.Errorf("failed to check store: %w", err)
+	}
+	if storeRecord == nil {
+		return withExitCode(ExitStoreMissing, fmt.Errorf("store not found: %s", storeName))
 	}
 
 	// Confirm deletion
-	fmt.Printf("Delete store '%s'? This will remove all indexed data. [y/N]: ", storeName)
-	var confirm string
-	fmt.Scanln(&confirm)
-	if strings.ToLower(confirm) != "y" {
-		fmt.Println("Cancelled.")
-		return nil
+	if !deleteYes {
+		ok, err := confirm(fmt.Sprintf("Delete store '%s'? This will remove all indexed data.", storeName))
+		if err != nil {
+			return fmt.Errorf("not deleting store '%s': %w; pass --yes to delete without confirmation", storeName, err)
+		}
+		if !ok {
+			fmt.Println("Cancelled.")
+			return nil
+		}
 	}
 
 	if err := st.DeleteStore(storeName); err != nil {
@@ -390,3 +622,61 @@ func runDelete(cmd *cobra.Command, args []string) error {
 	fmt.Println(ui.Success.Render(fmt.Sprintf("Store '%s' deleted.", storeName)))
 	return nil
 }
+
+var clearYes bool
+
+// clearCmd removes a store's indexed data but keeps the store
+var clearCmd = &cobra.Command{
+	Use:   "clear <store>",
+	Short: "Remove all indexed data from a store",
+	Long: `Remove all indexed files, chunks and embeddings from a store while
+keeping the store itself, including its name, aliases, root path and
+embedding model. Run 'lgrep index' afterwards to re-index from scratch.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeStoreArg,
+	RunE:              runClear,
+}
+
+func init() {
+	clearCmd.Flags().BoolVarP(&clearYes, "yes", "y", false, "clear without confirmation")
+	rootCmd.AddCommand(clearCmd)
+}
+
+func runClear(cmd *cobra.Command, args []string) error {
+	cfg := config.Get()
+
+	st, err := store.NewSQLiteStore(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	storeRecord, err := st.GetStore(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to check store: %w", err)
+	}
+	if storeRecord == nil {
+		return withExitCode(ExitStoreMissing, fmt.Errorf("store not found: %s", args[0]))
+	}
+
+	if !clearYes {
+		ok, err := confirm(fmt.Sprintf("Clear all indexed data from store '%s'?", storeRecord.Name))
+		if err != nil {
+			return fmt.Errorf("not clearing store '%s': %w; pass --yes to clear without confirmation", storeRecord.Name, err)
+		}
+		if !ok {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	// The indexer does not embed anything when clearing
+	idx := indexer.New(st, nil, cfg)
+	if err := idx.Clear(storeRecord.Name); err != nil {
+		return fmt.Errorf("failed to clear store: %w", err)
+	}
+
+	fmt.Println(ui.Success.Render(fmt.Sprintf("Store '%s' cleared.", storeRecord.Name)))
+	fmt.Printf("Run 'lgrep index %s --store %s' to re-index it.\n", storeRecord.RootPath, storeRecord.Name)
+	return nil
+}
@@ -0,0 +1,46 @@
+//go:build mangen
+
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+// manCmd writes a man page for every command. It is only built with the
+// mangen tag so that release binaries do not depend on the man page
+// renderer; use 'make man' to run it.
+var manCmd = &cobra.Command{
+	Use:    "man <dir>",
+	Short:  "Generate man pages",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := args[0]
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+
+		header := &doc.GenManHeader{
+			Title:   "LGREP",
+			Section: "1",
+			Source:  "lgrep " + version,
+			Manual:  "lgrep manual",
+		}
+
+		rootCmd.DisableAutoGenTag = true
+		if err := doc.GenManTree(rootCmd, header, dir); err != nil {
+			return fmt.Errorf("failed to generate man pages: %w", err)
+		}
+
+		fmt.Printf("Wrote man pages to %s\n", dir)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(manCmd)
+}
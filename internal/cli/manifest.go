@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/manifest"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+var manifestOutput string
+
+// manifestCmd prints the files a store has indexed as JSON.
+var manifestCmd = &cobra.Command{
+	Use:   "manifest <store>",
+	Short: "List a store's indexed files as JSON",
+	Long: `Print a JSON manifest of a store: its embedding model and totals, and
+every indexed file with the hash of its content when it was indexed, its
+size, its number of chunks and when it was indexed.
+
+Hashes are xxh64 hashes of the file content, so a CI job can check that an
+index matches a commit, and a file missing from the list was skipped by
+indexing (see 'lgrep index --dry-run' for why).
+
+Examples:
+  lgrep manifest myproject
+
+  # Files with no chunks
+  lgrep manifest myproject | jq -r '.files[] | select(.chunks == 0) | .path'
+
+  # Save it next to a build
+  lgrep manifest myproject -o index-manifest.json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeStoreArg,
+	RunE:              runManifest,
+}
+
+func init() {
+	manifestCmd.Flags().StringVarP(&manifestOutput, "output", "o", "", "write the manifest to this file (default stdout)")
+	rootCmd.AddCommand(manifestCmd)
+}
+
+func runManifest(cmd *cobra.Command, args []string) error {
+	cfg := config.Get()
+	st, err := store.NewSQLiteStoreReadOnly(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	storeRecord, err := st.GetStore(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to check store: %w", err)
+	}
+	if storeRecord == nil {
+		return withExitCode(ExitStoreMissing, fmt.Errorf("store not found: %s", args[0]))
+	}
+
+	m, err := manifest.Build(st, storeRecord)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if manifestOutput != "" && manifestOutput != "-" {
+		file, err = os.Create(manifestOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", manifestOutput, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := manifest.Write(w, m); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if file == nil {
+		return nil
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if !quiet {
+		fmt.Println(ui.Success.Render(fmt.Sprintf("Wrote the manifest of '%s' (%d files, %d chunks) to %s.",
+			storeRecord.Name, m.FileCount, m.ChunkCount, manifestOutput)))
+	}
+	return nil
+}
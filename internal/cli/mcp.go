@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -14,13 +15,15 @@ import (
 
 	"github.com/nickcecere/lgrep/internal/config"
 	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/indexer"
 	"github.com/nickcecere/lgrep/internal/mcp"
 	"github.com/nickcecere/lgrep/internal/store"
 	"github.com/nickcecere/lgrep/internal/watcher"
 )
 
 var (
-	mcpNoWatch bool
+	mcpNoWatch      bool
+	mcpAllowAnyPath bool
 )
 
 // mcpCmd represents the MCP server command.
@@ -32,9 +35,21 @@ var mcpCmd = &cobra.Command{
 The server communicates via stdin/stdout using JSON-RPC 2.0 and provides tools for:
   - lgrep_search: Semantic code search
   - lgrep_index: Index a directory
+  - lgrep_read: Read lines of an indexed file
+
+It also offers prompts that walk agents through common retrieval tasks:
+  - explain-this-code-area: Explain how an area of the codebase works
+  - find-implementation: Find where a feature is implemented
 
 By default, the server also starts a background file watcher to keep the index
-up-to-date. Use --no-watch to disable this.
+up-to-date, and sends a notifications/lgrep/indexUpdated notification after
+each update so agents know fresh results are available. Use --no-watch to
+disable this.
+
+Tools only index, search and read directories under mcp.allowed_roots, which
+defaults to the directory the server was started in. Use --allow-any-path to
+lift this restriction. Set mcp.audit_log to record every tool call, with its
+arguments, result size and latency, as a JSON line in that file.
 
 This command is typically invoked by AI agents (Claude Code, OpenCode, Codex) and
 not run directly by users.`,
@@ -43,6 +58,7 @@ not run directly by users.`,
 
 func init() {
 	mcpCmd.Flags().BoolVar(&mcpNoWatch, "no-watch", false, "disable background file watching")
+	mcpCmd.Flags().BoolVar(&mcpAllowAnyPath, "allow-any-path", false, "let tools access any directory, ignoring mcp.allowed_roots")
 }
 
 func runMcpCmd(cmd *cobra.Command, args []string) error {
@@ -63,34 +79,67 @@ func runMcpCmd(cmd *cobra.Command, args []string) error {
 	go func() {
 		sig := <-sigCh
 		log.Info("Received signal, shutting down", "signal", sig)
+		// A second signal kills the process, skipping queued changes
+		signal.Stop(sigCh)
 		cancel()
 	}()
 
 	// Open store
-	st, err := store.NewSQLiteStore(cfg.Database.Path)
+	st, err := store.NewSQLiteStore(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
 	}
 	defer st.Close()
+	checkDatabase(st, cfg)
 
 	// Create embedding service
-	emb, err := embeddings.NewService(cfg)
+	emb, err := newMeteredEmbedder(st, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create embedding service: %w", err)
+		return err
+	}
+
+	// Create the MCP server
+	server := mcp.NewServer(st, emb, cfg)
+	if mcpAllowAnyPath {
+		server.AllowAnyPath()
 	}
 
 	// Start background file watcher if enabled
+	var bgWatcher atomic.Pointer[watcher.Watcher]
+	watcherDone := make(chan struct{})
 	if !mcpNoWatch {
-		go startBackgroundWatcher(ctx, st, emb, cfg)
+		go func() {
+			defer close(watcherDone)
+			startBackgroundWatcher(ctx, st, emb, server, &bgWatcher)
+		}()
+	} else {
+		close(watcherDone)
 	}
 
-	// Create and run MCP server
-	server := mcp.NewServer(st, emb, cfg)
-	return server.Run(ctx)
+	togglePauseOnSignal(ctx, bgWatcher.Load)
+
+	// Apply config file edits without a restart
+	stopConfigWatch := config.Watch(func(c *config.Config) {
+		server.SetConfig(c)
+		if w := bgWatcher.Load(); w != nil {
+			w.SetConfig(c)
+		}
+	})
+	defer stopConfigWatch()
+
+	err = server.Run(ctx)
+
+	// Let the watcher handle or save its queued changes before the store
+	// is closed
+	cancel()
+	<-watcherDone
+	return err
 }
 
-// startBackgroundWatcher starts a file watcher for the current directory.
-func startBackgroundWatcher(ctx context.Context, st store.Store, emb embeddings.Service, cfg *config.Config) {
+// startBackgroundWatcher starts a file watcher for the current directory,
+// storing it in started so config reloads can reach it. The server's client
+// is notified of each update.
+func startBackgroundWatcher(ctx context.Context, st store.Store, emb embeddings.Service, server *mcp.Server, started *atomic.Pointer[watcher.Watcher]) {
 	// Wait a bit before starting to let the MCP server initialize
 	select {
 	case <-ctx.Done():
@@ -111,7 +160,15 @@ func startBackgroundWatcher(ctx context.Context, st store.Store, emb embeddings.
 		return
 	}
 
-	storeName := filepath.Base(absPath)
+	// Includes reloads since the server started
+	cfg := config.Get()
+
+	// A single watcher cannot feed the partitions of a monorepo
+	if partitions, _ := indexer.Partitions(cfg, absPath); len(partitions) > 0 {
+		log.Info("Not watching a partitioned monorepo; run 'lgrep watch' to keep its partitions up to date", "path", absPath)
+		return
+	}
+	storeName := resolveStoreName(st, indexer.StoreName(cfg, absPath))
 
 	log.Info("Starting background file watcher", "path", absPath)
 
@@ -125,12 +182,14 @@ func startBackgroundWatcher(ctx context.Context, st store.Store, emb embeddings.
 		watcher.WithDebounceTime(1*time.Second),
 		watcher.WithEventCallback(func(event, path string) {
 			log.Debug("Background watcher event", "event", event, "path", path)
+			server.NotifyIndexUpdated(storeName, path)
 		}),
 	)
 	if err != nil {
 		log.Error("Failed to create watcher", "error", err)
 		return
 	}
+	started.Store(w)
 
 	// Start watching (blocks until context is cancelled)
 	if err := w.Start(ctx); err != nil && ctx.Err() == nil {
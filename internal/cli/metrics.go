@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/cost"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+var (
+	metricsDays int
+	metricsJSON bool
+)
+
+// metricsCmd shows locally recorded usage metrics.
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Show local usage metrics",
+	Long: `Show how often lgrep has searched, indexed and answered, and where the
+time went.
+
+Searches are split into query embedding and database time, index runs into
+embedding and database time, and watcher activity into batches of file
+events. Answers show the LLM tokens used, as reported by the provider, and
+their estimated cost, in total and per store. Metrics are stored only in the
+local database and are never sent anywhere.
+
+Examples:
+  # Metrics for the last 30 days
+  lgrep metrics
+
+  # Metrics for the last week as JSON
+  lgrep metrics --days 7 --json`,
+	Args: cobra.NoArgs,
+	RunE: runMetrics,
+}
+
+func init() {
+	metricsCmd.Flags().IntVar(&metricsDays, "days", 30, "number of days to include (0 for all time)")
+	metricsCmd.Flags().BoolVar(&metricsJSON, "json", false, "output metrics as JSON")
+}
+
+// metricsReport is the JSON output of 'lgrep metrics'.
+type metricsReport struct {
+	Totals []store.MetricSummary `json:"totals"` // By event
+	Stores []store.MetricSummary `json:"stores"` // Answers by store
+}
+
+func runMetrics(cmd *cobra.Command, args []string) error {
+	cfg := config.Get()
+
+	st, err := store.NewSQLiteStoreReadOnly(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	var since time.Time
+	if metricsDays > 0 {
+		since = time.Now().AddDate(0, 0, -metricsDays)
+	}
+
+	summaries, err := st.SummarizeMetrics(since)
+	if err != nil {
+		return err
+	}
+	answersByStore, err := st.SummarizeMetricsByStore(store.MetricAnswer, since)
+	if err != nil {
+		return err
+	}
+
+	if metricsJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(metricsReport{
+			Totals: append([]store.MetricSummary{}, summaries...),
+			Stores: append([]store.MetricSummary{}, answersByStore...),
+		})
+	}
+
+	period := "all time"
+	if metricsDays > 0 {
+		period = fmt.Sprintf("last %d days", metricsDays)
+	}
+	fmt.Println(ui.Header.Render("Metrics (" + period + ")"))
+	fmt.Println()
+
+	if len(summaries) == 0 {
+		fmt.Println("No activity recorded.")
+		return nil
+	}
+
+	for _, m := range summaries {
+		switch m.Event {
+		case store.MetricSearch:
+			fmt.Println(ui.Bold.Render("Searches"))
+			fmt.Printf("  Count:       %d\n", m.Runs)
+			fmt.Printf("  Avg latency: %s (embed %s, db %s)\n",
+				formatMs(m.AvgDuration), formatMs(m.AvgEmbed), formatMs(m.AvgDB))
+			fmt.Printf("  Slowest:     %s\n", formatMs(m.MaxDuration))
+		case store.MetricIndex:
+			fmt.Println(ui.Bold.Render("Index runs"))
+			fmt.Printf("  Count:        %d\n", m.Runs)
+			fmt.Printf("  Avg duration: %s (embed %s, db %s)\n",
+				formatMs(m.AvgDuration), formatMs(m.AvgEmbed), formatMs(m.AvgDB))
+			fmt.Printf("  Longest:      %s\n", formatMs(m.MaxDuration))
+			fmt.Printf("  Files:        %d indexed\n", m.TotalCount)
+		case store.MetricAnswer:
+			fmt.Println(ui.Bold.Render("Answers"))
+			fmt.Printf("  Count:       %d\n", m.Runs)
+			fmt.Printf("  Avg latency: %s\n", formatMs(m.AvgDuration))
+			fmt.Printf("  Tokens:      %s\n", formatTokens(m))
+			if len(answersByStore) > 1 {
+				fmt.Println("  By store:")
+				for _, s := range answersByStore {
+					fmt.Printf("    %s: %d answers, %s\n", s.StoreName, s.Runs, formatTokens(s))
+				}
+			}
+		case store.MetricWatch:
+			fmt.Println(ui.Bold.Render("Watcher"))
+			fmt.Printf("  Batches:     %d (%d file events)\n", m.Runs, m.TotalCount)
+			fmt.Printf("  Avg latency: %s\n", formatMs(m.AvgDuration))
+			fmt.Printf("  Slowest:     %s\n", formatMs(m.MaxDuration))
+		default:
+			fmt.Println(ui.Bold.Render(m.Event))
+			fmt.Printf("  Count:       %d\n", m.Runs)
+			fmt.Printf("  Avg latency: %s\n", formatMs(m.AvgDuration))
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// formatTokens formats the LLM tokens of a summary, with their cost if any.
+func formatTokens(m store.MetricSummary) string {
+	s := fmt.Sprintf("%d prompt, %d completion", m.InputTokens, m.OutputTokens)
+	if m.Cost > 0 {
+		s += fmt.Sprintf(" (%s)", cost.FormatUSD(m.Cost))
+	}
+	return s
+}
+
+// formatMs formats a duration rounded to the millisecond.
+func formatMs(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
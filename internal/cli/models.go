@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/httpclient"
+	"github.com/nickcecere/lgrep/internal/models"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+// modelsCmd represents the models command
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List available embedding and chat models",
+	Long: `Query the configured providers and list the models they offer.
+
+For Ollama, the installed models are listed with their embedding dimensions
+and context sizes. For OpenAI, the models available to your API key are
+listed. Models referenced in your configuration that the provider does not
+have are flagged, so missing downloads are caught before indexing.
+
+Examples:
+  # List models for the configured providers
+  lgrep models`,
+	Args: cobra.NoArgs,
+	RunE: runModels,
+}
+
+// configuredModel is a model referenced in the configuration.
+type configuredModel struct {
+	use      string // "embeddings" or "llm"
+	provider string
+	url      string // Ollama URL or OpenAI base URL
+	apiKey   string
+	model    string
+	http     config.HTTPConfig
+}
+
+func runModels(cmd *cobra.Command, args []string) error {
+	cfg := config.Get()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	configured := configuredModels(cfg)
+
+	// Query each distinct provider endpoint once
+	type endpoint struct{ provider, url string }
+	available := make(map[endpoint][]models.Model)
+	failed := make(map[endpoint]error)
+	var order []endpoint
+
+	for _, c := range configured {
+		ep := endpoint{c.provider, c.url}
+		if _, ok := available[ep]; ok {
+			continue
+		}
+		if _, ok := failed[ep]; ok {
+			continue
+		}
+		order = append(order, ep)
+
+		client, err := httpclient.New(cfg.Network, c.http)
+		if err != nil {
+			failed[ep] = err
+			continue
+		}
+
+		var list []models.Model
+		switch c.provider {
+		case "ollama":
+			client, url := httpclient.Endpoint(client, c.url)
+			list, err = models.ListOllama(ctx, client, url)
+		case "openai":
+			list, err = models.ListOpenAI(ctx, client, c.apiKey, c.url)
+			for i := range list {
+				list[i].Dimensions = embeddings.GetModelDimensions(list[i].Name)
+			}
+		default:
+			err = fmt.Errorf("model listing is not supported for %s", c.provider)
+		}
+
+		if err != nil {
+			failed[ep] = err
+			continue
+		}
+		available[ep] = list
+	}
+
+	for _, ep := range order {
+		title := ep.provider
+		if ep.url != "" {
+			title += " (" + ep.url + ")"
+		}
+		fmt.Println(ui.SectionTitle.Render(title))
+		fmt.Println()
+
+		if err, ok := failed[ep]; ok {
+			fmt.Printf("  %s %v\n", ui.Error.Render("✗"), err)
+			fmt.Println()
+			continue
+		}
+
+		list := available[ep]
+		if len(list) == 0 {
+			fmt.Println(ui.Dim.Render("  No models found."))
+			fmt.Println()
+			continue
+		}
+
+		for _, m := range list {
+			fmt.Printf("  %-40s %s\n", m.Name, ui.Dim.Render(modelDetails(m)))
+		}
+		fmt.Println()
+	}
+
+	// Flag configured models the provider does not have
+	fmt.Println(ui.SectionTitle.Render("Configured Models"))
+	fmt.Println()
+	for _, c := range configured {
+		ep := endpoint{c.provider, c.url}
+		label := fmt.Sprintf("%-11s %s/%s", c.use+":", c.provider, c.model)
+
+		if _, ok := failed[ep]; ok {
+			fmt.Printf("  %s %s %s\n", ui.Warning.Render("?"), label, ui.Dim.Render("(provider unavailable)"))
+			continue
+		}
+
+		if models.Find(available[ep], c.model) != nil {
+			fmt.Printf("  %s %s\n", ui.Success.Render("✓"), label)
+			continue
+		}
+
+		hint := "(not found)"
+		if c.provider == "ollama" {
+			hint = fmt.Sprintf("(not found, run 'ollama pull %s')", c.model)
+		}
+		fmt.Printf("  %s %s %s\n", ui.Error.Render("✗"), label, ui.Dim.Render(hint))
+	}
+
+	return nil
+}
+
+// configuredModels returns the embedding and LLM models selected in the configuration.
+func configuredModels(cfg *config.Config) []configuredModel {
+	var list []configuredModel
+
+	switch cfg.Embeddings.Provider {
+	case "ollama":
+		list = append(list, configuredModel{"embeddings", "ollama", cfg.Embeddings.Ollama.URL, "", cfg.Embeddings.Ollama.Model, cfg.Embeddings.Ollama.HTTPConfig})
+	case "openai":
+		list = append(list, configuredModel{"embeddings", "openai", cfg.Embeddings.OpenAI.BaseURL, cfg.Embeddings.OpenAI.APIKey, cfg.Embeddings.OpenAI.Model, cfg.Embeddings.OpenAI.HTTPConfig})
+	case "voyage":
+		list = append(list, configuredModel{"embeddings", "voyage", cfg.Embeddings.Voyage.BaseURL, cfg.Embeddings.Voyage.APIKey, cfg.Embeddings.Voyage.Model, cfg.Embeddings.Voyage.HTTPConfig})
+	case "cohere":
+		list = append(list, configuredModel{"embeddings", "cohere", cfg.Embeddings.Cohere.BaseURL, cfg.Embeddings.Cohere.APIKey, cfg.Embeddings.Cohere.Model, cfg.Embeddings.Cohere.HTTPConfig})
+	}
+
+	switch cfg.LLM.Provider {
+	case "ollama":
+		list = append(list, configuredModel{"llm", "ollama", cfg.LLM.Ollama.URL, "", cfg.LLM.Ollama.Model, cfg.LLM.Ollama.HTTPConfig})
+	case "openai":
+		list = append(list, configuredModel{"llm", "openai", cfg.LLM.OpenAI.BaseURL, cfg.LLM.OpenAI.APIKey, cfg.LLM.OpenAI.Model, cfg.LLM.OpenAI.HTTPConfig})
+	case "anthropic":
+		list = append(list, configuredModel{"llm", "anthropic", "", cfg.LLM.Anthropic.APIKey, cfg.LLM.Anthropic.Model, cfg.LLM.Anthropic.HTTPConfig})
+	}
+
+	return list
+}
+
+// modelDetails formats a model's kind, dimensions, context size and size.
+func modelDetails(m models.Model) string {
+	details := string(m.Kind)
+	if m.Dimensions > 0 {
+		details += fmt.Sprintf(", %d dims", m.Dimensions)
+	}
+	if m.ContextLength > 0 {
+		details += fmt.Sprintf(", %d ctx", m.ContextLength)
+	}
+	if m.Size > 0 {
+		details += ", " + formatBytes(m.Size)
+	}
+	return details
+}
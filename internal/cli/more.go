@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/search"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+// sessionResults is how many results a search asks for once it is paged,
+// unless the pages asked for need more.
+const sessionResults = 100
+
+var (
+	moreLimit   int
+	moreContent bool
+	moreContext contextFlags
+	moreJSON    bool
+)
+
+// moreCmd shows the next results of the previous search.
+var moreCmd = &cobra.Command{
+	Use:   "more",
+	Short: "Show the next results of the previous search",
+	Long: `Show the next page of results of the most recent search, from the
+results it logged rather than by searching again, so paging is free.
+
+A search only keeps the results it showed. 'lgrep search --page N' shows a
+given page, keeping up to 100 results for the pages after it, and only
+searches again when the previous search was different or kept too few.
+
+Searches that open the database read-only, such as those with
+search.auto_index set to never and a local embedding provider, are not
+logged, so 'lgrep more' continues the search before them. With search.log
+set to false no search is logged, and 'lgrep more' has none to continue.
+
+Examples:
+  lgrep search "error handling" --page 2
+  lgrep more
+
+  # The next 20, with content
+  lgrep more -m 20 -c`,
+	Args: cobra.NoArgs,
+	RunE: runMore,
+}
+
+func init() {
+	moreCmd.Flags().IntVarP(&moreLimit, "limit", "m", 10, "maximum number of results")
+	moreCmd.Flags().BoolVarP(&moreContent, "content", "c", false, "show content snippets in results")
+	moreContext.register(moreCmd)
+	moreCmd.Flags().BoolVar(&moreJSON, "json", false, "output results as JSON")
+	rootCmd.AddCommand(moreCmd)
+}
+
+func runMore(cmd *cobra.Command, args []string) error {
+	if moreLimit < 1 {
+		return withExitCode(ExitUsage, fmt.Errorf("invalid --limit %d: must be 1 or more", moreLimit))
+	}
+
+	cfg := config.Get()
+	if !cfg.Search.Log {
+		return withExitCode(ExitUsage, errors.New("searches are not logged, so there is none to continue; set search.log to true"))
+	}
+	st, err := store.NewSQLiteStore(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	session, err := st.GetLastSearch()
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return withExitCode(ExitUsage, errors.New("no search to continue; run 'lgrep search' first"))
+	}
+	storeRecord, err := st.GetStoreByID(session.StoreID)
+	if err != nil {
+		return fmt.Errorf("failed to check store: %w", err)
+	}
+	if storeRecord == nil {
+		return withExitCode(ExitStoreMissing, errors.New("the store of the previous search no longer exists"))
+	}
+
+	// Results of the next page are rebuilt from the store, so no embedder
+	// is needed
+	total := len(session.Results)
+	offset := min(session.Shown, total)
+	shown := min(offset+moreLimit, total)
+	searcher := search.New(st, nil)
+	results, err := searcher.ResultsFor(session.Results[offset:shown], search.SearchOptions{
+		IncludeContent: moreContent || moreContext.requested(cmd),
+		LinesBefore:    moreContext.linesBefore(cmd),
+		LinesAfter:     moreContext.linesAfter(cmd),
+	})
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		if !quiet {
+			fmt.Printf("No more results for %q; all %d kept were shown. Search with --page for more.\n", session.Query, total)
+		}
+		cmd.SilenceErrors = true
+		return errNoResults
+	}
+	searcher.MarkStale(storeRecord, results)
+	showSession(st, session, shown)
+
+	if moreJSON {
+		return outputJSON(results, offset, nil)
+	}
+	if quiet {
+		displayQuiet(results)
+		return nil
+	}
+
+	fmt.Println(ui.Dim.Render(fmt.Sprintf("Continuing %q in store '%s'", session.Query, storeRecord.Name)))
+	displayResults(results, offset, total, moreContent || moreContext.requested(cmd), newSnippetRenderer(cfg, session.Query))
+	printStaleHint(results)
+	if shown < total {
+		fmt.Println(ui.Dim.Render("Run 'lgrep more' for the next results."))
+	}
+	return nil
+}
+
+// sessionTopK returns how many results a search asks for to show page. The
+// first page asks for its own results only; once a search is paged it asks
+// for sessionResults, or enough for page, up to search.MaxTopK, so the
+// pages after it can be shown from the log.
+func sessionTopK(limit, page int) int {
+	if page <= 1 {
+		return limit
+	}
+	return min(max(sessionResults, limit*page), search.MaxTopK)
+}
+
+// sessionKey identifies a search by its query and the options that decide
+// its results and their order, so a page is only served from the log for
+// the same search.
+func sessionKey(query string, opts search.SearchOptions, expand bool) string {
+	grep, prefilter := "", ""
+	if opts.Grep != nil {
+		grep = opts.Grep.String()
+	}
+	if opts.Prefilter != nil {
+		prefilter = opts.Prefilter.String()
+	}
+	prefixes := make([]string, 0, len(opts.PathBoost))
+	for prefix, factor := range opts.PathBoost {
+		prefixes = append(prefixes, fmt.Sprintf("%q=%g", prefix, factor))
+	}
+	sort.Strings(prefixes)
+	h := sha256.New()
+	fmt.Fprintf(h, "%q %d %g %g %q %q %q %d %q %t %t",
+		query, opts.TopK, opts.MinScore, opts.MinRelevance, grep, prefilter, opts.PathPrefix, opts.PerFile,
+		strings.Join(opts.ExcludeTerms, "\x00"), opts.ExcludeGenerated, expand)
+	fmt.Fprintf(h, " %g %g %s %g %s %g",
+		opts.RelatedBoost, opts.LanguageBoost, opts.RecencyHalfLife, opts.RecencyWeight,
+		strings.Join(prefixes, ","), opts.FeedbackWeight)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// findSession returns the previous search if it is the same search in the
+// same store, so that page can be shown from its log, or nil to search
+// again. The first page is always searched afresh.
+func findSession(st store.Store, storeRecord *store.StoreRecord, key string, page int) *store.SearchLogEntry {
+	if page <= 1 {
+		return nil
+	}
+	session, err := st.GetLastSearch()
+	if err != nil {
+		log.Debug("Failed to get the previous search", "error", err)
+		return nil
+	}
+	if session == nil || session.StoreID != storeRecord.ID || session.Key != key {
+		return nil
+	}
+	return session
+}
+
+// showSession records that the results of a logged search up to shown have
+// been shown, so 'lgrep more' continues after them.
+func showSession(st store.Store, session *store.SearchLogEntry, shown int) {
+	if session.ID == 0 {
+		return
+	}
+	if err := st.SetSearchShown(session.ID, shown); err != nil {
+		log.Debug("Failed to update the search log", "error", err)
+	}
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nickcecere/lgrep/internal/search"
+)
+
+// TestSessionTopK tests that only paged searches ask for more results than
+// they show.
+func TestSessionTopK(t *testing.T) {
+	assert.Equal(t, 10, sessionTopK(10, 1))
+	assert.Equal(t, sessionResults, sessionTopK(10, 2))
+	assert.Equal(t, 150, sessionTopK(50, 3))
+	assert.Equal(t, search.MaxTopK, sessionTopK(search.MaxTopK, 2))
+}
+
+// TestSessionKey tests that a search is identified by every option that
+// decides its results and their order.
+func TestSessionKey(t *testing.T) {
+	base := search.SearchOptions{TopK: 100, PathBoost: map[string]float64{"docs/": 1.2, "legacy/": 0.5}}
+	key := sessionKey("retry", base, false)
+	assert.Equal(t, key, sessionKey("retry", base, false))
+
+	tests := []struct {
+		name   string
+		change func(opts *search.SearchOptions)
+	}{
+		{"top k", func(opts *search.SearchOptions) { opts.TopK = 10 }},
+		{"min relevance", func(opts *search.SearchOptions) { opts.MinRelevance = 40 }},
+		{"related boost", func(opts *search.SearchOptions) { opts.RelatedBoost = 0.02 }},
+		{"language boost", func(opts *search.SearchOptions) { opts.LanguageBoost = 0.05 }},
+		{"recency half-life", func(opts *search.SearchOptions) { opts.RecencyHalfLife = 720 * time.Hour }},
+		{"recency weight", func(opts *search.SearchOptions) { opts.RecencyWeight = 0.1 }},
+		{"path boost", func(opts *search.SearchOptions) { opts.PathBoost = map[string]float64{"docs/": 1.5, "legacy/": 0.5} }},
+		{"feedback weight", func(opts *search.SearchOptions) { opts.FeedbackWeight = 0.1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := base
+			tt.change(&opts)
+			assert.NotEqual(t, key, sessionKey("retry", opts, false))
+		})
+	}
+	assert.NotEqual(t, key, sessionKey("retry", base, true), "expanded")
+	assert.NotEqual(t, key, sessionKey("retries", base, false), "query")
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// errNotInteractive is returned by confirm when there is no terminal to ask
+// or --non-interactive is set.
+var errNotInteractive = errors.New("confirmation required but running non-interactively")
+
+// confirm asks a yes/no question on the terminal and reports whether the
+// answer was yes. It returns errNotInteractive instead of waiting for input
+// that will never come when stdin is a pipe, a file or closed, and instead of
+// asking at all with --non-interactive.
+func confirm(prompt string) (bool, error) {
+	if nonInteractive || !isTerminal(os.Stdin) {
+		return false, errNotInteractive
+	}
+
+	fmt.Printf("%s [y/N]: ", prompt)
+	var answer string
+	fmt.Scanln(&answer)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// isTerminal reports whether f is attached to an interactive terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
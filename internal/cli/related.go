@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/search"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+var (
+	relatedStore string
+	relatedJSON  bool
+)
+
+// relatedCmd lists the files related to a file by imports.
+var relatedCmd = &cobra.Command{
+	Use:   "related <path>",
+	Short: "List the files a file imports and the files importing it",
+	Long: `Show the indexed files that a file imports, and those that import it, from
+the file relations recorded when the store was indexed. Imports are resolved
+to files by path, so packages from outside the store are not listed.
+
+Relations are recorded for languages whose imports lgrep understands, at the
+end of each 'lgrep index' run unless indexing.relations is off. Stores indexed
+by an earlier version need 'lgrep index --force' to record the imports first.
+
+Search also uses the relations: results from files related to the top
+results' files get a small boost (search.related_boost).
+
+Examples:
+  # What does this file depend on, and what depends on it?
+  lgrep related internal/store/sqlite.go
+
+  # As JSON
+  lgrep related internal/store/sqlite.go --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRelated,
+}
+
+func init() {
+	relatedCmd.Flags().StringVar(&relatedStore, "store", "", "store name (auto-detected if not specified)")
+	_ = relatedCmd.RegisterFlagCompletionFunc("store", completeStoreNames)
+	relatedCmd.Flags().BoolVar(&relatedJSON, "json", false, "output the related files as JSON")
+	rootCmd.AddCommand(relatedCmd)
+}
+
+// relatedFiles is the JSON output of 'lgrep related'.
+type relatedFiles struct {
+	File       string   `json:"file"`
+	Imports    []string `json:"imports"`
+	ImportedBy []string `json:"imported_by"`
+}
+
+func runRelated(cmd *cobra.Command, args []string) error {
+	absPath, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+
+	cfg := config.Get()
+	st, err := store.NewSQLiteStoreReadOnly(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	// Relations are read from the store, so no embedder is needed
+	var storeRecord *store.StoreRecord
+	if relatedStore != "" {
+		storeRecord, err = st.GetStore(relatedStore)
+	} else {
+		storeRecord, err = search.New(st, nil).GetStoreForPath(absPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check store: %w", err)
+	}
+	if storeRecord == nil {
+		if relatedStore != "" {
+			return withExitCode(ExitStoreMissing, fmt.Errorf("store not found: %s", relatedStore))
+		}
+		return withExitCode(ExitStoreMissing, fmt.Errorf("no indexed store contains %s; pass --store or run 'lgrep index' first", absPath))
+	}
+
+	relPath, err := filepath.Rel(storeRecord.RootPath, absPath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside store '%s' (%s)", args[0], storeRecord.Name, storeRecord.RootPath)
+	}
+
+	file, err := st.GetFileByExternalID(storeRecord.ID, relPath)
+	if err != nil {
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+	if file == nil {
+		return fmt.Errorf("%s is not indexed in store '%s'", relPath, storeRecord.Name)
+	}
+
+	imports, importedBy, err := st.GetRelatedFiles(file.ID)
+	if err != nil {
+		return err
+	}
+
+	if relatedJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(relatedFiles{
+			File:       file.RelativePath,
+			Imports:    relativePaths(imports),
+			ImportedBy: relativePaths(importedBy),
+		})
+	}
+
+	if quiet {
+		for _, f := range append(imports, importedBy...) {
+			fmt.Println(f.RelativePath)
+		}
+		return nil
+	}
+
+	fmt.Printf("%s %s\n\n", ui.Header.Render("File:"), ui.FilePath.Render(file.RelativePath))
+	displayRelatedFiles("Imports", imports)
+	fmt.Println()
+	displayRelatedFiles("Imported by", importedBy)
+
+	if len(imports) == 0 && len(importedBy) == 0 {
+		count, err := st.CountFileRelations(storeRecord.ID)
+		if err == nil && count == 0 {
+			fmt.Println()
+			fmt.Println(ui.Warning.Render("This store has no recorded file relations. Run 'lgrep index --force' to record them."))
+		}
+	}
+	return nil
+}
+
+// displayRelatedFiles prints a titled list of files.
+func displayRelatedFiles(title string, files []store.FileRecord) {
+	if len(files) == 0 {
+		fmt.Printf("%s: %s\n", title, ui.Dim.Render("none"))
+		return
+	}
+	fmt.Println(ui.Header.Render(fmt.Sprintf("%s (%d):", title, len(files))))
+	for _, f := range files {
+		fmt.Printf("  %s\n", ui.FilePath.Render(f.RelativePath))
+	}
+}
+
+// relativePaths returns the relative paths of files, never nil so it
+// encodes as an empty JSON array.
+func relativePaths(files []store.FileRecord) []string {
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.RelativePath)
+	}
+	return paths
+}
@@ -2,13 +2,16 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/store"
 	"github.com/nickcecere/lgrep/internal/ui"
 )
 
@@ -21,6 +24,16 @@ var (
 	// Global flags
 	cfgFile string
 	debug   bool
+	quiet   bool
+
+	// namespace selects the database namespace; see database.namespace
+	namespace string
+
+	// nonInteractive makes every prompt fail instead of asking
+	nonInteractive bool
+
+	// timeout bounds the provider calls of a search; zero means no limit
+	timeout time.Duration
 )
 
 // SetVersionInfo sets the version information from build flags.
@@ -51,7 +64,10 @@ Examples:
   lgrep "how does authentication work" -a
 
   # Search a specific directory
-  lgrep "database queries" ./src`,
+  lgrep "database queries" ./src
+
+  # Read about every configuration key
+  lgrep help config`,
 	Args: cobra.MaximumNArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// If no args, show help
@@ -60,9 +76,12 @@ Examples:
 		}
 
 		// Otherwise, run search command
-		return runSearch(cmd, args)
+		return runSearchCmd(cmd, args)
 	},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Arguments and flags are valid, so later errors are not usage errors
+		cmd.SilenceUsage = true
+
 		// Set up logging based on debug flag
 		if debug {
 			log.SetLevel(log.DebugLevel)
@@ -73,6 +92,10 @@ Examples:
 		if err := config.Load(cfgFile); err != nil {
 			log.Warn("Failed to load config", "error", err)
 		}
+		if err := store.ValidateNamespace(config.Get().Database.Namespace); err != nil {
+			return withExitCode(ExitUsage, err)
+		}
+		ui.Configure(config.Get().UI.NoColor, config.Get().UI.Background)
 
 		return nil
 	},
@@ -80,6 +103,7 @@ Examples:
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Pass the returned error to ExitCode for the process exit code.
 func Execute() error {
 	return rootCmd.Execute()
 }
@@ -91,9 +115,14 @@ func init() {
 	// Persistent flags (available to all commands)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.config/lgrep/config.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "enable debug logging")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "print only results, without headers or progress")
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "never prompt; commands that need confirmation fail unless --yes is given")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "give up on a search or answer that takes longer than this, e.g. 30s (0 for no limit)")
+	rootCmd.PersistentFlags().StringVar(&namespace, "namespace", "", "use the stores of this namespace in a shared database (default from database.namespace)")
 
 	// Bind flags to viper
 	_ = viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
+	_ = viper.BindPFlag("database.namespace", rootCmd.PersistentFlags().Lookup("namespace"))
 
 	// Add subcommands
 	rootCmd.AddCommand(indexCmd)
@@ -103,8 +132,20 @@ func init() {
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(watchCmd)
 	rootCmd.AddCommand(mcpCmd)
+	rootCmd.AddCommand(modelsCmd)
+	rootCmd.AddCommand(metricsCmd)
 	rootCmd.AddCommand(installCmd)
 	rootCmd.AddCommand(uninstallCmd)
+	rootCmd.AddCommand(completionCmd)
+}
+
+// withTimeout returns a context derived from ctx that is canceled once
+// --timeout has passed, if it is set.
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 // versionCmd shows version information
@@ -118,26 +159,8 @@ var versionCmd = &cobra.Command{
 	},
 }
 
-// runSearch is a convenience wrapper that delegates to the search command
-func runSearch(cmd *cobra.Command, args []string) error {
-	// Copy flags to module-level variables used by searchCmd
-	if answer, _ := cmd.Flags().GetBool("answer"); answer {
-		searchAnswer = true
-	}
-	if content, _ := cmd.Flags().GetBool("content"); content {
-		searchContent = true
-	}
-	if limit, _ := cmd.Flags().GetString("limit"); limit != "" {
-		searchLimit = limit
-	}
-
-	// Call the search handler directly instead of executing the command
-	return runSearchCmd(cmd, args)
-}
-
 func init() {
-	// Add search flags to root command for convenience
-	rootCmd.Flags().BoolP("answer", "a", false, "generate an answer using LLM")
-	rootCmd.Flags().BoolP("content", "c", false, "show content snippets in results")
-	rootCmd.Flags().StringP("limit", "m", "10", "maximum number of results")
+	// Accept every search flag on the root command, so 'lgrep "query"' is
+	// the same as 'lgrep search "query"'
+	addSearchFlags(rootCmd)
 }
@@ -3,11 +3,13 @@ package cli
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"strconv"
+	"regexp"
 	"strings"
 	"syscall"
 	"time"
@@ -18,10 +20,13 @@ import (
 	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/log"
+	"github.com/muesli/termenv"
 	"github.com/spf13/cobra"
 
 	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/cost"
 	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/fs"
 	"github.com/nickcecere/lgrep/internal/indexer"
 	"github.com/nickcecere/lgrep/internal/llm"
 	"github.com/nickcecere/lgrep/internal/search"
@@ -30,14 +35,30 @@ import (
 )
 
 var (
-	searchAnswer   bool
-	searchContent  bool
-	searchLimit    string
-	searchStore    string
-	searchMinScore float64
-	searchContext  int
-	searchJSON     bool
-	searchNoSync   bool
+	searchAnswer    bool
+	searchContent   bool
+	searchLimit     int
+	searchPerFile   int
+	searchStore     string
+	searchMinScore  float64
+	searchMinRel    float64
+	searchContext   contextFlags
+	searchJSON      bool
+	searchNoSync    bool
+	searchAutoIndex string
+	searchExpand    bool
+	searchExclude   []string
+	searchGrep      string
+	searchPrefilter string
+	searchNoLog     bool
+	searchNoCache   bool
+	searchImports   bool
+	searchYes       bool
+	searchRefresh   bool
+	searchOutput    string
+	searchGenerated bool
+	searchRecency   time.Duration
+	searchPage      int
 )
 
 // searchCmd represents the search command
@@ -49,6 +70,9 @@ var searchCmd = &cobra.Command{
 The search uses vector similarity to find relevant code snippets
 that match your query semantically, not just by keywords.
 
+A path inside an indexed directory searches that directory's store and
+only returns results from under the path.
+
 Examples:
   # Basic search
   lgrep search "how does authentication work"
@@ -56,40 +80,118 @@ Examples:
   # Search with content preview
   lgrep search "database connection" -c
 
+  # Only return results from under ./src
+  lgrep search "request validation" ./src
+
   # Search with LLM-generated answer (Q&A mode)
   lgrep search "how are errors handled" -a
 
-  # Limit results
-  lgrep search "api endpoints" -m 5
+  # Save the answer and its sources as markdown, or print them as JSON
+  lgrep search "how are errors handled" -a -o answer.md
+  lgrep search "how are errors handled" -a --json
+
+  # Limit results, with at most 2 from any one file
+  lgrep search "api endpoints" -m 5 --per-file 2
   
-  # Filter by minimum similarity score
-  lgrep search "error handling" --min-score 0.5`,
+  # Filter by relevance, calibrated per store so it works for any model
+  lgrep search "error handling" --min-relevance 40
+
+  # Expand the query with LLM-generated paraphrases for better recall
+  lgrep search "jwt refresh" --expand
+
+  # Exclude results mentioning a term (inline or with a flag)
+  lgrep search "token validation -test"
+  lgrep search "token validation" --exclude-term test
+
+  # Keep only results that literally match a pattern
+  lgrep search "database retries" --grep 'ctx\.Done\(\)'
+  lgrep search "feature flags" --grep '(?i)launchdarkly'
+
+  # Re-index results from files edited since indexing, then search again
+  lgrep search "rate limiter" --refresh-hits
+
+  # Results 11-20, then 21-30 from the log without searching again
+  lgrep search "rate limiter" --page 2
+  lgrep more`,
 	Args: cobra.RangeArgs(1, 2),
 	RunE: runSearchCmd,
 }
 
 func init() {
-	searchCmd.Flags().BoolVarP(&searchAnswer, "answer", "a", false, "generate an answer using LLM")
-	searchCmd.Flags().BoolVarP(&searchContent, "content", "c", false, "show content snippets in results")
-	searchCmd.Flags().StringVarP(&searchLimit, "limit", "m", "10", "maximum number of results")
-	searchCmd.Flags().StringVar(&searchStore, "store", "", "store name (auto-detected if not specified)")
-	searchCmd.Flags().Float64Var(&searchMinScore, "min-score", 0.0, "minimum similarity score (0-1)")
-	searchCmd.Flags().IntVar(&searchContext, "context", 0, "lines of context to show")
-	searchCmd.Flags().BoolVar(&searchJSON, "json", false, "output results as JSON")
-	searchCmd.Flags().BoolVar(&searchNoSync, "no-sync", false, "skip auto-indexing if store not found")
+	addSearchFlags(searchCmd)
+}
+
+// addSearchFlags defines the search flags on cmd. The search command and the
+// root command's search shorthand share them, so both behave identically.
+func addSearchFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&searchAnswer, "answer", "a", false, "generate an answer using LLM")
+	cmd.Flags().BoolVarP(&searchContent, "content", "c", false, "show content snippets in results")
+	cmd.Flags().IntVarP(&searchLimit, "limit", "m", 10, "maximum number of results (0 for up to 1000)")
+	cmd.Flags().IntVar(&searchPage, "page", 1, "show this page of --limit results, reusing the previous run of the same search")
+	cmd.Flags().IntVar(&searchPerFile, "per-file", 0, "maximum results from any one file (0 for no limit)")
+	cmd.Flags().StringVar(&searchStore, "store", "", "store name (auto-detected if not specified)")
+	_ = cmd.RegisterFlagCompletionFunc("store", completeStoreNames)
+	cmd.Flags().Float64Var(&searchMinScore, "min-score", 0.0, "minimum raw similarity score (0-1); its meaning depends on the model")
+	cmd.Flags().Float64Var(&searchMinRel, "min-relevance", 0, "minimum calibrated relevance (0-100)")
+	searchContext.register(cmd)
+	cmd.Flags().BoolVar(&searchJSON, "json", false, "output results, or with --answer the answer and its sources, as JSON")
+	cmd.Flags().StringVarP(&searchOutput, "output", "o", "", "with --answer, write the answer to this file as markdown (JSON with --json or a .json name)")
+	cmd.Flags().StringVar(&searchAutoIndex, "auto-index", "", "index a missing store: always, prompt or never (default from search.auto_index)")
+	cmd.Flags().BoolVar(&searchNoSync, "no-sync", false, "skip auto-indexing if store not found")
+	_ = cmd.Flags().MarkDeprecated("no-sync", "use --auto-index=never instead")
+	cmd.Flags().BoolVar(&searchExpand, "expand", false, "expand the query with LLM-generated alternatives")
+	cmd.Flags().StringSliceVar(&searchExclude, "exclude-term", nil, "exclude results containing this term (can be repeated)")
+	cmd.Flags().StringVar(&searchGrep, "grep", "", "only keep results whose content matches this regular expression")
+	cmd.Flags().StringVar(&searchPrefilter, "prefilter", "", "only search files with a line matching this regular expression (RE2 syntax), found with ripgrep if installed")
+	cmd.Flags().BoolVar(&searchGenerated, "include-generated", false, "include results from vendored and generated files")
+	cmd.Flags().DurationVar(&searchRecency, "recency-half-life", 0, "rank recently modified files higher, halving the boost each half-life (e.g. 720h)")
+	cmd.Flags().BoolVar(&searchNoLog, "no-log", false, "do not record Q&A transcripts in the history log")
+	cmd.Flags().BoolVar(&searchNoCache, "no-cache", false, "always generate a fresh answer instead of reusing a cached one")
+	cmd.Flags().BoolVar(&searchImports, "follow-imports", false, "add definitions the top results use from imported files to the answer context")
+	cmd.Flags().BoolVarP(&searchYes, "yes", "y", false, "auto-index without confirmation or size limits")
+	cmd.Flags().BoolVar(&searchRefresh, "refresh-hits", false, "re-index result files changed since indexing and search again")
+	cmd.Flags().BoolVar(&searchRefresh, "fresh", false, "re-index result files changed since indexing and search again")
+	_ = cmd.Flags().MarkDeprecated("fresh", "use --refresh-hits instead")
 }
 
 func runSearchCmd(cmd *cobra.Command, args []string) error {
-	query := args[0]
+	query, excludeTerms := search.ParseQuery(args[0])
+	excludeTerms = append(excludeTerms, searchExclude...)
+	if query == "" {
+		return fmt.Errorf("query cannot be empty")
+	}
 	path := "."
 	if len(args) > 1 {
 		path = args[1]
 	}
 
-	// Parse limit
-	limit, err := strconv.Atoi(searchLimit)
-	if err != nil || limit <= 0 {
-		limit = 10
+	limit, err := resultLimit(searchLimit)
+	if err != nil {
+		return err
+	}
+	if err := checkPerFile(searchPerFile); err != nil {
+		return err
+	}
+	if searchOutput != "" && !searchAnswer {
+		return withExitCode(ExitUsage, fmt.Errorf("--output requires --answer"))
+	}
+	if searchPage < 1 {
+		return withExitCode(ExitUsage, fmt.Errorf("invalid --page %d: must be 1 or more", searchPage))
+	}
+	if searchPage > 1 && searchAnswer {
+		return withExitCode(ExitUsage, fmt.Errorf("--page cannot be combined with --answer"))
+	}
+	var grep *regexp.Regexp
+	if searchGrep != "" {
+		if grep, err = regexp.Compile(searchGrep); err != nil {
+			return withExitCode(ExitUsage, fmt.Errorf("invalid --grep pattern: %w", err))
+		}
+	}
+	var prefilter *regexp.Regexp
+	if searchPrefilter != "" {
+		if prefilter, err = search.CompilePrefilter(searchPrefilter); err != nil {
+			return withExitCode(ExitUsage, fmt.Errorf("invalid --prefilter pattern: %w", err))
+		}
 	}
 
 	log.Debug("Starting search",
@@ -97,11 +199,33 @@ func runSearchCmd(cmd *cobra.Command, args []string) error {
 		"path", path,
 		"limit", limit,
 		"store", searchStore,
+		"exclude", excludeTerms,
 	)
 
 	// Get configuration
 	cfg := config.Get()
 
+	autoIndexMode, err := resolveAutoIndex(cmd, cfg)
+	if err != nil {
+		return withExitCode(ExitUsage, err)
+	}
+
+	minRelevance := cfg.Search.MinRelevance
+	if cmd.Flags().Changed("min-relevance") {
+		minRelevance = searchMinRel
+	}
+	if minRelevance < 0 || minRelevance > 100 {
+		return withExitCode(ExitUsage, fmt.Errorf("invalid minimum relevance %g: must be between 0 and 100", minRelevance))
+	}
+
+	recencyHalfLife := cfg.Search.RecencyHalfLife
+	if cmd.Flags().Changed("recency-half-life") {
+		recencyHalfLife = searchRecency
+	}
+	if recencyHalfLife < 0 {
+		return withExitCode(ExitUsage, fmt.Errorf("invalid recency half-life %s: must not be negative", recencyHalfLife))
+	}
+
 	// Setup context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -115,17 +239,46 @@ func runSearchCmd(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
-	// Open store
-	st, err := store.NewSQLiteStore(cfg.Database.Path)
+	// Open store. Searches that cannot write (no auto-index, no answer to
+	// record, no cloud usage to record) open read-only so they never wait on
+	// a concurrent indexer.
+	usesCloudLLM := (searchAnswer || searchExpand || cfg.Search.Expand) && !cost.IsLocal(cfg.LLM.Provider)
+	recordsUsage := !cost.IsLocal(cfg.Embeddings.Provider) || usesCloudLLM
+	refreshHits := searchRefresh || cfg.Search.RefreshHits
+	openStore := store.NewSQLiteStore
+	if autoIndexMode == config.AutoIndexNever && !refreshHits && !searchAnswer && !recordsUsage {
+		openStore = store.NewSQLiteStoreReadOnly
+	}
+	st, err := openStore(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
 	}
 	defer st.Close()
 
+	// Without a usable embedding provider, a search scans the files for the
+	// words of the query instead
+	canFallback := !searchAnswer && searchPage == 1
+	searchKeywords := func(cause error) error {
+		searchKeywordsInstead(cause)
+		return keywordSearch(ctx, cmd, cfg, query, path, search.SearchOptions{
+			TopK:             limit,
+			Grep:             grep,
+			PerFile:          searchPerFile,
+			IncludeContent:   searchContent || searchContext.requested(cmd),
+			LinesBefore:      searchContext.linesBefore(cmd),
+			LinesAfter:       searchContext.linesAfter(cmd),
+			ExcludeTerms:     excludeTerms,
+			ExcludeGenerated: !(searchGenerated || cfg.Search.IncludeGenerated),
+		}, searchJSON)
+	}
+
 	// Create embedding service
-	emb, err := embeddings.NewService(cfg)
+	emb, err := newMeteredEmbedder(st, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create embedding service: %w", err)
+		if canFallback && keywordFallback(cfg, err) {
+			return searchKeywords(err)
+		}
+		return err
 	}
 
 	// Create searcher
@@ -140,8 +293,8 @@ func runSearchCmd(cmd *cobra.Command, args []string) error {
 		if storeRecord != nil {
 			storeName = storeRecord.Name
 		} else {
-			// Use directory name
-			storeName = filepath.Base(absPath)
+			// Use directory name, or the partition's store name
+			storeName = indexer.StoreName(cfg, absPath)
 		}
 	}
 
@@ -151,15 +304,29 @@ func runSearchCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to check store: %w", err)
 	}
 	if storeRecord == nil {
-		// Store doesn't exist - auto-index if --no-sync is not set
-		if searchNoSync {
-			return fmt.Errorf("store '%s' not found. Run 'lgrep index' first or remove --no-sync", storeName)
+		// Store doesn't exist - auto-index unless disabled
+		absPath, _ := filepath.Abs(path)
+		if searchStore == "" {
+			if err := partitionedRoot(cfg, absPath); err != nil {
+				return err
+			}
+		}
+		if autoIndexMode == config.AutoIndexNever {
+			return withExitCode(ExitStoreMissing, fmt.Errorf("store '%s' not found. Run 'lgrep index %s' first, or search with --auto-index=prompt", storeName, absPath))
 		}
 
-		// Auto-index the directory
-		absPath, _ := filepath.Abs(path)
-		if err := autoIndex(ctx, st, emb, cfg, storeName, absPath); err != nil {
-			return fmt.Errorf("auto-index failed: %w", err)
+		err := autoIndex(ctx, st, emb, cfg, storeName, absPath, autoIndexMode)
+		emb.Flush(st, storeName, cost.OpIndex)
+		if err != nil {
+			if errors.Is(err, errAutoIndexDeclined) {
+				fmt.Printf("Cancelled. Run 'lgrep index %s' to index it.\n", absPath)
+				cmd.SilenceErrors = true
+				return &exitError{code: ExitStoreMissing}
+			}
+			if canFallback && ctx.Err() == nil && keywordFallback(cfg, err) {
+				return searchKeywords(err)
+			}
+			return providerUnavailable(fmt.Errorf("auto-index failed: %w", err))
 		}
 
 		// Re-fetch the store record
@@ -169,47 +336,291 @@ func runSearchCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Perform search
+	// The store may have been found through an alias
+	storeName = storeRecord.Name
+
+	// --timeout bounds the search and answer, but not indexing a new store
+	ctx, cancelTimeout := withTimeout(ctx)
+	defer cancelTimeout()
+
+	// A path inside the store, such as ./src, also restricts the results
+	pathPrefix := ""
+	if len(args) > 1 {
+		absPath, _ := filepath.Abs(path)
+		pathPrefix = search.PathPrefixFor(storeRecord.RootPath, absPath)
+		if pathPrefix != "" {
+			log.Debug("Restricting results to path", "prefix", pathPrefix)
+		}
+	}
+
+	// Perform search, collecting a timing breakdown for --debug
+	timings := search.NewTimings()
 	opts := search.SearchOptions{
 		StoreName:      storeName,
 		TopK:           limit,
 		MinScore:       searchMinScore,
-		IncludeContent: searchContent || searchAnswer,
-		ContextLines:   searchContext,
+		MinRelevance:   minRelevance,
+		Grep:           grep,
+		Prefilter:      prefilter,
+		PathPrefix:     pathPrefix,
+		PerFile:        searchPerFile,
+		IncludeContent: searchContent || searchAnswer || searchContext.requested(cmd),
+		LinesBefore:    searchContext.linesBefore(cmd),
+		LinesAfter:     searchContext.linesAfter(cmd),
+		ExcludeTerms:   excludeTerms,
+		Oversample:     cfg.Search.Oversample,
+		RelatedBoost:   cfg.Search.RelatedBoost,
+		LanguageBoost:  cfg.Search.LanguageBoost,
+		Timings:        timings,
+
+		RecencyHalfLife: recencyHalfLife,
+		RecencyWeight:   cfg.Search.RecencyWeight,
+		PathBoost:       cfg.Search.Boost,
+		FeedbackWeight:  cfg.Search.FeedbackWeight,
+
+		ExcludeGenerated: !(searchGenerated || cfg.Search.IncludeGenerated),
+	}
+
+	// A paged search runs once for all its pages: it returns up to
+	// sessionResults results, which are logged, and later pages of the same
+	// search come from the log
+	expand := searchExpand || cfg.Search.Expand
+	if !searchAnswer {
+		opts.TopK = sessionTopK(limit, searchPage)
 	}
+	key := sessionKey(query, opts, expand)
+
+	// Only the page shown is read with its content and context and checked
+	// for changed files; the rest of the results are only logged
+	var results []search.Result
+	var total, offset, shown int
+	var session *store.SearchLogEntry
+	if cfg.Search.Log {
+		session = findSession(st, storeRecord, key, searchPage)
+	}
+	if session != nil {
+		log.Debug("Showing page from the search log", "page", searchPage, "search", session.ID)
+		total = len(session.Results)
+		offset, shown = pageBounds(total, searchPage, limit)
+		results, err = searcher.ResultsFor(session.Results[offset:shown], opts)
+		if err == nil {
+			searcher.MarkStale(storeRecord, results)
+			showSession(st, session, shown)
+		}
+	} else {
+		// Query expansion with LLM
+		if expand {
+			expandStart := time.Now()
+			opts.Expansions = expandQuery(ctx, st, storeName, query, cfg)
+			timings.Since(search.PhaseLLM, expandStart)
+		}
 
-	results, err := searcher.Search(ctx, query, opts)
+		rankOpts := opts
+		rankOpts.IncludeContent = false
+		rankOpts.ContextLines, rankOpts.LinesBefore, rankOpts.LinesAfter = 0, 0, 0
+		var ranked []search.Result
+		ranked, err = searcher.Search(ctx, query, rankOpts)
+		emb.Flush(st, storeName, cost.OpSearch)
+		if err == nil {
+			offset, shown = pageBounds(len(ranked), searchPage, limit)
+			if searcher.MarkStale(storeRecord, ranked[offset:shown]) > 0 && refreshHits {
+				ranked, err = refreshAndSearch(ctx, st, emb, searcher, cfg, storeRecord, query, rankOpts, ranked[offset:shown])
+				if err == nil {
+					offset, shown = pageBounds(len(ranked), searchPage, limit)
+					searcher.MarkStale(storeRecord, ranked[offset:shown])
+				}
+			}
+		}
+		if err == nil {
+			total = len(ranked)
+			results = ranked[offset:shown]
+			err = searcher.LoadContent(results, opts)
+		}
+		if err == nil {
+			session = logSearch(st, storeRecord, query, key, ranked, shown, cfg.Search.Log)
+		}
+		log.Debug("Search timings", timings.LogValues()...)
+	}
 	if err != nil {
 		if ctx.Err() != nil {
-			return nil
+			return stopped(ctx)
+		}
+		if canFallback && keywordFallback(cfg, err) {
+			return searchKeywords(err)
 		}
-		return fmt.Errorf("search failed: %w", err)
+		return providerUnavailable(fmt.Errorf("search failed: %w", err))
 	}
-
 	if len(results) == 0 {
-		fmt.Println("No results found.")
-		return nil
+		if !quiet {
+			if total > 0 {
+				fmt.Printf("No results on page %d; the search found %d.\n", searchPage, total)
+			} else {
+				fmt.Println("No results found.")
+			}
+		}
+		cmd.SilenceErrors = true
+		return errNoResults
 	}
 
 	// Output results
-	if searchJSON {
-		return outputJSON(results)
+	if searchJSON && !searchAnswer {
+		if debug {
+			return outputJSON(results, offset, timings)
+		}
+		return outputJSON(results, offset, nil)
 	}
 
 	// Q&A mode with LLM
 	if searchAnswer {
-		return runQA(ctx, query, results, cfg)
+		if !quiet && !searchJSON {
+			printStaleHint(results)
+		}
+		return runQA(ctx, st, searcher, storeRecord, query, results, cfg, timings)
 	}
 
 	// Display results
-	displayResults(results, storeRecord.RootPath, searchContent)
+	if quiet {
+		displayQuiet(results)
+		return nil
+	}
+	displayResults(results, offset, total, searchContent || searchContext.requested(cmd), newSnippetRenderer(cfg, query))
+	printStaleHint(results)
+	if shown < total && session.ID != 0 {
+		fmt.Println(ui.Dim.Render("Run 'lgrep more' for the next results."))
+	}
+
+	return nil
+}
+
+// refreshAndSearch re-indexes the files of stale results, removing those
+// that were deleted, and runs the search again once so the results reflect
+// the files as they are now. The results are not checked for changes again.
+func refreshAndSearch(ctx context.Context, st store.Store, emb *cost.Embedder, searcher *search.Searcher, cfg *config.Config,
+	storeRecord *store.StoreRecord, query string, opts search.SearchOptions, results []search.Result) ([]search.Result, error) {
+	idx := indexer.New(st, emb, cfg)
+	updated, err := idx.RefreshFiles(ctx, storeRecord, search.StalePaths(results))
+	emb.Flush(st, storeRecord.Name, cost.OpIndex)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("Refreshed changed result files", "files", updated)
+
+	results, err = searcher.Search(ctx, query, opts)
+	emb.Flush(st, storeRecord.Name, cost.OpSearch)
+	return results, err
+}
+
+// pageBounds returns the range of the results of a search shown on page,
+// for total results and limit results per page.
+func pageBounds(total, page, limit int) (offset, end int) {
+	offset = min((page-1)*limit, total)
+	return offset, min(offset+limit, total)
+}
+
+// logSearch records the search in the search log, so feedback on its results
+// can be tied to the query and later pages shown without searching again.
+// shown is how many of the results are shown so far. Searches are not
+// logged unless enabled, nor on a read-only store, and then get an entry
+// with no ID.
+func logSearch(st store.Store, storeRecord *store.StoreRecord, query, key string, results []search.Result, shown int, enabled bool) *store.SearchLogEntry {
+	entry := &store.SearchLogEntry{StoreID: storeRecord.ID, Query: query, Key: key, Shown: shown}
+	if !enabled {
+		return entry
+	}
+	for _, r := range results {
+		entry.Results = append(entry.Results, store.LoggedResult{ChunkID: r.ChunkID, Score: r.Score, Relevance: r.Relevance})
+	}
+	if err := st.AddSearchLog(entry); err != nil {
+		log.Debug("Failed to log search", "error", err)
+	}
+	return entry
+}
+
+// printStaleHint notes how many results come from files changed since they
+// were indexed.
+func printStaleHint(results []search.Result) {
+	stale := 0
+	for _, r := range results {
+		if r.Stale {
+			stale++
+		}
+	}
+	if stale == 0 {
+		return
+	}
+	fmt.Println(ui.Warning.Render(fmt.Sprintf("%d of %d results are from files changed since indexing and may be out of date. Search with --refresh-hits or run 'lgrep index' to update them.",
+		stale, len(results))))
+}
+
+// resultLimit validates --limit, where 0 asks for as many results as a
+// search returns.
+func resultLimit(limit int) (int, error) {
+	switch {
+	case limit < 0 || limit > search.MaxTopK:
+		return 0, fmt.Errorf("invalid --limit %d: must be between 1 and %d, or 0 for no limit", limit, search.MaxTopK)
+	case limit == 0:
+		return search.MaxTopK, nil
+	}
+	return limit, nil
+}
 
+// checkPerFile returns a usage error if the --per-file value is negative.
+func checkPerFile(perFile int) error {
+	if perFile < 0 {
+		return withExitCode(ExitUsage, fmt.Errorf("invalid --per-file %d: must be 0 or more", perFile))
+	}
 	return nil
 }
 
-// displayResults formats and displays search results.
-func displayResults(results []search.Result, rootPath string, showContent bool) {
-	fmt.Printf("Found %d results:\n\n", len(results))
+// contextFlags are the grep-style flags for the lines of context shown
+// around each result.
+type contextFlags struct {
+	around int // -C, --context
+	before int // -B, --before-context
+	after  int // -A, --after-context
+}
+
+// register defines the context flags on cmd.
+func (f *contextFlags) register(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&f.around, "context", "C", 0, "lines of context to show before and after each result")
+	cmd.Flags().IntVarP(&f.before, "before-context", "B", 0, "lines of context to show before each result (overrides --context)")
+	cmd.Flags().IntVarP(&f.after, "after-context", "A", 0, "lines of context to show after each result (overrides --context)")
+}
+
+// linesBefore returns the lines of context to show before each result of
+// cmd: -B if it was given, even as 0, and otherwise --context.
+func (f contextFlags) linesBefore(cmd *cobra.Command) int {
+	if cmd.Flags().Changed("before-context") {
+		return f.before
+	}
+	return f.around
+}
+
+// linesAfter returns the lines of context to show after each result of
+// cmd: -A if it was given, even as 0, and otherwise --context.
+func (f contextFlags) linesAfter(cmd *cobra.Command) int {
+	if cmd.Flags().Changed("after-context") {
+		return f.after
+	}
+	return f.around
+}
+
+// requested reports whether any lines of context were asked for on cmd.
+// Context is shown with the result's content, so asking for it implies
+// --content.
+func (f contextFlags) requested(cmd *cobra.Command) bool {
+	return f.linesBefore(cmd) > 0 || f.linesAfter(cmd) > 0
+}
+
+// displayResults formats and displays search results. offset is the number
+// of results before them, on earlier pages, and total the number of results
+// of the search.
+func displayResults(results []search.Result, offset, total int, showContent bool, snippets *snippetRenderer) {
+	if offset == 0 && total == len(results) {
+		fmt.Printf("Found %d results:\n\n", total)
+	} else {
+		fmt.Printf("Results %d-%d of %d:\n\n", offset+1, offset+len(results), total)
+	}
 
 	for i, r := range results {
 		// Format file path (show relative path if possible)
@@ -218,14 +629,25 @@ func displayResults(results []search.Result, rootPath string, showContent bool)
 			displayPath = r.FilePath
 		}
 
-		// Score as percentage
-		scoreStr := fmt.Sprintf("%.1f%%", r.Score*100)
+		// Calibrated relevance, comparable across models
+		scoreStr := fmt.Sprintf("relevance %.0f", r.Relevance)
 
 		// Header line
-		fmt.Printf("%s %s %s\n",
-			ui.Highlight.Render(fmt.Sprintf("[%d]", i+1)),
+		staleStr := ""
+		if r.Stale {
+			staleStr = " " + ui.Warning.Render("(stale)")
+		}
+		// Keyword matches have no chunk to give feedback on
+		idStr := ""
+		if r.ChunkID != 0 {
+			idStr = " " + ui.Dim.Render(fmt.Sprintf("#%d", r.ChunkID))
+		}
+		fmt.Printf("%s %s %s%s%s\n",
+			ui.Highlight.Render(fmt.Sprintf("[%d]", offset+i+1)),
 			ui.FilePath.Render(displayPath),
 			ui.ResultScore.Render(scoreStr),
+			staleStr,
+			idStr,
 		)
 
 		// Line numbers
@@ -234,18 +656,85 @@ func displayResults(results []search.Result, rootPath string, showContent bool)
 			fmt.Printf("    %s\n", ui.LineNum.Render(lineInfo))
 		}
 
-		// Content preview
+		// Content preview, between its dimmed context lines
 		if showContent && r.Content != "" {
 			fmt.Println()
-			displayContentHighlighted(r.Content, r.StartLine, displayPath)
+			if r.ContextBefore != "" {
+				snippets.displayContext(r.ContextBefore, r.StartLine-strings.Count(r.ContextBefore, "\n")-1)
+			}
+			snippets.display(r.Content, r.StartLine, displayPath)
+			if r.ContextAfter != "" {
+				snippets.displayContext(r.ContextAfter, r.EndLine+1)
+			}
 		}
 
 		fmt.Println()
 	}
 }
 
-// displayContentHighlighted formats and displays code content with syntax highlighting.
-func displayContentHighlighted(content string, startLine int, filename string) {
+// displayQuiet prints one result per line as path:start-end, for scripts.
+func displayQuiet(results []search.Result) {
+	for _, r := range results {
+		displayPath := r.RelativePath
+		if displayPath == "" {
+			displayPath = r.FilePath
+		}
+		fmt.Printf("%s:%d-%d\n", displayPath, r.StartLine, r.EndLine)
+	}
+}
+
+// snippetWidth is the most terminal cells a line of a snippet takes up.
+const snippetWidth = 80
+
+// snippetRenderer displays result snippets with syntax highlighting
+// according to the ui configuration.
+type snippetRenderer struct {
+	style      *chroma.Style
+	formatter  chroma.Formatter // nil when color is off
+	maxLines   int
+	terms      *regexp.Regexp // Query terms to highlight
+	queryTerms []string       // Query terms that pick the lines of long snippets
+}
+
+// newSnippetRenderer returns a snippetRenderer for cfg that highlights the
+// terms of query.
+func newSnippetRenderer(cfg *config.Config, query string) *snippetRenderer {
+	r := &snippetRenderer{
+		maxLines:   cfg.UI.MaxSnippetLines,
+		terms:      termMatcher(query),
+		queryTerms: search.QueryTerms(query),
+	}
+	if !ui.ColorEnabled() {
+		return r
+	}
+
+	theme := cfg.UI.Theme
+	if theme == "" || theme == "auto" {
+		theme = "github"
+		if ui.DarkBackground() {
+			theme = "dracula"
+		}
+	}
+	r.style = styles.Get(theme)
+	if r.style == styles.Fallback && theme != styles.Fallback.Name {
+		log.Warn("Unknown ui.theme, using the default", "theme", theme)
+	}
+
+	// Use the richest formatter the terminal supports
+	switch ui.ColorProfile() {
+	case termenv.TrueColor:
+		r.formatter = formatters.Get("terminal16m")
+	case termenv.ANSI256:
+		r.formatter = formatters.Get("terminal256")
+	default:
+		r.formatter = formatters.Get("terminal16")
+	}
+	return r
+}
+
+// display formats and displays code content. Of long snippets, only the
+// lines most relevant to the query are shown.
+func (r *snippetRenderer) display(content string, startLine int, filename string) {
 	// Get lexer based on filename
 	lexer := lexers.Match(filename)
 	if lexer == nil {
@@ -253,129 +742,269 @@ func displayContentHighlighted(content string, startLine int, filename string) {
 	}
 	lexer = chroma.Coalesce(lexer)
 
-	// Use a terminal-friendly style
-	style := styles.Get("dracula")
-	if style == nil {
-		style = styles.Fallback
+	lines := strings.Split(content, "\n")
+	start, end := 0, len(lines)
+	if r.maxLines > 0 && len(lines) > r.maxLines {
+		start, end = search.SnippetWindow(content, r.queryTerms, r.maxLines)
 	}
 
-	// Use terminal16m (true color) formatter for best color support
-	formatter := formatters.Get("terminal16m")
-	if formatter == nil {
-		formatter = formatters.Get("terminal256")
+	if start > 0 {
+		fmt.Printf("    %s\n", ui.Dim.Render(fmt.Sprintf("    ... (%d lines above)", start)))
 	}
-	if formatter == nil {
-		formatter = formatters.Fallback
+	r.displayLines(content, startLine, start, end, lexer)
+	if end < len(lines) {
+		fmt.Printf("    %s\n", ui.Dim.Render(fmt.Sprintf("    ... (%d lines below)", len(lines)-end)))
 	}
+}
 
-	lines := strings.Split(content, "\n")
-	maxLines := 15 // Maximum lines to show
-
-	if len(lines) > maxLines {
-		// Show first and last few lines with highlighting
-		showLines := maxLines / 2
-
-		// Highlight first section
-		firstContent := strings.Join(lines[:showLines], "\n")
-		displayHighlightedLines(firstContent, startLine, lexer, style, formatter)
-
-		fmt.Printf("    %s\n", ui.Dim.Render(fmt.Sprintf("    ... (%d lines omitted)", len(lines)-maxLines)))
-
-		// Highlight last section
-		lastContent := strings.Join(lines[len(lines)-showLines:], "\n")
-		displayHighlightedLines(lastContent, startLine+len(lines)-showLines, lexer, style, formatter)
-	} else {
-		displayHighlightedLines(content, startLine, lexer, style, formatter)
+// displayLines highlights code and displays its lines [start, end) with
+// line numbers, the first line of content being startLine. The whole of
+// content is tokenized, so strings and comments that begin above the shown
+// lines are still highlighted as such.
+func (r *snippetRenderer) displayLines(content string, startLine, start, end int, lexer chroma.Lexer) {
+	if r.formatter == nil {
+		r.displayPlainLines(content, startLine, start, end)
+		return
 	}
-}
 
-// displayHighlightedLines highlights and displays code with line numbers.
-func displayHighlightedLines(content string, startLine int, lexer chroma.Lexer, style *chroma.Style, formatter chroma.Formatter) {
 	// Tokenize the content
 	iterator, err := lexer.Tokenise(nil, content)
 	if err != nil {
 		// Fallback to plain display
-		displayPlainLines(content, startLine)
+		r.displayPlainLines(content, startLine, start, end)
 		return
 	}
 
-	// Render highlighted content to buffer
-	var buf bytes.Buffer
-	if err := formatter.Format(&buf, style, iterator); err != nil {
-		displayPlainLines(content, startLine)
-		return
+	// Overlay query term matches on the syntax highlighting
+	tokens := markTerms(iterator.Tokens(), r.terms)
+	style := matchStyle(r.style, tokens)
+
+	// Render each line on its own, so a token spanning lines is coloured on
+	// every line it covers, even after the line number resets the colour
+	tokenLines := chroma.SplitTokensIntoLines(tokens)
+	highlighted := make([]string, 0, end-start)
+	for _, line := range tokenLines[min(start, len(tokenLines)):min(end, len(tokenLines))] {
+		if n := len(line) - 1; n >= 0 {
+			line[n].Value = strings.TrimSuffix(line[n].Value, "\n")
+		}
+		line = truncateTokens(line, snippetWidth)
+		var buf bytes.Buffer
+		if err := r.formatter.Format(&buf, style, chroma.Literator(line...)); err != nil {
+			r.displayPlainLines(content, startLine, start, end)
+			return
+		}
+		highlighted = append(highlighted, buf.String())
 	}
 
-	// Split highlighted output by lines and add line numbers
-	highlightedLines := strings.Split(buf.String(), "\n")
-	for i, line := range highlightedLines {
-		lineNum := startLine + i
+	for i, line := range highlighted {
 		fmt.Printf("    %s %s\n",
-			ui.LineNum.Render(fmt.Sprintf("%4d│", lineNum)),
+			ui.LineNum.Render(fmt.Sprintf("%4d│", startLine+start+i)),
 			line,
 		)
 	}
 }
 
-// displayPlainLines displays content without highlighting (fallback).
-func displayPlainLines(content string, startLine int) {
+// displayPlainLines displays lines [start, end) of content without syntax
+// highlighting.
+func (r *snippetRenderer) displayPlainLines(content string, startLine, start, end int) {
 	lines := strings.Split(content, "\n")
-	for i, line := range lines {
-		lineNum := startLine + i
+	for i := start; i < end && i < len(lines); i++ {
+		fmt.Printf("    %s %s\n",
+			ui.LineNum.Render(fmt.Sprintf("%4d│", startLine+i)),
+			markTermsPlain(truncateLine(lines[i], snippetWidth), r.terms),
+		)
+	}
+}
+
+// displayContext displays the lines of context around a snippet, dimmed so
+// they stand apart from it.
+func (r *snippetRenderer) displayContext(content string, startLine int) {
+	for i, line := range strings.Split(content, "\n") {
 		fmt.Printf("    %s %s\n",
-			ui.LineNum.Render(fmt.Sprintf("%4d│", lineNum)),
-			truncateLine(line, 80),
+			ui.LineNum.Render(fmt.Sprintf("%4d│", startLine+i)),
+			ui.Dim.Render(truncateLine(line, snippetWidth)),
 		)
 	}
 }
 
-// truncateLine shortens a line for display.
-func truncateLine(line string, maxLen int) string {
-	// Replace tabs with spaces for consistent display
-	line = strings.ReplaceAll(line, "\t", "    ")
-	if len(line) <= maxLen {
+// expandTabs replaces tabs with spaces for consistent display.
+func expandTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", "    ")
+}
+
+// truncateLine shortens a line to maxWidth terminal cells for display,
+// expanding its tabs first.
+func truncateLine(line string, maxWidth int) string {
+	return ui.Truncate(expandTabs(line), maxWidth)
+}
+
+// truncateTokens shortens a highlighted line to maxWidth terminal cells
+// like truncateLine, expanding tabs, cutting the token that crosses the
+// limit and dropping the ones after it.
+func truncateTokens(line []chroma.Token, maxWidth int) []chroma.Token {
+	width := 0
+	for i := range line {
+		line[i].Value = expandTabs(line[i].Value)
+		width += ui.Width(line[i].Value)
+	}
+	if width <= maxWidth {
 		return line
 	}
-	return line[:maxLen-3] + "..."
+
+	room := maxWidth - len("...")
+	for i := range line {
+		w := ui.Width(line[i].Value)
+		if w <= room {
+			room -= w
+			continue
+		}
+		// With "..." appended the token is wider than room plus "...", so
+		// it is always cut and ends with "..."
+		line[i].Value = ui.Truncate(line[i].Value+"...", room+len("..."))
+		return line[:i+1]
+	}
+	return line
 }
 
-// outputJSON outputs results as JSON.
-func outputJSON(results []search.Result) error {
-	// Simple JSON output without importing encoding/json to keep it simple
+// outputJSON outputs results as JSON, with each result's rank counting from
+// offset + 1, and its content and lines of context when they were asked
+// for. When timings is set, the results are wrapped in an object with
+// a "meta" section holding the timing breakdown.
+func outputJSON(results []search.Result, offset int, timings *search.Timings) error {
+	indent := "  "
+	if timings != nil {
+		fmt.Println("{")
+		fmt.Print(`  "results": `)
+		indent = "    "
+	}
+
+	// Hand-formatted so each result stays on one line
 	fmt.Println("[")
 	for i, r := range results {
 		comma := ","
 		if i == len(results)-1 {
 			comma = ""
 		}
-		fmt.Printf(`  {"file": %q, "lines": [%d, %d], "score": %.4f}%s
+		extra := ""
+		if r.Stale {
+			extra += `, "stale": true`
+		}
+		for _, field := range []struct{ name, value string }{
+			{"content", r.Content},
+			{"context_before", r.ContextBefore},
+			{"context_after", r.ContextAfter},
+		} {
+			if field.value != "" {
+				value, _ := json.Marshal(field.value)
+				extra += fmt.Sprintf(", %q: %s", field.name, value)
+			}
+		}
+		fmt.Printf(`%s{"id": %d, "rank": %d, "file": %q, "lines": [%d, %d], "score": %.4f, "relevance": %.1f%s}%s
 `,
-			r.RelativePath, r.StartLine, r.EndLine, r.Score, comma)
+			indent, r.ChunkID, offset+i+1, r.RelativePath, r.StartLine, r.EndLine, r.Score, r.Relevance, extra, comma)
+	}
+
+	if timings == nil {
+		fmt.Println("]")
+		return nil
+	}
+
+	fmt.Println("  ],")
+	fmt.Println(`  "meta": {`)
+	fmt.Println(`    "timings_ms": {`)
+	ms := timings.Milliseconds()
+	for i, p := range search.Phases() {
+		comma := ","
+		if i == len(search.Phases())-1 {
+			comma = ""
+		}
+		fmt.Printf("      %q: %.3f%s\n", string(p), ms[string(p)], comma)
 	}
-	fmt.Println("]")
+	fmt.Println("    }")
+	fmt.Println("  }")
+	fmt.Println("}")
 	return nil
 }
 
 // runQA generates an answer using the LLM with search results as context.
-func runQA(ctx context.Context, query string, results []search.Result, cfg *config.Config) error {
+func runQA(ctx context.Context, st store.Store, searcher *search.Searcher, storeRecord *store.StoreRecord, query string, results []search.Result,
+	cfg *config.Config, timings *search.Timings) error {
+	storeName := storeRecord.Name
+
 	// Create LLM service
-	llmService, err := llm.NewService(cfg)
+	llmService, err := newMeteredLLM(st, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create LLM service: %w", err)
+		return err
 	}
 
 	// Create Q&A service
 	qaService := llm.NewQAService(llmService)
 
-	// Start spinner while generating (no Answer header yet)
-	stopSpinner := make(chan struct{})
-	spinnerDone := make(chan struct{})
-	go showSpinner("Generating answer", stopSpinner, spinnerDone)
-
 	// Use non-streaming mode
 	opts := llm.DefaultQAOptions()
 	opts.Stream = true // Still use stream internally for the channel API
+	opts.MaxContextTokens = cfg.LLM.MaxContextTokens
+	opts.Timings = timings
+
+	// Follow the imports of the results sent as context, adding what they
+	// use after them so it is the first to go when the budget runs out
+	if searchImports || cfg.LLM.FollowImports {
+		n := min(len(results), opts.MaxContextChunks)
+		top := results[:n:n]
+		imported, err := searcher.ImportedChunks(storeRecord, top, opts.MaxContextChunks)
+		if err != nil {
+			log.Debug("Failed to follow imports", "error", err)
+		} else if len(imported) > 0 {
+			log.Debug("Following imports", "chunks", len(imported))
+			results = append(top, imported...)
+			opts.MaxContextChunks += len(imported)
+		}
+	}
+
+	// Pack the context and report anything that did not fit
+	contextSources, report := llm.SelectSources(results, opts)
+	log.Debug("Packed Q&A context", "included", report.Included, "tokens", report.Tokens,
+		"truncated", report.Truncated, "dropped", report.Dropped)
+	if !quiet && !searchJSON && (report.Truncated > 0 || report.Dropped > 0) {
+		fmt.Println(ui.Dim.Render(fmt.Sprintf("Context: %d results (~%d tokens), %d truncated, %d dropped to fit llm.max_context_tokens",
+			report.Included, report.Tokens, report.Truncated, report.Dropped)))
+	}
 
+	// Reuse a previous answer for the same question and context
+	contextHash := qaContextHash(query, contextSources)
+	if !searchNoCache {
+		cached, err := st.FindQATranscript(storeName, llmService.ModelName(), contextHash)
+		if err != nil {
+			log.Debug("Failed to look up cached answer", "error", err)
+		} else if cached != nil {
+			log.Debug("Using cached answer", "id", cached.ID, "created", cached.CreatedAt)
+			return outputAnswer(&llm.QAResult{
+				Question: query,
+				Answer:   cached.Answer,
+				Sources:  contextSources,
+				Provider: cached.Provider,
+				Model:    cached.Model,
+				Cached:   true,
+			})
+		}
+	}
+
+	// Refuse before showing a spinner if the budget is already spent
+	if err := llmService.Check(); err != nil {
+		return providerUnavailable(err)
+	}
+
+	// Start spinner while generating (no Answer header yet), keeping
+	// stdout to the JSON alone with --json
+	stopSpinner := make(chan struct{})
+	spinnerDone := make(chan struct{})
+	if searchJSON {
+		close(spinnerDone)
+	} else {
+		go showSpinner("Generating answer", stopSpinner, spinnerDone)
+	}
+
+	startTime := time.Now()
 	contentCh, errCh, sources := qaService.AnswerStream(ctx, query, results, opts)
 
 	// Collect all content silently
@@ -389,11 +1018,96 @@ func runQA(ctx context.Context, query string, results []search.Result, cfg *conf
 	<-spinnerDone
 
 	// Check for errors
-	if err := <-errCh; err != nil {
+	err = <-errCh
+	usage, answerCost := llmService.Usage(), llmService.Cost()
+	llmService.Flush(st, storeName, cost.OpAnswer)
+	log.Debug("Answer timings", timings.LogValues()...)
+	if err != nil {
 		if ctx.Err() != nil {
+			return stopped(ctx)
+		}
+		return withExitCode(ExitProviderUnavailable, fmt.Errorf("answer generation failed: %w", err))
+	}
+
+	// Record the tokens for 'lgrep metrics', local providers included
+	metric := &store.Metric{
+		Event:        store.MetricAnswer,
+		StoreName:    storeName,
+		Duration:     time.Since(startTime),
+		Count:        len(sources),
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		Cost:         answerCost,
+	}
+	if err := st.AddMetric(metric); err != nil {
+		log.Debug("Failed to record answer metric", "error", err)
+	}
+
+	// Record the transcript
+	if !searchNoLog {
+		transcript := &store.QATranscript{
+			StoreName:   storeName,
+			Question:    query,
+			Answer:      contentBuilder.String(),
+			Provider:    string(llmService.Provider()),
+			Model:       llmService.ModelName(),
+			Sources:     toQASources(sources),
+			ContextHash: contextHash,
+			Latency:     time.Since(startTime),
+		}
+		if err := st.AddQATranscript(transcript); err != nil {
+			log.Warn("Failed to record Q&A transcript", "error", err)
+		}
+	}
+
+	return outputAnswer(&llm.QAResult{
+		Question: query,
+		Answer:   contentBuilder.String(),
+		Sources:  sources,
+		Provider: string(llmService.Provider()),
+		Model:    llmService.ModelName(),
+		Usage:    &usage,
+	})
+}
+
+// outputAnswer displays an answer, prints it as JSON with --json, or writes
+// it to the --output file as markdown, or as JSON with --json or a .json
+// file name.
+func outputAnswer(result *llm.QAResult) error {
+	asJSON := searchJSON || strings.EqualFold(filepath.Ext(searchOutput), ".json")
+	if searchOutput == "" {
+		if !asJSON {
+			displayAnswer(result.Answer, result.Sources)
 			return nil
 		}
-		return fmt.Errorf("answer generation failed: %w", err)
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	data := []byte(result.Markdown())
+	if asJSON {
+		encoded, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(encoded, '\n')
+	}
+	if err := os.WriteFile(searchOutput, data, 0644); err != nil {
+		return fmt.Errorf("failed to write answer: %w", err)
+	}
+	if !quiet {
+		fmt.Printf("Answer written to %s\n", searchOutput)
+	}
+	return nil
+}
+
+// displayAnswer renders an answer and the sources it was generated from.
+func displayAnswer(answer string, sources []search.Result) {
+	// Print the raw answer alone in quiet mode
+	if quiet {
+		fmt.Println(strings.TrimSpace(answer))
+		return
 	}
 
 	// Now show the Answer header
@@ -401,10 +1115,10 @@ func runQA(ctx context.Context, query string, results []search.Result, cfg *conf
 	fmt.Println()
 
 	// Render markdown with glamour
-	rendered, err := renderMarkdown(contentBuilder.String())
+	rendered, err := renderMarkdown(answer)
 	if err != nil {
 		// Fallback to raw output if rendering fails
-		fmt.Println(contentBuilder.String())
+		fmt.Println(answer)
 	} else {
 		fmt.Print(rendered)
 	}
@@ -413,12 +1127,75 @@ func runQA(ctx context.Context, query string, results []search.Result, cfg *conf
 	if len(sources) > 0 {
 		fmt.Println(ui.Dim.Render("Sources:"))
 		for i, s := range sources {
-			fmt.Printf("  [%d] %s (lines %d-%d)\n",
-				i+1, s.RelativePath, s.StartLine, s.EndLine)
+			imported := ""
+			if s.ImportedBy != "" {
+				imported = ui.Dim.Render(", imported by " + s.ImportedBy)
+			}
+			fmt.Printf("  [%d] %s (lines %d-%d%s)\n",
+				i+1, s.RelativePath, s.StartLine, s.EndLine, imported)
 		}
 	}
+}
 
-	return nil
+// qaContextHash fingerprints the question and the context sent to the LLM.
+func qaContextHash(query string, sources []search.Result) string {
+	var sb strings.Builder
+	sb.WriteString(query)
+	for _, s := range sources {
+		fmt.Fprintf(&sb, "\x00%s:%d-%d\x00%s", s.RelativePath, s.StartLine, s.EndLine, s.Content)
+	}
+	return fs.HashContent([]byte(sb.String()))
+}
+
+// toQASources converts search results to history source records.
+func toQASources(results []search.Result) []store.QASource {
+	sources := make([]store.QASource, len(results))
+	for i, r := range results {
+		sources[i] = store.QASource{
+			RelativePath: r.RelativePath,
+			StartLine:    r.StartLine,
+			EndLine:      r.EndLine,
+			Score:        r.Score,
+			Relevance:    r.Relevance,
+		}
+	}
+	return sources
+}
+
+// expandQuery asks the LLM for alternative phrasings of the query.
+// Failures are logged and searching continues with the original query only.
+func expandQuery(ctx context.Context, st store.Store, storeName, query string, cfg *config.Config) []string {
+	llmService, err := newMeteredLLM(st, cfg)
+	if err != nil {
+		log.Warn("Query expansion unavailable", "error", err)
+		return nil
+	}
+
+	expansions, err := llm.NewQueryExpander(llmService).Expand(ctx, query, llm.DefaultExpansions)
+	llmService.Flush(st, storeName, cost.OpSearch)
+	if err != nil {
+		log.Warn("Query expansion failed", "error", err)
+		return nil
+	}
+
+	log.Debug("Expanded query", "expansions", expansions)
+	return expansions
+}
+
+// newMeteredLLM creates the configured LLM service, metered so that cloud
+// usage is recorded and budget.monthly_usd is enforced.
+func newMeteredLLM(st store.Store, cfg *config.Config) (*cost.LLM, error) {
+	svc, err := llm.NewService(cfg)
+	if err != nil {
+		return nil, withExitCode(ExitProviderUnavailable, fmt.Errorf("failed to create LLM service: %w", err))
+	}
+
+	budget, err := cost.LoadBudget(st, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load budget: %w", err)
+	}
+
+	return cost.NewLLM(svc, budget), nil
 }
 
 // showSpinner displays an animated spinner until stopCh is closed.
@@ -428,6 +1205,11 @@ func showSpinner(message string, stopCh <-chan struct{}, doneCh chan<- struct{})
 	defer ticker.Stop()
 	defer close(doneCh)
 
+	if quiet {
+		<-stopCh
+		return
+	}
+
 	i := 0
 	for {
 		select {
@@ -444,8 +1226,16 @@ func showSpinner(message string, stopCh <-chan struct{}, doneCh chan<- struct{})
 
 // renderMarkdown renders markdown content using glamour.
 func renderMarkdown(content string) (string, error) {
+	style := glamour.WithAutoStyle()
+	switch {
+	case !ui.ColorEnabled():
+		style = glamour.WithStandardStyle("notty")
+	case ui.Background() != ui.BackgroundAuto:
+		style = glamour.WithStandardStyle(ui.Background())
+	}
+
 	renderer, err := glamour.NewTermRenderer(
-		glamour.WithAutoStyle(),
+		style,
 		glamour.WithWordWrap(100),
 	)
 	if err != nil {
@@ -454,9 +1244,56 @@ func renderMarkdown(content string) (string, error) {
 	return renderer.Render(content)
 }
 
-// autoIndex automatically indexes a directory before searching.
-func autoIndex(ctx context.Context, st store.Store, emb embeddings.Service, cfg *config.Config, storeName, absPath string) error {
-	fmt.Printf("Store '%s' not found. Auto-indexing...\n\n", storeName)
+// errAutoIndexDeclined is returned when the user answers no to the
+// auto-index prompt.
+var errAutoIndexDeclined = errors.New("auto-index declined")
+
+// resolveAutoIndex returns the auto-index mode of a search. --auto-index
+// overrides search.auto_index, as do the older --no-sync (never) and --yes
+// (always) flags.
+func resolveAutoIndex(cmd *cobra.Command, cfg *config.Config) (string, error) {
+	mode := cfg.Search.AutoIndex
+	switch {
+	case cmd.Flags().Changed("auto-index"):
+		mode = searchAutoIndex
+	case searchNoSync:
+		mode = config.AutoIndexNever
+	case searchYes:
+		mode = config.AutoIndexAlways
+	}
+	if err := config.ValidateAutoIndex(mode); err != nil {
+		return "", err
+	}
+	return mode, nil
+}
+
+// autoIndex automatically indexes a directory before searching. In prompt
+// mode, large directories are refused and interactive users are asked to
+// confirm first.
+func autoIndex(ctx context.Context, st store.Store, emb embeddings.Service, cfg *config.Config, storeName, absPath, mode string) error {
+	if mode == config.AutoIndexPrompt {
+		scan, err := indexer.Scan(cfg, absPath, nil, nil)
+		if err != nil {
+			return err
+		}
+		if err := indexer.CheckAutoIndexLimits(cfg, absPath, scan); err != nil {
+			return withExitCode(ExitStoreMissing, fmt.Errorf("%w. Run 'lgrep index %s' to index it explicitly, or pass --auto-index=always", err, absPath))
+		}
+		// Without a terminal to ask, directories within the limits are
+		// indexed as before
+		ok, err := confirm(fmt.Sprintf("Store '%s' not found. Index %d files (%s) from %s?",
+			storeName, len(scan.Files), formatBytes(scan.TotalSize), absPath))
+		if err == nil && !ok {
+			return errAutoIndexDeclined
+		}
+		if err == nil {
+			fmt.Println()
+		}
+	}
+
+	if !quiet {
+		fmt.Printf("Store '%s' not found. Auto-indexing...\n\n", storeName)
+	}
 
 	// Start spinner
 	stopSpinner := make(chan struct{})
@@ -466,10 +1303,12 @@ func autoIndex(ctx context.Context, st store.Store, emb embeddings.Service, cfg
 	// Create indexer and run
 	idx := indexer.New(st, emb, cfg)
 	opts := indexer.IndexOptions{
-		StoreName: storeName,
-		Path:      absPath,
-		Force:     false,
-		BatchSize: 50,
+		StoreName:  storeName,
+		Path:       absPath,
+		Force:      false,
+		BatchSize:  50,
+		LockPolicy: indexer.LockDelegate,
+		Trigger:    store.TriggerSearch,
 	}
 
 	err := idx.Index(ctx, opts)
@@ -483,6 +1322,9 @@ func autoIndex(ctx context.Context, st store.Store, emb embeddings.Service, cfg
 	}
 
 	// Show stats
+	if quiet {
+		return nil
+	}
 	storeRecord, _ := st.GetStore(storeName)
 	if storeRecord != nil {
 		stats, _ := st.GetStats(storeRecord.ID)
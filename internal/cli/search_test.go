@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/search"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+// TestTruncateLine tests that snippet lines are cut to a width in terminal
+// cells, with tabs expanded, and that highlighted lines are cut the same way
+// wherever their tokens split.
+func TestTruncateLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		width int
+		want  string
+	}{
+		{"fits", "return nil", 10, "return nil"},
+		{"ascii", "return errors.New(msg)", 12, "return er..."},
+		{"tab", "\treturn nil", 14, "    return nil"},
+		{"tab cut", "\t\treturn nil", 10, "       ..."},
+		{"cjk", "// 日本語のコメント", 12, "// 日本語..."},
+		{"cjk straddling", "// 日本語のコメント", 11, "// 日本..."},
+		{"emoji", "log(\"🚀🚀🚀🚀\")", 10, "log(\"🚀..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateLine(tt.line, tt.width)
+			assert.Equal(t, tt.want, got)
+			assert.True(t, utf8.ValidString(got))
+			assert.LessOrEqual(t, ui.Width(got), tt.width)
+
+			for _, tokens := range [][]chroma.Token{
+				{{Type: chroma.Text, Value: tt.line}},
+				splitTokens(tt.line, 1),
+				splitTokens(tt.line, 3),
+			} {
+				var joined strings.Builder
+				for _, token := range truncateTokens(tokens, tt.width) {
+					joined.WriteString(token.Value)
+				}
+				assert.Equal(t, tt.want, joined.String())
+			}
+		})
+	}
+}
+
+// splitTokens splits line into text tokens of n runes.
+func splitTokens(line string, n int) []chroma.Token {
+	var tokens []chroma.Token
+	runes := []rune(line)
+	for i := 0; i < len(runes); i += n {
+		tokens = append(tokens, chroma.Token{Type: chroma.Text, Value: string(runes[i:min(i+n, len(runes))])})
+	}
+	return tokens
+}
+
+// TestContextFlags tests that -B and -A override --context on their side,
+// even with 0.
+func TestContextFlags(t *testing.T) {
+	tests := []struct {
+		args          []string
+		before, after int
+	}{
+		{nil, 0, 0},
+		{[]string{"-C", "3"}, 3, 3},
+		{[]string{"-B", "2"}, 2, 0},
+		{[]string{"-C", "3", "-A", "1"}, 3, 1},
+		{[]string{"-C", "3", "-B", "0"}, 0, 3},
+		{[]string{"-C", "3", "-B", "0", "-A", "0"}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			cmd := &cobra.Command{}
+			var f contextFlags
+			f.register(cmd)
+			require.NoError(t, cmd.ParseFlags(tt.args))
+			assert.Equal(t, tt.before, f.linesBefore(cmd))
+			assert.Equal(t, tt.after, f.linesAfter(cmd))
+			assert.Equal(t, tt.before > 0 || tt.after > 0, f.requested(cmd))
+		})
+	}
+}
+
+// snippet is the content of the results the display tests show.
+const snippet = "func sum() int {\n\tx := 1\n\ty := 2\n\treturn x + y\n}"
+
+// TestDisplayLines tests that only the lines [start, end) of a snippet are
+// shown, numbered from its first line, with and without highlighting.
+func TestDisplayLines(t *testing.T) {
+	lines := strings.Split(snippet, "\n")
+	tests := []struct {
+		name       string
+		start, end int
+		want       []int // Indexes of the lines shown
+	}{
+		{"all", 0, len(lines), []int{0, 1, 2, 3, 4}},
+		{"middle", 1, 3, []int{1, 2}},
+		{"past the end", 3, len(lines) + 2, []int{3, 4}},
+		{"empty", 2, 2, nil},
+	}
+	for name, r := range renderers() {
+		for _, tt := range tests {
+			t.Run(name+" "+tt.name, func(t *testing.T) {
+				var want strings.Builder
+				for _, i := range tt.want {
+					fmt.Fprintf(&want, "    %4d│ %s\n", 10+i, expandTabs(lines[i]))
+				}
+				got := captureStdout(t, func() {
+					r.displayLines(snippet, 10, tt.start, tt.end, chroma.Coalesce(lexers.Get("go")))
+				})
+				assert.Equal(t, want.String(), got)
+			})
+		}
+	}
+}
+
+// TestDisplaySnippetWindow tests that a long snippet is cut to the lines
+// with the query's terms, noting how many lines it leaves out.
+func TestDisplaySnippetWindow(t *testing.T) {
+	for name, r := range renderers() {
+		t.Run(name, func(t *testing.T) {
+			r.maxLines = 3
+			r.queryTerms = []string{"y := 2"}
+			got := captureStdout(t, func() { r.display(snippet, 10, "sum.go") })
+			assert.Equal(t, []int{11, 12, 13}, lineNumbers(got))
+			assert.Contains(t, got, "... (1 lines above)")
+			assert.Contains(t, got, "... (1 lines below)")
+		})
+	}
+}
+
+// TestDisplayContext tests that the lines of context of results at the
+// start and end of a file are numbered around the result's lines.
+func TestDisplayContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		result search.Result
+		want   []int
+	}{
+		{"start of file", search.Result{StartLine: 1, EndLine: 5, ContextAfter: "\n// end"}, []int{1, 2, 3, 4, 5, 6, 7}},
+		{"end of file", search.Result{StartLine: 3, EndLine: 7, ContextBefore: "package sum\n"}, []int{1, 2, 3, 4, 5, 6, 7}},
+		{"middle", search.Result{StartLine: 4, EndLine: 8, ContextBefore: "// a", ContextAfter: "// b"}, []int{3, 4, 5, 6, 7, 8, 9}},
+	}
+	for name, r := range renderers() {
+		for _, tt := range tests {
+			t.Run(name+" "+tt.name, func(t *testing.T) {
+				result := tt.result
+				result.RelativePath = "sum.go"
+				result.Content = snippet
+				got := captureStdout(t, func() {
+					displayResults([]search.Result{result}, 0, 1, true, r)
+				})
+				assert.Equal(t, tt.want, lineNumbers(got))
+			})
+		}
+	}
+}
+
+// renderers returns snippet renderers without and with highlighting.
+func renderers() map[string]*snippetRenderer {
+	return map[string]*snippetRenderer{
+		"plain":       {},
+		"highlighted": {style: styles.Get("github"), formatter: formatters.Get("terminal16")},
+	}
+}
+
+// ansiEscape matches the terminal escape sequences of highlighting.
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// captureStdout returns what fn prints to standard output, without
+// terminal escape sequences.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	require.NoError(t, w.Close())
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return ansiEscape.ReplaceAllString(string(out), "")
+}
+
+// lineNumbers returns the line numbers of the snippet and context lines in
+// output, in order.
+func lineNumbers(output string) []int {
+	var numbers []int
+	for _, line := range strings.Split(output, "\n") {
+		prefix, _, found := strings.Cut(line, "│")
+		if !found {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(prefix)); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/selftest"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+// selftestCmd checks the installation end to end
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Check that indexing and search work with the configured provider",
+	Long: `Index a small bundled corpus with the configured embedding provider into a
+temporary database, then verify that embeddings are consistent, that every
+file is indexed, and that searches find the expected files.
+
+Your index is not touched. Use it to check a new installation or provider
+setup; the exit code is 0 when every check passes.`,
+	Args: cobra.NoArgs,
+	RunE: runSelftest,
+}
+
+func init() {
+	rootCmd.AddCommand(selftestCmd)
+}
+
+func runSelftest(cmd *cobra.Command, args []string) error {
+	cfg := config.Get()
+
+	// Setup context with cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Handle interrupt signals
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	// Indexing progress is noise here; each check reports its own result
+	if !debug {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	emb, err := embeddings.NewService(cfg)
+	if err != nil {
+		return withExitCode(ExitProviderUnavailable, fmt.Errorf("failed to create embedding service: %w", err))
+	}
+
+	if !quiet {
+		fmt.Println(ui.Header.Render("Self-test"))
+		fmt.Printf("Provider: %s (%s)\n\n", emb.Provider(), emb.ModelName())
+	}
+
+	providerFailed := false
+	err = selftest.Run(ctx, emb, cfg, func(s selftest.Step) {
+		if s.Err != nil {
+			providerFailed = s.Name == "Embed"
+			fmt.Printf("  %s %-11s %v\n", ui.Error.Render("✗"), s.Name, s.Err)
+			return
+		}
+		if !quiet {
+			fmt.Printf("  %s %-11s %s %s\n", ui.Success.Render("✓"), s.Name, s.Detail,
+				ui.Dim.Render(s.Duration.Round(time.Millisecond).String()))
+		}
+	})
+	if err != nil {
+		cmd.SilenceErrors = true
+		if providerFailed {
+			return withExitCode(ExitProviderUnavailable, err)
+		}
+		return err
+	}
+
+	if !quiet {
+		fmt.Println()
+		fmt.Println(ui.Success.Render("All checks passed."))
+	}
+	return nil
+}
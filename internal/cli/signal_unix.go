@@ -0,0 +1,11 @@
+//go:build !windows
+
+package cli
+
+import (
+	"os"
+	"syscall"
+)
+
+// pauseSignals toggle pausing indexing by a running watcher.
+var pauseSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package cli
+
+import "os"
+
+// pauseSignals toggle pausing indexing by a running watcher. Windows has no
+// user signals; use 'lgrep watch pause' instead.
+var pauseSignals []os.Signal
@@ -4,21 +4,30 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
 
 	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/cost"
+	"github.com/nickcecere/lgrep/internal/lock"
 	"github.com/nickcecere/lgrep/internal/store"
 	"github.com/nickcecere/lgrep/internal/ui"
 )
 
 var (
-	statusStore string
-	statusAll   bool
+	statusStore   string
+	statusAll     bool
+	statusHistory int
 )
 
+// defaultHistoryRuns is how many runs --history shows without a count.
+const defaultHistoryRuns = 10
+
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
 	Use:   "status",
@@ -27,7 +36,12 @@ var statusCmd = &cobra.Command{
 - Number of indexed files
 - Number of chunks
 - Embedding provider and model used
-- Last indexing time
+- Last indexing time and the run that did it
+
+With --history, recent index runs are listed: full runs by 'lgrep index',
+searches, the MCP server and the watcher, and each batch of file changes the
+watcher applied. A run still in progress shows the process holding the
+store's index lock.
 
 Examples:
   # Show status for current directory's store
@@ -37,13 +51,22 @@ Examples:
   lgrep status --store myproject
 
   # Show all stores
-  lgrep status --all`,
+  lgrep status --all
+
+  # Show the last 10 index runs, e.g. to check the watcher is working
+  lgrep status --history
+
+  # Show the last 50
+  lgrep status --history=50`,
 	RunE: runStatus,
 }
 
 func init() {
 	statusCmd.Flags().StringVar(&statusStore, "store", "", "specific store to show status for")
+	_ = statusCmd.RegisterFlagCompletionFunc("store", completeStoreNames)
 	statusCmd.Flags().BoolVar(&statusAll, "all", false, "show all stores")
+	statusCmd.Flags().IntVar(&statusHistory, "history", 0, "show this many recent index runs")
+	statusCmd.Flags().Lookup("history").NoOptDefVal = fmt.Sprint(defaultHistoryRuns)
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
@@ -52,7 +75,7 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	cfg := config.Get()
 
 	// Open store
-	st, err := store.NewSQLiteStore(cfg.Database.Path)
+	st, err := store.NewSQLiteStoreReadOnly(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
 	}
@@ -76,8 +99,9 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	if statusAll {
 		displayStores = stores
 	} else if statusStore != "" {
+		name := resolveStoreName(st, statusStore)
 		for _, s := range stores {
-			if s.Name == statusStore {
+			if s.Name == name {
 				displayStores = append(displayStores, s)
 				break
 			}
@@ -88,7 +112,7 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	} else {
 		// Try to find store for current directory
 		cwd, _ := os.Getwd()
-		cwdName := filepath.Base(cwd)
+		cwdName := resolveStoreName(st, filepath.Base(cwd))
 
 		// First try exact match on current directory name
 		for _, s := range stores {
@@ -142,6 +166,16 @@ func runStatus(cmd *cobra.Command, args []string) error {
 			ui.Dim.Render("Dimensions:"),
 			s.EmbeddingDimensions,
 		)
+		fmt.Printf("  %s %s\n",
+			ui.Dim.Render("Distance:"),
+			s.DistanceMetric,
+		)
+		if c, err := st.GetScoreCalibration(s.ID); err == nil && c != nil {
+			fmt.Printf("  %s unrelated chunks score %.2f (median) to %.2f (99th percentile), from %d samples\n",
+				ui.Dim.Render("Calibration:"),
+				c.Baseline, c.High, c.Samples,
+			)
+		}
 
 		// Stats
 		fmt.Printf("  %s %d files, %d chunks\n",
@@ -153,6 +187,19 @@ func runStatus(cmd *cobra.Command, args []string) error {
 			ui.Dim.Render("Size:"),
 			formatBytes(stats.TotalSize),
 		)
+		if len(stats.Languages) > 0 {
+			fmt.Printf("  %s %s\n",
+				ui.Dim.Render("Languages:"),
+				formatLanguages(stats.Languages),
+			)
+		}
+		if stats.Usage.InputTokens > 0 || stats.Usage.OutputTokens > 0 {
+			fmt.Printf("  %s %d tokens, %s\n",
+				ui.Dim.Render("Usage:"),
+				stats.Usage.InputTokens+stats.Usage.OutputTokens,
+				cost.FormatUSD(stats.Usage.Cost),
+			)
+		}
 
 		// Timestamps
 		fmt.Printf("  %s %s\n",
@@ -171,6 +218,27 @@ func runStatus(cmd *cobra.Command, args []string) error {
 			health,
 		)
 
+		if pausedAt, err := st.GetWatchPause(s.ID); err == nil && !pausedAt.IsZero() {
+			fmt.Printf("  %s %s\n",
+				ui.Dim.Render("Watch:"),
+				ui.Warning.Render("paused since "+formatTime(pausedAt.Local())+" (lgrep watch resume)"),
+			)
+		}
+
+		// Run history
+		limit := max(statusHistory, 1)
+		if runs, err := st.ListIndexRuns(s.ID, limit); err == nil && len(runs) > 0 {
+			lockPath := lock.NamespaceStorePath(cfg.Database.Path, cfg.Database.Namespace, s.Name)
+			if statusHistory > 0 {
+				fmt.Printf("  %s\n", ui.Dim.Render("History:"))
+				for _, run := range runs {
+					fmt.Printf("    %s\n", formatRun(run, runLive(run, lockPath)))
+				}
+			} else {
+				fmt.Printf("  %s %s\n", ui.Dim.Render("Last run:"), formatRun(runs[0], runLive(runs[0], lockPath)))
+			}
+		}
+
 		if i < len(displayStores)-1 {
 			fmt.Println()
 		}
@@ -186,11 +254,52 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	fmt.Println()
 	fmt.Println(ui.Dim.Render("Configuration:"))
 	fmt.Printf("  Database: %s\n", cfg.Database.Path)
+	if cfg.Database.Namespace != "" {
+		fmt.Printf("  Namespace: %s\n", cfg.Database.Namespace)
+	}
 	fmt.Printf("  Embedding Provider: %s\n", cfg.Embeddings.Provider)
+	if cfg.Budget.MonthlyUSD > 0 {
+		if month, err := st.GetUsageSummary("", cost.MonthStart(time.Now())); err == nil {
+			fmt.Printf("  Budget: %s of %s this month\n", cost.FormatUSD(month.Cost), cost.FormatUSD(cfg.Budget.MonthlyUSD))
+		}
+	}
 
 	return nil
 }
 
+// maxStatusLanguages is how many languages status lists before grouping
+// the rest.
+const maxStatusLanguages = 6
+
+// formatLanguages lists the file counts of languages, most files first,
+// e.g. "go 120, markdown 12, other 3".
+func formatLanguages(languages map[string]int) string {
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if languages[names[i]] != languages[names[j]] {
+			return languages[names[i]] > languages[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var parts []string
+	rest := 0
+	for i, name := range names {
+		if i >= maxStatusLanguages || name == "" {
+			rest += languages[name]
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %d", name, languages[name]))
+	}
+	if rest > 0 {
+		parts = append(parts, fmt.Sprintf("other %d", rest))
+	}
+	return strings.Join(parts, ", ")
+}
+
 // formatTime formats a time for display.
 func formatTime(t time.Time) string {
 	if t.IsZero() {
@@ -211,6 +320,55 @@ func formatTime(t time.Time) string {
 	return t.Format("Jan 2, 2006 at 15:04")
 }
 
+// runLive reports whether a run recorded as running is still in progress,
+// that is whether its process holds the store's index lock. A run whose
+// process was killed leaves the lock free, or held by a later process.
+func runLive(run store.IndexRun, lockPath string) bool {
+	if run.Status != store.RunRunning || !lock.Held(lockPath) {
+		return false
+	}
+	return strings.HasPrefix(lock.Holder(lockPath), strconv.Itoa(run.PID)+" ")
+}
+
+// formatRun describes an index run on one line. A run that never finished
+// and is not live was interrupted.
+func formatRun(run store.IndexRun, live bool) string {
+	status := run.Status
+	switch {
+	case run.Status == store.RunRunning && !live:
+		status = ui.Warning.Render("interrupted")
+	case run.Status == store.RunRunning:
+		status = ui.Highlight.Render(fmt.Sprintf("running (pid %d on %s)", run.PID, run.Host))
+	case run.Status == store.RunOK:
+		status = ui.Success.Render(status)
+	default:
+		status = ui.Warning.Render(status)
+	}
+
+	line := fmt.Sprintf("%s  %-8s %s", formatTime(run.StartedAt.Local()), run.Trigger, status)
+	if run.Status == store.RunRunning {
+		return line
+	}
+
+	line += fmt.Sprintf(", %d files", run.Files)
+	if run.Skipped > 0 {
+		line += fmt.Sprintf(" (%d unchanged)", run.Skipped)
+	}
+	if run.Removed > 0 {
+		line += fmt.Sprintf(", %d removed", run.Removed)
+	}
+	if run.Errors > 0 {
+		line += fmt.Sprintf(", %d errors", run.Errors)
+	}
+	if !run.FinishedAt.IsZero() {
+		line += fmt.Sprintf(", took %s", run.FinishedAt.Sub(run.StartedAt))
+	}
+	if run.Error != "" {
+		line += ": " + run.Error
+	}
+	return line
+}
+
 // getHealthStatus returns a health indicator based on stats.
 func getHealthStatus(stats *store.StoreStats) string {
 	if stats.FileCount == 0 {
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/lock"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// TestRunLive tests that a running run is only shown as running while its
+// process holds the store's lock, whether or not it is the newest run.
+func TestRunLive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "test.lock")
+	ours := store.IndexRun{PID: os.Getpid(), Status: store.RunRunning}
+	other := store.IndexRun{PID: os.Getpid() + 1, Status: store.RunRunning}
+
+	// Nobody holds the lock: the run was killed
+	assert.False(t, runLive(ours, path))
+
+	l, err := lock.TryAcquire(path)
+	require.NoError(t, err)
+	assert.True(t, runLive(ours, path))
+	assert.False(t, runLive(other, path), "the lock is held by a later process")
+	assert.False(t, runLive(store.IndexRun{PID: os.Getpid(), Status: store.RunOK}, path))
+
+	require.NoError(t, l.Release())
+	assert.False(t, runLive(ours, path))
+}
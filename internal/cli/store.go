@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/indexer"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+// storeCmd groups commands that manage stores.
+var storeCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Rename stores and manage their aliases",
+	Long: `Manage indexed stores without re-indexing them.
+
+Stores are named after the indexed directory unless --store is given, so
+indexing ./src creates a store called "src". Rename it to something more
+meaningful; the old name is kept as an alias, so searching from the same
+directory still finds the store.
+
+Examples:
+  # Rename a store
+  lgrep store rename src billing-api
+
+  # Add another name for a store
+  lgrep store alias billing-api billing
+
+  # Remove an alias
+  lgrep store unalias billing`,
+}
+
+var storeRenameCmd = &cobra.Command{
+	Use:               "rename <old> <new>",
+	Short:             "Rename a store, keeping the old name as an alias",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeStoreArg,
+	RunE:              runStoreRename,
+}
+
+var storeAliasCmd = &cobra.Command{
+	Use:               "alias <store> <alias>",
+	Short:             "Add another name for a store",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeStoreArg,
+	RunE:              runStoreAlias,
+}
+
+var storeUnaliasCmd = &cobra.Command{
+	Use:   "unalias <alias>",
+	Short: "Remove an alias from a store",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runStoreUnalias,
+}
+
+func init() {
+	storeCmd.AddCommand(storeRenameCmd)
+	storeCmd.AddCommand(storeAliasCmd)
+	storeCmd.AddCommand(storeUnaliasCmd)
+	rootCmd.AddCommand(storeCmd)
+}
+
+func runStoreRename(cmd *cobra.Command, args []string) error {
+	oldName, newName := args[0], args[1]
+
+	st, err := store.NewSQLiteStore(config.Get().Database.Path, store.WithNamespace(config.Get().Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	if err := st.RenameStore(oldName, newName); err != nil {
+		if errors.Is(err, store.ErrNameInUse) {
+			return fmt.Errorf("cannot rename to '%s': it is already used by another store", newName)
+		}
+		return fmt.Errorf("failed to rename store: %w", err)
+	}
+
+	fmt.Println(ui.Success.Render(fmt.Sprintf("Store '%s' renamed to '%s'.", oldName, newName)))
+	fmt.Println(ui.Dim.Render(fmt.Sprintf("'%s' remains an alias; remove it with 'lgrep store unalias %s'.", oldName, oldName)))
+	return nil
+}
+
+func runStoreAlias(cmd *cobra.Command, args []string) error {
+	name, alias := args[0], args[1]
+
+	st, err := store.NewSQLiteStore(config.Get().Database.Path, store.WithNamespace(config.Get().Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	if err := st.AddStoreAlias(name, alias); err != nil {
+		if errors.Is(err, store.ErrNameInUse) {
+			return fmt.Errorf("cannot add alias '%s': it is already used by another store", alias)
+		}
+		return fmt.Errorf("failed to add alias: %w", err)
+	}
+
+	fmt.Println(ui.Success.Render(fmt.Sprintf("'%s' now refers to store '%s'.", alias, name)))
+	return nil
+}
+
+func runStoreUnalias(cmd *cobra.Command, args []string) error {
+	st, err := store.NewSQLiteStore(config.Get().Database.Path, store.WithNamespace(config.Get().Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	if err := st.RemoveStoreAlias(args[0]); err != nil {
+		return err
+	}
+
+	fmt.Println(ui.Success.Render(fmt.Sprintf("Alias '%s' removed.", args[0])))
+	return nil
+}
+
+// resolveStoreName returns the current name of the store called name,
+// following aliases. If no such store exists, name is returned unchanged.
+func resolveStoreName(st store.Store, name string) string {
+	if record, err := st.GetStore(name); err == nil && record != nil {
+		return record.Name
+	}
+	return name
+}
+
+// partitionedRoot returns a usage error if absPath is the root of a
+// monorepo split into partition stores, which are searched one at a time.
+func partitionedRoot(cfg *config.Config, absPath string) error {
+	partitions, err := indexer.Partitions(cfg, absPath)
+	if err != nil || len(partitions) == 0 {
+		return nil
+	}
+	names := make([]string, len(partitions))
+	for i, p := range partitions {
+		names[i] = p.Store
+	}
+	return withExitCode(ExitUsage, fmt.Errorf("%s is split into the stores %s; search one with --store, or search from a directory inside it",
+		absPath, strings.Join(names, ", ")))
+}
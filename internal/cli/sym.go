@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/cost"
+	"github.com/nickcecere/lgrep/internal/search"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+var (
+	symLimit   int
+	symStore   string
+	symPrefix  bool
+	symRefs    bool
+	symContent bool
+	symJSON    bool
+)
+
+// symCmd finds the definitions and uses of a symbol.
+var symCmd = &cobra.Command{
+	Use:   "sym <name> [path]",
+	Short: "Find where a symbol is defined and used",
+	Long: `Look up a function, type, class or other definition by name in the symbols
+recorded when the store was indexed. Names match exactly and case-sensitively,
+so the lookup is faster and more precise than a semantic search and needs no
+query embedding. When no definition is found, a semantic search for the name
+is shown instead.
+
+Symbols are recorded for languages with code-aware chunking. Stores indexed
+by an earlier version have none until re-indexed with 'lgrep index --force'.
+
+A path inside the store restricts matches to the files under it.
+
+Examples:
+  # Go to a definition
+  lgrep sym ParseConfig
+
+  # Every symbol starting with "Parse"
+  lgrep sym Parse --prefix
+
+  # Definitions and uses, under ./internal only
+  lgrep sym ParseConfig ./internal --refs`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runSym,
+}
+
+func init() {
+	symCmd.Flags().IntVarP(&symLimit, "limit", "m", 10, "maximum definitions, and references, to show (0 for no limit)")
+	symCmd.Flags().StringVar(&symStore, "store", "", "store name (auto-detected if not specified)")
+	_ = symCmd.RegisterFlagCompletionFunc("store", completeStoreNames)
+	symCmd.Flags().BoolVarP(&symPrefix, "prefix", "p", false, "match every symbol starting with the name")
+	symCmd.Flags().BoolVarP(&symRefs, "refs", "r", false, "also show where the name is used")
+	symCmd.Flags().BoolVarP(&symContent, "content", "c", false, "show the content of each match")
+	symCmd.Flags().BoolVar(&symJSON, "json", false, "output the matches as JSON")
+	rootCmd.AddCommand(symCmd)
+}
+
+func runSym(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	path := "."
+	if len(args) > 1 {
+		path = args[1]
+	}
+	limit, err := resultLimit(symLimit)
+	if err != nil {
+		return withExitCode(ExitUsage, err)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+
+	cfg := config.Get()
+	st, err := store.NewSQLiteStore(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	emb, err := newMeteredEmbedder(st, cfg)
+	if err != nil {
+		return err
+	}
+	searcher := search.New(st, emb)
+
+	var storeRecord *store.StoreRecord
+	if symStore != "" {
+		storeRecord, err = st.GetStore(symStore)
+	} else {
+		storeRecord, err = searcher.GetStoreForPath(absPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check store: %w", err)
+	}
+	if storeRecord == nil {
+		if symStore != "" {
+			return withExitCode(ExitStoreMissing, fmt.Errorf("store not found: %s", symStore))
+		}
+		return withExitCode(ExitStoreMissing, fmt.Errorf("no indexed store contains %s; pass --store or run 'lgrep index' first", absPath))
+	}
+
+	pathPrefix := ""
+	if len(args) > 1 {
+		pathPrefix = search.PathPrefixFor(storeRecord.RootPath, absPath)
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	results, err := searcher.FindSymbol(ctx, name, search.SymbolOptions{
+		StoreName:      storeRecord.Name,
+		Prefix:         symPrefix,
+		References:     symRefs,
+		Limit:          limit,
+		PathPrefix:     pathPrefix,
+		IncludeContent: symContent,
+	})
+	emb.Flush(st, storeRecord.Name, cost.OpSearch)
+	if err != nil {
+		return providerUnavailable(err)
+	}
+
+	if symJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		displaySymbols(results, name, cfg)
+	}
+
+	if len(results.Definitions) == 0 && len(results.References) == 0 && len(results.Semantic) == 0 {
+		cmd.SilenceErrors = true
+		return errNoResults
+	}
+	return nil
+}
+
+// displaySymbols prints the definitions and uses of a symbol, or the
+// semantic matches found in their place.
+func displaySymbols(results *search.SymbolResults, name string, cfg *config.Config) {
+	if quiet {
+		for _, matches := range [][]search.SymbolResult{results.Definitions, results.References} {
+			for _, r := range matches {
+				fmt.Printf("%s:%d\n", r.RelativePath, r.Line)
+			}
+		}
+		if len(results.Definitions) == 0 {
+			displayQuiet(results.Semantic)
+		}
+		return
+	}
+
+	var snippets *snippetRenderer
+	if symContent {
+		snippets = newSnippetRenderer(cfg, name)
+	}
+
+	if len(results.Definitions) > 0 {
+		fmt.Println(ui.Header.Render(fmt.Sprintf("Definitions (%d):", len(results.Definitions))))
+		displaySymbolMatches(results.Definitions, snippets)
+	} else {
+		fmt.Printf("No definition of %s found.\n", ui.Bold.Render(name))
+		if results.NoSymbols {
+			fmt.Println(ui.Warning.Render("This store has no recorded symbols. Run 'lgrep index --force' to record them."))
+		}
+	}
+
+	if symRefs {
+		fmt.Println()
+		if len(results.References) == 0 {
+			fmt.Println("No references found.")
+		} else {
+			fmt.Println(ui.Header.Render(fmt.Sprintf("References (%d):", len(results.References))))
+			displaySymbolMatches(results.References, snippets)
+		}
+	}
+
+	if len(results.Definitions) == 0 {
+		fmt.Println()
+		if len(results.Semantic) == 0 {
+			fmt.Println("No semantic matches found.")
+			return
+		}
+		fmt.Print(ui.Dim.Render("Semantic search: "))
+		displayResults(results.Semantic, 0, len(results.Semantic), symContent, newSnippetRenderer(cfg, name))
+	}
+}
+
+// displaySymbolMatches prints one line per match, followed by its content
+// when snippets is set.
+func displaySymbolMatches(matches []search.SymbolResult, snippets *snippetRenderer) {
+	for _, r := range matches {
+		location := fmt.Sprintf("%s:%d", r.RelativePath, r.Line)
+		fmt.Printf("  %s  %s\n", ui.FilePath.Render(location), r.Text)
+		if snippets != nil && r.Content != "" {
+			fmt.Println()
+			snippets.display(r.Content, r.StartLine, r.RelativePath)
+			fmt.Println()
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/indexer"
+	"github.com/nickcecere/lgrep/internal/search"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+var (
+	verifyStore string
+	verifyJSON  bool
+)
+
+// errIndexOutdated reports an index that does not match the files. Like
+// diff, 'lgrep verify' exits with 1 when it finds differences.
+var errIndexOutdated = &exitError{code: ExitNoResults}
+
+// verifyCmd checks that an index is up to date with the working tree.
+var verifyCmd = &cobra.Command{
+	Use:   "verify [path]",
+	Short: "Check that the index matches the files on disk",
+	Long: `Check that the store of a directory is up to date: every file that
+'lgrep index' would index is in the store, with the same content hash.
+Nothing is embedded or written.
+
+The exit code is 0 when the index is up to date and 1 when a file was
+changed since it was indexed, is not indexed at all, or is still in the
+store but no longer found, so pipelines that publish a shared index can
+gate on it. 'lgrep index --force' drops files that are no longer found.
+
+The root of a partitioned monorepo verifies each partition's store.
+
+Examples:
+  # Verify the store of the current directory
+  lgrep verify
+
+  # In CI, list the files that differ
+  lgrep verify -q || { echo "index is stale"; exit 1; }
+
+  # Machine-readable report
+  lgrep verify --json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runVerify,
+}
+
+func init() {
+	verifyCmd.Flags().StringVar(&verifyStore, "store", "", "store to verify (auto-detected if not specified)")
+	_ = verifyCmd.RegisterFlagCompletionFunc("store", completeStoreNames)
+	verifyCmd.Flags().BoolVar(&verifyJSON, "json", false, "output the report as JSON")
+	rootCmd.AddCommand(verifyCmd)
+}
+
+// verifyReport compares one store with the files under its root.
+type verifyReport struct {
+	Store     string   `json:"store"`
+	Root      string   `json:"root"`
+	UpToDate  bool     `json:"up_to_date"`
+	Unchanged int      `json:"unchanged"`
+	Changed   []string `json:"changed"` // Indexed with another hash
+	Missing   []string `json:"missing"` // Not indexed
+	Removed   []string `json:"removed"` // Indexed but no longer found
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	cfg := config.Get()
+	st, err := store.NewSQLiteStoreReadOnly(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	records, err := verifyStores(st, cfg, absPath)
+	if err != nil {
+		return err
+	}
+
+	reports := make([]verifyReport, 0, len(records))
+	upToDate := true
+	for _, storeRecord := range records {
+		report, err := verifyStoreFiles(st, cfg, storeRecord)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, *report)
+		upToDate = upToDate && report.UpToDate
+	}
+
+	switch {
+	case verifyJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			return err
+		}
+	case quiet:
+		// The files that differ, one per line
+		for _, r := range reports {
+			for _, p := range append(append(append([]string{}, r.Changed...), r.Missing...), r.Removed...) {
+				fmt.Println(filepath.Join(r.Root, filepath.FromSlash(p)))
+			}
+		}
+	default:
+		for i, r := range reports {
+			if i > 0 {
+				fmt.Println()
+			}
+			printVerifyReport(r)
+		}
+	}
+
+	if !upToDate {
+		cmd.SilenceErrors = true
+		return errIndexOutdated
+	}
+	return nil
+}
+
+// verifyStores returns the stores to verify for absPath: the --store store,
+// the partition stores of a partitioned root, or the store containing it.
+func verifyStores(st store.Store, cfg *config.Config, absPath string) ([]*store.StoreRecord, error) {
+	var names []string
+	if verifyStore != "" {
+		names = []string{verifyStore}
+	} else {
+		partitions, err := indexer.Partitions(cfg, absPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range partitions {
+			names = append(names, p.Store)
+		}
+	}
+
+	if len(names) == 0 {
+		storeRecord, err := search.New(st, nil).GetStoreForPath(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find store: %w", err)
+		}
+		if storeRecord != nil {
+			return []*store.StoreRecord{storeRecord}, nil
+		}
+		names = []string{indexer.StoreName(cfg, absPath)}
+	}
+
+	records := make([]*store.StoreRecord, 0, len(names))
+	for _, name := range names {
+		storeRecord, err := st.GetStore(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check store: %w", err)
+		}
+		if storeRecord == nil {
+			return nil, withExitCode(ExitStoreMissing, fmt.Errorf("store not found: %s (run 'lgrep index' first)", name))
+		}
+		records = append(records, storeRecord)
+	}
+	return records, nil
+}
+
+// verifyStoreFiles scans the root of a store as an index run would and
+// compares the files found with those indexed.
+func verifyStoreFiles(st store.Store, cfg *config.Config, storeRecord *store.StoreRecord) (*verifyReport, error) {
+	if _, err := os.Stat(storeRecord.RootPath); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("root of store '%s' not found: %s", storeRecord.Name, storeRecord.RootPath)
+	}
+	scan, err := indexer.Scan(cfg, storeRecord.RootPath, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := scan.Diff(st, storeRecord, cfg, indexer.DefaultBatchSize, false)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &verifyReport{
+		Store:     storeRecord.Name,
+		Root:      storeRecord.RootPath,
+		UpToDate:  len(diff.Changed) == 0 && len(diff.Added) == 0 && len(diff.Removed) == 0,
+		Unchanged: diff.Unchanged,
+		Changed:   []string{},
+		Missing:   []string{},
+		Removed:   []string{},
+	}
+	for _, fi := range diff.Changed {
+		report.Changed = append(report.Changed, fi.RelPath)
+	}
+	for _, fi := range diff.Added {
+		report.Missing = append(report.Missing, fi.RelPath)
+	}
+	for _, f := range diff.Removed {
+		report.Removed = append(report.Removed, f.RelativePath)
+	}
+	return report, nil
+}
+
+// printVerifyReport prints the outcome of verifying one store.
+func printVerifyReport(r verifyReport) {
+	if r.UpToDate {
+		fmt.Println(ui.Success.Render(fmt.Sprintf("✓ '%s' is up to date (%d files).", r.Store, r.Unchanged)))
+	} else {
+		fmt.Println(ui.Error.Render(fmt.Sprintf("✗ '%s' is out of date: %d changed, %d not indexed, %d no longer found.",
+			r.Store, len(r.Changed), len(r.Missing), len(r.Removed))))
+	}
+	printVerifyFiles("Changed since indexing", r.Changed)
+	printVerifyFiles("Not indexed", r.Missing)
+	printVerifyFiles("No longer found", r.Removed)
+	switch {
+	case len(r.Removed) > 0:
+		fmt.Println(ui.Dim.Render(fmt.Sprintf("Run 'lgrep index --force %s' to update it and drop the files no longer found.", r.Root)))
+	case !r.UpToDate:
+		fmt.Println(ui.Dim.Render(fmt.Sprintf("Run 'lgrep index %s' to update it.", r.Root)))
+	}
+}
+
+// printVerifyFiles lists paths under a heading, if there are any.
+func printVerifyFiles(heading string, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Printf("  %s:\n", heading)
+	for _, p := range paths {
+		fmt.Printf("    %s\n", ui.FilePath.Render(p))
+	}
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/indexer"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// TestVerifyExitCode tests that 'lgrep verify' exits with 0 for an up to
+// date index and with 1 when a file was changed, added or removed.
+func TestVerifyExitCode(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":  "package main\n\nfunc main() {}\n",
+		"lib.go":   "package main\n\nfunc lib() {}\n",
+		"extra.go": "package main\n\nfunc extra() {}\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(content), 0644))
+	}
+
+	cfg := config.Get()
+	defer func(path string) { cfg.Database.Path = path }(cfg.Database.Path)
+	cfg.Database.Path = filepath.Join(t.TempDir(), "test.db")
+	defer func(name string, q bool) { verifyStore, quiet = name, q }(verifyStore, quiet)
+	verifyStore, quiet = "test", true
+
+	// Index every file as a run would, by the hashes of a scan
+	st, err := store.NewSQLiteStore(cfg.Database.Path)
+	require.NoError(t, err)
+	defer st.Close()
+	storeRecord, err := st.CreateStore("test", root, store.ProviderOllama, "model", 2)
+	require.NoError(t, err)
+	scan, err := indexer.Scan(cfg, root, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, scan.Files, 3)
+	for _, fi := range scan.Files {
+		require.NoError(t, st.UpsertFile(storeRecord.ID, store.FileInput{
+			ExternalID:   fi.RelPath,
+			Path:         fi.Path,
+			RelativePath: fi.RelPath,
+			Hash:         fi.Hash,
+			FileSize:     fi.Size,
+		}, []store.Chunk{{Content: "x", StartLine: 1, EndLine: 1}}, [][]float32{{1, 0}}))
+	}
+
+	verify := func() int {
+		return ExitCode(runVerify(verifyCmd, []string{root}))
+	}
+	require.Equal(t, ExitOK, verify())
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T) func()
+	}{
+		{"changed", func(t *testing.T) func() {
+			path := filepath.Join(root, "main.go")
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, []byte("package main\n"), 0644))
+			return func() { require.NoError(t, os.WriteFile(path, content, 0644)) }
+		}},
+		{"not indexed", func(t *testing.T) func() {
+			path := filepath.Join(root, "new.go")
+			require.NoError(t, os.WriteFile(path, []byte("package main\n"), 0644))
+			return func() { require.NoError(t, os.Remove(path)) }
+		}},
+		{"no longer found", func(t *testing.T) func() {
+			path := filepath.Join(root, "extra.go")
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NoError(t, os.Remove(path))
+			return func() { require.NoError(t, os.WriteFile(path, content, 0644)) }
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			undo := tt.change(t)
+			assert.Equal(t, ExitNoResults, verify())
+			undo()
+			assert.Equal(t, ExitOK, verify())
+		})
+	}
+}
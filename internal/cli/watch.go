@@ -13,7 +13,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/nickcecere/lgrep/internal/config"
-	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/cost"
 	"github.com/nickcecere/lgrep/internal/indexer"
 	"github.com/nickcecere/lgrep/internal/store"
 	"github.com/nickcecere/lgrep/internal/ui"
@@ -21,7 +21,10 @@ import (
 )
 
 var (
-	watchNoInitial bool
+	watchNoInitial       bool
+	watchReindexInterval time.Duration
+	watchPoll            bool
+	watchPollInterval    time.Duration
 )
 
 // watchCmd represents the watch command.
@@ -41,13 +44,26 @@ Examples:
   lgrep watch ./src
 
   # Skip initial sync (assumes already indexed)
-  lgrep watch --no-initial`,
+  lgrep watch --no-initial
+
+  # Also reconcile the whole tree daily, for file systems that miss events
+  lgrep watch --full-reindex-interval 24h
+
+  # Poll for changes on a network mount
+  lgrep watch --poll --poll-interval 30s /mnt/share/project
+
+  # Pause indexing during a large rebase, then catch up
+  lgrep watch pause
+  lgrep watch resume`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runWatchCmd,
 }
 
 func init() {
 	watchCmd.Flags().BoolVar(&watchNoInitial, "no-initial", false, "skip initial index sync")
+	watchCmd.Flags().BoolVar(&watchPoll, "poll", false, "scan for changes instead of using file system events (NFS, SMB, WSL2 mounts)")
+	watchCmd.Flags().DurationVar(&watchPollInterval, "poll-interval", watcher.DefaultPollInterval, "how often to scan for changes when polling, including when events are unavailable")
+	watchCmd.Flags().DurationVar(&watchReindexInterval, "full-reindex-interval", 0, "also index changed files and remove deleted ones this often, e.g. 24h (0 disables)")
 }
 
 func runWatchCmd(cmd *cobra.Command, args []string) error {
@@ -56,6 +72,13 @@ func runWatchCmd(cmd *cobra.Command, args []string) error {
 		path = args[0]
 	}
 
+	if watchReindexInterval < 0 {
+		return withExitCode(ExitUsage, fmt.Errorf("--full-reindex-interval must not be negative"))
+	}
+	if watchPollInterval <= 0 {
+		return withExitCode(ExitUsage, fmt.Errorf("--poll-interval must be positive"))
+	}
+
 	// Resolve absolute path
 	absPath, err := filepath.Abs(path)
 	if err != nil {
@@ -83,34 +106,119 @@ func runWatchCmd(cmd *cobra.Command, args []string) error {
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigCh
-		fmt.Println("\nShutting down...")
+		fmt.Println("\nShutting down... (press Ctrl+C again to quit now)")
+		// A second interrupt kills the process, skipping queued changes
+		signal.Stop(sigCh)
 		cancel()
 	}()
 
 	// Open store
-	st, err := store.NewSQLiteStore(cfg.Database.Path)
+	st, err := store.NewSQLiteStore(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
 	}
 	defer st.Close()
+	checkDatabase(st, cfg)
+
+	// The root of a partitioned monorepo is watched as one store per
+	// partition, all fed by this process
+	targets, err := indexer.Partitions(cfg, absPath)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		targets = []indexer.Partition{{Store: indexer.StoreName(cfg, absPath), Path: absPath}}
+	} else {
+		indexer.LogRootFiles(cfg, absPath)
+	}
+
+	var watchers []*watcher.Watcher
+	for _, target := range targets {
+		w, flush, err := startWatch(ctx, st, cfg, target)
+		if err != nil {
+			return err
+		}
+		defer flush()
+		if ctx.Err() != nil {
+			return nil // User cancelled
+		}
+		watchers = append(watchers, w)
+	}
+
+	// Start watching
+	if !quiet {
+		fmt.Println(ui.Header.Render("Watching for Changes"))
+		fmt.Printf("Directory: %s\n", absPath)
+		if len(watchers) > 1 {
+			fmt.Printf("Stores: %d partitions\n", len(watchers))
+		}
+		fmt.Println("Press Ctrl+C to stop.")
+		fmt.Println()
+	}
 
+	// Apply config file edits without a restart
+	stopConfigWatch := config.Watch(func(cfg *config.Config) {
+		for _, w := range watchers {
+			w.SetConfig(cfg)
+		}
+	})
+	defer stopConfigWatch()
+
+	// Stopping on Ctrl+C is not an error. A partition that fails to watch
+	// stops the others.
+	ctx, cancelWatch := context.WithCancel(ctx)
+	defer cancelWatch()
+	errs := make(chan error, len(watchers))
+	for _, w := range watchers {
+		togglePauseOnSignal(ctx, func() *watcher.Watcher { return w })
+		go func(w *watcher.Watcher) {
+			err := w.Start(ctx)
+			if err != nil && ctx.Err() == nil {
+				err = fmt.Errorf("watching store '%s' failed: %w", w.GetStoreName(), err)
+				cancelWatch()
+			} else {
+				err = nil
+			}
+			errs <- err
+		}(w)
+	}
+
+	var watchErr error
+	for range watchers {
+		if err := <-errs; err != nil && watchErr == nil {
+			watchErr = err
+		}
+	}
+	return watchErr
+}
+
+// startWatch runs the initial index of a store unless --no-initial is set,
+// and returns a watcher for it with a function recording the usage of its
+// embedding service. Each store gets its own embedding service, so usage is
+// recorded against the store it was spent on. The watcher is nil when ctx is
+// cancelled during the initial index.
+func startWatch(ctx context.Context, st store.Store, cfg *config.Config, target indexer.Partition) (*watcher.Watcher, func(), error) {
 	// Create embedding service
-	emb, err := embeddings.NewService(cfg)
+	emb, err := newMeteredEmbedder(st, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create embedding service: %w", err)
+		return nil, nil, err
 	}
 
-	// Determine store name
-	storeName := filepath.Base(absPath)
+	// Determine store name, following aliases of a renamed store
+	storeName := resolveStoreName(st, target.Store)
+	absPath := target.Path
+	flush := func() { emb.Flush(st, storeName, cost.OpIndex) }
 
 	// Create indexer for initial sync
 	idx := indexer.New(st, emb, cfg)
 
 	// Perform initial sync unless --no-initial is set
 	if !watchNoInitial {
-		fmt.Println(ui.Header.Render("Initial Index"))
-		fmt.Printf("Path: %s\n", absPath)
-		fmt.Printf("Provider: %s (%s)\n\n", cfg.Embeddings.Provider, cfg.Embeddings.Ollama.Model)
+		if !quiet {
+			fmt.Println(ui.Header.Render("Initial Index"))
+			fmt.Printf("Path: %s\n", absPath)
+			fmt.Printf("Provider: %s (%s)\n\n", cfg.Embeddings.Provider, cfg.Embeddings.Ollama.Model)
+		}
 
 		stopSpinner := make(chan struct{})
 		spinnerDone := make(chan struct{})
@@ -121,26 +229,30 @@ func runWatchCmd(cmd *cobra.Command, args []string) error {
 			Path:      absPath,
 			Force:     false,
 			BatchSize: 50, // Default batch size
+			// Reuse a run already in progress, e.g. from the MCP server
+			LockPolicy: indexer.LockDelegate,
+			Trigger:    store.TriggerReindex,
 			OnProgress: func(p indexer.Progress) {
 				// Progress is shown via spinner
 			},
 		}
 
 		err = idx.Index(ctx, opts)
+		emb.Flush(st, storeName, cost.OpIndex)
 
 		close(stopSpinner)
 		<-spinnerDone
 
 		if err != nil {
 			if ctx.Err() != nil {
-				return nil // User cancelled
+				return nil, flush, nil // User cancelled
 			}
-			return fmt.Errorf("initial index failed: %w", err)
+			return nil, nil, fmt.Errorf("initial index failed: %w", err)
 		}
 
 		// Show stats
 		storeRecord, _ := st.GetStore(storeName)
-		if storeRecord != nil {
+		if storeRecord != nil && !quiet {
 			stats, _ := st.GetStats(storeRecord.ID)
 			if stats != nil {
 				fmt.Printf("Initial index complete: %d files, %d chunks\n\n",
@@ -150,26 +262,22 @@ func runWatchCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create watcher
-	w, err := watcher.New(
-		absPath,
-		storeName,
-		st,
-		emb,
-		cfg,
-		watcher.WithDebounceTime(500*time.Millisecond),
+	watchOpts := []watcher.Option{
+		watcher.WithDebounceTime(500 * time.Millisecond),
+		watcher.WithFullReindexInterval(watchReindexInterval),
+		watcher.WithPollInterval(watchPollInterval),
 		watcher.WithEventCallback(func(event, path string) {
-			log.Debug("File event", "event", event, "path", path)
+			log.Debug("File event", "event", event, "store", storeName, "path", path)
+			// Record usage from re-indexing earlier events
+			flush()
 		}),
-	)
+	}
+	if watchPoll {
+		watchOpts = append(watchOpts, watcher.WithPolling())
+	}
+	w, err := watcher.New(absPath, storeName, st, emb, cfg, watchOpts...)
 	if err != nil {
-		return fmt.Errorf("failed to create watcher: %w", err)
+		return nil, nil, fmt.Errorf("failed to create watcher: %w", err)
 	}
-
-	// Start watching
-	fmt.Println(ui.Header.Render("Watching for Changes"))
-	fmt.Printf("Directory: %s\n", absPath)
-	fmt.Println("Press Ctrl+C to stop.")
-	fmt.Println()
-
-	return w.Start(ctx)
+	return w, flush, nil
 }
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/indexer"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+	"github.com/nickcecere/lgrep/internal/watcher"
+)
+
+var watchPauseCmd = &cobra.Command{
+	Use:   "pause [path]",
+	Short: "Pause indexing by watchers of a directory's store",
+	Long: `Pause indexing by every watcher of the store of a directory (default: the
+current directory), including the background watcher of 'lgrep mcp'.
+Watchers keep queueing file changes and handle them when resumed, so large
+rebases or code generation runs do not churn the index.
+
+The pause is kept in the database, so it also applies to watchers started
+later, until 'lgrep watch resume'. On Linux and macOS, sending SIGUSR1 to
+'lgrep watch' toggles the pause too.
+
+Examples:
+  lgrep watch pause
+  git rebase -i main
+  lgrep watch resume`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWatchPause(args, true)
+	},
+}
+
+var watchResumeCmd = &cobra.Command{
+	Use:   "resume [path]",
+	Short: "Resume indexing by watchers of a directory's store",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWatchPause(args, false)
+	},
+}
+
+func init() {
+	watchCmd.AddCommand(watchPauseCmd)
+	watchCmd.AddCommand(watchResumeCmd)
+}
+
+// runWatchPause pauses or resumes watchers of the store of the directory in
+// args.
+func runWatchPause(args []string, pause bool) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	cfg := config.Get()
+	st, err := store.NewSQLiteStore(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	// Pausing the root of a partitioned monorepo pauses every partition
+	names := []string{indexer.StoreName(cfg, absPath)}
+	partitions, err := indexer.Partitions(cfg, absPath)
+	if err != nil {
+		return err
+	}
+	if len(partitions) > 0 {
+		names = names[:0]
+		for _, p := range partitions {
+			names = append(names, p.Store)
+		}
+	}
+
+	found := 0
+	for _, name := range names {
+		storeRecord, err := st.GetStore(name)
+		if err != nil {
+			return fmt.Errorf("failed to check store: %w", err)
+		}
+		if storeRecord == nil {
+			continue
+		}
+		found++
+
+		if pause {
+			if err := st.PauseWatch(storeRecord.ID); err != nil {
+				return err
+			}
+			fmt.Println(ui.Success.Render(fmt.Sprintf("Indexing of store '%s' paused.", storeRecord.Name)))
+			continue
+		}
+		if err := st.ResumeWatch(storeRecord.ID); err != nil {
+			return err
+		}
+		fmt.Println(ui.Success.Render(fmt.Sprintf("Indexing of store '%s' resumed.", storeRecord.Name)))
+	}
+	if found == 0 {
+		return withExitCode(ExitStoreMissing, fmt.Errorf("no store for %s; run 'lgrep watch' or 'lgrep index' first", absPath))
+	}
+	if pause {
+		fmt.Println(ui.Dim.Render("Watchers queue file changes until 'lgrep watch resume'."))
+	}
+	return nil
+}
+
+// togglePauseOnSignal pauses or resumes the watcher returned by current,
+// once there is one, on each of pauseSignals until ctx is done.
+func togglePauseOnSignal(ctx context.Context, current func() *watcher.Watcher) {
+	if len(pauseSignals) == 0 {
+		return
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, pauseSignals...)
+	go func() {
+		defer signal.Stop(sigCh)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigCh:
+				w := current()
+				if w == nil {
+					continue
+				}
+				if err := w.SetPaused(!w.Paused()); err != nil {
+					log.Error("Failed to pause or resume indexing", "error", err)
+					continue
+				}
+				w.Paused() // Logs the change
+			}
+		}
+	}()
+}
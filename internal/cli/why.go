@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/cost"
+	"github.com/nickcecere/lgrep/internal/search"
+	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
+)
+
+var (
+	whyLimit    int
+	whyStore    string
+	whyMinScore float64
+	whyContent  bool
+	whyJSON     bool
+)
+
+// whyCmd explains how a file's chunks rank for a query.
+var whyCmd = &cobra.Command{
+	Use:   "why <query> <path>",
+	Short: "Explain why a file does or does not match a query",
+	Long: `Score every chunk of an indexed file against a query and show where each
+ranks among all chunks of the store, compared with the cutoff a search with
+the same --limit and --min-score would apply.
+
+Use it to find out why an expected file is missing from results, and to
+tune chunk sizes or the query itself. Excluded terms and query expansion
+are not applied.
+
+Examples:
+  # Why is this file not in the results?
+  lgrep why "retry failed uploads" internal/upload/client.go
+
+  # Compare with a larger result limit
+  lgrep why "retry failed uploads" internal/upload/client.go -m 25
+
+  # Show each chunk's content
+  lgrep why "retry failed uploads" internal/upload/client.go -c`,
+	Args: cobra.ExactArgs(2),
+	RunE: runWhy,
+}
+
+func init() {
+	whyCmd.Flags().IntVarP(&whyLimit, "limit", "m", 10, "result limit to compare with (0 for up to 1000)")
+	whyCmd.Flags().StringVar(&whyStore, "store", "", "store name (auto-detected if not specified)")
+	_ = whyCmd.RegisterFlagCompletionFunc("store", completeStoreNames)
+	whyCmd.Flags().Float64Var(&whyMinScore, "min-score", 0.0, "minimum similarity score (0-1) to compare with")
+	whyCmd.Flags().BoolVarP(&whyContent, "content", "c", false, "show the content of each chunk")
+	whyCmd.Flags().BoolVar(&whyJSON, "json", false, "output the ranking as JSON")
+	rootCmd.AddCommand(whyCmd)
+}
+
+func runWhy(cmd *cobra.Command, args []string) error {
+	query := args[0]
+	limit, err := resultLimit(whyLimit)
+	if err != nil {
+		return withExitCode(ExitUsage, err)
+	}
+
+	absPath, err := filepath.Abs(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+
+	cfg := config.Get()
+	st, err := store.NewSQLiteStore(cfg.Database.Path, store.WithNamespace(cfg.Database.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	emb, err := newMeteredEmbedder(st, cfg)
+	if err != nil {
+		return err
+	}
+	searcher := search.New(st, emb)
+
+	var storeRecord *store.StoreRecord
+	if whyStore != "" {
+		storeRecord, err = st.GetStore(whyStore)
+	} else {
+		storeRecord, err = searcher.GetStoreForPath(absPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check store: %w", err)
+	}
+	if storeRecord == nil {
+		if whyStore != "" {
+			return withExitCode(ExitStoreMissing, fmt.Errorf("store not found: %s", whyStore))
+		}
+		return withExitCode(ExitStoreMissing, fmt.Errorf("no indexed store contains %s; pass --store or run 'lgrep index' first", absPath))
+	}
+
+	relPath, err := filepath.Rel(storeRecord.RootPath, absPath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside store '%s' (%s)", args[1], storeRecord.Name, storeRecord.RootPath)
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	e, err := searcher.Explain(ctx, query, relPath, search.SearchOptions{
+		StoreName:      storeRecord.Name,
+		TopK:           limit,
+		MinScore:       whyMinScore,
+		IncludeContent: whyContent,
+	})
+	emb.Flush(st, storeRecord.Name, cost.OpSearch)
+	if err != nil {
+		return providerUnavailable(err)
+	}
+
+	if whyJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(e)
+	}
+
+	displayExplanation(e, query, cfg)
+	return nil
+}
+
+// displayExplanation prints the ranking of a file's chunks.
+func displayExplanation(e *search.Explanation, query string, cfg *config.Config) {
+	if !quiet {
+		fmt.Printf("%s %s\n", ui.Header.Render("Query:"), query)
+		fmt.Printf("%s %s\n", ui.Header.Render("File: "), ui.FilePath.Render(e.File.RelativePath))
+		if e.Cutoff > 0 {
+			fmt.Printf("Top %d cutoff: %s", e.TopK, ui.ResultScore.Render(fmt.Sprintf("%.1f%%", e.Cutoff*100)))
+		} else {
+			fmt.Printf("Top %d cutoff: none (fewer results reach the minimum score)", e.TopK)
+		}
+		if e.MinScore > 0 {
+			fmt.Printf(", minimum score %.1f%%", e.MinScore*100)
+		}
+		fmt.Println()
+		fmt.Println()
+	}
+
+	if len(e.Chunks) == 0 {
+		fmt.Println("The file is indexed but has no chunks.")
+		return
+	}
+
+	var snippets *snippetRenderer
+	if whyContent {
+		snippets = newSnippetRenderer(cfg, query)
+	}
+
+	best := e.Chunks[0]
+	for _, c := range e.Chunks {
+		rank := fmt.Sprintf(">%d", e.Ranked)
+		if c.Rank > 0 {
+			rank = fmt.Sprintf("#%d", c.Rank)
+		}
+		fmt.Printf("%s %s %s  %s\n",
+			ui.Highlight.Render(fmt.Sprintf("%-6s", rank)),
+			ui.ResultScore.Render(fmt.Sprintf("%5.1f%%", c.Score*100)),
+			ui.LineNum.Render(fmt.Sprintf("lines %d-%d", c.StartLine, c.EndLine)),
+			chunkVerdict(e, c),
+		)
+		if whyContent && c.Content != "" {
+			fmt.Println()
+			snippets.display(c.Content, c.StartLine, e.File.RelativePath)
+			fmt.Println()
+		}
+	}
+
+	if quiet || best.Returned {
+		return
+	}
+	fmt.Println()
+	switch {
+	case best.Score < e.MinScore:
+		fmt.Println(ui.Dim.Render(fmt.Sprintf("No chunk reaches the minimum score; the best scores %.1f%%.", best.Score*100)))
+	case best.Rank > 0 && best.Rank <= search.MaxTopK:
+		fmt.Println(ui.Dim.Render(fmt.Sprintf("The best chunk would be returned with --limit %d, or rephrase the query closer to its content.", best.Rank)))
+	default:
+		fmt.Println(ui.Dim.Render("The file ranks far below the cutoff; rephrase the query closer to its content."))
+	}
+}
+
+// chunkVerdict describes whether a search returns chunk c, and if not, why.
+func chunkVerdict(e *search.Explanation, c search.ChunkRank) string {
+	switch {
+	case c.Returned:
+		return ui.Success.Render("✓ returned")
+	case c.Score < e.MinScore:
+		return ui.Error.Render("✗ below the minimum score")
+	case c.Rank == 0:
+		return ui.Error.Render(fmt.Sprintf("✗ not in the top %d", e.Ranked))
+	default:
+		return ui.Error.Render(fmt.Sprintf("✗ outside the top %d", e.TopK))
+	}
+}
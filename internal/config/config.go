@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/viper"
@@ -17,7 +19,62 @@ type Config struct {
 	Database   DatabaseConfig   `mapstructure:"database"`
 	Indexing   IndexingConfig   `mapstructure:"indexing"`
 	LLM        LLMConfig        `mapstructure:"llm"`
+	Search     SearchConfig     `mapstructure:"search"`
+	Budget     BudgetConfig     `mapstructure:"budget"`
+	MCP        MCPConfig        `mapstructure:"mcp"`
+	Network    NetworkConfig    `mapstructure:"network"`
+	UI         UIConfig         `mapstructure:"ui"`
 	Ignore     []string         `mapstructure:"ignore"`
+
+	// Partitions split monorepos into one store per top-level directory.
+	Partitions []PartitionConfig `mapstructure:"partitions"`
+}
+
+// PartitionConfig splits a monorepo into several stores, one per top-level
+// directory or per mapped directory, so searches can be scoped to a service
+// with --store monorepo/payments. Indexing or watching Root covers every
+// partition.
+type PartitionConfig struct {
+	// Root is the monorepo directory. A leading ~ is the home directory.
+	Root string `mapstructure:"root"`
+
+	// Name prefixes the names of the partition stores, as in
+	// "monorepo/payments". Empty uses the base name of Root.
+	Name string `mapstructure:"name"`
+
+	// Directories are the partitions of Root, each named and mapped to a
+	// directory under Root, e.g. {name: payments, path: services/payments}.
+	// They are a list rather than a map so names keep their case. Empty
+	// makes every top-level directory a partition named after it.
+	Directories []PartitionDirectory `mapstructure:"directories"`
+}
+
+// PartitionDirectory is a partition of a monorepo mapped to a directory.
+type PartitionDirectory struct {
+	Name string `mapstructure:"name"` // Partition name, e.g. "payments"
+	Path string `mapstructure:"path"` // Directory under Root, e.g. "services/payments"
+}
+
+// RootPath returns the absolute path of Root.
+func (p PartitionConfig) RootPath() string {
+	root := p.Root
+	if root == "~" || strings.HasPrefix(root, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			root = filepath.Join(home, root[1:])
+		}
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		return abs
+	}
+	return filepath.Clean(root)
+}
+
+// StorePrefix returns the prefix of the partition store names.
+func (p PartitionConfig) StorePrefix() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return filepath.Base(p.RootPath())
 }
 
 // EmbeddingsConfig configures the embedding service.
@@ -25,12 +82,64 @@ type EmbeddingsConfig struct {
 	Provider string            `mapstructure:"provider"`
 	Ollama   OllamaEmbedConfig `mapstructure:"ollama"`
 	OpenAI   OpenAIEmbedConfig `mapstructure:"openai"`
+	Voyage   VoyageEmbedConfig `mapstructure:"voyage"`
+	Cohere   CohereEmbedConfig `mapstructure:"cohere"`
+
+	// TruncateDimensions shortens embeddings from Matryoshka models to this
+	// many dimensions before storing and searching. Zero keeps full vectors.
+	TruncateDimensions int `mapstructure:"truncate_dimensions"`
+
+	// DistanceMetric is how new stores compare embeddings: cosine, l2 or
+	// dot. Dot product needs a model with normalized embeddings. A store
+	// keeps the metric it was created with until it is rebuilt.
+	DistanceMetric DistanceMetric `mapstructure:"distance_metric"`
+
+	// Fallbacks are providers tried in order when Provider cannot be
+	// reached or does not have the model. They must serve the same model,
+	// e.g. an OpenAI-compatible endpoint for an Ollama model.
+	Fallbacks []string `mapstructure:"fallbacks"`
 }
 
 // OllamaEmbedConfig configures Ollama embeddings.
 type OllamaEmbedConfig struct {
-	URL   string `mapstructure:"url"`
-	Model string `mapstructure:"model"`
+	URL      string `mapstructure:"url"`
+	Model    string `mapstructure:"model"`
+	AutoPull bool   `mapstructure:"auto_pull"` // Pull the model on first use if missing
+
+	// KeepAlive is how long Ollama keeps the model loaded after a request,
+	// e.g. "10m" or "-1" for indefinitely. Empty uses the server's default.
+	KeepAlive string `mapstructure:"keep_alive"`
+
+	// NumCtx is the context window the model is loaded with. Zero uses the
+	// model's default.
+	NumCtx int `mapstructure:"num_ctx"`
+
+	// Parallel is how many embed requests are sent at once. Raise it to
+	// match OLLAMA_NUM_PARALLEL on the server.
+	Parallel int `mapstructure:"parallel"`
+
+	HTTPConfig `mapstructure:",squash"`
+}
+
+// HTTPConfig configures the HTTP client used to reach a provider. It is
+// part of each provider's settings.
+type HTTPConfig struct {
+	// Timeout limits each request, including reading a streamed response.
+	// Zero means no limit.
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// MaxIdleConns is how many idle connections to the provider are kept
+	// for reuse. Zero uses Go's default of two.
+	MaxIdleConns int `mapstructure:"max_idle_conns"`
+
+	// CAFile is a PEM bundle of certificate authorities trusted for the
+	// provider's endpoint in addition to the system's, such as the CA of a
+	// TLS-intercepting proxy.
+	CAFile string `mapstructure:"ca_file"`
+
+	// InsecureSkipVerify turns off TLS certificate verification for the
+	// endpoint. Prefer CAFile.
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify"`
 }
 
 // OpenAIEmbedConfig configures OpenAI embeddings.
@@ -39,11 +148,47 @@ type OpenAIEmbedConfig struct {
 	BaseURL    string `mapstructure:"base_url"`
 	APIKey     string `mapstructure:"api_key"`
 	Dimensions int    `mapstructure:"dimensions"`
+
+	HTTPConfig `mapstructure:",squash"`
+}
+
+// VoyageEmbedConfig configures Voyage AI embeddings.
+type VoyageEmbedConfig struct {
+	Model      string `mapstructure:"model"`
+	BaseURL    string `mapstructure:"base_url"`
+	APIKey     string `mapstructure:"api_key"`
+	Dimensions int    `mapstructure:"dimensions"`
+
+	HTTPConfig `mapstructure:",squash"`
+}
+
+// CohereEmbedConfig configures Cohere embeddings.
+type CohereEmbedConfig struct {
+	Model      string `mapstructure:"model"`
+	BaseURL    string `mapstructure:"base_url"`
+	APIKey     string `mapstructure:"api_key"`
+	Dimensions int    `mapstructure:"dimensions"`
+
+	HTTPConfig `mapstructure:",squash"`
 }
 
 // DatabaseConfig configures the SQLite database.
 type DatabaseConfig struct {
 	Path string `mapstructure:"path"`
+
+	// Namespace isolates stores, Q&A history and usage from those of other
+	// namespaces in the same database. Empty is the default namespace.
+	Namespace string `mapstructure:"namespace"`
+
+	// CheckAtStartup runs the integrity check of 'lgrep db check' when
+	// 'lgrep watch' or 'lgrep mcp' starts. It reads the whole database, so
+	// it is off by default.
+	CheckAtStartup bool `mapstructure:"check_at_startup"`
+
+	// AutoRepair repairs problems found by the integrity check at startup,
+	// instead of only reporting them, and turns the check on; see 'lgrep
+	// db check'.
+	AutoRepair bool `mapstructure:"auto_repair"`
 }
 
 // IndexingConfig configures the indexing process.
@@ -52,6 +197,57 @@ type IndexingConfig struct {
 	MaxFileCount int `mapstructure:"max_file_count"`
 	ChunkSize    int `mapstructure:"chunk_size"`
 	ChunkOverlap int `mapstructure:"chunk_overlap"`
+
+	// MaxChunksPerFile caps the chunks indexed from one file, keeping its
+	// first and last chunks, so a huge generated file cannot dominate the
+	// store and results. Zero indexes every chunk.
+	MaxChunksPerFile int `mapstructure:"max_chunks_per_file"`
+
+	// MinFileSize skips files smaller than this many bytes, such as empty
+	// __init__.py files. Zero indexes files of any size.
+	MinFileSize int `mapstructure:"min_file_size"`
+
+	// TextExtensions are read as text even when they are known binary
+	// formats or look binary, e.g. ".svg".
+	TextExtensions []string `mapstructure:"text_extensions"`
+
+	// IncludeBinaryNames indexes the paths of binary files such as images,
+	// so that searches can find assets by name.
+	IncludeBinaryNames bool `mapstructure:"include_binary_names"`
+
+	// Languages limits indexing to files of some languages, by extension.
+	Languages LanguagesConfig `mapstructure:"languages"`
+
+	// AutoIndexMaxFiles and AutoIndexMaxBytes cap the size of a directory
+	// that search will index implicitly. Zero disables the check.
+	AutoIndexMaxFiles int   `mapstructure:"auto_index_max_files"`
+	AutoIndexMaxBytes int64 `mapstructure:"auto_index_max_bytes"`
+
+	// ContextLines is the number of lines stored before and after each
+	// chunk, used for result context when the file is not on disk.
+	ContextLines int `mapstructure:"context_lines"`
+
+	// StoreContent stores the text of each indexed file, so any amount of
+	// context is available without the file.
+	StoreContent bool `mapstructure:"store_content"`
+
+	// MaxBatchTokens caps the estimated tokens sent in one embedding
+	// request, so requests stay within provider limits for large chunks
+	// and carry more chunks when they are small. Zero sends a fixed number
+	// of chunks per request.
+	MaxBatchTokens int `mapstructure:"max_batch_tokens"`
+
+	// Relations records which files of a store import which, resolved from
+	// their imports at the end of each index run, for 'lgrep related' and
+	// search.related_boost.
+	Relations bool `mapstructure:"relations"`
+}
+
+// LanguagesConfig selects files to index by language, using the names of
+// the language map, e.g. "go" or "sql".
+type LanguagesConfig struct {
+	Include []string `mapstructure:"include"` // Empty includes every language
+	Exclude []string `mapstructure:"exclude"`
 }
 
 // LLMConfig configures the LLM service for Q&A.
@@ -60,12 +256,27 @@ type LLMConfig struct {
 	Ollama    OllamaLLMConfig `mapstructure:"ollama"`
 	OpenAI    OpenAILLMConfig `mapstructure:"openai"`
 	Anthropic AnthropicConfig `mapstructure:"anthropic"`
+
+	// MaxContextTokens limits the estimated size of the code context sent to
+	// the LLM for Q&A. Zero means no limit.
+	MaxContextTokens int `mapstructure:"max_context_tokens"`
+
+	// FollowImports adds to the Q&A context the definitions that the top
+	// results use from the files they import.
+	FollowImports bool `mapstructure:"follow_imports"`
+
+	// Fallbacks are providers tried in order, each with its configured
+	// model, when Provider cannot be reached or does not have the model.
+	Fallbacks []string `mapstructure:"fallbacks"`
 }
 
 // OllamaLLMConfig configures Ollama LLM.
 type OllamaLLMConfig struct {
-	URL   string `mapstructure:"url"`
-	Model string `mapstructure:"model"`
+	URL      string `mapstructure:"url"`
+	Model    string `mapstructure:"model"`
+	AutoPull bool   `mapstructure:"auto_pull"` // Pull the model on first use if missing
+
+	HTTPConfig `mapstructure:",squash"`
 }
 
 // OpenAILLMConfig configures OpenAI LLM.
@@ -73,19 +284,234 @@ type OpenAILLMConfig struct {
 	Model   string `mapstructure:"model"`
 	BaseURL string `mapstructure:"base_url"`
 	APIKey  string `mapstructure:"api_key"`
+
+	HTTPConfig `mapstructure:",squash"`
 }
 
 // AnthropicConfig configures Anthropic LLM.
 type AnthropicConfig struct {
 	Model  string `mapstructure:"model"`
 	APIKey string `mapstructure:"api_key"`
+
+	HTTPConfig `mapstructure:",squash"`
+}
+
+// SearchConfig configures search behavior.
+type SearchConfig struct {
+	// Expand rewrites queries with the LLM before retrieval.
+	Expand bool `mapstructure:"expand"`
+
+	// Oversample is how many candidates are fetched per requested result
+	// when results are filtered after retrieval, e.g. by exclusion terms.
+	// Higher values fill the limit more reliably at the cost of speed.
+	Oversample int `mapstructure:"oversample"`
+
+	// MinRelevance drops results below this calibrated relevance, from 0
+	// to 100. Unlike a raw score threshold it means the same for every
+	// embedding model.
+	MinRelevance float64 `mapstructure:"min_relevance"`
+
+	// AutoIndex controls what searching an unindexed directory does in the
+	// CLI and the MCP server: AutoIndexAlways, AutoIndexPrompt or
+	// AutoIndexNever.
+	AutoIndex string `mapstructure:"auto_index"`
+
+	// RefreshHits re-indexes the files of results that changed since
+	// indexing and runs the search again, in the CLI and the MCP server.
+	RefreshHits bool `mapstructure:"refresh_hits"`
+
+	// RelatedBoost is added to the score of results from files that import,
+	// or are imported by, the files of the top results. Zero disables it.
+	RelatedBoost float64 `mapstructure:"related_boost"`
+
+	// LanguageBoost raises the score of results in a language the query
+	// names, as in "retry logic in python", by this fraction. Zero disables
+	// it.
+	LanguageBoost float64 `mapstructure:"language_boost"`
+
+	// RecencyHalfLife, if positive, ranks results from recently modified
+	// files higher, with the boost halving each half-life since the file
+	// was modified. Zero disables it.
+	RecencyHalfLife time.Duration `mapstructure:"recency_half_life"`
+
+	// RecencyWeight is the boost of a file modified just now, as a fraction
+	// of its score.
+	RecencyWeight float64 `mapstructure:"recency_weight"`
+
+	// Boost multiplies the scores of results under a path prefix, given
+	// relative to the store root, e.g. {"docs/": 1.2, "legacy/": 0.5}. The
+	// longest matching prefix applies. Keys are lowercased by the config
+	// loader, so prefixes match case-insensitively.
+	Boost map[string]float64 `mapstructure:"boost"`
+
+	// FeedbackWeight scales the ranking prior learned from 'lgrep feedback'
+	// judgments, from 0 (disabled) to 1.
+	FeedbackWeight float64 `mapstructure:"feedback_weight"`
+
+	// IncludeGenerated keeps results from vendored and generated files,
+	// which are flagged at index time and left out of search by default.
+	IncludeGenerated bool `mapstructure:"include_generated"`
+
+	// KeywordFallback scans the files for the words of the query when the
+	// embedding provider cannot be used, instead of failing the search.
+	KeywordFallback bool `mapstructure:"keyword_fallback"`
+
+	// Log records each search and its results in the database, so 'lgrep
+	// more' and --page can show later pages without searching again and
+	// feedback can be tied to the query. The most recent 1000 searches of
+	// each store are kept.
+	Log bool `mapstructure:"log"`
+}
+
+// Values of search.auto_index.
+const (
+	// AutoIndexAlways indexes without asking and ignores the auto-index
+	// size limits.
+	AutoIndexAlways = "always"
+	// AutoIndexPrompt asks before indexing in a terminal and refuses
+	// directories over the size limits.
+	AutoIndexPrompt = "prompt"
+	// AutoIndexNever fails instead of indexing.
+	AutoIndexNever = "never"
+)
+
+// DistanceMetric is how a store compares embeddings when searching, a value
+// of embeddings.distance_metric.
+type DistanceMetric string
+
+const (
+	// MetricCosine ranks by the angle between embeddings.
+	MetricCosine DistanceMetric = "cosine"
+	// MetricL2 ranks by the Euclidean distance between embeddings.
+	MetricL2 DistanceMetric = "l2"
+	// MetricDot ranks by the dot product of embeddings. It is only valid
+	// for normalized embeddings, where it equals cosine similarity.
+	MetricDot DistanceMetric = "dot"
+)
+
+// ValidateDistanceMetric returns an error if metric is not an
+// embeddings.distance_metric value.
+func ValidateDistanceMetric(metric DistanceMetric) error {
+	switch metric {
+	case MetricCosine, MetricL2, MetricDot:
+		return nil
+	}
+	return fmt.Errorf("invalid embeddings.distance_metric %q (want %s, %s or %s)", metric, MetricCosine, MetricL2, MetricDot)
+}
+
+// ValidateBoost returns an error if a search.boost factor is not positive.
+func ValidateBoost(boost map[string]float64) error {
+	for prefix, factor := range boost {
+		if factor <= 0 {
+			return fmt.Errorf("invalid search.boost factor %g for %q: must be positive", factor, prefix)
+		}
+	}
+	return nil
+}
+
+// ValidatePartitions returns an error if a partitions entry has no root,
+// names two partitions alike, or maps a partition to a directory outside its
+// root.
+func ValidatePartitions(partitions []PartitionConfig) error {
+	for _, p := range partitions {
+		if p.Root == "" {
+			return fmt.Errorf("invalid partitions entry: root is required")
+		}
+		names := make(map[string]bool)
+		for _, d := range p.Directories {
+			if d.Name == "" || strings.Contains(d.Name, "/") {
+				return fmt.Errorf("invalid partition name %q for %s: must not be empty or contain '/'", d.Name, p.Root)
+			}
+			if names[d.Name] {
+				return fmt.Errorf("duplicate partition name %q for %s", d.Name, p.Root)
+			}
+			names[d.Name] = true
+			clean := filepath.Clean(filepath.FromSlash(d.Path))
+			if d.Path == "" || filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("invalid directory %q of partition %q: must be a directory under %s", d.Path, d.Name, p.Root)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateAutoIndex returns an error if mode is not a search.auto_index
+// value.
+func ValidateAutoIndex(mode string) error {
+	switch mode {
+	case AutoIndexAlways, AutoIndexPrompt, AutoIndexNever:
+		return nil
+	}
+	return fmt.Errorf("invalid auto-index mode %q (want %s, %s or %s)", mode, AutoIndexAlways, AutoIndexPrompt, AutoIndexNever)
+}
+
+// BudgetConfig limits spending on cloud providers.
+type BudgetConfig struct {
+	// MonthlyUSD blocks cloud embedding and LLM calls once the estimated
+	// spend for the current calendar month reaches it. Zero means no limit.
+	MonthlyUSD float64 `mapstructure:"monthly_usd"`
+}
+
+// MCPConfig configures the MCP server.
+type MCPConfig struct {
+	// AllowedRoots are the directories, with their subdirectories, that
+	// MCP tools may index and search. Empty allows only the directory the
+	// server was started in.
+	AllowedRoots []string `mapstructure:"allowed_roots"`
+
+	// AuditLog is a file that every tool call is appended to as a JSON
+	// line. Empty disables the audit log.
+	AuditLog string `mapstructure:"audit_log"`
 }
 
-// Global configuration instance
-var cfg *Config
+// NetworkConfig configures how providers are reached.
+type NetworkConfig struct {
+	// Proxy is the URL of the proxy for provider requests. Empty uses
+	// $HTTPS_PROXY and $HTTP_PROXY.
+	Proxy string `mapstructure:"proxy"`
+
+	// NoProxy lists hosts reached without the proxy, in the format of
+	// $NO_PROXY. Empty uses $NO_PROXY. Localhost is never proxied.
+	NoProxy string `mapstructure:"no_proxy"`
+}
+
+// UIConfig configures terminal output.
+type UIConfig struct {
+	// Theme is the syntax highlighting style for code snippets, or "auto"
+	// to pick one that suits the terminal background.
+	Theme string `mapstructure:"theme"`
+
+	// Background is "dark", "light" or "auto" to ask the terminal.
+	Background string `mapstructure:"background"`
+
+	// NoColor turns off colored output, as does the NO_COLOR variable.
+	NoColor bool `mapstructure:"no_color"`
+
+	// MaxSnippetLines limits the lines shown per result snippet; longer
+	// snippets show the lines with the most query terms. Zero shows whole
+	// snippets.
+	MaxSnippetLines int `mapstructure:"max_snippet_lines"`
+}
+
+// Global configuration instance. A Config is never modified once published;
+// reloading replaces it.
+var (
+	cfg *Config
+	mu  sync.Mutex
+)
+
+// set publishes c as the current configuration. A nil c resets it to the
+// defaults on the next Get.
+func set(c *Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+}
 
 // Get returns the current configuration.
 func Get() *Config {
+	mu.Lock()
+	defer mu.Unlock()
 	if cfg == nil {
 		cfg = DefaultConfig()
 	}
@@ -98,12 +524,24 @@ func DefaultConfig() *Config {
 		Embeddings: EmbeddingsConfig{
 			Provider: DefaultEmbeddingProvider,
 			Ollama: OllamaEmbedConfig{
-				URL:   DefaultOllamaURL,
-				Model: DefaultOllamaEmbedModel,
+				URL:        DefaultOllamaURL,
+				Model:      DefaultOllamaEmbedModel,
+				Parallel:   DefaultOllamaParallel,
+				HTTPConfig: HTTPConfig{Timeout: DefaultEmbedTimeout},
 			},
 			OpenAI: OpenAIEmbedConfig{
-				Model: DefaultOpenAIEmbedModel,
+				Model:      DefaultOpenAIEmbedModel,
+				HTTPConfig: HTTPConfig{Timeout: DefaultEmbedTimeout},
+			},
+			Voyage: VoyageEmbedConfig{
+				Model:      DefaultVoyageEmbedModel,
+				HTTPConfig: HTTPConfig{Timeout: DefaultEmbedTimeout},
 			},
+			Cohere: CohereEmbedConfig{
+				Model:      DefaultCohereEmbedModel,
+				HTTPConfig: HTTPConfig{Timeout: DefaultEmbedTimeout},
+			},
+			DistanceMetric: DefaultDistanceMetric,
 		},
 		Database: DatabaseConfig{
 			Path: DefaultDatabasePath(),
@@ -113,19 +551,53 @@ func DefaultConfig() *Config {
 			MaxFileCount: DefaultMaxFileCount,
 			ChunkSize:    DefaultChunkSize,
 			ChunkOverlap: DefaultChunkOverlap,
+
+			MaxChunksPerFile: DefaultMaxChunksPerFile,
+
+			AutoIndexMaxFiles: DefaultAutoIndexMaxFiles,
+			AutoIndexMaxBytes: DefaultAutoIndexMaxBytes,
+
+			ContextLines: DefaultContextLines,
+
+			MaxBatchTokens: DefaultMaxBatchTokens,
+
+			Relations: DefaultIndexRelations,
 		},
 		LLM: LLMConfig{
 			Provider: DefaultLLMProvider,
 			Ollama: OllamaLLMConfig{
-				URL:   DefaultOllamaURL,
-				Model: DefaultOllamaLLMModel,
+				URL:        DefaultOllamaURL,
+				Model:      DefaultOllamaLLMModel,
+				HTTPConfig: HTTPConfig{Timeout: DefaultLLMTimeout},
 			},
 			OpenAI: OpenAILLMConfig{
-				Model: DefaultOpenAILLMModel,
+				Model:      DefaultOpenAILLMModel,
+				HTTPConfig: HTTPConfig{Timeout: DefaultLLMTimeout},
 			},
 			Anthropic: AnthropicConfig{
-				Model: DefaultAnthropicModel,
+				Model:      DefaultAnthropicModel,
+				HTTPConfig: HTTPConfig{Timeout: DefaultLLMTimeout},
 			},
+			MaxContextTokens: DefaultMaxContextTokens,
+		},
+		Search: SearchConfig{
+			Expand:     DefaultSearchExpand,
+			Oversample: DefaultSearchOversample,
+			AutoIndex:  DefaultSearchAutoIndex,
+
+			RelatedBoost:  DefaultSearchRelatedBoost,
+			LanguageBoost: DefaultSearchLanguageBoost,
+			RecencyWeight: DefaultSearchRecencyWeight,
+
+			FeedbackWeight: DefaultSearchFeedbackWeight,
+
+			KeywordFallback: DefaultSearchKeywordFallback,
+			Log:             DefaultSearchLog,
+		},
+		UI: UIConfig{
+			Theme:           DefaultTheme,
+			Background:      DefaultBackground,
+			MaxSnippetLines: DefaultMaxSnippetLines,
 		},
 		Ignore: DefaultIgnorePatterns(),
 	}
@@ -156,6 +628,7 @@ func Load(configFile string) error {
 	viper.SetEnvPrefix("LGREP")
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
+	bindEnv()
 
 	// Read config file
 	if err := viper.ReadInConfig(); err != nil {
@@ -167,16 +640,41 @@ func Load(configFile string) error {
 		log.Debug("Loaded config from", "file", viper.ConfigFileUsed())
 	}
 
-	// Unmarshal into config struct
-	cfg = &Config{}
-	if err := viper.Unmarshal(cfg); err != nil {
-		return fmt.Errorf("error parsing config: %w", err)
+	loaded, err := unmarshal()
+	if err != nil {
+		return err
+	}
+
+	set(loaded)
+	return nil
+}
+
+// unmarshal builds a Config from viper's current settings.
+func unmarshal() (*Config, error) {
+	c := &Config{}
+	if err := viper.Unmarshal(c); err != nil {
+		return nil, fmt.Errorf("error parsing config: %w", err)
+	}
+
+	// Unmarshal splits map keys at dots, dropping prefixes such as
+	// ".github/", so the boosts are read on their own
+	if err := viper.UnmarshalKey("search.boost", &c.Search.Boost); err != nil {
+		return nil, fmt.Errorf("error parsing search.boost: %w", err)
+	}
+	if err := ValidateBoost(c.Search.Boost); err != nil {
+		return nil, err
+	}
+	if err := ValidateDistanceMetric(c.Embeddings.DistanceMetric); err != nil {
+		return nil, err
+	}
+	if err := ValidatePartitions(c.Partitions); err != nil {
+		return nil, err
 	}
 
 	// Load API keys from environment if not in config
-	loadAPIKeysFromEnv()
+	loadAPIKeysFromEnv(c)
 
-	return nil
+	return c, nil
 }
 
 // setDefaults sets default values in viper.
@@ -185,26 +683,117 @@ func setDefaults() {
 	viper.SetDefault("embeddings.provider", DefaultEmbeddingProvider)
 	viper.SetDefault("embeddings.ollama.url", DefaultOllamaURL)
 	viper.SetDefault("embeddings.ollama.model", DefaultOllamaEmbedModel)
+	viper.SetDefault("embeddings.ollama.auto_pull", false)
+	viper.SetDefault("embeddings.ollama.keep_alive", "")
+	viper.SetDefault("embeddings.ollama.num_ctx", 0)
+	viper.SetDefault("embeddings.ollama.parallel", DefaultOllamaParallel)
 	viper.SetDefault("embeddings.openai.model", DefaultOpenAIEmbedModel)
+	viper.SetDefault("embeddings.voyage.model", DefaultVoyageEmbedModel)
+	viper.SetDefault("embeddings.cohere.model", DefaultCohereEmbedModel)
+	viper.SetDefault("embeddings.truncate_dimensions", 0)
+	viper.SetDefault("embeddings.distance_metric", DefaultDistanceMetric)
+	viper.SetDefault("embeddings.fallbacks", []string{})
+	for _, provider := range []string{"ollama", "openai", "voyage", "cohere"} {
+		viper.SetDefault("embeddings."+provider+".timeout", DefaultEmbedTimeout)
+		viper.SetDefault("embeddings."+provider+".max_idle_conns", 0)
+		viper.SetDefault("embeddings."+provider+".ca_file", "")
+		viper.SetDefault("embeddings."+provider+".insecure_skip_verify", false)
+	}
 
 	// Database
 	viper.SetDefault("database.path", DefaultDatabasePath())
+	viper.SetDefault("database.namespace", "")
+	viper.SetDefault("database.check_at_startup", false)
+	viper.SetDefault("database.auto_repair", false)
 
 	// Indexing
 	viper.SetDefault("indexing.max_file_size", DefaultMaxFileSize)
 	viper.SetDefault("indexing.max_file_count", DefaultMaxFileCount)
+	viper.SetDefault("indexing.max_chunks_per_file", DefaultMaxChunksPerFile)
+	viper.SetDefault("indexing.min_file_size", 0)
+	viper.SetDefault("indexing.text_extensions", []string{})
+	viper.SetDefault("indexing.include_binary_names", false)
+	viper.SetDefault("indexing.languages.include", []string{})
+	viper.SetDefault("indexing.languages.exclude", []string{})
 	viper.SetDefault("indexing.chunk_size", DefaultChunkSize)
 	viper.SetDefault("indexing.chunk_overlap", DefaultChunkOverlap)
+	viper.SetDefault("indexing.auto_index_max_files", DefaultAutoIndexMaxFiles)
+	viper.SetDefault("indexing.auto_index_max_bytes", DefaultAutoIndexMaxBytes)
+	viper.SetDefault("indexing.context_lines", DefaultContextLines)
+	viper.SetDefault("indexing.store_content", false)
+	viper.SetDefault("indexing.max_batch_tokens", DefaultMaxBatchTokens)
+	viper.SetDefault("indexing.relations", DefaultIndexRelations)
 
 	// LLM
 	viper.SetDefault("llm.provider", DefaultLLMProvider)
 	viper.SetDefault("llm.ollama.url", DefaultOllamaURL)
 	viper.SetDefault("llm.ollama.model", DefaultOllamaLLMModel)
+	viper.SetDefault("llm.ollama.auto_pull", false)
 	viper.SetDefault("llm.openai.model", DefaultOpenAILLMModel)
 	viper.SetDefault("llm.anthropic.model", DefaultAnthropicModel)
+	viper.SetDefault("llm.max_context_tokens", DefaultMaxContextTokens)
+	viper.SetDefault("llm.follow_imports", false)
+	viper.SetDefault("llm.fallbacks", []string{})
+	for _, provider := range []string{"ollama", "openai", "anthropic"} {
+		viper.SetDefault("llm."+provider+".timeout", DefaultLLMTimeout)
+		viper.SetDefault("llm."+provider+".max_idle_conns", 0)
+		viper.SetDefault("llm."+provider+".ca_file", "")
+		viper.SetDefault("llm."+provider+".insecure_skip_verify", false)
+	}
+
+	// Search
+	viper.SetDefault("search.expand", DefaultSearchExpand)
+	viper.SetDefault("search.oversample", DefaultSearchOversample)
+	viper.SetDefault("search.auto_index", DefaultSearchAutoIndex)
+	viper.SetDefault("search.refresh_hits", false)
+	viper.SetDefault("search.min_relevance", 0)
+	viper.SetDefault("search.related_boost", DefaultSearchRelatedBoost)
+	viper.SetDefault("search.language_boost", DefaultSearchLanguageBoost)
+	viper.SetDefault("search.recency_half_life", time.Duration(0))
+	viper.SetDefault("search.recency_weight", DefaultSearchRecencyWeight)
+	viper.SetDefault("search.feedback_weight", DefaultSearchFeedbackWeight)
+	viper.SetDefault("search.include_generated", false)
+	viper.SetDefault("search.keyword_fallback", DefaultSearchKeywordFallback)
+	viper.SetDefault("search.log", DefaultSearchLog)
+
+	// Budget
+	viper.SetDefault("budget.monthly_usd", 0)
+
+	// MCP
+	viper.SetDefault("mcp.allowed_roots", []string{})
+	viper.SetDefault("mcp.audit_log", "")
+	viper.SetDefault("network.proxy", "")
+	viper.SetDefault("network.no_proxy", "")
+
+	// UI
+	viper.SetDefault("ui.theme", DefaultTheme)
+	viper.SetDefault("ui.background", DefaultBackground)
+	viper.SetDefault("ui.no_color", false)
+	viper.SetDefault("ui.max_snippet_lines", DefaultMaxSnippetLines)
 
 	// Ignore patterns
 	viper.SetDefault("ignore", DefaultIgnorePatterns())
+
+	// Monorepo partitions
+	viper.SetDefault("partitions", []PartitionConfig{})
+}
+
+// envAliases are shorter environment variables accepted for some keys, in
+// addition to the LGREP_<KEY> name. The full name wins if both are set.
+var envAliases = map[string][]string{
+	"database.path":         {"LGREP_DB_PATH"},
+	"database.namespace":    {"LGREP_NAMESPACE"},
+	"embeddings.ollama.url": {"LGREP_OLLAMA_URL"},
+	"llm.ollama.url":        {"LGREP_OLLAMA_URL"},
+}
+
+// bindEnv binds every key to its environment variables. AutomaticEnv alone
+// only applies to keys viper already knows of, so keys without a default,
+// like embeddings.openai.base_url, would otherwise be ignored when unmarshaling.
+func bindEnv() {
+	for _, opt := range Reference() {
+		_ = viper.BindEnv(append([]string{opt.Key, opt.Env}, envAliases[opt.Key]...)...)
+	}
 }
 
 // findRCFile searches for .lgreprc.yaml starting from current directory.
@@ -232,7 +821,7 @@ func findRCFile() string {
 }
 
 // loadAPIKeysFromEnv loads API keys from environment variables if not already set.
-func loadAPIKeysFromEnv() {
+func loadAPIKeysFromEnv(cfg *Config) {
 	// OpenAI API key
 	if cfg.Embeddings.OpenAI.APIKey == "" {
 		if key := os.Getenv("OPENAI_API_KEY"); key != "" {
@@ -245,6 +834,23 @@ func loadAPIKeysFromEnv() {
 		}
 	}
 
+	// Voyage AI API key
+	if cfg.Embeddings.Voyage.APIKey == "" {
+		if key := os.Getenv("VOYAGE_API_KEY"); key != "" {
+			cfg.Embeddings.Voyage.APIKey = key
+		}
+	}
+
+	// Cohere API key
+	if cfg.Embeddings.Cohere.APIKey == "" {
+		for _, name := range []string{"COHERE_API_KEY", "CO_API_KEY"} {
+			if key := os.Getenv(name); key != "" {
+				cfg.Embeddings.Cohere.APIKey = key
+				break
+			}
+		}
+	}
+
 	// Anthropic API key
 	if cfg.LLM.Anthropic.APIKey == "" {
 		if key := os.Getenv("ANTHROPIC_API_KEY"); key != "" {
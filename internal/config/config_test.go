@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -26,6 +27,7 @@ func TestDefaultConfig(t *testing.T) {
 	assert.Equal(t, DefaultOllamaLLMModel, cfg.LLM.Ollama.Model)
 	assert.Equal(t, DefaultOpenAILLMModel, cfg.LLM.OpenAI.Model)
 	assert.Equal(t, DefaultAnthropicModel, cfg.LLM.Anthropic.Model)
+	assert.Equal(t, DefaultMaxContextTokens, cfg.LLM.MaxContextTokens)
 
 	// Indexing defaults
 	assert.Equal(t, DefaultMaxFileSize, cfg.Indexing.MaxFileSize)
@@ -33,6 +35,9 @@ func TestDefaultConfig(t *testing.T) {
 	assert.Equal(t, DefaultChunkSize, cfg.Indexing.ChunkSize)
 	assert.Equal(t, DefaultChunkOverlap, cfg.Indexing.ChunkOverlap)
 
+	// Search defaults
+	assert.Equal(t, DefaultSearchExpand, cfg.Search.Expand)
+
 	// Ignore patterns
 	assert.NotEmpty(t, cfg.Ignore)
 	assert.Contains(t, cfg.Ignore, "node_modules/")
@@ -77,7 +82,7 @@ func TestDefaultPaths(t *testing.T) {
 func TestLoadWithConfigFile(t *testing.T) {
 	// Reset viper and global config
 	viper.Reset()
-	cfg = nil
+	set(nil)
 
 	// Create a temporary config file
 	tmpDir := t.TempDir()
@@ -89,6 +94,8 @@ embeddings:
   ollama:
     url: http://custom:11434
     model: custom-model
+    timeout: 15s
+    max_idle_conns: 4
   openai:
     model: text-embedding-3-large
     base_url: https://custom-api.example.com
@@ -101,8 +108,20 @@ llm:
   provider: anthropic
   anthropic:
     model: claude-3-opus-20240229
+search:
+  boost:
+    docs/: 1.2
+    Legacy/: 0.5
+    .github/: 0.8
 ignore:
   - "custom-ignore/"
+partitions:
+  - root: /src/monorepo
+    directories:
+      - name: Payments
+        path: services/Payments
+  - root: /src/other
+    name: shop
 `
 	err := os.WriteFile(configPath, []byte(configContent), 0644)
 	require.NoError(t, err)
@@ -117,6 +136,9 @@ ignore:
 	assert.Equal(t, "openai", loadedCfg.Embeddings.Provider)
 	assert.Equal(t, "http://custom:11434", loadedCfg.Embeddings.Ollama.URL)
 	assert.Equal(t, "custom-model", loadedCfg.Embeddings.Ollama.Model)
+	assert.Equal(t, 15*time.Second, loadedCfg.Embeddings.Ollama.Timeout)
+	assert.Equal(t, 4, loadedCfg.Embeddings.Ollama.MaxIdleConns)
+	assert.Equal(t, DefaultEmbedTimeout, loadedCfg.Embeddings.OpenAI.Timeout)
 	assert.Equal(t, "text-embedding-3-large", loadedCfg.Embeddings.OpenAI.Model)
 	assert.Equal(t, "https://custom-api.example.com", loadedCfg.Embeddings.OpenAI.BaseURL)
 	assert.Equal(t, "/custom/path/index.db", loadedCfg.Database.Path)
@@ -124,13 +146,60 @@ ignore:
 	assert.Equal(t, 1000, loadedCfg.Indexing.ChunkSize)
 	assert.Equal(t, "anthropic", loadedCfg.LLM.Provider)
 	assert.Equal(t, "claude-3-opus-20240229", loadedCfg.LLM.Anthropic.Model)
+	assert.Equal(t, DefaultLLMTimeout, loadedCfg.LLM.Anthropic.Timeout)
 	assert.Contains(t, loadedCfg.Ignore, "custom-ignore/")
+	assert.Equal(t, map[string]float64{"docs/": 1.2, "legacy/": 0.5, ".github/": 0.8}, loadedCfg.Search.Boost)
+	require.Len(t, loadedCfg.Partitions, 2)
+	assert.Equal(t, []PartitionDirectory{{Name: "Payments", Path: "services/Payments"}}, loadedCfg.Partitions[0].Directories)
+	assert.Equal(t, "monorepo", loadedCfg.Partitions[0].StorePrefix())
+	assert.Equal(t, "shop", loadedCfg.Partitions[1].StorePrefix())
+}
+
+func TestLoadInvalidBoost(t *testing.T) {
+	viper.Reset()
+	set(nil)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("search:\n  boost:\n    legacy/: 0\n"), 0644))
+
+	err := Load(configPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "legacy/")
+}
+
+func TestLoadInvalidDistanceMetric(t *testing.T) {
+	viper.Reset()
+	set(nil)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("embeddings:\n  distance_metric: manhattan\n"), 0644))
+
+	err := Load(configPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "manhattan")
+}
+
+func TestLoadInvalidPartitions(t *testing.T) {
+	viper.Reset()
+	set(nil)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("partitions:\n  - root: /src/monorepo\n    directories:\n      - name: web\n        path: ../web\n"), 0644))
+
+	err := Load(configPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "../web")
+
+	assert.ErrorContains(t, ValidatePartitions([]PartitionConfig{{Root: "/src/monorepo", Directories: []PartitionDirectory{
+		{Name: "web", Path: "apps/web"},
+		{Name: "web", Path: "web"},
+	}}}), "duplicate partition name")
 }
 
 func TestLoadWithEnvironmentVariables(t *testing.T) {
 	// Reset viper and global config
 	viper.Reset()
-	cfg = nil
+	set(nil)
 
 	// Set environment variables
 	t.Setenv("LGREP_EMBEDDINGS_PROVIDER", "openai")
@@ -155,7 +224,7 @@ func TestLoadWithEnvironmentVariables(t *testing.T) {
 func TestLoadMissingConfigFile(t *testing.T) {
 	// Reset viper and global config
 	viper.Reset()
-	cfg = nil
+	set(nil)
 
 	// Load with non-existent config file - should not error, just use defaults
 	err := Load("")
@@ -170,7 +239,7 @@ func TestLoadMissingConfigFile(t *testing.T) {
 
 func TestGet(t *testing.T) {
 	// Reset global config
-	cfg = nil
+	set(nil)
 
 	// First call should return default config
 	c1 := Get()
@@ -186,3 +255,53 @@ func TestGlobalConfigPath(t *testing.T) {
 	assert.Contains(t, path, "lgrep")
 	assert.Contains(t, path, "config.yaml")
 }
+
+func TestReference(t *testing.T) {
+	options := Reference()
+	require.NotEmpty(t, options)
+
+	byKey := make(map[string]Option)
+	for _, opt := range options {
+		assert.NotEmpty(t, opt.Description, "config key %s has no description", opt.Key)
+		byKey[opt.Key] = opt
+	}
+
+	chunkSize, ok := byKey["indexing.chunk_size"]
+	require.True(t, ok)
+	assert.Equal(t, "int", chunkSize.Type)
+	assert.Equal(t, "500", chunkSize.Default)
+	assert.Equal(t, "LGREP_INDEXING_CHUNK_SIZE", chunkSize.Env)
+
+	ignore, ok := byKey["ignore"]
+	require.True(t, ok)
+	assert.Equal(t, "[]string", ignore.Type)
+
+	// Nested structs are flattened rather than listed themselves
+	_, ok = byKey["embeddings.ollama"]
+	assert.False(t, ok)
+}
+
+func TestLoadNestedEnvironmentVariables(t *testing.T) {
+	viper.Reset()
+	set(nil)
+
+	// Keys with and without defaults
+	t.Setenv("LGREP_EMBEDDINGS_OLLAMA_URL", "http://embed-host:11434")
+	t.Setenv("LGREP_EMBEDDINGS_OPENAI_BASE_URL", "https://proxy.example.com/v1")
+	t.Setenv("LGREP_EMBEDDINGS_OPENAI_DIMENSIONS", "256")
+	t.Setenv("LGREP_INDEXING_CHUNK_SIZE", "900")
+
+	// Aliases
+	t.Setenv("LGREP_DB_PATH", "/tmp/alias.db")
+	t.Setenv("LGREP_OLLAMA_URL", "http://alias-host:11434")
+
+	require.NoError(t, Load(""))
+	loadedCfg := Get()
+
+	assert.Equal(t, "http://embed-host:11434", loadedCfg.Embeddings.Ollama.URL, "full name wins over alias")
+	assert.Equal(t, "https://proxy.example.com/v1", loadedCfg.Embeddings.OpenAI.BaseURL)
+	assert.Equal(t, 256, loadedCfg.Embeddings.OpenAI.Dimensions)
+	assert.Equal(t, 900, loadedCfg.Indexing.ChunkSize)
+	assert.Equal(t, "/tmp/alias.db", loadedCfg.Database.Path)
+	assert.Equal(t, "http://alias-host:11434", loadedCfg.LLM.Ollama.URL)
+}
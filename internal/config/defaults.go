@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // Default configuration values
@@ -12,6 +13,17 @@ const (
 	DefaultOllamaURL         = "http://localhost:11434"
 	DefaultOllamaEmbedModel  = "nomic-embed-text"
 	DefaultOpenAIEmbedModel  = "text-embedding-3-small"
+	DefaultVoyageEmbedModel  = "voyage-code-3"
+	DefaultCohereEmbedModel  = "embed-v4.0"
+	DefaultDistanceMetric    = MetricCosine
+
+	// DefaultOllamaParallel sends one embed request at a time
+	DefaultOllamaParallel = 1
+
+	// Provider request timeouts. LLM calls can be slow, particularly
+	// streamed answers from local models.
+	DefaultEmbedTimeout = 60 * time.Second
+	DefaultLLMTimeout   = 5 * time.Minute
 
 	// LLM defaults
 	DefaultLLMProvider    = "ollama"
@@ -19,12 +31,67 @@ const (
 	DefaultOpenAILLMModel = "gpt-4o-mini"
 	DefaultAnthropicModel = "claude-3-haiku-20240307"
 
+	// DefaultMaxContextTokens fits comfortably in the context window of
+	// small local models while leaving room for the answer.
+	DefaultMaxContextTokens = 6000
+
 	// Indexing defaults
 	DefaultMaxFileSize  = 1 << 20 // 1MB
 	DefaultMaxFileCount = 10000
 	DefaultChunkSize    = 500
 	DefaultChunkOverlap = 50
 
+	// Chunks kept from a single file, enough for any hand-written source
+	// file at the default chunk size
+	DefaultMaxChunksPerFile = 1000
+
+	// Auto-index guardrails: searching an unindexed directory larger than
+	// this asks the user to run 'lgrep index' explicitly.
+	DefaultAutoIndexMaxFiles = 2000
+	DefaultAutoIndexMaxBytes = 50 << 20 // 50MB
+
+	// Lines stored on each side of a chunk for offline result context
+	DefaultContextLines = 5
+
+	// Estimated tokens per embedding request, well within the per-request
+	// limits of the cloud providers
+	DefaultMaxBatchTokens = 8000
+
+	// Record file relations for 'lgrep related' and the related boost
+	DefaultIndexRelations = true
+
+	// Search defaults
+	DefaultSearchExpand     = false
+	DefaultSearchOversample = 3
+	DefaultSearchAutoIndex  = AutoIndexPrompt
+
+	// A small boost, enough to reorder close results but not to lift
+	// unrelated code over a clearly better match
+	DefaultSearchRelatedBoost = 0.02
+
+	// Larger than the related boost, since the query asks for the language
+	// outright
+	DefaultSearchLanguageBoost = 0.05
+
+	// Multiplies scores by up to 1.1, enough to prefer the current copy of
+	// similar code over an old one
+	DefaultSearchRecencyWeight = 0.1
+
+	// Judged files move by up to 10%, so feedback reorders close results
+	// without burying a clearly better match
+	DefaultSearchFeedbackWeight = 0.1
+
+	// Searches still return something before any provider is set up
+	DefaultSearchKeywordFallback = true
+
+	// Searches are logged for 'lgrep more', --page and feedback
+	DefaultSearchLog = true
+
+	// UI defaults
+	DefaultTheme           = "auto"
+	DefaultBackground      = "auto"
+	DefaultMaxSnippetLines = 15
+
 	// Database
 	DefaultDBFileName = "index.db"
 )
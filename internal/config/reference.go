@@ -0,0 +1,194 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Option describes a single configuration key.
+type Option struct {
+	Key         string // Dotted key as used in config files, e.g. "indexing.chunk_size"
+	Type        string // Value type, e.g. "int" or "[]string"
+	Default     string // Default value, formatted for display
+	Env         string // Environment variable that overrides the key
+	Description string
+}
+
+// descriptions documents each configuration key. Keys are derived from the
+// Config struct, so a new field only needs an entry here to be documented.
+var descriptions = map[string]string{
+	"embeddings.provider":                    "Embedding provider: ollama, openai, voyage or cohere",
+	"embeddings.ollama.url":                  "Ollama server URL, or unix:///path/to/socket for a unix socket",
+	"embeddings.ollama.model":                "Ollama embedding model",
+	"embeddings.ollama.auto_pull":            "Pull the Ollama model on first use if it is missing",
+	"embeddings.ollama.keep_alive":           "How long Ollama keeps the model loaded, e.g. 10m or -1 (server default if empty)",
+	"embeddings.ollama.num_ctx":              "Context window the Ollama model is loaded with (0 for the model default)",
+	"embeddings.ollama.parallel":             "Embed requests sent to Ollama at once; match OLLAMA_NUM_PARALLEL",
+	"embeddings.ollama.timeout":              "Time limit for each request to Ollama, e.g. 30s (0 for no limit)",
+	"embeddings.ollama.max_idle_conns":       "Idle connections kept open to Ollama for reuse (0 uses Go's default of 2)",
+	"embeddings.ollama.ca_file":              "PEM bundle of extra certificate authorities trusted for Ollama, e.g. a proxy's CA",
+	"embeddings.ollama.insecure_skip_verify": "Skip TLS certificate verification for Ollama (insecure; prefer ca_file)",
+	"embeddings.openai.model":                "OpenAI embedding model",
+	"embeddings.openai.base_url":             "OpenAI-compatible API base URL",
+	"embeddings.openai.api_key":              "OpenAI API key (defaults to $OPENAI_API_KEY)",
+	"embeddings.openai.dimensions":           "Requested embedding dimensions (0 uses the model default)",
+	"embeddings.openai.timeout":              "Time limit for each request to OpenAI, e.g. 30s (0 for no limit)",
+	"embeddings.openai.max_idle_conns":       "Idle connections kept open to OpenAI for reuse (0 uses Go's default of 2)",
+	"embeddings.openai.ca_file":              "PEM bundle of extra certificate authorities trusted for OpenAI, e.g. a proxy's CA",
+	"embeddings.openai.insecure_skip_verify": "Skip TLS certificate verification for OpenAI (insecure; prefer ca_file)",
+	"embeddings.voyage.model":                "Voyage AI embedding model",
+	"embeddings.voyage.base_url":             "Voyage AI API base URL",
+	"embeddings.voyage.api_key":              "Voyage AI API key (defaults to $VOYAGE_API_KEY)",
+	"embeddings.voyage.dimensions":           "Requested embedding dimensions (0 uses the model default)",
+	"embeddings.voyage.timeout":              "Time limit for each request to Voyage AI, e.g. 30s (0 for no limit)",
+	"embeddings.voyage.max_idle_conns":       "Idle connections kept open to Voyage AI for reuse (0 uses Go's default of 2)",
+	"embeddings.voyage.ca_file":              "PEM bundle of extra certificate authorities trusted for Voyage AI, e.g. a proxy's CA",
+	"embeddings.voyage.insecure_skip_verify": "Skip TLS certificate verification for Voyage AI (insecure; prefer ca_file)",
+	"embeddings.cohere.model":                "Cohere embedding model",
+	"embeddings.cohere.base_url":             "Cohere API base URL",
+	"embeddings.cohere.api_key":              "Cohere API key (defaults to $COHERE_API_KEY)",
+	"embeddings.cohere.dimensions":           "Requested embedding dimensions (0 uses the model default)",
+	"embeddings.cohere.timeout":              "Time limit for each request to Cohere, e.g. 30s (0 for no limit)",
+	"embeddings.cohere.max_idle_conns":       "Idle connections kept open to Cohere for reuse (0 uses Go's default of 2)",
+	"embeddings.cohere.ca_file":              "PEM bundle of extra certificate authorities trusted for Cohere, e.g. a proxy's CA",
+	"embeddings.cohere.insecure_skip_verify": "Skip TLS certificate verification for Cohere (insecure; prefer ca_file)",
+	"embeddings.truncate_dimensions":         "Shorten Matryoshka embeddings to this many dimensions (0 keeps full vectors)",
+	"embeddings.distance_metric":             "How new stores compare embeddings: cosine, l2 or dot (dot needs normalized embeddings)",
+	"embeddings.fallbacks":                   "Providers tried in order when the embedding provider is unreachable; they must serve the same model",
+	"database.path":                          "Path to the SQLite index database",
+	"database.namespace":                     "Namespace whose stores, history and usage are used, isolating teams that share a database (empty is the default namespace)",
+	"database.check_at_startup":              "Run the integrity check of 'lgrep db check' when watch or mcp starts",
+	"database.auto_repair":                   "Check the database when watch or mcp starts and repair the problems found, instead of only reporting them",
+	"indexing.max_file_size":                 "Skip files larger than this many bytes",
+	"indexing.max_file_count":                "Stop indexing after this many files",
+	"indexing.max_chunks_per_file":           "Index only the first and last chunks of files with more than this many (0 = no limit)",
+	"indexing.min_file_size":                 "Skip files smaller than this, in bytes (0 = no minimum)",
+	"indexing.text_extensions":               "Extensions read as text even if they look binary, e.g. [.svg]",
+	"indexing.include_binary_names":          "Index the paths of binary files such as images, so searches find assets by name",
+	"indexing.languages.include":             "Index only files of these languages, e.g. [go, sql]; see lgrep help languages",
+	"indexing.languages.exclude":             "Skip files of these languages, e.g. [markdown, json]",
+	"indexing.chunk_size":                    "Target chunk size in characters (see 'lgrep help chunking')",
+	"indexing.chunk_overlap":                 "Characters shared between consecutive text chunks",
+	"indexing.auto_index_max_files":          "Largest directory, in files, that search indexes without asking (0 disables the check)",
+	"indexing.auto_index_max_bytes":          "Largest directory, in bytes, that search indexes without asking (0 disables the check)",
+	"indexing.context_lines":                 "Lines stored before and after each chunk for --context when the file is not on disk",
+	"indexing.store_content":                 "Store the text of each file so --context works without it (except files larger than one batch)",
+	"indexing.max_batch_tokens":              "Estimated tokens per embedding request (0 sends 50 chunks per request)",
+	"indexing.relations":                     "Record which files import which, for 'lgrep related' and search.related_boost",
+	"llm.provider":                           "LLM provider for Q&A: ollama, openai or anthropic",
+	"llm.ollama.url":                         "Ollama server URL, or unix:///path/to/socket for a unix socket",
+	"llm.ollama.model":                       "Ollama chat model",
+	"llm.ollama.auto_pull":                   "Pull the Ollama model on first use if it is missing",
+	"llm.ollama.timeout":                     "Time limit for each request to Ollama, e.g. 30s (0 for no limit)",
+	"llm.ollama.max_idle_conns":              "Idle connections kept open to Ollama for reuse (0 uses Go's default of 2)",
+	"llm.ollama.ca_file":                     "PEM bundle of extra certificate authorities trusted for Ollama, e.g. a proxy's CA",
+	"llm.ollama.insecure_skip_verify":        "Skip TLS certificate verification for Ollama (insecure; prefer ca_file)",
+	"llm.openai.model":                       "OpenAI chat model",
+	"llm.openai.base_url":                    "OpenAI-compatible API base URL",
+	"llm.openai.api_key":                     "OpenAI API key (defaults to $OPENAI_API_KEY)",
+	"llm.openai.timeout":                     "Time limit for each request to OpenAI, e.g. 30s (0 for no limit)",
+	"llm.openai.max_idle_conns":              "Idle connections kept open to OpenAI for reuse (0 uses Go's default of 2)",
+	"llm.openai.ca_file":                     "PEM bundle of extra certificate authorities trusted for OpenAI, e.g. a proxy's CA",
+	"llm.openai.insecure_skip_verify":        "Skip TLS certificate verification for OpenAI (insecure; prefer ca_file)",
+	"llm.anthropic.model":                    "Anthropic model",
+	"llm.anthropic.api_key":                  "Anthropic API key (defaults to $ANTHROPIC_API_KEY)",
+	"llm.anthropic.timeout":                  "Time limit for each request to Anthropic, e.g. 30s (0 for no limit)",
+	"llm.anthropic.max_idle_conns":           "Idle connections kept open to Anthropic for reuse (0 uses Go's default of 2)",
+	"llm.anthropic.ca_file":                  "PEM bundle of extra certificate authorities trusted for Anthropic, e.g. a proxy's CA",
+	"llm.anthropic.insecure_skip_verify":     "Skip TLS certificate verification for Anthropic (insecure; prefer ca_file)",
+	"llm.max_context_tokens":                 "Limit on the estimated code context sent to the LLM (0 means no limit)",
+	"llm.follow_imports":                     "Add the definitions the top results use from files they import to the Q&A context (same as --follow-imports)",
+	"llm.fallbacks":                          "LLM providers tried in order when the LLM provider is unreachable or lacks the model",
+	"search.expand":                          "Rewrite queries with the LLM before retrieval",
+	"search.auto_index":                      "What searching an unindexed directory does: always (no prompt or size limits), prompt or never",
+	"search.refresh_hits":                    "Re-index result files changed since indexing and search again (same as --refresh-hits)",
+	"search.min_relevance":                   "Drop results below this calibrated relevance, 0-100 (same as --min-relevance)",
+	"search.related_boost":                   "Score added to results from files related by imports to the top results' files (0 disables)",
+	"search.include_generated":               "Keep results from vendored and generated files, which are left out by default (same as --include-generated)",
+	"search.log":                             "Log searches and their results for 'lgrep more', --page and feedback, keeping the last 1000 per store",
+	"search.keyword_fallback":                "Scan files for the words of the query when the embedding provider is unavailable, instead of failing",
+	"search.language_boost":                  "Fraction the score of results in a language the query names is raised by, e.g. \"in python\" (0 disables)",
+	"search.recency_half_life":               "Rank recently modified files higher, halving the boost each half-life, e.g. 720h (0 disables)",
+	"search.boost":                           "Score multipliers by path prefix, e.g. {\"docs/\": 1.2, \"legacy/\": 0.5}; the longest matching prefix applies",
+	"search.feedback_weight":                 "How much 'lgrep feedback' judgments move results of the judged files and their directories, 0-1 (0 disables)",
+	"search.recency_weight":                  "Boost of a file modified just now, as a fraction of its score",
+	"search.oversample":                      "Candidates fetched per result when results are filtered after retrieval; higher is more complete but slower",
+	"budget.monthly_usd":                     "Block cloud calls once this month's estimated spend reaches this amount (0 means no limit)",
+	"mcp.allowed_roots":                      "Directories MCP tools may index and search (empty allows only the directory the server was started in)",
+	"mcp.audit_log":                          "File that MCP tool calls are appended to as JSON lines (empty disables the audit log)",
+	"network.proxy":                          "Proxy URL for provider requests (empty uses $HTTPS_PROXY and $HTTP_PROXY)",
+	"network.no_proxy":                       "Hosts reached without the proxy, like $NO_PROXY (empty uses $NO_PROXY; localhost is never proxied)",
+	"ui.theme":                               "Syntax highlighting style for snippets (any chroma style, e.g. dracula or github), or auto",
+	"ui.background":                          "Terminal background: dark, light or auto to detect it",
+	"ui.no_color":                            "Turn off colored output (also turned off by $NO_COLOR)",
+	"ui.max_snippet_lines":                   "Lines shown per result snippet; longer snippets show the lines most relevant to the query (0 shows whole snippets)",
+	"ignore":                                 "Gitignore-style patterns excluded from indexing",
+	"partitions":                             "Monorepos split into one store per top-level directory: entries with root, an optional store name prefix and an optional list of {name, path} directories",
+}
+
+// Reference lists every configuration key with its type, default value and
+// description, in the order the fields appear in Config.
+func Reference() []Option {
+	var options []Option
+	collectOptions(reflect.ValueOf(*DefaultConfig()), "", &options)
+	return options
+}
+
+// collectOptions appends an Option for each leaf field of v.
+func collectOptions(v reflect.Value, prefix string, options *[]Option) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("mapstructure")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+
+		value := v.Field(i)
+		if tag == ",squash" {
+			collectOptions(value, prefix, options)
+			continue
+		}
+		if value.Kind() == reflect.Struct {
+			collectOptions(value, key, options)
+			continue
+		}
+
+		*options = append(*options, Option{
+			Key:         key,
+			Type:        value.Type().String(),
+			Default:     formatDefault(value),
+			Env:         "LGREP_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_")),
+			Description: descriptions[key],
+		})
+	}
+}
+
+// formatDefault formats a default value for display.
+func formatDefault(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		if v.String() == "" {
+			return `""`
+		}
+		return v.String()
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return "[]"
+		}
+		return fmt.Sprintf("[%d entries]", v.Len())
+	case reflect.Map:
+		if v.Len() == 0 {
+			return "{}"
+		}
+		return fmt.Sprintf("{%d entries}", v.Len())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// restartPrefixes are the keys that cannot change while lgrep runs. The
+// embedding service and the database are set up once at startup, and vectors
+// from a different model or dimension cannot be compared with stored ones.
+var restartPrefixes = []string{"embeddings.", "database."}
+
+// reloadDelay gives an editor time to finish writing the config file before
+// it is read again, so a half-written file is not applied.
+const reloadDelay = 100 * time.Millisecond
+
+// ChangedKeys returns the keys whose values differ between a and b.
+func ChangedKeys(a, b *Config) []string {
+	var keys []string
+	diffValues(reflect.ValueOf(*a), reflect.ValueOf(*b), "", &keys)
+	return keys
+}
+
+// diffValues appends the keys of the leaf fields that differ between a and b.
+func diffValues(a, b reflect.Value, prefix string, keys *[]string) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("mapstructure")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+
+		if a.Field(i).Kind() == reflect.Struct {
+			diffValues(a.Field(i), b.Field(i), key, keys)
+			continue
+		}
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			*keys = append(*keys, key)
+		}
+	}
+}
+
+// RestartRequired returns the keys of changed that only take effect after a
+// restart.
+func RestartRequired(changed []string) []string {
+	var keys []string
+	for _, key := range changed {
+		for _, prefix := range restartPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	return keys
+}
+
+// Watch reloads the configuration whenever the loaded config file changes
+// and passes the new configuration to onChange. A change to a setting that
+// needs a restart is reported as an error and the whole change is ignored,
+// so the running process never mixes old and new embedding settings. Watch
+// does nothing if no config file was loaded. The returned function stops
+// watching and waits for a reload in progress.
+func Watch(onChange func(*Config)) (stop func()) {
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		log.Debug("No config file to watch")
+		return func() {}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Warn("Failed to watch the config file, edits need a restart", "file", file, "error", err)
+		return func() {}
+	}
+	// Editors often save by replacing the file, so its directory is watched
+	file = filepath.Clean(file)
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		log.Warn("Failed to watch the config file, edits need a restart", "file", file, "error", err)
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(e.Name) == file && e.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					reload(file, onChange)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Debug("Config file watcher error", "error", err)
+			}
+		}
+	}()
+	return func() {
+		watcher.Close()
+		<-done
+	}
+}
+
+// reload reads the config file again and applies it as described for Watch.
+func reload(file string, onChange func(*Config)) {
+	time.Sleep(reloadDelay)
+	if err := viper.ReadInConfig(); err != nil {
+		log.Error("Failed to reload config, keeping the current settings", "file", file, "error", err)
+		return
+	}
+
+	next, err := unmarshal()
+	if err != nil {
+		log.Error("Failed to reload config, keeping the current settings", "file", file, "error", err)
+		return
+	}
+
+	changed := ChangedKeys(Get(), next)
+	if len(changed) == 0 {
+		return
+	}
+	if keys := RestartRequired(changed); len(keys) > 0 {
+		log.Error("Config change needs a restart and was not applied; changing the embedding model also needs 'lgrep index --force'",
+			"file", file, "keys", keys)
+		return
+	}
+
+	set(next)
+	log.Info("Reloaded config", "file", file, "changed", changed)
+	onChange(next)
+}
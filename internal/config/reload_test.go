@@ -0,0 +1,63 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedKeys(t *testing.T) {
+	a := DefaultConfig()
+	b := DefaultConfig()
+	assert.Empty(t, ChangedKeys(a, b))
+
+	b.Indexing.ChunkSize = 800
+	b.Embeddings.Ollama.Model = "other-model"
+	b.Ignore = append(b.Ignore, "generated/")
+
+	changed := ChangedKeys(a, b)
+	assert.ElementsMatch(t, []string{"indexing.chunk_size", "embeddings.ollama.model", "ignore"}, changed)
+	assert.Equal(t, []string{"embeddings.ollama.model"}, RestartRequired(changed))
+}
+
+func TestWatchReloads(t *testing.T) {
+	viper.Reset()
+	set(nil)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("indexing:\n  chunk_size: 1000\n"), 0644))
+	require.NoError(t, Load(configPath))
+
+	reloaded := make(chan *Config, 1)
+	t.Cleanup(Watch(func(c *Config) { reloaded <- c }))
+
+	// A change that needs a restart is not applied
+	require.NoError(t, os.WriteFile(configPath, []byte("indexing:\n  chunk_size: 1000\ndatabase:\n  path: /elsewhere.db\n"), 0644))
+	select {
+	case <-reloaded:
+		t.Fatal("restart-only change was applied")
+	case <-time.After(500 * time.Millisecond):
+	}
+	assert.NotEqual(t, "/elsewhere.db", Get().Database.Path)
+
+	// Other changes are. The file may be seen part-way through the write
+	// first, so wait for the final contents.
+	require.NoError(t, os.WriteFile(configPath, []byte("indexing:\n  chunk_size: 800\n"), 0644))
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case c := <-reloaded:
+			if c.Indexing.ChunkSize == 800 {
+				assert.Same(t, c, Get())
+				return
+			}
+		case <-timeout:
+			t.Fatal("config was not reloaded")
+		}
+	}
+}
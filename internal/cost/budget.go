@@ -0,0 +1,81 @@
+package cost
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// ErrBudgetExceeded is returned when a cloud call would exceed the monthly
+// budget set by budget.monthly_usd.
+var ErrBudgetExceeded = errors.New("monthly budget exceeded")
+
+// Budget tracks month-to-date spend against the configured monthly limit.
+type Budget struct {
+	limit float64
+
+	mu    sync.Mutex
+	spent float64
+}
+
+// LoadBudget reads this month's recorded spend from the store.
+func LoadBudget(st store.Store, cfg *config.Config) (*Budget, error) {
+	b := &Budget{limit: cfg.Budget.MonthlyUSD}
+	if b.limit <= 0 {
+		return b, nil
+	}
+
+	summary, err := st.GetUsageSummary("", MonthStart(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	b.spent = summary.Cost
+	return b, nil
+}
+
+// MonthStart returns midnight on the first day of t's month.
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// Check returns ErrBudgetExceeded if the monthly limit has been reached.
+func (b *Budget) Check() error {
+	if b == nil || b.limit <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.spent >= b.limit {
+		return fmt.Errorf("%w: %s of %s spent this month (raise budget.monthly_usd or use a local provider)",
+			ErrBudgetExceeded, FormatUSD(b.spent), FormatUSD(b.limit))
+	}
+	return nil
+}
+
+// Add records spending against the budget.
+func (b *Budget) Add(amount float64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent += amount
+}
+
+// Spent returns the month-to-date spend.
+func (b *Budget) Spent() float64 {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
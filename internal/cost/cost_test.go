@@ -0,0 +1,251 @@
+package cost
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/llm"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// stubEmbedder implements embeddings.Service for testing.
+type stubEmbedder struct {
+	provider embeddings.Provider
+	model    string
+	calls    int
+}
+
+func (s *stubEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	s.calls++
+	return []float32{1, 0}, nil
+}
+
+func (s *stubEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return s.Embed(ctx, text)
+}
+
+func (s *stubEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	s.calls++
+	out := make([][]float32, len(texts))
+	for i := range texts {
+		out[i] = []float32{1, 0}
+	}
+	return out, nil
+}
+
+func (s *stubEmbedder) Dimensions() int               { return 2 }
+func (s *stubEmbedder) Provider() embeddings.Provider { return s.provider }
+func (s *stubEmbedder) ModelName() string             { return s.model }
+
+// stubLLM implements llm.Service for testing.
+type stubLLM struct {
+	response string
+	usage    llm.Usage // Reported usage, if any
+}
+
+func (s *stubLLM) Complete(ctx context.Context, messages []llm.Message, opts llm.CompletionOptions) (string, error) {
+	if opts.Usage != nil {
+		*opts.Usage = s.usage
+	}
+	return s.response, nil
+}
+
+func (s *stubLLM) CompleteStream(ctx context.Context, messages []llm.Message, opts llm.CompletionOptions) (<-chan string, <-chan error) {
+	contentCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	contentCh <- s.response
+	if opts.Usage != nil {
+		*opts.Usage = s.usage
+	}
+	close(contentCh)
+	close(errCh)
+	return contentCh, errCh
+}
+
+func (s *stubLLM) Provider() llm.Provider { return llm.ProviderOpenAI }
+func (s *stubLLM) ModelName() string      { return "gpt-4o" }
+
+func setupStore(t *testing.T) store.Store {
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func TestLookup(t *testing.T) {
+	price, ok := Lookup("openai", "text-embedding-3-small")
+	require.True(t, ok)
+	assert.InDelta(t, 0.02, price.Input, 1e-9)
+
+	// Dated names match by prefix
+	price, ok = Lookup("anthropic", "claude-3-5-sonnet-20241022")
+	require.True(t, ok)
+	assert.InDelta(t, 15.0, price.Output, 1e-9)
+
+	// Local models are free
+	price, ok = Lookup("ollama", "nomic-embed-text")
+	require.True(t, ok)
+	assert.Zero(t, price.Cost(1_000_000, 1_000_000))
+
+	_, ok = Lookup("openai", "unknown-model")
+	assert.False(t, ok)
+
+	assert.InDelta(t, 2.5+5.0, Price{Input: 2.5, Output: 10}.Cost(1_000_000, 500_000), 1e-9)
+}
+
+func TestEstimateIndexTokens(t *testing.T) {
+	cfg := &config.Config{Indexing: config.IndexingConfig{ChunkSize: 500, ChunkOverlap: 50}}
+	assert.Equal(t, 1100, EstimateIndexTokens(4000, cfg))
+}
+
+func TestBudget(t *testing.T) {
+	st := setupStore(t)
+	cfg := &config.Config{Budget: config.BudgetConfig{MonthlyUSD: 1}}
+
+	// Usage from last month does not count
+	require.NoError(t, st.AddUsage(&store.UsageRecord{StoreName: "a", Operation: OpIndex, Provider: "openai", Model: "m", Cost: 5, CreatedAt: MonthStart(time.Now()).Add(-time.Hour)}))
+	require.NoError(t, st.AddUsage(&store.UsageRecord{StoreName: "a", Operation: OpIndex, Provider: "openai", Model: "m", Cost: 0.5}))
+
+	budget, err := LoadBudget(st, cfg)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, budget.Spent(), 1e-9)
+	assert.NoError(t, budget.Check())
+
+	budget.Add(0.5)
+	assert.True(t, errors.Is(budget.Check(), ErrBudgetExceeded))
+
+	// No limit configured
+	unlimited, err := LoadBudget(st, &config.Config{})
+	require.NoError(t, err)
+	unlimited.Add(100)
+	assert.NoError(t, unlimited.Check())
+}
+
+func TestEmbedderMetering(t *testing.T) {
+	st := setupStore(t)
+	budget := &Budget{limit: 1}
+
+	svc := &stubEmbedder{provider: embeddings.ProviderOpenAI, model: "text-embedding-3-small"}
+	emb := NewEmbedder(svc, budget)
+
+	_, err := emb.EmbedBatch(context.Background(), []string{"abcdefgh", "abcd"})
+	require.NoError(t, err)
+	_, err = emb.EmbedQuery(context.Background(), "abcd")
+	require.NoError(t, err)
+
+	emb.Flush(st, "proj", OpIndex)
+	summary, err := st.GetUsageSummary("proj", time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), summary.InputTokens)
+
+	// Flushing again records nothing new
+	emb.Flush(st, "proj", OpIndex)
+	summary, err = st.GetUsageSummary("proj", time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), summary.InputTokens)
+
+	// Once the budget is spent, cloud calls are refused
+	budget.Add(1)
+	_, err = emb.Embed(context.Background(), "text")
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	assert.Equal(t, 2, svc.calls)
+
+	// Local providers are never blocked or recorded
+	local := NewEmbedder(&stubEmbedder{provider: embeddings.ProviderOllama, model: "nomic-embed-text"}, budget)
+	_, err = local.Embed(context.Background(), "text")
+	require.NoError(t, err)
+	local.Flush(st, "local", OpIndex)
+	summary, err = st.GetUsageSummary("local", time.Time{})
+	require.NoError(t, err)
+	assert.Zero(t, summary.InputTokens)
+}
+
+// stubBatchEmbedder adds a batch API to stubEmbedder.
+type stubBatchEmbedder struct {
+	stubEmbedder
+}
+
+func (s *stubBatchEmbedder) SubmitBatch(ctx context.Context, texts []string) (string, error) {
+	return "batch", nil
+}
+
+func (s *stubBatchEmbedder) GetBatch(ctx context.Context, id string) (*embeddings.BatchJob, error) {
+	return &embeddings.BatchJob{ID: id, Status: embeddings.BatchCompleted}, nil
+}
+
+func (s *stubBatchEmbedder) BatchResults(ctx context.Context, id string) ([][]float32, error) {
+	return nil, nil
+}
+
+func (s *stubBatchEmbedder) MaxBatchSize() int { return 10 }
+
+func TestEmbedderBatchMetering(t *testing.T) {
+	st := setupStore(t)
+	budget := &Budget{limit: 1}
+
+	svc := &stubBatchEmbedder{stubEmbedder{provider: embeddings.ProviderOpenAI, model: "text-embedding-3-small"}}
+	emb := NewEmbedder(svc, budget)
+
+	b, ok := embeddings.AsBatchService(emb)
+	require.True(t, ok)
+	_, err := b.SubmitBatch(context.Background(), []string{"abcdefgh", "abcd"})
+	require.NoError(t, err)
+	_, err = emb.EmbedQuery(context.Background(), "abcd")
+	require.NoError(t, err)
+
+	// Batch tokens are charged at the discounted price
+	emb.Flush(st, "proj", OpIndex)
+	summary, err := st.GetUsageSummary("proj", time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), summary.InputTokens)
+	price, _ := Lookup("openai", "text-embedding-3-small")
+	assert.InDelta(t, price.Cost(1, 0)+price.Cost(3, 0)*BatchDiscount, summary.Cost, 1e-12)
+
+	// Submissions are refused once the budget is spent
+	budget.Add(1)
+	_, err = b.SubmitBatch(context.Background(), []string{"text"})
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+
+	// Services without a batch API have none when metered
+	_, ok = embeddings.AsBatchService(NewEmbedder(&stubEmbedder{provider: embeddings.ProviderOpenAI}, budget))
+	assert.False(t, ok)
+}
+
+func TestLLMMetering(t *testing.T) {
+	st := setupStore(t)
+	svc := NewLLM(&stubLLM{response: "12345678"}, &Budget{limit: 10})
+
+	messages := []llm.Message{{Role: "user", Content: "abcd"}}
+	contentCh, errCh := svc.CompleteStream(context.Background(), messages, llm.CompletionOptions{})
+	for range contentCh {
+	}
+	require.NoError(t, <-errCh)
+
+	// Without reported usage the tokens are estimated
+	assert.Equal(t, llm.Usage{PromptTokens: 1, CompletionTokens: 2, Estimated: true}, svc.Usage())
+	assert.Greater(t, svc.Cost(), 0.0)
+
+	svc.Flush(st, "proj", OpAnswer)
+	summary, err := st.GetUsageSummary("proj", time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), summary.InputTokens)
+	assert.Equal(t, int64(2), summary.OutputTokens)
+	assert.Greater(t, summary.Cost, 0.0)
+	assert.Zero(t, svc.Usage())
+
+	// Reported usage is used as is, and passed on to the caller
+	svc = NewLLM(&stubLLM{response: "12345678", usage: llm.Usage{PromptTokens: 120, CompletionTokens: 30}}, &Budget{limit: 10})
+	var usage llm.Usage
+	_, err = svc.Complete(context.Background(), messages, llm.CompletionOptions{Usage: &usage})
+	require.NoError(t, err)
+	assert.Equal(t, llm.Usage{PromptTokens: 120, CompletionTokens: 30}, usage)
+	assert.Equal(t, llm.Usage{PromptTokens: 120, CompletionTokens: 30}, svc.Usage())
+}
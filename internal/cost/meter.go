@@ -0,0 +1,371 @@
+package cost
+
+import (
+	"context"
+	"sync"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/llm"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// Operations recorded in the usage log.
+const (
+	OpIndex  = "index"
+	OpSearch = "search"
+	OpAnswer = "answer"
+)
+
+// Embedder wraps an embedding service, counting the tokens sent to it and
+// refusing cloud calls once the monthly budget is spent.
+type Embedder struct {
+	embeddings.Service
+
+	budget *Budget
+
+	mu          sync.Mutex
+	tokens      int
+	batchTokens int // Tokens submitted through the batch API
+}
+
+// NewEmbedder meters svc against budget.
+func NewEmbedder(svc embeddings.Service, budget *Budget) *Embedder {
+	provider := string(svc.Provider())
+	if _, ok := Lookup(provider, svc.ModelName()); !ok {
+		log.Debug("No price known for embedding model; usage will be recorded without cost",
+			"provider", provider, "model", svc.ModelName())
+	}
+
+	return &Embedder{
+		Service: svc,
+		budget:  budget,
+	}
+}
+
+// Embed generates an embedding for document text.
+func (e *Embedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	if err := e.check(); err != nil {
+		return nil, err
+	}
+	embedding, err := e.Service.Embed(ctx, text)
+	if err == nil {
+		e.count(llm.EstimateTokens(text))
+	}
+	return embedding, err
+}
+
+// EmbedQuery generates an embedding for query text.
+func (e *Embedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	if err := e.check(); err != nil {
+		return nil, err
+	}
+	embedding, err := e.Service.EmbedQuery(ctx, text)
+	if err == nil {
+		e.count(llm.EstimateTokens(text))
+	}
+	return embedding, err
+}
+
+// EmbedBatch generates embeddings for multiple document texts.
+func (e *Embedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := e.check(); err != nil {
+		return nil, err
+	}
+	embeddings, err := e.Service.EmbedBatch(ctx, texts)
+	if err == nil {
+		tokens := 0
+		for _, text := range texts {
+			tokens += llm.EstimateTokens(text)
+		}
+		e.count(tokens)
+	}
+	return embeddings, err
+}
+
+// Batch returns the wrapped service's batch API, metered at the batch price.
+func (e *Embedder) Batch() (embeddings.BatchService, bool) {
+	b, ok := embeddings.AsBatchService(e.Service)
+	if !ok {
+		return nil, false
+	}
+	return &meteredBatch{BatchService: b, meter: e}, true
+}
+
+// Normalized reports whether the wrapped service returns normalized
+// embeddings.
+func (e *Embedder) Normalized() bool {
+	return embeddings.Normalized(e.Service)
+}
+
+// Primary returns the configured service behind the wrapped one.
+func (e *Embedder) Primary() embeddings.Service {
+	return embeddings.Primary(e.Service)
+}
+
+// meteredBatch counts the texts submitted through a batch API.
+type meteredBatch struct {
+	embeddings.BatchService
+	meter *Embedder
+}
+
+// SubmitBatch queues texts for embedding. They are counted when submitted
+// rather than when the job completes, so the budget cannot be overcommitted
+// by jobs that are still running.
+func (b *meteredBatch) SubmitBatch(ctx context.Context, texts []string) (string, error) {
+	if err := b.meter.check(); err != nil {
+		return "", err
+	}
+	id, err := b.BatchService.SubmitBatch(ctx, texts)
+	if err == nil {
+		tokens := 0
+		for _, text := range texts {
+			tokens += llm.EstimateTokens(text)
+		}
+		b.meter.countBatch(tokens)
+	}
+	return id, err
+}
+
+// price returns the price of the provider and model in use. A fallback
+// chain can change provider between calls, so it is looked up each time.
+func (e *Embedder) price() Price {
+	price, _ := Lookup(string(e.Provider()), e.ModelName())
+	return price
+}
+
+// local reports whether the provider in use runs locally.
+func (e *Embedder) local() bool {
+	return IsLocal(string(e.Provider()))
+}
+
+// check enforces the budget for cloud providers.
+func (e *Embedder) check() error {
+	if e.local() {
+		return nil
+	}
+	return e.budget.Check()
+}
+
+// count adds tokens sent to the provider.
+func (e *Embedder) count(tokens int) {
+	e.mu.Lock()
+	e.tokens += tokens
+	e.mu.Unlock()
+
+	if !e.local() {
+		e.budget.Add(e.price().Cost(tokens, 0))
+	}
+}
+
+// countBatch adds tokens submitted through the batch API.
+func (e *Embedder) countBatch(tokens int) {
+	e.mu.Lock()
+	e.batchTokens += tokens
+	e.mu.Unlock()
+
+	if !e.local() {
+		e.budget.Add(e.price().Cost(tokens, 0) * BatchDiscount)
+	}
+}
+
+// Flush records the tokens counted since the last flush against storeName
+// and resets the counter. Usage of local providers is not recorded.
+func (e *Embedder) Flush(st store.Store, storeName, operation string) {
+	e.mu.Lock()
+	tokens, batchTokens := e.tokens, e.batchTokens
+	e.tokens, e.batchTokens = 0, 0
+	e.mu.Unlock()
+
+	if tokens+batchTokens == 0 || e.local() {
+		return
+	}
+
+	record := &store.UsageRecord{
+		StoreName:   storeName,
+		Operation:   operation,
+		Provider:    string(e.Provider()),
+		Model:       e.ModelName(),
+		InputTokens: tokens + batchTokens,
+		Cost:        e.price().Cost(tokens, 0) + e.price().Cost(batchTokens, 0)*BatchDiscount,
+	}
+	if err := st.AddUsage(record); err != nil {
+		log.Warn("Failed to record embedding usage", "error", err)
+	}
+}
+
+// LLM wraps an LLM service, counting the tokens sent and received and
+// refusing cloud calls once the monthly budget is spent. Counts are those
+// the provider reports, or estimated from the text if it reports none.
+type LLM struct {
+	llm.Service
+
+	budget *Budget
+
+	mu           sync.Mutex
+	inputTokens  int
+	outputTokens int
+	estimated    bool
+}
+
+// NewLLM meters svc against budget.
+func NewLLM(svc llm.Service, budget *Budget) *LLM {
+	provider := string(svc.Provider())
+	if _, ok := Lookup(provider, svc.ModelName()); !ok {
+		log.Debug("No price known for LLM model; usage will be recorded without cost",
+			"provider", provider, "model", svc.ModelName())
+	}
+
+	return &LLM{
+		Service: svc,
+		budget:  budget,
+	}
+}
+
+// Complete generates a completion for the given messages.
+func (l *LLM) Complete(ctx context.Context, messages []llm.Message, opts llm.CompletionOptions) (string, error) {
+	if err := l.Check(); err != nil {
+		return "", err
+	}
+	reported := withUsage(&opts)
+	response, err := l.Service.Complete(ctx, messages, opts)
+	if err == nil {
+		l.count(*reported, messages, llm.EstimateTokens(response))
+	}
+	return response, err
+}
+
+// CompleteStream generates a streaming completion. Output tokens are counted
+// as the stream is consumed.
+func (l *LLM) CompleteStream(ctx context.Context, messages []llm.Message, opts llm.CompletionOptions) (<-chan string, <-chan error) {
+	if err := l.Check(); err != nil {
+		contentCh := make(chan string)
+		errCh := make(chan error, 1)
+		close(contentCh)
+		errCh <- err
+		close(errCh)
+		return contentCh, errCh
+	}
+
+	reported := withUsage(&opts)
+	upstream, errCh := l.Service.CompleteStream(ctx, messages, opts)
+	contentCh := make(chan string, cap(upstream))
+	go func() {
+		defer close(contentCh)
+		output := 0
+		for content := range upstream {
+			output += llm.EstimateTokens(content)
+			contentCh <- content
+		}
+		l.count(*reported, messages, output)
+	}()
+
+	return contentCh, errCh
+}
+
+// Check returns ErrBudgetExceeded if the service is a cloud provider and
+// the monthly budget has been spent.
+func (l *LLM) Check() error {
+	if l.local() {
+		return nil
+	}
+	return l.budget.Check()
+}
+
+// price returns the price of the provider and model in use. A fallback
+// chain can change provider between calls, so it is looked up each time.
+func (l *LLM) price() Price {
+	price, _ := Lookup(string(l.Provider()), l.ModelName())
+	return price
+}
+
+// local reports whether the provider in use runs locally.
+func (l *LLM) local() bool {
+	return IsLocal(string(l.Provider()))
+}
+
+// withUsage makes sure opts collects the usage the provider reports,
+// returning where it is collected.
+func withUsage(opts *llm.CompletionOptions) *llm.Usage {
+	if opts.Usage == nil {
+		opts.Usage = &llm.Usage{}
+	}
+	return opts.Usage
+}
+
+// count adds the tokens sent to and received from the provider for one
+// completion: those it reported, or else estimates from the messages and
+// the estimated output tokens.
+func (l *LLM) count(reported llm.Usage, messages []llm.Message, estimatedOutput int) {
+	input, output := reported.PromptTokens, reported.CompletionTokens
+	estimated := !reported.Reported()
+	if estimated {
+		input, output = messageTokens(messages), estimatedOutput
+	}
+	log.Debug("LLM token usage", "provider", l.Provider(), "model", l.ModelName(),
+		"prompt", input, "completion", output, "estimated", estimated)
+
+	l.mu.Lock()
+	l.inputTokens += input
+	l.outputTokens += output
+	l.estimated = l.estimated || estimated
+	l.mu.Unlock()
+
+	if !l.local() {
+		l.budget.Add(l.price().Cost(input, output))
+	}
+}
+
+// Usage returns the tokens counted since the last flush.
+func (l *LLM) Usage() llm.Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return llm.Usage{PromptTokens: l.inputTokens, CompletionTokens: l.outputTokens, Estimated: l.estimated}
+}
+
+// Cost returns the estimated cost in US dollars of the tokens counted since
+// the last flush, or zero for a local provider or an unknown price.
+func (l *LLM) Cost() float64 {
+	if l.local() {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.price().Cost(l.inputTokens, l.outputTokens)
+}
+
+// Flush records the tokens counted since the last flush against storeName
+// and resets the counters. Usage of local providers is not recorded.
+func (l *LLM) Flush(st store.Store, storeName, operation string) {
+	l.mu.Lock()
+	input, output := l.inputTokens, l.outputTokens
+	l.inputTokens, l.outputTokens, l.estimated = 0, 0, false
+	l.mu.Unlock()
+
+	if (input == 0 && output == 0) || l.local() {
+		return
+	}
+
+	record := &store.UsageRecord{
+		StoreName:    storeName,
+		Operation:    operation,
+		Provider:     string(l.Provider()),
+		Model:        l.ModelName(),
+		InputTokens:  input,
+		OutputTokens: output,
+		Cost:         l.price().Cost(input, output),
+	}
+	if err := st.AddUsage(record); err != nil {
+		log.Warn("Failed to record LLM usage", "error", err)
+	}
+}
+
+// messageTokens estimates the tokens in a list of messages.
+func messageTokens(messages []llm.Message) int {
+	tokens := 0
+	for _, m := range messages {
+		tokens += llm.EstimateTokens(m.Content)
+	}
+	return tokens
+}
@@ -0,0 +1,106 @@
+// Package cost estimates and tracks spending on cloud embedding and LLM
+// providers.
+package cost
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nickcecere/lgrep/internal/config"
+)
+
+// Price is the list price of a model in US dollars per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Cost returns the cost in US dollars of the given token counts.
+func (p Price) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1e6
+}
+
+// BatchDiscount is the fraction of the list price charged for requests made
+// through a provider's asynchronous batch API.
+const BatchDiscount = 0.5
+
+// prices lists known cloud model prices. Dated model names such as
+// claude-3-5-sonnet-20241022 match by prefix.
+var prices = map[string]Price{
+	// OpenAI embeddings
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+	"text-embedding-ada-002": {Input: 0.10},
+
+	// Voyage AI
+	"voyage-code-3":  {Input: 0.18},
+	"voyage-3-large": {Input: 0.18},
+	"voyage-3.5":     {Input: 0.06},
+	"voyage-3":       {Input: 0.06},
+	"voyage-3-lite":  {Input: 0.02},
+
+	// Cohere
+	"embed-v4.0":              {Input: 0.12},
+	"embed-english-v3.0":      {Input: 0.10},
+	"embed-multilingual-v3.0": {Input: 0.10},
+
+	// OpenAI chat
+	"gpt-4o":       {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":  {Input: 0.15, Output: 0.60},
+	"gpt-4.1":      {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini": {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano": {Input: 0.10, Output: 0.40},
+
+	// Anthropic
+	"claude-3-5-sonnet": {Input: 3.00, Output: 15.00},
+	"claude-3-7-sonnet": {Input: 3.00, Output: 15.00},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4.00},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"claude-3-opus":     {Input: 15.00, Output: 75.00},
+}
+
+// IsLocal reports whether provider runs on this machine and costs nothing.
+func IsLocal(provider string) bool {
+	return provider == "ollama"
+}
+
+// Lookup returns the price of a model. Local providers are free; unknown
+// cloud models report ok=false.
+func Lookup(provider, model string) (price Price, ok bool) {
+	if IsLocal(provider) {
+		return Price{}, true
+	}
+	if p, ok := prices[model]; ok {
+		return p, true
+	}
+
+	// Fall back to the longest matching prefix for dated model names
+	best := ""
+	for name := range prices {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return prices[best], true
+}
+
+// EstimateIndexTokens estimates the tokens embedded when indexing totalBytes
+// of source, accounting for chunk overlap.
+func EstimateIndexTokens(totalBytes int64, cfg *config.Config) int {
+	tokens := float64(totalBytes) / 4
+	if cfg.Indexing.ChunkSize > 0 && cfg.Indexing.ChunkOverlap > 0 {
+		tokens *= 1 + float64(cfg.Indexing.ChunkOverlap)/float64(cfg.Indexing.ChunkSize)
+	}
+	return int(tokens)
+}
+
+// FormatUSD formats a dollar amount, keeping precision for small values.
+func FormatUSD(amount float64) string {
+	if amount > 0 && amount < 0.01 {
+		return fmt.Sprintf("$%.4f", amount)
+	}
+	return fmt.Sprintf("$%.2f", amount)
+}
@@ -0,0 +1,97 @@
+package embeddings
+
+import (
+	"context"
+)
+
+// Batch job states. Jobs move from BatchPending to BatchCompleted, or end
+// in BatchFailed if the provider rejected or abandoned them.
+const (
+	BatchPending   = "pending"
+	BatchCompleted = "completed"
+	BatchFailed    = "failed"
+)
+
+// BatchJob describes the progress of an asynchronous embedding job.
+type BatchJob struct {
+	ID     string
+	Status string // BatchPending, BatchCompleted or BatchFailed
+
+	// Detail is the provider's own status, e.g. "in_progress", or the
+	// reason the job failed.
+	Detail string
+
+	// Requests completed, failed and in total. Each request embeds several
+	// texts.
+	Completed, Failed, Total int
+}
+
+// Done reports whether the job has finished, successfully or not.
+func (j *BatchJob) Done() bool {
+	return j.Status != BatchPending
+}
+
+// BatchService is implemented by providers with an asynchronous batch API,
+// which embeds large numbers of texts more cheaply than EmbedBatch but may
+// take hours to complete.
+type BatchService interface {
+	// SubmitBatch queues texts for embedding and returns the job ID. At most
+	// MaxBatchSize texts can be submitted in one job.
+	SubmitBatch(ctx context.Context, texts []string) (string, error)
+
+	// GetBatch returns the current state of a job.
+	GetBatch(ctx context.Context, id string) (*BatchJob, error)
+
+	// BatchResults returns the embeddings of a finished job in the order
+	// the texts were submitted. Texts the provider failed to embed have a
+	// nil embedding, or are missing if they were the last ones.
+	BatchResults(ctx context.Context, id string) ([][]float32, error)
+
+	// MaxBatchSize returns the most texts a single job can hold.
+	MaxBatchSize() int
+}
+
+// batcher is implemented by services that wrap another service and can
+// expose its batch API.
+type batcher interface {
+	Batch() (BatchService, bool)
+}
+
+// AsBatchService returns the batch API of svc, looking through wrappers
+// such as dimension truncation, and reports whether it has one.
+func AsBatchService(svc Service) (BatchService, bool) {
+	if b, ok := svc.(batcher); ok {
+		return b.Batch()
+	}
+	b, ok := svc.(BatchService)
+	return b, ok
+}
+
+// Batch returns the wrapped service's batch API, truncating its results.
+func (s *truncatedService) Batch() (BatchService, bool) {
+	b, ok := AsBatchService(s.Service)
+	if !ok {
+		return nil, false
+	}
+	return &truncatedBatch{BatchService: b, dimensions: s.dimensions}, true
+}
+
+// truncatedBatch truncates the embeddings returned by a batch API.
+type truncatedBatch struct {
+	BatchService
+	dimensions int
+}
+
+// BatchResults returns the job's embeddings, truncated.
+func (b *truncatedBatch) BatchResults(ctx context.Context, id string) ([][]float32, error) {
+	embeddings, err := b.BatchService.BatchResults(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for i, embedding := range embeddings {
+		if embedding != nil {
+			embeddings[i] = Truncate(embedding, b.dimensions)
+		}
+	}
+	return embeddings, nil
+}
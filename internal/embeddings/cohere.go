@@ -0,0 +1,186 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/httpclient"
+)
+
+func init() {
+	Register(string(ProviderCohere), func(cfg *config.Config, model string) (Service, error) {
+		if model == "" {
+			model = cfg.Embeddings.Cohere.Model
+		}
+		svc, err := NewCohereService(
+			cfg.Embeddings.Cohere.APIKey,
+			model,
+			cfg.Embeddings.Cohere.BaseURL,
+			cfg.Embeddings.Cohere.Dimensions,
+		)
+		if err != nil {
+			return nil, err
+		}
+		client, err := httpclient.New(cfg.Network, cfg.Embeddings.Cohere.HTTPConfig)
+		if err != nil {
+			return nil, err
+		}
+		svc.SetHTTPClient(client)
+		return svc, nil
+	})
+}
+
+const (
+	cohereAPIURL = "https://api.cohere.com/v2"
+
+	// cohereMaxBatch is the maximum number of texts per embed request.
+	cohereMaxBatch = 96
+)
+
+// CohereService implements the embedding service using the Cohere API.
+type CohereService struct {
+	apiKey     string
+	baseURL    string
+	model      string
+	dimensions int
+	reduced    bool // Request dimensions instead of the model default
+	client     *http.Client
+}
+
+// cohereEmbedRequest is the request body for the Cohere v2 embed API.
+type cohereEmbedRequest struct {
+	Model           string   `json:"model"`
+	Texts           []string `json:"texts"`
+	InputType       string   `json:"input_type"`
+	EmbeddingTypes  []string `json:"embedding_types"`
+	OutputDimension int      `json:"output_dimension,omitempty"`
+	Truncate        string   `json:"truncate,omitempty"`
+}
+
+// cohereEmbedResponse is the response from the Cohere v2 embed API.
+type cohereEmbedResponse struct {
+	Embeddings struct {
+		Float [][]float32 `json:"float"`
+	} `json:"embeddings"`
+}
+
+// NewCohereService creates a new Cohere embedding service.
+func NewCohereService(apiKey, model, baseURL string, dimensions int) (*CohereService, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("Cohere API key is required")
+	}
+	if baseURL == "" {
+		baseURL = cohereAPIURL
+	}
+
+	// Use the model's default dimensions unless a size is requested
+	reduced := dimensions > 0
+	if dimensions == 0 {
+		dimensions = GetModelDimensions(model)
+		if dimensions == 0 {
+			dimensions = 1024
+			log.Debug("Unknown model dimensions, defaulting", "model", model, "dimensions", dimensions)
+		}
+	}
+
+	return &CohereService{
+		apiKey:     apiKey,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		model:      model,
+		dimensions: dimensions,
+		reduced:    reduced,
+		client: &http.Client{
+			Timeout: config.DefaultEmbedTimeout,
+		},
+	}, nil
+}
+
+// SetHTTPClient sets the client used for requests, e.g. one built by
+// httpclient.New from the provider's configuration.
+func (s *CohereService) SetHTTPClient(client *http.Client) {
+	s.client = client
+}
+
+// Embed generates an embedding for document text.
+func (s *CohereService) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := s.embedTexts(ctx, []string{text}, "search_document")
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedQuery generates an embedding for query text.
+func (s *CohereService) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := s.embedTexts(ctx, []string{text}, "search_query")
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for multiple document texts.
+func (s *CohereService) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	results := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += cohereMaxBatch {
+		end := min(start+cohereMaxBatch, len(texts))
+		batch, err := s.embedTexts(ctx, texts[start:end], "search_document")
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, batch...)
+	}
+
+	return results, nil
+}
+
+// Dimensions returns the embedding dimensions.
+func (s *CohereService) Dimensions() int {
+	return s.dimensions
+}
+
+// Provider returns the provider name.
+func (s *CohereService) Provider() Provider {
+	return ProviderCohere
+}
+
+// ModelName returns the model name.
+func (s *CohereService) ModelName() string {
+	return s.model
+}
+
+// embedTexts performs the actual embedding request.
+func (s *CohereService) embedTexts(ctx context.Context, texts []string, inputType string) ([][]float32, error) {
+	log.Debug("Requesting embeddings from Cohere", "model", s.model, "count", len(texts), "input_type", inputType)
+
+	reqBody := cohereEmbedRequest{
+		Model:          s.model,
+		Texts:          texts,
+		InputType:      inputType,
+		EmbeddingTypes: []string{"float"},
+		Truncate:       "END",
+	}
+	if s.reduced {
+		reqBody.OutputDimension = s.dimensions
+	}
+
+	var result cohereEmbedResponse
+	if err := postJSON(ctx, s.client, s.baseURL+"/embed", s.apiKey, reqBody, &result); err != nil {
+		return nil, fmt.Errorf("cohere embeddings failed: %w", err)
+	}
+
+	if len(result.Embeddings.Float) != len(texts) {
+		return nil, fmt.Errorf("cohere returned %d embeddings for %d texts", len(result.Embeddings.Float), len(texts))
+	}
+
+	return result.Embeddings.Float, nil
+}
@@ -3,7 +3,7 @@ package embeddings
 
 import (
 	"context"
-	"fmt"
+	"strings"
 
 	"github.com/nickcecere/lgrep/internal/config"
 )
@@ -14,6 +14,8 @@ type Provider string
 const (
 	ProviderOllama Provider = "ollama"
 	ProviderOpenAI Provider = "openai"
+	ProviderVoyage Provider = "voyage"
+	ProviderCohere Provider = "cohere"
 )
 
 // Service defines the interface for embedding services.
@@ -49,6 +51,22 @@ var modelDimensions = map[string]int{
 	"text-embedding-3-small": 1536,
 	"text-embedding-3-large": 3072,
 	"text-embedding-ada-002": 1536,
+
+	// Voyage AI models
+	"voyage-code-3":   1024,
+	"voyage-code-2":   1536,
+	"voyage-3.5":      1024,
+	"voyage-3.5-lite": 1024,
+	"voyage-3-large":  1024,
+	"voyage-3":        1024,
+	"voyage-3-lite":   512,
+
+	// Cohere models
+	"embed-v4.0":                    1536,
+	"embed-english-v3.0":            1024,
+	"embed-multilingual-v3.0":       1024,
+	"embed-english-light-v3.0":      384,
+	"embed-multilingual-light-v3.0": 384,
 }
 
 // GetModelDimensions returns the known dimensions for a model, or 0 if unknown.
@@ -56,42 +74,70 @@ func GetModelDimensions(model string) int {
 	return modelDimensions[model]
 }
 
-// NewService creates an embedding service based on the configuration.
+// normalizer is implemented by services that wrap others and know whether
+// their embeddings are normalized.
+type normalizer interface {
+	Normalized() bool
+}
+
+// Normalized reports whether svc returns embeddings of unit length, which
+// the dot product distance metric relies on. Ollama normalizes every
+// embedding it returns, as do Voyage AI and OpenAI's own models; models
+// behind other OpenAI-compatible endpoints and Cohere's are not assumed to.
+// Truncated embeddings are always normalized again, and a service with
+// fallbacks only returns normalized embeddings if every provider does.
+func Normalized(svc Service) bool {
+	if n, ok := svc.(normalizer); ok {
+		return n.Normalized()
+	}
+	switch svc.Provider() {
+	case ProviderOllama, ProviderVoyage:
+		return true
+	case ProviderOpenAI:
+		return strings.HasPrefix(svc.ModelName(), "text-embedding-")
+	}
+	return false
+}
+
+// primaryer is implemented by services that wrap others and know which one
+// is configured.
+type primaryer interface {
+	Primary() Service
+}
+
+// Primary returns the configured service behind svc, looking through
+// wrappers such as fallbacks, whose provider may change from one request to
+// the next. Stores record its provider and model as the ones their
+// embeddings come from.
+func Primary(svc Service) Service {
+	if p, ok := svc.(primaryer); ok {
+		return p.Primary()
+	}
+	return svc
+}
+
+// NewService creates an embedding service based on the configuration,
+// falling back to the providers in embeddings.fallbacks when the configured
+// one is unavailable.
 func NewService(cfg *config.Config) (Service, error) {
-	switch cfg.Embeddings.Provider {
-	case "ollama":
-		return NewOllamaService(
-			cfg.Embeddings.Ollama.URL,
-			cfg.Embeddings.Ollama.Model,
-		)
-	case "openai":
-		return NewOpenAIService(
-			cfg.Embeddings.OpenAI.APIKey,
-			cfg.Embeddings.OpenAI.Model,
-			cfg.Embeddings.OpenAI.BaseURL,
-			cfg.Embeddings.OpenAI.Dimensions,
-		)
-	default:
-		return nil, fmt.Errorf("unsupported embedding provider: %s", cfg.Embeddings.Provider)
+	svc, err := NewServiceForStore(cfg.Embeddings.Provider, "", cfg)
+	if err != nil {
+		return nil, err
 	}
+	return withFallbacks(svc, cfg.Embeddings.Fallbacks, cfg), nil
 }
 
 // NewServiceForStore creates an embedding service matching a store's configuration.
+// An empty model uses the model configured for the provider.
 func NewServiceForStore(provider, model string, cfg *config.Config) (Service, error) {
-	switch provider {
-	case "ollama":
-		return NewOllamaService(
-			cfg.Embeddings.Ollama.URL,
-			model,
-		)
-	case "openai":
-		return NewOpenAIService(
-			cfg.Embeddings.OpenAI.APIKey,
-			model,
-			cfg.Embeddings.OpenAI.BaseURL,
-			cfg.Embeddings.OpenAI.Dimensions,
-		)
-	default:
-		return nil, fmt.Errorf("unsupported embedding provider: %s", provider)
+	c, err := lookup(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := c(cfg, model)
+	if err != nil {
+		return nil, err
 	}
+	return withTruncation(svc, cfg.Embeddings.TruncateDimensions), nil
 }
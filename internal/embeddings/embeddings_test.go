@@ -3,9 +3,16 @@ package embeddings
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/nickcecere/lgrep/internal/config"
 	"github.com/stretchr/testify/assert"
@@ -150,7 +157,9 @@ func mockOllamaServer(t *testing.T, dims int) *httptest.Server {
 
 		var req ollamaEmbedRequest
 		err := json.NewDecoder(r.Body).Decode(&req)
-		require.NoError(t, err)
+		if !assert.NoError(t, err) {
+			return
+		}
 
 		// Generate fake embeddings
 		embeddings := make([][]float32, len(req.Input))
@@ -211,6 +220,32 @@ func TestOllamaEmbed(t *testing.T) {
 	})
 }
 
+// TestOllamaUnixSocket tests reaching Ollama over a unix socket.
+func TestOllamaUnixSocket(t *testing.T) {
+	tcp := mockOllamaServer(t, 768)
+	defer tcp.Close()
+
+	dir, err := os.MkdirTemp("", "lgrep")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "ollama.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	server := &httptest.Server{Listener: listener, Config: &http.Server{Handler: tcp.Config.Handler}}
+	server.Start()
+	defer server.Close()
+
+	t.Run("socket URL", func(t *testing.T) {
+		svc, err := NewOllamaService("unix://"+socket, "nomic-embed-text")
+		require.NoError(t, err)
+		svc.SetHTTPClient(&http.Client{Timeout: time.Minute})
+
+		embedding, err := svc.Embed(context.Background(), "test document")
+		require.NoError(t, err)
+		assert.Len(t, embedding, 768)
+	})
+}
+
 // TestOllamaErrorHandling tests error cases.
 func TestOllamaErrorHandling(t *testing.T) {
 	t.Run("server error", func(t *testing.T) {
@@ -250,6 +285,80 @@ func TestOllamaErrorHandling(t *testing.T) {
 	})
 }
 
+// TestOllamaTuning tests that keep_alive and num_ctx are sent and that
+// batches are split across at most the configured number of concurrent
+// requests, keeping their order.
+func TestOllamaTuning(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight, requests := 0, 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaEmbedRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			return
+		}
+		assert.JSONEq(t, "-1", string(req.KeepAlive))
+		if !assert.NotNil(t, req.Options) {
+			return
+		}
+		assert.Equal(t, 4096, req.Options.NumCtx)
+
+		mu.Lock()
+		requests++
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		embeddings := make([][]float32, len(req.Input))
+		for i, text := range req.Input {
+			n, err := strconv.Atoi(text)
+			if !assert.NoError(t, err) {
+				return
+			}
+			embeddings[i] = []float32{float32(n)}
+		}
+		json.NewEncoder(w).Encode(ollamaEmbedResponse{Embeddings: embeddings})
+	}))
+	defer server.Close()
+
+	svc, err := NewOllamaService(server.URL, "custom-model")
+	require.NoError(t, err)
+	svc.SetKeepAlive("-1")
+	svc.SetNumCtx(4096)
+	svc.SetParallel(3)
+
+	texts := make([]string, 10)
+	for i := range texts {
+		texts[i] = strconv.Itoa(i)
+	}
+	embeddings, err := svc.EmbedBatch(context.Background(), texts)
+	require.NoError(t, err)
+	require.Len(t, embeddings, len(texts))
+	for i, emb := range embeddings {
+		assert.Equal(t, []float32{float32(i)}, emb)
+	}
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, 3, maxInFlight)
+
+	// Concurrent callers share the limit
+	requests, maxInFlight = 0, 0
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.EmbedQuery(context.Background(), "1")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 5, requests)
+	assert.LessOrEqual(t, maxInFlight, 3)
+}
+
 // TestOllamaDimensionUpdate tests that dimensions are updated from response.
 func TestOllamaDimensionUpdate(t *testing.T) {
 	// Server returns 512 dimensions instead of expected 768
@@ -317,6 +426,102 @@ func TestNewService(t *testing.T) {
 	})
 }
 
+// TestNewServiceFallbacks tests falling back to another provider for the
+// same model.
+func TestNewServiceFallbacks(t *testing.T) {
+	// An address nothing listens on
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	openaiCalls := 0
+	openaiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		openaiCalls++
+		assert.Equal(t, "/embeddings", r.URL.Path)
+		var req struct {
+			Model string `json:"model"`
+		}
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			return
+		}
+		assert.Equal(t, "nomic-embed-text", req.Model)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"object": "list",
+			"model":  req.Model,
+			"data": []map[string]any{
+				{"object": "embedding", "index": 0, "embedding": make([]float32, 768)},
+			},
+		})
+	}))
+	defer openaiServer.Close()
+
+	newConfig := func(ollamaURL string, openaiDims int) *config.Config {
+		return &config.Config{
+			Embeddings: config.EmbeddingsConfig{
+				Provider: "ollama",
+				Ollama: config.OllamaEmbedConfig{
+					URL:   ollamaURL,
+					Model: "nomic-embed-text",
+				},
+				OpenAI: config.OpenAIEmbedConfig{
+					APIKey:     "sk-test",
+					BaseURL:    openaiServer.URL,
+					Model:      "text-embedding-3-small",
+					Dimensions: openaiDims,
+				},
+				Fallbacks: []string{"openai"},
+			},
+		}
+	}
+
+	t.Run("falls back when the provider is unreachable", func(t *testing.T) {
+		openaiCalls = 0
+		svc, err := NewService(newConfig(down.URL, 0))
+		require.NoError(t, err)
+		assert.Equal(t, ProviderOllama, svc.Provider())
+		assert.Equal(t, 768, svc.Dimensions())
+
+		embedding, err := svc.EmbedQuery(context.Background(), "test")
+		require.NoError(t, err)
+		assert.Len(t, embedding, 768)
+		assert.Equal(t, ProviderOpenAI, svc.Provider())
+		assert.Equal(t, "nomic-embed-text", svc.ModelName())
+
+		// Stores still record the configured provider, and the embeddings
+		// are only normalized if every provider's are
+		assert.Equal(t, ProviderOllama, Primary(svc).Provider())
+		assert.True(t, Normalized(Primary(svc)))
+		assert.False(t, Normalized(svc))
+
+		// The unreachable provider is skipped while its breaker is open
+		_, err = svc.Embed(context.Background(), "test")
+		require.NoError(t, err)
+		assert.Equal(t, 2, openaiCalls)
+	})
+
+	t.Run("does not fall back on other errors", func(t *testing.T) {
+		openaiCalls = 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		svc, err := NewService(newConfig(server.URL, 0))
+		require.NoError(t, err)
+
+		_, err = svc.EmbedQuery(context.Background(), "test")
+		assert.ErrorContains(t, err, "status 400")
+		assert.Zero(t, openaiCalls)
+	})
+
+	t.Run("skips fallbacks with other dimensions", func(t *testing.T) {
+		svc, err := NewService(newConfig(down.URL, 256))
+		require.NoError(t, err)
+		assert.IsType(t, &OllamaService{}, svc)
+	})
+}
+
 // TestNewServiceForStore tests the store-based factory function.
 func TestNewServiceForStore(t *testing.T) {
 	cfg := &config.Config{
@@ -380,3 +585,382 @@ func TestContextCancellation(t *testing.T) {
 	_, err := svc.Embed(ctx, "test")
 	assert.Error(t, err)
 }
+
+// TestOllamaAutoPull tests pulling a missing model before the first request.
+func TestOllamaAutoPull(t *testing.T) {
+	pulled := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/show":
+			if !pulled {
+				http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{}`))
+		case "/api/pull":
+			pulled = true
+			w.Write([]byte("{\"status\":\"pulling manifest\"}\n{\"status\":\"downloading\",\"total\":100,\"completed\":100}\n{\"status\":\"success\"}\n"))
+		case "/api/embed":
+			if !pulled {
+				http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(ollamaEmbedResponse{Embeddings: [][]float32{{0.1, 0.2}}})
+		}
+	}))
+	defer server.Close()
+
+	t.Run("missing model without auto-pull", func(t *testing.T) {
+		svc, err := NewOllamaService(server.URL, "nomic-embed-text")
+		require.NoError(t, err)
+
+		_, err = svc.Embed(context.Background(), "test")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ollama pull nomic-embed-text")
+		assert.Contains(t, err.Error(), "embeddings.ollama.auto_pull")
+		assert.False(t, pulled)
+	})
+
+	t.Run("auto-pull", func(t *testing.T) {
+		svc, err := NewOllamaService(server.URL, "nomic-embed-text")
+		require.NoError(t, err)
+		svc.SetAutoPull(true)
+
+		// A cancelled pull is not remembered
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = svc.Embed(cancelled, "test")
+		require.Error(t, err)
+		assert.False(t, pulled)
+
+		embedding, err := svc.Embed(context.Background(), "test")
+		require.NoError(t, err)
+		assert.True(t, pulled)
+		assert.Len(t, embedding, 2)
+	})
+}
+
+// TestKeepAliveJSON tests that keep_alive is sent as a number of seconds or
+// a duration string, never as a number in a string.
+func TestKeepAliveJSON(t *testing.T) {
+	assert.Nil(t, keepAliveJSON(""))
+	assert.Equal(t, "-1", string(keepAliveJSON("-1")))
+	assert.Equal(t, "300", string(keepAliveJSON("300")))
+	assert.Equal(t, `"10m"`, string(keepAliveJSON("10m")))
+	assert.Equal(t, `"-1s"`, string(keepAliveJSON("-1s")))
+}
+
+// stubService is a minimal embedding service for registry tests.
+type stubService struct{ model string }
+
+func (s *stubService) Embed(ctx context.Context, text string) ([]float32, error) {
+	return []float32{1}, nil
+}
+func (s *stubService) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return []float32{1}, nil
+}
+func (s *stubService) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, nil
+}
+func (s *stubService) Dimensions() int    { return 1 }
+func (s *stubService) Provider() Provider { return "stub" }
+func (s *stubService) ModelName() string  { return s.model }
+
+// TestRegister tests registering a custom provider.
+func TestRegister(t *testing.T) {
+	Register("stub", func(cfg *config.Config, model string) (Service, error) {
+		if model == "" {
+			model = "stub-default"
+		}
+		return &stubService{model: model}, nil
+	})
+	t.Cleanup(func() { unregister("stub") })
+
+	assert.Contains(t, Providers(), "stub")
+	assert.Contains(t, Providers(), "ollama")
+	assert.Contains(t, Providers(), "openai")
+
+	cfg := &config.Config{Embeddings: config.EmbeddingsConfig{Provider: "stub"}}
+	svc, err := NewService(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "stub-default", svc.ModelName())
+
+	svc, err = NewServiceForStore("stub", "stub-v2", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "stub-v2", svc.ModelName())
+
+	assert.Panics(t, func() {
+		Register("stub", func(cfg *config.Config, model string) (Service, error) { return nil, nil })
+	})
+}
+
+// TestVoyageEmbed tests the Voyage provider with a mock server.
+func TestVoyageEmbed(t *testing.T) {
+	var inputTypes []string
+	var batchSizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/embeddings", r.URL.Path)
+		assert.Equal(t, "Bearer pa-test", r.Header.Get("Authorization"))
+
+		var req voyageEmbedRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			return
+		}
+		inputTypes = append(inputTypes, req.InputType)
+		batchSizes = append(batchSizes, len(req.Input))
+		assert.Equal(t, 256, req.OutputDimension)
+
+		// Return the results in reverse order to exercise index handling
+		var resp voyageEmbedResponse
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			resp.Data = append(resp.Data, struct {
+				Embedding []float32 `json:"embedding"`
+				Index     int       `json:"index"`
+			}{Embedding: []float32{float32(i)}, Index: i})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	svc, err := NewVoyageService("pa-test", "voyage-code-3", server.URL, 256)
+	require.NoError(t, err)
+	assert.Equal(t, 256, svc.Dimensions())
+	assert.Equal(t, ProviderVoyage, svc.Provider())
+
+	_, err = svc.EmbedQuery(context.Background(), "query")
+	require.NoError(t, err)
+
+	texts := make([]string, voyageMaxBatch+5)
+	embeddings, err := svc.EmbedBatch(context.Background(), texts)
+	require.NoError(t, err)
+	require.Len(t, embeddings, len(texts))
+	assert.Equal(t, float32(3), embeddings[3][0])
+	assert.Equal(t, float32(2), embeddings[voyageMaxBatch+2][0])
+
+	assert.Equal(t, []string{"query", "document", "document"}, inputTypes)
+	assert.Equal(t, []int{1, voyageMaxBatch, 5}, batchSizes)
+}
+
+// TestCohereEmbed tests the Cohere provider with a mock server.
+func TestCohereEmbed(t *testing.T) {
+	var inputTypes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/embed", r.URL.Path)
+		assert.Equal(t, "Bearer co-test", r.Header.Get("Authorization"))
+
+		var req cohereEmbedRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			return
+		}
+		inputTypes = append(inputTypes, req.InputType)
+		assert.Equal(t, []string{"float"}, req.EmbeddingTypes)
+		assert.Zero(t, req.OutputDimension)
+		assert.LessOrEqual(t, len(req.Texts), cohereMaxBatch)
+
+		var resp cohereEmbedResponse
+		for range req.Texts {
+			resp.Embeddings.Float = append(resp.Embeddings.Float, make([]float32, 1536))
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	svc, err := NewCohereService("co-test", "embed-v4.0", server.URL, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1536, svc.Dimensions())
+	assert.Equal(t, ProviderCohere, svc.Provider())
+
+	embedding, err := svc.Embed(context.Background(), "document")
+	require.NoError(t, err)
+	assert.Len(t, embedding, 1536)
+
+	_, err = svc.EmbedQuery(context.Background(), "query")
+	require.NoError(t, err)
+
+	embeddings, err := svc.EmbedBatch(context.Background(), make([]string, cohereMaxBatch*2+1))
+	require.NoError(t, err)
+	assert.Len(t, embeddings, cohereMaxBatch*2+1)
+
+	assert.Equal(t, []string{"search_document", "search_query", "search_document", "search_document", "search_document"}, inputTypes)
+}
+
+// TestNewVoyageCohereRequireKey tests API key validation.
+func TestNewVoyageCohereRequireKey(t *testing.T) {
+	_, err := NewVoyageService("", "voyage-code-3", "", 0)
+	assert.Error(t, err)
+
+	_, err = NewCohereService("", "embed-v4.0", "", 0)
+	assert.Error(t, err)
+}
+
+// TestTruncate tests Matryoshka truncation and re-normalization.
+func TestTruncate(t *testing.T) {
+	truncated := Truncate([]float32{3, 4, 12}, 2)
+	assert.InDeltaSlice(t, []float32{0.6, 0.8}, truncated, 1e-6)
+
+	// Shorter embeddings are unchanged
+	assert.Equal(t, []float32{1, 2}, Truncate([]float32{1, 2}, 4))
+	assert.Equal(t, []float32{1, 2}, Truncate([]float32{1, 2}, 0))
+
+	assert.True(t, SupportsTruncation("nomic-embed-text:v1.5"))
+	assert.False(t, SupportsTruncation("nomic-embed-text:latest"))
+	assert.False(t, SupportsTruncation("nomic-embed-text"))
+	assert.True(t, SupportsTruncation("mxbai-embed-large:latest"))
+	assert.True(t, SupportsTruncation("text-embedding-3-small"))
+	assert.False(t, SupportsTruncation("all-minilm"))
+}
+
+// TestNewServiceWithTruncation tests that truncation applies to every embed method.
+func TestNewServiceWithTruncation(t *testing.T) {
+	server := mockOllamaServer(t, 768)
+	defer server.Close()
+
+	cfg := &config.Config{
+		Embeddings: config.EmbeddingsConfig{
+			Provider:           "ollama",
+			Ollama:             config.OllamaEmbedConfig{URL: server.URL, Model: "nomic-embed-text"},
+			TruncateDimensions: 256,
+		},
+	}
+
+	svc, err := NewService(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 256, svc.Dimensions())
+	assert.Equal(t, ProviderOllama, svc.Provider())
+
+	embedding, err := svc.Embed(context.Background(), "doc")
+	require.NoError(t, err)
+	assert.Len(t, embedding, 256)
+
+	embedding, err = svc.EmbedQuery(context.Background(), "query")
+	require.NoError(t, err)
+	assert.Len(t, embedding, 256)
+
+	batch, err := svc.EmbedBatch(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Len(t, batch[1], 256)
+
+	// Truncating to more dimensions than the model has is a no-op
+	cfg.Embeddings.TruncateDimensions = 1024
+	svc, err = NewService(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 768, svc.Dimensions())
+}
+
+// mockOpenAIBatchServer serves the file and batch endpoints, embedding each
+// input as [request ID, index within the request, 1]. The request whose
+// custom ID is failID fails.
+func mockOpenAIBatchServer(t *testing.T, failID string) *httptest.Server {
+	var input []openAIBatchRequest
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/files":
+			f, _, err := r.FormFile("file")
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, "batch", r.FormValue("purpose"))
+			decoder := json.NewDecoder(f)
+			for decoder.More() {
+				var req openAIBatchRequest
+				if !assert.NoError(t, decoder.Decode(&req)) {
+					return
+				}
+				assert.Equal(t, openAIBatchEndpoint, req.URL)
+				input = append(input, req)
+			}
+			_, _ = w.Write([]byte(`{"id":"file-in","object":"file","purpose":"batch"}`))
+		case r.Method == "POST" && r.URL.Path == "/batches":
+			var body map[string]string
+			if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&body)) {
+				return
+			}
+			assert.Equal(t, "file-in", body["input_file_id"])
+			assert.Equal(t, "/v1/embeddings", body["endpoint"])
+			_, _ = w.Write([]byte(`{"id":"batch_1","object":"batch","status":"validating"}`))
+		case r.Method == "GET" && r.URL.Path == "/batches/batch_1":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"id": "batch_1", "object": "batch", "status": "completed", "output_file_id": "file-out",
+				"request_counts": map[string]int{"completed": len(input) - 1, "failed": 1, "total": len(input)},
+			})
+		case r.Method == "GET" && r.URL.Path == "/files/file-out/content":
+			encoder := json.NewEncoder(w)
+			for _, req := range input {
+				if req.CustomID == failID {
+					_ = encoder.Encode(map[string]any{"custom_id": req.CustomID, "error": map[string]string{"code": "x", "message": "failed"}})
+					continue
+				}
+				id, _ := strconv.Atoi(req.CustomID)
+				var data []map[string]any
+				for i := range req.Body.Input {
+					data = append(data, map[string]any{"index": i, "embedding": []float32{float32(id), float32(i), 1}})
+				}
+				_ = encoder.Encode(map[string]any{
+					"custom_id": req.CustomID,
+					"response":  map[string]any{"status_code": 200, "body": map[string]any{"data": data}},
+				})
+			}
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestOpenAIBatch(t *testing.T) {
+	server := mockOpenAIBatchServer(t, "100")
+	defer server.Close()
+
+	svc, err := NewOpenAIService("sk-test", "text-embedding-3-small", server.URL+"/", 0)
+	require.NoError(t, err)
+
+	b, ok := AsBatchService(svc)
+	require.True(t, ok)
+
+	texts := make([]string, 250)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("text %d", i)
+	}
+	id, err := b.SubmitBatch(context.Background(), texts)
+	require.NoError(t, err)
+	assert.Equal(t, "batch_1", id)
+
+	job, err := b.GetBatch(context.Background(), id)
+	require.NoError(t, err)
+	assert.True(t, job.Done())
+	assert.Equal(t, BatchCompleted, job.Status)
+	assert.Equal(t, 3, job.Total)
+	assert.Equal(t, 1, job.Failed)
+
+	results, err := b.BatchResults(context.Background(), id)
+	require.NoError(t, err)
+	require.Len(t, results, 250)
+	assert.Equal(t, []float32{0, 5, 1}, results[5])
+	assert.Nil(t, results[150]) // The second request failed
+	assert.Equal(t, []float32{200, 49, 1}, results[249])
+
+	_, err = b.SubmitBatch(context.Background(), make([]string, openAIMaxBatchInputs+1))
+	assert.Error(t, err)
+}
+
+func TestAsBatchServiceTruncates(t *testing.T) {
+	server := mockOpenAIBatchServer(t, "")
+	defer server.Close()
+
+	svc, err := NewOpenAIService("sk-test", "text-embedding-3-small", server.URL+"/", 0)
+	require.NoError(t, err)
+
+	b, ok := AsBatchService(withTruncation(svc, 2))
+	require.True(t, ok)
+	id, err := b.SubmitBatch(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	results, err := b.BatchResults(context.Background(), id)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, []float32{0, 1}, results[1])
+
+	// Providers without a batch API have none
+	_, ok = AsBatchService(&stubService{model: "m"})
+	assert.False(t, ok)
+}
@@ -0,0 +1,113 @@
+package embeddings
+
+import (
+	"context"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/fallback"
+)
+
+// fallbackService tries each service of a chain in turn, moving on when a
+// provider cannot be reached or does not have the model.
+type fallbackService struct {
+	primary Service
+	chain   *fallback.Chain[Service]
+}
+
+// withFallbacks returns primary followed by the services of the fallback
+// providers, or primary alone if there are none. Each fallback is created
+// for the primary's model, since vectors from another model cannot be
+// compared with those already indexed; fallbacks that cannot serve it with
+// the same dimensions are left out with a warning.
+func withFallbacks(primary Service, providers []string, cfg *config.Config) Service {
+	chain := fallback.NewChain[Service](fallback.DefaultCooldown)
+	chain.Add(string(primary.Provider()), primary)
+	for _, provider := range providers {
+		if provider == string(primary.Provider()) {
+			continue
+		}
+		c, err := lookup(provider)
+		if err == nil {
+			var svc Service
+			if svc, err = c(cfg, primary.ModelName()); err == nil {
+				svc = withTruncation(svc, cfg.Embeddings.TruncateDimensions)
+				if svc.Dimensions() != primary.Dimensions() {
+					log.Warn("Skipping embedding fallback with different dimensions",
+						"provider", provider, "model", svc.ModelName(),
+						"dimensions", svc.Dimensions(), "want", primary.Dimensions())
+					continue
+				}
+				chain.Add(provider, svc)
+				continue
+			}
+		}
+		log.Warn("Skipping embedding fallback", "provider", provider, "error", err)
+	}
+	if chain.Len() == 1 {
+		return primary
+	}
+	return &fallbackService{primary: primary, chain: chain}
+}
+
+// Embed generates an embedding for document text.
+func (s *fallbackService) Embed(ctx context.Context, text string) ([]float32, error) {
+	return fallback.Do(ctx, s.chain, func(svc Service) ([]float32, error) {
+		return svc.Embed(ctx, text)
+	})
+}
+
+// EmbedQuery generates an embedding for query text.
+func (s *fallbackService) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return fallback.Do(ctx, s.chain, func(svc Service) ([]float32, error) {
+		return svc.EmbedQuery(ctx, text)
+	})
+}
+
+// EmbedBatch generates embeddings for multiple document texts.
+func (s *fallbackService) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return fallback.Do(ctx, s.chain, func(svc Service) ([][]float32, error) {
+		return svc.EmbedBatch(ctx, texts)
+	})
+}
+
+// Dimensions returns the embedding dimensions, which every service in the
+// chain shares.
+func (s *fallbackService) Dimensions() int {
+	return s.chain.Active().Dimensions()
+}
+
+// Provider returns the provider that served the last request.
+func (s *fallbackService) Provider() Provider {
+	return s.chain.Active().Provider()
+}
+
+// Primary returns the configured service, which stores record as the one
+// their embeddings come from.
+func (s *fallbackService) Primary() Service {
+	return s.primary
+}
+
+// Normalized reports whether every service in the chain returns normalized
+// embeddings.
+func (s *fallbackService) Normalized() bool {
+	for _, svc := range s.chain.Services() {
+		if !Normalized(svc) {
+			return false
+		}
+	}
+	return true
+}
+
+// ModelName returns the model name, which every service in the chain
+// shares.
+func (s *fallbackService) ModelName() string {
+	return s.chain.Active().ModelName()
+}
+
+// Batch returns the primary service's batch API. Batch jobs outlive the
+// process that submits them, so they are not moved to other providers.
+func (s *fallbackService) Batch() (BatchService, bool) {
+	return AsBatchService(s.primary)
+}
@@ -7,10 +7,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/httpclient"
+	"github.com/nickcecere/lgrep/internal/models"
 )
 
 // Task prefixes for specific models
@@ -28,20 +33,68 @@ var taskPrefixes = map[string]struct {
 	},
 }
 
+func init() {
+	Register(string(ProviderOllama), func(cfg *config.Config, model string) (Service, error) {
+		if model == "" {
+			model = cfg.Embeddings.Ollama.Model
+		}
+		svc, err := NewOllamaService(cfg.Embeddings.Ollama.URL, model)
+		if err != nil {
+			return nil, err
+		}
+		client, err := httpclient.New(cfg.Network, cfg.Embeddings.Ollama.HTTPConfig)
+		if err != nil {
+			return nil, err
+		}
+		svc.SetHTTPClient(client)
+		svc.SetAutoPull(cfg.Embeddings.Ollama.AutoPull)
+		svc.SetKeepAlive(cfg.Embeddings.Ollama.KeepAlive)
+		svc.SetNumCtx(cfg.Embeddings.Ollama.NumCtx)
+		svc.SetParallel(cfg.Embeddings.Ollama.Parallel)
+		return svc, nil
+	})
+}
+
 // OllamaService implements the embedding service using Ollama.
 type OllamaService struct {
+	url        string // as configured, e.g. a unix socket URL
 	baseURL    string
 	model      string
-	dimensions int
 	client     *http.Client
+	mu         sync.Mutex // guards dimensions
+	dimensions int
+
+	// keepAlive and numCtx are passed with each request when set
+	keepAlive json.RawMessage
+	numCtx    int
+
+	// parallel is how many requests may be in flight at once; sem holds a
+	// token for each
+	parallel int
+	sem      chan struct{}
+
+	// autoPull downloads the model on first use if Ollama does not have it
+	autoPull bool
+	pullMu   sync.Mutex
+	pulled   bool // Set once the model is known to be present
 }
 
 // ollamaEmbedRequest is the request body for the Ollama embed API.
 type ollamaEmbedRequest struct {
-	Model     string   `json:"model"`
-	Input     []string `json:"input"`
-	KeepAlive string   `json:"keep_alive,omitempty"`
-	Truncate  bool     `json:"truncate,omitempty"`
+	Model    string   `json:"model"`
+	Input    []string `json:"input"`
+	Truncate bool     `json:"truncate,omitempty"`
+
+	// KeepAlive is a number of seconds or a duration string, as Ollama
+	// rejects a number in a string
+	KeepAlive json.RawMessage `json:"keep_alive,omitempty"`
+
+	Options *ollamaOptions `json:"options,omitempty"`
+}
+
+// ollamaOptions are the model options of an embed request.
+type ollamaOptions struct {
+	NumCtx int `json:"num_ctx,omitempty"`
 }
 
 // ollamaEmbedResponse is the response from the Ollama embed API.
@@ -50,6 +103,7 @@ type ollamaEmbedResponse struct {
 }
 
 // NewOllamaService creates a new Ollama embedding service.
+// baseURL may also be a unix socket URL such as unix:///run/ollama.sock.
 func NewOllamaService(baseURL, model string) (*OllamaService, error) {
 	if baseURL == "" {
 		baseURL = "http://localhost:11434"
@@ -63,14 +117,15 @@ func NewOllamaService(baseURL, model string) (*OllamaService, error) {
 		log.Debug("Unknown model dimensions, defaulting", "model", model, "dimensions", dimensions)
 	}
 
-	return &OllamaService{
-		baseURL:    strings.TrimSuffix(baseURL, "/"),
+	s := &OllamaService{
+		url:        strings.TrimSuffix(baseURL, "/"),
 		model:      model,
 		dimensions: dimensions,
-		client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
-	}, nil
+		parallel:   1,
+		sem:        make(chan struct{}, 1),
+	}
+	s.SetHTTPClient(&http.Client{Timeout: config.DefaultEmbedTimeout})
+	return s, nil
 }
 
 // Embed generates an embedding for document text.
@@ -119,11 +174,50 @@ func (s *OllamaService) EmbedBatch(ctx context.Context, texts []string) ([][]flo
 		prefixedTexts[i] = s.applyPrefix(text, false)
 	}
 
-	return s.embedTexts(ctx, prefixedTexts)
+	return s.embedParallel(ctx, prefixedTexts)
+}
+
+// embedParallel splits texts across up to s.parallel concurrent requests,
+// so a server with OLLAMA_NUM_PARALLEL set embeds them at the same time.
+func (s *OllamaService) embedParallel(ctx context.Context, texts []string) ([][]float32, error) {
+	parts := min(s.parallel, len(texts))
+	if parts <= 1 {
+		return s.embedTexts(ctx, texts)
+	}
+
+	size := (len(texts) + parts - 1) / parts
+	embeddings := make([][]float32, 0, len(texts))
+	results := make([][][]float32, parts)
+	errs := make([]error, parts)
+
+	var wg sync.WaitGroup
+	for i := range parts {
+		start := i * size
+		end := min(start+size, len(texts))
+		if start >= end {
+			break
+		}
+		wg.Add(1)
+		go func(i int, texts []string) {
+			defer wg.Done()
+			results[i], errs[i] = s.embedTexts(ctx, texts)
+		}(i, texts[start:end])
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, results[i]...)
+	}
+	return embeddings, nil
 }
 
 // Dimensions returns the embedding dimensions.
 func (s *OllamaService) Dimensions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.dimensions
 }
 
@@ -137,6 +231,88 @@ func (s *OllamaService) ModelName() string {
 	return s.model
 }
 
+// SetHTTPClient sets the client used for requests, e.g. one built by
+// httpclient.New from the provider's configuration. For a unix socket URL,
+// its connections are made to the socket.
+func (s *OllamaService) SetHTTPClient(client *http.Client) {
+	s.client, s.baseURL = httpclient.Endpoint(client, s.url)
+}
+
+// SetAutoPull enables pulling the model before the first request if Ollama
+// does not have it.
+func (s *OllamaService) SetAutoPull(enabled bool) {
+	s.autoPull = enabled
+}
+
+// SetKeepAlive sets how long Ollama keeps the model loaded after a request,
+// as a duration such as "10m" or "-1" to keep it loaded indefinitely. Empty
+// uses the server's default.
+func (s *OllamaService) SetKeepAlive(keepAlive string) {
+	s.keepAlive = keepAliveJSON(keepAlive)
+}
+
+// keepAliveJSON encodes a keep_alive setting for Ollama: whole numbers of
+// seconds, such as -1, as JSON numbers and durations as strings. Empty
+// leaves it out.
+func keepAliveJSON(keepAlive string) json.RawMessage {
+	if keepAlive == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(keepAlive); err == nil {
+		return json.RawMessage(strconv.Itoa(n))
+	}
+	encoded, _ := json.Marshal(keepAlive)
+	return encoded
+}
+
+// SetNumCtx sets the context window, in tokens, the model is loaded with.
+// Zero uses the model's default.
+func (s *OllamaService) SetNumCtx(numCtx int) {
+	s.numCtx = numCtx
+}
+
+// SetParallel sets how many embed requests may be in flight at once. The
+// server only processes them concurrently up to its OLLAMA_NUM_PARALLEL
+// setting. Values below 1 are treated as 1.
+func (s *OllamaService) SetParallel(n int) {
+	s.parallel = max(n, 1)
+	s.sem = make(chan struct{}, s.parallel)
+}
+
+// acquire waits for a free request slot or for ctx to be cancelled.
+func (s *OllamaService) acquire(ctx context.Context) error {
+	select {
+	case s.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a request slot.
+func (s *OllamaService) release() {
+	<-s.sem
+}
+
+// ensureModel pulls the model if auto-pull is enabled and it has not been
+// found yet. A cancelled or failed pull is tried again by the next caller,
+// with its own ctx.
+func (s *OllamaService) ensureModel(ctx context.Context) error {
+	if !s.autoPull {
+		return nil
+	}
+	s.pullMu.Lock()
+	defer s.pullMu.Unlock()
+	if s.pulled {
+		return nil
+	}
+	if err := models.EnsureOllamaModel(ctx, s.client, s.baseURL, s.model); err != nil {
+		return err
+	}
+	s.pulled = true
+	return nil
+}
+
 // applyPrefix applies the appropriate task prefix for the model.
 func (s *OllamaService) applyPrefix(text string, isQuery bool) string {
 	prefixes, ok := taskPrefixes[s.model]
@@ -152,10 +328,18 @@ func (s *OllamaService) applyPrefix(text string, isQuery bool) string {
 
 // embedTexts performs the actual embedding request.
 func (s *OllamaService) embedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := s.ensureModel(ctx); err != nil {
+		return nil, err
+	}
+
 	reqBody := ollamaEmbedRequest{
-		Model:    s.model,
-		Input:    texts,
-		Truncate: true,
+		Model:     s.model,
+		Input:     texts,
+		KeepAlive: s.keepAlive,
+		Truncate:  true,
+	}
+	if s.numCtx > 0 {
+		reqBody.Options = &ollamaOptions{NumCtx: s.numCtx}
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
@@ -170,6 +354,11 @@ func (s *OllamaService) embedTexts(ctx context.Context, texts []string) ([][]flo
 	}
 	req.Header.Set("Content-Type", "application/json")
 
+	if err := s.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer s.release()
+
 	log.Debug("Requesting embeddings from Ollama", "model", s.model, "count", len(texts))
 
 	resp, err := s.client.Do(req)
@@ -178,6 +367,9 @@ func (s *OllamaService) embedTexts(ctx context.Context, texts []string) ([][]flo
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, models.MissingModelError(s.model, "embeddings.ollama.auto_pull")
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
@@ -190,7 +382,9 @@ func (s *OllamaService) embedTexts(ctx context.Context, texts []string) ([][]flo
 
 	// Update dimensions if we got a response
 	if len(result.Embeddings) > 0 && len(result.Embeddings[0]) > 0 {
+		s.mu.Lock()
 		s.dimensions = len(result.Embeddings[0])
+		s.mu.Unlock()
 	}
 
 	return result.Embeddings, nil
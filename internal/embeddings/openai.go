@@ -3,15 +3,43 @@ package embeddings
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/charmbracelet/log"
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/httpclient"
 )
 
+func init() {
+	Register(string(ProviderOpenAI), func(cfg *config.Config, model string) (Service, error) {
+		if model == "" {
+			model = cfg.Embeddings.OpenAI.Model
+		}
+		svc, err := NewOpenAIService(
+			cfg.Embeddings.OpenAI.APIKey,
+			model,
+			cfg.Embeddings.OpenAI.BaseURL,
+			cfg.Embeddings.OpenAI.Dimensions,
+		)
+		if err != nil {
+			return nil, err
+		}
+		client, err := httpclient.New(cfg.Network, cfg.Embeddings.OpenAI.HTTPConfig)
+		if err != nil {
+			return nil, err
+		}
+		svc.SetHTTPClient(client)
+		return svc, nil
+	})
+}
+
 // OpenAIService implements the embedding service using OpenAI API.
 type OpenAIService struct {
 	client     openai.Client
+	options    []option.RequestOption // Options client was created with
 	model      string
 	dimensions int
 }
@@ -44,11 +72,18 @@ func NewOpenAIService(apiKey, model, baseURL string, dimensions int) (*OpenAISer
 
 	return &OpenAIService{
 		client:     client,
+		options:    opts,
 		model:      model,
 		dimensions: dimensions,
 	}, nil
 }
 
+// SetHTTPClient sets the client used for requests, e.g. one built by
+// httpclient.New from the provider's configuration.
+func (s *OpenAIService) SetHTTPClient(client *http.Client) {
+	s.client = openai.NewClient(append(s.options, option.WithHTTPClient(client))...)
+}
+
 // Embed generates an embedding for document text.
 func (s *OpenAIService) Embed(ctx context.Context, text string) ([]float32, error) {
 	embeddings, err := s.embedTexts(ctx, []string{text})
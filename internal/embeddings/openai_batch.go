@@ -0,0 +1,189 @@
+package embeddings
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/openai/openai-go/v3"
+)
+
+const (
+	// openAIMaxBatchInputs is the most embedding inputs OpenAI accepts
+	// across all requests of one batch.
+	openAIMaxBatchInputs = 50000
+
+	// openAIBatchRequestSize is how many texts are embedded per request in
+	// a batch, well within the per-request input and token limits.
+	openAIBatchRequestSize = 100
+
+	// openAIBatchEndpoint is the API path each batch request calls.
+	openAIBatchEndpoint = "/v1/embeddings"
+)
+
+// openAIBatchRequest is one line of a batch input file.
+type openAIBatchRequest struct {
+	CustomID string                 `json:"custom_id"`
+	Method   string                 `json:"method"`
+	URL      string                 `json:"url"`
+	Body     openAIBatchRequestBody `json:"body"`
+}
+
+type openAIBatchRequestBody struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// openAIBatchResult is one line of a batch output file.
+type openAIBatchResult struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int `json:"status_code"`
+		Body       struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+		} `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// MaxBatchSize returns the most texts a single batch can hold.
+func (s *OpenAIService) MaxBatchSize() int {
+	return openAIMaxBatchInputs
+}
+
+// SubmitBatch uploads texts as a batch input file and starts a batch job.
+// Each request's custom ID is the index of its first text, so results can
+// be put back in order.
+func (s *OpenAIService) SubmitBatch(ctx context.Context, texts []string) (string, error) {
+	if len(texts) == 0 {
+		return "", fmt.Errorf("batch is empty")
+	}
+	if len(texts) > openAIMaxBatchInputs {
+		return "", fmt.Errorf("batch of %d texts exceeds the limit of %d", len(texts), openAIMaxBatchInputs)
+	}
+
+	var input bytes.Buffer
+	encoder := json.NewEncoder(&input)
+	for start := 0; start < len(texts); start += openAIBatchRequestSize {
+		end := min(start+openAIBatchRequestSize, len(texts))
+		err := encoder.Encode(openAIBatchRequest{
+			CustomID: strconv.Itoa(start),
+			Method:   "POST",
+			URL:      openAIBatchEndpoint,
+			Body:     openAIBatchRequestBody{Model: s.model, Input: texts[start:end]},
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to encode batch request: %w", err)
+		}
+	}
+
+	file, err := s.client.Files.New(ctx, openai.FileNewParams{
+		File:    openai.File(&input, "lgrep-batch.jsonl", "application/jsonl"),
+		Purpose: openai.FilePurposeBatch,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload batch input: %w", err)
+	}
+
+	batch, err := s.client.Batches.New(ctx, openai.BatchNewParams{
+		CompletionWindow: openai.BatchNewParamsCompletionWindow24h,
+		Endpoint:         openai.BatchNewParamsEndpointV1Embeddings,
+		InputFileID:      file.ID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	log.Debug("Submitted OpenAI batch", "id", batch.ID, "texts", len(texts), "file", file.ID)
+	return batch.ID, nil
+}
+
+// GetBatch returns the current state of a batch job.
+func (s *OpenAIService) GetBatch(ctx context.Context, id string) (*BatchJob, error) {
+	batch, err := s.client.Batches.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+
+	job := &BatchJob{
+		ID:        batch.ID,
+		Status:    BatchPending,
+		Detail:    string(batch.Status),
+		Completed: int(batch.RequestCounts.Completed),
+		Failed:    int(batch.RequestCounts.Failed),
+		Total:     int(batch.RequestCounts.Total),
+	}
+	switch batch.Status {
+	case openai.BatchStatusCompleted:
+		job.Status = BatchCompleted
+	case openai.BatchStatusExpired:
+		// Requests completed before the window closed are still in the
+		// output file
+		job.Status = BatchCompleted
+	case openai.BatchStatusFailed, openai.BatchStatusCancelled:
+		job.Status = BatchFailed
+		if len(batch.Errors.Data) > 0 {
+			job.Detail = batch.Errors.Data[0].Message
+		}
+	}
+	return job, nil
+}
+
+// BatchResults downloads the output file of a finished batch.
+func (s *OpenAIService) BatchResults(ctx context.Context, id string) ([][]float32, error) {
+	batch, err := s.client.Batches.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+
+	total := int(batch.RequestCounts.Total) * openAIBatchRequestSize
+	embeddings := make([][]float32, 0, total)
+	if batch.OutputFileID == "" {
+		return embeddings, nil
+	}
+
+	resp, err := s.client.Files.Content(ctx, batch.OutputFileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download batch output: %w", err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 256*1024*1024)
+	for scanner.Scan() {
+		var result openAIBatchResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			return nil, fmt.Errorf("failed to decode batch output: %w", err)
+		}
+		start, err := strconv.Atoi(result.CustomID)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("unexpected request ID in batch output: %q", result.CustomID)
+		}
+		if result.Error != nil || result.Response == nil || result.Response.StatusCode != 200 {
+			log.Debug("Batch request failed", "batch", id, "request", result.CustomID)
+			continue
+		}
+
+		for _, data := range result.Response.Body.Data {
+			i := start + data.Index
+			for len(embeddings) <= i {
+				embeddings = append(embeddings, nil)
+			}
+			embeddings[i] = data.Embedding
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch output: %w", err)
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,67 @@
+package embeddings
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/nickcecere/lgrep/internal/config"
+)
+
+// Constructor creates an embedding service for a provider. An empty model
+// means the model configured for the provider.
+type Constructor func(cfg *config.Config, model string) (Service, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Constructor)
+)
+
+// Register makes an embedding provider available by name. It is intended to
+// be called from init functions, so custom builds can add providers without
+// changing the factory code. Register panics if the name is registered twice.
+func Register(name string, c Constructor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if c == nil {
+		panic("embeddings: Register constructor is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("embeddings: Register called twice for provider " + name)
+	}
+	registry[name] = c
+}
+
+// unregister removes a provider added with Register, so tests can register
+// providers of their own without leaking them into other tests.
+func unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, name)
+}
+
+// Providers returns the names of the registered embedding providers, sorted.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookup returns the constructor registered for a provider.
+func lookup(provider string) (Constructor, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	c, ok := registry[provider]
+	if !ok {
+		return nil, fmt.Errorf("unsupported embedding provider: %s", provider)
+	}
+	return c, nil
+}
@@ -0,0 +1,121 @@
+package embeddings
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// matryoshkaModels lists models trained with Matryoshka representation
+// learning, whose embeddings stay useful when truncated to a prefix. Only
+// v1.5 of nomic-embed-text is, so it is listed by its exact tag.
+var matryoshkaModels = map[string]bool{
+	"nomic-embed-text:v1.5":  true,
+	"nomic-embed-text-v1.5":  true,
+	"mxbai-embed-large":      true,
+	"text-embedding-3-small": true,
+	"text-embedding-3-large": true,
+	"voyage-code-3":          true,
+	"voyage-3.5":             true,
+	"voyage-3.5-lite":        true,
+	"voyage-3-large":         true,
+	"embed-v4.0":             true,
+}
+
+// SupportsTruncation reports whether a model's embeddings can be truncated.
+// A model matches an entry with its exact tag, or any Ollama tag (e.g.
+// ":latest") of an entry listed without one.
+func SupportsTruncation(model string) bool {
+	if matryoshkaModels[model] {
+		return true
+	}
+	name, _, _ := strings.Cut(model, ":")
+	return matryoshkaModels[name]
+}
+
+// Truncate shortens an embedding to the given dimensions and re-normalizes it
+// to unit length. Embeddings already at or below that size are returned as is.
+func Truncate(embedding []float32, dimensions int) []float32 {
+	if dimensions <= 0 || len(embedding) <= dimensions {
+		return embedding
+	}
+
+	truncated := make([]float32, dimensions)
+	copy(truncated, embedding[:dimensions])
+
+	var sum float64
+	for _, v := range truncated {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return truncated
+	}
+
+	norm := float32(math.Sqrt(sum))
+	for i := range truncated {
+		truncated[i] /= norm
+	}
+	return truncated
+}
+
+// truncatedService wraps an embedding service so every embedding it returns
+// is truncated to a fixed number of dimensions.
+type truncatedService struct {
+	Service
+	dimensions int
+}
+
+// withTruncation wraps svc to truncate its embeddings, or returns svc unchanged
+// if no truncation is configured or the model is already small enough.
+func withTruncation(svc Service, dimensions int) Service {
+	if dimensions <= 0 || svc.Dimensions() <= dimensions {
+		return svc
+	}
+	if !SupportsTruncation(svc.ModelName()) {
+		log.Warn("Model may not support dimension truncation; search quality may suffer",
+			"model", svc.ModelName(), "dimensions", dimensions)
+	}
+	return &truncatedService{Service: svc, dimensions: dimensions}
+}
+
+// Embed generates a truncated embedding for document text.
+func (s *truncatedService) Embed(ctx context.Context, text string) ([]float32, error) {
+	embedding, err := s.Service.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return Truncate(embedding, s.dimensions), nil
+}
+
+// EmbedQuery generates a truncated embedding for query text.
+func (s *truncatedService) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	embedding, err := s.Service.EmbedQuery(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return Truncate(embedding, s.dimensions), nil
+}
+
+// EmbedBatch generates truncated embeddings for multiple document texts.
+func (s *truncatedService) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings, err := s.Service.EmbedBatch(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	for i, embedding := range embeddings {
+		embeddings[i] = Truncate(embedding, s.dimensions)
+	}
+	return embeddings, nil
+}
+
+// Dimensions returns the truncated embedding dimensions.
+func (s *truncatedService) Dimensions() int {
+	return s.dimensions
+}
+
+// Normalized reports true, since truncated embeddings are normalized again.
+func (s *truncatedService) Normalized() bool {
+	return true
+}
@@ -0,0 +1,195 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/httpclient"
+)
+
+func init() {
+	Register(string(ProviderVoyage), func(cfg *config.Config, model string) (Service, error) {
+		if model == "" {
+			model = cfg.Embeddings.Voyage.Model
+		}
+		svc, err := NewVoyageService(
+			cfg.Embeddings.Voyage.APIKey,
+			model,
+			cfg.Embeddings.Voyage.BaseURL,
+			cfg.Embeddings.Voyage.Dimensions,
+		)
+		if err != nil {
+			return nil, err
+		}
+		client, err := httpclient.New(cfg.Network, cfg.Embeddings.Voyage.HTTPConfig)
+		if err != nil {
+			return nil, err
+		}
+		svc.SetHTTPClient(client)
+		return svc, nil
+	})
+}
+
+const (
+	voyageAPIURL = "https://api.voyageai.com/v1"
+
+	// voyageMaxBatch is the number of texts sent per request. The API accepts
+	// up to 1000, but code chunks hit the per-request token limit well before that.
+	voyageMaxBatch = 128
+)
+
+// VoyageService implements the embedding service using the Voyage AI API.
+type VoyageService struct {
+	apiKey     string
+	baseURL    string
+	model      string
+	dimensions int
+	reduced    bool // Request dimensions instead of the model default
+	client     *http.Client
+}
+
+// voyageEmbedRequest is the request body for the Voyage embeddings API.
+type voyageEmbedRequest struct {
+	Input           []string `json:"input"`
+	Model           string   `json:"model"`
+	InputType       string   `json:"input_type"`
+	OutputDimension int      `json:"output_dimension,omitempty"`
+	Truncation      bool     `json:"truncation"`
+}
+
+// voyageEmbedResponse is the response from the Voyage embeddings API.
+type voyageEmbedResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+		Index     int       `json:"index"`
+	} `json:"data"`
+}
+
+// NewVoyageService creates a new Voyage AI embedding service.
+func NewVoyageService(apiKey, model, baseURL string, dimensions int) (*VoyageService, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("Voyage API key is required")
+	}
+	if baseURL == "" {
+		baseURL = voyageAPIURL
+	}
+
+	// Use the model's default dimensions unless a size is requested
+	reduced := dimensions > 0
+	if dimensions == 0 {
+		dimensions = GetModelDimensions(model)
+		if dimensions == 0 {
+			dimensions = 1024
+			log.Debug("Unknown model dimensions, defaulting", "model", model, "dimensions", dimensions)
+		}
+	}
+
+	return &VoyageService{
+		apiKey:     apiKey,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		model:      model,
+		dimensions: dimensions,
+		reduced:    reduced,
+		client: &http.Client{
+			Timeout: config.DefaultEmbedTimeout,
+		},
+	}, nil
+}
+
+// SetHTTPClient sets the client used for requests, e.g. one built by
+// httpclient.New from the provider's configuration.
+func (s *VoyageService) SetHTTPClient(client *http.Client) {
+	s.client = client
+}
+
+// Embed generates an embedding for document text.
+func (s *VoyageService) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := s.embedTexts(ctx, []string{text}, "document")
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedQuery generates an embedding for query text.
+func (s *VoyageService) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := s.embedTexts(ctx, []string{text}, "query")
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for multiple document texts.
+func (s *VoyageService) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	results := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += voyageMaxBatch {
+		end := min(start+voyageMaxBatch, len(texts))
+		batch, err := s.embedTexts(ctx, texts[start:end], "document")
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, batch...)
+	}
+
+	return results, nil
+}
+
+// Dimensions returns the embedding dimensions.
+func (s *VoyageService) Dimensions() int {
+	return s.dimensions
+}
+
+// Provider returns the provider name.
+func (s *VoyageService) Provider() Provider {
+	return ProviderVoyage
+}
+
+// ModelName returns the model name.
+func (s *VoyageService) ModelName() string {
+	return s.model
+}
+
+// embedTexts performs the actual embedding request.
+func (s *VoyageService) embedTexts(ctx context.Context, texts []string, inputType string) ([][]float32, error) {
+	log.Debug("Requesting embeddings from Voyage", "model", s.model, "count", len(texts), "input_type", inputType)
+
+	reqBody := voyageEmbedRequest{
+		Input:      texts,
+		Model:      s.model,
+		InputType:  inputType,
+		Truncation: true,
+	}
+	if s.reduced {
+		reqBody.OutputDimension = s.dimensions
+	}
+
+	var result voyageEmbedResponse
+	if err := postJSON(ctx, s.client, s.baseURL+"/embeddings", s.apiKey, reqBody, &result); err != nil {
+		return nil, fmt.Errorf("voyage embeddings failed: %w", err)
+	}
+
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("voyage returned %d embeddings for %d texts", len(result.Data), len(texts))
+	}
+
+	// Results carry their input index and may arrive out of order
+	embeddings := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("voyage returned invalid index %d", d.Index)
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+
+	return embeddings, nil
+}
@@ -0,0 +1,186 @@
+// Package export writes the stored embeddings of a store, with metadata
+// about each chunk, in formats read by visualization tools such as the
+// TensorFlow Embedding Projector and UMAP.
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/nickcecere/lgrep/internal/fs"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// Formats accepted by lgrep export-embeddings.
+const (
+	FormatTSV   = "tsv"
+	FormatJSONL = "jsonl"
+)
+
+// pageSize is how many vectors are read from the store at a time.
+const pageSize = 512
+
+// previewLength is the most characters of a chunk's first line included in
+// its metadata.
+const previewLength = 80
+
+// metadataHeader names the metadata columns. The Projector requires a header
+// row when there is more than one column.
+var metadataHeader = []string{"label", "path", "directory", "language", "start_line", "end_line", "preview"}
+
+// Chunk is one exported chunk.
+type Chunk struct {
+	ChunkID   int64     `json:"chunk_id"`
+	Path      string    `json:"path"`
+	Directory string    `json:"directory"`
+	Language  string    `json:"language"`
+	StartLine int       `json:"start_line"`
+	EndLine   int       `json:"end_line"`
+	Preview   string    `json:"preview"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// TSV writes every vector of a store to vectors, one tab-separated row per
+// chunk, and the matching metadata rows to metadata, as loaded by the
+// Projector. It returns the number of chunks written.
+func TSV(st store.Store, storeID int64, vectors, metadata io.Writer) (int, error) {
+	vw := bufio.NewWriter(vectors)
+	mw := bufio.NewWriter(metadata)
+	if _, err := mw.WriteString(strings.Join(metadataHeader, "\t") + "\n"); err != nil {
+		return 0, err
+	}
+
+	n, err := each(st, storeID, func(c Chunk) error {
+		values := make([]string, len(c.Embedding))
+		for i, v := range c.Embedding {
+			values[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+		}
+		if _, err := vw.WriteString(strings.Join(values, "\t") + "\n"); err != nil {
+			return err
+		}
+
+		row := []string{
+			fmt.Sprintf("%s:%d-%d", c.Path, c.StartLine, c.EndLine),
+			c.Path,
+			c.Directory,
+			c.Language,
+			strconv.Itoa(c.StartLine),
+			strconv.Itoa(c.EndLine),
+			c.Preview,
+		}
+		for i, v := range row {
+			row[i] = tsvField(v)
+		}
+		_, err := mw.WriteString(strings.Join(row, "\t") + "\n")
+		return err
+	})
+	if err != nil {
+		return n, err
+	}
+	if err := vw.Flush(); err != nil {
+		return n, err
+	}
+	return n, mw.Flush()
+}
+
+// JSONL writes every chunk of a store with its embedding to w, one JSON
+// object per line. It returns the number of chunks written.
+func JSONL(st store.Store, storeID int64, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	n, err := each(st, storeID, func(c Chunk) error {
+		return encoder.Encode(c)
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// each calls fn for every chunk of a store that has a vector, in chunk ID
+// order, and returns the number of chunks visited.
+func each(st store.Store, storeID int64, fn func(Chunk) error) (int, error) {
+	n := 0
+	var afterID int64
+	for {
+		vectors, err := st.ListChunkVectors(storeID, afterID, pageSize)
+		if err != nil {
+			return n, err
+		}
+		if len(vectors) == 0 {
+			return n, nil
+		}
+		afterID = vectors[len(vectors)-1].ChunkID
+
+		ids := make([]int64, len(vectors))
+		for i, v := range vectors {
+			ids[i] = v.ChunkID
+		}
+		chunks, err := st.GetChunks(ids)
+		if err != nil {
+			return n, err
+		}
+		byID := make(map[int64]store.SearchResult, len(chunks))
+		for _, c := range chunks {
+			byID[c.Chunk.ID] = c
+		}
+
+		for _, v := range vectors {
+			c, ok := byID[v.ChunkID]
+			if !ok {
+				// A vector without a chunk is left over from a deleted
+				// file and is not searchable either
+				continue
+			}
+			if err := fn(newChunk(c, v.Embedding)); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+}
+
+// newChunk builds the exported form of a stored chunk.
+func newChunk(c store.SearchResult, embedding []float32) Chunk {
+	rel := c.File.RelativePath
+	dir := path.Dir(rel)
+	if i := strings.Index(rel, "/"); i >= 0 {
+		dir = rel[:i] // Top-level directory, which colors clusters usefully
+	}
+	return Chunk{
+		ChunkID:   c.Chunk.ID,
+		Path:      rel,
+		Directory: dir,
+		Language:  fs.DetectLanguage(rel),
+		StartLine: c.Chunk.StartLine,
+		EndLine:   c.Chunk.EndLine,
+		Preview:   preview(c.Chunk.Content),
+		Embedding: embedding,
+	}
+}
+
+// preview returns the first non-blank line of content, shortened to
+// previewLength characters.
+func preview(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if r := []rune(line); len(r) > previewLength {
+			line = string(r[:previewLength]) + "..."
+		}
+		return line
+	}
+	return ""
+}
+
+// tsvField replaces the characters that would break a TSV row.
+func tsvField(s string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+}
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+func setupStore(t *testing.T) (store.Store, int64) {
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { st.Close() })
+
+	storeRecord, err := st.CreateStore("test", "/repo", store.ProviderOllama, "model", 3)
+	require.NoError(t, err)
+
+	files := []struct {
+		path    string
+		content string
+		vector  []float32
+	}{
+		{"main.go", "\npackage main\tfunc main() {}", []float32{1, 0, 0.5}},
+		{"internal/api/server.py", "def serve():\n    pass", []float32{0, 1, 0.25}},
+	}
+	for _, f := range files {
+		err := st.UpsertFile(storeRecord.ID, store.FileInput{
+			ExternalID:   f.path,
+			Path:         "/repo/" + f.path,
+			RelativePath: f.path,
+			Hash:         f.path,
+		}, []store.Chunk{{Content: f.content, StartLine: 1, EndLine: 2}}, [][]float32{f.vector})
+		require.NoError(t, err)
+	}
+	return st, storeRecord.ID
+}
+
+func TestTSV(t *testing.T) {
+	st, storeID := setupStore(t)
+
+	var vectors, metadata bytes.Buffer
+	n, err := TSV(st, storeID, &vectors, &metadata)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	assert.Equal(t, "1\t0\t0.5\n0\t1\t0.25\n", vectors.String())
+
+	rows := strings.Split(strings.TrimSuffix(metadata.String(), "\n"), "\n")
+	require.Len(t, rows, 3)
+	assert.Equal(t, strings.Join(metadataHeader, "\t"), rows[0])
+	assert.Equal(t, "main.go:1-2\tmain.go\t.\tgo\t1\t2\tpackage main func main() {}", rows[1])
+	assert.Equal(t, "internal/api/server.py:1-2\tinternal/api/server.py\tinternal\tpython\t1\t2\tdef serve():", rows[2])
+	for _, row := range rows {
+		assert.Len(t, strings.Split(row, "\t"), len(metadataHeader))
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	st, storeID := setupStore(t)
+
+	var out bytes.Buffer
+	n, err := JSONL(st, storeID, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var c Chunk
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &c))
+	assert.Equal(t, "internal/api/server.py", c.Path)
+	assert.Equal(t, []float32{0, 1, 0.25}, c.Embedding)
+}
+
+func TestPreview(t *testing.T) {
+	assert.Equal(t, "", preview("\n  \n"))
+	assert.Equal(t, "first", preview("\n  first  \nsecond"))
+	long := strings.Repeat("é", previewLength+5)
+	assert.Equal(t, strings.Repeat("é", previewLength)+"...", preview(long))
+}
@@ -0,0 +1,181 @@
+// Package fallback tries a request with each of an ordered list of
+// providers until one can serve it, skipping providers that recently
+// failed.
+package fallback
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/models"
+)
+
+// DefaultCooldown is how long a provider is skipped after it could not be
+// reached.
+const DefaultCooldown = 30 * time.Second
+
+// ShouldFallback reports whether err means the provider could not serve the
+// request at all, so the next one should be tried: it could not be reached,
+// timed out, or does not have the model. Errors the next provider would
+// most likely repeat, such as a rejected request, and cancellation by the
+// caller are not.
+func ShouldFallback(err error) bool {
+	var final *finalError
+	switch {
+	case err == nil, errors.As(err, &final), errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, models.ErrMissingModel), errors.Is(err, context.DeadlineExceeded):
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Final marks err as one that must not fall back, such as a failure after a
+// stream has started sending content.
+func Final(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &finalError{err}
+}
+
+// finalError is an error that ShouldFallback rejects.
+type finalError struct {
+	err error
+}
+
+func (e *finalError) Error() string { return e.err.Error() }
+func (e *finalError) Unwrap() error { return e.err }
+
+// Breaker is a circuit breaker for one provider. After a failure that
+// ShouldFallback accepts it opens, and the provider is skipped until the
+// cooldown has passed. The zero value uses DefaultCooldown.
+type Breaker struct {
+	Cooldown time.Duration
+
+	mu        sync.Mutex
+	openUntil time.Time
+}
+
+// Open reports whether the provider is being skipped.
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().Before(b.openUntil)
+}
+
+// Record updates the breaker with the result of a request, returning true
+// if it opened.
+func (b *Breaker) Record(err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !ShouldFallback(err) {
+		b.openUntil = time.Time{}
+		return false
+	}
+	cooldown := b.Cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultCooldown
+	}
+	b.openUntil = time.Now().Add(cooldown)
+	return true
+}
+
+// Chain is an ordered list of services for the same kind of request, each
+// with its own Breaker.
+type Chain[S any] struct {
+	names    []string
+	services []S
+	breakers []*Breaker
+	cooldown time.Duration
+
+	mu     sync.Mutex
+	active int
+}
+
+// NewChain returns an empty chain whose breakers open for cooldown, or
+// DefaultCooldown if it is zero.
+func NewChain[S any](cooldown time.Duration) *Chain[S] {
+	return &Chain[S]{cooldown: cooldown}
+}
+
+// Add appends a service to the chain under a name used in log messages.
+func (c *Chain[S]) Add(name string, svc S) {
+	c.names = append(c.names, name)
+	c.services = append(c.services, svc)
+	c.breakers = append(c.breakers, &Breaker{Cooldown: c.cooldown})
+}
+
+// Len returns the number of services in the chain.
+func (c *Chain[S]) Len() int {
+	return len(c.services)
+}
+
+// Services returns the services in the chain, in order.
+func (c *Chain[S]) Services() []S {
+	return append([]S(nil), c.services...)
+}
+
+// Active returns the service that served the last request, or the first
+// one before any request.
+func (c *Chain[S]) Active() S {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.services[c.active]
+}
+
+// Do calls fn with each service in turn until one succeeds or fails with an
+// error that ShouldFallback rejects, and returns its result. Services whose
+// breaker is open are skipped, unless every one's is, in which case each is
+// tried again. The last error is returned if no service succeeds.
+//
+// A failure after ctx, the context fn's requests are made with, is done is
+// returned at once: the caller ran out of time or gave up, which says
+// nothing about the provider, so it is neither counted against it nor
+// retried with the next one.
+func Do[S, T any](ctx context.Context, c *Chain[S], fn func(S) (T, error)) (T, error) {
+	var (
+		result T
+		err    error
+	)
+	order := c.order()
+	for n, i := range order {
+		result, err = fn(c.services[i])
+		if err != nil && ctx.Err() != nil {
+			return result, err
+		}
+		if c.breakers[i].Record(err) && n < len(order)-1 {
+			log.Warn("Provider unavailable, falling back to the next one",
+				"provider", c.names[i], "next", c.names[order[n+1]], "error", err)
+		}
+		if !ShouldFallback(err) {
+			c.mu.Lock()
+			c.active = i
+			c.mu.Unlock()
+			return result, err
+		}
+	}
+	return result, err
+}
+
+// order returns the indexes of the services to try, in order.
+func (c *Chain[S]) order() []int {
+	var closed, all []int
+	for i, b := range c.breakers {
+		all = append(all, i)
+		if !b.Open() {
+			closed = append(closed, i)
+		}
+	}
+	if len(closed) == 0 {
+		return all
+	}
+	return closed
+}
@@ -0,0 +1,109 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nickcecere/lgrep/internal/models"
+)
+
+func TestShouldFallback(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"connection refused", fmt.Errorf("failed to make request: %w", refused), true},
+		{"missing model", models.MissingModelError("llama2", "llm.ollama.auto_pull"), true},
+		{"timeout", fmt.Errorf("request: %w", context.DeadlineExceeded), true},
+		{"canceled", fmt.Errorf("request: %w", context.Canceled), false},
+		{"rejected request", errors.New("status 401: invalid api key"), false},
+		{"final", Final(refused), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ShouldFallback(tt.err))
+		})
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	b := &Breaker{Cooldown: time.Hour}
+	assert.False(t, b.Open())
+
+	assert.False(t, b.Record(errors.New("status 500")))
+	assert.False(t, b.Open())
+
+	assert.True(t, b.Record(context.DeadlineExceeded))
+	assert.True(t, b.Open())
+
+	b.Record(nil)
+	assert.False(t, b.Open())
+}
+
+func TestDo(t *testing.T) {
+	down := fmt.Errorf("request: %w", context.DeadlineExceeded)
+
+	chain := NewChain[string](time.Hour)
+	chain.Add("first", "first")
+	chain.Add("second", "second")
+	assert.Equal(t, "first", chain.Active())
+
+	var tried []string
+	try := func(failing map[string]error) (string, error) {
+		tried = nil
+		return Do(context.Background(), chain, func(svc string) (string, error) {
+			tried = append(tried, svc)
+			return svc, failing[svc]
+		})
+	}
+
+	// The first provider fails and is skipped afterwards
+	got, err := try(map[string]error{"first": down})
+	assert.NoError(t, err)
+	assert.Equal(t, "second", got)
+	assert.Equal(t, "second", chain.Active())
+	assert.Equal(t, []string{"first", "second"}, tried)
+
+	_, err = try(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"second"}, tried)
+
+	// Errors that are not worth falling back on are returned at once
+	rejected := errors.New("status 400")
+	_, err = try(map[string]error{"second": rejected})
+	assert.Equal(t, rejected, err)
+	assert.Equal(t, []string{"second"}, tried)
+
+	// With every breaker open, each provider is tried again
+	_, err = try(map[string]error{"second": down})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = try(map[string]error{"second": down})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first"}, tried)
+	assert.Equal(t, []string{"first", "second"}, chain.Services())
+
+	// A caller running out of time does not count against the provider
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+	tried = nil
+	_, err = Do(ctx, chain, func(svc string) (string, error) {
+		tried = append(tried, svc)
+		return svc, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"first"}, tried)
+	_, err = try(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first"}, tried)
+}
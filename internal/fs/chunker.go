@@ -3,7 +3,9 @@ package fs
 import (
 	"bufio"
 	"io"
+	"path/filepath"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -37,94 +39,212 @@ func (c *TextChunker) Chunk(content string, filename string) []Chunk {
 	// Check if we should use code-aware chunking
 	lang := DetectLanguage(filename)
 	if SupportsCodeChunking(lang) {
-		return c.chunkCode(content, lang)
+		chunks := c.chunkCode(content, lang)
+		for i := range chunks {
+			chunks[i].Symbols = ExtractSymbols(chunks[i].Content, lang)
+		}
+		return chunks
 	}
 
 	return c.chunkText(content)
 }
 
+// NameChunk returns the only chunk of a binary file indexed by name: its
+// path, followed by the words of the path, so that a search such as "logo
+// asset" finds assets/logo.png.
+func NameChunk(relPath string) Chunk {
+	ext := filepath.Ext(relPath)
+	words := strings.FieldsFunc(strings.TrimSuffix(relPath, ext), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	kind := "binary file"
+	if ext != "" {
+		kind = "binary " + strings.ToLower(ext[1:]) + " file"
+	}
+	return Chunk{
+		Content:   relPath + "\n" + kind + ": " + strings.Join(words, " "),
+		StartLine: 1,
+		EndLine:   1,
+	}
+}
+
 // ChunkReader reads content from a reader and chunks it.
 func (c *TextChunker) ChunkReader(r io.Reader, filename string) ([]Chunk, error) {
-	var content strings.Builder
-	scanner := bufio.NewScanner(r)
-	// Increase buffer size for large lines
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
-
-	for scanner.Scan() {
-		content.WriteString(scanner.Text())
-		content.WriteString("\n")
+	var chunks []Chunk
+	err := c.ChunkStream(r, filename, func(chunk Chunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return chunks, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+// ChunkStream reads content from a reader and passes each chunk to emit as
+// soon as it is complete, stopping at the first error emit returns. Text is
+// chunked while it is read, so memory use is bounded by the chunk size.
+// Code-aware chunking needs the whole file to find definition boundaries, so
+// code files are read in full first. The chunks are the same as Chunk would
+// produce for the full content.
+func (c *TextChunker) ChunkStream(r io.Reader, filename string, emit func(Chunk) error) error {
+	if SupportsCodeChunking(DetectLanguage(filename)) {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		for _, chunk := range c.Chunk(string(content), filename) {
+			if err := emit(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	return c.Chunk(content.String(), filename), nil
+	br := bufio.NewReader(r)
+	splitter := newTextSplitter(c, emit)
+	empty := true
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line != "" {
+			empty = false
+		}
+		if empty && err == io.EOF {
+			return nil
+		}
+
+		// Split lines exactly like strings.Split, so content ending in a
+		// newline has a final empty line
+		if addErr := splitter.addLine(strings.TrimSuffix(line, "\n")); addErr != nil {
+			return addErr
+		}
+		if err == io.EOF {
+			return splitter.finish()
+		}
+	}
 }
 
 // chunkText performs simple text chunking with overlap.
 func (c *TextChunker) chunkText(content string) []Chunk {
 	var chunks []Chunk
-	lines := strings.Split(content, "\n")
+	splitter := newTextSplitter(c, func(chunk Chunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
 
-	chunkStart := 0
-	chunkStartChar := 0
-	currentSize := 0
-	var currentLines []string
+	for _, line := range strings.Split(content, "\n") {
+		_ = splitter.addLine(line)
+	}
+	_ = splitter.finish()
 
-	for lineNum, line := range lines {
-		lineLen := utf8.RuneCountInString(line) + 1 // +1 for newline
+	return chunks
+}
 
-		// Check if adding this line would exceed chunk size
-		if currentSize+lineLen > c.opts.ChunkSize && len(currentLines) > 0 {
-			// Create chunk from current lines
-			chunks = append(chunks, Chunk{
-				Content:    strings.Join(currentLines, "\n"),
-				StartLine:  chunkStart + 1, // 1-indexed
-				EndLine:    chunkStart + len(currentLines),
-				StartChar:  chunkStartChar,
-				EndChar:    chunkStartChar + currentSize - 1,
-				ChunkIndex: len(chunks),
-			})
+// textSplitter builds overlapping text chunks one line at a time. The most
+// recent chunk is held back until the next one starts, because a short final
+// chunk is merged into the one before it.
+type textSplitter struct {
+	c    *TextChunker
+	emit func(Chunk) error
+
+	held  *Chunk
+	count int
+
+	lineNum        int
+	chunkStart     int
+	chunkStartChar int
+	currentSize    int
+	currentLines   []string
+}
 
-			// Calculate overlap
-			overlapLines, overlapSize := c.calculateOverlap(currentLines)
+// newTextSplitter creates a splitter that passes finished chunks to emit.
+func newTextSplitter(c *TextChunker, emit func(Chunk) error) *textSplitter {
+	return &textSplitter{c: c, emit: emit}
+}
 
-			// Start new chunk with overlap
-			currentLines = make([]string, len(overlapLines))
-			copy(currentLines, overlapLines)
-			chunkStart = lineNum - len(overlapLines)
-			chunkStartChar = chunkStartChar + currentSize - overlapSize
-			currentSize = overlapSize
+// addLine adds the next line of content.
+func (s *textSplitter) addLine(line string) error {
+	lineLen := utf8.RuneCountInString(line) + 1 // +1 for newline
+
+	// Check if adding this line would exceed chunk size
+	if s.currentSize+lineLen > s.c.opts.ChunkSize && len(s.currentLines) > 0 {
+		// Create chunk from current lines
+		err := s.push(Chunk{
+			Content:   strings.Join(s.currentLines, "\n"),
+			StartLine: s.chunkStart + 1, // 1-indexed
+			EndLine:   s.chunkStart + len(s.currentLines),
+			StartChar: s.chunkStartChar,
+			EndChar:   s.chunkStartChar + s.currentSize - 1,
+		})
+		if err != nil {
+			return err
 		}
 
-		currentLines = append(currentLines, line)
-		currentSize += lineLen
+		// Calculate overlap
+		overlapLines, overlapSize := s.c.calculateOverlap(s.currentLines)
+
+		// Start new chunk with overlap
+		s.currentLines = make([]string, len(overlapLines))
+		copy(s.currentLines, overlapLines)
+		s.chunkStart = s.lineNum - len(overlapLines)
+		s.chunkStartChar = s.chunkStartChar + s.currentSize - overlapSize
+		s.currentSize = overlapSize
 	}
 
-	// Add final chunk
-	if len(currentLines) > 0 {
-		content := strings.Join(currentLines, "\n")
+	s.currentLines = append(s.currentLines, line)
+	s.currentSize += lineLen
+	s.lineNum++
+	return nil
+}
+
+// finish emits the remaining content.
+func (s *textSplitter) finish() error {
+	if len(s.currentLines) > 0 {
+		content := strings.Join(s.currentLines, "\n")
 		// Skip chunks that are too small (unless it's the only chunk)
-		if len(chunks) == 0 || utf8.RuneCountInString(content) >= c.opts.MinChunkSize {
-			chunks = append(chunks, Chunk{
-				Content:    content,
-				StartLine:  chunkStart + 1,
-				EndLine:    chunkStart + len(currentLines),
-				StartChar:  chunkStartChar,
-				EndChar:    chunkStartChar + currentSize - 1,
-				ChunkIndex: len(chunks),
+		if s.held == nil || utf8.RuneCountInString(content) >= s.c.opts.MinChunkSize {
+			err := s.push(Chunk{
+				Content:   content,
+				StartLine: s.chunkStart + 1,
+				EndLine:   s.chunkStart + len(s.currentLines),
+				StartChar: s.chunkStartChar,
+				EndChar:   s.chunkStartChar + s.currentSize - 1,
 			})
-		} else if len(chunks) > 0 {
+			if err != nil {
+				return err
+			}
+		} else {
 			// Merge with previous chunk
-			prev := &chunks[len(chunks)-1]
-			prev.Content += "\n" + content
-			prev.EndLine = chunkStart + len(currentLines)
-			prev.EndChar = chunkStartChar + currentSize - 1
+			s.held.Content += "\n" + content
+			s.held.EndLine = s.chunkStart + len(s.currentLines)
+			s.held.EndChar = s.chunkStartChar + s.currentSize - 1
 		}
+		s.currentLines = nil
 	}
 
-	return chunks
+	if s.held == nil {
+		return nil
+	}
+	held := *s.held
+	s.held = nil
+	return s.emit(held)
+}
+
+// push holds chunk back and emits the previously held chunk.
+func (s *textSplitter) push(chunk Chunk) error {
+	chunk.ChunkIndex = s.count
+	s.count++
+
+	previous := s.held
+	s.held = &chunk
+	if previous != nil {
+		return s.emit(*previous)
+	}
+	return nil
 }
 
 // calculateOverlap determines how many lines to include in the overlap.
@@ -1,6 +1,8 @@
 package fs
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -81,16 +83,16 @@ func TestHashContent(t *testing.T) {
 }
 
 // TestIsBinaryContent tests binary detection.
-func TestIsBinaryContent(t *testing.T) {
+func TestIsBinary(t *testing.T) {
 	// Text content
-	assert.False(t, isBinaryContent([]byte("Hello, World!\n")))
-	assert.False(t, isBinaryContent([]byte("line1\nline2\tindented")))
+	assert.False(t, IsBinary([]byte("Hello, World!\n")))
+	assert.False(t, IsBinary([]byte("line1\nline2\tindented")))
 
 	// Binary content (null bytes)
-	assert.True(t, isBinaryContent([]byte("hello\x00world")))
+	assert.True(t, IsBinary([]byte("hello\x00world")))
 
 	// Empty content
-	assert.False(t, isBinaryContent([]byte{}))
+	assert.False(t, IsBinary([]byte{}))
 }
 
 // TestTextChunker tests basic text chunking.
@@ -306,6 +308,27 @@ func TestFileWalker(t *testing.T) {
 		for _, f := range found {
 			assert.True(t, strings.HasSuffix(f, ".md"), "unexpected file: %s", f)
 		}
+		assert.Greater(t, walker.Stats().SkippedFilter, 0)
+	})
+
+	t.Run("respects language filter", func(t *testing.T) {
+		walker, err := NewFileWalker(WalkOptions{
+			Root:             tmpDir,
+			ExcludeLanguages: []string{"go"},
+		})
+		require.NoError(t, err)
+
+		var found []string
+		err = walker.Walk(func(info FileInfo) error {
+			found = append(found, info.RelPath)
+			return nil
+		})
+		require.NoError(t, err)
+
+		assert.Contains(t, found, "README.md")
+		for _, f := range found {
+			assert.False(t, strings.HasSuffix(f, ".go"), "unexpected file: %s", f)
+		}
 	})
 
 	t.Run("respects max file count", func(t *testing.T) {
@@ -323,6 +346,7 @@ func TestFileWalker(t *testing.T) {
 		require.NoError(t, err)
 
 		assert.Equal(t, 2, count)
+		assert.True(t, walker.Stats().LimitReached)
 	})
 
 	t.Run("includes hidden files when configured", func(t *testing.T) {
@@ -357,6 +381,8 @@ func TestFileWalker(t *testing.T) {
 		stats := walker.Stats()
 		assert.Greater(t, stats.FilesFound, 0)
 		assert.Greater(t, stats.TotalBytes, int64(0))
+		assert.False(t, stats.LimitReached)
+		assert.Equal(t, stats.FilesSkipped, stats.SkippedIgnored+stats.SkippedBinary+stats.SkippedFilter+stats.SkippedSize)
 	})
 
 	t.Run("computes file hashes", func(t *testing.T) {
@@ -398,6 +424,112 @@ func TestFileWalker(t *testing.T) {
 	})
 }
 
+// TestFileWalkerBinary tests binary files, text overrides and the minimum
+// file size.
+func TestFileWalkerBinary(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"main.go":              "package main\n\nfunc main() {}\n",
+		"empty.py":             "",
+		"assets/logo.png":      "\x89PNG\r\n\x1a\n\x00\x00",
+		"assets/icon.svg":      "<svg></svg>\n",
+		"data/blob.bin":        "abc\x00def",
+		"assets/font.woff2":    "wOF2\x00",
+		"node_modules/pic.png": "\x89PNG\x00",
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(tmpDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+	}
+
+	walk := func(opts WalkOptions) map[string]FileInfo {
+		opts.Root = tmpDir
+		walker, err := NewFileWalker(opts)
+		require.NoError(t, err)
+		found := make(map[string]FileInfo)
+		require.NoError(t, walker.Walk(func(info FileInfo) error {
+			found[filepath.ToSlash(info.RelPath)] = info
+			return nil
+		}))
+		return found
+	}
+
+	t.Run("skips binary files by default", func(t *testing.T) {
+		found := walk(WalkOptions{})
+		assert.Contains(t, found, "main.go")
+		assert.Contains(t, found, "empty.py")
+		assert.NotContains(t, found, "assets/logo.png")
+		assert.NotContains(t, found, "assets/icon.svg")
+		assert.NotContains(t, found, "data/blob.bin")
+	})
+
+	t.Run("includes binary names", func(t *testing.T) {
+		found := walk(WalkOptions{IncludeBinaryNames: true, MaxFileSize: 4})
+		assert.True(t, found["assets/logo.png"].Binary)
+		assert.True(t, found["data/blob.bin"].Binary, "detected by content")
+		assert.False(t, found["empty.py"].Binary)
+		assert.NotContains(t, found, "main.go", "over the size limit")
+		assert.NotContains(t, found, "node_modules/pic.png", "still ignored")
+	})
+
+	t.Run("reads text extensions as text", func(t *testing.T) {
+		found := walk(WalkOptions{TextExtensions: []string{"svg", ".BIN"}})
+		assert.Contains(t, found, "assets/icon.svg")
+		assert.Contains(t, found, "data/blob.bin")
+		assert.False(t, found["assets/icon.svg"].Binary)
+		assert.NotContains(t, found, "assets/font.woff2")
+	})
+
+	t.Run("skips files under the minimum size", func(t *testing.T) {
+		found := walk(WalkOptions{MinFileSize: 1})
+		assert.Contains(t, found, "main.go")
+		assert.NotContains(t, found, "empty.py")
+	})
+}
+
+// TestNameChunk tests the chunk of a binary file indexed by name.
+func TestNameChunk(t *testing.T) {
+	c := NameChunk("web/assets/company-logo_dark.PNG")
+	assert.Equal(t, "web/assets/company-logo_dark.PNG\nbinary png file: web assets company logo dark", c.Content)
+	assert.Equal(t, 1, c.StartLine)
+	assert.Equal(t, 1, c.EndLine)
+
+	assert.Equal(t, "LICENSE\nbinary file: LICENSE", NameChunk("LICENSE").Content)
+}
+
+// TestLanguageFilter tests selecting files by language.
+func TestLanguageFilter(t *testing.T) {
+	exts, err := LanguageExtensions([]string{"Go", " sql"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{".go", ".sql"}, exts)
+
+	_, err = LanguageExtensions([]string{"golang"})
+	assert.ErrorContains(t, err, `unknown language "golang"`)
+
+	filter, err := NewLanguageFilter(nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+	assert.True(t, filter.Match("main.go"))
+
+	filter, err = NewLanguageFilter([]string{"go", "sql"}, nil)
+	require.NoError(t, err)
+	assert.True(t, filter.Match("cmd/main.go"))
+	assert.True(t, filter.Match("schema.SQL"))
+	assert.False(t, filter.Match("app.ts"))
+	assert.False(t, filter.Match("Makefile"))
+
+	filter, err = NewLanguageFilter(nil, []string{"markdown"})
+	require.NoError(t, err)
+	assert.True(t, filter.Match("main.go"))
+	assert.True(t, filter.Match("Makefile"))
+	assert.False(t, filter.Match("README.md"))
+
+	// The walker rejects unknown names
+	_, err = NewFileWalker(WalkOptions{Root: t.TempDir(), Languages: []string{"cobol"}})
+	assert.ErrorContains(t, err, "invalid language filter")
+}
+
 // TestFileWalkerErrors tests error handling.
 func TestFileWalkerErrors(t *testing.T) {
 	t.Run("non-existent root", func(t *testing.T) {
@@ -435,6 +567,209 @@ func TestChunkerReader(t *testing.T) {
 	assert.Contains(t, chunks[0].Content, "line 1")
 }
 
+// TestChunkStreamMatchesChunk tests that streaming produces the same chunks
+// as chunking the whole content.
+func TestChunkStreamMatchesChunk(t *testing.T) {
+	chunker := NewTextChunker(ChunkOptions{ChunkSize: 100, ChunkOverlap: 20, MinChunkSize: 30})
+
+	var long strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&long, "line %d of a long text file\n", i)
+	}
+
+	contents := map[string]string{
+		"empty":           "",
+		"newline":         "\n",
+		"single":          "just one line",
+		"long":            long.String(),
+		"no trailing":     strings.TrimSuffix(long.String(), "\n"),
+		"short remainder": long.String() + "tail",
+	}
+
+	for name, content := range contents {
+		t.Run(name, func(t *testing.T) {
+			want := chunker.Chunk(content, "notes.txt")
+
+			var got []Chunk
+			err := chunker.ChunkStream(strings.NewReader(content), "notes.txt", func(c Chunk) error {
+				got = append(got, c)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+
+	// Errors from emit stop the stream
+	stop := errors.New("stop")
+	calls := 0
+	err := chunker.ChunkStream(strings.NewReader(long.String()), "notes.txt", func(c Chunk) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+// TestExtractSymbols tests finding the names defined in code.
+func TestExtractSymbols(t *testing.T) {
+	tests := []struct {
+		lang    string
+		content string
+		want    []string
+	}{
+		{LangGo, "package main\n\nfunc (s *Server) Start() error {\n\treturn nil\n}\n\ntype Config struct{}\nfunc main() {}", []string{"Start", "Config", "main"}},
+		{LangPython, "class Parser:\n    def parse(self):\n        pass\n\nasync def fetch():\n    pass", []string{"Parser", "parse", "fetch"}},
+		{LangTypeScript, "export interface Options {}\nexport const DEFAULT = 1;\nexport async function load() {}\nif (x) {}", []string{"Options", "DEFAULT", "load"}},
+		{LangRust, "pub(crate) struct Index;\npub async fn build() {}\nimpl Index {}", []string{"Index", "build"}},
+		{LangC, "static int count_lines(const char *s) {\n\tif (s) {\n\t\treturn 0;\n\t}\n}\nint declared(void);", []string{"count_lines"}},
+		{LangRuby, "module Store\n  def self.open?\n  end\nend", []string{"Store", "open?"}},
+		{LangMarkdown, "# func main()", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExtractSymbols(tt.content, tt.lang))
+		})
+	}
+
+	assert.Equal(t, 2, SymbolLine("package main\n\nfunc main() {}", LangGo, "main"))
+	assert.Equal(t, -1, SymbolLine("main()", LangGo, "main"))
+
+	// Code chunks carry the symbols they define
+	chunker := NewTextChunker(DefaultChunkOptions())
+	chunks := chunker.Chunk("package main\n\nfunc main() {}\n", "main.go")
+	require.NotEmpty(t, chunks)
+	assert.Contains(t, chunks[len(chunks)-1].Symbols, "main")
+}
+
+// TestExtractImports tests finding the imports of code.
+func TestExtractImports(t *testing.T) {
+	tests := []struct {
+		name    string
+		lang    string
+		relPath string
+		content string
+		want    []string
+	}{
+		{"go", LangGo, "cmd/main.go", "package main\n\nimport \"fmt\"\n\nimport (\n\t\"os\"\n\tst \"github.com/x/app/internal/store\"\n)\n", []string{"fmt", "os", "github.com/x/app/internal/store"}},
+		{"typescript", LangTypeScript, "src/app.ts", "import { a } from './util';\nimport React from 'react';\nconst b = require('../lib/b.js');", []string{"src/util", "lib/b"}},
+		{"python", LangPython, "pkg/sub/mod.py", "import os, json\nfrom . import helpers\nfrom ..core import Engine as E, run\nfrom app.models import User", []string{"os", "json", "pkg/sub/helpers", "pkg/core/Engine", "pkg/core/run", "app/models/User"}},
+		{"c", LangC, "src/main.c", "#include <stdio.h>\n#include \"util.h\"\n#include \"../include/api.h\"\n#include \"net/http.h\"", []string{"src/util", "include/api", "src/net/http", "net/http"}},
+		{"rust", LangRust, "src/main.rs", "mod config;\nuse crate::store::Index;\nuse std::io;", []string{"src/config", "store/Index"}},
+		{"java", LangJava, "A.java", "import com.x.store.Index;\nimport com.x.util.*;", []string{"com/x/store/Index", "com/x/util"}},
+		{"outside root", LangTypeScript, "app.ts", "import x from '../outside';", nil},
+		{"markdown", LangMarkdown, "README.md", "import x from './y'", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExtractImports(tt.content, tt.lang, tt.relPath))
+		})
+	}
+}
+
+// TestIsGenerated tests detecting vendored and generated files.
+func TestIsGenerated(t *testing.T) {
+	tests := []struct {
+		name    string
+		relPath string
+		content string
+		want    bool
+	}{
+		{"source", "internal/store/sqlite.go", "package store\n", false},
+		{"vendor dir", "vendor/github.com/x/y/y.go", "package y\n", true},
+		{"nested node_modules", "web/node_modules/react/index.js", "", true},
+		{"windows path", "third_party\\lib\\lib.c", "", true},
+		{"vendor in name only", "internal/vendored.go", "package internal\n", false},
+		{"protobuf", "api/v1/service.pb.go", "package v1\n", true},
+		{"minified", "static/app.min.js", "", true},
+		{"go header", "internal/mocks.go", "// Code generated by mockery. DO NOT EDIT.\n\npackage mocks\n", true},
+		{"at-generated", "src/schema.ts", "/**\n * @generated\n */\n", true},
+		{"go header with CRLF", "internal/mocks.go", "// Code generated by mockery. DO NOT EDIT.\r\n\r\npackage mocks\r\n", true},
+		{"go marker past header", "notes.go", "package notes\n\n\n\n\n// Code generated by hand. DO NOT EDIT.\n", false},
+		{"go marker mid-line", "notes.go", "// The line \"// Code generated by x. DO NOT EDIT.\" marks output\n", false},
+		{"do not edit", "config.go", "// Settings below; do not edit by hand.\npackage config\n", false},
+		{"auto-generated mention", "docs.go", "// Package docs serves the auto-generated API reference.\npackage docs\n", false},
+		{"at-generated in an address", "CONTRIBUTORS.md", "mail me@generated.dev\n", false},
+		{"source map", "static/app.js.map", "{}", true},
+		{"other map", "world/level.map", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsGenerated(tt.relPath, []byte(tt.content)))
+		})
+	}
+}
+
+// TestImportResolver tests resolving imports to files.
+func TestImportResolver(t *testing.T) {
+	files := []string{
+		"src/util.ts",                          // 0
+		"src/lib/index.ts",                     // 1
+		"internal/store/sqlite.go",             // 2
+		"internal/store/schema.go",             // 3
+		"src/main/java/com/x/store/Index.java", // 4
+		"lib/app/models.py",                    // 5
+		"src/utility.ts",                       // 6
+		"main.go",                              // 7
+		"internal/errors/errors.go",            // 8
+		"internal/codec/json.go",               // 9
+		"tools/context.go",                     // 10
+		"tools/gen/gen.go",                     // 11
+	}
+	r := NewImportResolver(files, []Module{
+		{Path: "github.com/x/app/tools", Dir: "tools"},
+		{Path: "github.com/x/app"},
+	})
+
+	tests := []struct {
+		target string
+		want   []int
+	}{
+		{"src/util", []int{0}},
+		{"src/lib", []int{1}},
+		{"github.com/x/app/internal/store", []int{2, 3}},
+		{"github.com/x/app/internal/search", nil},
+		{"github.com/x/app", []int{7}},
+		{"github.com/x/app/tools/gen", []int{11}},
+		{"com/x/store/Index", []int{4}},
+		{"app/models", []int{5}},
+		{"app/models/User", []int{5}},
+		{"fmt", nil},
+		{"main", []int{7}},
+
+		// The standard library and other modules do not match files or
+		// directories of the same name
+		{"context", nil},
+		{"errors", nil},
+		{"encoding/json", nil},
+		{"github.com/y/lib/store", nil},
+		{"github.com/x/application/internal/store", nil},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, r.Resolve(tt.target), "import %s", tt.target)
+	}
+}
+
+// TestReadModules tests finding the Go modules of a project.
+func TestReadModules(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "tools"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("module github.com/x/app\n\ngo 1.23\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "tools", "go.mod"), []byte("// Tools\nmodule \"github.com/x/app/tools\"\n"), 0644))
+
+	assert.Equal(t, []Module{
+		{Path: "github.com/x/app/tools", Dir: "tools"},
+		{Path: "github.com/x/app"},
+	}, ReadModules(root, []string{"main.go", "tools/gen.go"}))
+
+	// Modules are only looked for where the files are
+	assert.Equal(t, []Module{{Path: "github.com/x/app"}}, ReadModules(root, []string{"main.go"}))
+}
+
 // TestDefaultOptions tests default options.
 func TestDefaultOptions(t *testing.T) {
 	walkOpts := DefaultWalkOptions()
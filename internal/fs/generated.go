@@ -0,0 +1,85 @@
+package fs
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// vendorDirs are the directories that hold third-party code, in the manner
+// of GitHub Linguist's vendor list.
+var vendorDirs = map[string]bool{
+	"vendor":           true,
+	"third_party":      true,
+	"third-party":      true,
+	"node_modules":     true,
+	"bower_components": true,
+	"Godeps":           true,
+	"Pods":             true,
+	"Carthage":         true,
+}
+
+// generatedSuffixes end the names of files written by code generators and
+// minifiers.
+var generatedSuffixes = []string{
+	".pb.go", "_pb2.py", ".pb.cc", ".pb.h",
+	"_generated.go", ".gen.go",
+	".designer.cs",
+	".g.dart", ".freezed.dart",
+	".min.js", ".min.css", ".js.map", ".css.map",
+}
+
+// generatedMarkers match the lines that generators put at the top of their
+// output: Go's "// Code generated ... DO NOT EDIT." convention and the
+// @generated tag. Each is matched against whole lines of the header, so a
+// hand-written file that merely mentions them is not flagged.
+var generatedMarkers = []*regexp.Regexp{
+	regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`),
+	regexp.MustCompile(`(^|[^\w@])@generated\b`),
+}
+
+// generatedHeaderLines is how many lines at the top of a file are searched
+// for a generated marker, and generatedHeaderSize caps the bytes searched
+// when those lines are long.
+const (
+	generatedHeaderLines = 5
+	generatedHeaderSize  = 4096
+)
+
+// IsGenerated reports whether the file at relPath is vendored or generated
+// code: it is under a vendor directory, has the name of a generator's
+// output, or carries a generated marker in its first lines.
+func IsGenerated(relPath string, content []byte) bool {
+	relPath = strings.ReplaceAll(relPath, "\\", "/")
+	for _, dir := range strings.Split(path.Dir(relPath), "/") {
+		if vendorDirs[dir] {
+			return true
+		}
+	}
+
+	name := strings.ToLower(path.Base(relPath))
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	header := content
+	if len(header) > generatedHeaderSize {
+		header = header[:generatedHeaderSize]
+	}
+	lines := bytes.SplitN(header, []byte("\n"), generatedHeaderLines+1)
+	for i, line := range lines {
+		if i == generatedHeaderLines {
+			break
+		}
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		for _, marker := range generatedMarkers {
+			if marker.Match(line) {
+				return true
+			}
+		}
+	}
+	return false
+}
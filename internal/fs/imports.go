@@ -0,0 +1,326 @@
+package fs
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Import patterns. The first capture group of each is the imported path or
+// module.
+var (
+	goImportLine    = regexp.MustCompile(`^import\s+(?:[\w.]+\s+)?"([^"]+)"`)
+	goImportSpec    = regexp.MustCompile(`^\s*(?:[\w.]+\s+)?"([^"]+)"`)
+	jsImport        = regexp.MustCompile(`(?:\bfrom|^\s*import|\brequire\(|\bimport\()\s*['"]([^'"]+)['"]`)
+	pyFromImport    = regexp.MustCompile(`^\s*from\s+(\.*)([\w.]*)\s+import\s+\(?([\w\s,]+)`)
+	pyImport        = regexp.MustCompile(`^\s*import\s+([\w.]+(?:\s*,\s*[\w.]+)*)`)
+	cInclude        = regexp.MustCompile(`^\s*#\s*(?:include|import)\s*"([^"]+)"`)
+	rustMod         = regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?mod\s+(\w+)\s*;`)
+	rustUse         = regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?use\s+crate::([\w:]+)`)
+	jvmImport       = regexp.MustCompile(`^\s*import\s+(?:static\s+)?([\w.]+)`)
+	rubyRequire     = regexp.MustCompile(`^\s*require(_relative)?\s*\(?\s*['"]([^'"]+)['"]`)
+	phpUse          = regexp.MustCompile(`^\s*use\s+([\w\\]+)`)
+	phpRequire      = regexp.MustCompile(`^\s*(?:require|include)(?:_once)?\s*\(?\s*['"]([^'"]+)['"]`)
+	importSeparator = regexp.MustCompile(`\s*,\s*`)
+)
+
+// ExtractImports returns the files and packages that content, the file at
+// relPath, imports. Each import is given as a slash-separated path without a
+// file extension: relative imports are resolved against the file's
+// directory, module names such as Python's a.b are converted to a/b, and
+// other paths are kept as written. Use an ImportResolver to find the files
+// an import refers to. Packages from outside the project are included, since
+// they cannot be told apart from the project's own without its build files.
+func ExtractImports(content, lang, relPath string) []string {
+	dir := path.Dir(strings.ReplaceAll(relPath, "\\", "/"))
+	var raw []string
+
+	inGoBlock := false
+	for _, line := range strings.Split(content, "\n") {
+		switch lang {
+		case LangGo:
+			trimmed := strings.TrimSpace(line)
+			switch {
+			case inGoBlock && strings.HasPrefix(trimmed, ")"):
+				inGoBlock = false
+			case inGoBlock:
+				if m := goImportSpec.FindStringSubmatch(line); m != nil {
+					raw = append(raw, m[1])
+				}
+			case strings.HasPrefix(trimmed, "import ("):
+				inGoBlock = true
+			default:
+				if m := goImportLine.FindStringSubmatch(trimmed); m != nil {
+					raw = append(raw, m[1])
+				}
+			}
+
+		case LangTypeScript, LangJavaScript:
+			for _, m := range jsImport.FindAllStringSubmatch(line, -1) {
+				// Only relative imports can be files of the project
+				if strings.HasPrefix(m[1], ".") {
+					raw = append(raw, m[1])
+				}
+			}
+
+		case LangPython:
+			if m := pyFromImport.FindStringSubmatch(line); m != nil {
+				base := strings.TrimSuffix(pythonModule(m[1], m[2]), "/")
+				for _, name := range importSeparator.Split(strings.TrimSpace(m[3]), -1) {
+					// A name may be an item of the module rather than a
+					// submodule, so it is resolved like a module path
+					if fields := strings.Fields(name); len(fields) > 0 && fields[0] != "*" {
+						raw = append(raw, base+"/"+fields[0])
+					}
+				}
+			} else if m := pyImport.FindStringSubmatch(line); m != nil {
+				for _, name := range importSeparator.Split(m[1], -1) {
+					raw = append(raw, pythonModule("", name))
+				}
+			}
+
+		case LangC, LangCPP:
+			// Quoted includes are looked up next to the including file
+			// first, then in the include directories
+			if m := cInclude.FindStringSubmatch(line); m != nil {
+				if strings.HasPrefix(m[1], ".") {
+					raw = append(raw, m[1])
+				} else {
+					raw = append(raw, "./"+m[1])
+					if strings.Contains(m[1], "/") {
+						raw = append(raw, m[1])
+					}
+				}
+			}
+
+		case LangRust:
+			if m := rustMod.FindStringSubmatch(line); m != nil {
+				raw = append(raw, "./"+m[1])
+			} else if m := rustUse.FindStringSubmatch(line); m != nil {
+				raw = append(raw, strings.ReplaceAll(m[1], "::", "/"))
+			}
+
+		case LangJava, LangKotlin, LangScala:
+			if m := jvmImport.FindStringSubmatch(line); m != nil {
+				raw = append(raw, strings.ReplaceAll(strings.TrimSuffix(m[1], ".*"), ".", "/"))
+			}
+
+		case LangRuby:
+			if m := rubyRequire.FindStringSubmatch(line); m != nil {
+				target := m[2]
+				if m[1] != "" && !strings.HasPrefix(target, ".") {
+					target = "./" + target
+				}
+				raw = append(raw, target)
+			}
+
+		case LangPHP:
+			if m := phpUse.FindStringSubmatch(line); m != nil {
+				raw = append(raw, strings.ReplaceAll(m[1], "\\", "/"))
+			} else if m := phpRequire.FindStringSubmatch(line); m != nil {
+				raw = append(raw, m[1])
+			}
+		}
+	}
+
+	var imports []string
+	seen := make(map[string]bool)
+	for _, target := range raw {
+		target = normalizeImport(target, dir)
+		if target != "" && !seen[target] {
+			seen[target] = true
+			imports = append(imports, target)
+		}
+	}
+	return imports
+}
+
+// pythonModule converts a Python module name, with the leading dots of a
+// relative import, to a path.
+func pythonModule(dots, module string) string {
+	p := strings.ReplaceAll(module, ".", "/")
+	if dots == "" {
+		return p
+	}
+	return "./" + strings.Repeat("../", len(dots)-1) + p
+}
+
+// normalizeImport resolves a relative import against dir and removes a
+// source file extension.
+func normalizeImport(target, dir string) string {
+	if strings.HasPrefix(target, "./") || strings.HasPrefix(target, "../") {
+		target = path.Join(dir, target)
+		if strings.HasPrefix(target, "../") || target == ".." {
+			return ""
+		}
+	}
+	target = strings.TrimPrefix(path.Clean(target), "/")
+	if ext := path.Ext(target); ext != "" && DetectLanguage(target) != LangUnknown {
+		target = strings.TrimSuffix(target, ext)
+	}
+	if target == "." {
+		return ""
+	}
+	return target
+}
+
+// Module is a module of a project whose imports start with its path, such
+// as a Go module, and the directory of the project it is in.
+type Module struct {
+	Path string
+	Dir  string // Slash-separated and relative to the project root; "" for the root
+}
+
+// goModule matches the module directive of a go.mod file.
+var goModule = regexp.MustCompile(`(?m)^module\s+"?([^"\s]+)"?\s*$`)
+
+// ReadModules returns the Go modules of the project at root whose go.mod
+// files are in the root or a directory of the files at relPaths.
+func ReadModules(root string, relPaths []string) []Module {
+	dirs := map[string]bool{".": true}
+	for _, relPath := range relPaths {
+		dirs[path.Dir(strings.ReplaceAll(relPath, "\\", "/"))] = true
+	}
+
+	var modules []Module
+	for dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(dir), "go.mod"))
+		if err != nil {
+			continue
+		}
+		if m := goModule.FindSubmatch(data); m != nil {
+			modules = append(modules, Module{Path: string(m[1]), Dir: strings.TrimPrefix(dir, ".")})
+		}
+	}
+
+	// Longest path first, so nested modules take precedence
+	sort.Slice(modules, func(i, j int) bool { return len(modules[i].Path) > len(modules[j].Path) })
+	return modules
+}
+
+// ImportResolver finds the files that imports returned by ExtractImports
+// refer to among the files of a project.
+type ImportResolver struct {
+	modules []Module
+
+	// paths maps each file's path without its extension to the file, and
+	// stems every slash-separated suffix of it
+	paths map[string][]int
+	stems map[string][]int
+
+	// dirs maps each file's directory to the files in it, and dirSuffixes
+	// every slash-separated suffix of it
+	dirs        map[string][]int
+	dirSuffixes map[string][]int
+
+	// names holds the name of every directory and the stem of every file,
+	// which an import must start with to be matched at the end of a path
+	names map[string]bool
+}
+
+// NewImportResolver returns an ImportResolver for the files at relPaths of a
+// project with the given modules.
+func NewImportResolver(relPaths []string, modules []Module) *ImportResolver {
+	r := &ImportResolver{
+		modules:     modules,
+		paths:       make(map[string][]int),
+		stems:       make(map[string][]int),
+		dirs:        make(map[string][]int),
+		dirSuffixes: make(map[string][]int),
+		names:       make(map[string]bool),
+	}
+	for i, relPath := range relPaths {
+		relPath = strings.ReplaceAll(relPath, "\\", "/")
+		stem := strings.TrimSuffix(relPath, path.Ext(relPath))
+		r.paths[stem] = append(r.paths[stem], i)
+		for _, suffix := range pathSuffixes(stem) {
+			r.stems[suffix] = append(r.stems[suffix], i)
+		}
+		for _, name := range strings.Split(stem, "/") {
+			r.names[name] = true
+		}
+
+		dir := path.Dir(relPath)
+		r.dirs[dir] = append(r.dirs[dir], i)
+		if dir != "." {
+			for _, suffix := range pathSuffixes(dir) {
+				r.dirSuffixes[suffix] = append(r.dirSuffixes[suffix], i)
+			}
+		}
+	}
+	return r
+}
+
+// Resolve returns the indexes of the files target refers to: the file
+// itself or the files of the imported directory or package.
+//
+// Imports starting with the path of one of the project's modules, and
+// relative imports, which ExtractImports makes relative to the project
+// root, must match a path exactly. Other imports may be written relative
+// to a source root, so they are matched at the end of a path, but only if
+// their first part names a directory or file of the project: imports of
+// the standard library or other packages, such as Go's "context" or
+// "encoding/json", would otherwise match files of the same name. A target
+// matching no file is taken to name an item of a module, and resolves to
+// the module's file.
+func (r *ImportResolver) Resolve(target string) []int {
+	for _, m := range r.modules {
+		if rest, ok := strings.CutPrefix(target, m.Path); ok && (rest == "" || rest[0] == '/') {
+			dir := strings.Trim(path.Join(m.Dir, rest), "/")
+			if dir == "" {
+				dir = "."
+			}
+			return r.lookup(dir, false)
+		}
+	}
+
+	first, _, nested := strings.Cut(target, "/")
+	return r.lookup(target, nested && r.names[first])
+}
+
+// lookup returns the files target refers to, matching it against the end
+// of their paths if suffix is set.
+func (r *ImportResolver) lookup(target string, suffix bool) []int {
+	var matches []int
+	seen := make(map[int]bool)
+	add := func(ids []int) {
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				matches = append(matches, id)
+			}
+		}
+	}
+	files, dirs := r.paths, r.dirs
+	if suffix {
+		files, dirs = r.stems, r.dirSuffixes
+	}
+
+	add(files[target])
+	add(dirs[target])
+	if len(matches) > 0 {
+		return matches
+	}
+
+	if parent := path.Dir(target); parent != "." {
+		for _, module := range []string{parent, parent + "/__init__", parent + "/mod", parent + "/index"} {
+			add(files[module])
+		}
+	}
+	return matches
+}
+
+// pathSuffixes returns p and each part of it following a slash, longest
+// first.
+func pathSuffixes(p string) []string {
+	suffixes := []string{p}
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' {
+			suffixes = append(suffixes, p[i+1:])
+		}
+	}
+	return suffixes
+}
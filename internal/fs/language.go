@@ -1,7 +1,10 @@
 package fs
 
 import (
+	"fmt"
+	"maps"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -160,6 +163,76 @@ func DetectLanguage(path string) string {
 	return LangUnknown
 }
 
+// Languages returns the names of the languages detected from file
+// extensions, sorted.
+func Languages() []string {
+	return slices.Sorted(maps.Keys(langExts()))
+}
+
+// LanguageExtensions returns the file extensions of the named languages,
+// e.g. [".go"] for "go". Names are not case sensitive.
+func LanguageExtensions(names []string) ([]string, error) {
+	byLang := langExts()
+	var exts []string
+	for _, name := range names {
+		langExts, ok := byLang[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown language %q (known: %s)", name, strings.Join(Languages(), ", "))
+		}
+		exts = append(exts, langExts...)
+	}
+	return exts, nil
+}
+
+// langExts returns the extensions of each language of extToLang.
+func langExts() map[string][]string {
+	byLang := make(map[string][]string)
+	for ext, lang := range extToLang {
+		byLang[lang] = append(byLang[lang], ext)
+	}
+	return byLang
+}
+
+// LanguageFilter selects files by the extensions of the languages included
+// and excluded. A nil filter selects every file.
+type LanguageFilter struct {
+	include map[string]bool // nil includes all languages
+	exclude map[string]bool
+}
+
+// NewLanguageFilter returns a filter for files of the include languages, or
+// any language if there are none, that are not of the exclude languages. It
+// returns nil if both are empty.
+func NewLanguageFilter(include, exclude []string) (*LanguageFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+	includeExts, err := LanguageExtensions(include)
+	if err != nil {
+		return nil, err
+	}
+	excludeExts, err := LanguageExtensions(exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &LanguageFilter{
+		include: extensionSet(includeExts),
+		exclude: extensionSet(excludeExts),
+	}, nil
+}
+
+// Match reports whether the filter selects the file at path.
+func (f *LanguageFilter) Match(path string) bool {
+	if f == nil {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if f.include != nil && !f.include[ext] {
+		return false
+	}
+	return !f.exclude[ext]
+}
+
 // IsCodeFile returns true if the file appears to be source code.
 func IsCodeFile(path string) bool {
 	lang := DetectLanguage(path)
@@ -173,14 +246,20 @@ func IsCodeFile(path string) bool {
 	}
 }
 
+// codeChunkingLanguages lists the languages split at function and class
+// boundaries rather than by size alone.
+var codeChunkingLanguages = []string{
+	LangGo, LangTypeScript, LangJavaScript, LangPython, LangRust,
+	LangJava, LangC, LangCPP, LangCSharp, LangRuby, LangPHP,
+	LangSwift, LangKotlin, LangScala,
+}
+
 // SupportsCodeChunking returns true if the language supports code-aware chunking.
 func SupportsCodeChunking(lang string) bool {
-	switch lang {
-	case LangGo, LangTypeScript, LangJavaScript, LangPython, LangRust,
-		LangJava, LangC, LangCPP, LangCSharp, LangRuby, LangPHP,
-		LangSwift, LangKotlin, LangScala:
-		return true
-	default:
-		return false
-	}
+	return slices.Contains(codeChunkingLanguages, lang)
+}
+
+// CodeChunkingLanguages returns the languages that support code-aware chunking.
+func CodeChunkingLanguages() []string {
+	return slices.Clone(codeChunkingLanguages)
 }
@@ -0,0 +1,120 @@
+package fs
+
+import (
+	"regexp"
+	"strings"
+)
+
+// symbolPatterns match the lines that define a symbol in each language. The
+// first capture group of each pattern is the symbol's name.
+var symbolPatterns = map[string][]*regexp.Regexp{
+	LangGo: {
+		regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^(?:type|const|var)\s+([A-Za-z_]\w*)`),
+	},
+	LangTypeScript: jsSymbolPatterns,
+	LangJavaScript: jsSymbolPatterns,
+	LangPython: {
+		regexp.MustCompile(`^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*class\s+([A-Za-z_]\w*)`),
+	},
+	LangRust: {
+		regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:(?:async|const|unsafe|extern\s+"[^"]*")\s+)*fn\s+([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|type|mod|union)\s+([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*macro_rules!\s*([A-Za-z_]\w*)`),
+	},
+	LangJava: {
+		regexp.MustCompile(`^\s*(?:(?:public|private|protected|static|final|abstract|sealed)\s+)*(?:class|interface|enum|record|@interface)\s+([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*(?:(?:public|private|protected|static|final|abstract|synchronized|native|default)\s+)+[\w<>\[\],.? ]+\s+([A-Za-z_]\w*)\s*\(`),
+	},
+	LangC:   cSymbolPatterns,
+	LangCPP: cSymbolPatterns,
+	LangCSharp: {
+		regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|static|abstract|sealed|partial|readonly)\s+)*(?:class|interface|struct|enum|record|namespace)\s+([A-Za-z_][\w.]*)`),
+		regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|static|virtual|override|abstract|async|sealed)\s+)+[\w<>\[\],.? ]+\s+([A-Za-z_]\w*)\s*\(`),
+	},
+	LangRuby: {
+		regexp.MustCompile(`^\s*def\s+(?:self\.)?([A-Za-z_]\w*[?!=]?)`),
+		regexp.MustCompile(`^\s*(?:class|module)\s+([A-Z]\w*(?:::[A-Z]\w*)*)`),
+	},
+	LangPHP: {
+		regexp.MustCompile(`^\s*(?:(?:public|private|protected|static|abstract|final)\s+)*function\s+&?([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*(?:(?:abstract|final)\s+)?(?:class|interface|trait|enum)\s+([A-Za-z_]\w*)`),
+	},
+	LangSwift: {
+		regexp.MustCompile(`^\s*(?:(?:public|private|fileprivate|internal|open|static|final|override|mutating)\s+)*func\s+([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*(?:(?:public|private|fileprivate|internal|open|final)\s+)*(?:class|struct|enum|protocol|actor)\s+([A-Za-z_]\w*)`),
+	},
+	LangKotlin: {
+		regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|override|open|suspend|inline|operator)\s+)*fun\s+(?:<[^>]*>\s*)?(?:[\w.]+\.)?([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|open|abstract|sealed|data|enum)\s+)*(?:class|interface|object)\s+([A-Za-z_]\w*)`),
+	},
+	LangScala: {
+		regexp.MustCompile(`^\s*(?:(?:private|protected|override|final|implicit)\s+)*def\s+([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*(?:(?:abstract|sealed|final|case)\s+)*(?:class|object|trait)\s+([A-Za-z_]\w*)`),
+	},
+}
+
+var jsSymbolPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`),
+	regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][\w$]*)`),
+	regexp.MustCompile(`^\s*(?:export\s+)?(?:declare\s+)?(?:interface|type|enum)\s+([A-Za-z_$][\w$]*)`),
+	regexp.MustCompile(`^(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*[:=]`),
+}
+
+var cSymbolPatterns = []*regexp.Regexp{
+	// A function definition starts at the beginning of a line and does not
+	// end in a semicolon, unlike a declaration or a call
+	regexp.MustCompile(`^[A-Za-z_][\w\s\*&:<>,]*?[\s\*&:]([A-Za-z_]\w*)\s*\([^;]*$`),
+	regexp.MustCompile(`^\s*(?:typedef\s+)?(?:struct|class|union|enum(?:\s+class)?|namespace)\s+([A-Za-z_]\w*)`),
+	regexp.MustCompile(`^#\s*define\s+([A-Za-z_]\w*)`),
+}
+
+// notSymbols are keywords that a loose pattern can mistake for a name.
+var notSymbols = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "return": true,
+	"catch": true, "sizeof": true, "else": true, "new": true,
+}
+
+// ExtractSymbols returns the names of the symbols defined in content, in the
+// order they are defined and each once. Definitions are recognized line by
+// line, so only languages with code-aware chunking have symbols.
+func ExtractSymbols(content, lang string) []string {
+	patterns := symbolPatterns[lang]
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		if name := lineSymbol(line, patterns); name != "" && !seen[name] {
+			seen[name] = true
+			symbols = append(symbols, name)
+		}
+	}
+	return symbols
+}
+
+// SymbolLine returns the index of the line of content that defines name, or
+// -1 if none does.
+func SymbolLine(content, lang, name string) int {
+	patterns := symbolPatterns[lang]
+	for i, line := range strings.Split(content, "\n") {
+		if lineSymbol(line, patterns) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// lineSymbol returns the name of the symbol line defines, or an empty string
+// if it defines none.
+func lineSymbol(line string, patterns []*regexp.Regexp) string {
+	for _, re := range patterns {
+		if m := re.FindStringSubmatch(line); m != nil && !notSymbols[m[1]] {
+			return m[1]
+		}
+	}
+	return ""
+}
@@ -14,6 +14,7 @@ type FileInfo struct {
 	ModTime  time.Time // Last modification time
 	Hash     string    // xxhash of file contents
 	Language string    // Detected programming language (if applicable)
+	Binary   bool      // Binary file, indexed by its path only
 }
 
 // Chunk represents a piece of a file for embedding.
@@ -24,6 +25,10 @@ type Chunk struct {
 	StartChar  int    // Starting character offset
 	EndChar    int    // Ending character offset
 	ChunkIndex int    // Index of this chunk within the file
+
+	// Symbols are the names defined in the chunk, for languages with
+	// code-aware chunking.
+	Symbols []string
 }
 
 // WalkOptions configures the file walker.
@@ -34,6 +39,10 @@ type WalkOptions struct {
 	// MaxFileSize is the maximum file size to process (in bytes).
 	MaxFileSize int64
 
+	// MinFileSize is the minimum file size to process (in bytes), e.g. to
+	// leave out empty files.
+	MinFileSize int64
+
 	// MaxFileCount is the maximum number of files to process.
 	MaxFileCount int
 
@@ -49,6 +58,20 @@ type WalkOptions struct {
 	// Extensions limits to specific file extensions (e.g., ".go", ".ts").
 	// Empty means all text files.
 	Extensions []string
+
+	// Languages limits to the extensions of these languages (e.g., "go",
+	// "sql"), and ExcludeLanguages leaves out those of others. Names are
+	// those of DetectLanguage.
+	Languages        []string
+	ExcludeLanguages []string
+
+	// TextExtensions are extensions of files read as text even if they
+	// look binary or are known binary formats, e.g. ".svg".
+	TextExtensions []string
+
+	// IncludeBinaryNames yields binary files, marked Binary, so that their
+	// paths can be indexed. Size limits do not apply to them.
+	IncludeBinaryNames bool
 }
 
 // ChunkOptions configures the chunker.
@@ -96,8 +119,17 @@ type WalkStats struct {
 	FilesFound   int   // Total files found
 	FilesSkipped int   // Files skipped due to size/pattern/etc
 	DirsSkipped  int   // Directories skipped
-	TotalBytes   int64 // Total bytes of files found
+	TotalBytes   int64 // Total bytes of files found, apart from binary files
 	SkippedBytes int64 // Total bytes of skipped files
+
+	// Files skipped for each reason, adding up to FilesSkipped
+	SkippedIgnored int // Matched an ignore pattern
+	SkippedBinary  int // Binary, without IncludeBinaryNames
+	SkippedFilter  int // Outside the extension or language filter
+	SkippedSize    int // Over MaxFileSize or under MinFileSize
+
+	// LimitReached is set when MaxFileCount stopped the walk early.
+	LimitReached bool
 }
 
 // Chunker splits file contents into chunks.
@@ -107,4 +139,8 @@ type Chunker interface {
 
 	// ChunkReader splits content from a reader into chunks.
 	ChunkReader(r io.Reader, filename string) ([]Chunk, error)
+
+	// ChunkStream splits content from a reader, passing each chunk to emit
+	// as soon as it is complete.
+	ChunkStream(r io.Reader, filename string, emit func(Chunk) error) error
 }
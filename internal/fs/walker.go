@@ -32,8 +32,11 @@ func (c *combinedIgnorer) MatchesPath(path string) bool {
 type FileWalker struct {
 	opts    WalkOptions
 	ignorer Ignorer
+	binary  Ignorer // binary file names, e.g. *.png
 	stats   WalkStats
 	extSet  map[string]bool
+	textExt map[string]bool
+	langs   *LanguageFilter
 }
 
 // NewFileWalker creates a new file walker.
@@ -58,16 +61,13 @@ func NewFileWalker(opts WalkOptions) (*FileWalker, error) {
 		opts: opts,
 	}
 
-	// Build extension set for fast lookup
-	if len(opts.Extensions) > 0 {
-		w.extSet = make(map[string]bool)
-		for _, ext := range opts.Extensions {
-			// Normalize extension to have leading dot
-			if !strings.HasPrefix(ext, ".") {
-				ext = "." + ext
-			}
-			w.extSet[strings.ToLower(ext)] = true
-		}
+	// Build extension sets for fast lookup
+	w.extSet = extensionSet(opts.Extensions)
+	w.textExt = extensionSet(opts.TextExtensions)
+
+	w.langs, err = NewLanguageFilter(opts.Languages, opts.ExcludeLanguages)
+	if err != nil {
+		return nil, fmt.Errorf("invalid language filter: %w", err)
 	}
 
 	// Initialize gitignore
@@ -85,9 +85,19 @@ func (w *FileWalker) initIgnorer() error {
 	// Add custom ignore patterns
 	patterns = append(patterns, w.opts.IgnorePatterns...)
 
-	// Add default patterns for binary and generated files
+	// Add default patterns for generated files
 	patterns = append(patterns, defaultIgnorePatterns...)
 
+	// Binary file names are matched apart, so they can be indexed by name
+	// or read as text for the extensions of TextExtensions
+	var binary []string
+	for _, pattern := range binaryPatterns {
+		if !w.textExt[strings.TrimPrefix(pattern, "*")] {
+			binary = append(binary, pattern)
+		}
+	}
+	w.binary = gitignore.CompileIgnoreLines(binary...)
+
 	// Load .gitignore from root if it exists
 	if w.opts.UseGitignore {
 		gitignorePath := filepath.Join(w.opts.Root, ".gitignore")
@@ -139,12 +149,14 @@ func (w *FileWalker) Walk(fn func(FileInfo) error) error {
 
 		// Check max file count
 		if w.opts.MaxFileCount > 0 && w.stats.FilesFound >= w.opts.MaxFileCount {
+			w.stats.LimitReached = true
 			return filepath.SkipAll
 		}
 
 		// Skip if file should be ignored
 		if w.shouldSkipFile(d.Name(), relPath) {
 			w.stats.FilesSkipped++
+			w.stats.SkippedIgnored++
 			return nil
 		}
 
@@ -155,25 +167,18 @@ func (w *FileWalker) Walk(fn func(FileInfo) error) error {
 			return nil
 		}
 
-		// Check file size
-		if w.opts.MaxFileSize > 0 && info.Size() > w.opts.MaxFileSize {
+		binary, reason := w.check(path, relPath, info.Size())
+		if reason != notSkipped {
 			w.stats.FilesSkipped++
-			w.stats.SkippedBytes += info.Size()
-			return nil
-		}
-
-		// Check extension filter
-		if w.extSet != nil {
-			ext := strings.ToLower(filepath.Ext(path))
-			if !w.extSet[ext] {
-				w.stats.FilesSkipped++
-				return nil
+			switch reason {
+			case skippedBinary:
+				w.stats.SkippedBinary++
+			case skippedFilter:
+				w.stats.SkippedFilter++
+			case skippedSize:
+				w.stats.SkippedSize++
+				w.stats.SkippedBytes += info.Size()
 			}
-		}
-
-		// Check if file is binary
-		if isBinary, err := isBinaryFile(path); err != nil || isBinary {
-			w.stats.FilesSkipped++
 			return nil
 		}
 
@@ -194,20 +199,110 @@ func (w *FileWalker) Walk(fn func(FileInfo) error) error {
 			ModTime:  info.ModTime(),
 			Hash:     hash,
 			Language: lang,
+			Binary:   binary,
 		}
 
 		w.stats.FilesFound++
-		w.stats.TotalBytes += info.Size()
+		if !binary {
+			w.stats.TotalBytes += info.Size()
+		}
 
 		return fn(fileInfo)
 	})
 }
 
+// skipReason is why a file that is not ignored is skipped.
+type skipReason int
+
+const (
+	notSkipped skipReason = iota
+	skippedBinary
+	skippedFilter
+	skippedSize
+)
+
+// check applies the filters that follow the ignore patterns to the file at
+// path, of size bytes. It returns whether the file is binary and why it is
+// skipped, if it is.
+func (w *FileWalker) check(path, relPath string, size int64) (bool, skipReason) {
+	// Binary files are only indexed by name, if at all
+	binary := w.binary.MatchesPath(relPath)
+	if binary && !w.opts.IncludeBinaryNames {
+		return true, skippedBinary
+	}
+
+	// Check extension filter
+	ext := strings.ToLower(filepath.Ext(path))
+	if (w.extSet != nil && !w.extSet[ext]) || !w.langs.Match(path) {
+		return binary, skippedFilter
+	}
+
+	// Check if file is binary
+	if !binary && !w.textExt[ext] {
+		isBinary, err := isBinaryFile(path)
+		if err != nil || (isBinary && !w.opts.IncludeBinaryNames) {
+			return true, skippedBinary
+		}
+		binary = isBinary
+	}
+
+	// Check file size
+	if !binary && ((w.opts.MaxFileSize > 0 && size > w.opts.MaxFileSize) || size < w.opts.MinFileSize) {
+		return false, skippedSize
+	}
+	return binary, notSkipped
+}
+
+// Include reports whether Walk would yield the file at path, and whether as
+// a binary file, without walking the tree. It is used to judge single files,
+// e.g. those reported by file system events.
+func (w *FileWalker) Include(path string) (include, binary bool) {
+	relPath, err := filepath.Rel(w.opts.Root, path)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return false, false
+	}
+	info, err := os.Lstat(path)
+	if err != nil || info.IsDir() {
+		return false, false
+	}
+
+	// Files below skipped directories are never walked
+	dirs := strings.Split(filepath.ToSlash(relPath), "/")
+	for i := 1; i < len(dirs); i++ {
+		if w.shouldSkipDir(dirs[i-1], filepath.Join(dirs[:i]...)) {
+			return false, false
+		}
+	}
+	if w.shouldSkipFile(filepath.Base(path), relPath) {
+		return false, false
+	}
+
+	binary, reason := w.check(path, relPath, info.Size())
+	return reason == notSkipped, binary
+}
+
 // Stats returns the walk statistics.
 func (w *FileWalker) Stats() WalkStats {
 	return w.stats
 }
 
+// TopLevelDirs returns the names of the directories directly under the root
+// that Walk enters, in lexical order.
+func (w *FileWalker) TopLevelDirs() ([]string, error) {
+	entries, err := os.ReadDir(w.opts.Root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && !w.shouldSkipDir(entry.Name(), entry.Name()) {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	return dirs, nil
+}
+
 // shouldSkipDir checks if a directory should be skipped.
 func (w *FileWalker) shouldSkipDir(name, relPath string) bool {
 	// Always skip .git
@@ -243,6 +338,23 @@ func (w *FileWalker) shouldSkipFile(name, relPath string) bool {
 	return false
 }
 
+// extensionSet returns the set of exts, lower case with a leading dot, or
+// nil if there are none.
+func extensionSet(exts []string) map[string]bool {
+	if len(exts) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		// Normalize extension to have leading dot
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		set[strings.ToLower(ext)] = true
+	}
+	return set
+}
+
 // hashFile computes the xxhash of a file's contents.
 func hashFile(path string) (string, error) {
 	f, err := os.Open(path)
@@ -279,14 +391,16 @@ func isBinaryFile(path string) (bool, error) {
 		return false, err
 	}
 
-	return isBinaryContent(buf[:n]), nil
+	return IsBinary(buf[:n]), nil
 }
 
-// isBinaryContent checks if content appears to be binary.
-func isBinaryContent(content []byte) bool {
+// IsBinary checks if content appears to be binary. Only the first 8KB is
+// examined.
+func IsBinary(content []byte) bool {
 	if len(content) == 0 {
 		return false
 	}
+	content = content[:min(len(content), 8192)]
 
 	// Check for null bytes (strong indicator of binary)
 	for _, b := range content {
@@ -307,7 +421,7 @@ func isBinaryContent(content []byte) bool {
 	return float64(nonPrintable)/float64(len(content)) > 0.3
 }
 
-// Default patterns to ignore (common binary/generated files).
+// Default patterns to ignore (build outputs and other generated files).
 var defaultIgnorePatterns = []string{
 	// Build outputs
 	"node_modules/",
@@ -341,7 +455,23 @@ var defaultIgnorePatterns = []string{
 	".DS_Store",
 	"Thumbs.db",
 
-	// Binary file extensions
+	// Database files
+	"*.db",
+	"*.sqlite",
+	"*.sqlite3",
+
+	// Coverage and test artifacts
+	"coverage/",
+	".nyc_output/",
+	"*.lcov",
+
+	// Generated files
+	"*.generated.*",
+	"*.gen.*",
+}
+
+// Patterns of binary files, which are skipped unless indexed by name.
+var binaryPatterns = []string{
 	"*.exe",
 	"*.dll",
 	"*.so",
@@ -387,18 +517,4 @@ var defaultIgnorePatterns = []string{
 	"*.ttf",
 	"*.eot",
 	"*.otf",
-
-	// Database files
-	"*.db",
-	"*.sqlite",
-	"*.sqlite3",
-
-	// Coverage and test artifacts
-	"coverage/",
-	".nyc_output/",
-	"*.lcov",
-
-	// Generated files
-	"*.generated.*",
-	"*.gen.*",
 }
@@ -0,0 +1,209 @@
+// Package health checks that the index database and the embedding provider
+// are usable, for long-running modes such as the MCP server that should keep
+// answering in a degraded state rather than exit.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// Overall states of a Report.
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusChecking = "checking" // The provider has not been checked yet
+)
+
+const (
+	// DefaultTimeout bounds each check, so an unreachable provider is
+	// reported instead of stalling the caller.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMaxAge is how long a report is reused before checking again.
+	DefaultMaxAge = 30 * time.Second
+
+	// probeText is embedded to check the provider.
+	probeText = "health check"
+)
+
+// Component is the result of checking one dependency.
+type Component struct {
+	OK        bool   `json:"ok"`
+	Name      string `json:"name,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report describes the health of the database and the embedding provider,
+// with totals across all stores.
+type Report struct {
+	Status    string    `json:"status"` // StatusOK, StatusDegraded or StatusChecking
+	Database  Component `json:"database"`
+	Provider  Component `json:"provider"`
+	Stores    int       `json:"stores"`
+	Files     int       `json:"files"`
+	Chunks    int       `json:"chunks"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Healthy reports whether every component is usable. A report still
+// checking is not.
+func (r *Report) Healthy() bool {
+	return r.Status == StatusOK
+}
+
+// Problems describes the components that are not usable.
+func (r *Report) Problems() []string {
+	var problems []string
+	if !r.Database.OK {
+		problems = append(problems, "database unavailable: "+r.Database.Error)
+	}
+	if !r.Provider.OK {
+		problems = append(problems, "embedding provider "+r.Provider.Name+" unavailable: "+r.Provider.Error)
+	}
+	return problems
+}
+
+// Checker checks the database and embedding provider. Current never waits
+// for the provider, so frequent callers such as pings stay cheap.
+type Checker struct {
+	store    store.Store
+	embedder embeddings.Service
+
+	// Timeout bounds each check. MaxAge is how long a report is reused by
+	// Current.
+	Timeout time.Duration
+	MaxAge  time.Duration
+
+	mu       sync.Mutex
+	last     *Report
+	checking bool // A Refresh is running
+}
+
+// NewChecker creates a checker with the default timeout and maximum age.
+func NewChecker(st store.Store, emb embeddings.Service) *Checker {
+	return &Checker{
+		store:    st,
+		embedder: emb,
+		Timeout:  DefaultTimeout,
+		MaxAge:   DefaultMaxAge,
+	}
+}
+
+// Check checks the database and the provider now. The provider is checked
+// by embedding a short text, which is recorded as usage by metered
+// embedders like any other query.
+func (c *Checker) Check(ctx context.Context) *Report {
+	r := &Report{Status: StatusOK, CheckedAt: time.Now()}
+	r.Database = c.checkDatabase(r)
+	r.Provider = c.checkProvider(ctx)
+	if !r.Database.OK || !r.Provider.OK {
+		r.Status = StatusDegraded
+	}
+
+	c.mu.Lock()
+	c.last = r
+	c.mu.Unlock()
+	return r
+}
+
+// Refresh checks in the background, unless a check started by Refresh is
+// still running, and then calls done with the report if done is not nil.
+func (c *Checker) Refresh(ctx context.Context, done func(*Report)) {
+	c.mu.Lock()
+	if c.checking {
+		c.mu.Unlock()
+		return
+	}
+	c.checking = true
+	c.mu.Unlock()
+
+	go func() {
+		r := c.Check(ctx)
+		c.mu.Lock()
+		c.checking = false
+		c.mu.Unlock()
+		if done != nil {
+			done(r)
+		}
+	}()
+}
+
+// Current returns the last report without waiting for the provider, and
+// refreshes it in the background once it is older than MaxAge. Until the
+// first check finishes, the database is checked now and the report has
+// StatusChecking.
+func (c *Checker) Current(ctx context.Context) *Report {
+	c.mu.Lock()
+	last := c.last
+	c.mu.Unlock()
+	if last == nil || time.Since(last.CheckedAt) >= c.MaxAge {
+		c.Refresh(ctx, nil)
+	}
+	if last != nil {
+		return last
+	}
+
+	r := &Report{Status: StatusChecking, CheckedAt: time.Now()}
+	r.Database = c.checkDatabase(r)
+	r.Provider = Component{Name: c.providerName()}
+	if !r.Database.OK {
+		r.Status = StatusDegraded
+	}
+	return r
+}
+
+// checkDatabase lists the stores and totals their files and chunks.
+func (c *Checker) checkDatabase(r *Report) Component {
+	start := time.Now()
+	stores, err := c.store.ListStores()
+	if err != nil {
+		return Component{Error: err.Error(), LatencyMS: time.Since(start).Milliseconds()}
+	}
+
+	r.Stores = len(stores)
+	for _, sr := range stores {
+		stats, err := c.store.GetStats(sr.ID)
+		if err != nil {
+			return Component{Error: err.Error(), LatencyMS: time.Since(start).Milliseconds()}
+		}
+		r.Files += stats.FileCount
+		r.Chunks += stats.ChunkCount
+	}
+	return Component{OK: true, LatencyMS: time.Since(start).Milliseconds()}
+}
+
+// checkProvider embeds probeText within the timeout.
+func (c *Checker) checkProvider(ctx context.Context) Component {
+	comp := Component{Name: c.providerName()}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	embedding, err := c.embedder.EmbedQuery(ctx, probeText)
+	comp.LatencyMS = time.Since(start).Milliseconds()
+	switch {
+	case err != nil:
+		comp.Error = err.Error()
+	case len(embedding) == 0:
+		comp.Error = "the provider returned an empty embedding"
+	default:
+		comp.OK = true
+	}
+	return comp
+}
+
+// providerName names the provider and model being checked.
+func (c *Checker) providerName() string {
+	return string(c.embedder.Provider()) + "/" + c.embedder.ModelName()
+}
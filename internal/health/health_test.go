@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// stubEmbedder returns err from every call, or a fixed embedding if nil.
+// With block set, calls wait until it is closed.
+type stubEmbedder struct {
+	err   error
+	calls int
+	block chan struct{}
+}
+
+func (s *stubEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return s.EmbedQuery(ctx, text)
+}
+
+func (s *stubEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	if s.block != nil {
+		<-s.block
+	}
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []float32{1, 0, 0, 0}, nil
+}
+
+func (s *stubEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, s.err
+}
+
+func (s *stubEmbedder) Dimensions() int               { return 4 }
+func (s *stubEmbedder) Provider() embeddings.Provider { return embeddings.ProviderOllama }
+func (s *stubEmbedder) ModelName() string             { return "stub" }
+
+func TestCheck(t *testing.T) {
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	sr, err := st.CreateStore("test", t.TempDir(), store.ProviderOllama, "stub", 4)
+	require.NoError(t, err)
+	err = st.UpsertFile(sr.ID, store.FileInput{ExternalID: "a.go", Path: "/a.go", RelativePath: "a.go", Hash: "h"},
+		[]store.Chunk{{Content: "package a", StartLine: 1, EndLine: 1}}, [][]float32{{1, 0, 0, 0}})
+	require.NoError(t, err)
+
+	emb := &stubEmbedder{}
+	c := NewChecker(st, emb)
+
+	r := c.Check(context.Background())
+	assert.True(t, r.Healthy())
+	assert.Empty(t, r.Problems())
+	assert.True(t, r.Database.OK)
+	assert.True(t, r.Provider.OK)
+	assert.Equal(t, "ollama/stub", r.Provider.Name)
+	assert.Equal(t, 1, r.Stores)
+	assert.Equal(t, 1, r.Files)
+	assert.Equal(t, 1, r.Chunks)
+
+	// Recent reports are reused
+	emb.err = errors.New("connection refused")
+	assert.Same(t, r, c.Current(context.Background()))
+	assert.Equal(t, 1, emb.calls)
+
+	// Older ones are refreshed in the background
+	c.MaxAge = time.Nanosecond
+	refreshed := make(chan *Report, 1)
+	c.Refresh(context.Background(), func(r *Report) { refreshed <- r })
+	r = <-refreshed
+	assert.False(t, r.Healthy())
+	assert.Equal(t, StatusDegraded, r.Status)
+	assert.True(t, r.Database.OK)
+	assert.Equal(t, "connection refused", r.Provider.Error)
+	require.Len(t, r.Problems(), 1)
+	assert.Contains(t, r.Problems()[0], "ollama/stub")
+
+	// Current does not wait for the provider: until it answers, only the
+	// database is reported
+	slow := &stubEmbedder{block: make(chan struct{})}
+	pending := NewChecker(st, slow)
+	r = pending.Current(context.Background())
+	assert.Equal(t, StatusChecking, r.Status)
+	assert.False(t, r.Healthy())
+	assert.True(t, r.Database.OK)
+	assert.Equal(t, "ollama/stub", r.Provider.Name)
+	close(slow.block)
+	assert.Eventually(t, func() bool {
+		return pending.Current(context.Background()).Healthy()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// A closed database is reported rather than failing the check
+	require.NoError(t, st.Close())
+	r = c.Check(context.Background())
+	assert.False(t, r.Database.OK)
+	assert.NotEmpty(t, r.Database.Error)
+}
@@ -0,0 +1,141 @@
+// Package httpclient builds the HTTP clients used to reach embedding and
+// LLM providers from their configuration.
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/nickcecere/lgrep/internal/config"
+)
+
+// New returns a client for a provider endpoint with the timeout, connection
+// pool and TLS settings of endpoint, sent through the proxy of network.
+// Each client has its own transport, so the pool of one provider does not
+// limit another's.
+func New(network config.NetworkConfig, endpoint config.HTTPConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if endpoint.MaxIdleConns > 0 {
+		// Providers are a single host, so the per-host limit is the one
+		// that matters
+		transport.MaxIdleConns = endpoint.MaxIdleConns
+		transport.MaxIdleConnsPerHost = endpoint.MaxIdleConns
+	}
+
+	proxy, err := proxyFunc(network)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+
+	tlsConfig, err := newTLSConfig(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Timeout:   endpoint.Timeout,
+		Transport: transport,
+	}, nil
+}
+
+// proxyFunc returns the proxy selection of network: its proxy and no-proxy
+// list, each falling back to the environment when empty. Requests to
+// localhost, such as a local Ollama, are never proxied.
+func proxyFunc(network config.NetworkConfig) (func(*http.Request) (*url.URL, error), error) {
+	proxyConfig := httpproxy.FromEnvironment()
+	if network.Proxy != "" {
+		if _, err := url.Parse(network.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid network.proxy: %w", err)
+		}
+		proxyConfig.HTTPProxy = network.Proxy
+		proxyConfig.HTTPSProxy = network.Proxy
+	}
+	if network.NoProxy != "" {
+		proxyConfig.NoProxy = network.NoProxy
+	}
+
+	proxy := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}, nil
+}
+
+// newTLSConfig returns the TLS settings of endpoint, or nil for Go's
+// defaults.
+func newTLSConfig(endpoint config.HTTPConfig) (*tls.Config, error) {
+	if endpoint.CAFile == "" && !endpoint.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if endpoint.CAFile != "" {
+		pem, err := os.ReadFile(endpoint.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", endpoint.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if endpoint.InsecureSkipVerify {
+		log.Debug("TLS certificate verification is off for a provider endpoint")
+		tlsConfig.InsecureSkipVerify = true
+	}
+	return tlsConfig, nil
+}
+
+// DialFunc makes the connections of a transport, like net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// socketBaseURL is the base URL of requests sent over a unix socket. Its
+// host is never resolved, since every connection goes to the socket.
+const socketBaseURL = "http://localhost"
+
+// Endpoint returns the client to send requests for rawURL with and the base
+// URL to send them to. A unix socket URL such as unix:///run/ollama.sock,
+// e.g. one forwarded from another host with ssh -L, gives a copy of client
+// whose connections all go to the socket; other URLs are returned with
+// client as they are.
+func Endpoint(client *http.Client, rawURL string) (*http.Client, string) {
+	path, ok := strings.CutPrefix(rawURL, "unix://")
+	if !ok || path == "" {
+		return client, rawURL
+	}
+	var dialer net.Dialer
+	return WithDialer(client, func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", path)
+	}), socketBaseURL
+}
+
+// WithDialer returns a copy of client that makes its connections with dial.
+// A client whose transport is not an *http.Transport gets a copy of Go's
+// default one.
+func WithDialer(client *http.Client, dial DialFunc) *http.Client {
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	transport = transport.Clone()
+	transport.DialContext = dial
+
+	c := *client
+	c.Transport = transport
+	return &c
+}
@@ -0,0 +1,116 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/config"
+)
+
+func TestNew(t *testing.T) {
+	client, err := New(config.NetworkConfig{}, config.HTTPConfig{Timeout: 30 * time.Second, MaxIdleConns: 8})
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, client.Timeout)
+	transport := client.Transport.(*http.Transport)
+	assert.Equal(t, 8, transport.MaxIdleConns)
+	assert.Equal(t, 8, transport.MaxIdleConnsPerHost)
+	assert.Nil(t, transport.TLSClientConfig)
+
+	// Zero keeps Go's defaults and no timeout
+	client, err = New(config.NetworkConfig{}, config.HTTPConfig{})
+	require.NoError(t, err)
+	assert.Zero(t, client.Timeout)
+	transport = client.Transport.(*http.Transport)
+	assert.Equal(t, http.DefaultTransport.(*http.Transport).MaxIdleConns, transport.MaxIdleConns)
+}
+
+func TestProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("HTTP_PROXY", "")
+	t.Setenv("NO_PROXY", "")
+
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client, err := New(config.NetworkConfig{Proxy: proxy.URL, NoProxy: "internal.example"}, config.HTTPConfig{})
+	require.NoError(t, err)
+
+	resp, err := client.Get("http://api.example/v1/models")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "http://api.example/v1/models", proxied)
+
+	// Hosts in the no-proxy list and localhost are reached directly
+	transport := client.Transport.(*http.Transport)
+	for _, target := range []string{"http://internal.example/", "http://localhost:11434/", "http://127.0.0.1:11434/"} {
+		req, err := http.NewRequest("GET", target, nil)
+		require.NoError(t, err)
+		u, err := transport.Proxy(req)
+		require.NoError(t, err)
+		assert.Nil(t, u, target)
+	}
+
+	// Without a configured proxy, the environment's is used
+	t.Setenv("HTTP_PROXY", proxy.URL)
+	client, err = New(config.NetworkConfig{}, config.HTTPConfig{})
+	require.NoError(t, err)
+	req, err := http.NewRequest("GET", "http://api.example/", nil)
+	require.NoError(t, err)
+	u, err := client.Transport.(*http.Transport).Proxy(req)
+	require.NoError(t, err)
+	assert.Equal(t, proxy.URL, u.String())
+}
+
+func TestTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, cert, 0o600))
+
+	get := func(endpoint config.HTTPConfig) error {
+		client, err := New(config.NetworkConfig{}, endpoint)
+		require.NoError(t, err)
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	assert.Error(t, get(config.HTTPConfig{}), "untrusted certificate")
+	assert.NoError(t, get(config.HTTPConfig{CAFile: caFile}))
+	assert.NoError(t, get(config.HTTPConfig{InsecureSkipVerify: true}))
+
+	// A file without certificates is rejected
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("not a certificate"), 0o600))
+	_, err := New(config.NetworkConfig{}, config.HTTPConfig{CAFile: empty})
+	assert.ErrorContains(t, err, "no certificates found")
+}
+
+func TestEndpoint(t *testing.T) {
+	client := &http.Client{Timeout: time.Minute}
+
+	got, url := Endpoint(client, "http://localhost:11434")
+	assert.Same(t, client, got)
+	assert.Equal(t, "http://localhost:11434", url)
+
+	got, url = Endpoint(client, "unix:///run/ollama.sock")
+	assert.Equal(t, socketBaseURL, url)
+	assert.Equal(t, time.Minute, got.Timeout)
+	assert.NotNil(t, got.Transport.(*http.Transport).DialContext)
+	assert.Nil(t, client.Transport, "the original client is unchanged")
+}
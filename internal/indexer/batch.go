@@ -0,0 +1,318 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/fs"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// DefaultPollInterval is how often embedding batches are checked.
+const DefaultPollInterval = 30 * time.Second
+
+// indexBatches indexes files through the embedding provider's batch API.
+// Batches left by an interrupted run are collected first. Changed files are
+// then chunked and submitted in as few batches as possible, and written
+// once each batch completes. Files too large for a batch are returned to be
+// indexed directly.
+//
+// If ctx is cancelled while waiting, submitted batches keep running and are
+// collected by the next run.
+func (idx *Indexer) indexBatches(ctx context.Context, storeRecord *store.StoreRecord, files []fs.FileInfo, opts IndexOptions) ([]fs.FileInfo, error) {
+	batcher, ok := embeddings.AsBatchService(idx.embedder)
+	if !ok {
+		return nil, fmt.Errorf("the %s embedding provider does not support batch embedding", idx.embedder.Provider())
+	}
+
+	resumed, err := idx.store.ListEmbeddingBatches(storeRecord.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(resumed) > 0 {
+		log.Info("Collecting embedding batches from an earlier run", "count", len(resumed))
+		if err := idx.waitBatches(ctx, batcher, storeRecord, resumed, false, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	submitted, direct, err := idx.submitBatches(ctx, batcher, storeRecord, files, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(submitted) > 0 {
+		if err := idx.waitBatches(ctx, batcher, storeRecord, submitted, true, opts); err != nil {
+			return nil, err
+		}
+	}
+	return direct, nil
+}
+
+// submitBatches chunks the changed files and submits their chunks, filling
+// each batch before starting the next. It returns the submitted batches and
+// the files to index directly instead.
+func (idx *Indexer) submitBatches(ctx context.Context, batcher embeddings.BatchService, storeRecord *store.StoreRecord, files []fs.FileInfo, opts IndexOptions) ([]store.EmbeddingBatch, []fs.FileInfo, error) {
+	set := idx.current.Load()
+	maxTexts := batcher.MaxBatchSize()
+
+	var submitted []store.EmbeddingBatch
+	var direct []fs.FileInfo
+	var texts []string
+	current := store.EmbeddingBatch{StoreID: storeRecord.ID}
+
+	submit := func() error {
+		if len(texts) == 0 {
+			return nil
+		}
+		phase := time.Now()
+		jobID, err := batcher.SubmitBatch(ctx, texts)
+		idx.addTime(&idx.embedTime, time.Since(phase))
+		if err != nil {
+			return fmt.Errorf("failed to submit embedding batch: %w", err)
+		}
+
+		current.JobID = jobID
+		if err := idx.store.AddEmbeddingBatch(&current); err != nil {
+			return err
+		}
+		log.Info("Submitted embedding batch", "id", jobID, "files", len(current.Files), "chunks", len(texts))
+		submitted = append(submitted, current)
+		current = store.EmbeddingBatch{StoreID: storeRecord.ID}
+		texts = nil
+		return nil
+	}
+
+	for _, fi := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if fi.Binary || idx.streamsFile(fi, opts.BatchSize) {
+			direct = append(direct, fi)
+			continue
+		}
+		if idx.fileUnchanged(storeRecord, fi) {
+			idx.commitFiles(storeRecord, nil, 1, opts)
+			continue
+		}
+
+		content, err := os.ReadFile(fi.Path)
+		if err != nil {
+			log.Warn("Failed to index file", "path", fi.RelPath, "error", err)
+			idx.countFiles(0, 1, opts)
+			continue
+		}
+		chunks := set.chunker.Chunk(string(content), fi.Path)
+		if limit := set.cfg.Indexing.MaxChunksPerFile; limit > 0 && len(chunks) > limit {
+			warnSampled(fi.RelPath, len(chunks), limit)
+			chunks = sampleChunks(chunks, limit)
+		}
+		if len(chunks) == 0 {
+			log.Debug("No chunks generated", "path", fi.RelPath)
+			idx.commitFiles(storeRecord, nil, 1, opts)
+			continue
+		}
+		if len(chunks) > maxTexts {
+			direct = append(direct, fi)
+			continue
+		}
+
+		if len(texts)+len(chunks) > maxTexts {
+			if err := submit(); err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, c := range chunks {
+			texts = append(texts, c.Content)
+		}
+		// The hash of the content that was read, in case the file changed
+		// since it was walked
+		current.Files = append(current.Files, store.BatchFile{
+			RelativePath: fi.RelPath,
+			Hash:         fs.HashContent(content),
+			Chunks:       len(chunks),
+		})
+
+		idx.mu.Lock()
+		idx.progress.TotalChunks += len(chunks)
+		idx.mu.Unlock()
+	}
+	if err := submit(); err != nil {
+		return nil, nil, err
+	}
+	return submitted, direct, nil
+}
+
+// waitBatches polls batches until each has finished, writing the files of
+// each as it completes. Files written are counted as processed if count is
+// set.
+func (idx *Indexer) waitBatches(ctx context.Context, batcher embeddings.BatchService, storeRecord *store.StoreRecord, batches []store.EmbeddingBatch, count bool, opts IndexOptions) error {
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	waiting := batches
+	for {
+		var still []store.EmbeddingBatch
+		completed, total := 0, 0
+		for _, b := range waiting {
+			job, err := batcher.GetBatch(ctx, b.JobID)
+			if err != nil {
+				return err
+			}
+			if !job.Done() {
+				still = append(still, b)
+				completed += job.Completed + job.Failed
+				total += job.Total
+				continue
+			}
+			if err := idx.collectBatch(ctx, batcher, storeRecord, b, job, count, opts); err != nil {
+				return err
+			}
+		}
+
+		waiting = still
+		if len(waiting) == 0 {
+			idx.setBatchStatus("", opts)
+			return nil
+		}
+		idx.setBatchStatus(fmt.Sprintf("%d embedding batches running, %d/%d requests done", len(waiting), completed, total), opts)
+
+		select {
+		case <-ctx.Done():
+			log.Info("Embedding batches are still running; index again to collect them", "count", len(waiting))
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// countFiles records files finished without going through commitFiles and
+// reports progress.
+func (idx *Indexer) countFiles(processed, errors int, opts IndexOptions) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.progress.ProcessedFiles += processed
+	idx.progress.Errors += errors
+	if opts.OnProgress != nil {
+		opts.OnProgress(idx.progress)
+	}
+}
+
+// setBatchStatus reports what the run is waiting for.
+func (idx *Indexer) setBatchStatus(status string, opts IndexOptions) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.progress.BatchStatus = status
+	if opts.OnProgress != nil {
+		opts.OnProgress(idx.progress)
+	}
+}
+
+// collectBatch writes the files of a finished batch and forgets the batch.
+// Files that changed since they were submitted, or whose chunks were not
+// all embedded, are left for the next run.
+func (idx *Indexer) collectBatch(ctx context.Context, batcher embeddings.BatchService, storeRecord *store.StoreRecord, b store.EmbeddingBatch, job *embeddings.BatchJob, count bool, opts IndexOptions) error {
+	if job.Status == embeddings.BatchFailed {
+		log.Warn("Embedding batch failed; its files will be indexed by the next run", "id", b.JobID, "reason", job.Detail)
+		if count {
+			idx.countFiles(0, len(b.Files), opts)
+		}
+		return idx.store.DeleteEmbeddingBatch(b.ID)
+	}
+
+	phase := time.Now()
+	results, err := batcher.BatchResults(ctx, b.JobID)
+	idx.addTime(&idx.embedTime, time.Since(phase))
+	if err != nil {
+		return err
+	}
+
+	set := idx.current.Load()
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+
+	var pending []store.FileUpsert
+	pendingChunks, failed := 0, 0
+	write := func() {
+		written := idx.writeFiles(storeRecord, pending)
+		if count {
+			idx.countFiles(written, len(pending)-written, opts)
+		}
+		pending, pendingChunks = nil, 0
+	}
+
+	offset := 0
+	for _, bf := range b.Files {
+		vectors := make([][]float32, bf.Chunks)
+		for i := range vectors {
+			if offset+i < len(results) {
+				vectors[i] = results[offset+i]
+			}
+		}
+		offset += bf.Chunks
+
+		upsert, err := idx.batchUpsert(set, storeRecord, bf, vectors)
+		if err != nil {
+			log.Warn("Failed to index file from embedding batch", "path", bf.RelativePath, "error", err)
+			failed++
+			continue
+		}
+		pending = append(pending, *upsert)
+		pendingChunks += len(upsert.Chunks)
+		if pendingChunks >= batchSize {
+			write()
+		}
+	}
+	write()
+
+	if count && failed > 0 {
+		idx.countFiles(0, failed, opts)
+	}
+	idx.mu.Lock()
+	idx.progress.ProcessedChunks += offset
+	idx.mu.Unlock()
+
+	return idx.store.DeleteEmbeddingBatch(b.ID)
+}
+
+// batchUpsert re-reads and re-chunks a file from a batch and pairs its
+// chunks with their embeddings. It fails if the file is no longer the one
+// that was submitted.
+func (idx *Indexer) batchUpsert(set *settings, storeRecord *store.StoreRecord, bf store.BatchFile, vectors [][]float32) (*store.FileUpsert, error) {
+	for _, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("the provider did not embed every chunk")
+		}
+	}
+
+	path := filepath.Join(storeRecord.RootPath, bf.RelativePath)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	hash := fs.HashContent(content)
+	if hash != bf.Hash {
+		return nil, fmt.Errorf("file changed since it was submitted")
+	}
+
+	chunks := sampleChunks(set.chunker.Chunk(string(content), path), set.cfg.Indexing.MaxChunksPerFile)
+	if len(chunks) != len(vectors) {
+		return nil, fmt.Errorf("chunking settings changed since the file was submitted")
+	}
+	storeChunks := make([]store.Chunk, len(chunks))
+	for i, c := range chunks {
+		storeChunks[i] = storeChunk(c)
+	}
+
+	fi := fs.FileInfo{Path: path, RelPath: bf.RelativePath, Size: int64(len(content)), Hash: hash}
+	return newUpsert(set, fi, content, storeChunks, vectors)
+}
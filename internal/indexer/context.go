@@ -0,0 +1,74 @@
+package indexer
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// lineWindow reads a file's lines forward, keeping only the lines still
+// needed, so the context of chunks can be collected without holding the
+// whole file.
+type lineWindow struct {
+	r     *bufio.Reader
+	first int      // Line number of lines[0], 1-indexed
+	lines []string // Lines split as strings.Split(content, "\n") would
+	eof   bool
+}
+
+// newLineWindow returns a lineWindow reading from r.
+func newLineWindow(r io.Reader) *lineWindow {
+	return &lineWindow{r: bufio.NewReader(r), first: 1}
+}
+
+// addContext sets the context of each chunk to up to n lines before and
+// after it. Chunks must be in file order.
+func (w *lineWindow) addContext(chunks []store.Chunk, n int) error {
+	for i := range chunks {
+		c := &chunks[i]
+		w.drop(c.StartLine - n)
+		if err := w.fill(c.EndLine + n); err != nil {
+			return err
+		}
+		c.ContextBefore = w.join(c.StartLine-n, c.StartLine-1)
+		c.ContextAfter = w.join(c.EndLine+1, c.EndLine+n)
+	}
+	return nil
+}
+
+// fill reads lines until line n is held or the input ends.
+func (w *lineWindow) fill(n int) error {
+	for !w.eof && w.first+len(w.lines) <= n {
+		line, err := w.r.ReadString('\n')
+		if err == io.EOF {
+			w.eof = true
+		} else if err != nil {
+			return err
+		}
+		w.lines = append(w.lines, strings.TrimSuffix(line, "\n"))
+	}
+	return nil
+}
+
+// drop discards the lines before line n.
+func (w *lineWindow) drop(n int) {
+	k := min(n-w.first, len(w.lines))
+	if k <= 0 {
+		return
+	}
+	w.lines = slices.Delete(w.lines, 0, k)
+	w.first += k
+}
+
+// join returns lines from through to, clamped to the lines held.
+func (w *lineWindow) join(from, to int) string {
+	from = max(from, w.first) - w.first
+	to = min(to-w.first+1, len(w.lines))
+	if from >= to {
+		return ""
+	}
+	return strings.Join(w.lines[from:to], "\n")
+}
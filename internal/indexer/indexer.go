@@ -2,17 +2,24 @@
 package indexer
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/cost"
 	"github.com/nickcecere/lgrep/internal/embeddings"
 	"github.com/nickcecere/lgrep/internal/fs"
+	"github.com/nickcecere/lgrep/internal/llm"
+	"github.com/nickcecere/lgrep/internal/lock"
 	"github.com/nickcecere/lgrep/internal/store"
 )
 
@@ -20,12 +27,37 @@ import (
 type Indexer struct {
 	store    store.Store
 	embedder embeddings.Service
-	chunker  *fs.TextChunker
-	cfg      *config.Config
+	current  atomic.Pointer[settings]
 
 	// Progress tracking
 	progress Progress
 	mu       sync.Mutex
+
+	// Time spent embedding and writing to the store during the current run
+	embedTime time.Duration
+	dbTime    time.Duration
+}
+
+// settings holds the configuration and the chunker built from it, replaced
+// together when the configuration is reloaded.
+type settings struct {
+	cfg     *config.Config
+	chunker *fs.TextChunker
+}
+
+// minChunkSize is the size below which chunks are merged with the next.
+const minChunkSize = 100
+
+// newSettings returns the settings for cfg.
+func newSettings(cfg *config.Config) *settings {
+	return &settings{
+		cfg: cfg,
+		chunker: fs.NewTextChunker(fs.ChunkOptions{
+			ChunkSize:    cfg.Indexing.ChunkSize,
+			ChunkOverlap: cfg.Indexing.ChunkOverlap,
+			MinChunkSize: minChunkSize,
+		}),
+	}
 }
 
 // Progress tracks indexing progress.
@@ -38,6 +70,18 @@ type Progress struct {
 	Errors          int
 	StartTime       time.Time
 	CurrentFile     string
+
+	// BatchStatus describes the embedding batches being waited for, when
+	// indexing with IndexOptions.Batch.
+	BatchStatus string
+
+	// PrunedFiles is the number of files removed by IndexOptions.Prune.
+	PrunedFiles int
+
+	// Delegated is set when the run waited for another process to index
+	// the store and, with LockDelegate, reused its result: nothing was
+	// indexed and the other counts are zero.
+	Delegated bool
 }
 
 // ProgressFunc is called to report progress during indexing.
@@ -57,39 +101,104 @@ type IndexOptions struct {
 	// IgnorePatterns are additional patterns to ignore.
 	IgnorePatterns []string
 
-	// Force re-indexes files even if unchanged.
+	// Force rebuilds the store from scratch: every file is re-indexed into
+	// a temporary store that replaces the existing contents only once the
+	// run succeeds, dropping files that no longer exist.
 	Force bool
 
-	// BatchSize is the number of chunks to embed in a single batch.
+	// BatchSize is roughly the number of chunks written per transaction.
+	// Files too large to fit in one batch are read and written a batch at a
+	// time. Embedding requests are sized by indexing.max_batch_tokens, or
+	// hold BatchSize chunks if it is zero.
 	BatchSize int
 
 	// OnProgress is called to report progress.
 	OnProgress ProgressFunc
+
+	// LockPolicy controls what happens when another process is already
+	// indexing the same store.
+	LockPolicy LockPolicy
+
+	// Batch embeds changed files through the provider's asynchronous batch
+	// API, which costs less but may take hours. Files are written once
+	// their batch completes, and batches left by an interrupted run are
+	// collected by the next one.
+	Batch bool
+
+	// PollInterval is how often batches are checked. Zero uses
+	// DefaultPollInterval.
+	PollInterval time.Duration
+
+	// Prune removes files from the store that were not found by this run,
+	// because they were deleted or are now ignored. It should not be
+	// combined with Extensions or IgnorePatterns that narrow an earlier
+	// run. Forced runs always drop such files.
+	Prune bool
+
+	// Trigger records what started the run in the store's run history, one
+	// of the store.Trigger constants. Empty means store.TriggerCLI.
+	Trigger string
 }
 
+// LockPolicy controls how Index coordinates with other processes indexing
+// the same store.
+type LockPolicy int
+
+const (
+	// LockWait queues behind the other run, then indexes.
+	LockWait LockPolicy = iota
+
+	// LockDelegate waits for the other run to finish and reuses its result
+	// instead of indexing again.
+	LockDelegate
+
+	// LockFail returns ErrIndexInProgress without waiting.
+	LockFail
+)
+
+// ErrIndexInProgress is returned when another process is indexing the store
+// and the lock policy is LockFail.
+var ErrIndexInProgress = errors.New("another lgrep process is indexing this store")
+
+// DefaultBatchSize is the default IndexOptions.BatchSize.
+const DefaultBatchSize = 50
+
 // DefaultIndexOptions returns sensible defaults.
 func DefaultIndexOptions() IndexOptions {
 	return IndexOptions{
-		BatchSize: 50,
+		BatchSize: DefaultBatchSize,
 	}
 }
 
 // New creates a new Indexer.
 func New(st store.Store, emb embeddings.Service, cfg *config.Config) *Indexer {
-	return &Indexer{
+	idx := &Indexer{
 		store:    st,
 		embedder: emb,
-		chunker: fs.NewTextChunker(fs.ChunkOptions{
-			ChunkSize:    cfg.Indexing.ChunkSize,
-			ChunkOverlap: cfg.Indexing.ChunkOverlap,
-			MinChunkSize: 100,
-		}),
-		cfg: cfg,
 	}
+	idx.current.Store(newSettings(cfg))
+	return idx
+}
+
+// SetConfig replaces the configuration, e.g. after the config file was
+// reloaded. Files already being indexed finish with the old settings.
+func (idx *Indexer) SetConfig(cfg *config.Config) {
+	idx.current.Store(newSettings(cfg))
+}
+
+// config returns the current configuration.
+func (idx *Indexer) config() *config.Config {
+	return idx.current.Load().cfg
 }
 
 // Index indexes files from the given path into the store.
-func (idx *Indexer) Index(ctx context.Context, opts IndexOptions) error {
+func (idx *Indexer) Index(ctx context.Context, opts IndexOptions) (err error) {
+	// A forced rebuild is discarded when interrupted, which would orphan
+	// its batches
+	if opts.Batch && opts.Force {
+		return fmt.Errorf("batch embedding cannot be combined with a forced re-index")
+	}
+
 	// Resolve path
 	absPath, err := filepath.Abs(opts.Path)
 	if err != nil {
@@ -108,14 +217,52 @@ func (idx *Indexer) Index(ctx context.Context, opts IndexOptions) error {
 	// Get or create the store
 	storeName := opts.StoreName
 	if storeName == "" {
-		storeName = filepath.Base(absPath)
+		storeName = StoreName(idx.config(), absPath)
+	}
+
+	// Follow aliases so a renamed store is locked under its current name
+	if existing, err := idx.store.GetStore(storeName); err == nil && existing != nil {
+		storeName = existing.Name
+	}
+
+	// Make sure only one process indexes the store at a time
+	storeLock, delegated, err := idx.lockStore(ctx, storeName, opts.LockPolicy)
+	if err != nil {
+		return err
+	}
+	if delegated {
+		idx.mu.Lock()
+		idx.progress = Progress{Delegated: true}
+		idx.mu.Unlock()
+		return nil
 	}
+	defer storeLock.Release()
 
 	storeRecord, err := idx.getOrCreateStore(storeName, absPath)
 	if err != nil {
 		return err
 	}
 
+	// A forced run indexes into a temporary store and swaps it in at the end
+	target := storeRecord
+	if !opts.Force {
+		if err := idx.removeRebuildStore(storeName); err != nil {
+			log.Warn("Failed to remove temporary store", "error", err)
+		}
+	} else {
+		storeRecord, err = idx.createRebuildStore(storeName, absPath)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if storeRecord.ID != target.ID {
+				if err := idx.store.DeleteStore(storeRecord.Name); err != nil {
+					log.Warn("Failed to remove temporary store", "store", storeRecord.Name, "error", err)
+				}
+			}
+		}()
+	}
+
 	// Check context
 	select {
 	case <-ctx.Done():
@@ -128,17 +275,26 @@ func (idx *Indexer) Index(ctx context.Context, opts IndexOptions) error {
 	idx.progress = Progress{
 		StartTime: time.Now(),
 	}
+	idx.embedTime, idx.dbTime = 0, 0
 	idx.mu.Unlock()
 
+	// What this run finds and produces, for its warnings
+	tally := &runStats{}
+
+	// Record the run in the store's history. While it runs, the record also
+	// shows which process holds the lock.
+	run := &store.IndexRun{StoreID: target.ID, Trigger: opts.Trigger}
+	if run.Trigger == "" {
+		run.Trigger = store.TriggerCLI
+	}
+	if err := idx.store.AddIndexRun(run); err != nil {
+		log.Debug("Failed to record index run", "error", err)
+	} else {
+		defer func() { idx.finishRun(run, err) }()
+	}
+
 	// Create file walker
-	walker, err := fs.NewFileWalker(fs.WalkOptions{
-		Root:           absPath,
-		MaxFileSize:    int64(idx.cfg.Indexing.MaxFileSize),
-		MaxFileCount:   idx.cfg.Indexing.MaxFileCount,
-		IgnorePatterns: append(idx.cfg.Ignore, opts.IgnorePatterns...),
-		UseGitignore:   true,
-		Extensions:     opts.Extensions,
-	})
+	walker, err := fs.NewFileWalker(walkOptions(idx.config(), absPath, opts.Extensions, opts.IgnorePatterns))
 	if err != nil {
 		return fmt.Errorf("failed to create file walker: %w", err)
 	}
@@ -156,13 +312,47 @@ func (idx *Indexer) Index(ctx context.Context, opts IndexOptions) error {
 	idx.mu.Lock()
 	idx.progress.TotalFiles = len(files)
 	idx.mu.Unlock()
+	tally.walk = walker.Stats()
+
+	// Note what was found before files is narrowed by batching
+	var found map[string]bool
+	if opts.Prune && !opts.Force {
+		found = make(map[string]bool, len(files))
+		for _, fi := range files {
+			found[fi.RelPath] = true
+		}
+	}
 
 	log.Info("Found files to index", "count", len(files))
 
-	// Process files
+	// Process files. Embedded files are committed in batches of about
+	// BatchSize chunks, and progress only counts committed files, so an
+	// interrupted run leaves the store at the last reported checkpoint.
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+
+	// Files too large for a batch are indexed directly below
+	if opts.Batch {
+		files, err = idx.indexBatches(ctx, storeRecord, files, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	var pending []store.FileUpsert
+	pendingChunks, pendingSkipped := 0, 0
+	commit := func() {
+		idx.commitFiles(storeRecord, pending, pendingSkipped, opts)
+		pending, pendingChunks, pendingSkipped = nil, 0, 0
+	}
+
 	for _, fi := range files {
 		select {
 		case <-ctx.Done():
+			// Keep the files that were already embedded
+			commit()
 			return ctx.Err()
 		default:
 		}
@@ -171,7 +361,30 @@ func (idx *Indexer) Index(ctx context.Context, opts IndexOptions) error {
 		idx.progress.CurrentFile = fi.RelPath
 		idx.mu.Unlock()
 
-		if err := idx.indexFile(ctx, storeRecord, fi, opts); err != nil {
+		if idx.streamsFile(fi, batchSize) {
+			// Too large to hold in a batch; write it on its own as it is
+			// embedded, after the files already pending
+			commit()
+			if err := idx.streamFile(ctx, storeRecord, fi, opts, tally); err != nil {
+				if errors.Is(err, cost.ErrBudgetExceeded) || ctx.Err() != nil {
+					return err
+				}
+				log.Warn("Failed to index file", "path", fi.RelPath, "error", err)
+				idx.mu.Lock()
+				idx.progress.Errors++
+				idx.mu.Unlock()
+				continue
+			}
+			idx.commitFiles(storeRecord, nil, 1, opts)
+			continue
+		}
+
+		upsert, err := idx.prepareFile(ctx, storeRecord, fi, opts, tally)
+		if err != nil {
+			if errors.Is(err, cost.ErrBudgetExceeded) {
+				commit()
+				return err
+			}
 			log.Warn("Failed to index file", "path", fi.RelPath, "error", err)
 			idx.mu.Lock()
 			idx.progress.Errors++
@@ -179,12 +392,41 @@ func (idx *Indexer) Index(ctx context.Context, opts IndexOptions) error {
 			continue
 		}
 
-		idx.mu.Lock()
-		idx.progress.ProcessedFiles++
-		if opts.OnProgress != nil {
-			opts.OnProgress(idx.progress)
+		if upsert == nil {
+			// Unchanged or empty; nothing to write
+			pendingSkipped++
+			if len(pending) == 0 {
+				commit()
+			}
+			continue
+		}
+
+		pending = append(pending, *upsert)
+		pendingChunks += len(upsert.Chunks)
+		if pendingChunks >= batchSize {
+			commit()
+		}
+	}
+	commit()
+
+	if found != nil {
+		if err := idx.prune(storeRecord, found); err != nil {
+			return err
 		}
-		idx.mu.Unlock()
+	}
+
+	if opts.Force {
+		if err := idx.store.ReplaceStoreContents(target.ID, storeRecord.ID); err != nil {
+			return fmt.Errorf("failed to replace store contents: %w", err)
+		}
+		storeRecord = target
+
+		// Return the space used by the old contents to the filesystem
+		phase := time.Now()
+		if err := idx.store.Vacuum(); err != nil {
+			log.Debug("Failed to vacuum database", "error", err)
+		}
+		idx.addTime(&idx.dbTime, time.Since(phase))
 	}
 
 	// Update store timestamp
@@ -192,6 +434,43 @@ func (idx *Indexer) Index(ctx context.Context, opts IndexOptions) error {
 		log.Warn("Failed to update store timestamp", "error", err)
 	}
 
+	// Sample the store's score distribution so relevance is comparable
+	// across models, unless the store has changed little since the last time
+	phase := time.Now()
+	if _, err := idx.store.CalibrateScores(storeRecord.ID); err != nil {
+		log.Warn("Failed to calibrate scores", "error", err)
+	}
+	idx.addTime(&idx.dbTime, time.Since(phase))
+
+	// Resolve imports to the files they refer to, for 'lgrep related'
+	if idx.config().Indexing.Relations {
+		phase = time.Now()
+		idx.mu.Lock()
+		since := idx.progress.StartTime.Truncate(time.Second)
+		idx.mu.Unlock()
+		if err := idx.updateRelations(storeRecord, since); err != nil {
+			log.Warn("Failed to record file relations", "error", err)
+		}
+		idx.addTime(&idx.dbTime, time.Since(phase))
+	}
+
+	// Record timings for 'lgrep metrics'
+	idx.mu.Lock()
+	metric := &store.Metric{
+		Event:     store.MetricIndex,
+		StoreName: storeName,
+		Duration:  time.Since(idx.progress.StartTime),
+		Embed:     idx.embedTime,
+		DB:        idx.dbTime,
+		Count:     idx.progress.ProcessedFiles,
+	}
+	idx.mu.Unlock()
+	if err := idx.store.AddMetric(metric); err != nil {
+		log.Debug("Failed to record index metric", "error", err)
+	}
+
+	logWarnings(diagnose(*tally))
+
 	// Get final stats
 	stats, err := idx.store.GetStats(storeRecord.ID)
 	if err == nil {
@@ -205,8 +484,75 @@ func (idx *Indexer) Index(ctx context.Context, opts IndexOptions) error {
 	return nil
 }
 
+// finishRun records the outcome of a run started by Index.
+func (idx *Indexer) finishRun(run *store.IndexRun, err error) {
+	idx.mu.Lock()
+	run.Files = idx.progress.ProcessedFiles
+	run.Skipped = idx.progress.SkippedFiles
+	run.Removed = idx.progress.PrunedFiles
+	run.Errors = idx.progress.Errors
+	idx.mu.Unlock()
+
+	switch {
+	case err == nil:
+		run.Status = store.RunOK
+	case errors.Is(err, context.Canceled):
+		run.Status = store.RunCancelled
+	default:
+		run.Status = store.RunFailed
+		run.Error = err.Error()
+	}
+
+	if err := idx.store.FinishIndexRun(run); err != nil {
+		log.Debug("Failed to record index run", "error", err)
+	}
+}
+
+// lockStore takes the per-store index lock according to policy. It reports
+// delegated=true if another process indexed the store while we waited and
+// the policy says to reuse its result.
+func (idx *Indexer) lockStore(ctx context.Context, storeName string, policy LockPolicy) (l *lock.Lock, delegated bool, err error) {
+	// Without a database path there is nothing shared to coordinate on
+	if idx.config().Database.Path == "" {
+		return nil, false, nil
+	}
+
+	path := lock.NamespaceStorePath(idx.config().Database.Path, idx.config().Database.Namespace, storeName)
+
+	l, err = lock.TryAcquire(path)
+	if err == nil {
+		return l, false, nil
+	}
+	if !errors.Is(err, lock.ErrLocked) {
+		return nil, false, fmt.Errorf("failed to lock store: %w", err)
+	}
+
+	holder := lock.Holder(path)
+	if policy == LockFail {
+		return nil, false, fmt.Errorf("%w (held by %s)", ErrIndexInProgress, holder)
+	}
+
+	log.Info("Waiting for another lgrep process to finish indexing", "store", storeName, "holder", holder)
+	l, err = lock.Acquire(ctx, path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if policy == LockDelegate {
+		l.Release()
+		log.Info("Store was indexed by another process", "store", storeName)
+		return nil, true, nil
+	}
+
+	return l, false, nil
+}
+
 // getOrCreateStore gets an existing store or creates a new one.
 func (idx *Indexer) getOrCreateStore(name, path string) (*store.StoreRecord, error) {
+	if store.IsRebuildStore(name) {
+		return nil, fmt.Errorf("invalid store name: %w", store.ErrReservedName)
+	}
+
 	// Check if store exists
 	existing, err := idx.store.GetStore(name)
 	if err != nil {
@@ -217,80 +563,495 @@ func (idx *Indexer) getOrCreateStore(name, path string) (*store.StoreRecord, err
 		if existing.RootPath != path {
 			log.Warn("Store path mismatch", "stored", existing.RootPath, "requested", path)
 		}
+		if metric := idx.distanceMetric(); metric != existing.DistanceMetric {
+			log.Warn("Store keeps the distance metric it was created with; re-index with --force to change it",
+				"store", existing.DistanceMetric, "configured", metric)
+		}
+		idx.resizeVectors(existing)
 		return existing, nil
 	}
 
 	// Create new store
 	log.Info("Creating new store", "name", name, "path", path)
+	storeRecord, err := idx.createStore(name, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %w", err)
+	}
+
+	return storeRecord, nil
+}
+
+// resizeVectors shrinks the vectors to the embedder's dimensions when only
+// they changed for the store's model, e.g. with a lower
+// embeddings.truncate_dimensions, by truncating the embeddings stored with
+// the chunks instead of embedding every chunk again.
+func (idx *Indexer) resizeVectors(storeRecord *store.StoreRecord) {
+	primary := embeddings.Primary(idx.embedder)
+	dimensions := primary.Dimensions()
+	if dimensions <= 0 || dimensions == storeRecord.EmbeddingDimensions {
+		return
+	}
+	if string(primary.Provider()) != string(storeRecord.EmbeddingProvider) || primary.ModelName() != storeRecord.EmbeddingModel ||
+		dimensions > storeRecord.EmbeddingDimensions {
+		log.Warn("Store was indexed with other embeddings; re-index with --force to use the configured ones",
+			"store", fmt.Sprintf("%s/%s %d", storeRecord.EmbeddingProvider, storeRecord.EmbeddingModel, storeRecord.EmbeddingDimensions),
+			"configured", fmt.Sprintf("%s/%s %d", primary.Provider(), primary.ModelName(), dimensions))
+		return
+	}
+
+	resized, err := idx.store.ResizeVectors(dimensions, func(embedding []float32) []float32 {
+		return embeddings.Truncate(embedding, dimensions)
+	})
+	if err != nil {
+		log.Warn("Failed to truncate the stored embeddings; re-index with --force to embed them again",
+			"dimensions", dimensions, "error", err)
+		return
+	}
+	log.Info("Truncated the stored embeddings instead of embedding again", "vectors", resized, "dimensions", dimensions)
+	storeRecord.EmbeddingDimensions = dimensions
+}
+
+// createStore creates a store for the embedder's model, searched with the
+// configured distance metric. The store records the configured provider,
+// even if a fallback is serving requests at the moment.
+func (idx *Indexer) createStore(name, path string) (*store.StoreRecord, error) {
+	primary := embeddings.Primary(idx.embedder)
+	metric := idx.distanceMetric()
+	if metric == config.MetricDot && !embeddings.Normalized(idx.embedder) {
+		return nil, fmt.Errorf("the dot distance metric needs normalized embeddings, which %s model %s is not known to return; use cosine or l2",
+			primary.Provider(), primary.ModelName())
+	}
+
 	storeRecord, err := idx.store.CreateStore(
 		name,
 		path,
-		store.EmbeddingProvider(string(idx.embedder.Provider())),
-		idx.embedder.ModelName(),
-		idx.embedder.Dimensions(),
+		store.EmbeddingProvider(string(primary.Provider())),
+		primary.ModelName(),
+		primary.Dimensions(),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create store: %w", err)
+		return nil, err
+	}
+
+	if metric != storeRecord.DistanceMetric {
+		if err := idx.store.SetDistanceMetric(storeRecord.ID, metric); err != nil {
+			return nil, fmt.Errorf("failed to set distance metric: %w", err)
+		}
+		storeRecord.DistanceMetric = metric
 	}
 
 	return storeRecord, nil
 }
 
-// indexFile indexes a single file.
-func (idx *Indexer) indexFile(ctx context.Context, storeRecord *store.StoreRecord, fi fs.FileInfo, opts IndexOptions) error {
-	// Check if file needs re-indexing
-	if !opts.Force {
-		existing, err := idx.store.GetFileByExternalID(storeRecord.ID, fi.RelPath)
+// distanceMetric returns the configured distance metric for new stores.
+func (idx *Indexer) distanceMetric() config.DistanceMetric {
+	if metric := idx.config().Embeddings.DistanceMetric; metric != "" {
+		return metric
+	}
+	return config.MetricCosine
+}
+
+// createRebuildStore creates an empty temporary store for a forced rebuild
+// of the store called name. Its name is reserved, so it cannot collide with
+// a user's store, and it is left out of store listings.
+func (idx *Indexer) createRebuildStore(name, path string) (*store.StoreRecord, error) {
+	if err := idx.removeRebuildStore(name); err != nil {
+		return nil, err
+	}
+	storeRecord, err := idx.createStore(store.RebuildStoreName(name), path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary store: %w", err)
+	}
+	return storeRecord, nil
+}
+
+// removeRebuildStore removes the temporary store left behind by a forced
+// rebuild of the store called name that crashed or was killed. The caller
+// holds the store's lock, so no rebuild of it is running.
+func (idx *Indexer) removeRebuildStore(name string) error {
+	rebuildName := store.RebuildStoreName(name)
+	stale, err := idx.store.GetStore(rebuildName)
+	if err != nil {
+		return fmt.Errorf("failed to check for temporary store: %w", err)
+	}
+	if stale == nil {
+		return nil
+	}
+	log.Debug("Removing temporary store of an interrupted rebuild", "store", rebuildName)
+	if err := idx.store.DeleteStore(rebuildName); err != nil {
+		return fmt.Errorf("failed to remove stale temporary store: %w", err)
+	}
+	return nil
+}
+
+// commitFiles writes a batch of embedded files in one transaction and counts
+// them, along with skipped files since the last commit, as processed.
+func (idx *Indexer) commitFiles(storeRecord *store.StoreRecord, files []store.FileUpsert, skipped int, opts IndexOptions) {
+	committed := idx.writeFiles(storeRecord, files)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.progress.Errors += len(files) - committed
+	idx.progress.ProcessedFiles += committed + skipped
+	if opts.OnProgress != nil && (len(files) > 0 || skipped > 0) {
+		opts.OnProgress(idx.progress)
+	}
+}
+
+// writeFiles writes files to the store in one transaction, logging those
+// that fail, and returns how many were written.
+func (idx *Indexer) writeFiles(storeRecord *store.StoreRecord, files []store.FileUpsert) int {
+	if len(files) == 0 {
+		return 0
+	}
+
+	phase := time.Now()
+	err := idx.store.UpsertFiles(storeRecord.ID, files)
+	idx.addTime(&idx.dbTime, time.Since(phase))
+
+	committed := len(files)
+	var upsertErr *store.UpsertError
+	switch {
+	case errors.As(err, &upsertErr):
+		for path, fileErr := range upsertErr.Files {
+			log.Warn("Failed to store file", "path", path, "error", fileErr)
+		}
+		committed -= len(upsertErr.Files)
+	case err != nil:
+		log.Warn("Failed to store files", "count", len(files), "error", err)
+		committed = 0
+	}
+
+	for _, f := range files {
+		log.Debug("Indexed file", "path", f.File.RelativePath, "chunks", len(f.Chunks))
+	}
+	return committed
+}
+
+// indexFile indexes a single file, adding what it read and embedded to stats.
+func (idx *Indexer) indexFile(ctx context.Context, storeRecord *store.StoreRecord, fi fs.FileInfo, opts IndexOptions, stats *runStats) error {
+	if idx.streamsFile(fi, opts.BatchSize) {
+		return idx.streamFile(ctx, storeRecord, fi, opts, stats)
+	}
+
+	upsert, err := idx.prepareFile(ctx, storeRecord, fi, opts, stats)
+	if err != nil || upsert == nil {
+		return err
+	}
+
+	phase := time.Now()
+	err = idx.store.UpsertFile(storeRecord.ID, upsert.File, upsert.Chunks, upsert.Embeddings)
+	if err != nil {
+		return fmt.Errorf("failed to store file: %w", err)
+	}
+	idx.addTime(&idx.dbTime, time.Since(phase))
+
+	log.Debug("Indexed file", "path", fi.RelPath, "chunks", len(upsert.Chunks))
+	return nil
+}
+
+// prepareFile chunks and embeds a file, returning nil if the file is
+// unchanged or has no content to index. What it read and embedded is added
+// to stats.
+func (idx *Indexer) prepareFile(ctx context.Context, storeRecord *store.StoreRecord, fi fs.FileInfo, opts IndexOptions, stats *runStats) (*store.FileUpsert, error) {
+	if !opts.Force && idx.fileUnchanged(storeRecord, fi) {
+		return nil, nil
+	}
+	set := idx.current.Load()
+
+	// Binary files are indexed by name alone
+	var content []byte
+	var chunks []fs.Chunk
+	if fi.Binary {
+		chunks = []fs.Chunk{fs.NameChunk(fi.RelPath)}
+	} else {
+		var err error
+		if content, err = os.ReadFile(fi.Path); err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		chunks = set.chunker.Chunk(string(content), fi.Path)
+	}
+	if limit := set.cfg.Indexing.MaxChunksPerFile; limit > 0 && len(chunks) > limit {
+		warnSampled(fi.RelPath, len(chunks), limit)
+		chunks = sampleChunks(chunks, limit)
+	}
+	if !fi.Binary {
+		stats.read++
+	}
+	if len(chunks) == 0 {
+		stats.empty++
+	}
+	idx.mu.Lock()
+	idx.progress.TotalChunks += len(chunks)
+	idx.mu.Unlock()
+	if len(chunks) == 0 {
+		log.Debug("No chunks generated", "path", fi.RelPath)
+		return nil, nil
+	}
+
+	storeChunks, embeddings, err := idx.embedChunks(ctx, chunks, opts, stats)
+	if err != nil {
+		return nil, err
+	}
+	return newUpsert(set, fi, content, storeChunks, embeddings)
+}
+
+// newUpsert describes an embedded file for the store, adding the context
+// around each chunk and the file's content as configured.
+func newUpsert(set *settings, fi fs.FileInfo, content []byte, chunks []store.Chunk, embeddings [][]float32) (*store.FileUpsert, error) {
+	// Keep the surrounding lines for context when the file is not on disk
+	if n := set.cfg.Indexing.ContextLines; n > 0 {
+		if err := newLineWindow(bytes.NewReader(content)).addContext(chunks, n); err != nil {
+			return nil, fmt.Errorf("failed to read context: %w", err)
+		}
+	}
+
+	file := fileInput(fi)
+	file.Imports = fs.ExtractImports(string(content), fs.DetectLanguage(fi.Path), fi.RelPath)
+	file.Generated = fs.IsGenerated(fi.RelPath, content)
+	if set.cfg.Indexing.StoreContent {
+		file.Content = string(content)
+	}
+
+	return &store.FileUpsert{
+		File:       file,
+		Chunks:     chunks,
+		Embeddings: embeddings,
+	}, nil
+}
+
+// streamsFile reports whether fi is large enough to produce more than one
+// batch of chunks, in which case it is indexed with streamFile.
+func (idx *Indexer) streamsFile(fi fs.FileInfo, batchSize int) bool {
+	if fi.Binary {
+		return false
+	}
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+	chunkSize := idx.config().Indexing.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = fs.DefaultChunkOptions().ChunkSize
+	}
+	return fi.Size > int64(batchSize*chunkSize)
+}
+
+// streamFile indexes a large file without holding all of it in memory. The
+// file is chunked as it is read, and each batch of chunks is embedded and
+// written before the next is read. The file's hash is recorded only once
+// every chunk is written, so a file interrupted part-way is re-indexed by
+// the next run. What it read and embedded is added to stats.
+func (idx *Indexer) streamFile(ctx context.Context, storeRecord *store.StoreRecord, fi fs.FileInfo, opts IndexOptions, stats *runStats) error {
+	if !opts.Force && idx.fileUnchanged(storeRecord, fi) {
+		return nil
+	}
+
+	set := idx.current.Load()
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+
+	f, err := os.Open(fi.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	// Context lines are read through a second handle that follows the chunks
+	contextLines := set.cfg.Indexing.ContextLines
+	var window *lineWindow
+	if contextLines > 0 {
+		cf, err := os.Open(fi.Path)
 		if err != nil {
-			log.Debug("Error checking existing file", "path", fi.RelPath, "error", err)
-		} else if existing != nil && existing.Hash == fi.Hash {
-			log.Debug("File unchanged, skipping", "path", fi.RelPath)
-			idx.mu.Lock()
-			idx.progress.SkippedFiles++
-			idx.mu.Unlock()
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		defer cf.Close()
+		window = newLineWindow(cf)
+	}
+
+	var fileID int64
+	total := 0
+	batch := make([]fs.Chunk, 0, batchSize)
+	sampler := newChunkSampler(set.cfg.Indexing.MaxChunksPerFile)
+
+	flush := func() error {
+		if len(batch) == 0 {
 			return nil
 		}
+
+		idx.mu.Lock()
+		idx.progress.TotalChunks += len(batch)
+		idx.mu.Unlock()
+
+		storeChunks, embeddings, err := idx.embedChunks(ctx, batch, opts, stats)
+		if err != nil {
+			return err
+		}
+		if window != nil {
+			if err := window.addContext(storeChunks, contextLines); err != nil {
+				return fmt.Errorf("failed to read context: %w", err)
+			}
+		}
+
+		phase := time.Now()
+		if total == 0 {
+			// Imports come first, so the first batch holds them
+			file := fileInput(fi)
+			file.Imports = fs.ExtractImports(joinChunks(batch), fs.DetectLanguage(fi.Path), fi.RelPath)
+			file.Generated = fs.IsGenerated(fi.RelPath, []byte(batch[0].Content))
+			fileID, err = idx.store.BeginFile(storeRecord.ID, file)
+			if err != nil {
+				return fmt.Errorf("failed to store file: %w", err)
+			}
+		}
+		if err := idx.store.AppendChunks(fileID, storeChunks, embeddings); err != nil {
+			return fmt.Errorf("failed to store chunks: %w", err)
+		}
+		idx.addTime(&idx.dbTime, time.Since(phase))
+
+		total += len(batch)
+		batch = batch[:0]
+		return nil
 	}
 
-	// Read file content
-	content, err := os.ReadFile(fi.Path)
+	add := func(c fs.Chunk) error {
+		batch = append(batch, c)
+		if len(batch) < batchSize {
+			return nil
+		}
+		return flush()
+	}
+	err = set.chunker.ChunkStream(f, fi.Path, func(c fs.Chunk) error {
+		if !sampler.add(c) {
+			return nil
+		}
+		return add(c)
+	})
+	// The last chunks of a file over the limit are only known at its end
+	for _, c := range sampler.rest() {
+		if err != nil {
+			break
+		}
+		err = add(c)
+	}
+	if err == nil {
+		err = flush()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return err
+	}
+	if n := sampler.dropped(); n > 0 {
+		warnSampled(fi.RelPath, total+n, total)
 	}
 
-	// Chunk the content
-	chunks := idx.chunker.Chunk(string(content), fi.Path)
-	if len(chunks) == 0 {
+	stats.read++
+	if total == 0 {
+		stats.empty++
+	}
+	if total == 0 {
 		log.Debug("No chunks generated", "path", fi.RelPath)
 		return nil
 	}
 
+	phase := time.Now()
+	if err := idx.store.FinishFile(fileID, fi.Hash); err != nil {
+		return fmt.Errorf("failed to store file: %w", err)
+	}
+	idx.addTime(&idx.dbTime, time.Since(phase))
+
+	log.Debug("Indexed file", "path", fi.RelPath, "chunks", total)
+	return nil
+}
+
+// prune removes the files of the store that are not in found.
+func (idx *Indexer) prune(storeRecord *store.StoreRecord, found map[string]bool) error {
+	phase := time.Now()
+	defer func() { idx.addTime(&idx.dbTime, time.Since(phase)) }()
+
+	return idx.store.WalkFiles(storeRecord.ID, nil, func(f store.FileRecord) error {
+		if found[f.ExternalID] {
+			return nil
+		}
+		if err := idx.store.DeleteFile(storeRecord.ID, f.ExternalID); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", f.RelativePath, err)
+		}
+		log.Debug("Removed file no longer found", "path", f.RelativePath)
+
+		idx.mu.Lock()
+		idx.progress.PrunedFiles++
+		idx.mu.Unlock()
+		return nil
+	})
+}
+
+// fileUnchanged reports whether the store already holds fi at its current
+// hash, counting it as skipped if so.
+func (idx *Indexer) fileUnchanged(storeRecord *store.StoreRecord, fi fs.FileInfo) bool {
+	existing, err := idx.store.GetFileByExternalID(storeRecord.ID, fi.RelPath)
+	if err != nil {
+		log.Debug("Error checking existing file", "path", fi.RelPath, "error", err)
+		return false
+	}
+	if existing == nil || existing.Hash != fi.Hash {
+		return false
+	}
+
+	log.Debug("File unchanged, skipping", "path", fi.RelPath)
 	idx.mu.Lock()
-	idx.progress.TotalChunks += len(chunks)
+	idx.progress.SkippedFiles++
 	idx.mu.Unlock()
+	return true
+}
 
-	// Generate embeddings in batches
-	batchSize := opts.BatchSize
+// maxRequestChunks caps the chunks sent in one embedding request when
+// requests are sized by tokens, below OpenAI's limit of 2048 inputs.
+const maxRequestChunks = 256
+
+// requestBatches splits chunks into embedding requests. Each request holds
+// as many chunks as fit in indexing.max_batch_tokens estimated tokens, up to
+// maxRequestChunks; a chunk larger than the limit is sent on its own. If no
+// token limit is set, each request holds batchSize chunks.
+func (idx *Indexer) requestBatches(chunks []fs.Chunk, batchSize int) [][]fs.Chunk {
 	if batchSize <= 0 {
 		batchSize = 50
 	}
+	maxTokens := idx.config().Indexing.MaxBatchTokens
+
+	var batches [][]fs.Chunk
+	start, tokens := 0, 0
+	for i, c := range chunks {
+		n := llm.EstimateTokens(c.Content)
+		full := i-start == batchSize
+		if maxTokens > 0 {
+			full = i-start == maxRequestChunks || tokens+n > maxTokens
+		}
+		if full && i > start {
+			batches = append(batches, chunks[start:i])
+			start, tokens = i, 0
+		}
+		tokens += n
+	}
+	if start < len(chunks) {
+		batches = append(batches, chunks[start:])
+	}
+	return batches
+}
 
-	var storeChunks []store.Chunk
-	var allEmbeddings [][]float32
+// embedChunks generates embeddings for chunks, in requests sized by
+// requestBatches, adding them to stats.
+func (idx *Indexer) embedChunks(ctx context.Context, chunks []fs.Chunk, opts IndexOptions, stats *runStats) ([]store.Chunk, [][]float32, error) {
+	storeChunks := make([]store.Chunk, 0, len(chunks))
+	allEmbeddings := make([][]float32, 0, len(chunks))
 
-	for i := 0; i < len(chunks); i += batchSize {
+	for _, batch := range idx.requestBatches(chunks, opts.BatchSize) {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return nil, nil, ctx.Err()
 		default:
 		}
 
-		end := i + batchSize
-		if end > len(chunks) {
-			end = len(chunks)
-		}
-		batch := chunks[i:end]
-
 		// Extract text for embedding
 		texts := make([]string, len(batch))
 		for j, c := range batch {
@@ -298,22 +1059,23 @@ func (idx *Indexer) indexFile(ctx context.Context, storeRecord *store.StoreRecor
 		}
 
 		// Generate embeddings
+		phase := time.Now()
 		embeddingVectors, err := idx.embedder.EmbedBatch(ctx, texts)
 		if err != nil {
-			return fmt.Errorf("failed to generate embeddings: %w", err)
+			return nil, nil, fmt.Errorf("failed to generate embeddings: %w", err)
 		}
+		idx.addTime(&idx.embedTime, time.Since(phase))
 
 		// Create store chunks
 		for j, c := range batch {
-			storeChunks = append(storeChunks, store.Chunk{
-				Content:    c.Content,
-				StartLine:  c.StartLine,
-				EndLine:    c.EndLine,
-				ChunkIndex: c.ChunkIndex,
-			})
+			storeChunks = append(storeChunks, storeChunk(c))
 			allEmbeddings = append(allEmbeddings, embeddingVectors[j])
 		}
 
+		stats.chunks += len(batch)
+		for _, c := range batch {
+			stats.chunkBytes += int64(len(c.Content))
+		}
 		idx.mu.Lock()
 		idx.progress.ProcessedChunks += len(batch)
 		if opts.OnProgress != nil {
@@ -322,22 +1084,48 @@ func (idx *Indexer) indexFile(ctx context.Context, storeRecord *store.StoreRecor
 		idx.mu.Unlock()
 	}
 
-	// Upsert file with chunks
-	fileInput := store.FileInput{
+	return storeChunks, allEmbeddings, nil
+}
+
+// storeChunk converts a chunk of a file for the store.
+func storeChunk(c fs.Chunk) store.Chunk {
+	return store.Chunk{
+		Content:    c.Content,
+		StartLine:  c.StartLine,
+		EndLine:    c.EndLine,
+		ChunkIndex: c.ChunkIndex,
+		Symbols:    c.Symbols,
+	}
+}
+
+// joinChunks returns the content of consecutive chunks.
+func joinChunks(chunks []fs.Chunk) string {
+	var b strings.Builder
+	for _, c := range chunks {
+		b.WriteString(c.Content)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// fileInput describes fi for the store.
+func fileInput(fi fs.FileInfo) store.FileInput {
+	return store.FileInput{
 		ExternalID:   fi.RelPath,
 		Path:         fi.Path,
 		RelativePath: fi.RelPath,
 		Hash:         fi.Hash,
 		FileSize:     fi.Size,
+		Language:     fi.Language,
+		ModifiedAt:   fi.ModTime,
 	}
+}
 
-	err = idx.store.UpsertFile(storeRecord.ID, fileInput, storeChunks, allEmbeddings)
-	if err != nil {
-		return fmt.Errorf("failed to store file: %w", err)
-	}
-
-	log.Debug("Indexed file", "path", fi.RelPath, "chunks", len(storeChunks))
-	return nil
+// addTime adds d to one of the run's phase timers.
+func (idx *Indexer) addTime(timer *time.Duration, d time.Duration) {
+	idx.mu.Lock()
+	*timer += d
+	idx.mu.Unlock()
 }
 
 // Progress returns the current indexing progress.
@@ -347,34 +1135,45 @@ func (idx *Indexer) Progress() Progress {
 	return idx.progress
 }
 
-// IndexSingleFile indexes a single file by its absolute path.
+// IndexSingleFile indexes a single file by its absolute path, reporting
+// whether it was indexed. A file the store already holds with the same
+// content is left alone, and one with no content left to index is removed.
 // This is used by the watcher for incremental updates.
-func (idx *Indexer) IndexSingleFile(ctx context.Context, storeName, rootPath, filePath string) error {
+func (idx *Indexer) IndexSingleFile(ctx context.Context, storeName, rootPath, filePath string) (bool, error) {
 	// Get or create the store
 	storeRecord, err := idx.getOrCreateStore(storeName, rootPath)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Get file info
 	info, err := os.Stat(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to stat file: %w", err)
+		return false, fmt.Errorf("failed to stat file: %w", err)
 	}
 
 	// Calculate relative path
 	relPath, err := filepath.Rel(rootPath, filePath)
 	if err != nil {
-		return fmt.Errorf("failed to get relative path: %w", err)
+		return false, fmt.Errorf("failed to get relative path: %w", err)
 	}
 
 	// Compute file hash
 	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return false, fmt.Errorf("failed to read file: %w", err)
 	}
 	hash := fs.HashContent(content)
 
+	// Editors often save without changing anything
+	existing, err := idx.store.GetFileByExternalID(storeRecord.ID, relPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to check existing file: %w", err)
+	}
+	if existing != nil && existing.Hash == hash {
+		return false, nil
+	}
+
 	// Detect language
 	lang := fs.DetectLanguage(filePath)
 
@@ -385,15 +1184,60 @@ func (idx *Indexer) IndexSingleFile(ctx context.Context, storeName, rootPath, fi
 		ModTime:  info.ModTime(),
 		Hash:     hash,
 		Language: lang,
+		Binary:   idx.config().Indexing.IncludeBinaryNames && fs.IsBinary(content),
 	}
 
 	opts := IndexOptions{
 		StoreName: storeName,
-		Force:     true, // Always re-index when called from watcher
+		Force:     true, // Already known to have changed
 		BatchSize: 50,
 	}
 
-	return idx.indexFile(ctx, storeRecord, fi, opts)
+	if err := idx.indexFile(ctx, storeRecord, fi, opts, &runStats{}); err != nil {
+		return false, err
+	}
+
+	// A file without chunks is not written, so drop its old version
+	if existing != nil {
+		current, err := idx.store.GetFileByExternalID(storeRecord.ID, relPath)
+		if err == nil && current != nil && current.Hash != hash {
+			if err := idx.store.DeleteFile(storeRecord.ID, relPath); err != nil {
+				return false, fmt.Errorf("failed to remove file: %w", err)
+			}
+		}
+	}
+	return true, nil
+}
+
+// RefreshFiles brings files of a store, given by relative path, up to date:
+// changed files are re-indexed and deleted ones removed. It returns the
+// number of files updated. Search uses it to refresh the files behind stale
+// results without indexing the whole store.
+func (idx *Indexer) RefreshFiles(ctx context.Context, storeRecord *store.StoreRecord, relPaths []string) (int, error) {
+	updated := 0
+	for _, relPath := range relPaths {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+
+		path := filepath.Join(storeRecord.RootPath, relPath)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := idx.store.DeleteFile(storeRecord.ID, relPath); err != nil {
+				return updated, fmt.Errorf("failed to remove %s: %w", relPath, err)
+			}
+			updated++
+			continue
+		}
+
+		indexed, err := idx.IndexSingleFile(ctx, storeRecord.Name, storeRecord.RootPath, path)
+		if err != nil {
+			return updated, fmt.Errorf("failed to re-index %s: %w", relPath, err)
+		}
+		if indexed {
+			updated++
+		}
+	}
+	return updated, nil
 }
 
 // Delete removes a store and all its indexed data.
@@ -418,12 +1262,20 @@ func (idx *Indexer) DeleteFile(storeName, relPath string) error {
 	return idx.store.DeleteFile(storeRecord.ID, relPath)
 }
 
-// Clear removes all indexed data from a store but keeps the store.
+// Clear removes all indexed data from a store but keeps the store. It
+// returns ErrIndexInProgress if another process is indexing the store.
 func (idx *Indexer) Clear(storeName string) error {
 	storeRecord, err := idx.store.GetStore(storeName)
-	if err != nil {
+	if err != nil || storeRecord == nil {
 		return fmt.Errorf("store not found: %s", storeName)
 	}
+
+	storeLock, _, err := idx.lockStore(context.Background(), storeRecord.Name, LockFail)
+	if err != nil {
+		return err
+	}
+	defer storeLock.Release()
+
 	return idx.store.ClearStore(storeRecord.ID)
 }
 
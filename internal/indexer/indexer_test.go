@@ -2,8 +2,10 @@ package indexer
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,6 +14,8 @@ import (
 
 	"github.com/nickcecere/lgrep/internal/config"
 	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/fs"
+	"github.com/nickcecere/lgrep/internal/lock"
 	"github.com/nickcecere/lgrep/internal/store"
 )
 
@@ -72,9 +76,14 @@ func createTestEnv(t *testing.T) (string, func()) {
 	files := map[string]string{
 		"main.go": `package main
 
-import "fmt"
+import (
+	"fmt"
+
+	"example.com/test/lib"
+)
 
 func main() {
+	lib.LibFunc()
 	fmt.Println("Hello, World!")
 }
 `,
@@ -114,6 +123,7 @@ func createTestConfig() *config.Config {
 			MaxFileCount: 1000,
 			ChunkSize:    1000,
 			ChunkOverlap: 100,
+			Relations:    true,
 		},
 		Ignore: []string{},
 	}
@@ -151,6 +161,7 @@ func TestIndexDirectory(t *testing.T) {
 	cfg := createTestConfig()
 
 	idx := New(st, emb, cfg)
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "go.mod"), []byte("module example.com/test\n"), 0644))
 
 	// Index the test directory
 	err = idx.Index(context.Background(), IndexOptions{
@@ -171,6 +182,76 @@ func TestIndexDirectory(t *testing.T) {
 	require.NoError(t, err)
 	assert.Greater(t, stats.FileCount, 0)
 	assert.Greater(t, stats.ChunkCount, 0)
+
+	// Imports and symbols are recorded
+	file, err := st.GetFileByExternalID(stores[0].ID, "main.go")
+	require.NoError(t, err)
+	imports, err := st.GetFileImports(file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fmt", "example.com/test/lib"}, imports)
+	matches, err := st.FindSymbols(stores[0].ID, "LibFunc", false, 0)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "lib/lib.go", matches[0].File.RelativePath)
+
+	// And resolved to the files they refer to
+	imported, importedBy, err := st.GetRelatedFiles(file.ID)
+	require.NoError(t, err)
+	require.Len(t, imported, 1)
+	assert.Equal(t, "lib/lib.go", imported[0].RelativePath)
+	assert.Empty(t, importedBy)
+}
+
+// TestIndexUpdatesRelations tests that re-indexing recomputes the relations
+// of changed and added files and keeps those of unchanged files.
+func TestIndexUpdatesRelations(t *testing.T) {
+	testDir := t.TempDir()
+	write := func(relPath, content string) {
+		path := filepath.Join(testDir, relPath)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	write("go.mod", "module example.com/test\n")
+	write("main.go", "package main\n\nimport \"example.com/test/lib\"\n")
+	write("lib/lib.go", "package lib\n\nimport \"errors\"\n")
+	write("lib/errors.go", "package lib\n")
+	write("tool/tool.go", "package tool\n\nimport \"example.com/test/later\"\n")
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+	idx := New(st, &mockEmbedder{model: "test-model", dimensions: 8}, createTestConfig())
+	index := func() {
+		require.NoError(t, idx.Index(context.Background(), IndexOptions{StoreName: "test", Path: testDir}))
+	}
+	related := func(relPath string) []string {
+		storeRecord, err := st.GetStore("test")
+		require.NoError(t, err)
+		file, err := st.GetFileByExternalID(storeRecord.ID, relPath)
+		require.NoError(t, err)
+		imported, _, err := st.GetRelatedFiles(file.ID)
+		require.NoError(t, err)
+		var paths []string
+		for _, f := range imported {
+			paths = append(paths, f.RelativePath)
+		}
+		return paths
+	}
+
+	// The standard library's errors is not lib/errors.go
+	index()
+	assert.Equal(t, []string{"lib/errors.go", "lib/lib.go"}, related("main.go"))
+	assert.Empty(t, related("lib/lib.go"))
+	assert.Empty(t, related("tool/tool.go"))
+
+	// A changed file gets new relations, and an unchanged one gains those
+	// to an added file
+	time.Sleep(time.Second)
+	write("main.go", "package main\n\nimport \"example.com/test/tool\"\n")
+	write("later/later.go", "package later\n")
+	index()
+	assert.Equal(t, []string{"tool/tool.go"}, related("main.go"))
+	assert.Equal(t, []string{"later/later.go"}, related("tool/tool.go"))
 }
 
 // TestIndexSkipsUnchangedFiles tests that unchanged files are skipped.
@@ -248,6 +329,195 @@ func TestIndexForce(t *testing.T) {
 	assert.Greater(t, emb.embedCalls, firstEmbedCalls, "force should re-index all files")
 }
 
+// TestIndexForceRebuild tests that a forced run drops deleted files and
+// leaves the store untouched if it fails.
+func TestIndexForceRebuild(t *testing.T) {
+	testDir, cleanup := createTestEnv(t)
+	defer cleanup()
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	idx := New(st, &mockEmbedder{model: "test-model", dimensions: 768}, createTestConfig())
+	opts := IndexOptions{StoreName: "test-store", Path: testDir}
+	require.NoError(t, idx.Index(context.Background(), opts))
+
+	before, err := st.GetStore("test-store")
+	require.NoError(t, err)
+	require.NoError(t, st.AddStoreAlias("test-store", "alias"))
+
+	// A cancelled rebuild keeps the existing contents
+	require.NoError(t, os.Remove(filepath.Join(testDir, "utils.go")))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts.Force = true
+	assert.Error(t, idx.Index(ctx, opts))
+
+	stats, err := idx.Stats("test-store")
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.FileCount)
+
+	// A completed rebuild drops the deleted file and keeps the store identity
+	require.NoError(t, idx.Index(context.Background(), opts))
+
+	stats, err = idx.Stats("test-store")
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.FileCount)
+
+	after, err := st.GetStore("alias")
+	require.NoError(t, err)
+	require.NotNil(t, after)
+	assert.Equal(t, before.ID, after.ID)
+
+	// The rebuilt vectors are searchable under the original store
+	emb := &mockEmbedder{dimensions: 768}
+	results, err := st.Search(after.ID, emb.generateEmbedding(), 10, -1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, stats.ChunkCount, len(results))
+
+	temporary, err := st.GetStore(store.RebuildStoreName("test-store"))
+	require.NoError(t, err)
+	assert.Nil(t, temporary, "temporary store should be removed")
+}
+
+// TestIndexRemovesStaleRebuild tests that the temporary store of a crashed
+// rebuild is hidden from listings and removed by the next run.
+func TestIndexRemovesStaleRebuild(t *testing.T) {
+	testDir, cleanup := createTestEnv(t)
+	defer cleanup()
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	rebuildName := store.RebuildStoreName("test-store")
+	_, err = st.CreateStore(rebuildName, testDir, store.ProviderOllama, "test-model", 768)
+	require.NoError(t, err)
+
+	idx := New(st, &mockEmbedder{model: "test-model", dimensions: 768}, createTestConfig())
+	stores, err := idx.List()
+	require.NoError(t, err)
+	assert.Empty(t, stores)
+
+	require.NoError(t, idx.Index(context.Background(), IndexOptions{StoreName: "test-store", Path: testDir}))
+	stale, err := st.GetStore(rebuildName)
+	require.NoError(t, err)
+	assert.Nil(t, stale)
+
+	// Users cannot create stores with the reserved names
+	assert.ErrorIs(t, idx.Index(context.Background(), IndexOptions{StoreName: rebuildName, Path: testDir}), store.ErrReservedName)
+	assert.ErrorIs(t, st.AddStoreAlias("test-store", rebuildName), store.ErrReservedName)
+	assert.ErrorIs(t, st.RenameStore("test-store", rebuildName), store.ErrReservedName)
+}
+
+// TestIndexPrune tests that pruning removes deleted files and keeps the rest.
+func TestIndexPrune(t *testing.T) {
+	testDir, cleanup := createTestEnv(t)
+	defer cleanup()
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	idx := New(st, &mockEmbedder{model: "test-model", dimensions: 768}, createTestConfig())
+	opts := IndexOptions{StoreName: "test-store", Path: testDir, BatchSize: 10}
+	require.NoError(t, idx.Index(context.Background(), opts))
+
+	require.NoError(t, os.Remove(filepath.Join(testDir, "utils.go")))
+
+	// Without pruning the deleted file stays
+	require.NoError(t, idx.Index(context.Background(), opts))
+	stats, err := idx.Stats("test-store")
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.FileCount)
+
+	opts.Prune = true
+	require.NoError(t, idx.Index(context.Background(), opts))
+	assert.Equal(t, 1, idx.Progress().PrunedFiles)
+	stats, err = idx.Stats("test-store")
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.FileCount)
+	file, err := st.GetFileByExternalID(stats.StoreID, "utils.go")
+	require.NoError(t, err)
+	assert.Nil(t, file)
+
+	// Each run is recorded in the store's history
+	runs, err := st.ListIndexRuns(stats.StoreID, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	assert.Equal(t, store.TriggerCLI, runs[0].Trigger)
+	assert.Equal(t, store.RunOK, runs[0].Status)
+	assert.Equal(t, 3, runs[0].Files)
+	assert.Equal(t, 1, runs[0].Removed)
+	assert.False(t, runs[0].FinishedAt.IsZero())
+}
+
+// TestIndexSingleFile tests that unchanged files are skipped and files left
+// without content are removed.
+func TestIndexSingleFile(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	require.NoError(t, os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0644))
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	idx := New(st, emb, createTestConfig())
+
+	indexed, err := idx.IndexSingleFile(context.Background(), "test-store", root, path)
+	require.NoError(t, err)
+	assert.True(t, indexed)
+	calls := emb.embedCalls
+
+	indexed, err = idx.IndexSingleFile(context.Background(), "test-store", root, path)
+	require.NoError(t, err)
+	assert.False(t, indexed)
+	assert.Equal(t, calls, emb.embedCalls)
+
+	require.NoError(t, os.WriteFile(path, nil, 0644))
+	indexed, err = idx.IndexSingleFile(context.Background(), "test-store", root, path)
+	require.NoError(t, err)
+	assert.True(t, indexed)
+	stats, err := idx.Stats("test-store")
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.FileCount)
+}
+
+func TestRefreshFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"same.go", "edited.go", "deleted.go"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte("package main\n\nfunc main() {}\n"), 0644))
+	}
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	idx := New(st, emb, createTestConfig())
+	require.NoError(t, idx.Index(context.Background(), IndexOptions{StoreName: "test-store", Path: root, BatchSize: 10}))
+	storeRecord, err := st.GetStore("test-store")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "edited.go"), []byte("package main\n\nfunc edited() {}\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(root, "deleted.go")))
+
+	updated, err := idx.RefreshFiles(context.Background(), storeRecord, []string{"same.go", "edited.go", "deleted.go"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated)
+
+	stats, err := idx.Stats("test-store")
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.FileCount)
+	file, err := st.GetFileByExternalID(storeRecord.ID, "edited.go")
+	require.NoError(t, err)
+	require.NotNil(t, file)
+	assert.Equal(t, fs.HashContent([]byte("package main\n\nfunc edited() {}\n")), file.Hash)
+}
+
 // TestIndexWithExtensionFilter tests extension filtering.
 func TestIndexWithExtensionFilter(t *testing.T) {
 	testDir, cleanup := createTestEnv(t)
@@ -279,6 +549,65 @@ func TestIndexWithExtensionFilter(t *testing.T) {
 	assert.Equal(t, 3, stats.FileCount, "should only index .go files") // main.go, utils.go, lib/lib.go
 }
 
+// TestIndexBinaryNames tests indexing binary files by their paths.
+func TestIndexBinaryNames(t *testing.T) {
+	testDir, cleanup := createTestEnv(t)
+	defer cleanup()
+	require.NoError(t, os.MkdirAll(filepath.Join(testDir, "assets"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "assets", "logo.png"), []byte("\x89PNG\r\n\x1a\n\x00\x00"), 0644))
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	cfg := createTestConfig()
+	cfg.Indexing.IncludeBinaryNames = true
+	idx := New(st, &mockEmbedder{model: "test-model", dimensions: 768}, cfg)
+	require.NoError(t, idx.Index(context.Background(), IndexOptions{StoreName: "test-store", Path: testDir}))
+
+	storeRecord, err := idx.GetStoreRecord("test-store")
+	require.NoError(t, err)
+	file, err := st.GetFileByExternalID(storeRecord.ID, "assets/logo.png")
+	require.NoError(t, err)
+	require.NotNil(t, file)
+
+	vectors, err := st.GetFileVectors(file.ID)
+	require.NoError(t, err)
+	require.Len(t, vectors, 1)
+	results, err := st.GetChunks([]int64{vectors[0].ChunkID})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "assets/logo.png\nbinary png file: assets logo", results[0].Chunk.Content)
+}
+
+// TestIndexGenerated tests flagging vendored and generated files.
+func TestIndexGenerated(t *testing.T) {
+	testDir, cleanup := createTestEnv(t)
+	defer cleanup()
+	require.NoError(t, os.MkdirAll(filepath.Join(testDir, "third_party", "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "third_party", "lib", "lib.go"), []byte("package lib\n\nfunc Lib() {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "mocks.go"), []byte("// Code generated by mockery. DO NOT EDIT.\n\npackage main\n"), 0644))
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	idx := New(st, &mockEmbedder{model: "test-model", dimensions: 768}, createTestConfig())
+	require.NoError(t, idx.Index(context.Background(), IndexOptions{StoreName: "test-store", Path: testDir}))
+
+	storeRecord, err := idx.GetStoreRecord("test-store")
+	require.NoError(t, err)
+	files, err := st.ListFiles(storeRecord.ID, nil)
+	require.NoError(t, err)
+	generated := map[string]bool{}
+	for _, f := range files {
+		generated[filepath.ToSlash(f.RelativePath)] = f.Generated
+	}
+	assert.Equal(t, true, generated["third_party/lib/lib.go"])
+	assert.Equal(t, true, generated["mocks.go"])
+	assert.Equal(t, false, generated["main.go"])
+}
+
 // TestIndexProgress tests progress callback.
 func TestIndexProgress(t *testing.T) {
 	testDir, cleanup := createTestEnv(t)
@@ -341,6 +670,315 @@ func TestIndexCancellation(t *testing.T) {
 	assert.ErrorIs(t, err, context.Canceled)
 }
 
+// cancellingEmbedder cancels a context after a number of embedding calls.
+type cancellingEmbedder struct {
+	mockEmbedder
+	after  int
+	cancel context.CancelFunc
+}
+
+func (c *cancellingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	result, err := c.mockEmbedder.EmbedBatch(ctx, texts)
+	if c.embedCalls == c.after {
+		c.cancel()
+	}
+	return result, err
+}
+
+// TestIndexCancellationCheckpoint tests that an interrupted run leaves the
+// store with exactly the files reported as processed.
+func TestIndexCancellationCheckpoint(t *testing.T) {
+	testDir, cleanup := createTestEnv(t)
+	defer cleanup()
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	emb := &cancellingEmbedder{mockEmbedder: mockEmbedder{model: "test-model", dimensions: 768}, after: 2, cancel: cancel}
+
+	idx := New(st, emb, createTestConfig())
+	err = idx.Index(ctx, IndexOptions{StoreName: "test-store", Path: testDir, BatchSize: 1})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	stats, err := idx.Stats("test-store")
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.FileCount)
+	assert.Equal(t, idx.Progress().ProcessedFiles, stats.FileCount)
+}
+
+// TestIndexStreamsLargeFiles tests that files larger than a batch are
+// written incrementally with the same chunks as smaller files.
+func TestIndexStreamsLargeFiles(t *testing.T) {
+	testDir := t.TempDir()
+
+	var b strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&b, "line %d of a large text file\n", i)
+	}
+	content := b.String()
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "large.txt"), []byte(content), 0644))
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	cfg := createTestConfig()
+	cfg.Indexing.ChunkSize = 100
+	cfg.Indexing.ChunkOverlap = 20
+	cfg.Indexing.ContextLines = 2
+
+	idx := New(st, &mockEmbedder{model: "test-model", dimensions: 768}, cfg)
+	require.True(t, idx.streamsFile(fs.FileInfo{Size: int64(len(content))}, 2))
+
+	opts := IndexOptions{StoreName: "test-store", Path: testDir, BatchSize: 2}
+	require.NoError(t, idx.Index(context.Background(), opts))
+
+	want := idx.current.Load().chunker.Chunk(content, "large.txt")
+	stats, err := idx.Stats("test-store")
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.FileCount)
+	assert.Equal(t, len(want), stats.ChunkCount)
+	assert.Equal(t, 1, idx.Progress().ProcessedFiles)
+
+	// Context is collected while streaming; line n reads "line n-1 ..."
+	results, err := st.Search(stats.StoreID, (&mockEmbedder{dimensions: 768}).generateEmbedding(), len(want), -1, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	for _, r := range results {
+		if r.Chunk.StartLine > 2 {
+			before := fmt.Sprintf("line %d of a large text file\nline %d of a large text file", r.Chunk.StartLine-3, r.Chunk.StartLine-2)
+			assert.Equal(t, before, r.Chunk.ContextBefore)
+		}
+	}
+
+	// The hash is recorded once the file is complete, so it is skipped
+	require.NoError(t, idx.Index(context.Background(), opts))
+	assert.Equal(t, 1, idx.Progress().SkippedFiles)
+}
+
+// TestIndexMaxChunksPerFile tests that files over the chunk limit keep
+// only their first and last chunks, whether streamed or not.
+func TestIndexMaxChunksPerFile(t *testing.T) {
+	testDir := t.TempDir()
+
+	var b strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&b, "line %d of a generated file\n", i)
+	}
+	content := b.String()
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "generated.txt"), []byte(content), 0644))
+
+	for _, batchSize := range []int{2, 1000} {
+		t.Run(fmt.Sprintf("batch size %d", batchSize), func(t *testing.T) {
+			st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+			require.NoError(t, err)
+			defer st.Close()
+
+			cfg := createTestConfig()
+			cfg.Indexing.ChunkSize = 100
+			cfg.Indexing.ChunkOverlap = 20
+			cfg.Indexing.MaxChunksPerFile = 5
+
+			idx := New(st, &mockEmbedder{model: "test-model", dimensions: 768}, cfg)
+			require.NoError(t, idx.Index(context.Background(), IndexOptions{StoreName: "test-store", Path: testDir, BatchSize: batchSize}))
+
+			all := idx.current.Load().chunker.Chunk(content, "generated.txt")
+			require.Greater(t, len(all), 5)
+			stats, err := idx.Stats("test-store")
+			require.NoError(t, err)
+			assert.Equal(t, 5, stats.ChunkCount)
+
+			results, err := st.Search(stats.StoreID, (&mockEmbedder{dimensions: 768}).generateEmbedding(), 10, -1, nil)
+			require.NoError(t, err)
+			var indexes []int
+			for _, r := range results {
+				indexes = append(indexes, r.Chunk.ChunkIndex)
+			}
+			n := len(all)
+			assert.ElementsMatch(t, []int{0, 1, 2, n - 2, n - 1}, indexes)
+		})
+	}
+}
+
+// TestSampleChunks tests keeping the first and last chunks of a file.
+func TestSampleChunks(t *testing.T) {
+	chunks := make([]fs.Chunk, 10)
+	for i := range chunks {
+		chunks[i].ChunkIndex = i
+	}
+	indexes := func(chunks []fs.Chunk) []int {
+		var out []int
+		for _, c := range chunks {
+			out = append(out, c.ChunkIndex)
+		}
+		return out
+	}
+
+	assert.Equal(t, []int{0, 1, 2, 8, 9}, indexes(sampleChunks(chunks, 5)))
+	assert.Equal(t, []int{0}, indexes(sampleChunks(chunks, 1)))
+	assert.Len(t, sampleChunks(chunks, 10), 10)
+	assert.Len(t, sampleChunks(chunks, 0), 10)
+
+	s := newChunkSampler(4)
+	for _, c := range chunks {
+		s.add(c)
+	}
+	assert.Equal(t, []int{8, 9}, indexes(s.rest()))
+	assert.Equal(t, 6, s.dropped())
+}
+
+// batchEmbedder is a mockEmbedder with a batch API whose jobs complete on
+// the first poll unless cancel is set, in which case it is called instead.
+type batchEmbedder struct {
+	mockEmbedder
+	jobs      map[string]int
+	submitted int
+	cancel    context.CancelFunc
+}
+
+func (b *batchEmbedder) SubmitBatch(ctx context.Context, texts []string) (string, error) {
+	b.submitted++
+	id := fmt.Sprintf("batch-%d", b.submitted)
+	b.jobs[id] = len(texts)
+	return id, nil
+}
+
+func (b *batchEmbedder) GetBatch(ctx context.Context, id string) (*embeddings.BatchJob, error) {
+	if b.cancel != nil {
+		b.cancel()
+		return &embeddings.BatchJob{ID: id, Status: embeddings.BatchPending}, nil
+	}
+	return &embeddings.BatchJob{ID: id, Status: embeddings.BatchCompleted}, nil
+}
+
+func (b *batchEmbedder) BatchResults(ctx context.Context, id string) ([][]float32, error) {
+	results := make([][]float32, b.jobs[id])
+	for i := range results {
+		results[i] = b.generateEmbedding()
+	}
+	return results, nil
+}
+
+func (b *batchEmbedder) MaxBatchSize() int {
+	return 1000
+}
+
+// TestIndexBatch tests indexing through a batch API, including collecting
+// the batches of an interrupted run.
+func TestIndexBatch(t *testing.T) {
+	testDir, cleanup := createTestEnv(t)
+	defer cleanup()
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	emb := &batchEmbedder{mockEmbedder: mockEmbedder{model: "test-model", dimensions: 768}, jobs: map[string]int{}, cancel: cancel}
+	idx := New(st, emb, createTestConfig())
+	opts := IndexOptions{StoreName: "test-store", Path: testDir, BatchSize: 10, Batch: true, PollInterval: time.Millisecond}
+
+	// Interrupted while the batch runs; nothing is written yet
+	err = idx.Index(ctx, opts)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, emb.submitted)
+	stats, err := idx.Stats("test-store")
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.FileCount)
+	batches, err := st.ListEmbeddingBatches(stats.StoreID)
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+
+	// The next run collects the batch instead of submitting the files again
+	emb.cancel = nil
+	require.NoError(t, idx.Index(context.Background(), opts))
+	assert.Equal(t, 1, emb.submitted)
+	assert.Equal(t, 0, emb.embedCalls)
+	stats, err = idx.Stats("test-store")
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.FileCount)
+	assert.Greater(t, stats.ChunkCount, 0)
+	batches, err = st.ListEmbeddingBatches(stats.StoreID)
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+
+	// A changed file is submitted and written by the same run
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644))
+	require.NoError(t, idx.Index(context.Background(), opts))
+	assert.Equal(t, 2, emb.submitted)
+	assert.Equal(t, 4, idx.Progress().ProcessedFiles)
+	assert.Equal(t, 3, idx.Progress().SkippedFiles)
+
+	// Providers without a batch API are rejected
+	plain := New(st, &mockEmbedder{model: "test-model", dimensions: 768}, createTestConfig())
+	assert.Error(t, plain.Index(context.Background(), opts))
+
+	opts.Force = true
+	assert.Error(t, idx.Index(context.Background(), opts))
+}
+
+// TestRequestBatches tests that embedding requests are cut by estimated
+// tokens when a limit is set and by count otherwise.
+func TestRequestBatches(t *testing.T) {
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	chunk := func(tokens int) fs.Chunk {
+		return fs.Chunk{Content: strings.Repeat("abcd", tokens)}
+	}
+	sizes := func(batches [][]fs.Chunk) []int {
+		var n []int
+		for _, b := range batches {
+			n = append(n, len(b))
+		}
+		return n
+	}
+
+	cfg := createTestConfig()
+	idx := New(st, &mockEmbedder{model: "test-model", dimensions: 768}, cfg)
+	chunks := []fs.Chunk{chunk(10), chunk(10), chunk(10), chunk(10), chunk(10)}
+	assert.Equal(t, []int{2, 2, 1}, sizes(idx.requestBatches(chunks, 2)))
+
+	cfg.Indexing.MaxBatchTokens = 100
+	idx = New(st, &mockEmbedder{model: "test-model", dimensions: 768}, cfg)
+
+	// Small chunks fill a request regardless of the batch size
+	assert.Equal(t, []int{5}, sizes(idx.requestBatches(chunks, 2)))
+
+	// Large chunks are split, and one over the limit goes alone
+	chunks = []fs.Chunk{chunk(60), chunk(30), chunk(20), chunk(150), chunk(10)}
+	assert.Equal(t, []int{2, 1, 1, 1}, sizes(idx.requestBatches(chunks, 50)))
+
+	// The chunk count is still capped
+	chunks = make([]fs.Chunk, maxRequestChunks+1)
+	assert.Equal(t, []int{maxRequestChunks, 1}, sizes(idx.requestBatches(chunks, 50)))
+}
+
+// TestLineWindow tests that stored context matches the lines around each
+// chunk.
+func TestLineWindow(t *testing.T) {
+	content := "l1\nl2\nl3\nl4\nl5\nl6\nl7\n"
+	chunks := []store.Chunk{
+		{StartLine: 1, EndLine: 2},
+		{StartLine: 3, EndLine: 5},
+		{StartLine: 7, EndLine: 8},
+	}
+
+	require.NoError(t, newLineWindow(strings.NewReader(content)).addContext(chunks, 2))
+
+	assert.Equal(t, "", chunks[0].ContextBefore)
+	assert.Equal(t, "l3\nl4", chunks[0].ContextAfter)
+	assert.Equal(t, "l1\nl2", chunks[1].ContextBefore)
+	assert.Equal(t, "l6\nl7", chunks[1].ContextAfter)
+	assert.Equal(t, "l5\nl6", chunks[2].ContextBefore)
+	assert.Equal(t, "", chunks[2].ContextAfter)
+}
+
 // TestIndexerDelete tests store deletion.
 func TestIndexerDelete(t *testing.T) {
 	testDir, cleanup := createTestEnv(t)
@@ -408,6 +1046,35 @@ func TestIndexerClear(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 0, stats.FileCount)
 	assert.Equal(t, 0, stats.ChunkCount)
+
+	// Clearing a missing store is an error
+	assert.Error(t, idx.Clear("missing"))
+}
+
+// TestIndexerClearLocked tests that Clear refuses to run during indexing.
+func TestIndexerClearLocked(t *testing.T) {
+	testDir, cleanup := createTestEnv(t)
+	defer cleanup()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	st, err := store.NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer st.Close()
+
+	cfg := createTestConfig()
+	cfg.Database.Path = dbPath
+	idx := New(st, &mockEmbedder{model: "test-model", dimensions: 768}, cfg)
+	require.NoError(t, idx.Index(context.Background(), IndexOptions{StoreName: "test-store", Path: testDir}))
+
+	held, err := lock.TryAcquire(lock.StorePath(dbPath, "test-store"))
+	require.NoError(t, err)
+	defer held.Release()
+
+	assert.ErrorIs(t, idx.Clear("test-store"), ErrIndexInProgress)
+
+	stats, err := idx.Stats("test-store")
+	require.NoError(t, err)
+	assert.Greater(t, stats.FileCount, 0)
 }
 
 // TestIndexInvalidPath tests error handling for invalid path.
@@ -460,3 +1127,317 @@ func TestProgressStruct(t *testing.T) {
 	assert.Equal(t, 1, p.Errors)
 	assert.Equal(t, "test.go", p.CurrentFile)
 }
+
+// TestIndexLocking tests coordination with another process indexing the same store.
+func TestIndexLocking(t *testing.T) {
+	testDir, cleanup := createTestEnv(t)
+	defer cleanup()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	st, err := store.NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer st.Close()
+
+	cfg := createTestConfig()
+	cfg.Database.Path = dbPath
+	idx := New(st, &mockEmbedder{model: "test-model", dimensions: 768}, cfg)
+
+	// Simulate another process holding the store lock
+	held, err := lock.TryAcquire(lock.StorePath(dbPath, "test-store"))
+	require.NoError(t, err)
+
+	opts := IndexOptions{StoreName: "test-store", Path: testDir, BatchSize: 10}
+
+	t.Run("fail", func(t *testing.T) {
+		opts.LockPolicy = LockFail
+		err := idx.Index(context.Background(), opts)
+		assert.ErrorIs(t, err, ErrIndexInProgress)
+	})
+
+	t.Run("delegate", func(t *testing.T) {
+		go func() {
+			time.Sleep(300 * time.Millisecond)
+			held.Release()
+		}()
+
+		opts.LockPolicy = LockDelegate
+		require.NoError(t, idx.Index(context.Background(), opts))
+		assert.True(t, idx.Progress().Delegated)
+
+		// The other process was trusted to index, so nothing was added here
+		stores, err := idx.List()
+		require.NoError(t, err)
+		assert.Empty(t, stores)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		opts.LockPolicy = LockWait
+		require.NoError(t, idx.Index(context.Background(), opts))
+		assert.False(t, idx.Progress().Delegated)
+
+		stats, err := idx.Stats("test-store")
+		require.NoError(t, err)
+		assert.Greater(t, stats.FileCount, 0)
+	})
+}
+
+// TestScan tests that Scan reports the files Index would process.
+func TestScan(t *testing.T) {
+	tmpDir, cleanup := createTestEnv(t)
+	defer cleanup()
+
+	cfg := createTestConfig()
+
+	scan, err := Scan(cfg, tmpDir, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, scan.Files, 4)
+	assert.Greater(t, scan.TotalSize, int64(0))
+
+	scan, err = Scan(cfg, tmpDir, []string{".go"}, nil)
+	require.NoError(t, err)
+	assert.Len(t, scan.Files, 3)
+}
+
+// TestScanDiff tests comparing a scan with an indexed store.
+func TestScanDiff(t *testing.T) {
+	tmpDir, cleanup := createTestEnv(t)
+	defer cleanup()
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	cfg := createTestConfig()
+	scan, err := Scan(cfg, tmpDir, nil, nil)
+	require.NoError(t, err)
+
+	// Everything is new to a store that does not exist
+	diff, err := scan.Diff(st, nil, cfg, 50, false)
+	require.NoError(t, err)
+	assert.Len(t, diff.Added, 4)
+	assert.Equal(t, scan.TotalSize, diff.Bytes)
+	assert.GreaterOrEqual(t, diff.Chunks, 4)
+	assert.Equal(t, 4, diff.Requests)
+
+	idx := New(st, &mockEmbedder{model: "test-model", dimensions: 768}, cfg)
+	require.NoError(t, idx.Index(context.Background(), IndexOptions{StoreName: "test-store", Path: tmpDir}))
+	storeRecord, err := idx.GetStoreRecord("test-store")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "utils.go"), []byte("package main\n\nfunc edited() {}\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(tmpDir, "README.md")))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "new.go"), []byte("package main\n"), 0644))
+
+	scan, err = Scan(cfg, tmpDir, nil, nil)
+	require.NoError(t, err)
+	diff, err = scan.Diff(st, storeRecord, cfg, 50, false)
+	require.NoError(t, err)
+	require.Len(t, diff.Added, 1)
+	assert.Equal(t, "new.go", diff.Added[0].RelPath)
+	require.Len(t, diff.Changed, 1)
+	assert.Equal(t, "utils.go", diff.Changed[0].RelPath)
+	require.Len(t, diff.Removed, 1)
+	assert.Equal(t, "README.md", diff.Removed[0].RelativePath)
+	assert.Equal(t, 2, diff.Unchanged)
+	assert.Equal(t, 2, diff.Requests)
+
+	// Forcing re-embeds every file
+	diff, err = scan.Diff(st, storeRecord, cfg, 50, true)
+	require.NoError(t, err)
+	assert.Len(t, diff.Changed, 3)
+	assert.Zero(t, diff.Unchanged)
+}
+
+// TestMeasureThroughput tests timing a sample of the chunks to embed.
+func TestMeasureThroughput(t *testing.T) {
+	tmpDir, cleanup := createTestEnv(t)
+	defer cleanup()
+
+	cfg := createTestConfig()
+	scan, err := Scan(cfg, tmpDir, nil, nil)
+	require.NoError(t, err)
+	diff, err := scan.Diff(nil, nil, cfg, 50, false)
+	require.NoError(t, err)
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	_, err = diff.MeasureThroughput(context.Background(), emb, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 2, emb.embedCalls, "a warm-up request and a timed one")
+
+	_, err = (&ScanDiff{}).MeasureThroughput(context.Background(), emb, cfg)
+	assert.Error(t, err)
+}
+
+// TestEstimateRequests tests estimating the embedding requests of a file.
+func TestEstimateRequests(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.Indexing.MaxBatchTokens = 0
+	assert.Equal(t, 1, estimateRequests(50, cfg, 50))
+	assert.Equal(t, 2, estimateRequests(51, cfg, 50))
+
+	// A token limit decides the chunks per request instead
+	cfg.Indexing.ChunkSize = 400
+	cfg.Indexing.MaxBatchTokens = 1000
+	assert.Equal(t, 5, estimateRequests(50, cfg, 50))
+}
+
+// TestDiagnose tests the warnings for misconfigured index runs.
+func TestDiagnose(t *testing.T) {
+	messages := func(s runStats) []string {
+		var msgs []string
+		for _, w := range diagnose(s) {
+			msgs = append(msgs, w.msg)
+		}
+		return msgs
+	}
+
+	healthy := runStats{
+		walk:       fs.WalkStats{FilesFound: 90, FilesSkipped: 10, SkippedIgnored: 10},
+		read:       90,
+		empty:      2,
+		chunks:     400,
+		chunkBytes: 400 * 800,
+	}
+	assert.Empty(t, diagnose(healthy))
+
+	filtered := healthy
+	filtered.walk = fs.WalkStats{FilesFound: 10, FilesSkipped: 90, SkippedFilter: 90}
+	assert.Equal(t, []string{"Most files were skipped by the extension or language filter"}, messages(filtered))
+	assert.Equal(t, "90% (90 of 100)", diagnose(filtered)[0].keyvals[1])
+
+	limited := healthy
+	limited.walk.LimitReached = true
+	assert.Len(t, diagnose(limited), 1)
+
+	empty := healthy
+	empty.empty = 30
+	assert.Len(t, diagnose(empty), 1)
+
+	tiny := healthy
+	tiny.chunkBytes = 400 * 20
+	assert.Len(t, diagnose(tiny), 1)
+
+	// Small runs are not judged
+	assert.Empty(t, diagnose(runStats{walk: fs.WalkStats{FilesFound: 1, FilesSkipped: 3, SkippedSize: 3}, read: 1, empty: 1}))
+}
+
+// TestCheckAutoIndexLimits tests the auto-index size guardrails.
+func TestCheckAutoIndexLimits(t *testing.T) {
+	tmpDir, cleanup := createTestEnv(t)
+	defer cleanup()
+
+	cfg := createTestConfig()
+	scan, err := Scan(cfg, tmpDir, nil, nil)
+	require.NoError(t, err)
+
+	// No limits configured
+	assert.NoError(t, CheckAutoIndexLimits(cfg, tmpDir, scan))
+
+	cfg.Indexing.AutoIndexMaxFiles = 10
+	cfg.Indexing.AutoIndexMaxBytes = 1 << 20
+	assert.NoError(t, CheckAutoIndexLimits(cfg, tmpDir, scan))
+
+	cfg.Indexing.AutoIndexMaxFiles = 2
+	err = CheckAutoIndexLimits(cfg, tmpDir, scan)
+	var limitErr *AutoIndexLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, 4, limitErr.Files)
+	assert.Contains(t, err.Error(), "files")
+
+	cfg.Indexing.AutoIndexMaxFiles = 0
+	cfg.Indexing.AutoIndexMaxBytes = 10
+	err = CheckAutoIndexLimits(cfg, tmpDir, scan)
+	require.ErrorAs(t, err, &limitErr)
+	assert.Contains(t, err.Error(), "bytes")
+}
+
+// TestPartitions tests splitting a monorepo into partition stores.
+func TestPartitions(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"payments", "web/app", ".github", "node_modules/x", "shared"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".gitignore"), []byte("shared/\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "README.md"), []byte("# Monorepo\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "payments", "main.go"), []byte("package main\n"), 0644))
+
+	cfg := createTestConfig()
+	cfg.Ignore = []string{"node_modules/"}
+
+	// Not configured
+	partitions, err := Partitions(cfg, root)
+	require.NoError(t, err)
+	assert.Nil(t, partitions)
+	files, err := RootFiles(cfg, root)
+	require.NoError(t, err)
+	assert.Nil(t, files)
+	assert.Equal(t, filepath.Base(root), StoreName(cfg, root))
+	assert.Equal(t, "payments", StoreName(cfg, filepath.Join(root, "payments")))
+
+	// Every top-level directory that is indexed
+	cfg.Partitions = []config.PartitionConfig{{Root: root, Name: "mono"}}
+	partitions, err = Partitions(cfg, root)
+	require.NoError(t, err)
+	assert.Equal(t, []Partition{
+		{Store: "mono/payments", Path: filepath.Join(root, "payments")},
+		{Store: "mono/web", Path: filepath.Join(root, "web")},
+	}, partitions)
+	assert.Equal(t, "mono/payments", StoreName(cfg, filepath.Join(root, "payments")))
+	assert.Equal(t, "app", StoreName(cfg, filepath.Join(root, "web", "app")))
+	assert.Equal(t, filepath.Base(root), StoreName(cfg, root))
+
+	// Files at the root are in no partition
+	files, err = RootFiles(cfg, root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"README.md"}, files)
+
+	// Mapped directories, whose names keep their case
+	cfg.Partitions[0].Directories = []config.PartitionDirectory{{Name: "Pay", Path: "payments"}, {Name: "frontend", Path: "web/app"}}
+	partitions, err = Partitions(cfg, root)
+	require.NoError(t, err)
+	assert.Equal(t, []Partition{
+		{Store: "mono/Pay", Path: filepath.Join(root, "payments")},
+		{Store: "mono/frontend", Path: filepath.Join(root, "web", "app")},
+	}, partitions)
+	assert.Equal(t, "mono/Pay", StoreName(cfg, filepath.Join(root, "payments")))
+	assert.Equal(t, "mono/frontend", StoreName(cfg, filepath.Join(root, "web", "app")))
+	assert.Equal(t, "web", StoreName(cfg, filepath.Join(root, "web")))
+}
+
+// TestIndexResizesVectors tests that embeddings truncated to fewer
+// dimensions of the same model are made from the stored embeddings, without
+// embedding again.
+func TestIndexResizesVectors(t *testing.T) {
+	testDir, cleanup := createTestEnv(t)
+	defer cleanup()
+
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 8}
+	opts := IndexOptions{StoreName: "test", Path: testDir, BatchSize: 10}
+	require.NoError(t, New(st, emb, createTestConfig()).Index(context.Background(), opts))
+
+	// The same model truncated to 4 dimensions
+	emb.dimensions, emb.embedCalls = 4, 0
+	require.NoError(t, New(st, emb, createTestConfig()).Index(context.Background(), opts))
+	assert.Zero(t, emb.embedCalls)
+
+	storeRecord, err := st.GetStore("test")
+	require.NoError(t, err)
+	assert.Equal(t, 4, storeRecord.EmbeddingDimensions)
+	results, err := st.Search(storeRecord.ID, []float32{0, 0.01, 0.02, 0.03}, 10, -1, nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, results)
+	report, err := st.CheckIntegrity()
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+
+	// Another model is not resized
+	emb.model, emb.dimensions = "other-model", 2
+	require.NoError(t, New(st, emb, createTestConfig()).Index(context.Background(), opts))
+	storeRecord, err = st.GetStore("test")
+	require.NoError(t, err)
+	assert.Equal(t, 4, storeRecord.EmbeddingDimensions)
+}
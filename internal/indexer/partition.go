@@ -0,0 +1,150 @@
+package indexer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/fs"
+)
+
+// Partition is one store of a monorepo split by the partitions setting.
+type Partition struct {
+	Store string // Store name, e.g. "monorepo/payments"
+	Path  string // Absolute path of the directory the store indexes
+}
+
+// Partitions returns the partitions of root, sorted by store name, if the
+// partitions setting splits it into several stores, or nil if it does not.
+// Without a directory mapping, every top-level directory that indexing would
+// enter is a partition.
+func Partitions(cfg *config.Config, root string) ([]Partition, error) {
+	pc := partitionConfig(cfg, root)
+	if pc == nil {
+		return nil, nil
+	}
+
+	var partitions []Partition
+	if len(pc.Directories) > 0 {
+		for _, d := range pc.Directories {
+			partitions = append(partitions, Partition{
+				Store: pc.StorePrefix() + "/" + d.Name,
+				Path:  filepath.Join(root, filepath.FromSlash(d.Path)),
+			})
+		}
+	} else {
+		walker, err := fs.NewFileWalker(walkOptions(cfg, root, nil, nil))
+		if err != nil {
+			return nil, err
+		}
+		dirs, err := walker.TopLevelDirs()
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			partitions = append(partitions, Partition{
+				Store: pc.StorePrefix() + "/" + dir,
+				Path:  filepath.Join(root, dir),
+			})
+		}
+	}
+
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].Store < partitions[j].Store
+	})
+	return partitions, nil
+}
+
+// RootFiles returns the names of the files directly under root that
+// indexing would include but no partition covers, such as README.md or
+// go.mod, or nil if the partitions setting does not split root.
+func RootFiles(cfg *config.Config, root string) ([]string, error) {
+	if partitionConfig(cfg, root) == nil {
+		return nil, nil
+	}
+	walker, err := fs.NewFileWalker(walkOptions(cfg, root, nil, nil))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if include, _ := walker.Include(filepath.Join(root, entry.Name())); include {
+			files = append(files, entry.Name())
+		}
+	}
+	return files, nil
+}
+
+// LogRootFiles warns about the files directly under root, a partitioned
+// monorepo, that are left out of every partition store.
+func LogRootFiles(cfg *config.Config, root string) {
+	files, err := RootFiles(cfg, root)
+	if err != nil {
+		log.Debug("Failed to list the files at the root of the partitions", "root", root, "error", err)
+		return
+	}
+	if len(files) == 0 {
+		return
+	}
+	shown := files
+	if len(shown) > 5 {
+		shown = shown[:5]
+	}
+	log.Warn("Files at the root of a partitioned monorepo are not in any partition store",
+		"root", root, "files", len(files), "examples", strings.Join(shown, ", "))
+}
+
+// PartitionStore returns the name of the partition store that indexes dir,
+// or "" if dir is not a partition of a monorepo in the partitions setting.
+func PartitionStore(cfg *config.Config, dir string) string {
+	for _, pc := range cfg.Partitions {
+		rel, err := filepath.Rel(pc.RootPath(), dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if len(pc.Directories) == 0 {
+			if !strings.Contains(rel, "/") {
+				return pc.StorePrefix() + "/" + rel
+			}
+			continue
+		}
+		for _, d := range pc.Directories {
+			if filepath.ToSlash(filepath.Clean(filepath.FromSlash(d.Path))) == rel {
+				return pc.StorePrefix() + "/" + d.Name
+			}
+		}
+	}
+	return ""
+}
+
+// StoreName returns the name of the store that indexes dir by default: its
+// partition store if it is a partition of a monorepo, or its base name.
+func StoreName(cfg *config.Config, dir string) string {
+	if name := PartitionStore(cfg, dir); name != "" {
+		return name
+	}
+	return filepath.Base(dir)
+}
+
+// partitionConfig returns the partitions entry for root, or nil.
+func partitionConfig(cfg *config.Config, root string) *config.PartitionConfig {
+	for i, pc := range cfg.Partitions {
+		if pc.RootPath() == filepath.Clean(root) {
+			return &cfg.Partitions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package indexer
+
+import (
+	"time"
+
+	"github.com/nickcecere/lgrep/internal/fs"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// updateRelations resolves the recorded imports of a store's files to the
+// files they refer to. Only the relations of the files indexed since the
+// given time are recomputed: those from them, and those from other files to
+// them, which are new if they were added. Imports of packages from outside
+// the store resolve to nothing.
+func (idx *Indexer) updateRelations(storeRecord *store.StoreRecord, since time.Time) error {
+	var files []store.FileRecord
+	err := idx.store.WalkFiles(storeRecord.ID, nil, func(f store.FileRecord) error {
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Relations may not have been recorded before, e.g. if they were
+	// turned off
+	if count, err := idx.store.CountFileRelations(storeRecord.ID); err != nil {
+		return err
+	} else if count == 0 {
+		since = time.Time{}
+	}
+
+	changed := make(map[int64]bool)
+	var changedIDs []int64
+	for _, f := range files {
+		if !f.IndexedAt.Before(since) {
+			changed[f.ID] = true
+			changedIDs = append(changedIDs, f.ID)
+		}
+	}
+	if len(changedIDs) == 0 {
+		return nil
+	}
+
+	imports, err := idx.store.ListStoreImports(storeRecord.ID)
+	if err != nil {
+		return err
+	}
+
+	relPaths := make([]string, len(files))
+	for i, f := range files {
+		relPaths[i] = f.RelativePath
+	}
+	resolver := fs.NewImportResolver(relPaths, fs.ReadModules(storeRecord.RootPath, relPaths))
+
+	var relations []store.FileRelation
+	for _, file := range files {
+		seen := map[int64]bool{file.ID: true}
+		for _, target := range imports[file.ID] {
+			for _, i := range resolver.Resolve(target) {
+				if to := files[i].ID; !seen[to] && (changed[file.ID] || changed[to]) {
+					seen[to] = true
+					relations = append(relations, store.FileRelation{FromFileID: file.ID, ToFileID: to})
+				}
+			}
+		}
+	}
+	return idx.store.UpdateFileRelations(changedIDs, relations)
+}
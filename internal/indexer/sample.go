@@ -0,0 +1,86 @@
+package indexer
+
+import (
+	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/fs"
+)
+
+// chunkSampler keeps the first and last chunks of a file with more than
+// indexing.max_chunks_per_file, so one huge generated file cannot dominate
+// the store and the results. Chunks in the middle are dropped.
+type chunkSampler struct {
+	head, tail int
+	seen       int
+	ring       []fs.Chunk // The last chunks after the head, oldest first
+}
+
+// newChunkSampler returns a sampler keeping max chunks, or nil if max is
+// not positive.
+func newChunkSampler(max int) *chunkSampler {
+	if max <= 0 {
+		return nil
+	}
+	tail := max / 2
+	return &chunkSampler{head: max - tail, tail: tail}
+}
+
+// add records the next chunk of the file and reports whether it is kept
+// at once. Chunks after the head are held until the file ends, since only
+// then is it known which of them are the last.
+func (s *chunkSampler) add(c fs.Chunk) bool {
+	if s == nil {
+		return true
+	}
+	s.seen++
+	if s.seen <= s.head {
+		return true
+	}
+	if s.tail == 0 {
+		return false
+	}
+	if len(s.ring) == s.tail {
+		s.ring = s.ring[1:]
+	}
+	s.ring = append(s.ring, c)
+	return false
+}
+
+// rest returns the chunks held for the end of the file, in file order.
+func (s *chunkSampler) rest() []fs.Chunk {
+	if s == nil {
+		return nil
+	}
+	return s.ring
+}
+
+// dropped returns the number of chunks left out.
+func (s *chunkSampler) dropped() int {
+	if s == nil {
+		return 0
+	}
+	return max(s.seen-s.head-s.tail, 0)
+}
+
+// sampleChunks returns the chunks of a file that are kept with a limit of
+// max chunks.
+func sampleChunks(chunks []fs.Chunk, max int) []fs.Chunk {
+	s := newChunkSampler(max)
+	if s == nil || len(chunks) <= max {
+		return chunks
+	}
+	var kept []fs.Chunk
+	for _, c := range chunks {
+		if s.add(c) {
+			kept = append(kept, c)
+		}
+	}
+	return append(kept, s.rest()...)
+}
+
+// warnSampled logs that a file had more chunks than the limit and only its
+// first and last ones were indexed.
+func warnSampled(relPath string, chunks, max int) {
+	log.Warn("File has too many chunks, indexing only the first and last ones",
+		"path", relPath, "chunks", chunks, "kept", max, "limit", "indexing.max_chunks_per_file")
+}
@@ -0,0 +1,242 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/fs"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// ScanResult describes the files an index run would process.
+type ScanResult struct {
+	Files     []fs.FileInfo
+	TotalSize int64 // Bytes to read, leaving out binary files indexed by name
+	Stats     fs.WalkStats
+}
+
+// Scan walks path with the same filters Index uses, hashing each file
+// without chunking or embedding any.
+func Scan(cfg *config.Config, path string, extensions, ignorePatterns []string) (*ScanResult, error) {
+	walker, err := fs.NewFileWalker(walkOptions(cfg, path, extensions, ignorePatterns))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file walker: %w", err)
+	}
+
+	result := &ScanResult{}
+	err = walker.Walk(func(fi fs.FileInfo) error {
+		result.Files = append(result.Files, fi)
+		if !fi.Binary {
+			result.TotalSize += fi.Size
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+	result.Stats = walker.Stats()
+
+	return result, nil
+}
+
+// ScanDiff compares the files of a scan with those a store holds, as an
+// index run would.
+type ScanDiff struct {
+	Added     []fs.FileInfo      // Not in the store
+	Changed   []fs.FileInfo      // In the store with another hash, or all of them when forced
+	Removed   []store.FileRecord // In the store but no longer found
+	Unchanged int                // In the store at the same hash
+
+	Bytes    int64 // Bytes of the added and changed files, leaving out binary files
+	Chunks   int   // Estimated chunks to embed
+	Requests int   // Estimated embedding requests
+}
+
+// Diff compares the scanned files with the files of storeRecord, which is
+// nil for a store that does not exist yet. With force, as with
+// IndexOptions.Force, every file in the store counts as changed. Chunks
+// and requests are estimated from file sizes and the chunking and batching
+// settings of cfg, for requests of batchSize chunks.
+func (r *ScanResult) Diff(st store.Store, storeRecord *store.StoreRecord, cfg *config.Config, batchSize int, force bool) (*ScanDiff, error) {
+	indexed := make(map[string]store.FileRecord)
+	if storeRecord != nil {
+		err := st.WalkFiles(storeRecord.ID, nil, func(f store.FileRecord) error {
+			indexed[f.ExternalID] = f
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list indexed files: %w", err)
+		}
+	}
+
+	diff := &ScanDiff{}
+	for _, fi := range r.Files {
+		existing, ok := indexed[fi.RelPath]
+		delete(indexed, fi.RelPath)
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, fi)
+		case force || existing.Hash != fi.Hash:
+			diff.Changed = append(diff.Changed, fi)
+		default:
+			diff.Unchanged++
+			continue
+		}
+
+		if !fi.Binary {
+			diff.Bytes += fi.Size
+		}
+		chunks := estimateChunks(fi, cfg)
+		diff.Chunks += chunks
+		diff.Requests += estimateRequests(chunks, cfg, batchSize)
+	}
+
+	for _, f := range indexed {
+		diff.Removed = append(diff.Removed, f)
+	}
+	sort.Slice(diff.Removed, func(i, j int) bool {
+		return diff.Removed[i].RelativePath < diff.Removed[j].RelativePath
+	})
+
+	return diff, nil
+}
+
+// probeChunks is how many chunks MeasureThroughput embeds in its timed
+// request.
+const probeChunks = 8
+
+// MeasureThroughput embeds a few chunks of the added and changed files with
+// emb and returns the time each chunk took. A warm-up request goes first so
+// that loading the model or opening the connection is not counted.
+func (d *ScanDiff) MeasureThroughput(ctx context.Context, emb embeddings.Service, cfg *config.Config) (time.Duration, error) {
+	chunker := newSettings(cfg).chunker
+	var texts []string
+	for _, fi := range append(append([]fs.FileInfo{}, d.Added...), d.Changed...) {
+		if len(texts) > probeChunks {
+			break
+		}
+		if fi.Binary {
+			texts = append(texts, fs.NameChunk(fi.RelPath).Content)
+			continue
+		}
+		content, err := os.ReadFile(fi.Path)
+		if err != nil {
+			continue
+		}
+		for _, c := range chunker.Chunk(string(content), fi.Path) {
+			texts = append(texts, c.Content)
+		}
+	}
+	if len(texts) == 0 {
+		return 0, fmt.Errorf("no chunks to embed")
+	}
+
+	if _, err := emb.EmbedBatch(ctx, texts[:1]); err != nil {
+		return 0, fmt.Errorf("failed to generate embeddings: %w", err)
+	}
+	if len(texts) > 1 {
+		texts = texts[1:min(len(texts), probeChunks+1)]
+	}
+	start := time.Now()
+	if _, err := emb.EmbedBatch(ctx, texts); err != nil {
+		return 0, fmt.Errorf("failed to generate embeddings: %w", err)
+	}
+	return time.Since(start) / time.Duration(len(texts)), nil
+}
+
+// estimateChunks estimates the chunks fi is split into, after
+// indexing.max_chunks_per_file.
+func estimateChunks(fi fs.FileInfo, cfg *config.Config) int {
+	if fi.Binary {
+		return 1
+	}
+	step := int64(cfg.Indexing.ChunkSize - cfg.Indexing.ChunkOverlap)
+	if step <= 0 {
+		step = int64(fs.DefaultChunkOptions().ChunkSize)
+	}
+	n := max(int((fi.Size+step-1)/step), 1)
+	if limit := cfg.Indexing.MaxChunksPerFile; limit > 0 {
+		n = min(n, limit)
+	}
+	return n
+}
+
+// estimateRequests estimates the embedding requests for a file of chunks
+// chunks, split as requestBatches would split them.
+func estimateRequests(chunks int, cfg *config.Config, batchSize int) int {
+	perRequest := batchSize
+	if perRequest <= 0 {
+		perRequest = 50
+	}
+	if maxTokens := cfg.Indexing.MaxBatchTokens; maxTokens > 0 {
+		chunkTokens := max(cfg.Indexing.ChunkSize/4, 1)
+		perRequest = min(max(maxTokens/chunkTokens, 1), maxRequestChunks)
+	}
+	return (chunks + perRequest - 1) / perRequest
+}
+
+// NewFileWalker returns a walker that yields the files indexing root would
+// index under cfg.
+func NewFileWalker(cfg *config.Config, root string) (*fs.FileWalker, error) {
+	return fs.NewFileWalker(walkOptions(cfg, root, nil, nil))
+}
+
+// walkOptions builds the file walker options for indexing path.
+func walkOptions(cfg *config.Config, path string, extensions, ignorePatterns []string) fs.WalkOptions {
+	return fs.WalkOptions{
+		Root:               path,
+		MaxFileSize:        int64(cfg.Indexing.MaxFileSize),
+		MinFileSize:        int64(cfg.Indexing.MinFileSize),
+		MaxFileCount:       cfg.Indexing.MaxFileCount,
+		IgnorePatterns:     append(append([]string{}, cfg.Ignore...), ignorePatterns...),
+		UseGitignore:       true,
+		Extensions:         extensions,
+		Languages:          cfg.Indexing.Languages.Include,
+		ExcludeLanguages:   cfg.Indexing.Languages.Exclude,
+		TextExtensions:     cfg.Indexing.TextExtensions,
+		IncludeBinaryNames: cfg.Indexing.IncludeBinaryNames,
+	}
+}
+
+// AutoIndexLimitError is returned when a directory is too large to be
+// indexed implicitly.
+type AutoIndexLimitError struct {
+	Path     string
+	Files    int
+	Bytes    int64
+	MaxFiles int
+	MaxBytes int64
+}
+
+func (e *AutoIndexLimitError) Error() string {
+	if e.MaxFiles > 0 && e.Files > e.MaxFiles {
+		return fmt.Sprintf("%s has %d files to index, more than the auto-index limit of %d",
+			e.Path, e.Files, e.MaxFiles)
+	}
+	return fmt.Sprintf("%s has %d bytes to index, more than the auto-index limit of %d",
+		e.Path, e.Bytes, e.MaxBytes)
+}
+
+// CheckAutoIndexLimits returns an *AutoIndexLimitError if the scanned
+// directory exceeds indexing.auto_index_max_files or
+// indexing.auto_index_max_bytes. Zero limits are not enforced.
+func CheckAutoIndexLimits(cfg *config.Config, path string, scan *ScanResult) error {
+	maxFiles := cfg.Indexing.AutoIndexMaxFiles
+	maxBytes := cfg.Indexing.AutoIndexMaxBytes
+
+	if (maxFiles > 0 && len(scan.Files) > maxFiles) || (maxBytes > 0 && scan.TotalSize > maxBytes) {
+		return &AutoIndexLimitError{
+			Path:     path,
+			Files:    len(scan.Files),
+			Bytes:    scan.TotalSize,
+			MaxFiles: maxFiles,
+			MaxBytes: maxBytes,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package indexer
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/fs"
+)
+
+// Thresholds past which an index run is reported as probably misconfigured.
+const (
+	// skippedWarnRatio is the share of files skipped for one reason.
+	skippedWarnRatio = 0.5
+	// emptyWarnRatio is the share of read files that produced no chunks.
+	emptyWarnRatio = 0.2
+	// warnMinFiles is how many files a run needs before ratios are judged.
+	warnMinFiles = 10
+	// warnMinChunks is how many chunks a run needs before their average
+	// size is judged.
+	warnMinChunks = 10
+)
+
+// runStats is what an index run found and produced, for diagnose.
+type runStats struct {
+	walk       fs.WalkStats
+	read       int   // Files chunked, leaving out unchanged ones
+	empty      int   // Files read that produced no chunks
+	chunks     int   // Chunks embedded
+	chunkBytes int64 // Content bytes of the chunks embedded
+}
+
+// indexWarning describes a probable misconfiguration, as a log message and
+// its key-value pairs.
+type indexWarning struct {
+	msg     string
+	keyvals []any
+}
+
+// diagnose returns warnings for the signs of a misconfigured index run:
+// most files skipped for one reason, the file count limit cutting the walk
+// short, many files producing no chunks, and chunks far below the minimum
+// chunk size. These otherwise only show up as puzzling search results.
+func diagnose(s runStats) []indexWarning {
+	var warnings []indexWarning
+
+	if s.walk.LimitReached {
+		warnings = append(warnings, indexWarning{
+			"Stopped at the file count limit; the rest of the directory was not indexed",
+			[]any{"files", s.walk.FilesFound, "check", "indexing.max_file_count"},
+		})
+	}
+
+	total := s.walk.FilesFound + s.walk.FilesSkipped
+	skipped := []struct {
+		count int
+		msg   string
+		check string
+	}{
+		{s.walk.SkippedFilter, "Most files were skipped by the extension or language filter", "--ext and indexing.languages"},
+		{s.walk.SkippedIgnored, "Most files were skipped by ignore patterns", "ignore, --ignore and .gitignore"},
+		{s.walk.SkippedBinary, "Most files were skipped as binary", "indexing.text_extensions and indexing.include_binary_names"},
+		{s.walk.SkippedSize, "Most files were skipped for their size", "indexing.max_file_size and indexing.min_file_size"},
+	}
+	for _, sk := range skipped {
+		if total >= warnMinFiles && float64(sk.count) >= skippedWarnRatio*float64(total) {
+			warnings = append(warnings, indexWarning{
+				sk.msg,
+				[]any{"skipped", share(sk.count, total), "check", sk.check},
+			})
+		}
+	}
+
+	if s.read >= warnMinFiles && float64(s.empty) >= emptyWarnRatio*float64(s.read) {
+		warnings = append(warnings, indexWarning{
+			"Many files produced no chunks; they may be empty or shorter than the minimum chunk size",
+			[]any{"files", share(s.empty, s.read), "minimum", minChunkSize},
+		})
+	}
+
+	if s.chunks >= warnMinChunks {
+		if avg := s.chunkBytes / int64(s.chunks); avg < minChunkSize/2 {
+			warnings = append(warnings, indexWarning{
+				"Chunks are far below the minimum chunk size, which makes for poor search results",
+				[]any{"average", avg, "minimum", minChunkSize, "check", "indexing.chunk_size and the files indexed"},
+			})
+		}
+	}
+
+	return warnings
+}
+
+// share formats count as a percentage of total, e.g. "90% (450 of 500)".
+func share(count, total int) string {
+	return fmt.Sprintf("%.0f%% (%d of %d)", 100*float64(count)/float64(total), count, total)
+}
+
+// logWarnings logs the warnings of diagnose.
+func logWarnings(warnings []indexWarning) {
+	for _, w := range warnings {
+		log.Warn(w.msg, w.keyvals...)
+	}
+}
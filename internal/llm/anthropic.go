@@ -4,20 +4,39 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"time"
 
 	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/httpclient"
 )
 
+func init() {
+	Register(string(ProviderAnthropic), func(cfg *config.Config) (Service, error) {
+		svc, err := NewAnthropicService(cfg.LLM.Anthropic.APIKey, cfg.LLM.Anthropic.Model)
+		if err != nil {
+			return nil, err
+		}
+		client, err := httpclient.New(cfg.Network, cfg.LLM.Anthropic.HTTPConfig)
+		if err != nil {
+			return nil, err
+		}
+		svc.SetHTTPClient(client)
+		return svc, nil
+	})
+}
+
 const anthropicAPIURL = "https://api.anthropic.com/v1/messages"
 
 // AnthropicService implements the LLM service using Anthropic Claude.
 type AnthropicService struct {
 	apiKey string
 	model  string
+	url    string
 	client *http.Client
 }
 
@@ -43,6 +62,15 @@ type anthropicResponse struct {
 	Role       string             `json:"role"`
 	Content    []anthropicContent `json:"content"`
 	StopReason string             `json:"stop_reason"`
+	Usage      anthropicUsage     `json:"usage"`
+}
+
+// anthropicUsage is the token usage of a message. In a stream, the input
+// tokens arrive with message_start and the output tokens so far with each
+// message_delta.
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
 }
 
 type anthropicContent struct {
@@ -58,8 +86,19 @@ type anthropicStreamEvent struct {
 		Type string `json:"type"`
 		Text string `json:"text"`
 	} `json:"delta,omitempty"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+	Message *struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message,omitempty"`
+	Usage *anthropicUsage `json:"usage,omitempty"`
 }
 
+// errStreamDone stops SSE parsing once the message is complete.
+var errStreamDone = errors.New("stream done")
+
 // NewAnthropicService creates a new Anthropic LLM service.
 func NewAnthropicService(apiKey, model string) (*AnthropicService, error) {
 	if apiKey == "" {
@@ -69,12 +108,19 @@ func NewAnthropicService(apiKey, model string) (*AnthropicService, error) {
 	return &AnthropicService{
 		apiKey: apiKey,
 		model:  model,
+		url:    anthropicAPIURL,
 		client: &http.Client{
-			Timeout: 5 * time.Minute,
+			Timeout: config.DefaultLLMTimeout,
 		},
 	}, nil
 }
 
+// SetHTTPClient sets the client used for requests, e.g. one built by
+// httpclient.New from the provider's configuration.
+func (s *AnthropicService) SetHTTPClient(client *http.Client) {
+	s.client = client
+}
+
 // Complete generates a completion for the given messages.
 func (s *AnthropicService) Complete(ctx context.Context, messages []Message, opts CompletionOptions) (string, error) {
 	log.Debug("Requesting completion from Anthropic", "model", s.model)
@@ -107,7 +153,7 @@ func (s *AnthropicService) Complete(ctx context.Context, messages []Message, opt
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", anthropicAPIURL, bytes.NewReader(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(jsonBody))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -135,6 +181,7 @@ func (s *AnthropicService) Complete(ctx context.Context, messages []Message, opt
 	if len(result.Content) == 0 {
 		return "", fmt.Errorf("no content in response")
 	}
+	opts.setUsage(result.Usage.InputTokens, result.Usage.OutputTokens)
 
 	return result.Content[0].Text, nil
 }
@@ -178,7 +225,7 @@ func (s *AnthropicService) CompleteStream(ctx context.Context, messages []Messag
 			return
 		}
 
-		req, err := http.NewRequestWithContext(ctx, "POST", anthropicAPIURL, bytes.NewReader(jsonBody))
+		req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(jsonBody))
 		if err != nil {
 			errCh <- fmt.Errorf("failed to create request: %w", err)
 			return
@@ -202,35 +249,61 @@ func (s *AnthropicService) CompleteStream(ctx context.Context, messages []Messag
 		}
 
 		// Read SSE stream
-		decoder := json.NewDecoder(resp.Body)
-		for {
-			select {
-			case <-ctx.Done():
-				errCh <- ctx.Err()
-				return
-			default:
+		var usage anthropicUsage
+		err = readSSE(resp.Body, func(ev sseEvent) error {
+			if err := ctx.Err(); err != nil {
+				return err
 			}
+			return handleAnthropicEvent(ev, contentCh, &usage)
+		})
+		opts.setUsage(usage.InputTokens, usage.OutputTokens)
+		if err != nil && err != errStreamDone {
+			errCh <- err
+		}
+	}()
 
-			var event anthropicStreamEvent
-			if err := decoder.Decode(&event); err != nil {
-				if err == io.EOF {
-					return
-				}
-				// Skip non-JSON lines (SSE format)
-				continue
-			}
+	return contentCh, errCh
+}
 
-			if event.Type == "content_block_delta" && event.Delta != nil && event.Delta.Text != "" {
-				contentCh <- event.Delta.Text
-			}
+// handleAnthropicEvent processes a single streaming event, sending any text
+// deltas to contentCh and recording token counts in usage. It returns
+// errStreamDone when the message is complete.
+func handleAnthropicEvent(ev sseEvent, contentCh chan<- string, usage *anthropicUsage) error {
+	switch ev.Event {
+	case "ping", "content_block_start", "content_block_stop":
+		return nil
+	case "message_stop":
+		return errStreamDone
+	}
 
-			if event.Type == "message_stop" {
-				return
-			}
+	var event anthropicStreamEvent
+	if err := json.Unmarshal([]byte(ev.Data), &event); err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", ev.Event, err)
+	}
+
+	switch event.Type {
+	case "message_start":
+		if event.Message != nil {
+			*usage = event.Message.Usage
 		}
-	}()
+	case "message_delta":
+		if event.Usage != nil {
+			usage.OutputTokens = event.Usage.OutputTokens
+		}
+	case "content_block_delta":
+		if event.Delta != nil && event.Delta.Text != "" {
+			contentCh <- event.Delta.Text
+		}
+	case "error":
+		if event.Error != nil {
+			return fmt.Errorf("anthropic stream error (%s): %s", event.Error.Type, event.Error.Message)
+		}
+		return fmt.Errorf("anthropic stream error: %s", ev.Data)
+	case "message_stop":
+		return errStreamDone
+	}
 
-	return contentCh, errCh
+	return nil
 }
 
 // Provider returns the provider name.
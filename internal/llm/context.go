@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nickcecere/lgrep/internal/search"
+)
+
+const (
+	// sourceHeaderTokens approximates the tokens used by each source header.
+	sourceHeaderTokens = 20
+
+	// minTruncatedTokens is the smallest budget worth truncating a result into.
+	// Results that would be cut shorter than this are dropped instead.
+	minTruncatedTokens = 64
+)
+
+// ContextReport describes how search results were packed into the LLM context.
+type ContextReport struct {
+	Included  int // Results sent as context
+	Truncated int // Results shortened to fit the token budget
+	Dropped   int // Results left out because the token budget was exhausted
+	Tokens    int // Estimated tokens of context sent
+}
+
+// EstimateTokens returns a rough token count for text (about 4 characters per token).
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// SelectSources returns the search results that will be sent to the LLM as
+// context. Results are taken in rank order up to MaxContextChunks. When
+// MaxContextTokens is set, a result that does not fit the remaining budget is
+// truncated to fit, or dropped if too little budget is left, and packing
+// continues so that smaller lower-ranked results can still fill the space.
+func SelectSources(results []search.Result, opts QAOptions) ([]search.Result, ContextReport) {
+	var selected []search.Result
+	var report ContextReport
+
+	budget := opts.MaxContextTokens
+	for _, r := range results {
+		if opts.MaxContextChunks > 0 && len(selected) >= opts.MaxContextChunks {
+			break
+		}
+
+		cost := EstimateTokens(r.Content) + sourceHeaderTokens
+		if budget > 0 && report.Tokens+cost > budget {
+			remaining := budget - report.Tokens - sourceHeaderTokens
+			if remaining < minTruncatedTokens {
+				report.Dropped++
+				continue
+			}
+
+			r = truncateResult(r, remaining)
+			cost = EstimateTokens(r.Content) + sourceHeaderTokens
+			report.Truncated++
+		}
+
+		selected = append(selected, r)
+		report.Tokens += cost
+	}
+
+	report.Included = len(selected)
+	return selected, report
+}
+
+// truncateResult shortens a result's content to roughly maxTokens, keeping
+// whole lines from the start of the chunk and adjusting the line range.
+func truncateResult(r search.Result, maxTokens int) search.Result {
+	lines := strings.Split(r.Content, "\n")
+
+	var kept []string
+	tokens := 0
+	for _, line := range lines {
+		lineTokens := EstimateTokens(line + "\n")
+		if tokens+lineTokens > maxTokens {
+			break
+		}
+		kept = append(kept, line)
+		tokens += lineTokens
+	}
+
+	// A single oversized line is cut by characters instead
+	if len(kept) == 0 {
+		runes := []rune(lines[0])
+		if len(runes) > maxTokens*4 {
+			runes = runes[:maxTokens*4]
+		}
+		kept = []string{string(runes)}
+	}
+
+	r.Content = strings.Join(kept, "\n")
+	if r.StartLine > 0 {
+		r.EndLine = r.StartLine + len(kept) - 1
+	}
+	return r
+}
@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// QueryExpander rewrites search queries into alternative phrasings using an LLM.
+type QueryExpander struct {
+	llm Service
+}
+
+// NewQueryExpander creates a new query expander.
+func NewQueryExpander(llm Service) *QueryExpander {
+	return &QueryExpander{llm: llm}
+}
+
+// DefaultExpansions is the number of alternative queries generated by default.
+const DefaultExpansions = 3
+
+// Expand asks the LLM for up to n alternative phrasings of the query.
+// The original query is never included in the returned list.
+func (e *QueryExpander) Expand(ctx context.Context, query string, n int) ([]string, error) {
+	if n <= 0 {
+		n = DefaultExpansions
+	}
+
+	messages := []Message{
+		{
+			Role:    "system",
+			Content: expandPrompt,
+		},
+		{
+			Role:    "user",
+			Content: fmt.Sprintf("Write %d alternatives for this search query:\n\n%s", n, query),
+		},
+	}
+
+	response, err := e.llm.Complete(ctx, messages, CompletionOptions{
+		Temperature: 0.5,
+		MaxTokens:   512,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand query: %w", err)
+	}
+
+	return parseExpansions(response, query, n), nil
+}
+
+// parseExpansions extracts one query per line from an LLM response.
+func parseExpansions(response, query string, n int) []string {
+	seen := map[string]bool{
+		strings.ToLower(strings.TrimSpace(query)): true,
+	}
+
+	var expansions []string
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+
+		// Skip code fences the model may wrap its answer in
+		if strings.HasPrefix(line, "```") {
+			continue
+		}
+
+		// Strip list markers like "1.", "2)", "-" and "*"
+		line = strings.TrimLeft(line, "-*• ")
+		if i := strings.IndexAny(line, ".)"); i > 0 && i <= 3 && isDigits(line[:i]) {
+			line = strings.TrimSpace(line[i+1:])
+		}
+		line = strings.Trim(line, "\"'`")
+
+		if line == "" {
+			continue
+		}
+
+		key := strings.ToLower(line)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		expansions = append(expansions, line)
+		if len(expansions) >= n {
+			break
+		}
+	}
+
+	return expansions
+}
+
+// isDigits reports whether s consists only of ASCII digits.
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// System prompt for query expansion.
+const expandPrompt = `You help a semantic code search engine find relevant code.
+
+Given a search query, write alternative queries that would match the same code.
+Mix paraphrases of the question with short hypothetical code (a function
+signature or a single line) that the answer is likely to contain.
+
+Rules:
+- Output one alternative per line
+- Do not number the lines or add any explanation
+- Keep each alternative under 20 words`
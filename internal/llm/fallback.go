@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/fallback"
+)
+
+// fallbackService tries each service of a chain in turn, moving on when a
+// provider cannot be reached or does not have the model.
+type fallbackService struct {
+	chain *fallback.Chain[Service]
+}
+
+// withFallbacks returns primary followed by the services of the fallback
+// providers, each with its own configured model, or primary alone if there
+// are none. Fallbacks that cannot be created, e.g. for want of an API key,
+// are left out with a warning.
+func withFallbacks(primary Service, providers []string, cfg *config.Config) Service {
+	chain := fallback.NewChain[Service](fallback.DefaultCooldown)
+	chain.Add(string(primary.Provider()), primary)
+	for _, provider := range providers {
+		if provider == string(primary.Provider()) {
+			continue
+		}
+		c, err := lookup(provider)
+		if err == nil {
+			var svc Service
+			if svc, err = c(cfg); err == nil {
+				chain.Add(provider, svc)
+				continue
+			}
+		}
+		log.Warn("Skipping LLM fallback", "provider", provider, "error", err)
+	}
+	if chain.Len() == 1 {
+		return primary
+	}
+	return &fallbackService{chain: chain}
+}
+
+// Complete generates a completion for the given messages.
+func (s *fallbackService) Complete(ctx context.Context, messages []Message, opts CompletionOptions) (string, error) {
+	return fallback.Do(ctx, s.chain, func(svc Service) (string, error) {
+		return svc.Complete(ctx, messages, opts)
+	})
+}
+
+// CompleteStream generates a streaming completion. A provider that fails
+// before sending any content is replaced by the next one; once content has
+// been sent, errors are returned as they are.
+func (s *fallbackService) CompleteStream(ctx context.Context, messages []Message, opts CompletionOptions) (<-chan string, <-chan error) {
+	contentCh := make(chan string, 100)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(contentCh)
+		defer close(errCh)
+
+		_, err := fallback.Do(ctx, s.chain, func(svc Service) (struct{}, error) {
+			upstream, upstreamErr := svc.CompleteStream(ctx, messages, opts)
+			started := false
+			for content := range upstream {
+				started = true
+				contentCh <- content
+			}
+			err := <-upstreamErr
+			if started {
+				err = fallback.Final(err)
+			}
+			return struct{}{}, err
+		})
+		if err != nil {
+			errCh <- err
+		}
+	}()
+
+	return contentCh, errCh
+}
+
+// Provider returns the provider that served the last request.
+func (s *fallbackService) Provider() Provider {
+	return s.chain.Active().Provider()
+}
+
+// ModelName returns the model of the provider that served the last request.
+func (s *fallbackService) ModelName() string {
+	return s.chain.Active().ModelName()
+}
@@ -3,7 +3,6 @@ package llm
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/nickcecere/lgrep/internal/config"
 )
@@ -33,6 +32,34 @@ type CompletionOptions struct {
 
 	// Stream enables streaming responses.
 	Stream bool
+
+	// Usage, if set, receives the token counts the provider reports for the
+	// completion. For a stream it is set by the time the content channel is
+	// closed. It is left zero if the provider reports none.
+	Usage *Usage
+}
+
+// Usage counts the tokens sent to and generated by an LLM.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+
+	// Estimated is set when the counts are estimated from the text because
+	// the provider did not report them.
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// Reported reports whether the provider reported any token counts.
+func (u Usage) Reported() bool {
+	return u.PromptTokens > 0 || u.CompletionTokens > 0
+}
+
+// setUsage stores the counts a provider reported in opts.Usage, if set.
+func (opts CompletionOptions) setUsage(prompt, completion int) {
+	if opts.Usage != nil {
+		opts.Usage.PromptTokens = prompt
+		opts.Usage.CompletionTokens = completion
+	}
 }
 
 // DefaultCompletionOptions returns sensible defaults.
@@ -59,26 +86,17 @@ type Service interface {
 	ModelName() string
 }
 
-// NewService creates an LLM service based on the configuration.
+// NewService creates an LLM service based on the configuration, falling
+// back to the providers in llm.fallbacks when the configured one is
+// unavailable.
 func NewService(cfg *config.Config) (Service, error) {
-	switch cfg.LLM.Provider {
-	case "ollama":
-		return NewOllamaService(
-			cfg.LLM.Ollama.URL,
-			cfg.LLM.Ollama.Model,
-		)
-	case "openai":
-		return NewOpenAIService(
-			cfg.LLM.OpenAI.APIKey,
-			cfg.LLM.OpenAI.Model,
-			cfg.LLM.OpenAI.BaseURL,
-		)
-	case "anthropic":
-		return NewAnthropicService(
-			cfg.LLM.Anthropic.APIKey,
-			cfg.LLM.Anthropic.Model,
-		)
-	default:
-		return nil, fmt.Errorf("unsupported LLM provider: %s", cfg.LLM.Provider)
+	c, err := lookup(cfg.LLM.Provider)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := c(cfg)
+	if err != nil {
+		return nil, err
 	}
+	return withFallbacks(svc, cfg.LLM.Fallbacks, cfg), nil
 }
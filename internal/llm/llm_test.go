@@ -5,12 +5,16 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/fallback"
+	"github.com/nickcecere/lgrep/internal/models"
 	"github.com/nickcecere/lgrep/internal/search"
 )
 
@@ -134,14 +138,18 @@ func mockOllamaServer(t *testing.T, response string) *httptest.Server {
 
 		var req ollamaChatRequest
 		err := json.NewDecoder(r.Body).Decode(&req)
-		require.NoError(t, err)
+		if !assert.NoError(t, err) {
+			return
+		}
 
 		resp := ollamaChatResponse{
 			Message: ollamaMessage{
 				Role:    "assistant",
 				Content: response,
 			},
-			Done: true,
+			Done:            true,
+			PromptEvalCount: 42,
+			EvalCount:       7,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -161,9 +169,12 @@ func TestOllamaComplete(t *testing.T) {
 		{Role: "user", Content: "Hello"},
 	}
 
-	response, err := svc.Complete(context.Background(), messages, DefaultCompletionOptions())
+	opts := DefaultCompletionOptions()
+	opts.Usage = &Usage{}
+	response, err := svc.Complete(context.Background(), messages, opts)
 	require.NoError(t, err)
 	assert.Equal(t, "Hello! How can I help you?", response)
+	assert.Equal(t, Usage{PromptTokens: 42, CompletionTokens: 7}, *opts.Usage)
 }
 
 // TestOllamaCompleteError tests error handling.
@@ -182,6 +193,72 @@ func TestOllamaCompleteError(t *testing.T) {
 	assert.Contains(t, err.Error(), "status 500")
 }
 
+// TestFallbackService tests falling back to the next provider.
+func TestFallbackService(t *testing.T) {
+	// An address nothing listens on
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	// Ollama's reply for a model it does not have
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	up := mockOllamaServer(t, "Hello!")
+	defer up.Close()
+
+	newChain := func(urls ...string) *fallbackService {
+		chain := fallback.NewChain[Service](fallback.DefaultCooldown)
+		for _, url := range urls {
+			svc, err := NewOllamaService(url, "llama2")
+			require.NoError(t, err)
+			chain.Add(url, svc)
+		}
+		return &fallbackService{chain: chain}
+	}
+	messages := []Message{{Role: "user", Content: "Hello"}}
+
+	t.Run("falls back when unreachable or the model is missing", func(t *testing.T) {
+		svc := newChain(down.URL, missing.URL, up.URL)
+		opts := DefaultCompletionOptions()
+		opts.Usage = &Usage{}
+		response, err := svc.Complete(context.Background(), messages, opts)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello!", response)
+		assert.Equal(t, 42, opts.Usage.PromptTokens)
+	})
+
+	t.Run("streams from the next provider", func(t *testing.T) {
+		svc := newChain(down.URL, up.URL)
+		contentCh, errCh := svc.CompleteStream(context.Background(), messages, DefaultCompletionOptions())
+		var response strings.Builder
+		for content := range contentCh {
+			response.WriteString(content)
+		}
+		require.NoError(t, <-errCh)
+		assert.Equal(t, "Hello!", response.String())
+	})
+
+	t.Run("returns the last error when every provider fails", func(t *testing.T) {
+		svc := newChain(down.URL, missing.URL)
+		_, err := svc.Complete(context.Background(), messages, DefaultCompletionOptions())
+		assert.ErrorIs(t, err, models.ErrMissingModel)
+	})
+
+	t.Run("skips fallbacks that cannot be created", func(t *testing.T) {
+		t.Setenv("ANTHROPIC_API_KEY", "")
+		cfg := &config.Config{
+			LLM: config.LLMConfig{
+				Provider:  "ollama",
+				Ollama:    config.OllamaLLMConfig{URL: up.URL, Model: "llama2"},
+				Fallbacks: []string{"anthropic"},
+			},
+		}
+		svc, err := NewService(cfg)
+		require.NoError(t, err)
+		assert.IsType(t, &OllamaService{}, svc)
+	})
+}
+
 // TestDefaultCompletionOptions tests default options.
 func TestDefaultCompletionOptions(t *testing.T) {
 	opts := DefaultCompletionOptions()
@@ -221,12 +298,19 @@ func TestQAService(t *testing.T) {
 		},
 	}
 
-	answer, err := qaSvc.Answer(context.Background(), "How does authentication work?", results, DefaultQAOptions())
+	opts := DefaultQAOptions()
+	opts.Timings = search.NewTimings()
+
+	answer, err := qaSvc.Answer(context.Background(), "How does authentication work?", results, opts)
 	require.NoError(t, err)
 
 	assert.NotEmpty(t, answer.Answer)
 	assert.Len(t, answer.Sources, 1)
 	assert.Equal(t, "auth.go", answer.Sources[0].RelativePath)
+	assert.Equal(t, "How does authentication work?", answer.Question)
+	assert.Equal(t, "llama2", answer.Model)
+	assert.Equal(t, &Usage{PromptTokens: 42, CompletionTokens: 7}, answer.Usage)
+	assert.Greater(t, opts.Timings.Get(search.PhaseLLM), time.Duration(0))
 }
 
 // TestQAServiceNoResults tests Q&A with no results.
@@ -278,6 +362,30 @@ func TestQAServiceMaxContextChunks(t *testing.T) {
 	assert.Len(t, answer.Sources, 3)
 }
 
+// TestQAResultMarkdown tests rendering an answer as markdown.
+func TestQAResultMarkdown(t *testing.T) {
+	result := &QAResult{
+		Question: "How does auth work?",
+		Answer:   "It uses JWT [Source 1].\n",
+		Sources: []search.Result{
+			{RelativePath: "auth.go", StartLine: 10, EndLine: 20, Relevance: 82.4},
+			{RelativePath: "token.go", StartLine: 1, EndLine: 5, ImportedBy: "auth.go"},
+		},
+		Model: "llama3",
+		Usage: &Usage{PromptTokens: 1200, CompletionTokens: 80},
+	}
+
+	assert.Equal(t, "# How does auth work?\n\n"+
+		"It uses JWT [Source 1].\n\n"+
+		"## Sources\n\n"+
+		"1. `auth.go` lines 10-20 (relevance 82)\n"+
+		"2. `token.go` lines 1-5 (imported by `auth.go`)\n\n"+
+		"_Answered by llama3 using 1200 prompt and 80 completion tokens_\n", result.Markdown())
+
+	// Nothing but the answer when the rest is unknown
+	assert.Equal(t, "Nothing found.\n", (&QAResult{Answer: "Nothing found."}).Markdown())
+}
+
 // TestDefaultQAOptions tests default Q&A options.
 func TestDefaultQAOptions(t *testing.T) {
 	opts := DefaultQAOptions()
@@ -293,3 +401,192 @@ func TestProviderConstants(t *testing.T) {
 	assert.Equal(t, Provider("openai"), ProviderOpenAI)
 	assert.Equal(t, Provider("anthropic"), ProviderAnthropic)
 }
+
+// TestQueryExpander tests query expansion.
+func TestQueryExpander(t *testing.T) {
+	server := mockOllamaServer(t, "1. how are JWT tokens refreshed\n2. func refreshToken(token string) (string, error)\n3. jwt refresh")
+	defer server.Close()
+
+	llmSvc, err := NewOllamaService(server.URL, "llama2")
+	require.NoError(t, err)
+
+	expansions, err := NewQueryExpander(llmSvc).Expand(context.Background(), "jwt refresh", 3)
+	require.NoError(t, err)
+
+	// The original query is dropped from the expansions
+	assert.Equal(t, []string{
+		"how are JWT tokens refreshed",
+		"func refreshToken(token string) (string, error)",
+	}, expansions)
+}
+
+// TestParseExpansions tests parsing of LLM expansion output.
+func TestParseExpansions(t *testing.T) {
+	response := "```\n- first query\n* Second Query\n2) third query\n\n\"fourth query\"\nfirst query\n```"
+
+	expansions := parseExpansions(response, "original", 10)
+	assert.Equal(t, []string{"first query", "Second Query", "third query", "fourth query"}, expansions)
+
+	// Limited to n
+	assert.Len(t, parseExpansions(response, "original", 2), 2)
+}
+
+// TestSelectSources tests token-aware context packing.
+func TestSelectSources(t *testing.T) {
+	long := strings.Repeat("line of code here\n", 100) // ~450 tokens
+	short := "func short() {}"                         // ~4 tokens
+
+	results := []search.Result{
+		{RelativePath: "a.go", Content: long, StartLine: 1, EndLine: 100},
+		{RelativePath: "b.go", Content: long, StartLine: 1, EndLine: 100},
+		{RelativePath: "c.go", Content: short, StartLine: 5, EndLine: 5},
+	}
+
+	// No token limit keeps everything
+	selected, report := SelectSources(results, QAOptions{})
+	assert.Len(t, selected, 3)
+	assert.Equal(t, 3, report.Included)
+	assert.Zero(t, report.Truncated)
+	assert.Zero(t, report.Dropped)
+
+	// The second result is truncated to fit and the short one still fits
+	selected, report = SelectSources(results, QAOptions{MaxContextTokens: 800})
+	require.Len(t, selected, 3)
+	assert.Equal(t, 1, report.Truncated)
+	assert.Zero(t, report.Dropped)
+	assert.LessOrEqual(t, report.Tokens, 800)
+	assert.Less(t, selected[1].EndLine, 100)
+	assert.Equal(t, "c.go", selected[2].RelativePath)
+
+	// Too little budget left to truncate drops the result
+	selected, report = SelectSources(results, QAOptions{MaxContextTokens: 500})
+	require.Len(t, selected, 2)
+	assert.Equal(t, "c.go", selected[1].RelativePath)
+	assert.Equal(t, 1, report.Dropped)
+
+	// The chunk limit still applies
+	selected, _ = SelectSources(results, QAOptions{MaxContextChunks: 1})
+	assert.Len(t, selected, 1)
+}
+
+// TestReadSSE tests server-sent event parsing.
+func TestReadSSE(t *testing.T) {
+	stream := ": keep-alive\r\n" +
+		"event: first\r\n" +
+		"data: {\"a\":1}\r\n" +
+		"\r\n" +
+		"data: line one\n" +
+		"data: line two\n" +
+		"\n" +
+		"event: empty\n" +
+		"\n" +
+		"event: last\n" +
+		"data:no space"
+
+	var events []sseEvent
+	err := readSSE(strings.NewReader(stream), func(ev sseEvent) error {
+		events = append(events, ev)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []sseEvent{
+		{Event: "first", Data: `{"a":1}`},
+		{Event: "message", Data: "line one\nline two"},
+		{Event: "last", Data: "no space"},
+	}, events)
+}
+
+// TestAnthropicCompleteStream tests Anthropic streaming over SSE.
+func TestAnthropicCompleteStream(t *testing.T) {
+	stream := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"usage":{"input_tokens":25,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type": "ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":", world"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":15}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+	t.Run("streams text deltas", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "sk-ant-test", r.Header.Get("x-api-key"))
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte(stream))
+		}))
+		defer server.Close()
+
+		svc, err := NewAnthropicService("sk-ant-test", "claude-3")
+		require.NoError(t, err)
+		svc.url = server.URL
+
+		opts := DefaultCompletionOptions()
+		opts.Usage = &Usage{}
+		contentCh, errCh := svc.CompleteStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, opts)
+
+		var sb strings.Builder
+		for content := range contentCh {
+			sb.WriteString(content)
+		}
+		assert.NoError(t, <-errCh)
+		assert.Equal(t, "Hello, world", sb.String())
+		assert.Equal(t, Usage{PromptTokens: 25, CompletionTokens: 15}, *opts.Usage)
+	})
+
+	t.Run("reports error events", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"))
+		}))
+		defer server.Close()
+
+		svc, err := NewAnthropicService("sk-ant-test", "claude-3")
+		require.NoError(t, err)
+		svc.url = server.URL
+
+		contentCh, errCh := svc.CompleteStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, DefaultCompletionOptions())
+		for range contentCh {
+		}
+
+		err = <-errCh
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "overloaded_error")
+	})
+}
+
+// TestRegister tests registering a custom provider.
+func TestRegister(t *testing.T) {
+	Register("custom", func(cfg *config.Config) (Service, error) {
+		return NewOllamaService("http://custom:11434", "custom-model")
+	})
+	t.Cleanup(func() { unregister("custom") })
+
+	for _, name := range []string{"anthropic", "custom", "ollama", "openai"} {
+		assert.Contains(t, Providers(), name)
+	}
+
+	svc, err := NewService(&config.Config{LLM: config.LLMConfig{Provider: "custom"}})
+	require.NoError(t, err)
+	assert.Equal(t, "custom-model", svc.ModelName())
+
+	assert.Panics(t, func() {
+		Register("ollama", func(cfg *config.Config) (Service, error) { return nil, nil })
+	})
+}
@@ -8,16 +8,42 @@ import (
 	"io"
 	"net/http"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/httpclient"
+	"github.com/nickcecere/lgrep/internal/models"
 )
 
+func init() {
+	Register(string(ProviderOllama), func(cfg *config.Config) (Service, error) {
+		svc, err := NewOllamaService(cfg.LLM.Ollama.URL, cfg.LLM.Ollama.Model)
+		if err != nil {
+			return nil, err
+		}
+		client, err := httpclient.New(cfg.Network, cfg.LLM.Ollama.HTTPConfig)
+		if err != nil {
+			return nil, err
+		}
+		svc.SetHTTPClient(client)
+		svc.SetAutoPull(cfg.LLM.Ollama.AutoPull)
+		return svc, nil
+	})
+}
+
 // OllamaService implements the LLM service using Ollama.
 type OllamaService struct {
+	url     string // as configured, e.g. a unix socket URL
 	baseURL string
 	model   string
 	client  *http.Client
+
+	// autoPull downloads the model on first use if Ollama does not have it
+	autoPull bool
+	pullMu   sync.Mutex
+	pulled   bool // Set once the model is known to be present
 }
 
 // ollamaChatRequest is the request body for the Ollama chat API.
@@ -44,25 +70,33 @@ type ollamaChatResponse struct {
 	Done          bool          `json:"done"`
 	DoneReason    string        `json:"done_reason,omitempty"`
 	TotalDuration int64         `json:"total_duration,omitempty"`
+
+	// Token counts, in the final response
+	PromptEvalCount int `json:"prompt_eval_count,omitempty"`
+	EvalCount       int `json:"eval_count,omitempty"`
 }
 
 // NewOllamaService creates a new Ollama LLM service.
+// baseURL may also be a unix socket URL such as unix:///run/ollama.sock.
 func NewOllamaService(baseURL, model string) (*OllamaService, error) {
 	if baseURL == "" {
 		baseURL = "http://localhost:11434"
 	}
 
-	return &OllamaService{
-		baseURL: strings.TrimSuffix(baseURL, "/"),
-		model:   model,
-		client: &http.Client{
-			Timeout: 5 * time.Minute, // LLM calls can be slow
-		},
-	}, nil
+	s := &OllamaService{
+		url:   strings.TrimSuffix(baseURL, "/"),
+		model: model,
+	}
+	s.SetHTTPClient(&http.Client{Timeout: config.DefaultLLMTimeout})
+	return s, nil
 }
 
 // Complete generates a completion for the given messages.
 func (s *OllamaService) Complete(ctx context.Context, messages []Message, opts CompletionOptions) (string, error) {
+	if err := s.ensureModel(ctx); err != nil {
+		return "", err
+	}
+
 	// Convert messages
 	ollamaMessages := make([]ollamaMessage, len(messages))
 	for i, m := range messages {
@@ -102,6 +136,9 @@ func (s *OllamaService) Complete(ctx context.Context, messages []Message, opts C
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return "", models.MissingModelError(s.model, "llm.ollama.auto_pull")
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return "", fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
@@ -111,6 +148,7 @@ func (s *OllamaService) Complete(ctx context.Context, messages []Message, opts C
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return "", fmt.Errorf("failed to decode response: %w", err)
 	}
+	opts.setUsage(result.PromptEvalCount, result.EvalCount)
 
 	return result.Message.Content, nil
 }
@@ -124,6 +162,11 @@ func (s *OllamaService) CompleteStream(ctx context.Context, messages []Message,
 		defer close(contentCh)
 		defer close(errCh)
 
+		if err := s.ensureModel(ctx); err != nil {
+			errCh <- err
+			return
+		}
+
 		// Convert messages
 		ollamaMessages := make([]ollamaMessage, len(messages))
 		for i, m := range messages {
@@ -164,6 +207,10 @@ func (s *OllamaService) CompleteStream(ctx context.Context, messages []Message,
 		}
 		defer resp.Body.Close()
 
+		if resp.StatusCode == http.StatusNotFound {
+			errCh <- models.MissingModelError(s.model, "llm.ollama.auto_pull")
+			return
+		}
 		if resp.StatusCode != http.StatusOK {
 			body, _ := io.ReadAll(resp.Body)
 			errCh <- fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
@@ -193,6 +240,7 @@ func (s *OllamaService) CompleteStream(ctx context.Context, messages []Message,
 			}
 
 			if chunk.Done {
+				opts.setUsage(chunk.PromptEvalCount, chunk.EvalCount)
 				return
 			}
 		}
@@ -201,6 +249,38 @@ func (s *OllamaService) CompleteStream(ctx context.Context, messages []Message,
 	return contentCh, errCh
 }
 
+// SetHTTPClient sets the client used for requests, e.g. one built by
+// httpclient.New from the provider's configuration. For a unix socket URL,
+// its connections are made to the socket.
+func (s *OllamaService) SetHTTPClient(client *http.Client) {
+	s.client, s.baseURL = httpclient.Endpoint(client, s.url)
+}
+
+// SetAutoPull enables pulling the model before the first request if Ollama
+// does not have it.
+func (s *OllamaService) SetAutoPull(enabled bool) {
+	s.autoPull = enabled
+}
+
+// ensureModel pulls the model if auto-pull is enabled and it has not been
+// found yet. A cancelled or failed pull is tried again by the next caller,
+// with its own ctx.
+func (s *OllamaService) ensureModel(ctx context.Context) error {
+	if !s.autoPull {
+		return nil
+	}
+	s.pullMu.Lock()
+	defer s.pullMu.Unlock()
+	if s.pulled {
+		return nil
+	}
+	if err := models.EnsureOllamaModel(ctx, s.client, s.baseURL, s.model); err != nil {
+		return err
+	}
+	s.pulled = true
+	return nil
+}
+
 // Provider returns the provider name.
 func (s *OllamaService) Provider() Provider {
 	return ProviderOllama
@@ -3,16 +3,36 @@ package llm
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/charmbracelet/log"
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/httpclient"
 )
 
+func init() {
+	Register(string(ProviderOpenAI), func(cfg *config.Config) (Service, error) {
+		svc, err := NewOpenAIService(cfg.LLM.OpenAI.APIKey, cfg.LLM.OpenAI.Model, cfg.LLM.OpenAI.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		client, err := httpclient.New(cfg.Network, cfg.LLM.OpenAI.HTTPConfig)
+		if err != nil {
+			return nil, err
+		}
+		svc.SetHTTPClient(client)
+		return svc, nil
+	})
+}
+
 // OpenAIService implements the LLM service using OpenAI.
 type OpenAIService struct {
-	client openai.Client
-	model  string
+	client  openai.Client
+	options []option.RequestOption // Options client was created with
+	model   string
 }
 
 // NewOpenAIService creates a new OpenAI LLM service.
@@ -31,11 +51,18 @@ func NewOpenAIService(apiKey, model, baseURL string) (*OpenAIService, error) {
 	client := openai.NewClient(opts...)
 
 	return &OpenAIService{
-		client: client,
-		model:  model,
+		client:  client,
+		options: opts,
+		model:   model,
 	}, nil
 }
 
+// SetHTTPClient sets the client used for requests, e.g. one built by
+// httpclient.New from the provider's configuration.
+func (s *OpenAIService) SetHTTPClient(client *http.Client) {
+	s.client = openai.NewClient(append(s.options, option.WithHTTPClient(client))...)
+}
+
 // Complete generates a completion for the given messages.
 func (s *OpenAIService) Complete(ctx context.Context, messages []Message, opts CompletionOptions) (string, error) {
 	log.Debug("Requesting completion from OpenAI", "model", s.model)
@@ -68,6 +95,7 @@ func (s *OpenAIService) Complete(ctx context.Context, messages []Message, opts C
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("no completion returned")
 	}
+	opts.setUsage(int(resp.Usage.PromptTokens), int(resp.Usage.CompletionTokens))
 
 	return resp.Choices[0].Message.Content, nil
 }
@@ -101,6 +129,10 @@ func (s *OpenAIService) CompleteStream(ctx context.Context, messages []Message,
 			Messages:    openaiMessages,
 			Temperature: openai.Float(opts.Temperature),
 			MaxTokens:   openai.Int(int64(opts.MaxTokens)),
+			// Ask for the token counts in a final chunk
+			StreamOptions: openai.ChatCompletionStreamOptionsParam{
+				IncludeUsage: openai.Bool(true),
+			},
 		})
 
 		for stream.Next() {
@@ -108,6 +140,9 @@ func (s *OpenAIService) CompleteStream(ctx context.Context, messages []Message,
 			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
 				contentCh <- chunk.Choices[0].Delta.Content
 			}
+			if chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0 {
+				opts.setUsage(int(chunk.Usage.PromptTokens), int(chunk.Usage.CompletionTokens))
+			}
 		}
 
 		if err := stream.Err(); err != nil {
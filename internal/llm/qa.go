@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/nickcecere/lgrep/internal/search"
 )
@@ -26,6 +27,13 @@ type QAOptions struct {
 
 	// MaxContextChunks limits how many search results to include.
 	MaxContextChunks int
+
+	// MaxContextTokens limits the estimated size of the context sent to the
+	// LLM. Results that do not fit are truncated or dropped. Zero means no limit.
+	MaxContextTokens int
+
+	// Timings, if set, collects the time spent generating the answer.
+	Timings *search.Timings
 }
 
 // DefaultQAOptions returns sensible defaults.
@@ -40,8 +48,55 @@ func DefaultQAOptions() QAOptions {
 
 // QAResult contains the answer and its sources.
 type QAResult struct {
-	Answer  string          `json:"answer"`
-	Sources []search.Result `json:"sources"`
+	Question string          `json:"question,omitempty"`
+	Answer   string          `json:"answer"`
+	Sources  []search.Result `json:"sources"`
+
+	// Provider and Model generated the answer.
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+
+	// Usage is the tokens used to generate the answer, if known.
+	Usage *Usage `json:"usage,omitempty"`
+
+	// Cached reports that the answer was reused from an earlier identical
+	// question and context rather than generated.
+	Cached bool `json:"cached,omitempty"`
+}
+
+// Markdown renders the question, answer and sources as a markdown document,
+// for saving or pasting into a pull request.
+func (r *QAResult) Markdown() string {
+	var sb strings.Builder
+	if r.Question != "" {
+		fmt.Fprintf(&sb, "# %s\n\n", r.Question)
+	}
+	sb.WriteString(strings.TrimSpace(r.Answer))
+	sb.WriteString("\n")
+
+	if len(r.Sources) > 0 {
+		sb.WriteString("\n## Sources\n\n")
+		for i, s := range r.Sources {
+			fmt.Fprintf(&sb, "%d. `%s` lines %d-%d", i+1, s.RelativePath, s.StartLine, s.EndLine)
+			if s.ImportedBy != "" {
+				fmt.Fprintf(&sb, " (imported by `%s`)\n", s.ImportedBy)
+			} else {
+				fmt.Fprintf(&sb, " (relevance %.0f)\n", s.Relevance)
+			}
+		}
+	}
+
+	if r.Model != "" {
+		fmt.Fprintf(&sb, "\n_Answered by %s", r.Model)
+		if r.Usage != nil {
+			fmt.Fprintf(&sb, " using %d prompt and %d completion tokens", r.Usage.PromptTokens, r.Usage.CompletionTokens)
+		}
+		if r.Cached {
+			sb.WriteString(" (cached)")
+		}
+		sb.WriteString("_\n")
+	}
+	return sb.String()
 }
 
 // NewQAService creates a new Q&A service.
@@ -53,16 +108,14 @@ func NewQAService(llm Service) *QAService {
 func (qa *QAService) Answer(ctx context.Context, question string, results []search.Result, opts QAOptions) (*QAResult, error) {
 	if len(results) == 0 {
 		return &QAResult{
-			Answer:  "I couldn't find any relevant code to answer your question. Try rephrasing your query or indexing more files.",
-			Sources: nil,
+			Question: question,
+			Answer:   "I couldn't find any relevant code to answer your question. Try rephrasing your query or indexing more files.",
+			Sources:  nil,
 		}, nil
 	}
 
-	// Limit context chunks
-	contextResults := results
-	if opts.MaxContextChunks > 0 && len(results) > opts.MaxContextChunks {
-		contextResults = results[:opts.MaxContextChunks]
-	}
+	// Select the results sent as context
+	contextResults, _ := SelectSources(results, opts)
 
 	// Build context from search results
 	context := buildContext(contextResults)
@@ -80,18 +133,29 @@ func (qa *QAService) Answer(ctx context.Context, question string, results []sear
 	}
 
 	// Generate answer
+	start := time.Now()
+	usage := &Usage{}
 	answer, err := qa.llm.Complete(ctx, messages, CompletionOptions{
 		Temperature: opts.Temperature,
 		MaxTokens:   opts.MaxTokens,
+		Usage:       usage,
 	})
+	opts.Timings.Since(search.PhaseLLM, start)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate answer: %w", err)
 	}
 
-	return &QAResult{
-		Answer:  answer,
-		Sources: contextResults,
-	}, nil
+	result := &QAResult{
+		Question: question,
+		Answer:   answer,
+		Sources:  contextResults,
+		Provider: string(qa.llm.Provider()),
+		Model:    qa.llm.ModelName(),
+	}
+	if usage.Reported() {
+		result.Usage = usage
+	}
+	return result, nil
 }
 
 // AnswerStream generates a streaming answer.
@@ -105,11 +169,8 @@ func (qa *QAService) AnswerStream(ctx context.Context, question string, results
 		return contentCh, errCh, nil
 	}
 
-	// Limit context chunks
-	contextResults := results
-	if opts.MaxContextChunks > 0 && len(results) > opts.MaxContextChunks {
-		contextResults = results[:opts.MaxContextChunks]
-	}
+	// Select the results sent as context
+	contextResults, _ := SelectSources(results, opts)
 
 	// Build context from search results
 	context := buildContext(contextResults)
@@ -127,13 +188,27 @@ func (qa *QAService) AnswerStream(ctx context.Context, question string, results
 	}
 
 	// Stream answer
+	start := time.Now()
 	contentCh, errCh := qa.llm.CompleteStream(ctx, messages, CompletionOptions{
 		Temperature: opts.Temperature,
 		MaxTokens:   opts.MaxTokens,
 		Stream:      true,
 	})
+	if opts.Timings == nil {
+		return contentCh, errCh, contextResults
+	}
 
-	return contentCh, errCh, contextResults
+	// Time the stream until it is fully consumed
+	timedCh := make(chan string, cap(contentCh))
+	go func() {
+		defer close(timedCh)
+		for content := range contentCh {
+			timedCh <- content
+		}
+		opts.Timings.Since(search.PhaseLLM, start)
+	}()
+
+	return timedCh, errCh, contextResults
 }
 
 // buildContext creates the context string from search results.
@@ -143,8 +218,13 @@ func buildContext(results []search.Result) string {
 	sb.WriteString("Here is the relevant code context:\n\n")
 
 	for i, r := range results {
-		sb.WriteString(fmt.Sprintf("--- Source [%d]: %s (lines %d-%d, %.0f%% match) ---\n",
-			i+1, r.RelativePath, r.StartLine, r.EndLine, r.Score*100))
+		if r.ImportedBy != "" {
+			sb.WriteString(fmt.Sprintf("--- Source [%d]: %s (lines %d-%d, imported by %s) ---\n",
+				i+1, r.RelativePath, r.StartLine, r.EndLine, r.ImportedBy))
+		} else {
+			sb.WriteString(fmt.Sprintf("--- Source [%d]: %s (lines %d-%d, relevance %.0f/100) ---\n",
+				i+1, r.RelativePath, r.StartLine, r.EndLine, r.Relevance))
+		}
 		sb.WriteString(r.Content)
 		sb.WriteString("\n\n")
 	}
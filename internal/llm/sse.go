@@ -0,0 +1,72 @@
+package llm
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// sseEvent is a single server-sent event.
+type sseEvent struct {
+	Event string // Event type from the "event:" field, "message" if unset
+	Data  string // Joined "data:" lines
+}
+
+// readSSE parses a server-sent event stream and calls fn for each complete
+// event. Parsing stops when the stream ends or fn returns an error, which is
+// passed back to the caller. Comments and unknown fields are ignored.
+func readSSE(r io.Reader, fn func(sseEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	// Events can carry large JSON payloads
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var event string
+	var data []string
+
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		ev := sseEvent{Event: event, Data: strings.Join(data, "\n")}
+		if ev.Event == "" {
+			ev.Event = "message"
+		}
+		event, data = "", nil
+		return fn(ev)
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		// A blank line ends the current event
+		if line == "" {
+			if err := dispatch(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Lines starting with a colon are comments (e.g. keep-alives)
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Dispatch a final event that was not followed by a blank line
+	return dispatch()
+}
@@ -0,0 +1,146 @@
+// Package lock provides advisory file locks that coordinate lgrep processes
+// sharing the same index database.
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned by TryAcquire when another process holds the lock.
+var ErrLocked = errors.New("lock is held by another process")
+
+// pollInterval is how often Acquire retries a held lock.
+const pollInterval = 250 * time.Millisecond
+
+// Lock is an exclusive advisory lock backed by a file. The lock is released
+// automatically by the operating system if the process exits.
+type Lock struct {
+	file *os.File
+	path string
+}
+
+// StorePath returns the lock file path for a store in the given database.
+func StorePath(dbPath, storeName string) string {
+	return NamespaceStorePath(dbPath, "", storeName)
+}
+
+// NamespaceStorePath returns the lock file path for a store in a namespace
+// of the given database, so stores with the same name in different
+// namespaces do not share a lock.
+func NamespaceStorePath(dbPath, namespace, storeName string) string {
+	dir := filepath.Join(filepath.Dir(dbPath), "locks")
+	if namespace != "" {
+		dir = filepath.Join(dir, "namespaces", fileName(namespace))
+	}
+	return filepath.Join(dir, fileName(storeName)+".lock")
+}
+
+// fileName returns name with the bytes not allowed in lock file names
+// escaped as %XX. '%' is escaped too, so different names never share a
+// file, and names made only of dots are escaped whole so they cannot refer
+// to a directory.
+func fileName(name string) string {
+	allDots := strings.Trim(name, ".") == ""
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if safeChar(c) && !(allDots && c == '.') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// safeChar reports whether c may appear unescaped in a lock file name.
+func safeChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '.' || c == '_' || c == '-'
+}
+
+// TryAcquire takes the lock at path without waiting. It returns ErrLocked if
+// another process holds it.
+func TryAcquire(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// Record the holder so waiting processes can report who they wait for
+	f.Truncate(0)
+	f.WriteAt([]byte(fmt.Sprintf("%d %s\n", os.Getpid(), strings.Join(os.Args, " "))), 0)
+
+	return &Lock{file: f, path: path}, nil
+}
+
+// Acquire takes the lock at path, waiting until it is free or ctx is done.
+func Acquire(ctx context.Context, path string) (*Lock, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		l, err := TryAcquire(path)
+		if !errors.Is(err, ErrLocked) {
+			return l, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Holder returns a description of the process holding the lock at path,
+// or an empty string if it is unknown.
+func Holder(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Held reports whether a process holds the lock at path. It takes the lock
+// only for the moment of the check and does not record itself as holder.
+func Held(path string) bool {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return errors.Is(err, ErrLocked)
+	}
+	unlockFile(f)
+	return false
+}
+
+// Release releases the lock.
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	unlockFile(l.file)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
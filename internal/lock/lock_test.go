@@ -0,0 +1,89 @@
+package lock
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTryAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "test.lock")
+	assert.False(t, Held(path))
+
+	l, err := TryAcquire(path)
+	require.NoError(t, err)
+
+	// A second holder is refused
+	_, err = TryAcquire(path)
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.Contains(t, Holder(path), " ")
+
+	assert.True(t, Held(path))
+
+	require.NoError(t, l.Release())
+	assert.False(t, Held(path))
+
+	// Free again after release
+	l, err = TryAcquire(path)
+	require.NoError(t, err)
+	require.NoError(t, l.Release())
+}
+
+func TestAcquireWaits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	held, err := TryAcquire(path)
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		held.Release()
+	}()
+
+	start := time.Now()
+	l, err := Acquire(context.Background(), path)
+	require.NoError(t, err)
+	defer l.Release()
+	assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+}
+
+func TestAcquireCancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	held, err := TryAcquire(path)
+	require.NoError(t, err)
+	defer held.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = Acquire(ctx, path)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestStorePath(t *testing.T) {
+	assert.Equal(t, filepath.Join("/data", "locks", "my%2Fproject.lock"), StorePath("/data/index.db", "my/project"))
+	assert.Equal(t, filepath.Join("/data", "locks", "api-v2.lock"), StorePath("/data/index.db", "api-v2"))
+	assert.Equal(t, filepath.Join("/data", "locks", "%2E%2E.lock"), StorePath("/data/index.db", ".."))
+	assert.Equal(t, filepath.Join("/data", "locks", "namespaces", "team%2Fa", "api.lock"),
+		NamespaceStorePath("/data/index.db", "team/a", "api"))
+}
+
+// TestStorePathDistinct tests that names differing only in unsafe
+// characters get different lock files.
+func TestStorePathDistinct(t *testing.T) {
+	pairs := [][2]string{
+		{"my/project", "my_project"},
+		{"my project", "my_project"},
+		{"a%2Fb", "a/b"},
+		{"プロジェクト", "_________"},
+	}
+	for _, p := range pairs {
+		assert.NotEqual(t, StorePath("/data/index.db", p[0]), StorePath("/data/index.db", p[1]), p)
+		assert.NotEqual(t, NamespaceStorePath("/data/index.db", p[0], "api"), NamespaceStorePath("/data/index.db", p[1], "api"), p)
+	}
+}
@@ -0,0 +1,86 @@
+// Package manifest describes what a store has indexed: every file with its
+// content hash, size and chunk count, for scripts and CI jobs that check an
+// index against a checkout, and for finding out why a file was skipped.
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// Manifest lists the indexed files of a store.
+type Manifest struct {
+	Store      string    `json:"store"`
+	Root       string    `json:"root"`
+	Provider   string    `json:"provider"`
+	Model      string    `json:"model"`
+	Dimensions int       `json:"dimensions"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	FileCount  int       `json:"file_count"`
+	ChunkCount int       `json:"chunk_count"`
+	TotalSize  int64     `json:"total_size"`
+	Files      []File    `json:"files"`
+}
+
+// File is one indexed file. Hash is the hash of its content when it was
+// indexed, as computed by fs.HashContent, so it can be compared with the
+// file in a checkout.
+type File struct {
+	Path       string    `json:"path"` // Relative to the store root, with forward slashes
+	Hash       string    `json:"hash"`
+	Size       int64     `json:"size"`
+	Chunks     int       `json:"chunks"`
+	Language   string    `json:"language,omitempty"`
+	Generated  bool      `json:"generated,omitempty"`
+	IndexedAt  time.Time `json:"indexed_at"`
+	ModifiedAt time.Time `json:"modified_at"` // Zero if indexed before it was recorded
+}
+
+// Build returns the manifest of a store, with its files sorted by path.
+func Build(st store.Store, storeRecord *store.StoreRecord) (*Manifest, error) {
+	chunks, err := st.CountFileChunks(storeRecord.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Store:      storeRecord.Name,
+		Root:       storeRecord.RootPath,
+		Provider:   string(storeRecord.EmbeddingProvider),
+		Model:      storeRecord.EmbeddingModel,
+		Dimensions: storeRecord.EmbeddingDimensions,
+		UpdatedAt:  storeRecord.UpdatedAt,
+		Files:      []File{},
+	}
+	err = st.WalkFiles(storeRecord.ID, nil, func(f store.FileRecord) error {
+		m.Files = append(m.Files, File{
+			Path:       f.RelativePath,
+			Hash:       f.Hash,
+			Size:       f.FileSize,
+			Chunks:     chunks[f.ID],
+			Language:   f.Language,
+			Generated:  f.Generated,
+			IndexedAt:  f.IndexedAt,
+			ModifiedAt: f.ModifiedAt,
+		})
+		m.ChunkCount += chunks[f.ID]
+		m.TotalSize += f.FileSize
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	m.FileCount = len(m.Files)
+	return m, nil
+}
+
+// Write writes m to w as indented JSON.
+func Write(w io.Writer, m *Manifest) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
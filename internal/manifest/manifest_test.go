@@ -0,0 +1,89 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+func TestBuild(t *testing.T) {
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	storeRecord, err := st.CreateStore("test", "/repo", store.ProviderOllama, "model", 2)
+	require.NoError(t, err)
+
+	files := []struct {
+		path   string
+		size   int64
+		chunks int
+	}{
+		{"main.go", 120, 2},
+		{"docs/guide.md", 300, 3},
+	}
+	for _, f := range files {
+		var chunks []store.Chunk
+		var vectors [][]float32
+		for i := 0; i < f.chunks; i++ {
+			chunks = append(chunks, store.Chunk{Content: "x", StartLine: i + 1, EndLine: i + 1, ChunkIndex: i})
+			vectors = append(vectors, []float32{1, 0})
+		}
+		err := st.UpsertFile(storeRecord.ID, store.FileInput{
+			ExternalID:   f.path,
+			Path:         "/repo/" + f.path,
+			RelativePath: f.path,
+			Hash:         "xxh64:" + f.path,
+			FileSize:     f.size,
+			Language:     "go",
+		}, chunks, vectors)
+		require.NoError(t, err)
+	}
+
+	m, err := Build(st, storeRecord)
+	require.NoError(t, err)
+	assert.Equal(t, "test", m.Store)
+	assert.Equal(t, "/repo", m.Root)
+	assert.Equal(t, "model", m.Model)
+	assert.Equal(t, 2, m.FileCount)
+	assert.Equal(t, 5, m.ChunkCount)
+	assert.Equal(t, int64(420), m.TotalSize)
+
+	require.Len(t, m.Files, 2)
+	assert.Equal(t, "docs/guide.md", m.Files[0].Path)
+	assert.Equal(t, "xxh64:docs/guide.md", m.Files[0].Hash)
+	assert.Equal(t, int64(300), m.Files[0].Size)
+	assert.Equal(t, 3, m.Files[0].Chunks)
+	assert.Equal(t, "main.go", m.Files[1].Path)
+	assert.Equal(t, 2, m.Files[1].Chunks)
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, m))
+	var decoded Manifest
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, m.Files[0].Path, decoded.Files[0].Path)
+	assert.Equal(t, m.ChunkCount, decoded.ChunkCount)
+}
+
+// TestBuildEmpty tests that an empty store lists no files as an empty array.
+func TestBuildEmpty(t *testing.T) {
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	storeRecord, err := st.CreateStore("empty", "/repo", store.ProviderOllama, "model", 2)
+	require.NoError(t, err)
+
+	m, err := Build(st, storeRecord)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, m))
+	assert.Contains(t, buf.String(), `"files": []`)
+}
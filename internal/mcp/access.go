@@ -0,0 +1,81 @@
+package mcp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AllowAnyPath lets tools index and search any directory, ignoring
+// mcp.allowed_roots.
+func (s *Server) AllowAnyPath() {
+	s.allowAnyPath = true
+}
+
+// checkPath returns an error if tools may not access path. Agents choose the
+// paths they pass to tools, so only directories under the configured roots
+// are allowed, by default the directory the server was started in.
+func (s *Server) checkPath(path string) error {
+	if s.allowAnyPath {
+		return nil
+	}
+
+	resolved := resolvePath(path)
+	roots := s.allowedRoots()
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is outside the allowed directories (%s). Add it to mcp.allowed_roots or start the server with --allow-any-path",
+		path, strings.Join(roots, ", "))
+}
+
+// allowedRoots returns the directories tools may access, with symlinks
+// resolved.
+func (s *Server) allowedRoots() []string {
+	configured := s.cfg.Load().MCP.AllowedRoots
+	if len(configured) == 0 {
+		return []string{resolvePath(s.launchDir)}
+	}
+
+	roots := make([]string, 0, len(configured))
+	for _, root := range configured {
+		roots = append(roots, resolvePath(expandHome(root)))
+	}
+	return roots
+}
+
+// expandHome replaces a leading ~ in a configured path with the user's home
+// directory.
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// resolvePath returns path as an absolute path with symlinks resolved, so a
+// link inside an allowed directory cannot lead outside it. For paths that do
+// not exist, the nearest existing parent is resolved.
+func resolvePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	missing := ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(resolved, missing)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, missing)
+		}
+		missing = filepath.Join(filepath.Base(path), missing)
+		path = parent
+	}
+}
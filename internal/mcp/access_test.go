@@ -0,0 +1,68 @@
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/config"
+)
+
+// TestCheckPath tests which paths tools may access, for the launch directory
+// and for configured roots.
+func TestCheckPath(t *testing.T) {
+	base := t.TempDir()
+	repo := filepath.Join(base, "repo")
+	for _, dir := range []string{
+		filepath.Join(repo, "src"),
+		filepath.Join(base, "repo2"),
+		filepath.Join(base, "outside"),
+	} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+	require.NoError(t, os.Symlink(filepath.Join(base, "outside"), filepath.Join(repo, "escape")))
+	require.NoError(t, os.Symlink(filepath.Join(repo, "src"), filepath.Join(base, "link")))
+
+	tests := []struct {
+		name    string
+		roots   []string
+		path    string
+		allowed bool
+	}{
+		{"launch dir", nil, repo, true},
+		{"subdirectory", nil, filepath.Join(repo, "src"), true},
+		{"missing subdirectory", nil, filepath.Join(repo, "new"), true},
+		{"trailing slash", nil, repo + string(filepath.Separator), true},
+		{"parent", nil, base, false},
+		{"dot dot traversal", nil, filepath.Join(repo, "src", "..", "..", "outside"), false},
+		{"dot dot back inside", nil, repo + "/src/../src", true},
+		{"prefix collision", nil, filepath.Join(base, "repo2"), false},
+		{"symlink escape", nil, filepath.Join(repo, "escape"), false},
+		{"symlink escape subdirectory", nil, filepath.Join(repo, "escape", "x"), false},
+		{"symlink into root", nil, filepath.Join(base, "link"), true},
+		{"configured root", []string{filepath.Join(base, "outside")}, filepath.Join(base, "outside"), true},
+		{"configured root replaces launch dir", []string{filepath.Join(base, "outside")}, repo, false},
+		{"symlink to configured root", []string{filepath.Join(base, "outside")}, filepath.Join(repo, "escape"), true},
+		{"configured prefix collision", []string{repo}, filepath.Join(base, "repo2"), false},
+		{"configured symlinked root", []string{filepath.Join(base, "link")}, filepath.Join(repo, "src"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{launchDir: repo}
+			s.cfg.Store(&config.Config{MCP: config.MCPConfig{AllowedRoots: tt.roots}})
+
+			err := s.checkPath(tt.path)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, "outside the allowed directories")
+			}
+
+			s.AllowAnyPath()
+			assert.NoError(t, s.checkPath(tt.path))
+		})
+	}
+}
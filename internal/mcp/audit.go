@@ -0,0 +1,76 @@
+package mcp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// AuditEntry is one line of the audit log, recording a tool call.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+
+	// Client is the name and version the client sent with initialize, and
+	// PID the server's process, to tell concurrent sessions apart.
+	Client        string `json:"client,omitempty"`
+	ClientVersion string `json:"client_version,omitempty"`
+	PID           int    `json:"pid"`
+	Namespace     string `json:"namespace,omitempty"`
+
+	Tool        string         `json:"tool"`
+	Arguments   map[string]any `json:"arguments,omitempty"`
+	ResultBytes int            `json:"result_bytes"`
+	IsError     bool           `json:"is_error,omitempty"`
+	LatencyMS   int64          `json:"latency_ms"`
+}
+
+// audit appends a tool call to the audit log, if mcp.audit_log is set. The
+// file is opened for each entry so a changed path or a rotated file takes
+// effect immediately. Failures are logged but do not fail the call.
+func (s *Server) audit(p CallToolParams, result string, isError bool, latency time.Duration) {
+	cfg := s.cfg.Load()
+	path := cfg.MCP.AuditLog
+	if path == "" {
+		return
+	}
+
+	line, err := json.Marshal(AuditEntry{
+		Time:          time.Now().UTC(),
+		Client:        s.client.Name,
+		ClientVersion: s.client.Version,
+		PID:           os.Getpid(),
+		Namespace:     cfg.Database.Namespace,
+		Tool:          p.Name,
+		Arguments:     p.Arguments,
+		ResultBytes:   len(result),
+		IsError:       isError,
+		LatencyMS:     latency.Milliseconds(),
+	})
+	if err != nil {
+		log.Warn("Failed to encode audit entry", "error", err)
+		return
+	}
+
+	path = expandHome(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Warn("Failed to create audit log directory", "error", err)
+		return
+	}
+
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Warn("Failed to open audit log", "path", path, "error", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Warn("Failed to write audit log", "path", path, "error", err)
+	}
+}
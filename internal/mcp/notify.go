@@ -0,0 +1,91 @@
+package mcp
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// NotificationIndexUpdated tells the client that a store's index changed,
+	// so results from earlier searches may be stale.
+	NotificationIndexUpdated = "notifications/lgrep/indexUpdated"
+
+	// notifyDelay is how long index updates are collected before they are
+	// sent as one notification, so a burst of file changes is one message.
+	notifyDelay = time.Second
+
+	// maxNotifyPaths caps the paths listed in one notification.
+	maxNotifyPaths = 20
+)
+
+// IndexUpdatedParams are the parameters of NotificationIndexUpdated.
+type IndexUpdatedParams struct {
+	Store string `json:"store"`
+
+	// Changes is the number of files indexed or removed, and Paths the
+	// first of them.
+	Changes int      `json:"changes"`
+	Paths   []string `json:"paths,omitempty"`
+
+	// Files and Chunks are the store's totals after the update.
+	Files  int `json:"files"`
+	Chunks int `json:"chunks"`
+}
+
+// NotifyIndexUpdated tells the client that path in a store was indexed or
+// removed; an empty path stands for a full re-index. Updates are collected
+// for notifyDelay and sent together. Nothing is sent before the client has
+// finished initializing.
+func (s *Server) NotifyIndexUpdated(storeName, path string) {
+	if !s.initialized.Load() {
+		return
+	}
+
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+
+	if s.pending[storeName] == nil {
+		if s.pending == nil {
+			s.pending = make(map[string]*IndexUpdatedParams)
+		}
+		s.pending[storeName] = &IndexUpdatedParams{Store: storeName}
+		time.AfterFunc(notifyDelay, func() { s.sendIndexUpdated(storeName) })
+	}
+
+	p := s.pending[storeName]
+	p.Changes++
+	if path != "" && len(p.Paths) < maxNotifyPaths {
+		p.Paths = append(p.Paths, path)
+	}
+}
+
+// sendIndexUpdated sends the updates collected for a store.
+func (s *Server) sendIndexUpdated(storeName string) {
+	s.notifyMu.Lock()
+	p := s.pending[storeName]
+	delete(s.pending, storeName)
+	s.notifyMu.Unlock()
+	if p == nil {
+		return
+	}
+
+	if storeRecord, err := s.store.GetStore(storeName); err == nil && storeRecord != nil {
+		if stats, err := s.store.GetStats(storeRecord.ID); err == nil {
+			p.Files, p.Chunks = stats.FileCount, stats.ChunkCount
+		}
+	}
+
+	params, err := json.Marshal(p)
+	if err != nil {
+		log.Error("Failed to marshal notification", "error", err)
+		return
+	}
+	log.Debug("Notifying client of index update", "store", storeName, "changes", p.Changes)
+	s.send(Notification{
+		JSONRPC: "2.0",
+		Method:  NotificationIndexUpdated,
+		Params:  params,
+	})
+}
@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// promptDefinition is a prompt the server offers, with the function that
+// renders its text from the client's arguments.
+type promptDefinition struct {
+	Prompt
+	render func(args map[string]string) string
+}
+
+// prompts are curated retrieval workflows. Each tells the agent which
+// lgrep_search calls to make, with defaults that work well for the task.
+var prompts = []promptDefinition{
+	{
+		Prompt: Prompt{
+			Name:        "explain-this-code-area",
+			Description: "Explain how an area of the codebase works, based on the code lgrep finds for it",
+			Arguments: []PromptArgument{
+				{Name: "area", Description: "The feature, component or concept to explain, e.g. \"request authentication\"", Required: true},
+				{Name: "path", Description: "Project directory (default: current directory)"},
+			},
+		},
+		render: func(args map[string]string) string {
+			return fmt.Sprintf(`Explain how %[1]q works in the project at %[2]q.
+
+1. Call lgrep_search with query %[1]q, path %[2]q and limit 10.
+2. Call lgrep_search again with one or two rephrasings that name likely functions, types or files from the first results, to fill in gaps.
+3. For the most relevant results, call lgrep_read with the file and a start_line a little before the hit to see the surrounding code.
+4. Explain the area: its entry points, the main types and functions, how data flows between them, and anything surprising. Cite code as file:line.
+
+Base the explanation only on code you have read; say so if the search did not find enough to answer.`, args["area"], pathArg(args))
+		},
+	},
+	{
+		Prompt: Prompt{
+			Name:        "find-implementation",
+			Description: "Find where a feature or behavior is implemented, excluding tests",
+			Arguments: []PromptArgument{
+				{Name: "feature", Description: "What to find, e.g. \"retry with exponential backoff\"", Required: true},
+				{Name: "path", Description: "Project directory (default: current directory)"},
+			},
+		},
+		render: func(args map[string]string) string {
+			return fmt.Sprintf(`Find where %[1]q is implemented in the project at %[2]q.
+
+1. Call lgrep_search with query "%[1]s -test -mock", path %[2]q and limit 5, so test code is excluded.
+2. If no result clearly implements it, search again with the names of functions or types that would implement it.
+3. Confirm the best candidates with lgrep_read before answering; a result that only calls or mentions the feature is not its implementation.
+4. Answer with the file and line range of the implementation and a short summary of how it works. List other relevant locations (callers, configuration) separately.`, args["feature"], pathArg(args))
+		},
+	},
+}
+
+// pathArg returns the path argument of a prompt, or the current directory.
+func pathArg(args map[string]string) string {
+	if path := args["path"]; path != "" {
+		return path
+	}
+	return "."
+}
+
+// handleListPrompts returns the list of available prompts.
+func (s *Server) handleListPrompts() (*ListPromptsResult, error) {
+	result := &ListPromptsResult{Prompts: make([]Prompt, len(prompts))}
+	for i, p := range prompts {
+		result.Prompts[i] = p.Prompt
+	}
+	return result, nil
+}
+
+// handleGetPrompt renders a prompt with the client's arguments.
+func (s *Server) handleGetPrompt(params json.RawMessage) (*GetPromptResult, error) {
+	var p GetPromptParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	for _, def := range prompts {
+		if def.Name != p.Name {
+			continue
+		}
+		for _, arg := range def.Arguments {
+			if arg.Required && p.Arguments[arg.Name] == "" {
+				return nil, fmt.Errorf("prompt %s requires argument %s", p.Name, arg.Name)
+			}
+		}
+		return &GetPromptResult{
+			Description: def.Description,
+			Messages: []PromptMessage{{
+				Role:    "user",
+				Content: ContentBlock{Type: "text", Text: def.render(p.Arguments)},
+			}},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown prompt: %s", p.Name)
+}
@@ -1,7 +1,11 @@
 // Package mcp implements the Model Context Protocol server for lgrep.
 package mcp
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"github.com/nickcecere/lgrep/internal/health"
+)
 
 // JSON-RPC 2.0 types
 
@@ -47,7 +51,12 @@ type ServerInfo struct {
 
 // ServerCapabilities describes what the server can do.
 type ServerCapabilities struct {
-	Tools *ToolsCapability `json:"tools,omitempty"`
+	Tools   *ToolsCapability   `json:"tools,omitempty"`
+	Prompts *PromptsCapability `json:"prompts,omitempty"`
+
+	// Experimental lists non-standard features, here the notifications
+	// the server sends.
+	Experimental map[string]any `json:"experimental,omitempty"`
 }
 
 // ToolsCapability indicates the server supports tools.
@@ -55,6 +64,11 @@ type ToolsCapability struct {
 	// Empty struct indicates capability is present
 }
 
+// PromptsCapability indicates the server supports prompts.
+type PromptsCapability struct {
+	// Empty struct indicates capability is present
+}
+
 // InitializeParams are the parameters for the initialize request.
 type InitializeParams struct {
 	ProtocolVersion string             `json:"protocolVersion"`
@@ -78,6 +92,16 @@ type InitializeResult struct {
 	ProtocolVersion string             `json:"protocolVersion"`
 	Capabilities    ServerCapabilities `json:"capabilities"`
 	ServerInfo      ServerInfo         `json:"serverInfo"`
+
+	// Instructions tell the client how to use the server, here whether it
+	// started in a degraded mode.
+	Instructions string `json:"instructions,omitempty"`
+}
+
+// PingResult is the response to ping. MCP only requires an empty result;
+// the server's health is included for clients that want it.
+type PingResult struct {
+	Health *health.Report `json:"health,omitempty"`
 }
 
 // Tool represents a tool that can be called.
@@ -124,6 +148,43 @@ type ContentBlock struct {
 	Text string `json:"text,omitempty"`
 }
 
+// Prompt represents a prompt template the client can request.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument describes an argument of a prompt.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// ListPromptsResult is the response to prompts/list.
+type ListPromptsResult struct {
+	Prompts []Prompt `json:"prompts"`
+}
+
+// GetPromptParams are the parameters for prompts/get.
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// GetPromptResult is the response to prompts/get.
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// PromptMessage is a message of a rendered prompt.
+type PromptMessage struct {
+	Role    string       `json:"role"`
+	Content ContentBlock `json:"content"`
+}
+
 // Notification types
 
 // Notification represents a JSON-RPC 2.0 notification (no id, no response expected).
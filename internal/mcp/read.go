@@ -0,0 +1,128 @@
+package mcp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nickcecere/lgrep/internal/indexer"
+)
+
+const (
+	// maxReadLines caps the lines lgrep_read returns in one call.
+	maxReadLines = 400
+
+	// maxReadBytes caps the content lgrep_read returns in one call, for
+	// files with very long lines.
+	maxReadBytes = 64 * 1024
+)
+
+// toolRead returns a line range of an indexed file. Only files in the index
+// can be read, so agents cannot use it to reach arbitrary files. The file is
+// read from disk, or from the index if it was stored with its content.
+func (s *Server) toolRead(args map[string]any) (string, bool) {
+	file, _ := args["file"].(string)
+	if file == "" {
+		return "Error: file is required", true
+	}
+
+	path := "."
+	if p, ok := args["path"].(string); ok && p != "" {
+		path = p
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Sprintf("Error: failed to resolve path: %v", err), true
+	}
+	if err := s.checkPath(absPath); err != nil {
+		return fmt.Sprintf("Error: %v", err), true
+	}
+
+	storeRecord, err := s.store.GetStore(indexer.StoreName(s.cfg.Load(), absPath))
+	if err != nil || storeRecord == nil {
+		return fmt.Sprintf("Error: %s is not indexed. Call lgrep_index first", absPath), true
+	}
+	if err := s.checkPath(storeRecord.RootPath); err != nil {
+		return fmt.Sprintf("Error: %v", err), true
+	}
+
+	relPath := file
+	if filepath.IsAbs(file) {
+		relPath, err = filepath.Rel(storeRecord.RootPath, file)
+		if err != nil {
+			return fmt.Sprintf("Error: %s is outside %s", file, storeRecord.RootPath), true
+		}
+	}
+	relPath = filepath.Clean(relPath)
+
+	record, err := s.store.GetFileByExternalID(storeRecord.ID, relPath)
+	if err != nil {
+		return fmt.Sprintf("Error: failed to look up file: %v", err), true
+	}
+	if record == nil {
+		return fmt.Sprintf("Error: %s is not in the index of %s", relPath, storeRecord.RootPath), true
+	}
+
+	content, err := s.readIndexedFile(record.ID, filepath.Join(storeRecord.RootPath, relPath))
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), true
+	}
+
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	start := max(intArg(args, "start_line", 1), 1)
+	end := intArg(args, "end_line", start+maxReadLines-1)
+	if start > len(lines) {
+		return fmt.Sprintf("Error: %s has %d lines", relPath, len(lines)), true
+	}
+	end = min(end, len(lines), start+maxReadLines-1)
+	if end < start {
+		return "Error: end_line is before start_line", true
+	}
+
+	var sb strings.Builder
+	width := len(strconv.Itoa(end))
+	last := start - 1
+	for i := start; i <= end; i++ {
+		line := fmt.Sprintf("%*d  %s\n", width, i, lines[i-1])
+		if sb.Len()+len(line) > maxReadBytes && i > start {
+			break
+		}
+		sb.WriteString(line)
+		last = i
+	}
+
+	header := fmt.Sprintf("%s (lines %d-%d of %d)\n\n", relPath, start, last, len(lines))
+	if last < len(lines) {
+		return header + sb.String() + fmt.Sprintf("\n[Continue with start_line %d]", last+1), false
+	}
+	return header + sb.String(), false
+}
+
+// readIndexedFile returns the content of an indexed file from disk, or from
+// the index if the file is no longer on disk.
+func (s *Server) readIndexedFile(fileID int64, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return string(data), nil
+	}
+	if stored, serr := s.store.GetFileContent(fileID); serr == nil && stored != "" {
+		return stored, nil
+	}
+	return "", fmt.Errorf("failed to read file: %w", err)
+}
+
+// intArg returns a numeric tool argument, which clients send as a number or
+// a string, or def if it is missing or invalid.
+func intArg(args map[string]any, name string, def int) int {
+	switch v := args[name].(type) {
+	case float64:
+		return int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
@@ -8,13 +8,18 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strconv"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/charmbracelet/log"
 
 	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/cost"
 	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/health"
 	"github.com/nickcecere/lgrep/internal/indexer"
 	"github.com/nickcecere/lgrep/internal/search"
 	"github.com/nickcecere/lgrep/internal/store"
@@ -37,27 +42,53 @@ type Server struct {
 	embedder embeddings.Service
 	searcher *search.Searcher
 	indexer  *indexer.Indexer
-	cfg      *config.Config
+	health   *health.Checker
+	cfg      atomic.Pointer[config.Config]
 
-	// Stdin/stdout for communication
-	reader *bufio.Reader
-	writer io.Writer
+	// Stdin/stdout for communication. Writes are serialized because
+	// notifications are sent from other goroutines.
+	reader  *bufio.Reader
+	writer  io.Writer
+	writeMu sync.Mutex
+
+	// Directories tools may access; see checkPath
+	launchDir    string
+	allowAnyPath bool
 
 	// State
-	initialized bool
+	initialized atomic.Bool
+	client      ClientInfo
+
+	// Serializes writes to the audit log
+	auditMu sync.Mutex
+
+	// Index updates waiting to be sent, by store
+	notifyMu sync.Mutex
+	pending  map[string]*IndexUpdatedParams
 }
 
 // NewServer creates a new MCP server.
 func NewServer(st store.Store, emb embeddings.Service, cfg *config.Config) *Server {
-	return &Server{
-		store:    st,
-		embedder: emb,
-		searcher: search.New(st, emb),
-		indexer:  indexer.New(st, emb, cfg),
-		cfg:      cfg,
-		reader:   bufio.NewReader(os.Stdin),
-		writer:   os.Stdout,
+	launchDir, _ := os.Getwd()
+	s := &Server{
+		store:     st,
+		embedder:  emb,
+		searcher:  search.New(st, emb),
+		indexer:   indexer.New(st, emb, cfg),
+		health:    health.NewChecker(st, emb),
+		launchDir: launchDir,
+		reader:    bufio.NewReader(os.Stdin),
+		writer:    os.Stdout,
 	}
+	s.cfg.Store(cfg)
+	return s
+}
+
+// SetConfig replaces the configuration, e.g. after the config file was
+// reloaded. Requests already being handled finish with the old settings.
+func (s *Server) SetConfig(cfg *config.Config) {
+	s.cfg.Store(cfg)
+	s.indexer.SetConfig(cfg)
 }
 
 // Run starts the MCP server and processes requests until the context is cancelled.
@@ -108,18 +139,22 @@ func (s *Server) handleRequest(ctx context.Context, req Request) {
 
 	switch req.Method {
 	case "initialize":
-		result, err = s.handleInitialize(req.Params)
-	case "initialized":
+		result, err = s.handleInitialize(ctx, req.Params)
+	case "initialized", "notifications/initialized":
 		// This is a notification, no response needed
-		s.initialized = true
+		s.initialized.Store(true)
 		log.Info("MCP server initialized")
 		return
 	case "tools/list":
 		result, err = s.handleListTools()
 	case "tools/call":
 		result, err = s.handleCallTool(ctx, req.Params)
+	case "prompts/list":
+		result, err = s.handleListPrompts()
+	case "prompts/get":
+		result, err = s.handleGetPrompt(req.Params)
 	case "ping":
-		result = map[string]any{}
+		result = &PingResult{Health: s.health.Current(ctx)}
 	default:
 		s.sendError(req.ID, ErrorCodeMethodNotFound, "Method not found", req.Method)
 		return
@@ -133,8 +168,11 @@ func (s *Server) handleRequest(ctx context.Context, req Request) {
 	s.sendResult(req.ID, result)
 }
 
-// handleInitialize handles the initialize request.
-func (s *Server) handleInitialize(params json.RawMessage) (*InitializeResult, error) {
+// handleInitialize handles the initialize request. The database and the
+// embedding provider are checked without delaying the response; if either
+// is unusable the server keeps running, in a degraded mode that the client
+// is told about when it is already known and that ping reports.
+func (s *Server) handleInitialize(ctx context.Context, params json.RawMessage) (*InitializeResult, error) {
 	var p InitializeParams
 	if params != nil {
 		if err := json.Unmarshal(params, &p); err != nil {
@@ -142,22 +180,48 @@ func (s *Server) handleInitialize(params json.RawMessage) (*InitializeResult, er
 		}
 	}
 
+	s.client = p.ClientInfo
+
 	log.Info("Initializing MCP server",
 		"clientName", p.ClientInfo.Name,
 		"clientVersion", p.ClientInfo.Version,
 		"protocolVersion", p.ProtocolVersion,
 	)
 
-	return &InitializeResult{
+	result := &InitializeResult{
 		ProtocolVersion: MCPVersion,
 		Capabilities: ServerCapabilities{
-			Tools: &ToolsCapability{},
+			Tools:   &ToolsCapability{},
+			Prompts: &PromptsCapability{},
+			Experimental: map[string]any{
+				NotificationIndexUpdated: map[string]any{},
+			},
 		},
 		ServerInfo: ServerInfo{
 			Name:    ServerName,
 			Version: ServerVersion,
 		},
-	}, nil
+	}
+
+	// The provider is checked in the background so a slow or unreachable
+	// one does not hold up the handshake; ping reports the outcome
+	s.health.Refresh(ctx, func(report *health.Report) {
+		if !report.Healthy() {
+			log.Warn("MCP server running in degraded mode", "problems", strings.Join(report.Problems(), "; "))
+			return
+		}
+		log.Info("Health check passed",
+			"provider", report.Provider.Name,
+			"latency_ms", report.Provider.LatencyMS,
+			"stores", report.Stores,
+			"chunks", report.Chunks,
+		)
+	})
+	if report := s.health.Current(ctx); report.Status == health.StatusDegraded {
+		result.Instructions = fmt.Sprintf("lgrep is running in degraded mode (%s). Searching and indexing will fail until this is fixed; ping reports the current status.",
+			strings.Join(report.Problems(), "; "))
+	}
+	return result, nil
 }
 
 // handleListTools returns the list of available tools.
@@ -171,11 +235,11 @@ func (s *Server) handleListTools() (*ListToolsResult, error) {
 				Properties: map[string]Property{
 					"query": {
 						Type:        "string",
-						Description: "The search query in natural language",
+						Description: "The search query in natural language. Prefix words with - to exclude results containing them (e.g. \"token validation -test\")",
 					},
 					"path": {
 						Type:        "string",
-						Description: "Directory path to search in (default: current directory)",
+						Description: "Directory path to search in (default: current directory). A directory inside an indexed project only returns results from under it",
 						Default:     ".",
 					},
 					"limit": {
@@ -183,6 +247,26 @@ func (s *Server) handleListTools() (*ListToolsResult, error) {
 						Description: "Maximum number of results to return",
 						Default:     10,
 					},
+					"grep": {
+						Type:        "string",
+						Description: "Regular expression (RE2 syntax) that a result's content must match; results that do not match are dropped",
+					},
+					"prefilter": {
+						Type:        "string",
+						Description: "Regular expression (RE2 syntax, without \\Q...\\E) that a line of a file must match for its code to be searched, e.g. a rare identifier; narrows large stores before ranking",
+					},
+					"per_file": {
+						Type:        "number",
+						Description: "Maximum results from any one file (default: no limit)",
+					},
+					"refresh_hits": {
+						Type:        "boolean",
+						Description: "Re-index result files changed since indexing and search again (default: search.refresh_hits)",
+					},
+					"include_generated": {
+						Type:        "boolean",
+						Description: "Include results from vendored and generated files (default: search.include_generated)",
+					},
 				},
 				Required: []string{"query"},
 			},
@@ -201,6 +285,34 @@ func (s *Server) handleListTools() (*ListToolsResult, error) {
 				},
 			},
 		},
+		{
+			Name:        "lgrep_read",
+			Description: fmt.Sprintf("Read lines of an indexed file, e.g. to see the code around a search result. Returns at most %d lines per call.", maxReadLines),
+			InputSchema: JSONSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"file": {
+						Type:        "string",
+						Description: "File path as shown in search results, relative to the indexed directory",
+					},
+					"path": {
+						Type:        "string",
+						Description: "Indexed directory the file belongs to (default: current directory)",
+						Default:     ".",
+					},
+					"start_line": {
+						Type:        "number",
+						Description: "First line to return (1-based)",
+						Default:     1,
+					},
+					"end_line": {
+						Type:        "number",
+						Description: fmt.Sprintf("Last line to return (default: start_line + %d)", maxReadLines-1),
+					},
+				},
+				Required: []string{"file"},
+			},
+		},
 	}
 
 	return &ListToolsResult{Tools: tools}, nil
@@ -215,6 +327,7 @@ func (s *Server) handleCallTool(ctx context.Context, params json.RawMessage) (*C
 
 	log.Debug("Calling tool", "name", p.Name, "arguments", p.Arguments)
 
+	start := time.Now()
 	var resultText string
 	var isError bool
 
@@ -223,12 +336,12 @@ func (s *Server) handleCallTool(ctx context.Context, params json.RawMessage) (*C
 		resultText, isError = s.toolSearch(ctx, p.Arguments)
 	case "lgrep_index":
 		resultText, isError = s.toolIndex(ctx, p.Arguments)
+	case "lgrep_read":
+		resultText, isError = s.toolRead(p.Arguments)
 	default:
-		return &CallToolResult{
-			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Unknown tool: %s", p.Name)}},
-			IsError: true,
-		}, nil
+		resultText, isError = fmt.Sprintf("Unknown tool: %s", p.Name), true
 	}
+	s.audit(p, resultText, isError, time.Since(start))
 
 	return &CallToolResult{
 		Content: []ContentBlock{{Type: "text", Text: resultText}},
@@ -239,6 +352,7 @@ func (s *Server) handleCallTool(ctx context.Context, params json.RawMessage) (*C
 // toolSearch performs a semantic search.
 func (s *Server) toolSearch(ctx context.Context, args map[string]any) (string, bool) {
 	query, _ := args["query"].(string)
+	query, excludeTerms := search.ParseQuery(query)
 	if query == "" {
 		return "Error: query is required", true
 	}
@@ -248,12 +362,22 @@ func (s *Server) toolSearch(ctx context.Context, args map[string]any) (string, b
 		path = p
 	}
 
-	limit := 10
-	if l, ok := args["limit"].(float64); ok {
-		limit = int(l)
-	} else if l, ok := args["limit"].(string); ok {
-		if parsed, err := strconv.Atoi(l); err == nil {
-			limit = parsed
+	cfg := s.cfg.Load()
+
+	limit := intArg(args, "limit", 10)
+
+	var grep *regexp.Regexp
+	if pattern, ok := args["grep"].(string); ok && pattern != "" {
+		var err error
+		if grep, err = regexp.Compile(pattern); err != nil {
+			return fmt.Sprintf("Error: invalid grep pattern: %v", err), true
+		}
+	}
+	var prefilter *regexp.Regexp
+	if pattern, ok := args["prefilter"].(string); ok && pattern != "" {
+		var err error
+		if prefilter, err = search.CompilePrefilter(pattern); err != nil {
+			return fmt.Sprintf("Error: invalid prefilter pattern: %v", err), true
 		}
 	}
 
@@ -263,33 +387,107 @@ func (s *Server) toolSearch(ctx context.Context, args map[string]any) (string, b
 		return fmt.Sprintf("Error: failed to resolve path: %v", err), true
 	}
 
+	if err := s.checkPath(absPath); err != nil {
+		return fmt.Sprintf("Error: %v", err), true
+	}
+
 	// Determine store name
-	storeName := filepath.Base(absPath)
+	storeName := indexer.StoreName(cfg, absPath)
 
-	// Check if store exists, auto-index if not
-	storeRecord, _ := s.store.GetStore(storeName)
+	// A directory inside an indexed project searches the project's store,
+	// restricted to the directory. Otherwise check if the store exists, and
+	// auto-index if not
+	pathPrefix := ""
+	storeRecord, _ := s.searcher.GetStoreForPath(absPath)
+	if storeRecord != nil {
+		pathPrefix = search.PathPrefixFor(storeRecord.RootPath, absPath)
+		if (pathPrefix == "" && storeRecord.RootPath != absPath) || s.checkPath(storeRecord.RootPath) != nil {
+			storeRecord, pathPrefix = nil, ""
+		}
+	}
 	if storeRecord == nil {
-		// Auto-index
+		storeRecord, _ = s.store.GetStore(storeName)
+	}
+	if storeRecord != nil {
+		// The store may have been found through an alias, or belong to
+		// another directory with the same name
+		if err := s.checkPath(storeRecord.RootPath); err != nil {
+			return fmt.Sprintf("Error: %v", err), true
+		}
+		storeName = storeRecord.Name
+	} else {
+		// The partitions of a monorepo are searched one at a time
+		if partitions, _ := indexer.Partitions(cfg, absPath); len(partitions) > 0 {
+			names := make([]string, len(partitions))
+			for i, p := range partitions {
+				names[i] = p.Path
+			}
+			return fmt.Sprintf("Error: %s is split into one store per partition; search one of %s", absPath, strings.Join(names, ", ")), true
+		}
+
+		// Auto-index as search.auto_index allows. There is no one to ask, so
+		// prompt mode indexes directories within the size limits.
+		mode := cfg.Search.AutoIndex
+		if err := config.ValidateAutoIndex(mode); err != nil {
+			return fmt.Sprintf("Error: search.auto_index: %v", err), true
+		}
+		if mode == config.AutoIndexNever {
+			return fmt.Sprintf("Error: %s is not indexed. Call lgrep_index or run 'lgrep index %s' first", absPath, absPath), true
+		}
+		if mode == config.AutoIndexPrompt {
+			scan, err := indexer.Scan(cfg, absPath, nil, nil)
+			if err != nil {
+				return fmt.Sprintf("Error: failed to scan: %v", err), true
+			}
+			if err := indexer.CheckAutoIndexLimits(cfg, absPath, scan); err != nil {
+				return fmt.Sprintf("Error: %v. Call lgrep_index or run 'lgrep index %s' to index it explicitly", err, absPath), true
+			}
+		}
+
 		opts := indexer.IndexOptions{
-			StoreName: storeName,
-			Path:      absPath,
-			Force:     false,
-			BatchSize: 50,
+			StoreName:  storeName,
+			Path:       absPath,
+			Force:      false,
+			BatchSize:  50,
+			LockPolicy: indexer.LockDelegate,
+			Trigger:    store.TriggerMCP,
 		}
-		if err := s.indexer.Index(ctx, opts); err != nil {
+		err = s.indexer.Index(ctx, opts)
+		s.recordUsage(storeName, cost.OpIndex)
+		if err != nil {
 			return fmt.Sprintf("Error: failed to index: %v", err), true
 		}
 	}
 
+	includeGenerated := cfg.Search.IncludeGenerated
+	if b, ok := args["include_generated"].(bool); ok {
+		includeGenerated = b
+	}
+
 	// Perform search
 	opts := search.SearchOptions{
-		StoreName:      storeName,
-		TopK:           limit,
-		MinScore:       0.0,
-		IncludeContent: true,
+		StoreName:        storeName,
+		TopK:             limit,
+		MinScore:         0.0,
+		MinRelevance:     cfg.Search.MinRelevance,
+		Grep:             grep,
+		Prefilter:        prefilter,
+		PathPrefix:       pathPrefix,
+		PerFile:          max(intArg(args, "per_file", 0), 0),
+		IncludeContent:   true,
+		ExcludeTerms:     excludeTerms,
+		ExcludeGenerated: !includeGenerated,
+		Oversample:       cfg.Search.Oversample,
+		RelatedBoost:     cfg.Search.RelatedBoost,
+		LanguageBoost:    cfg.Search.LanguageBoost,
+		RecencyHalfLife:  cfg.Search.RecencyHalfLife,
+		RecencyWeight:    cfg.Search.RecencyWeight,
+		PathBoost:        cfg.Search.Boost,
+		FeedbackWeight:   cfg.Search.FeedbackWeight,
 	}
 
 	results, err := s.searcher.Search(ctx, query, opts)
+	s.recordUsage(storeName, cost.OpSearch)
 	if err != nil {
 		return fmt.Sprintf("Error: search failed: %v", err), true
 	}
@@ -298,18 +496,54 @@ func (s *Server) toolSearch(ctx context.Context, args map[string]any) (string, b
 		return "No results found.", false
 	}
 
+	// A store indexed by this call is current
+	stale := 0
+	if storeRecord != nil {
+		stale = s.searcher.MarkStale(storeRecord, results)
+	}
+
+	refreshHits := cfg.Search.RefreshHits
+	if b, ok := args["refresh_hits"].(bool); ok {
+		refreshHits = b
+	}
+	if stale > 0 && refreshHits {
+		// Bring the changed files up to date and search once more
+		_, err := s.indexer.RefreshFiles(ctx, storeRecord, search.StalePaths(results))
+		s.recordUsage(storeName, cost.OpIndex)
+		if err != nil {
+			return fmt.Sprintf("Error: failed to refresh results: %v", err), true
+		}
+		results, err = s.searcher.Search(ctx, query, opts)
+		s.recordUsage(storeName, cost.OpSearch)
+		if err != nil {
+			return fmt.Sprintf("Error: search failed: %v", err), true
+		}
+		if len(results) == 0 {
+			return "No results found.", false
+		}
+		stale = s.searcher.MarkStale(storeRecord, results)
+	}
+
 	// Format results
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Found %d results:\n\n", len(results)))
+	if stale > 0 {
+		sb.WriteString(fmt.Sprintf("%d results are from files changed since indexing; their content and line numbers may be out of date. Search with refresh_hits or call lgrep_index to update them.\n\n", stale))
+	}
 
 	for i, r := range results {
-		sb.WriteString(fmt.Sprintf("[%d] %s (lines %d-%d) - %.1f%% match\n",
-			i+1, r.RelativePath, r.StartLine, r.EndLine, r.Score*100))
+		staleNote := ""
+		if r.Stale {
+			staleNote = " [stale]"
+		}
+		sb.WriteString(fmt.Sprintf("[%d] %s (lines %d-%d) - relevance %.0f/100%s\n",
+			i+1, r.RelativePath, r.StartLine, r.EndLine, r.Relevance, staleNote))
 		if r.Content != "" {
 			// Truncate content if too long
 			content := r.Content
 			if len(content) > 500 {
-				content = content[:500] + "..."
+				// Drop the bytes of a character split by the cut
+				content = strings.ToValidUTF8(content[:500], "") + "..."
 			}
 			sb.WriteString(content)
 			sb.WriteString("\n\n")
@@ -331,31 +565,63 @@ func (s *Server) toolIndex(ctx context.Context, args map[string]any) (string, bo
 	if err != nil {
 		return fmt.Sprintf("Error: failed to resolve path: %v", err), true
 	}
-
-	storeName := filepath.Base(absPath)
-
-	opts := indexer.IndexOptions{
-		StoreName: storeName,
-		Path:      absPath,
-		Force:     false,
-		BatchSize: 50,
+	if err := s.checkPath(absPath); err != nil {
+		return fmt.Sprintf("Error: %v", err), true
 	}
 
-	if err := s.indexer.Index(ctx, opts); err != nil {
-		return fmt.Sprintf("Error: indexing failed: %v", err), true
+	// The root of a partitioned monorepo is indexed into one store per
+	// partition
+	cfg := s.cfg.Load()
+	targets, err := indexer.Partitions(cfg, absPath)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), true
+	}
+	if len(targets) == 0 {
+		targets = []indexer.Partition{{Store: indexer.StoreName(cfg, absPath), Path: absPath}}
+	} else {
+		indexer.LogRootFiles(cfg, absPath)
 	}
 
-	// Get stats
-	storeRecord, _ := s.store.GetStore(storeName)
-	if storeRecord != nil {
-		stats, _ := s.store.GetStats(storeRecord.ID)
-		if stats != nil {
-			return fmt.Sprintf("Successfully indexed %s: %d files, %d chunks",
-				absPath, stats.FileCount, stats.ChunkCount), false
+	var summaries []string
+	for _, target := range targets {
+		storeName := target.Store
+		if existing, _ := s.store.GetStore(storeName); existing != nil {
+			// Follow aliases of a renamed store
+			storeName = existing.Name
 		}
+
+		opts := indexer.IndexOptions{
+			StoreName:  storeName,
+			Path:       target.Path,
+			Force:      false,
+			BatchSize:  50,
+			LockPolicy: indexer.LockDelegate,
+			Trigger:    store.TriggerMCP,
+		}
+
+		err = s.indexer.Index(ctx, opts)
+		s.recordUsage(storeName, cost.OpIndex)
+		if err != nil {
+			return fmt.Sprintf("Error: indexing %s failed: %v", target.Path, err), true
+		}
+
+		// Get stats
+		summary := fmt.Sprintf("Successfully indexed %s", target.Path)
+		storeRecord, _ := s.store.GetStore(storeName)
+		if storeRecord != nil {
+			stats, _ := s.store.GetStats(storeRecord.ID)
+			if stats != nil {
+				summary = fmt.Sprintf("Successfully indexed %s: %d files, %d chunks",
+					target.Path, stats.FileCount, stats.ChunkCount)
+			}
+		}
+		if len(targets) > 1 {
+			summary += fmt.Sprintf(" (store %s)", storeName)
+		}
+		summaries = append(summaries, summary)
 	}
 
-	return fmt.Sprintf("Successfully indexed %s", absPath), false
+	return strings.Join(summaries, "\n"), false
 }
 
 // sendResult sends a successful response.
@@ -389,5 +655,15 @@ func (s *Server) send(v any) {
 		log.Error("Failed to marshal response", "error", err)
 		return
 	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 	fmt.Fprintln(s.writer, string(data))
 }
+
+// recordUsage records cloud embedding usage since the last call when the
+// server's embedder is metered.
+func (s *Server) recordUsage(storeName, operation string) {
+	if e, ok := s.embedder.(*cost.Embedder); ok {
+		e.Flush(s.store, storeName, operation)
+	}
+}
@@ -0,0 +1,53 @@
+// Package models lists the models available from embedding and LLM providers.
+package models
+
+import "strings"
+
+// Kind describes what a model can be used for.
+type Kind string
+
+const (
+	KindEmbedding Kind = "embedding"
+	KindChat      Kind = "chat"
+	KindUnknown   Kind = "unknown"
+)
+
+// Model describes a model available from a provider.
+type Model struct {
+	Provider      string `json:"provider"`
+	Name          string `json:"name"`
+	Kind          Kind   `json:"kind"`
+	Dimensions    int    `json:"dimensions,omitempty"`     // Embedding dimensions, if known
+	ContextLength int    `json:"context_length,omitempty"` // Context window in tokens, if known
+	Size          int64  `json:"size,omitempty"`           // Size on disk in bytes (Ollama only)
+}
+
+// Find returns the model with the given name, or nil if it is not in the list.
+// Ollama's implicit ":latest" tag is matched in either direction.
+func Find(list []Model, name string) *Model {
+	for i, m := range list {
+		if m.Name == name || stripLatest(m.Name) == stripLatest(name) {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+// stripLatest removes the default ":latest" tag from an Ollama model name.
+func stripLatest(name string) string {
+	return strings.TrimSuffix(name, ":latest")
+}
+
+// guessKind classifies a model by name when the provider does not say.
+func guessKind(name string) Kind {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "embed"), strings.Contains(lower, "minilm"), strings.Contains(lower, "bge-"):
+		return KindEmbedding
+	case strings.HasPrefix(lower, "gpt-"), strings.HasPrefix(lower, "o1"), strings.HasPrefix(lower, "o3"),
+		strings.HasPrefix(lower, "o4"), strings.HasPrefix(lower, "chatgpt"):
+		return KindChat
+	default:
+		return KindUnknown
+	}
+}
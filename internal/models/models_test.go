@@ -0,0 +1,95 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestListOllama tests listing models from the Ollama API.
+func TestListOllama(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models":[{"name":"nomic-embed-text:latest","size":274302450},{"name":"llama3:latest","size":4661224676}]}`))
+		case "/api/show":
+			var req map[string]string
+			if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+				return
+			}
+			if req["model"] == "nomic-embed-text:latest" {
+				w.Write([]byte(`{"capabilities":["embedding"],"model_info":{"nomic-bert.embedding_length":768,"nomic-bert.context_length":2048}}`))
+			} else {
+				w.Write([]byte(`{"capabilities":["completion"],"model_info":{"llama.embedding_length":4096,"llama.context_length":8192}}`))
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	list, err := ListOllama(context.Background(), server.Client(), server.URL)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	assert.Equal(t, Model{Provider: "ollama", Name: "nomic-embed-text:latest", Kind: KindEmbedding, Dimensions: 768, ContextLength: 2048, Size: 274302450}, list[0])
+	assert.Equal(t, KindChat, list[1].Kind)
+	assert.Zero(t, list[1].Dimensions)
+	assert.Equal(t, 8192, list[1].ContextLength)
+}
+
+// TestListOllamaUnreachable tests the error when Ollama is not running.
+func TestListOllamaUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	_, err := ListOllama(context.Background(), server.Client(), server.URL)
+	assert.Error(t, err)
+}
+
+// TestFind tests model lookup by name.
+func TestFind(t *testing.T) {
+	list := []Model{{Name: "nomic-embed-text:latest"}, {Name: "llama3:8b"}, {Name: "gpt-4o"}}
+
+	assert.NotNil(t, Find(list, "nomic-embed-text"))
+	assert.NotNil(t, Find(list, "llama3:8b"))
+	assert.NotNil(t, Find(list, "gpt-4o:latest"))
+	assert.Nil(t, Find(list, "llama3"))
+	assert.Nil(t, Find(list, "mxbai-embed-large"))
+}
+
+// TestPullOllama tests streaming pull progress.
+func TestPullOllama(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/pull", r.URL.Path)
+		w.Write([]byte("{\"status\":\"pulling manifest\"}\n{\"status\":\"downloading\",\"digest\":\"sha256:abc\",\"total\":200,\"completed\":100}\n{\"status\":\"success\"}\n"))
+	}))
+	defer server.Close()
+
+	var updates []PullProgress
+	err := PullOllama(context.Background(), server.Client(), server.URL, "llama3", func(p PullProgress) {
+		updates = append(updates, p)
+	})
+	require.NoError(t, err)
+	require.Len(t, updates, 3)
+	assert.Equal(t, int64(100), updates[1].Completed)
+	assert.Equal(t, "success", updates[2].Status)
+}
+
+// TestPullOllamaError tests errors reported in the pull stream.
+func TestPullOllamaError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{\"status\":\"pulling manifest\"}\n{\"error\":\"pull model manifest: file does not exist\"}\n"))
+	}))
+	defer server.Close()
+
+	err := PullOllama(context.Background(), server.Client(), server.URL, "no-such-model", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "file does not exist")
+}
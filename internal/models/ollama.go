@@ -0,0 +1,127 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// ollamaTagsResponse is the response from the Ollama tags API.
+type ollamaTagsResponse struct {
+	Models []struct {
+		Name string `json:"name"`
+		Size int64  `json:"size"`
+	} `json:"models"`
+}
+
+// ollamaShowResponse is the subset of the Ollama show API used here.
+type ollamaShowResponse struct {
+	Capabilities []string       `json:"capabilities"`
+	ModelInfo    map[string]any `json:"model_info"`
+}
+
+// ListOllama returns the models installed in an Ollama server, requested
+// with client.
+func ListOllama(ctx context.Context, client *http.Client, baseURL string) ([]Model, error) {
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	var tags ollamaTagsResponse
+	if err := ollamaRequest(ctx, client, "GET", baseURL+"/api/tags", nil, &tags); err != nil {
+		return nil, err
+	}
+
+	list := make([]Model, 0, len(tags.Models))
+	for _, t := range tags.Models {
+		m := Model{
+			Provider: "ollama",
+			Name:     t.Name,
+			Kind:     guessKind(t.Name),
+			Size:     t.Size,
+		}
+
+		// Details are best effort; older servers may not report them
+		var show ollamaShowResponse
+		body := map[string]string{"model": t.Name}
+		if err := ollamaRequest(ctx, client, "POST", baseURL+"/api/show", body, &show); err != nil {
+			log.Debug("Failed to get model details", "model", t.Name, "error", err)
+		} else {
+			applyOllamaDetails(&m, show)
+		}
+
+		list = append(list, m)
+	}
+
+	return list, nil
+}
+
+// applyOllamaDetails fills in kind, dimensions and context length from the show API.
+func applyOllamaDetails(m *Model, show ollamaShowResponse) {
+	for _, c := range show.Capabilities {
+		switch c {
+		case "embedding":
+			m.Kind = KindEmbedding
+		case "completion":
+			m.Kind = KindChat
+		}
+	}
+
+	// model_info keys are prefixed with the architecture, e.g. "llama.context_length"
+	for key, value := range show.ModelInfo {
+		n, ok := value.(float64)
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(key, ".context_length"):
+			m.ContextLength = int(n)
+		case strings.HasSuffix(key, ".embedding_length") && m.Kind == KindEmbedding:
+			m.Dimensions = int(n)
+		}
+	}
+}
+
+// ollamaRequest sends a request to the Ollama API and decodes the JSON response.
+func ollamaRequest(ctx context.Context, client *http.Client, method, url string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// ListOpenAI returns the models available to an OpenAI API key, requested
+// with client. Works with OpenAI-compatible servers when baseURL is set.
+func ListOpenAI(ctx context.Context, client *http.Client, apiKey, baseURL string) ([]Model, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("OpenAI API key is required")
+	}
+
+	opts := []option.RequestOption{
+		option.WithAPIKey(apiKey),
+		option.WithHTTPClient(client),
+	}
+	if baseURL != "" {
+		opts = append(opts, option.WithBaseURL(baseURL))
+	}
+	api := openai.NewClient(opts...)
+
+	var list []Model
+	iter := api.Models.ListAutoPaging(ctx)
+	for iter.Next() {
+		m := iter.Current()
+		list = append(list, Model{
+			Provider: "openai",
+			Name:     m.ID,
+			Kind:     guessKind(m.ID),
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list OpenAI models: %w", err)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list, nil
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// PullProgress is a progress update streamed by the Ollama pull API.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ErrMissingModel is matched by the errors MissingModelError returns.
+var ErrMissingModel = errors.New("model not available")
+
+// MissingModelError returns the error reported when Ollama does not have a
+// model. configKey is the setting that enables automatic pulls.
+func MissingModelError(model, configKey string) error {
+	return &missingModelError{model: model, configKey: configKey}
+}
+
+// missingModelError reports a model Ollama does not have.
+type missingModelError struct {
+	model, configKey string
+}
+
+func (e *missingModelError) Error() string {
+	return fmt.Sprintf("model %q is not available in Ollama: run 'ollama pull %s' or set %s: true", e.model, e.model, e.configKey)
+}
+
+func (e *missingModelError) Unwrap() error {
+	return ErrMissingModel
+}
+
+// HasOllamaModel reports whether an Ollama server has the given model,
+// asking with client.
+func HasOllamaModel(ctx context.Context, client *http.Client, baseURL, model string) (bool, error) {
+	jsonBody, err := json.Marshal(map[string]string{"model": model})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(baseURL, "/")+"/api/show", bytes.NewReader(jsonBody))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
+	}
+}
+
+// PullOllama downloads a model into an Ollama server, calling progress for
+// each update. The request is sent with client, but without its timeout:
+// pulls can take minutes, so cancel ctx to abort.
+func PullOllama(ctx context.Context, client *http.Client, baseURL, model string, progress func(PullProgress)) error {
+	jsonBody, err := json.Marshal(map[string]any{"model": model, "stream": true})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(baseURL, "/")+"/api/pull", bytes.NewReader(jsonBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// No client timeout: large models take a while to download
+	untimed := *client
+	untimed.Timeout = 0
+	resp, err := untimed.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to pull %s: ollama returned status %d: %s", model, resp.StatusCode, string(body))
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var p PullProgress
+		if err := decoder.Decode(&p); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to decode pull progress: %w", err)
+		}
+
+		if p.Error != "" {
+			return fmt.Errorf("failed to pull %s: %s", model, p.Error)
+		}
+		if progress != nil {
+			progress(p)
+		}
+		if p.Status == "success" {
+			return nil
+		}
+	}
+}
+
+// EnsureOllamaModel pulls a model into Ollama if it is not already present,
+// printing progress to stderr. Requests are sent with client.
+func EnsureOllamaModel(ctx context.Context, client *http.Client, baseURL, model string) error {
+	ok, err := HasOllamaModel(ctx, client, baseURL, model)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+
+	log.Info("Pulling Ollama model", "model", model)
+	err = PullOllama(ctx, client, baseURL, model, printPullProgress(model))
+	fmt.Fprintln(os.Stderr)
+	return err
+}
+
+// printPullProgress returns a progress callback that redraws a single line on stderr.
+func printPullProgress(model string) func(PullProgress) {
+	return func(p PullProgress) {
+		line := fmt.Sprintf("Pulling %s: %s", model, p.Status)
+		if p.Total > 0 {
+			line += fmt.Sprintf(" %d%%", p.Completed*100/p.Total)
+		}
+		fmt.Fprintf(os.Stderr, "\r\033[K%s", line)
+	}
+}
@@ -0,0 +1,218 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Defaults for FindDuplicates.
+const (
+	DefaultDuplicateScore     = 0.95
+	DefaultDuplicateNeighbors = 5
+	DefaultDuplicateMinLines  = 5
+)
+
+// duplicatePageSize is how many stored vectors are read at a time.
+const duplicatePageSize = 256
+
+// DuplicateOptions configures FindDuplicates.
+type DuplicateOptions struct {
+	// StoreName is the name of the store to scan.
+	StoreName string
+
+	// MinScore is the similarity above which two chunks are considered
+	// duplicates. Zero uses DefaultDuplicateScore.
+	MinScore float64
+
+	// Neighbors is how many nearest chunks are compared with each chunk.
+	// Zero uses DefaultDuplicateNeighbors.
+	Neighbors int
+
+	// MinLines skips chunks shorter than this many lines, which are often
+	// boilerplate such as closing braces or imports. Zero uses
+	// DefaultDuplicateMinLines; a negative value disables the filter.
+	MinLines int
+
+	// OnProgress, if set, is called after each chunk is compared.
+	OnProgress func(done, total int)
+}
+
+// DuplicateGroup is a set of chunks that are near-identical to each other.
+type DuplicateGroup struct {
+	Chunks []Result `json:"chunks"`
+
+	// Score is the lowest similarity of the links that joined the group.
+	Score float64 `json:"score"`
+}
+
+// FindDuplicates reports groups of near-identical chunks in a store. Each
+// stored vector is searched for its nearest neighbours, and chunks whose
+// similarity is at least MinScore are grouped together. Groups are sorted
+// by size and then by score, largest and most similar first.
+func (s *Searcher) FindDuplicates(ctx context.Context, opts DuplicateOptions) ([]DuplicateGroup, error) {
+	if opts.MinScore == 0 {
+		opts.MinScore = DefaultDuplicateScore
+	}
+	if opts.Neighbors <= 0 {
+		opts.Neighbors = DefaultDuplicateNeighbors
+	}
+	if opts.MinLines == 0 {
+		opts.MinLines = DefaultDuplicateMinLines
+	}
+
+	storeRecord, err := s.store.GetStore(opts.StoreName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+	if storeRecord == nil {
+		return nil, fmt.Errorf("store not found: %s", opts.StoreName)
+	}
+	stats, err := s.store.GetStats(storeRecord.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats: %w", err)
+	}
+
+	searchOpts := SearchOptions{
+		StoreName:      opts.StoreName,
+		TopK:           opts.Neighbors + 1, // The chunk itself is always the nearest
+		MinScore:       opts.MinScore,
+		IncludeContent: true,
+	}
+
+	groups := newUnionFind()
+	chunks := make(map[int64]Result)
+	done := 0
+	var afterID int64
+	for {
+		page, err := s.store.ListChunkVectors(storeRecord.ID, afterID, duplicatePageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list vectors: %w", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		afterID = page[len(page)-1].ChunkID
+
+		for _, v := range page {
+			neighbors, err := s.SearchByVector(ctx, v.Embedding, searchOpts)
+			if err != nil {
+				return nil, err
+			}
+			// Identical copies can outrank the chunk itself, in which case
+			// it is linked when one of them is compared instead
+			self, ok := findResult(neighbors, v.ChunkID)
+			if ok && lineCount(self) >= opts.MinLines {
+				for _, n := range neighbors {
+					if n.ChunkID == v.ChunkID || lineCount(n) < opts.MinLines {
+						continue
+					}
+					chunks[v.ChunkID] = self
+					chunks[n.ChunkID] = n
+					groups.union(v.ChunkID, n.ChunkID, n.Score)
+				}
+			}
+
+			done++
+			if opts.OnProgress != nil {
+				opts.OnProgress(done, stats.ChunkCount)
+			}
+		}
+	}
+
+	return groups.groups(chunks), nil
+}
+
+// findResult returns the result for chunk id.
+func findResult(results []Result, id int64) (Result, bool) {
+	for _, r := range results {
+		if r.ChunkID == id {
+			return r, true
+		}
+	}
+	return Result{}, false
+}
+
+// lineCount returns the number of lines a result spans.
+func lineCount(r Result) int {
+	return r.EndLine - r.StartLine + 1
+}
+
+// unionFind groups chunk IDs, tracking the weakest link in each group.
+type unionFind struct {
+	parent map[int64]int64
+	score  map[int64]float64 // Lowest link score, by root
+}
+
+func newUnionFind() *unionFind {
+	return &unionFind{parent: make(map[int64]int64), score: make(map[int64]float64)}
+}
+
+func (u *unionFind) find(id int64) int64 {
+	p, ok := u.parent[id]
+	if !ok {
+		u.parent[id] = id
+		return id
+	}
+	if p == id {
+		return id
+	}
+	root := u.find(p)
+	u.parent[id] = root
+	return root
+}
+
+func (u *unionFind) union(a, b int64, score float64) {
+	ra, rb := u.find(a), u.find(b)
+	lowest := score
+	for _, r := range []int64{ra, rb} {
+		if s, ok := u.score[r]; ok && s < lowest {
+			lowest = s
+		}
+	}
+	if ra != rb {
+		u.parent[rb] = ra
+		delete(u.score, rb)
+	}
+	u.score[ra] = lowest
+}
+
+// groups returns the groups with more than one chunk, sorted largest and
+// most similar first. Chunks within a group are sorted by path and line.
+func (u *unionFind) groups(chunks map[int64]Result) []DuplicateGroup {
+	members := make(map[int64][]Result)
+	for id, r := range chunks {
+		root := u.find(id)
+		members[root] = append(members[root], r)
+	}
+
+	var groups []DuplicateGroup
+	for root, rs := range members {
+		if len(rs) < 2 {
+			continue
+		}
+		sort.Slice(rs, func(i, j int) bool {
+			if rs[i].RelativePath != rs[j].RelativePath {
+				return rs[i].RelativePath < rs[j].RelativePath
+			}
+			return rs[i].StartLine < rs[j].StartLine
+		})
+		// Scores are relative to the chunk that found each neighbour, so
+		// they are not meaningful per chunk within a group
+		for i := range rs {
+			rs[i].Score, rs[i].Distance = 0, 0
+		}
+		groups = append(groups, DuplicateGroup{Chunks: rs, Score: u.score[root]})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].Chunks) != len(groups[j].Chunks) {
+			return len(groups[i].Chunks) > len(groups[j].Chunks)
+		}
+		if groups[i].Score != groups[j].Score {
+			return groups[i].Score > groups[j].Score
+		}
+		return groups[i].Chunks[0].RelativePath < groups[j].Chunks[0].RelativePath
+	})
+	return groups
+}
@@ -0,0 +1,74 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/test.db")
+	require.NoError(t, err)
+	defer st.Close()
+
+	storeRecord, err := st.CreateStore("dupes", "/repo", store.ProviderOllama, "test-model", 4)
+	require.NoError(t, err)
+
+	files := []struct {
+		name      string
+		endLine   int
+		embedding []float32
+	}{
+		{"a.go", 10, []float32{1, 0, 0, 0}},
+		{"b.go", 10, []float32{1, 0, 0, 0}},
+		{"c.go", 10, []float32{0.99, 0.1, 0, 0}},
+		{"short.go", 2, []float32{1, 0, 0, 0}}, // Below the line minimum
+		{"other.go", 10, []float32{0, 1, 0, 0}},
+		{"x.go", 10, []float32{0, 0, 1, 0}},
+		{"y.go", 10, []float32{0, 0, 1, 0.01}},
+	}
+	for _, f := range files {
+		err := st.UpsertFile(storeRecord.ID, store.FileInput{
+			ExternalID:   f.name,
+			Path:         "/repo/" + f.name,
+			RelativePath: f.name,
+			Hash:         f.name,
+		}, []store.Chunk{{Content: f.name, StartLine: 1, EndLine: f.endLine}}, [][]float32{f.embedding})
+		require.NoError(t, err)
+	}
+
+	searcher := New(st, &mockEmbedder{model: "test-model", dimensions: 4})
+	var progress int
+	groups, err := searcher.FindDuplicates(context.Background(), DuplicateOptions{
+		StoreName:  "dupes",
+		OnProgress: func(done, total int) { progress = done; assert.Equal(t, len(files), total) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, len(files), progress)
+
+	require.Len(t, groups, 2)
+	paths := func(g DuplicateGroup) []string {
+		var ps []string
+		for _, c := range g.Chunks {
+			ps = append(ps, c.RelativePath)
+		}
+		return ps
+	}
+	assert.Equal(t, []string{"a.go", "b.go", "c.go"}, paths(groups[0]))
+	assert.Less(t, groups[0].Score, 1.0)
+	assert.GreaterOrEqual(t, groups[0].Score, DefaultDuplicateScore)
+	assert.Equal(t, []string{"x.go", "y.go"}, paths(groups[1]))
+
+	// A stricter threshold splits off the near match
+	groups, err = searcher.FindDuplicates(context.Background(), DuplicateOptions{StoreName: "dupes", MinScore: 0.999999})
+	require.NoError(t, err)
+	require.NotEmpty(t, groups)
+	assert.Equal(t, []string{"a.go", "b.go"}, paths(groups[0]))
+
+	_, err = searcher.FindDuplicates(context.Background(), DuplicateOptions{StoreName: "missing"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package search
+
+import (
+	"path"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// feedbackBoost returns the booster that multiplies the score of each
+// candidate by 1 + weight × its feedback prior. The prior, between -1 and
+// 1, comes from the good and bad judgments of the candidate's file and,
+// with half the say, of the other files in its directory, so a file that
+// was never judged still inherits from its neighbours. Weights above 1 are
+// treated as 1 so scores never turn negative. It returns nil for stores
+// without feedback.
+func (s *Searcher) feedbackBoost(storeID int64, weight float64) booster {
+	if weight <= 0 {
+		return nil
+	}
+
+	counts, err := s.store.CountFeedback(storeID)
+	if err != nil {
+		log.Debug("Failed to count feedback", "error", err)
+		return nil
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+
+	weight = min(weight, 1)
+	dirs := make(map[string]store.FeedbackCount)
+	for p, c := range counts {
+		dir := dirs[path.Dir(p)]
+		dir.Good += c.Good
+		dir.Bad += c.Bad
+		dirs[path.Dir(p)] = dir
+	}
+
+	return func(sr store.SearchResult) float64 {
+		relPath := sr.File.RelativePath
+		prior := (2*feedbackPrior(counts[relPath]) + feedbackPrior(dirs[path.Dir(relPath)])) / 3
+		return 1 + weight*prior
+	}
+}
+
+// feedbackPrior returns the balance of good over bad judgments, between -1
+// and 1. One judgment counts for half, so a single click does not decide it.
+func feedbackPrior(c store.FeedbackCount) float64 {
+	return float64(c.Good-c.Bad) / float64(c.Good+c.Bad+1)
+}
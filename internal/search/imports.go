@@ -0,0 +1,117 @@
+package search
+
+import (
+	"regexp"
+
+	"github.com/nickcecere/lgrep/internal/fs"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// identifierPattern matches identifiers in most languages.
+var identifierPattern = regexp.MustCompile(`[A-Za-z_$][\w$]*`)
+
+// ImportedChunks returns chunks from the files that the files of results
+// import which define a symbol the results use, such as the type a function
+// takes, for up to limit chunks. Each is marked with the result file that
+// imports it. Chunks already among results are skipped. Files indexed before
+// imports and symbols were recorded contribute nothing.
+func (s *Searcher) ImportedChunks(storeRecord *store.StoreRecord, results []Result, limit int) ([]Result, error) {
+	if limit <= 0 || len(results) == 0 {
+		return nil, nil
+	}
+
+	// The identifiers each result file uses, in rank order
+	var order []string
+	used := make(map[string]map[string]bool)
+	have := make(map[int64]bool)
+	for _, r := range results {
+		have[r.ChunkID] = true
+		if used[r.RelativePath] == nil {
+			used[r.RelativePath] = make(map[string]bool)
+			order = append(order, r.RelativePath)
+		}
+		for _, id := range identifierPattern.FindAllString(r.Content, -1) {
+			used[r.RelativePath][id] = true
+		}
+	}
+
+	var files []store.FileRecord
+	var resolver *fs.ImportResolver
+	var imported []Result
+	for _, relPath := range order {
+		file, err := s.store.GetFileByExternalID(storeRecord.ID, relPath)
+		if err != nil {
+			return nil, err
+		}
+		if file == nil {
+			continue
+		}
+		imports, err := s.store.GetFileImports(file.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(imports) == 0 {
+			continue
+		}
+		if resolver == nil {
+			err := s.store.WalkFiles(storeRecord.ID, nil, func(f store.FileRecord) error {
+				files = append(files, f)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			resolver = fileResolver(storeRecord.RootPath, files)
+		}
+
+		symbols, err := s.store.GetFileSymbols(resolveImports(imports, files, resolver, file.ID))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range symbols {
+			if !used[relPath][m.Symbol] || have[m.Chunk.ID] {
+				continue
+			}
+			have[m.Chunk.ID] = true
+			imported = append(imported, Result{
+				ChunkID:      m.Chunk.ID,
+				FilePath:     m.File.Path,
+				RelativePath: m.File.RelativePath,
+				Content:      m.Chunk.Content,
+				StartLine:    m.Chunk.StartLine,
+				EndLine:      m.Chunk.EndLine,
+				ImportedBy:   relPath,
+			})
+			if len(imported) >= limit {
+				return imported, nil
+			}
+		}
+	}
+	return imported, nil
+}
+
+// fileResolver returns an ImportResolver for files, the files of the store
+// at root, whose indexes are those of files.
+func fileResolver(root string, files []store.FileRecord) *fs.ImportResolver {
+	relPaths := make([]string, len(files))
+	for i, f := range files {
+		relPaths[i] = f.RelativePath
+	}
+	return fs.NewImportResolver(relPaths, fs.ReadModules(root, relPaths))
+}
+
+// resolveImports returns the IDs of the files that imports refer to, other
+// than the importing file. resolver must be built from files.
+func resolveImports(imports []string, files []store.FileRecord, resolver *fs.ImportResolver, importer int64) []int64 {
+	var ids []int64
+	seen := map[int64]bool{importer: true}
+	for _, target := range imports {
+		for _, i := range resolver.Resolve(target) {
+			if id := files[i].ID; !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
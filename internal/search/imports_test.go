@@ -0,0 +1,70 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+func TestImportedChunks(t *testing.T) {
+	tmpDir := t.TempDir()
+	st, err := store.NewSQLiteStore(filepath.Join(tmpDir, "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	rec, err := st.CreateStore("app", tmpDir, store.ProviderOllama, "test-model", 768)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/app\n"), 0644))
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	upsert := func(relPath string, imports []string, chunks []store.Chunk) {
+		embeddings := make([][]float32, len(chunks))
+		for i, c := range chunks {
+			embeddings[i] = emb.generateEmbedding(c.Content)
+		}
+		err := st.UpsertFile(rec.ID, store.FileInput{
+			ExternalID:   relPath,
+			Path:         filepath.Join(tmpDir, relPath),
+			RelativePath: relPath,
+			Hash:         relPath,
+			Imports:      imports,
+		}, chunks, embeddings)
+		require.NoError(t, err)
+	}
+
+	upsert("cmd/main.go", []string{"fmt", "example.com/app/internal/store"}, []store.Chunk{
+		{Content: "func main() {\n\tidx := store.Open()\n\tfmt.Println(idx)\n}", StartLine: 5, EndLine: 8, Symbols: []string{"main"}},
+	})
+	upsert("internal/store/store.go", nil, []store.Chunk{
+		{Content: "func Open() *Index {\n\treturn &Index{}\n}", StartLine: 1, EndLine: 3, Symbols: []string{"Open"}},
+		{Content: "func Close() {}", StartLine: 5, EndLine: 5, ChunkIndex: 1, Symbols: []string{"Close"}},
+	})
+	upsert("internal/search/search.go", nil, []store.Chunk{
+		{Content: "func Open() {}", StartLine: 1, EndLine: 1, Symbols: []string{"Open"}},
+	})
+
+	searcher := New(st, emb)
+	results := []Result{{
+		ChunkID:      1,
+		RelativePath: "cmd/main.go",
+		Content:      "func main() {\n\tidx := store.Open()\n\tfmt.Println(idx)\n}",
+	}}
+
+	// Only the definitions used, from the files actually imported
+	imported, err := searcher.ImportedChunks(rec, results, 5)
+	require.NoError(t, err)
+	require.Len(t, imported, 1)
+	assert.Equal(t, "internal/store/store.go", imported[0].RelativePath)
+	assert.Equal(t, 1, imported[0].StartLine)
+	assert.Equal(t, "cmd/main.go", imported[0].ImportedBy)
+	assert.Contains(t, imported[0].Content, "func Open()")
+
+	imported, err = searcher.ImportedChunks(rec, results, 0)
+	require.NoError(t, err)
+	assert.Empty(t, imported)
+}
@@ -0,0 +1,152 @@
+package search
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nickcecere/lgrep/internal/fs"
+)
+
+// stopWords are left out of keyword searches, since nearly every file
+// contains them.
+var stopWords = map[string]bool{
+	"and": true, "are": true, "can": true, "does": true, "for": true,
+	"from": true, "how": true, "the": true, "this": true, "that": true,
+	"what": true, "when": true, "where": true, "which": true, "who": true,
+	"why": true, "with": true, "into": true, "there": true, "its": true,
+	"work": true, "works": true, "use": true, "used": true, "code": true,
+}
+
+// Keywords returns the lowercased words of query that a keyword search looks
+// for: words of three letters or more that are not stop words, or every
+// word if that leaves none.
+func Keywords(query string) []string {
+	var all, keywords []string
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		word = strings.Trim(word, `.,;:!?"'()[]{}`)
+		if word == "" || seen[word] {
+			continue
+		}
+		seen[word] = true
+		all = append(all, word)
+		if len(word) >= 3 && !stopWords[word] {
+			keywords = append(keywords, word)
+		}
+	}
+	if len(keywords) == 0 {
+		return all
+	}
+	return keywords
+}
+
+// KeywordSearch scans files for the keywords of query, for when the query
+// cannot be embedded. Each line containing a keyword is a result, scored by
+// the share of the keywords it contains, so lines with more of them rank
+// higher. TopK, PerFile, Grep, ExcludeTerms, ExcludeGenerated,
+// IncludeContent and the context lines of opts apply as they do to Search.
+func KeywordSearch(ctx context.Context, files []fs.FileInfo, query string, opts SearchOptions) ([]Result, error) {
+	keywords := Keywords(query)
+	if len(keywords) == 0 {
+		return nil, nil
+	}
+
+	topK := opts.TopK
+	if topK <= 0 {
+		topK = 10
+	}
+
+	var results []Result
+	for _, fi := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if fi.Binary {
+			continue
+		}
+		content, err := os.ReadFile(fi.Path)
+		if err != nil || fs.IsBinary(content) {
+			continue
+		}
+		if opts.ExcludeGenerated && fs.IsGenerated(fi.RelPath, content) {
+			continue
+		}
+		results = append(results, keywordMatches(fi, string(content), keywords, opts)...)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	var kept []Result
+	perFile := make(map[string]int)
+	for _, r := range results {
+		if len(kept) >= topK {
+			break
+		}
+		if opts.PerFile > 0 && perFile[r.RelativePath] >= opts.PerFile {
+			continue
+		}
+		perFile[r.RelativePath]++
+		kept = append(kept, r)
+	}
+	return kept, nil
+}
+
+// keywordMatches returns the lines of a file that contain any of keywords
+// and pass the filters of opts.
+func keywordMatches(fi fs.FileInfo, content string, keywords []string, opts SearchOptions) []Result {
+	lines := strings.Split(content, "\n")
+	path := strings.ToLower(fi.RelPath)
+
+	var results []Result
+	for i, line := range lines {
+		lower := strings.ToLower(line)
+		matched := 0
+		for _, k := range keywords {
+			if strings.Contains(lower, k) {
+				matched++
+			}
+		}
+		if matched == 0 || (opts.Grep != nil && !opts.Grep.MatchString(line)) {
+			continue
+		}
+		if keywordExcluded(lower, path, opts.ExcludeTerms) {
+			continue
+		}
+
+		score := float64(matched) / float64(len(keywords))
+		result := Result{
+			FilePath:     fi.Path,
+			RelativePath: fi.RelPath,
+			StartLine:    i + 1,
+			EndLine:      i + 1,
+			Score:        score,
+			Distance:     1 - score,
+			Relevance:    100 * score,
+		}
+		if opts.IncludeContent {
+			result.Content = line
+		}
+		if opts.wantsContext() {
+			before, after := opts.contextLines()
+			result.ContextBefore, result.ContextAfter = linesAround(content, i+1, i+1, before, after)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// keywordExcluded reports whether a lowercased line or path contains any of
+// the exclusion terms.
+func keywordExcluded(line, path string, terms []string) bool {
+	for _, term := range terms {
+		term = strings.ToLower(term)
+		if term != "" && (strings.Contains(line, term) || strings.Contains(path, term)) {
+			return true
+		}
+	}
+	return false
+}
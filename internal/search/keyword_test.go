@@ -0,0 +1,66 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/fs"
+)
+
+func TestKeywords(t *testing.T) {
+	assert.Equal(t, []string{"retry", "backoff"}, Keywords("How does the retry backoff work?"))
+	assert.Equal(t, []string{"go"}, Keywords("go"))
+	assert.Empty(t, Keywords("   "))
+}
+
+func TestKeywordSearch(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"retry.go":  "package retry\n\n// Retry with exponential backoff\nfunc Retry() {}\n\n// retry once\n",
+		"other.go":  "package other\n\nfunc Backoff() {}\n",
+		"test.go":   "// retry backoff in a test\n",
+		"unused.md": "nothing to see\n",
+	}
+	var infos []fs.FileInfo
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		infos = append(infos, fs.FileInfo{Path: path, RelPath: name})
+	}
+
+	ctx := context.Background()
+	results, err := KeywordSearch(ctx, infos, "how does retry backoff work", SearchOptions{
+		TopK:           10,
+		IncludeContent: true,
+		ExcludeTerms:   []string{"test"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+
+	// The line with both keywords ranks first
+	assert.Equal(t, "retry.go", results[0].RelativePath)
+	assert.Equal(t, 3, results[0].StartLine)
+	assert.Equal(t, "// Retry with exponential backoff", results[0].Content)
+	assert.InDelta(t, 1, results[0].Score, 1e-9)
+	assert.InDelta(t, 0.5, results[1].Score, 1e-9)
+
+	// Per-file caps, patterns and limits apply as they do to Search
+	results, err = KeywordSearch(ctx, infos, "retry backoff", SearchOptions{TopK: 10, PerFile: 1, Grep: regexp.MustCompile(`func`)})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Empty(t, results[0].Content)
+	for _, r := range results {
+		assert.Contains(t, []string{"retry.go", "other.go"}, r.RelativePath)
+	}
+
+	results, err = KeywordSearch(ctx, infos, "once", SearchOptions{TopK: 1, ContextLines: 2})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "func Retry() {}\n", results[0].ContextBefore)
+}
@@ -0,0 +1,113 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/nickcecere/lgrep/internal/fs"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// languageNames maps the words that name a language in a query to the
+// language, as detected by fs.DetectLanguage.
+var languageNames = map[string]string{
+	"golang":     fs.LangGo,
+	"typescript": fs.LangTypeScript,
+	"javascript": fs.LangJavaScript,
+	"python":     fs.LangPython,
+	"rust":       fs.LangRust,
+	"java":       fs.LangJava,
+	"c++":        fs.LangCPP,
+	"cpp":        fs.LangCPP,
+	"c#":         fs.LangCSharp,
+	"csharp":     fs.LangCSharp,
+	"ruby":       fs.LangRuby,
+	"php":        fs.LangPHP,
+	"swift":      fs.LangSwift,
+	"kotlin":     fs.LangKotlin,
+	"scala":      fs.LangScala,
+	"bash":       fs.LangShell,
+	"shell":      fs.LangShell,
+	"sql":        fs.LangSQL,
+	"html":       fs.LangHTML,
+	"css":        fs.LangCSS,
+	"yaml":       fs.LangYAML,
+	"toml":       fs.LangTOML,
+	"markdown":   fs.LangMarkdown,
+}
+
+// languageShortNames are names that are also common words or too short to
+// trust on their own, such as "go". They only count when the query says
+// "in go" or "go code".
+var languageShortNames = map[string]string{
+	"go":   fs.LangGo,
+	"c":    fs.LangC,
+	"ts":   fs.LangTypeScript,
+	"js":   fs.LangJavaScript,
+	"py":   fs.LangPython,
+	"rb":   fs.LangRuby,
+	"sh":   fs.LangShell,
+	"yml":  fs.LangYAML,
+	"json": fs.LangJSON,
+	"xml":  fs.LangXML,
+}
+
+// languageContext are the words after a short name that show it names a
+// language, as in "go code".
+var languageContext = map[string]bool{
+	"code": true, "file": true, "files": true, "function": true, "functions": true,
+	"script": true, "scripts": true, "source": true,
+}
+
+// LanguageHints returns the languages a query names, such as python for
+// "retry logic in python" or sql for "sql query for users", sorted.
+func LanguageHints(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+' && r != '#'
+	})
+
+	seen := make(map[string]bool)
+	for i, w := range words {
+		lang, ok := languageNames[w]
+		if !ok {
+			lang, ok = languageShortNames[w]
+			ok = ok && ((i > 0 && words[i-1] == "in") || (i+1 < len(words) && languageContext[words[i+1]]))
+		}
+		if ok {
+			seen[lang] = true
+		}
+	}
+
+	langs := make([]string, 0, len(seen))
+	for lang := range seen {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// languageBoost returns the booster that multiplies the score of each
+// candidate from a file in one of langs by 1 + boost. Files indexed before
+// languages were recorded have theirs detected from the path.
+func languageBoost(langs []string, boost float64) booster {
+	if boost <= 0 || len(langs) == 0 {
+		return nil
+	}
+
+	wanted := make(map[string]bool, len(langs))
+	for _, lang := range langs {
+		wanted[lang] = true
+	}
+
+	return func(sr store.SearchResult) float64 {
+		lang := sr.File.Language
+		if lang == "" {
+			lang = fs.DetectLanguage(sr.File.RelativePath)
+		}
+		if wanted[lang] {
+			return 1 + boost
+		}
+		return 1
+	}
+}
@@ -0,0 +1,28 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanguageHints(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"retry logic in python", []string{"python"}},
+		{"sql query for active users", []string{"sql"}},
+		{"where is the flag parsing", []string{}},
+		{"how do I go about caching", []string{}},
+		{"rate limiter in go", []string{"go"}},
+		{"c code that frees buffers", []string{"c"}},
+		{"port the C++ parser to Rust", []string{"cpp", "rust"}},
+		{"Golang http client", []string{"go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.want, LanguageHints(tt.query))
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// pathBoost returns the booster that multiplies the score of each
+// candidate by the factor of the longest prefix in boosts its path is
+// under. Prefixes are relative to the store root and match
+// case-insensitively, since the config loader lowercases them; "docs/" and
+// "docs" both match docs/guide.md but not docsite/index.md.
+func pathBoost(boosts map[string]float64) booster {
+	if len(boosts) == 0 {
+		return nil
+	}
+
+	prefixes := make(map[string]float64, len(boosts))
+	for prefix, factor := range boosts {
+		prefixes[strings.Trim(strings.ToLower(prefix), "/")] = factor
+	}
+
+	return func(sr store.SearchResult) float64 {
+		if factor, ok := pathFactor(strings.ToLower(sr.File.RelativePath), prefixes); ok {
+			return factor
+		}
+		return 1
+	}
+}
+
+// pathFactor returns the factor of the longest prefix relPath is under.
+func pathFactor(relPath string, prefixes map[string]float64) (float64, bool) {
+	best, factor := -1, 0.0
+	for prefix, f := range prefixes {
+		if len(prefix) > best && (prefix == "" || underPath(relPath, prefix)) {
+			best, factor = len(prefix), f
+		}
+	}
+	return factor, best >= 0
+}
@@ -0,0 +1,121 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// ripgrepBinary is the ripgrep executable looked up on PATH for prefiltering.
+var ripgrepBinary = "rg"
+
+// CompilePrefilter compiles a prefilter pattern. Patterns use Go's RE2
+// syntax, which ripgrep's default engine shares but for \Q...\E quoting,
+// rejected here so a pattern means the same with and without ripgrep. With
+// ripgrep, \w, \d, \s and \b also match non-ASCII letters, digits and
+// spaces.
+func CompilePrefilter(pattern string) (*regexp.Regexp, error) {
+	for i := 0; i < len(pattern)-1; i++ {
+		if pattern[i] != '\\' {
+			continue
+		}
+		if pattern[i+1] == 'Q' {
+			return nil, fmt.Errorf("\\Q...\\E quoting is not supported; escape the characters instead")
+		}
+		i++
+	}
+	return regexp.Compile(pattern)
+}
+
+// PrefilterFiles returns the relative paths of the files of a store whose
+// content matches pattern, under pathPrefix if it is set. The files are
+// searched with ripgrep's default engine when it is installed, which honours
+// .gitignore as indexing does, and otherwise the store's indexed files are
+// read one by one and matched line by line. pattern should be compiled with
+// CompilePrefilter.
+// The result is never nil, so an empty result restricts a search to nothing.
+func (s *Searcher) PrefilterFiles(ctx context.Context, storeRecord *store.StoreRecord, pattern *regexp.Regexp, pathPrefix string) ([]string, error) {
+	if rg, err := exec.LookPath(ripgrepBinary); err == nil {
+		return ripgrepFiles(ctx, rg, storeRecord.RootPath, pattern, pathPrefix)
+	}
+
+	log.Debug("ripgrep not found, reading indexed files to prefilter")
+	paths := []string{}
+	err := s.store.WalkFiles(storeRecord.ID, &store.ListFilesOptions{PathPrefix: pathPrefix}, func(f store.FileRecord) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			// Deleted since indexing; its chunks cannot match either
+			return nil
+		}
+		if matchLines(pattern, content) {
+			paths = append(paths, f.RelativePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to prefilter files: %w", err)
+	}
+	return paths, nil
+}
+
+// matchLines reports whether pattern matches a line of content, as ripgrep
+// matches, so ^ and $ anchor to lines and no match spans a line break.
+func matchLines(pattern *regexp.Regexp, content []byte) bool {
+	for len(content) > 0 {
+		line := content
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			line, content = content[:i], content[i+1:]
+		} else {
+			content = nil
+		}
+		if pattern.Match(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// ripgrepFiles lists the files under root, or under pathPrefix within it,
+// that match pattern using the ripgrep executable rg. Paths are returned
+// relative to root with forward slashes.
+func ripgrepFiles(ctx context.Context, rg, root string, pattern *regexp.Regexp, pathPrefix string) ([]string, error) {
+	args := []string{"--files-with-matches", "--null", "--no-messages", "--no-config", "--hidden", "--glob", "!.git", "--engine", "default", "-e", pattern.String()}
+	if pathPrefix != "" {
+		args = append(args, "--", filepath.FromSlash(pathPrefix))
+	}
+	cmd := exec.CommandContext(ctx, rg, args...)
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+
+	// ripgrep exits with 1 when nothing matches, and with 2 after an error,
+	// such as an unreadable file, even if it found matches elsewhere
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && (exitErr.ExitCode() == 1 || len(out) > 0)) {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ripgrep failed: %s", msg)
+		}
+		return nil, fmt.Errorf("ripgrep failed: %w", err)
+	}
+
+	paths := []string{}
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" {
+			paths = append(paths, strings.TrimPrefix(filepath.ToSlash(p), "./"))
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,32 @@
+package search
+
+import (
+	"math"
+	"time"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// recencyBoost returns the booster that multiplies the score of each
+// candidate by 1 + weight × 0.5^(age/halfLife), where age is the time since
+// its file was modified. A file modified just now gets the full boost and
+// one modified a half-life ago half of it, so the current implementation of
+// something ranks above long-dead copies. Files indexed before modification
+// times were recorded use their indexing time.
+func recencyBoost(halfLife time.Duration, weight float64, now time.Time) booster {
+	if halfLife <= 0 || weight <= 0 {
+		return nil
+	}
+
+	return func(sr store.SearchResult) float64 {
+		modified := sr.File.ModifiedAt
+		if modified.IsZero() {
+			modified = sr.File.IndexedAt
+		}
+		if modified.IsZero() {
+			return 1
+		}
+		age := max(now.Sub(modified), 0)
+		return 1 + weight*math.Pow(0.5, float64(age)/float64(halfLife))
+	}
+}
@@ -0,0 +1,57 @@
+package search
+
+import (
+	"sort"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// relatedSeeds is how many of the top candidates have their files' related
+// files boosted.
+const relatedSeeds = 3
+
+// boostRelated adds boost to the score of each candidate from a file that
+// imports, or is imported by, the file of one of the top candidates, then
+// re-sorts the candidates by score. Code that works together then ranks
+// together. Stores without recorded relations are left as they are.
+func (s *Searcher) boostRelated(candidates []store.SearchResult, boost float64) []store.SearchResult {
+	if boost <= 0 || len(candidates) == 0 {
+		return candidates
+	}
+
+	var seeds []int64
+	seen := make(map[int64]bool)
+	for _, sr := range candidates[:min(relatedSeeds, len(candidates))] {
+		if !seen[sr.File.ID] {
+			seen[sr.File.ID] = true
+			seeds = append(seeds, sr.File.ID)
+		}
+	}
+
+	ids, err := s.store.GetRelatedFileIDs(seeds)
+	if err != nil {
+		log.Debug("Failed to get related files", "error", err)
+		return candidates
+	}
+	if len(ids) == 0 {
+		return candidates
+	}
+	related := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		related[id] = true
+	}
+
+	boosted := make([]store.SearchResult, len(candidates))
+	copy(boosted, candidates)
+	for i := range boosted {
+		if related[boosted[i].File.ID] {
+			boosted[i].Score += boost
+		}
+	}
+	sort.SliceStable(boosted, func(i, j int) bool {
+		return boosted[i].Score > boosted[j].Score
+	})
+	return boosted
+}
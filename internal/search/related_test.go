@@ -0,0 +1,71 @@
+package search
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+func TestBoostRelated(t *testing.T) {
+	tmpDir := t.TempDir()
+	st, err := store.NewSQLiteStore(filepath.Join(tmpDir, "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	rec, err := st.CreateStore("app", tmpDir, store.ProviderOllama, "test-model", 768)
+	require.NoError(t, err)
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	files := make(map[string]store.FileRecord)
+	for _, relPath := range []string{"a.go", "b.go", "c.go", "d.go", "e.go"} {
+		chunks := []store.Chunk{{Content: relPath, StartLine: 1, EndLine: 1}}
+		err := st.UpsertFile(rec.ID, store.FileInput{
+			ExternalID:   relPath,
+			Path:         filepath.Join(tmpDir, relPath),
+			RelativePath: relPath,
+			Hash:         relPath,
+		}, chunks, [][]float32{emb.generateEmbedding(relPath)})
+		require.NoError(t, err)
+		file, err := st.GetFileByExternalID(rec.ID, relPath)
+		require.NoError(t, err)
+		files[relPath] = *file
+	}
+
+	// e.go imports a.go, one of the top results
+	require.NoError(t, st.ReplaceFileRelations(rec.ID, []store.FileRelation{
+		{FromFileID: files["e.go"].ID, ToFileID: files["a.go"].ID},
+	}))
+
+	candidate := func(relPath string, score float64) store.SearchResult {
+		return store.SearchResult{File: files[relPath], Score: score}
+	}
+	candidates := []store.SearchResult{
+		candidate("a.go", 0.9),
+		candidate("b.go", 0.85),
+		candidate("c.go", 0.8),
+		candidate("d.go", 0.7),
+		candidate("e.go", 0.65),
+	}
+	order := func(results []store.SearchResult) []string {
+		var paths []string
+		for _, sr := range results {
+			paths = append(paths, sr.File.RelativePath)
+		}
+		return paths
+	}
+
+	searcher := New(st, emb)
+	boosted := searcher.boostRelated(candidates, 0.1)
+	assert.Equal(t, []string{"a.go", "b.go", "c.go", "e.go", "d.go"}, order(boosted))
+	assert.InDelta(t, 0.75, boosted[3].Score, 1e-9)
+
+	// The candidates are not modified
+	assert.Equal(t, 0.65, candidates[4].Score)
+
+	// No boost leaves the order as it is
+	assert.Equal(t, order(candidates), order(searcher.boostRelated(candidates, 0)))
+}
@@ -0,0 +1,56 @@
+package search
+
+import (
+	"sort"
+	"time"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// booster returns the factor a candidate's score is multiplied by when
+// reranking, 1 to leave it as it is.
+type booster func(sr store.SearchResult) float64
+
+// boosters returns the boosters opts turns on for a search of the store
+// with storeID, whose query names langs.
+func (s *Searcher) boosters(storeID int64, langs []string, opts SearchOptions) []booster {
+	return []booster{
+		languageBoost(langs, opts.LanguageBoost),
+		recencyBoost(opts.RecencyHalfLife, opts.RecencyWeight, time.Now()),
+		pathBoost(opts.PathBoost),
+		s.feedbackBoost(storeID, opts.FeedbackWeight),
+	}
+}
+
+// rerank multiplies the score of each candidate by the factors of boosts,
+// then re-sorts the candidates by score. A nil booster is one that is
+// turned off. Scores below zero are left as they are, since multiplying
+// would move them the wrong way. The candidates themselves are unchanged.
+func rerank(candidates []store.SearchResult, boosts ...booster) []store.SearchResult {
+	var active []booster
+	for _, b := range boosts {
+		if b != nil {
+			active = append(active, b)
+		}
+	}
+	if len(active) == 0 || len(candidates) == 0 {
+		return candidates
+	}
+
+	boosted := make([]store.SearchResult, len(candidates))
+	copy(boosted, candidates)
+	for i := range boosted {
+		if boosted[i].Score <= 0 {
+			continue
+		}
+		factor := 1.0
+		for _, b := range active {
+			factor *= b(candidates[i])
+		}
+		boosted[i].Score *= factor
+	}
+	sort.SliceStable(boosted, func(i, j int) bool {
+		return boosted[i].Score > boosted[j].Score
+	})
+	return boosted
+}
@@ -0,0 +1,120 @@
+package search
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+func TestRerank(t *testing.T) {
+	tmpDir := t.TempDir()
+	st, err := store.NewSQLiteStore(filepath.Join(tmpDir, "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	judged, err := st.CreateStore("judged", tmpDir, store.ProviderOllama, "test-model", 768)
+	require.NoError(t, err)
+	unjudged, err := st.CreateStore("unjudged", tmpDir, store.ProviderOllama, "test-model", 768)
+	require.NoError(t, err)
+	for _, f := range []store.Feedback{
+		{RelativePath: "auth/jwt.go", Good: false},
+		{RelativePath: "auth/jwt.go", Good: false},
+		{RelativePath: "auth/session.py", Good: true},
+	} {
+		f.StoreID = judged.ID
+		require.NoError(t, st.AddFeedback(&f))
+	}
+	searcher := New(st, nil)
+
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	candidate := func(relPath, lang string, modified, indexed time.Time, score float64) store.SearchResult {
+		return store.SearchResult{File: store.FileRecord{
+			RelativePath: relPath, Language: lang, ModifiedAt: modified, IndexedAt: indexed,
+		}, Score: score}
+	}
+	candidates := []store.SearchResult{
+		candidate("auth/jwt.go", "go", now.Add(-300*day), now, 0.8),
+		candidate("auth/session.py", "python", now.Add(-day), now, 0.76),
+		// Indexed before languages and modification times were recorded
+		candidate("Legacy/current/token.py", "", time.Time{}, now.Add(-30*day), 0.74),
+		candidate("docs/auth.md", "markdown", now.Add(-30*day), now, 0.7),
+		candidate("docsite/auth.md", "markdown", now.Add(day), now, 0.6), // Clock skew
+		candidate("legacy/old.go", "go", now, now, -0.2),
+	}
+	unchanged := append([]store.SearchResult(nil), candidates...)
+	paths := map[string]float64{"legacy/": 0.5, "legacy/current": 1, "docs": 1.2, "auth": 0.9}
+
+	tests := []struct {
+		name   string
+		boosts []booster
+		order  []string
+		scores map[string]float64
+	}{
+		{"no boosts", nil,
+			[]string{"auth/jwt.go", "auth/session.py", "Legacy/current/token.py", "docs/auth.md", "docsite/auth.md", "legacy/old.go"},
+			nil},
+		{"turned off", []booster{
+			languageBoost([]string{"python"}, 0),
+			languageBoost(nil, 0.1),
+			recencyBoost(0, 0.1, now),
+			recencyBoost(30*day, 0, now),
+			pathBoost(nil),
+			searcher.feedbackBoost(judged.ID, 0),
+			searcher.feedbackBoost(unjudged.ID, 0.1),
+		},
+			[]string{"auth/jwt.go", "auth/session.py", "Legacy/current/token.py", "docs/auth.md", "docsite/auth.md", "legacy/old.go"},
+			nil},
+		{"language", []booster{languageBoost([]string{"python"}, 0.1)},
+			[]string{"auth/session.py", "Legacy/current/token.py", "auth/jwt.go", "docs/auth.md", "docsite/auth.md", "legacy/old.go"},
+			map[string]float64{"auth/session.py": 0.76 * 1.1, "Legacy/current/token.py": 0.74 * 1.1, "legacy/old.go": -0.2}},
+		{"recency", []booster{recencyBoost(30*day, 0.1, now)},
+			[]string{"auth/session.py", "auth/jwt.go", "Legacy/current/token.py", "docs/auth.md", "docsite/auth.md", "legacy/old.go"},
+			map[string]float64{
+				"auth/session.py":         0.76 * (1 + 0.1*0.9771599684342459),
+				"Legacy/current/token.py": 0.74 * 1.05, // A half-life old gets half the boost
+				"docsite/auth.md":         0.6 * 1.1,   // No more than the full boost
+				"legacy/old.go":           -0.2,
+			}},
+		{"paths", []booster{pathBoost(paths)},
+			[]string{"docs/auth.md", "Legacy/current/token.py", "auth/jwt.go", "auth/session.py", "docsite/auth.md", "legacy/old.go"},
+			map[string]float64{
+				"docs/auth.md":            0.7 * 1.2,
+				"Legacy/current/token.py": 0.74, // The longest prefix applies
+				"docsite/auth.md":         0.6,
+				"legacy/old.go":           -0.2,
+			}},
+		{"feedback", []booster{searcher.feedbackBoost(judged.ID, 0.1)},
+			[]string{"auth/session.py", "auth/jwt.go", "Legacy/current/token.py", "docs/auth.md", "docsite/auth.md", "legacy/old.go"},
+			map[string]float64{
+				"auth/session.py": 0.76 * (1 + 0.1*(2*0.5-0.25)/3),
+				"auth/jwt.go":     0.8 * (1 + 0.1*(2*-2.0/3-0.25)/3),
+				"docs/auth.md":    0.7, // Other directories are left as they are
+			}},
+		{"combined", []booster{languageBoost([]string{"python"}, 0.1), pathBoost(paths)},
+			[]string{"docs/auth.md", "Legacy/current/token.py", "auth/session.py", "auth/jwt.go", "docsite/auth.md", "legacy/old.go"},
+			map[string]float64{"auth/session.py": 0.76 * 1.1 * 0.9, "Legacy/current/token.py": 0.74 * 1.1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boosted := rerank(candidates, tt.boosts...)
+
+			var order []string
+			scores := make(map[string]float64)
+			for _, sr := range boosted {
+				order = append(order, sr.File.RelativePath)
+				scores[sr.File.RelativePath] = sr.Score
+			}
+			assert.Equal(t, tt.order, order)
+			for relPath, want := range tt.scores {
+				assert.InDelta(t, want, scores[relPath], 1e-9, relPath)
+			}
+			assert.Equal(t, unchanged, candidates, "the candidates are unchanged")
+		})
+	}
+}
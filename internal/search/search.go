@@ -2,17 +2,31 @@
 package search
 
 import (
+	"container/heap"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/log"
 	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/fs"
 	"github.com/nickcecere/lgrep/internal/store"
+	"github.com/nickcecere/lgrep/internal/ui"
 )
 
+// ErrEmbedQuery is returned when the query cannot be embedded, typically
+// because the embedding provider is unavailable.
+var ErrEmbedQuery = errors.New("failed to embed query")
+
 // Searcher provides semantic search over indexed stores.
 type Searcher struct {
 	store    store.Store
@@ -26,6 +40,7 @@ type Result struct {
 	RelativePath string `json:"relative_path"`
 
 	// Chunk information
+	ChunkID   int64  `json:"chunk_id,omitempty"`
 	Content   string `json:"content"`
 	StartLine int    `json:"start_line"`
 	EndLine   int    `json:"end_line"`
@@ -34,9 +49,21 @@ type Result struct {
 	Score    float64 `json:"score"`    // 0-1, higher is better
 	Distance float64 `json:"distance"` // cosine distance
 
+	// Relevance is Score on the store's calibrated scale from 0 to 100,
+	// which means the same for every embedding model.
+	Relevance float64 `json:"relevance"`
+
 	// Context (optional, filled in by GetContext)
 	ContextBefore string `json:"context_before,omitempty"`
 	ContextAfter  string `json:"context_after,omitempty"`
+
+	// Stale is set by MarkStale when the file changed after it was indexed,
+	// so the content and line numbers may be out of date.
+	Stale bool `json:"stale,omitempty"`
+
+	// ImportedBy is set on chunks added by ImportedChunks to the path of
+	// the result file that imports them. They have no score.
+	ImportedBy string `json:"imported_by,omitempty"`
 }
 
 // SearchOptions configures the search.
@@ -47,14 +74,95 @@ type SearchOptions struct {
 	// TopK is the maximum number of results to return.
 	TopK int
 
-	// MinScore filters results below this similarity score.
+	// MinScore filters results below this similarity score. The threshold
+	// is applied in the vector query, so TopK results are still returned
+	// when enough candidates pass it.
 	MinScore float64
 
+	// MinRelevance filters results below this relevance, from 0 to 100. It
+	// is converted to a score with the store's calibration, so it can be
+	// set once for stores using different models.
+	MinRelevance float64
+
 	// IncludeContent includes the chunk content in results.
 	IncludeContent bool
 
 	// ContextLines is the number of lines of context to include.
 	ContextLines int
+
+	// LinesBefore and LinesAfter, if positive, are the number of lines of
+	// context to include before and after each result instead of
+	// ContextLines, like grep's -B and -A.
+	LinesBefore int
+	LinesAfter  int
+
+	// Expansions are alternative phrasings of the query. Each one is
+	// searched separately and the results are fused with the original query.
+	Expansions []string
+
+	// ExcludeTerms drops results whose content or path contains any of
+	// these terms (case-insensitive).
+	ExcludeTerms []string
+
+	// Grep, if set, drops results whose chunk content does not match it,
+	// keeping the ranking of the rest.
+	Grep *regexp.Regexp
+
+	// Prefilter, if set, restricts the search to the files whose content
+	// matches it, found with PrefilterFiles before ranking. Unlike Grep it
+	// narrows the candidates instead of filtering results, so it speeds up
+	// searches of large stores and finds chunks near rare identifiers
+	// that would not rank among the nearest of the whole store.
+	Prefilter *regexp.Regexp
+
+	// PathPrefix restricts results to the files under a directory, or to a
+	// single file, given relative to the store root. See PathPrefixFor.
+	PathPrefix string
+
+	// ExcludeGenerated drops results from vendored and generated files, as
+	// flagged at index time by fs.IsGenerated.
+	ExcludeGenerated bool
+
+	// PerFile caps how many results any one file contributes, so a large
+	// file with repeated content cannot crowd out the rest. Results over the
+	// cap are replaced by further candidates. Zero means no cap.
+	PerFile int
+
+	// Oversample is how many candidates are fetched per requested result
+	// when results are filtered after retrieval. Zero uses
+	// DefaultOversample.
+	Oversample int
+
+	// RelatedBoost is added to the score of results from files that import,
+	// or are imported by, the files of the top results, using the file
+	// relations recorded at indexing. Zero disables the boost.
+	RelatedBoost float64
+
+	// LanguageBoost raises the score of results in a language the query
+	// names, such as python for "retry logic in python", multiplying it by
+	// 1 + LanguageBoost. See LanguageHints. Zero disables the boost.
+	LanguageBoost float64
+
+	// RecencyHalfLife, if positive, boosts results from recently modified
+	// files, multiplying scores by up to 1 + RecencyWeight. The boost halves
+	// with each half-life since the file was modified.
+	RecencyHalfLife time.Duration
+
+	// RecencyWeight is the boost of a file modified just now. Zero disables
+	// the recency boost.
+	RecencyWeight float64
+
+	// PathBoost multiplies the scores of results under a path prefix, such
+	// as {"legacy/": 0.5}. The longest matching prefix applies.
+	PathBoost map[string]float64
+
+	// FeedbackWeight scales the prior learned from judgments of earlier
+	// results: scores are multiplied by up to 1 ± FeedbackWeight. Zero
+	// disables it.
+	FeedbackWeight float64
+
+	// Timings, if set, collects the time spent in each search phase.
+	Timings *Timings
 }
 
 // DefaultSearchOptions returns sensible defaults.
@@ -80,6 +188,7 @@ func (s *Searcher) Search(ctx context.Context, query string, opts SearchOptions)
 	if query == "" {
 		return nil, fmt.Errorf("query cannot be empty")
 	}
+	start := time.Now()
 
 	// Get store
 	storeRecord, err := s.store.GetStore(opts.StoreName)
@@ -90,40 +199,144 @@ func (s *Searcher) Search(ctx context.Context, query string, opts SearchOptions)
 		return nil, fmt.Errorf("store not found: %s", opts.StoreName)
 	}
 
-	// Generate query embedding
-	log.Debug("Generating query embedding", "query", truncate(query, 50))
-	queryEmbedding, err := s.embedder.EmbedQuery(ctx, query)
+	topK := opts.TopK
+	if topK <= 0 {
+		topK = 10
+	}
+
+	langs := LanguageHints(query)
+	if len(langs) == 0 {
+		opts.LanguageBoost = 0
+	}
+	fetchK := fetchCount(topK, opts)
+	calibration := s.calibration(storeRecord)
+	minScore := minScore(calibration, opts)
+
+	// Narrow the search to the files matching the prefilter, before paying
+	// for embeddings the search may not need
+	storeOpts := storeOptions(opts)
+	if opts.Prefilter != nil {
+		phase := time.Now()
+		paths, err := s.PrefilterFiles(ctx, storeRecord, opts.Prefilter, opts.PathPrefix)
+		if err != nil {
+			return nil, err
+		}
+		opts.Timings.Since(PhasePrefilter, phase)
+		log.Debug("Prefiltered files", "pattern", opts.Prefilter.String(), "files", len(paths))
+		if len(paths) == 0 {
+			return nil, nil
+		}
+		storeOpts.Paths = paths
+	}
+
+	// Search with the original query and any expansions
+	phase := time.Now()
+	queryEmbeddings, err := s.embedQueries(ctx, append([]string{query}, opts.Expansions...))
 	if err != nil {
-		return nil, fmt.Errorf("failed to embed query: %w", err)
+		return nil, err
+	}
+	embedTime := time.Since(phase)
+	opts.Timings.Add(PhaseEmbed, embedTime)
+
+	resultSets := make([][]store.SearchResult, 0, len(queryEmbeddings))
+	var dbTime time.Duration
+	for _, queryEmbedding := range queryEmbeddings {
+		// Search the store
+		log.Debug("Searching store", "store", opts.StoreName, "topK", fetchK)
+		phase := time.Now()
+		set, err := s.store.Search(storeRecord.ID, matchDimensions(queryEmbedding, storeRecord), fetchK, minScore, storeOpts)
+		if err != nil {
+			return nil, fmt.Errorf("search failed: %w", err)
+		}
+		dbTime += time.Since(phase)
+		opts.Timings.Since(PhaseVectorSearch, phase)
+		resultSets = append(resultSets, set)
+	}
+
+	rerankStart := time.Now()
+	candidates := s.boostRelated(fuseResults(resultSets, fetchK), opts.RelatedBoost)
+	candidates = rerank(candidates, s.boosters(storeRecord.ID, langs, opts)...)
+	results, contextTime := s.toResults(candidates, topK, calibration, opts)
+	if err := s.addContent(results, opts); err != nil {
+		return nil, err
+	}
+	opts.Timings.Add(PhaseContextIO, contextTime)
+	opts.Timings.Add(PhaseRerank, time.Since(rerankStart)-contextTime)
+
+	log.Debug("Search complete", "results", len(results))
+	s.recordMetric(&store.Metric{
+		Event:     store.MetricSearch,
+		StoreName: opts.StoreName,
+		Duration:  time.Since(start),
+		Embed:     embedTime,
+		DB:        dbTime,
+		Count:     len(results),
+	})
+	return results, nil
+}
+
+// SearchByVector searches a store with an embedding that has already been
+// computed, such as the stored vector of another chunk. Expansions are
+// ignored and no search metric is recorded.
+func (s *Searcher) SearchByVector(ctx context.Context, embedding []float32, opts SearchOptions) ([]Result, error) {
+	if len(embedding) == 0 {
+		return nil, fmt.Errorf("embedding cannot be empty")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storeRecord, err := s.store.GetStore(opts.StoreName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+	if storeRecord == nil {
+		return nil, fmt.Errorf("store not found: %s", opts.StoreName)
 	}
 
-	// Search the store
 	topK := opts.TopK
 	if topK <= 0 {
 		topK = 10
 	}
 
-	log.Debug("Searching store", "store", opts.StoreName, "topK", topK)
-	searchResults, err := s.store.Search(storeRecord.ID, queryEmbedding, topK)
+	calibration := s.calibration(storeRecord)
+	searchResults, err := s.store.Search(storeRecord.ID, matchDimensions(embedding, storeRecord), fetchCount(topK, opts), minScore(calibration, opts), storeOptions(opts))
 	if err != nil {
 		return nil, fmt.Errorf("search failed: %w", err)
 	}
+	results, _ := s.toResults(searchResults, topK, calibration, opts)
+	if err := s.addContent(results, opts); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
 
-	// Convert to Result type and filter
+// toResults converts up to topK store results to Results, dropping those
+// that contain excluded terms and adding content and context as requested.
+// It returns the time spent reading context from disk.
+func (s *Searcher) toResults(searchResults []store.SearchResult, topK int, calibration *store.ScoreCalibration, opts SearchOptions) ([]Result, time.Duration) {
+	var contextTime time.Duration
 	var results []Result
+	perFile := make(map[int64]int)
 	for _, sr := range searchResults {
-		// Filter by minimum score
-		if sr.Score < opts.MinScore {
+		if len(results) >= topK {
+			break
+		}
+
+		// Filter by excluded terms, pattern, path and the per-file cap
+		if excluded(sr, opts) || overFileCap(sr, perFile, opts.PerFile) {
 			continue
 		}
 
 		result := Result{
+			ChunkID:      sr.Chunk.ID,
 			FilePath:     sr.File.Path,
 			RelativePath: sr.File.RelativePath,
 			StartLine:    sr.Chunk.StartLine,
 			EndLine:      sr.Chunk.EndLine,
 			Score:        sr.Score,
 			Distance:     sr.Distance,
+			Relevance:    calibration.Relevance(sr.Score),
 		}
 
 		if opts.IncludeContent {
@@ -131,21 +344,90 @@ func (s *Searcher) Search(ctx context.Context, query string, opts SearchOptions)
 		}
 
 		// Add context if requested
-		if opts.ContextLines > 0 {
-			before, after := s.getContext(sr.File.Path, sr.Chunk.StartLine, sr.Chunk.EndLine, opts.ContextLines)
+		if opts.wantsContext() {
+			phase := time.Now()
+			before, after := s.getContext(sr, opts)
 			result.ContextBefore = before
 			result.ContextAfter = after
+			contextTime += time.Since(phase)
 		}
 
 		results = append(results, result)
 	}
+	return results, contextTime
+}
 
-	log.Debug("Search complete", "results", len(results))
-	return results, nil
+// storeOptions returns the options for searching the store with opts.
+// Chunk content is only read with the candidates when filtering them needs
+// it, or when context may fall back to the context stored with the chunk;
+// otherwise addContent reads it for the results that are kept. The store
+// only searches the files under the path prefix, so it does not use up the
+// candidates.
+func storeOptions(opts SearchOptions) *store.SearchOptions {
+	return &store.SearchOptions{
+		SkipContent: opts.Grep == nil && len(opts.ExcludeTerms) == 0 && !opts.wantsContext(),
+		PathPrefix:  opts.PathPrefix,
+	}
+}
+
+// addContent reads the content of results whose candidates were searched
+// without it, if opts asks for content.
+func (s *Searcher) addContent(results []Result, opts SearchOptions) error {
+	if !opts.IncludeContent || !storeOptions(opts).SkipContent || len(results) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(results))
+	for i, r := range results {
+		ids[i] = r.ChunkID
+	}
+	content, err := s.store.GetChunkContent(ids)
+	if err != nil {
+		return fmt.Errorf("failed to read result content: %w", err)
+	}
+	for i := range results {
+		results[i].Content = content[results[i].ChunkID]
+	}
+	return nil
+}
+
+// calibration returns the score calibration of a store, or nil if it has
+// none, in which case relevance is the score scaled to 0-100.
+func (s *Searcher) calibration(storeRecord *store.StoreRecord) *store.ScoreCalibration {
+	c, err := s.store.GetScoreCalibration(storeRecord.ID)
+	if err != nil {
+		log.Debug("Failed to get score calibration", "store", storeRecord.Name, "error", err)
+	}
+	return c
 }
 
-// SearchAll searches across all stores.
+// minScore returns the score threshold for the vector query: MinScore, or
+// the score of MinRelevance if that is higher.
+func minScore(calibration *store.ScoreCalibration, opts SearchOptions) float64 {
+	if opts.MinRelevance <= 0 {
+		return opts.MinScore
+	}
+	return max(opts.MinScore, calibration.MinScore(opts.MinRelevance))
+}
+
+// recordMetric stores a search metric, logging rather than failing on error.
+func (s *Searcher) recordMetric(m *store.Metric) {
+	if err := s.store.AddMetric(m); err != nil {
+		log.Debug("Failed to record search metric", "error", err)
+	}
+}
+
+// searchWorkers is the maximum number of stores SearchAll searches at once.
+const searchWorkers = 8
+
+// SearchAll searches across all stores. Stores are searched concurrently,
+// up to searchWorkers at a time, and the best topK results overall are
+// returned. A store that fails to search is logged and skipped.
 func (s *Searcher) SearchAll(ctx context.Context, query string, opts SearchOptions) ([]Result, error) {
+	if query == "" {
+		return nil, fmt.Errorf("query cannot be empty")
+	}
+
 	stores, err := s.store.ListStores()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list stores: %w", err)
@@ -155,69 +437,198 @@ func (s *Searcher) SearchAll(ctx context.Context, query string, opts SearchOptio
 		return nil, fmt.Errorf("no indexed stores found")
 	}
 
-	// Generate query embedding once
-	log.Debug("Generating query embedding", "query", truncate(query, 50))
-	queryEmbedding, err := s.embedder.EmbedQuery(ctx, query)
+	// Generate query embeddings once for all stores
+	start := time.Now()
+	queryEmbeddings, err := s.embedQueries(ctx, append([]string{query}, opts.Expansions...))
 	if err != nil {
-		return nil, fmt.Errorf("failed to embed query: %w", err)
+		return nil, err
 	}
+	embedTime := time.Since(start)
+	opts.Timings.Add(PhaseEmbed, embedTime)
 
 	topK := opts.TopK
 	if topK <= 0 {
 		topK = 10
 	}
 
-	// Search all stores and combine results
-	var allResults []Result
-	for _, storeRecord := range stores {
-		searchResults, err := s.store.Search(storeRecord.ID, queryEmbedding, topK)
-		if err != nil {
-			log.Warn("Search failed for store", "store", storeRecord.Name, "error", err)
-			continue
-		}
-
-		for _, sr := range searchResults {
-			if sr.Score < opts.MinScore {
-				continue
+	langs := LanguageHints(query)
+	if len(langs) == 0 {
+		opts.LanguageBoost = 0
+	}
+	fetchK := fetchCount(topK, opts)
+
+	phase := time.Now()
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		top  = &resultHeap{}
+		sem  = make(chan struct{}, searchWorkers)
+		errs = make([]error, len(stores))
+	)
+	for i := range stores {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
 			}
 
-			result := Result{
-				FilePath:     sr.File.Path,
-				RelativePath: sr.File.RelativePath,
-				StartLine:    sr.Chunk.StartLine,
-				EndLine:      sr.Chunk.EndLine,
-				Score:        sr.Score,
-				Distance:     sr.Distance,
+			storeRecord := &stores[i]
+			calibration := s.calibration(storeRecord)
+			minScore := minScore(calibration, opts)
+			sets := make([][]store.SearchResult, 0, len(queryEmbeddings))
+			for _, queryEmbedding := range queryEmbeddings {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					return
+				}
+				set, err := s.store.Search(storeRecord.ID, matchDimensions(queryEmbedding, storeRecord), fetchK, minScore, storeOptions(opts))
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				sets = append(sets, set)
 			}
 
-			if opts.IncludeContent {
-				result.Content = sr.Chunk.Content
+			candidates := rerank(fuseResults(sets, fetchK), s.boosters(storeRecord.ID, langs, opts)...)
+
+			mu.Lock()
+			defer mu.Unlock()
+			rank := 0
+			perFile := make(map[int64]int)
+			for _, sr := range candidates {
+				if excluded(sr, opts) || overFileCap(sr, perFile, opts.PerFile) {
+					continue
+				}
+				top.offer(rankedResult{result: sr, relevance: calibration.Relevance(sr.Score), store: i, rank: rank}, topK)
+				rank++
 			}
+		}(i)
+	}
+	wg.Wait()
+	dbTime := time.Since(phase)
+	opts.Timings.Add(PhaseVectorSearch, dbTime)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for i, err := range errs {
+		if err != nil {
+			log.Warn("Search failed for store", "store", stores[i].Name, "error", err)
+		}
+	}
+
+	rerankStart := time.Now()
+	ranked := top.sorted()
+	results := make([]Result, 0, len(ranked))
+	for _, r := range ranked {
+		sr := r.result
+		result := Result{
+			ChunkID:      sr.Chunk.ID,
+			FilePath:     sr.File.Path,
+			RelativePath: sr.File.RelativePath,
+			StartLine:    sr.Chunk.StartLine,
+			EndLine:      sr.Chunk.EndLine,
+			Score:        sr.Score,
+			Distance:     sr.Distance,
+			Relevance:    r.relevance,
+		}
+
+		if opts.IncludeContent {
+			result.Content = sr.Chunk.Content
+		}
 
-			allResults = append(allResults, result)
+		results = append(results, result)
+	}
+	if err := s.addContent(results, opts); err != nil {
+		return nil, err
+	}
+	opts.Timings.Since(PhaseRerank, rerankStart)
+
+	s.recordMetric(&store.Metric{
+		Event:    store.MetricSearch,
+		Duration: time.Since(start),
+		Embed:    embedTime,
+		DB:       dbTime,
+		Count:    len(results),
+	})
+	return results, nil
+}
+
+// embedQueries embeds a query and its expansions concurrently, returning the
+// embeddings in the same order as queries.
+func (s *Searcher) embedQueries(ctx context.Context, queries []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(queries))
+	errs := make([]error, len(queries))
+
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			log.Debug("Generating query embedding", "query", ui.Truncate(q, 50))
+			embeddings[i], errs[i] = s.embedder.EmbedQuery(ctx, q)
+		}(i, q)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrEmbedQuery, err)
 		}
 	}
+	return embeddings, nil
+}
 
-	// Sort by score (descending) and limit to topK
-	sortByScore(allResults)
-	if len(allResults) > topK {
-		allResults = allResults[:topK]
+// contextLines returns the number of lines of context to include before
+// and after each result.
+func (opts SearchOptions) contextLines() (before, after int) {
+	before, after = opts.ContextLines, opts.ContextLines
+	if opts.LinesBefore > 0 {
+		before = opts.LinesBefore
 	}
+	if opts.LinesAfter > 0 {
+		after = opts.LinesAfter
+	}
+	return before, after
+}
 
-	return allResults, nil
+// wantsContext reports whether opts asks for any lines of context.
+func (opts SearchOptions) wantsContext() bool {
+	before, after := opts.contextLines()
+	return before > 0 || after > 0
 }
 
-// getContext reads additional context lines from the file.
-func (s *Searcher) getContext(filePath string, startLine, endLine, contextLines int) (before, after string) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return "", ""
+// getContext returns the lines around a result. They are read from the file
+// if it is still on disk and not a binary file indexed by name, and otherwise from the file content or the
+// surrounding lines stored when the file was indexed.
+func (s *Searcher) getContext(sr store.SearchResult, opts SearchOptions) (before, after string) {
+	linesBefore, linesAfter := opts.contextLines()
+	content, err := os.ReadFile(sr.File.Path)
+	if err == nil && !fs.IsBinary(content) {
+		return linesAround(string(content), sr.Chunk.StartLine, sr.Chunk.EndLine, linesBefore, linesAfter)
+	}
+
+	// The file moved or is not available here
+	if stored, err := s.store.GetFileContent(sr.File.ID); err == nil && stored != "" {
+		return linesAround(stored, sr.Chunk.StartLine, sr.Chunk.EndLine, linesBefore, linesAfter)
 	}
 
-	lines := strings.Split(string(content), "\n")
+	return lastLines(sr.Chunk.ContextBefore, linesBefore), firstLines(sr.Chunk.ContextAfter, linesAfter)
+}
+
+// linesAround returns up to linesBefore lines of content before startLine
+// and up to linesAfter lines after endLine.
+func linesAround(content string, startLine, endLine, linesBefore, linesAfter int) (before, after string) {
+	lines := strings.Split(content, "\n")
 
 	// Get lines before
-	beforeStart := startLine - contextLines - 1
+	beforeStart := startLine - linesBefore - 1
 	if beforeStart < 0 {
 		beforeStart = 0
 	}
@@ -229,7 +640,7 @@ func (s *Searcher) getContext(filePath string, startLine, endLine, contextLines
 	// Get lines after
 	afterStart := endLine
 	if afterStart < len(lines) {
-		afterEnd := afterStart + contextLines
+		afterEnd := afterStart + linesAfter
 		if afterEnd > len(lines) {
 			afterEnd = len(lines)
 		}
@@ -239,7 +650,32 @@ func (s *Searcher) getContext(filePath string, startLine, endLine, contextLines
 	return before, after
 }
 
-// GetStoreForPath finds the store that contains the given path.
+// lastLines returns the last n lines of s.
+func lastLines(s string, n int) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(s, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// firstLines returns the first n lines of s.
+func firstLines(s string, n int) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(s, "\n")
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// GetStoreForPath finds the store that contains the given path. If stores
+// are nested, the innermost one is returned.
 func (s *Searcher) GetStoreForPath(path string) (*store.StoreRecord, error) {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
@@ -251,21 +687,259 @@ func (s *Searcher) GetStoreForPath(path string) (*store.StoreRecord, error) {
 		return nil, err
 	}
 
-	// Find store whose root path contains the given path
-	for _, storeRecord := range stores {
-		if strings.HasPrefix(absPath, storeRecord.RootPath) {
-			return &storeRecord, nil
+	// Find the store with the longest root path containing the given path
+	var found *store.StoreRecord
+	for i, storeRecord := range stores {
+		if storeRecord.RootPath != absPath && PathPrefixFor(storeRecord.RootPath, absPath) == "" {
+			continue
+		}
+		if found == nil || len(storeRecord.RootPath) > len(found.RootPath) {
+			found = &stores[i]
+		}
+	}
+
+	return found, nil
+}
+
+// fuseResults merges result sets from several queries. A chunk found by more
+// than one query keeps its best score, and the merged list is truncated to topK.
+func fuseResults(sets [][]store.SearchResult, topK int) []store.SearchResult {
+	if len(sets) == 1 {
+		return sets[0]
+	}
+
+	best := make(map[int64]int)
+	var fused []store.SearchResult
+	for _, set := range sets {
+		for _, sr := range set {
+			if i, ok := best[sr.Chunk.ID]; ok {
+				if sr.Score > fused[i].Score {
+					fused[i] = sr
+				}
+				continue
+			}
+			best[sr.Chunk.ID] = len(fused)
+			fused = append(fused, sr)
+		}
+	}
+
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+	if len(fused) > topK {
+		fused = fused[:topK]
+	}
+
+	return fused
+}
+
+// rankedResult is a candidate in SearchAll's merged results. Stores may use
+// different models, so candidates are ranked by relevance rather than raw
+// score. Ties are broken by store order and then by rank within the store,
+// so the merged order does not depend on which store finished first.
+type rankedResult struct {
+	result    store.SearchResult
+	relevance float64
+	store     int
+	rank      int
+}
+
+// better reports whether a ranks ahead of b.
+func (a rankedResult) better(b rankedResult) bool {
+	if a.relevance != b.relevance {
+		return a.relevance > b.relevance
+	}
+	if a.store != b.store {
+		return a.store < b.store
+	}
+	return a.rank < b.rank
+}
+
+// resultHeap keeps the best results seen so far, with the worst at the root
+// so it can be replaced cheaply.
+type resultHeap []rankedResult
+
+func (h resultHeap) Len() int           { return len(h) }
+func (h resultHeap) Less(i, j int) bool { return h[j].better(h[i]) }
+func (h resultHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *resultHeap) Push(x any)        { *h = append(*h, x.(rankedResult)) }
+
+func (h *resultHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// offer adds r if the heap holds fewer than limit results or r is better
+// than the worst of them.
+func (h *resultHeap) offer(r rankedResult, limit int) {
+	if h.Len() < limit {
+		heap.Push(h, r)
+		return
+	}
+	if h.Len() > 0 && r.better((*h)[0]) {
+		(*h)[0] = r
+		heap.Fix(h, 0)
+	}
+}
+
+// sorted empties the heap, returning its results best first.
+func (h *resultHeap) sorted() []rankedResult {
+	results := make([]rankedResult, h.Len())
+	for i := len(results) - 1; i >= 0; i-- {
+		results[i] = heap.Pop(h).(rankedResult)
+	}
+	return results
+}
+
+// matchDimensions truncates a query embedding to the dimensions a store was
+// indexed with, so stores built with embeddings.truncate_dimensions are
+// searched consistently even if the setting has since changed.
+func matchDimensions(embedding []float32, storeRecord *store.StoreRecord) []float32 {
+	if storeRecord.EmbeddingDimensions > 0 && len(embedding) > storeRecord.EmbeddingDimensions {
+		return embeddings.Truncate(embedding, storeRecord.EmbeddingDimensions)
+	}
+	return embedding
+}
+
+// DefaultOversample is how many candidates are fetched per requested result
+// when exclusion terms may filter some of them out.
+const DefaultOversample = 3
+
+// MaxTopK is the most results a single search returns.
+const MaxTopK = 1000
+
+// maxFetch is the largest k sqlite-vec accepts in a KNN query.
+const maxFetch = 4096
+
+// fetchCount returns how many candidates to fetch for topK results. Extra
+// candidates are fetched when excluding terms or generated files, matching
+// a pattern or capping results per file so filtered results can be
+// replaced, and when boosting related files, a language, recent files,
+// paths or feedback so candidates below topK can move up. A path prefix
+// needs none, as the store only searches the files under it.
+func fetchCount(topK int, opts SearchOptions) int {
+	if len(opts.ExcludeTerms) == 0 && !opts.ExcludeGenerated && opts.Grep == nil && opts.PerFile <= 0 && opts.RelatedBoost <= 0 && opts.LanguageBoost <= 0 && !recencyBoosted(opts) && len(opts.PathBoost) == 0 && opts.FeedbackWeight <= 0 {
+		return topK
+	}
+	oversample := opts.Oversample
+	if oversample <= 0 {
+		oversample = DefaultOversample
+	}
+	return min(topK*oversample, maxFetch)
+}
+
+// recencyBoosted reports whether opts boosts recently modified files.
+func recencyBoosted(opts SearchOptions) bool {
+	return opts.RecencyHalfLife > 0 && opts.RecencyWeight > 0
+}
+
+// queryStopWords are common words that are not worth highlighting.
+var queryStopWords = map[string]bool{
+	"and": true, "are": true, "can": true, "does": true, "for": true,
+	"from": true, "how": true, "the": true, "what": true, "when": true,
+	"where": true, "which": true, "who": true, "why": true, "with": true,
+}
+
+// QueryTerms splits a query into the lowercase words that may literally
+// appear in matching code, for highlighting. Words shorter than three
+// characters and common English words are dropped.
+func QueryTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range words {
+		if utf8.RuneCountInString(word) < 3 || queryStopWords[word] || seen[word] {
+			continue
 		}
+		seen[word] = true
+		terms = append(terms, word)
 	}
+	return terms
+}
+
+// ParseQuery splits exclusion terms out of a query. Words prefixed with "-"
+// (e.g. "token validation -test") are returned as exclusion terms and removed
+// from the query text.
+func ParseQuery(query string) (string, []string) {
+	var words, exclude []string
+	for _, word := range strings.Fields(query) {
+		if len(word) > 1 && strings.HasPrefix(word, "-") && !strings.HasPrefix(word, "--") {
+			exclude = append(exclude, word[1:])
+			continue
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), exclude
+}
 
-	// If path is a directory, find store with matching root
-	for _, storeRecord := range stores {
-		if storeRecord.RootPath == absPath {
-			return &storeRecord, nil
+// containsAnyTerm reports whether a result's content or path contains any term.
+func containsAnyTerm(sr store.SearchResult, terms []string) bool {
+	if len(terms) == 0 {
+		return false
+	}
+
+	content := strings.ToLower(sr.Chunk.Content)
+	path := strings.ToLower(sr.File.RelativePath)
+	for _, term := range terms {
+		term = strings.ToLower(term)
+		if term == "" {
+			continue
 		}
+		if strings.Contains(content, term) || strings.Contains(path, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded reports whether sr is filtered out by the excluded terms,
+// generated file filter, pattern or path prefix of opts. The store already
+// applies the path prefix; it is checked again in case a store does not.
+func excluded(sr store.SearchResult, opts SearchOptions) bool {
+	return containsAnyTerm(sr, opts.ExcludeTerms) ||
+		(opts.ExcludeGenerated && sr.File.Generated) ||
+		(opts.Grep != nil && !opts.Grep.MatchString(sr.Chunk.Content)) ||
+		!underPath(sr.File.RelativePath, opts.PathPrefix)
+}
+
+// underPath reports whether relPath is prefix or a file under it. An empty
+// prefix matches every path.
+func underPath(relPath, prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+	relPath = filepath.ToSlash(relPath)
+	return relPath == prefix || strings.HasPrefix(relPath, prefix+"/")
+}
+
+// PathPrefixFor returns the PathPrefix that restricts a search of the store
+// rooted at rootPath to path. It is empty if path is the root itself or not
+// inside it.
+func PathPrefixFor(rootPath, path string) string {
+	rel, err := filepath.Rel(rootPath, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
 	}
+	return filepath.ToSlash(rel)
+}
 
-	return nil, nil
+// overFileCap reports whether the file of sr already has limit results,
+// counting sr towards its file if not. A limit of zero means no cap.
+func overFileCap(sr store.SearchResult, counts map[int64]int, limit int) bool {
+	if limit <= 0 {
+		return false
+	}
+	if counts[sr.File.ID] >= limit {
+		return true
+	}
+	counts[sr.File.ID]++
+	return false
 }
 
 // sortByScore sorts results by score in descending order.
@@ -278,11 +952,3 @@ func sortByScore(results []Result) {
 		}
 	}
 }
-
-// truncate shortens a string for display.
-func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen-3] + "..."
-}
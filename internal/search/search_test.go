@@ -3,14 +3,18 @@ package search
 import (
 	"context"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/fs"
 	"github.com/nickcecere/lgrep/internal/store"
 )
 
@@ -105,8 +109,8 @@ func helper() {
 
 	chunks := []store.Chunk{
 		{Content: "package main\nimport \"fmt\"", StartLine: 1, EndLine: 3, ChunkIndex: 0},
-		{Content: "func main() {\n\tfmt.Println(\"Hello, World!\")\n}", StartLine: 5, EndLine: 7, ChunkIndex: 1},
-		{Content: "func helper() {\n\t// do something helpful\n}", StartLine: 9, EndLine: 11, ChunkIndex: 2},
+		{Content: "func main() {\n\tfmt.Println(\"Hello, World!\")\n}", StartLine: 5, EndLine: 7, ChunkIndex: 1, Symbols: []string{"main"}},
+		{Content: "func helper() {\n\t// do something helpful\n}", StartLine: 9, EndLine: 11, ChunkIndex: 2, Symbols: []string{"helper"}},
 	}
 
 	embeddings := make([][]float32, len(chunks))
@@ -192,6 +196,36 @@ func TestSearchWithMinScore(t *testing.T) {
 	assert.True(t, len(results) == 0 || results[0].Score >= 0.99)
 }
 
+// TestSearchWithMinRelevance tests relevance filtering on an uncalibrated
+// store.
+func TestSearchWithMinRelevance(t *testing.T) {
+	st, _, cleanup := createTestStore(t)
+	defer cleanup()
+
+	searcher := New(st, &mockEmbedder{model: "test-model", dimensions: 768})
+	search := func(minRelevance float64) []Result {
+		results, err := searcher.Search(context.Background(), "test query", SearchOptions{
+			StoreName:    "test-store",
+			TopK:         10,
+			MinScore:     -1,
+			MinRelevance: minRelevance,
+		})
+		require.NoError(t, err)
+		return results
+	}
+
+	all := search(0)
+	require.NotEmpty(t, all)
+	for _, r := range all {
+		assert.InDelta(t, max(0, r.Score*100), r.Relevance, 1e-9)
+	}
+
+	threshold := all[0].Relevance - 0.01
+	for _, r := range search(threshold) {
+		assert.GreaterOrEqual(t, r.Relevance, threshold)
+	}
+}
+
 // TestSearchWithContent tests content inclusion.
 func TestSearchWithContent(t *testing.T) {
 	st, _, cleanup := createTestStore(t)
@@ -307,6 +341,19 @@ func TestGetStoreForPath(t *testing.T) {
 	storeRecord, err = searcher.GetStoreForPath("/some/other/path")
 	require.NoError(t, err)
 	assert.Nil(t, storeRecord)
+
+	// A sibling sharing the root as a name prefix is not inside it
+	storeRecord, err = searcher.GetStoreForPath(tmpDir + "-other")
+	require.NoError(t, err)
+	assert.Nil(t, storeRecord)
+
+	// Nested stores resolve to the innermost
+	_, err = st.CreateStore("nested-store", filepath.Join(tmpDir, "lib"), store.ProviderOllama, "test-model", 768)
+	require.NoError(t, err)
+	storeRecord, err = searcher.GetStoreForPath(filepath.Join(tmpDir, "lib", "lib.go"))
+	require.NoError(t, err)
+	require.NotNil(t, storeRecord)
+	assert.Equal(t, "nested-store", storeRecord.Name)
 }
 
 // TestDefaultSearchOptions tests default options.
@@ -381,16 +428,534 @@ func TestSortByScore(t *testing.T) {
 	assert.Equal(t, 0.3, results[3].Score)
 }
 
-// TestTruncate tests string truncation.
-func TestTruncate(t *testing.T) {
-	// Short string - no truncation
-	assert.Equal(t, "hello", truncate("hello", 10))
+func TestFetchCount(t *testing.T) {
+	// No filtering - fetch exactly topK
+	assert.Equal(t, 10, fetchCount(10, SearchOptions{}))
+
+	// Exclusions oversample
+	opts := SearchOptions{ExcludeTerms: []string{"test"}}
+	assert.Equal(t, 10*DefaultOversample, fetchCount(10, opts))
+
+	// Capped at what sqlite-vec accepts
+	opts.Oversample = 10
+	assert.Equal(t, maxFetch, fetchCount(MaxTopK, opts))
+
+	// So do a pattern, a per-file cap and the related boost
+	assert.Equal(t, 10*DefaultOversample, fetchCount(10, SearchOptions{Grep: regexp.MustCompile("x")}))
+	assert.Equal(t, 10*DefaultOversample, fetchCount(10, SearchOptions{PerFile: 2}))
+	assert.Equal(t, 10*DefaultOversample, fetchCount(10, SearchOptions{RelatedBoost: 0.1}))
+	assert.Equal(t, 10*DefaultOversample, fetchCount(10, SearchOptions{ExcludeGenerated: true}))
+	assert.Equal(t, 10*DefaultOversample, fetchCount(10, SearchOptions{LanguageBoost: 0.05}))
+	assert.Equal(t, 10*DefaultOversample, fetchCount(10, SearchOptions{RecencyHalfLife: time.Hour, RecencyWeight: 0.1}))
+	assert.Equal(t, 10, fetchCount(10, SearchOptions{RecencyHalfLife: time.Hour}))
+	assert.Equal(t, 10, fetchCount(10, SearchOptions{PathPrefix: "lib"}))
+	assert.Equal(t, 10*DefaultOversample, fetchCount(10, SearchOptions{PathBoost: map[string]float64{"legacy/": 0.5}}))
+}
+
+// TestSearchGrep tests filtering results by a pattern.
+func TestSearchGrep(t *testing.T) {
+	st, _, cleanup := createTestStore(t)
+	defer cleanup()
+
+	searcher := New(st, &mockEmbedder{model: "test-model", dimensions: 768})
+	results, err := searcher.Search(context.Background(), "test query", SearchOptions{
+		StoreName: "test-store",
+		TopK:      10,
+		MinScore:  -1,
+		Grep:      regexp.MustCompile(`func \w+\(\)`),
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for _, r := range results {
+		assert.Contains(t, []int{5, 9}, r.StartLine)
+	}
+}
+
+// TestSearchPerFile tests capping the results from one file.
+func TestSearchPerFile(t *testing.T) {
+	st, _, cleanup := createTestStore(t)
+	defer cleanup()
+
+	searcher := New(st, &mockEmbedder{model: "test-model", dimensions: 768})
+	search := func(perFile int) []Result {
+		results, err := searcher.Search(context.Background(), "test query", SearchOptions{
+			StoreName: "test-store",
+			TopK:      10,
+			MinScore:  -1,
+			PerFile:   perFile,
+		})
+		require.NoError(t, err)
+		return results
+	}
+
+	assert.Len(t, search(0), 3)
+	capped := search(2)
+	require.Len(t, capped, 2)
+	assert.Equal(t, search(0)[:2], capped)
+}
+
+// TestSearchExcludeGenerated tests leaving out results from generated files.
+func TestSearchExcludeGenerated(t *testing.T) {
+	st, tmpDir, cleanup := createTestStore(t)
+	defer cleanup()
+
+	storeRecord, err := st.GetStore("test-store")
+	require.NoError(t, err)
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	content := "func mockMain() {}"
+	err = st.UpsertFile(storeRecord.ID, store.FileInput{
+		ExternalID:   "mocks.go",
+		Path:         filepath.Join(tmpDir, "mocks.go"),
+		RelativePath: "mocks.go",
+		Hash:         "mockhash",
+		FileSize:     int64(len(content)),
+		Generated:    true,
+	}, []store.Chunk{{Content: content, StartLine: 1, EndLine: 1}}, [][]float32{emb.generateEmbedding(content)})
+	require.NoError(t, err)
+
+	searcher := New(st, emb)
+	search := func(exclude bool) []string {
+		results, err := searcher.Search(context.Background(), "test query", SearchOptions{
+			StoreName:        "test-store",
+			TopK:             10,
+			MinScore:         -1,
+			ExcludeGenerated: exclude,
+		})
+		require.NoError(t, err)
+		var paths []string
+		for _, r := range results {
+			paths = append(paths, r.RelativePath)
+		}
+		return paths
+	}
+
+	assert.Contains(t, search(false), "mocks.go")
+	excluded := search(true)
+	assert.Len(t, excluded, 3)
+	assert.NotContains(t, excluded, "mocks.go")
+}
+
+// TestSearchWithExpansions tests fusing results from query expansions.
+func TestSearchWithExpansions(t *testing.T) {
+	st, _, cleanup := createTestStore(t)
+	defer cleanup()
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	searcher := New(st, emb)
+
+	results, err := searcher.Search(context.Background(), "hello world", SearchOptions{
+		StoreName:  "test-store",
+		TopK:       10,
+		Expansions: []string{"print a greeting", "func main()"},
+	})
+	require.NoError(t, err)
+
+	// Each chunk appears only once after fusion
+	seen := make(map[int]bool)
+	for _, r := range results {
+		assert.False(t, seen[r.StartLine], "duplicate result for line %d", r.StartLine)
+		seen[r.StartLine] = true
+	}
+	assert.LessOrEqual(t, len(results), 3)
+}
+
+// TestFuseResults tests merging of result sets.
+func TestFuseResults(t *testing.T) {
+	sets := [][]store.SearchResult{
+		{
+			{Chunk: store.ChunkRecord{ID: 1}, Score: 0.5},
+			{Chunk: store.ChunkRecord{ID: 2}, Score: 0.4},
+		},
+		{
+			{Chunk: store.ChunkRecord{ID: 2}, Score: 0.9},
+			{Chunk: store.ChunkRecord{ID: 3}, Score: 0.3},
+		},
+	}
+
+	fused := fuseResults(sets, 2)
+	require.Len(t, fused, 2)
+
+	// Chunk 2 keeps its best score and ranks first
+	assert.Equal(t, int64(2), fused[0].Chunk.ID)
+	assert.Equal(t, 0.9, fused[0].Score)
+	assert.Equal(t, int64(1), fused[1].Chunk.ID)
+}
+
+// TestParseQuery tests extraction of exclusion terms.
+func TestParseQuery(t *testing.T) {
+	query, exclude := ParseQuery("token validation -test -mock")
+	assert.Equal(t, "token validation", query)
+	assert.Equal(t, []string{"test", "mock"}, exclude)
+
+	// Lone dashes and double dashes are kept as part of the query
+	query, exclude = ParseQuery("a - b --flag")
+	assert.Equal(t, "a - b --flag", query)
+	assert.Empty(t, exclude)
+}
+
+func TestQueryTerms(t *testing.T) {
+	terms := QueryTerms("How does the JWT refresh_token get validated? jwt")
+	assert.Equal(t, []string{"jwt", "refresh_token", "get", "validated"}, terms)
+
+	assert.Empty(t, QueryTerms("is it ok"))
+}
+
+// TestSearchWithExcludeTerms tests filtering results by excluded terms.
+func TestSearchWithExcludeTerms(t *testing.T) {
+	st, _, cleanup := createTestStore(t)
+	defer cleanup()
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	searcher := New(st, emb)
+
+	results, err := searcher.Search(context.Background(), "function", SearchOptions{
+		StoreName:      "test-store",
+		TopK:           10,
+		IncludeContent: true,
+		ExcludeTerms:   []string{"HELPER"},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+
+	for _, r := range results {
+		assert.NotContains(t, strings.ToLower(r.Content), "helper")
+	}
+}
+
+// TestSearchTimings tests that a timing collector is filled in.
+func TestSearchTimings(t *testing.T) {
+	st, _, cleanup := createTestStore(t)
+	defer cleanup()
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	searcher := New(st, emb)
+
+	timings := NewTimings()
+	_, err := searcher.Search(context.Background(), "hello world", SearchOptions{
+		StoreName: "test-store",
+		TopK:      10,
+		Timings:   timings,
+	})
+	require.NoError(t, err)
+
+	assert.Greater(t, timings.Get(PhaseEmbed), time.Duration(0))
+	assert.Greater(t, timings.Get(PhaseVectorSearch), time.Duration(0))
+	assert.Zero(t, timings.Get(PhaseLLM))
+
+	ms := timings.Milliseconds()
+	assert.Len(t, ms, len(Phases()))
+	assert.Len(t, timings.LogValues(), 2*len(Phases()))
+
+	// A nil collector is ignored
+	var none *Timings
+	none.Add(PhaseEmbed, time.Second)
+	assert.Zero(t, none.Get(PhaseEmbed))
+}
+
+// TestSearchAll tests that results from every store are merged by score.
+func TestSearchAll(t *testing.T) {
+	st, _, cleanup := createTestStore(t)
+	defer cleanup()
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+
+	// Add more stores holding the same content
+	for _, name := range []string{"second", "third"} {
+		storeRecord, err := st.CreateStore(name, "/"+name, store.ProviderOllama, "test-model", 768)
+		require.NoError(t, err)
+		content := "func main() {\n\tfmt.Println(\"Hello, World!\")\n}"
+		err = st.UpsertFile(storeRecord.ID, store.FileInput{
+			ExternalID:   "main.go",
+			Path:         "/" + name + "/main.go",
+			RelativePath: "main.go",
+			Hash:         name,
+		}, []store.Chunk{{Content: content, StartLine: 1, EndLine: 3}}, [][]float32{emb.generateEmbedding(content)})
+		require.NoError(t, err)
+	}
+
+	// Mock embeddings can point away from the query, so allow negative scores
+	searcher := New(st, emb)
+	results, err := searcher.SearchAll(context.Background(), "hello world", SearchOptions{TopK: 10, MinScore: -1})
+	require.NoError(t, err)
+	assert.Len(t, results, 5)
+	for i := 1; i < len(results); i++ {
+		assert.GreaterOrEqual(t, results[i-1].Score, results[i].Score)
+	}
+
+	// The limit applies across stores
+	limited, err := searcher.SearchAll(context.Background(), "hello world", SearchOptions{TopK: 2, MinScore: -1})
+	require.NoError(t, err)
+	require.Len(t, limited, 2)
+	assert.Equal(t, results[:2], limited)
+}
+
+// TestResultHeap tests that the heap keeps the best results in a stable
+// order.
+func TestResultHeap(t *testing.T) {
+	h := &resultHeap{}
+	for i, score := range []float64{0.2, 0.9, 0.5, 0.9, 0.1, 0.7} {
+		h.offer(rankedResult{result: store.SearchResult{Score: score}, relevance: score * 100, store: i, rank: i}, 3)
+	}
+
+	got := h.sorted()
+	require.Len(t, got, 3)
+	assert.Equal(t, 0.9, got[0].result.Score)
+	assert.Equal(t, 1, got[0].store)
+	assert.Equal(t, 0.9, got[1].result.Score)
+	assert.Equal(t, 3, got[1].store)
+	assert.Equal(t, 0.7, got[2].result.Score)
+	assert.Zero(t, h.Len())
+}
+
+// TestSearchContextWithoutFile tests that context comes from the index when
+// the file is no longer on disk.
+func TestSearchContextWithoutFile(t *testing.T) {
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	storeRecord, err := st.CreateStore("test-store", "/gone", store.ProviderOllama, "test-model", 768)
+	require.NoError(t, err)
+
+	upsert := func(name, fileContent string) {
+		chunk := store.Chunk{Content: "three", StartLine: 3, EndLine: 3, ContextBefore: "one\ntwo", ContextAfter: "four\nfive"}
+		err := st.UpsertFile(storeRecord.ID, store.FileInput{
+			ExternalID:   name,
+			Path:         "/gone/" + name,
+			RelativePath: name,
+			Hash:         name,
+			Content:      fileContent,
+		}, []store.Chunk{chunk}, [][]float32{emb.generateEmbedding(chunk.Content)})
+		require.NoError(t, err)
+	}
+
+	searcher := New(st, emb)
+	search := func(contextLines int) Result {
+		results, err := searcher.Search(context.Background(), "three", SearchOptions{
+			StoreName:    "test-store",
+			TopK:         1,
+			MinScore:     -1,
+			ContextLines: contextLines,
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		return results[0]
+	}
+
+	// The stored window is trimmed to the requested lines
+	upsert("window.txt", "")
+	r := search(1)
+	assert.Equal(t, "two", r.ContextBefore)
+	assert.Equal(t, "four", r.ContextAfter)
+
+	// Stored file content is used in preference to the window
+	upsert("window.txt", "ONE\nTWO\nthree\nFOUR\nFIVE")
+	r = search(1)
+	assert.Equal(t, "TWO", r.ContextBefore)
+	assert.Equal(t, "FOUR", r.ContextAfter)
+
+	// Lines before and after can be asked for separately
+	results, err := searcher.Search(context.Background(), "three", SearchOptions{
+		StoreName:   "test-store",
+		TopK:        1,
+		MinScore:    -1,
+		LinesBefore: 2,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "ONE\nTWO", results[0].ContextBefore)
+	assert.Empty(t, results[0].ContextAfter)
+}
+
+// TestContextLines tests that LinesBefore and LinesAfter override
+// ContextLines on their own side only.
+func TestContextLines(t *testing.T) {
+	tests := []struct {
+		opts          SearchOptions
+		before, after int
+	}{
+		{SearchOptions{}, 0, 0},
+		{SearchOptions{ContextLines: 3}, 3, 3},
+		{SearchOptions{ContextLines: 3, LinesAfter: 1}, 3, 1},
+		{SearchOptions{LinesBefore: 2}, 2, 0},
+	}
+	for _, tt := range tests {
+		before, after := tt.opts.contextLines()
+		assert.Equal(t, tt.before, before)
+		assert.Equal(t, tt.after, after)
+		assert.Equal(t, tt.before > 0 || tt.after > 0, tt.opts.wantsContext())
+	}
+}
+
+func TestMarkStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	st, err := store.NewSQLiteStore(filepath.Join(tmpDir, "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	storeRecord, err := st.CreateStore("test-store", tmpDir, store.ProviderOllama, "test-model", 768)
+	require.NoError(t, err)
+
+	var results []Result
+	for _, name := range []string{"same.go", "edited.go", "deleted.go", "touched.go"} {
+		path := filepath.Join(tmpDir, name)
+		content := []byte("package main\n")
+		require.NoError(t, os.WriteFile(path, content, 0644))
+
+		chunk := store.Chunk{Content: string(content), StartLine: 1, EndLine: 1}
+		err := st.UpsertFile(storeRecord.ID, store.FileInput{
+			ExternalID:   name,
+			Path:         path,
+			RelativePath: name,
+			Hash:         fs.HashContent(content),
+			FileSize:     int64(len(content)),
+		}, []store.Chunk{chunk}, [][]float32{emb.generateEmbedding(chunk.Content)})
+		require.NoError(t, err)
+		results = append(results, Result{FilePath: path, RelativePath: name})
+	}
+	results = append(results, results[1])
+
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "edited.go"), []byte("package edited\n"), 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(tmpDir, "edited.go"), later, later))
+	require.NoError(t, os.Remove(filepath.Join(tmpDir, "deleted.go")))
+	require.NoError(t, os.Chtimes(filepath.Join(tmpDir, "touched.go"), later, later))
 
-	// Long string - truncated
-	result := truncate("hello world this is a long string", 10)
-	assert.Len(t, result, 10)
-	assert.True(t, strings.HasSuffix(result, "..."))
+	searcher := New(st, emb)
+	assert.Equal(t, 3, searcher.MarkStale(storeRecord, results))
+
+	stale := make([]bool, len(results))
+	for i, r := range results {
+		stale[i] = r.Stale
+	}
+	assert.Equal(t, []bool{false, true, true, false, true}, stale)
+	assert.Equal(t, []string{"edited.go", "deleted.go"}, StalePaths(results))
+}
+
+// TestSearchPathPrefix tests restricting results to a directory.
+func TestSearchPathPrefix(t *testing.T) {
+	st, tmpDir, cleanup := createTestStore(t)
+	defer cleanup()
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	storeRecord, err := st.GetStore("test-store")
+	require.NoError(t, err)
+	chunk := store.Chunk{Content: "func lib() {}", StartLine: 1, EndLine: 1}
+	require.NoError(t, st.UpsertFile(storeRecord.ID, store.FileInput{
+		ExternalID:   "lib/lib.go",
+		Path:         filepath.Join(tmpDir, "lib", "lib.go"),
+		RelativePath: "lib/lib.go",
+		Hash:         "libhash",
+	}, []store.Chunk{chunk}, [][]float32{emb.generateEmbedding(chunk.Content)}))
+
+	searcher := New(st, emb)
+	search := func(prefix string) []string {
+		results, err := searcher.Search(context.Background(), "test query", SearchOptions{
+			StoreName:  "test-store",
+			TopK:       10,
+			MinScore:   -1,
+			PathPrefix: prefix,
+		})
+		require.NoError(t, err)
+		var paths []string
+		for _, r := range results {
+			paths = append(paths, r.RelativePath)
+		}
+		return paths
+	}
+
+	assert.Len(t, search(""), 4)
+	assert.Equal(t, []string{"lib/lib.go"}, search("lib"))
+	assert.Equal(t, []string{"lib/lib.go"}, search("lib/lib.go"))
+	assert.Empty(t, search("li"))
+}
+
+// TestSearchPrefilter tests restricting a search to the files matching a
+// pattern, with ripgrep when it is installed and without it.
+func TestSearchPrefilter(t *testing.T) {
+	st, tmpDir, cleanup := createTestStore(t)
+	defer cleanup()
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	storeRecord, err := st.GetStore("test-store")
+	require.NoError(t, err)
+	content := "func lib() {\n\trareIdentifier()\n}\n"
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "lib", "lib.go"), []byte(content), 0644))
+	chunk := store.Chunk{Content: content, StartLine: 1, EndLine: 3}
+	require.NoError(t, st.UpsertFile(storeRecord.ID, store.FileInput{
+		ExternalID:   "lib/lib.go",
+		Path:         filepath.Join(tmpDir, "lib", "lib.go"),
+		RelativePath: "lib/lib.go",
+		Hash:         "libhash",
+	}, []store.Chunk{chunk}, [][]float32{emb.generateEmbedding(chunk.Content)}))
+
+	searcher := New(st, emb)
+	search := func(pattern, prefix string) []string {
+		prefilter, err := CompilePrefilter(pattern)
+		require.NoError(t, err)
+		timings := NewTimings()
+		results, err := searcher.Search(context.Background(), "test query", SearchOptions{
+			StoreName:  "test-store",
+			TopK:       10,
+			MinScore:   -1,
+			Prefilter:  prefilter,
+			PathPrefix: prefix,
+			Timings:    timings,
+		})
+		require.NoError(t, err)
+		assert.Positive(t, timings.Get(PhasePrefilter))
+		var paths []string
+		for _, r := range results {
+			paths = append(paths, r.RelativePath)
+		}
+		return paths
+	}
+
+	check := func(t *testing.T) {
+		assert.Equal(t, []string{"lib/lib.go"}, search(`rare\w+\(`, ""))
+		assert.Len(t, search(`func \w+\(\)`, ""), 4)
+		assert.Equal(t, []string{"lib/lib.go"}, search(`func \w+\(\)`, "lib"))
+		assert.Empty(t, search(`notInAnyFile`, ""))
+
+		// Patterns match line by line
+		assert.Equal(t, []string{"lib/lib.go"}, search(`^\trare\w+\(\)$`, ""))
+		assert.Empty(t, search(`\{\s+\trare`, ""))
+	}
+
+	t.Run("ripgrep", func(t *testing.T) {
+		if _, err := exec.LookPath(ripgrepBinary); err != nil {
+			t.Skip("ripgrep is not installed")
+		}
+		check(t)
+	})
+	t.Run("without ripgrep", func(t *testing.T) {
+		defer func(binary string) { ripgrepBinary = binary }(ripgrepBinary)
+		ripgrepBinary = "lgrep-no-such-ripgrep"
+		check(t)
+	})
+}
+
+// TestCompilePrefilter tests that only the patterns ripgrep reads the same
+// way are accepted.
+func TestCompilePrefilter(t *testing.T) {
+	for _, pattern := range []string{`rare\w+\(`, `a\\Q`, `^func$`} {
+		_, err := CompilePrefilter(pattern)
+		assert.NoError(t, err, pattern)
+	}
+	for _, pattern := range []string{`\Qa.b\E`, `x\Q(`, `(`} {
+		_, err := CompilePrefilter(pattern)
+		assert.Error(t, err, pattern)
+	}
+}
 
-	// Exact length
-	assert.Equal(t, "hello", truncate("hello", 5))
+func TestPathPrefixFor(t *testing.T) {
+	root := filepath.Join("/repo", "project")
+	assert.Equal(t, "", PathPrefixFor(root, root))
+	assert.Equal(t, "src", PathPrefixFor(root, filepath.Join(root, "src")))
+	assert.Equal(t, "src/api/handler.go", PathPrefixFor(root, filepath.Join(root, "src", "api", "handler.go")))
+	assert.Equal(t, "", PathPrefixFor(root, filepath.Join("/repo", "project2")))
+	assert.Equal(t, "", PathPrefixFor(root, "/elsewhere"))
 }
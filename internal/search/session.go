@@ -0,0 +1,88 @@
+package search
+
+import (
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// ResultsFor returns the results of an earlier search from its log, in rank
+// order and with the scores it recorded, so a later page can be shown
+// without searching again. Only opts.IncludeContent and the context lines of
+// opts apply. Chunks that no longer exist, because their files were re-indexed
+// or removed since, are left out.
+func (s *Searcher) ResultsFor(logged []store.LoggedResult, opts SearchOptions) ([]Result, error) {
+	ids := make([]int64, len(logged))
+	for i, r := range logged {
+		ids[i] = r.ChunkID
+	}
+	chunks, err := s.store.GetChunks(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]store.SearchResult, len(chunks))
+	for _, sr := range chunks {
+		byID[sr.Chunk.ID] = sr
+	}
+
+	results := make([]Result, 0, len(logged))
+	for _, r := range logged {
+		sr, ok := byID[r.ChunkID]
+		if !ok {
+			continue
+		}
+		result := Result{
+			ChunkID:      sr.Chunk.ID,
+			FilePath:     sr.File.Path,
+			RelativePath: sr.File.RelativePath,
+			StartLine:    sr.Chunk.StartLine,
+			EndLine:      sr.Chunk.EndLine,
+			Score:        r.Score,
+			Distance:     1 - r.Score,
+			Relevance:    r.Relevance,
+		}
+		if opts.IncludeContent {
+			result.Content = sr.Chunk.Content
+		}
+		if opts.wantsContext() {
+			result.ContextBefore, result.ContextAfter = s.getContext(sr, opts)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// LoadContent reads the content and context opts asks for into results
+// found without them, so a search can rank more results than it shows and
+// only read the ones shown. Results whose chunks no longer exist are left
+// without.
+func (s *Searcher) LoadContent(results []Result, opts SearchOptions) error {
+	if (!opts.IncludeContent && !opts.wantsContext()) || len(results) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(results))
+	for i, r := range results {
+		ids[i] = r.ChunkID
+	}
+	chunks, err := s.store.GetChunks(ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]store.SearchResult, len(chunks))
+	for _, sr := range chunks {
+		byID[sr.Chunk.ID] = sr
+	}
+
+	for i := range results {
+		sr, ok := byID[results[i].ChunkID]
+		if !ok {
+			continue
+		}
+		if opts.IncludeContent {
+			results[i].Content = sr.Chunk.Content
+		}
+		if opts.wantsContext() {
+			results[i].ContextBefore, results[i].ContextAfter = s.getContext(sr, opts)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+func TestResultsFor(t *testing.T) {
+	tmpDir := t.TempDir()
+	st, err := store.NewSQLiteStore(filepath.Join(tmpDir, "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	rec, err := st.CreateStore("app", tmpDir, store.ProviderOllama, "test-model", 768)
+	require.NoError(t, err)
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	ids := make(map[string]int64)
+	for _, relPath := range []string{"a.go", "b.go", "c.go"} {
+		chunks := []store.Chunk{{Content: "content of " + relPath, StartLine: 3, EndLine: 9}}
+		require.NoError(t, st.UpsertFile(rec.ID, store.FileInput{
+			ExternalID:   relPath,
+			Path:         filepath.Join(tmpDir, relPath),
+			RelativePath: relPath,
+			Hash:         relPath,
+		}, chunks, [][]float32{emb.generateEmbedding(relPath)}))
+		file, err := st.GetFileByExternalID(rec.ID, relPath)
+		require.NoError(t, err)
+		vectors, err := st.GetFileVectors(file.ID)
+		require.NoError(t, err)
+		require.Len(t, vectors, 1)
+		ids[relPath] = vectors[0].ChunkID
+	}
+
+	// b.go was removed since the search
+	require.NoError(t, st.DeleteFile(rec.ID, "b.go"))
+
+	searcher := New(st, emb)
+	logged := []store.LoggedResult{
+		{ChunkID: ids["c.go"], Score: 0.9, Relevance: 80},
+		{ChunkID: ids["b.go"], Score: 0.8, Relevance: 60},
+		{ChunkID: ids["a.go"], Score: 0.7, Relevance: 40},
+	}
+
+	results, err := searcher.ResultsFor(logged, SearchOptions{IncludeContent: true})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "c.go", results[0].RelativePath, "the logged order is kept")
+	assert.Equal(t, 0.9, results[0].Score)
+	assert.Equal(t, 80.0, results[0].Relevance)
+	assert.Equal(t, "content of c.go", results[0].Content)
+	assert.Equal(t, 3, results[0].StartLine)
+	assert.Equal(t, "a.go", results[1].RelativePath)
+
+	results, err = searcher.ResultsFor(logged, SearchOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, results[0].Content)
+}
+
+// TestLoadContent tests that content is read for results ranked without it.
+func TestLoadContent(t *testing.T) {
+	st, _, cleanup := createTestStore(t)
+	defer cleanup()
+
+	searcher := New(st, &mockEmbedder{model: "test-model", dimensions: 768})
+	results, err := searcher.Search(context.Background(), "test query", SearchOptions{StoreName: "test-store", TopK: 10, MinScore: -1})
+	require.NoError(t, err)
+	require.Greater(t, len(results), 1)
+	assert.Empty(t, results[0].Content)
+
+	page := results[:1]
+	require.NoError(t, searcher.LoadContent(page, SearchOptions{IncludeContent: true}))
+	assert.NotEmpty(t, page[0].Content)
+	assert.Empty(t, results[1].Content, "only the results given are read")
+}
@@ -0,0 +1,62 @@
+package search
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// SnippetWindow returns the range [start, end) of at most maxLines lines of
+// content that best show why it matched a query with the given terms (see
+// QueryTerms): the window containing the most distinct terms, then the most
+// lines with a term, centred on the lines with terms. Without any term in
+// content, or when it fits in maxLines, the window starts at the first line.
+func SnippetWindow(content string, terms []string, maxLines int) (start, end int) {
+	lines := strings.Split(content, "\n")
+	if maxLines <= 0 || len(lines) <= maxLines {
+		return 0, len(lines)
+	}
+
+	// The terms found on each line, as a bit set
+	found := make([]uint64, len(lines))
+	hasTerm := false
+	for i, line := range lines {
+		lower := strings.ToLower(line)
+		for t, term := range terms {
+			if t < 64 && strings.Contains(lower, term) {
+				found[i] |= 1 << t
+				hasTerm = true
+			}
+		}
+	}
+	if !hasTerm {
+		return 0, maxLines
+	}
+
+	best, bestDistinct, bestLines := 0, -1, -1
+	for s := 0; s+maxLines <= len(lines); s++ {
+		var seen uint64
+		matched := 0
+		for _, f := range found[s : s+maxLines] {
+			seen |= f
+			if f != 0 {
+				matched++
+			}
+		}
+		distinct := bits.OnesCount64(seen)
+		if distinct > bestDistinct || (distinct == bestDistinct && matched > bestLines) {
+			best, bestDistinct, bestLines = s, distinct, matched
+		}
+	}
+
+	// Centre the matched lines, which the earliest best window has at its top
+	first, last := best, best+maxLines-1
+	for found[first] == 0 {
+		first++
+	}
+	for found[last] == 0 {
+		last--
+	}
+	start = first - (maxLines-(last-first+1))/2
+	start = max(0, min(start, len(lines)-maxLines))
+	return start, start + maxLines
+}
@@ -0,0 +1,49 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnippetWindow(t *testing.T) {
+	lines := make([]string, 40)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i)
+	}
+	content := func(edits map[int]string) string {
+		l := append([]string(nil), lines...)
+		for i, s := range edits {
+			l[i] = s
+		}
+		return strings.Join(l, "\n")
+	}
+
+	// Short content and no limit show everything
+	start, end := SnippetWindow("a\nb", []string{"b"}, 5)
+	assert.Equal(t, [2]int{0, 2}, [2]int{start, end})
+	start, end = SnippetWindow(content(nil), []string{"retry"}, 0)
+	assert.Equal(t, [2]int{0, 40}, [2]int{start, end})
+
+	// Without a term the snippet starts at the top
+	start, end = SnippetWindow(content(nil), []string{"retry"}, 10)
+	assert.Equal(t, [2]int{0, 10}, [2]int{start, end})
+
+	// A single match is centred
+	start, end = SnippetWindow(content(map[int]string{20: "func Retry() {"}), []string{"retry"}, 10)
+	assert.Equal(t, [2]int{16, 26}, [2]int{start, end})
+
+	// More distinct terms beat more matching lines
+	text := content(map[int]string{
+		2: "retry", 3: "retry", 4: "retry",
+		30: "retry with backoff",
+	})
+	start, end = SnippetWindow(text, []string{"retry", "backoff"}, 10)
+	assert.True(t, start <= 30 && 30 < end, "window %d-%d", start, end)
+
+	// Near the end, the window stays inside the content
+	start, end = SnippetWindow(content(map[int]string{39: "backoff"}), []string{"backoff"}, 10)
+	assert.Equal(t, [2]int{30, 40}, [2]int{start, end})
+}
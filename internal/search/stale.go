@@ -0,0 +1,81 @@
+package search
+
+import (
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/nickcecere/lgrep/internal/fs"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// MarkStale sets Stale on results whose file has changed since it was
+// indexed, or no longer exists, and returns the number of stale results.
+// Files not modified since the store was last updated are taken to be
+// current, so most searches only stat each result's file; the content is
+// hashed only when the modification time and size cannot rule out a change.
+func (s *Searcher) MarkStale(storeRecord *store.StoreRecord, results []Result) int {
+	stale := make(map[string]bool)
+	count := 0
+	for i := range results {
+		r := &results[i]
+		isStale, checked := stale[r.RelativePath]
+		if !checked {
+			isStale = s.fileChanged(storeRecord, r)
+			stale[r.RelativePath] = isStale
+		}
+		r.Stale = isStale
+		if isStale {
+			count++
+		}
+	}
+	return count
+}
+
+// StalePaths returns the relative paths of the files of stale results, each
+// once.
+func StalePaths(results []Result) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, r := range results {
+		if r.Stale && !seen[r.RelativePath] {
+			seen[r.RelativePath] = true
+			paths = append(paths, r.RelativePath)
+		}
+	}
+	return paths
+}
+
+// fileChanged reports whether the file of r differs from its indexed
+// version.
+func (s *Searcher) fileChanged(storeRecord *store.StoreRecord, r *Result) bool {
+	info, err := os.Stat(r.FilePath)
+	if os.IsNotExist(err) {
+		return true
+	}
+	if err != nil {
+		log.Debug("Failed to check result file", "path", r.FilePath, "error", err)
+		return false
+	}
+	if !info.ModTime().After(storeRecord.UpdatedAt) {
+		return false
+	}
+
+	file, err := s.store.GetFileByExternalID(storeRecord.ID, r.RelativePath)
+	if err != nil || file == nil {
+		log.Debug("Failed to look up result file", "path", r.RelativePath, "error", err)
+		return false
+	}
+	if !info.ModTime().After(file.IndexedAt) {
+		return false
+	}
+	if info.Size() != file.FileSize {
+		return true
+	}
+
+	content, err := os.ReadFile(r.FilePath)
+	if err != nil {
+		log.Debug("Failed to read result file", "path", r.FilePath, "error", err)
+		return false
+	}
+	return fs.HashContent(content) != file.Hash
+}
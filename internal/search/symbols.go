@@ -0,0 +1,194 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nickcecere/lgrep/internal/fs"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// SymbolOptions configures a symbol lookup.
+type SymbolOptions struct {
+	// StoreName is the name of the store to search.
+	StoreName string
+
+	// Prefix matches every symbol starting with the name rather than only
+	// the name itself.
+	Prefix bool
+
+	// References also finds the chunks that use the name.
+	References bool
+
+	// Limit is the maximum number of definitions, and of references,
+	// returned.
+	Limit int
+
+	// PathPrefix restricts matches to the files under a directory, or to a
+	// single file, given relative to the store root. See PathPrefixFor.
+	PathPrefix string
+
+	// IncludeContent includes the chunk content in results.
+	IncludeContent bool
+}
+
+// SymbolResults are the definitions and uses of a symbol.
+type SymbolResults struct {
+	Definitions []SymbolResult `json:"definitions"`
+	References  []SymbolResult `json:"references,omitempty"`
+
+	// Semantic holds the results of a semantic search for the name, made
+	// when no definition is found.
+	Semantic []Result `json:"semantic,omitempty"`
+
+	// NoSymbols reports that the store has no symbol metadata, because it
+	// was indexed before symbols were recorded.
+	NoSymbols bool `json:"no_symbols,omitempty"`
+}
+
+// SymbolResult is a chunk that defines or uses a symbol.
+type SymbolResult struct {
+	Result
+
+	// Symbol is the name defined or used.
+	Symbol string `json:"symbol"`
+
+	// Line is the 1-indexed line of the definition or first use, and Text
+	// is that line.
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// FindSymbol looks up the definitions of name in the recorded symbols of a
+// store and, with References, the chunks using it. This is exact where a
+// semantic search is approximate and needs no query embedding. When no
+// definition is found, it falls back to a semantic search for the name.
+func (s *Searcher) FindSymbol(ctx context.Context, name string, opts SymbolOptions) (*SymbolResults, error) {
+	if name == "" {
+		return nil, fmt.Errorf("symbol cannot be empty")
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultSearchOptions().TopK
+	}
+
+	storeRecord, err := s.store.GetStore(opts.StoreName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+	if storeRecord == nil {
+		return nil, fmt.Errorf("store not found: %s", opts.StoreName)
+	}
+
+	// Overlapping chunks can repeat a definition or use, and matches outside
+	// PathPrefix are dropped, so fetch more than needed
+	fetch := opts.Limit * 4
+	if opts.PathPrefix != "" {
+		fetch = 0
+	}
+
+	results := &SymbolResults{}
+	matches, err := s.store.FindSymbols(storeRecord.ID, name, opts.Prefix, fetch)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, m := range matches {
+		if len(results.Definitions) >= opts.Limit {
+			break
+		}
+		sr := store.SearchResult{Chunk: m.Chunk, File: m.File}
+		if !underPath(m.File.RelativePath, opts.PathPrefix) {
+			continue
+		}
+		lang := fs.DetectLanguage(m.File.RelativePath)
+		line := max(fs.SymbolLine(m.Chunk.Content, lang, m.Symbol), 0)
+		r := newSymbolResult(sr, m.Symbol, line, opts)
+		if key := fmt.Sprintf("%s:%d:%s", r.RelativePath, r.Line, r.Symbol); !seen[key] {
+			seen[key] = true
+			results.Definitions = append(results.Definitions, r)
+		}
+	}
+
+	if opts.References {
+		refs, err := s.store.FindReferences(storeRecord.ID, name, fetch)
+		if err != nil {
+			return nil, err
+		}
+		for _, sr := range refs {
+			if len(results.References) >= opts.Limit {
+				break
+			}
+			if !underPath(sr.File.RelativePath, opts.PathPrefix) {
+				continue
+			}
+			line := useLine(sr.Chunk.Content, fs.DetectLanguage(sr.File.RelativePath), name)
+			if line < 0 {
+				continue
+			}
+			r := newSymbolResult(sr, name, line, opts)
+			if key := fmt.Sprintf("%s:%d", r.RelativePath, r.Line); !seen[key] {
+				seen[key] = true
+				results.References = append(results.References, r)
+			}
+		}
+	}
+
+	if len(results.Definitions) > 0 {
+		return results, nil
+	}
+
+	count, err := s.store.CountSymbols(storeRecord.ID)
+	if err != nil {
+		return nil, err
+	}
+	results.NoSymbols = count == 0
+
+	results.Semantic, err = s.Search(ctx, name, SearchOptions{
+		StoreName:      opts.StoreName,
+		TopK:           opts.Limit,
+		IncludeContent: opts.IncludeContent,
+		PathPrefix:     opts.PathPrefix,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// newSymbolResult returns the result for the line at index line of the
+// chunk in sr.
+func newSymbolResult(sr store.SearchResult, symbol string, line int, opts SymbolOptions) SymbolResult {
+	r := SymbolResult{
+		Result: Result{
+			ChunkID:      sr.Chunk.ID,
+			FilePath:     sr.File.Path,
+			RelativePath: sr.File.RelativePath,
+			StartLine:    sr.Chunk.StartLine,
+			EndLine:      sr.Chunk.EndLine,
+			Score:        1,
+			Relevance:    100,
+		},
+		Symbol: symbol,
+		Line:   sr.Chunk.StartLine + line,
+	}
+	if opts.IncludeContent {
+		r.Content = sr.Chunk.Content
+	}
+	lines := strings.Split(sr.Chunk.Content, "\n")
+	if line < len(lines) {
+		r.Text = strings.TrimSpace(lines[line])
+	}
+	return r
+}
+
+// useLine returns the index of the first line of content that uses name
+// other than to define it, or -1 if there is none.
+func useLine(content, lang, name string) int {
+	for i, line := range strings.Split(content, "\n") {
+		if store.ContainsIdentifier(line, name) && fs.SymbolLine(line, lang, name) < 0 {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,62 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindSymbol(t *testing.T) {
+	st, _, cleanup := createTestStore(t)
+	defer cleanup()
+
+	emb := &mockEmbedder{model: "test-model", dimensions: 768}
+	searcher := New(st, emb)
+	ctx := context.Background()
+
+	results, err := searcher.FindSymbol(ctx, "helper", SymbolOptions{StoreName: "test-store"})
+	require.NoError(t, err)
+	require.Len(t, results.Definitions, 1)
+	def := results.Definitions[0]
+	assert.Equal(t, "main.go", def.RelativePath)
+	assert.Equal(t, 9, def.Line)
+	assert.Equal(t, "func helper() {", def.Text)
+	assert.Empty(t, def.Content)
+	assert.Empty(t, results.Semantic)
+
+	// Prefixes and references
+	results, err = searcher.FindSymbol(ctx, "ma", SymbolOptions{StoreName: "test-store", Prefix: true})
+	require.NoError(t, err)
+	require.Len(t, results.Definitions, 1)
+	assert.Equal(t, "main", results.Definitions[0].Symbol)
+
+	results, err = searcher.FindSymbol(ctx, "fmt", SymbolOptions{StoreName: "test-store", References: true})
+	require.NoError(t, err)
+	require.Len(t, results.References, 2)
+	assert.Equal(t, 2, results.References[0].Line)
+	assert.Equal(t, 6, results.References[1].Line)
+
+	// Definition lines are not references
+	results, err = searcher.FindSymbol(ctx, "main", SymbolOptions{StoreName: "test-store", References: true})
+	require.NoError(t, err)
+	assert.Len(t, results.Definitions, 1)
+	require.Len(t, results.References, 1)
+	assert.Equal(t, 1, results.References[0].Line)
+
+	// Unknown names fall back to a semantic search
+	results, err = searcher.FindSymbol(ctx, "greeting", SymbolOptions{StoreName: "test-store"})
+	require.NoError(t, err)
+	assert.Empty(t, results.Definitions)
+	assert.NotEmpty(t, results.Semantic)
+	assert.False(t, results.NoSymbols)
+
+	// A path restricts matches
+	results, err = searcher.FindSymbol(ctx, "helper", SymbolOptions{StoreName: "test-store", PathPrefix: "other/"})
+	require.NoError(t, err)
+	assert.Empty(t, results.Definitions)
+
+	_, err = searcher.FindSymbol(ctx, "", SymbolOptions{StoreName: "test-store"})
+	assert.Error(t, err)
+}
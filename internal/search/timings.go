@@ -0,0 +1,85 @@
+package search
+
+import (
+	"sync"
+	"time"
+)
+
+// Phase identifies a stage of answering a query.
+type Phase string
+
+const (
+	PhasePrefilter    Phase = "prefilter"     // Finding the files matching the prefilter
+	PhaseEmbed        Phase = "embed"         // Query embedding
+	PhaseVectorSearch Phase = "vector_search" // Vector search in the store
+	PhaseContextIO    Phase = "context_io"    // Reading context lines from disk
+	PhaseRerank       Phase = "rerank"        // Fusing, filtering and ordering results
+	PhaseLLM          Phase = "llm"           // Generating an answer
+)
+
+// phases lists every phase in the order they are reported.
+var phases = []Phase{PhasePrefilter, PhaseEmbed, PhaseVectorSearch, PhaseContextIO, PhaseRerank, PhaseLLM}
+
+// Phases returns every phase in reporting order.
+func Phases() []Phase {
+	return append([]Phase(nil), phases...)
+}
+
+// Timings collects how long each phase of a search took. A nil *Timings
+// ignores all updates, so callers that do not need a breakdown can omit it.
+type Timings struct {
+	mu        sync.Mutex
+	durations map[Phase]time.Duration
+}
+
+// NewTimings creates an empty timing collector.
+func NewTimings() *Timings {
+	return &Timings{durations: make(map[Phase]time.Duration)}
+}
+
+// Add adds d to the time spent in phase.
+func (t *Timings) Add(phase Phase, d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.durations[phase] += d
+}
+
+// Since adds the time elapsed since start to phase.
+func (t *Timings) Since(phase Phase, start time.Time) {
+	t.Add(phase, time.Since(start))
+}
+
+// Get returns the time spent in phase.
+func (t *Timings) Get(phase Phase) time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.durations[phase]
+}
+
+// Milliseconds returns the time spent in each phase in milliseconds, keyed
+// by phase name.
+func (t *Timings) Milliseconds() map[string]float64 {
+	ms := make(map[string]float64, len(phases))
+	for _, p := range phases {
+		ms[string(p)] = float64(t.Get(p).Microseconds()) / 1000
+	}
+	return ms
+}
+
+// LogValues returns the phases as alternating keys and values for
+// structured logging, in a fixed order.
+func (t *Timings) LogValues() []any {
+	values := make([]any, 0, 2*len(phases))
+	for _, p := range phases {
+		values = append(values, string(p)+"_ms", t.Get(p).Milliseconds())
+	}
+	return values
+}
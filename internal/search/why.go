@@ -0,0 +1,160 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// Explanation describes how the chunks of one file rank for a query.
+type Explanation struct {
+	File store.FileRecord `json:"file"`
+
+	// TopK and MinScore are the limits a search would apply.
+	TopK     int     `json:"top_k"`
+	MinScore float64 `json:"min_score"`
+
+	// Cutoff is the score of the last result a search would return, or
+	// zero if fewer than TopK chunks reach MinScore.
+	Cutoff float64 `json:"cutoff"`
+
+	// Ranked is how many of the store's best chunks were ranked. Chunks
+	// scoring below all of them have a Rank of zero.
+	Ranked int `json:"ranked"`
+
+	// Chunks are the file's chunks, best first.
+	Chunks []ChunkRank `json:"chunks"`
+}
+
+// ChunkRank is a chunk of the explained file and its rank in the store.
+type ChunkRank struct {
+	Result
+
+	// Rank is the 1-based position of the chunk among all chunks of the
+	// store, or zero if it is beyond Explanation.Ranked.
+	Rank int `json:"rank"`
+
+	// Returned reports whether a search with the same options returns it.
+	Returned bool `json:"returned"`
+}
+
+// Explain embeds query and scores every chunk of the file at relPath in the
+// store against it, reporting where each ranks among all chunks of the
+// store and whether a search limited to TopK results with MinScore would
+// return it. Expansions and excluded terms are not applied.
+func (s *Searcher) Explain(ctx context.Context, query, relPath string, opts SearchOptions) (*Explanation, error) {
+	if query == "" {
+		return nil, fmt.Errorf("query cannot be empty")
+	}
+
+	storeRecord, err := s.store.GetStore(opts.StoreName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+	if storeRecord == nil {
+		return nil, fmt.Errorf("store not found: %s", opts.StoreName)
+	}
+	file, err := s.store.GetFileByExternalID(storeRecord.ID, relPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s is not indexed in store '%s'", relPath, storeRecord.Name)
+	}
+
+	topK := opts.TopK
+	if topK <= 0 {
+		topK = 10
+	}
+
+	queryEmbeddings, err := s.embedQueries(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	queryEmbedding := matchDimensions(queryEmbeddings[0], storeRecord)
+
+	// Rank as many chunks as a KNN query allows; the file's chunks are
+	// usually among them
+	ranked, err := s.store.Search(storeRecord.ID, queryEmbedding, maxFetch, -1, &store.SearchOptions{SkipContent: true})
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	ranks := make(map[int64]int, len(ranked))
+	for i, sr := range ranked {
+		ranks[sr.Chunk.ID] = i + 1
+	}
+
+	vectors, err := s.store.GetFileVectors(file.ID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(vectors))
+	scores := make(map[int64]float64, len(vectors))
+	for i, v := range vectors {
+		ids[i] = v.ChunkID
+		scores[v.ChunkID] = cosineSimilarity(queryEmbedding, v.Embedding)
+	}
+	chunks, err := s.store.GetChunks(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefer the store's own scores so ranks and scores agree exactly
+	for i := range chunks {
+		chunks[i].Score = scores[chunks[i].Chunk.ID]
+		if r := ranks[chunks[i].Chunk.ID]; r > 0 {
+			chunks[i].Score = ranked[r-1].Score
+		}
+		chunks[i].Distance = 1 - chunks[i].Score
+	}
+	opts.ExcludeTerms = nil
+	results, _ := s.toResults(chunks, len(chunks), s.calibration(storeRecord), opts)
+
+	e := &Explanation{
+		File:     *file,
+		TopK:     topK,
+		MinScore: opts.MinScore,
+		Ranked:   len(ranked),
+	}
+	var returned int
+	for _, sr := range ranked {
+		if returned == topK || sr.Score < opts.MinScore {
+			break
+		}
+		returned++
+		e.Cutoff = sr.Score
+	}
+	if returned < topK {
+		e.Cutoff = 0
+	}
+
+	for _, r := range results {
+		rank := ranks[r.ChunkID]
+		e.Chunks = append(e.Chunks, ChunkRank{
+			Result:   r,
+			Rank:     rank,
+			Returned: rank > 0 && rank <= returned,
+		})
+	}
+	sort.SliceStable(e.Chunks, func(i, j int) bool {
+		return e.Chunks[i].Score > e.Chunks[j].Score
+	})
+	return e, nil
+}
+
+// cosineSimilarity returns the cosine similarity of a and b.
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range min(len(a), len(b)) {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,79 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// fixedEmbedder embeds every query as the same vector.
+type fixedEmbedder struct {
+	mockEmbedder
+	vector []float32
+}
+
+func (f *fixedEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return f.vector, nil
+}
+
+func TestExplain(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/test.db")
+	require.NoError(t, err)
+	defer st.Close()
+
+	storeRecord, err := st.CreateStore("why", "/repo", store.ProviderOllama, "test-model", 2)
+	require.NoError(t, err)
+
+	upsert := func(name string, vectors ...[]float32) {
+		chunks := make([]store.Chunk, len(vectors))
+		for i := range vectors {
+			chunks[i] = store.Chunk{Content: name, StartLine: i*10 + 1, EndLine: i*10 + 10, ChunkIndex: i}
+		}
+		err := st.UpsertFile(storeRecord.ID, store.FileInput{
+			ExternalID:   name,
+			Path:         "/repo/" + name,
+			RelativePath: name,
+			Hash:         name,
+		}, chunks, vectors)
+		require.NoError(t, err)
+	}
+	upsert("best.go", []float32{1, 0}, []float32{1, 0.1})
+	upsert("target.go", []float32{0, 1}, []float32{1, 0.5})
+	upsert("other.go", []float32{1, 0.2})
+
+	searcher := New(st, &fixedEmbedder{mockEmbedder: mockEmbedder{dimensions: 2}, vector: []float32{1, 0}})
+	e, err := searcher.Explain(context.Background(), "query", "target.go", SearchOptions{StoreName: "why", TopK: 3})
+	require.NoError(t, err)
+
+	assert.Equal(t, "target.go", e.File.RelativePath)
+	assert.Equal(t, 5, e.Ranked)
+	assert.InDelta(t, cosineSimilarity([]float32{1, 0}, []float32{1, 0.2}), e.Cutoff, 1e-6)
+
+	// The file's second chunk ranks fourth, just outside the top 3; its
+	// first is orthogonal to the query and ranks last
+	require.Len(t, e.Chunks, 2)
+	assert.Equal(t, 11, e.Chunks[0].StartLine)
+	assert.Equal(t, 4, e.Chunks[0].Rank)
+	assert.False(t, e.Chunks[0].Returned)
+	assert.Equal(t, 1, e.Chunks[1].StartLine)
+	assert.Equal(t, 5, e.Chunks[1].Rank)
+	assert.InDelta(t, 0, e.Chunks[1].Score, 1e-6)
+
+	// A larger limit returns it
+	e, err = searcher.Explain(context.Background(), "query", "target.go", SearchOptions{StoreName: "why", TopK: 4})
+	require.NoError(t, err)
+	assert.True(t, e.Chunks[0].Returned)
+
+	// Unless the minimum score drops it
+	e, err = searcher.Explain(context.Background(), "query", "target.go", SearchOptions{StoreName: "why", TopK: 4, MinScore: 0.95})
+	require.NoError(t, err)
+	assert.False(t, e.Chunks[0].Returned)
+	assert.Zero(t, e.Cutoff)
+
+	_, err = searcher.Explain(context.Background(), "query", "missing.go", SearchOptions{StoreName: "why"})
+	assert.ErrorContains(t, err, "not indexed")
+}
@@ -0,0 +1,34 @@
+// Parse CSV text into an array of row objects keyed by the header line.
+// Quoted fields may contain commas and escaped "" quotes.
+function parseCSV(text) {
+  const [header, ...lines] = text.trim().split(/\r?\n/);
+  const columns = splitRow(header);
+  return lines.map((line) => {
+    const values = splitRow(line);
+    return Object.fromEntries(columns.map((name, i) => [name, values[i] ?? ""]));
+  });
+}
+
+function splitRow(line) {
+  const fields = [];
+  let field = "";
+  let quoted = false;
+  for (let i = 0; i < line.length; i++) {
+    const c = line[i];
+    if (quoted && c === '"' && line[i + 1] === '"') {
+      field += '"';
+      i++;
+    } else if (c === '"') {
+      quoted = !quoted;
+    } else if (c === "," && !quoted) {
+      fields.push(field);
+      field = "";
+    } else {
+      field += c;
+    }
+  }
+  fields.push(field);
+  return fields;
+}
+
+module.exports = { parseCSV };
@@ -0,0 +1,16 @@
+"""Fibonacci numbers with memoization."""
+
+from functools import lru_cache
+
+
+@lru_cache(maxsize=None)
+def fibonacci(n: int) -> int:
+    """Return the n-th Fibonacci number, where fibonacci(0) == 0."""
+    if n < 2:
+        return n
+    return fibonacci(n - 1) + fibonacci(n - 2)
+
+
+def fibonacci_sequence(count: int) -> list[int]:
+    """Return the first count numbers of the Fibonacci sequence."""
+    return [fibonacci(i) for i in range(count)]
@@ -0,0 +1,23 @@
+//go:build ignore
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// healthHandler reports that the HTTP server is up.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func main() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthHandler)
+
+	log.Println("listening on :8080")
+	log.Fatal(http.ListenAndServe(":8080", mux))
+}
@@ -0,0 +1,274 @@
+// Package selftest checks an lgrep installation end to end: it indexes a
+// small bundled corpus with the configured embedding provider into a
+// temporary database and verifies that searches find the expected files.
+package selftest
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/indexer"
+	"github.com/nickcecere/lgrep/internal/search"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+//go:embed corpus
+var corpus embed.FS
+
+// storeName is the name of the store in the temporary database.
+const storeName = "selftest"
+
+// queries are natural-language queries and the corpus file each should find.
+var queries = []struct {
+	query string
+	file  string
+}{
+	{"compute the fibonacci sequence", "fibonacci.py"},
+	{"HTTP server with a health check endpoint", "server.go"},
+	{"parse comma separated values with quoted fields", "csv.js"},
+	{"database tables for users and login sessions", "schema.sql"},
+}
+
+// queryRank is how highly a query's expected file must rank. Small local
+// models do not always put it first.
+const queryRank = 2
+
+// Step is the outcome of one check.
+type Step struct {
+	Name     string
+	Detail   string // What was verified, if it passed
+	Duration time.Duration
+	Err      error
+}
+
+// Run runs the self-test, calling report as each step finishes. It stops at
+// the first failing step and returns its error. Nothing outside a temporary
+// directory is written.
+func Run(ctx context.Context, emb embeddings.Service, cfg *config.Config, report func(Step)) error {
+	dir, err := os.MkdirTemp("", "lgrep-selftest-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "corpus")
+	files, err := writeCorpus(root)
+	if err != nil {
+		return err
+	}
+
+	// Index into a temporary database, ignoring the user's ignore patterns
+	// so every corpus file is indexed
+	testCfg := *cfg
+	testCfg.Database.Path = filepath.Join(dir, "selftest.db")
+	testCfg.Ignore = nil
+
+	st, err := store.NewSQLiteStore(testCfg.Database.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open temporary store: %w", err)
+	}
+	defer st.Close()
+
+	t := &tester{emb: emb, cfg: &testCfg, st: st, root: root, files: files}
+	steps := []struct {
+		name string
+		run  func(context.Context) (string, error)
+	}{
+		{"Embed", t.checkEmbeddings},
+		{"Index", t.index},
+		{"Round trip", t.checkRoundTrip},
+		{"Query", t.checkQueries},
+	}
+	for _, step := range steps {
+		start := time.Now()
+		detail, err := step.run(ctx)
+		report(Step{Name: step.name, Detail: detail, Duration: time.Since(start), Err: err})
+		if err != nil {
+			return fmt.Errorf("%s: %w", step.name, err)
+		}
+	}
+	return nil
+}
+
+// tester holds the state shared by the steps.
+type tester struct {
+	emb   embeddings.Service
+	cfg   *config.Config
+	st    store.Store
+	root  string
+	files []string // Relative paths of the corpus files
+}
+
+// writeCorpus copies the bundled corpus to root and returns its file names.
+func writeCorpus(root string) ([]string, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create corpus directory: %w", err)
+	}
+
+	entries, err := fs.ReadDir(corpus, "corpus")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		data, err := corpus.ReadFile("corpus/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(root, e.Name()), data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write corpus: %w", err)
+		}
+		files = append(files, e.Name())
+	}
+	return files, nil
+}
+
+// checkEmbeddings verifies that the provider returns usable, consistent
+// vectors: the same text embeds the same way twice, singly and in a batch.
+func (t *tester) checkEmbeddings(ctx context.Context) (string, error) {
+	const text = "func add(a, b int) int { return a + b }"
+
+	single, err := t.emb.Embed(ctx, text)
+	if err != nil {
+		return "", err
+	}
+	batch, err := t.emb.EmbedBatch(ctx, []string{text, "SELECT * FROM users"})
+	if err != nil {
+		return "", err
+	}
+
+	if len(single) == 0 {
+		return "", fmt.Errorf("provider returned an empty embedding")
+	}
+	if len(batch) != 2 {
+		return "", fmt.Errorf("provider returned %d embeddings for a batch of 2", len(batch))
+	}
+	if len(batch[0]) != len(single) || len(batch[1]) != len(single) {
+		return "", fmt.Errorf("embedding dimensions differ between calls: %d and %d", len(single), len(batch[0]))
+	}
+	for _, v := range single {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return "", fmt.Errorf("embedding contains invalid values")
+		}
+	}
+	if sim := cosine(single, batch[0]); sim < 0.99 {
+		return "", fmt.Errorf("the same text embedded differently (similarity %.3f)", sim)
+	}
+
+	return fmt.Sprintf("%s %s, %d dimensions", t.emb.Provider(), t.emb.ModelName(), len(single)), nil
+}
+
+// index indexes the corpus and checks that every file was stored.
+func (t *tester) index(ctx context.Context) (string, error) {
+	idx := indexer.New(t.st, t.emb, t.cfg)
+	opts := indexer.DefaultIndexOptions()
+	opts.StoreName = storeName
+	opts.Path = t.root
+	if err := idx.Index(ctx, opts); err != nil {
+		return "", err
+	}
+
+	stats, err := idx.Stats(storeName)
+	if err != nil {
+		return "", err
+	}
+	if stats.FileCount != len(t.files) {
+		return "", fmt.Errorf("indexed %d of %d files", stats.FileCount, len(t.files))
+	}
+	if stats.ChunkCount < stats.FileCount {
+		return "", fmt.Errorf("indexed %d files but only %d chunks", stats.FileCount, stats.ChunkCount)
+	}
+	return fmt.Sprintf("%d files, %d chunks", stats.FileCount, stats.ChunkCount), nil
+}
+
+// checkRoundTrip searches with each file's own content, which must find
+// that file first. This verifies that vectors are stored and retrieved
+// intact, independently of how good the model is.
+func (t *tester) checkRoundTrip(ctx context.Context) (string, error) {
+	searcher := search.New(t.st, t.emb)
+	for _, file := range t.files {
+		content, err := os.ReadFile(filepath.Join(t.root, file))
+		if err != nil {
+			return "", err
+		}
+		rank, err := t.rank(ctx, searcher, string(content), file)
+		if err != nil {
+			return "", err
+		}
+		if rank != 1 {
+			return "", fmt.Errorf("searching for the content of %s ranked it %s", file, rankString(rank))
+		}
+	}
+	return fmt.Sprintf("%d files found by their own content", len(t.files)), nil
+}
+
+// checkQueries runs natural-language queries with known answers.
+func (t *tester) checkQueries(ctx context.Context) (string, error) {
+	searcher := search.New(t.st, t.emb)
+	for _, q := range queries {
+		rank, err := t.rank(ctx, searcher, q.query, q.file)
+		if err != nil {
+			return "", err
+		}
+		if rank == 0 || rank > queryRank {
+			return "", fmt.Errorf("%q ranked %s %s, expected top %d", q.query, q.file, rankString(rank), queryRank)
+		}
+	}
+	return fmt.Sprintf("%d queries found the expected file", len(queries)), nil
+}
+
+// rank returns the 1-based rank of file in the results for query, or 0 if
+// it was not found.
+func (t *tester) rank(ctx context.Context, searcher *search.Searcher, query, file string) (int, error) {
+	results, err := searcher.Search(ctx, query, search.SearchOptions{
+		StoreName: storeName,
+		TopK:      len(t.files) * 4,
+		MinScore:  -1,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Files may have several chunks; rank by the first chunk of each file
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if seen[r.RelativePath] {
+			continue
+		}
+		seen[r.RelativePath] = true
+		if r.RelativePath == file {
+			return len(seen), nil
+		}
+	}
+	return 0, nil
+}
+
+// rankString describes a rank from rank.
+func rankString(rank int) string {
+	if rank == 0 {
+		return "not found"
+	}
+	return fmt.Sprintf("#%d", rank)
+}
+
+// cosine returns the cosine similarity of a and b.
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
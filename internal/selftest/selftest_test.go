@@ -0,0 +1,87 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/embeddings"
+)
+
+// wordEmbedder embeds text as a bag of hashed words, so texts sharing words
+// are similar.
+type wordEmbedder struct {
+	err error
+}
+
+func (w *wordEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	emb := make([]float32, 64)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		emb[h.Sum32()%64]++
+	}
+	return emb, nil
+}
+
+func (w *wordEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return w.Embed(ctx, text)
+}
+
+func (w *wordEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	result := make([][]float32, len(texts))
+	for i, text := range texts {
+		emb, err := w.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = emb
+	}
+	return result, nil
+}
+
+func (w *wordEmbedder) Dimensions() int               { return 64 }
+func (w *wordEmbedder) Provider() embeddings.Provider { return embeddings.ProviderOllama }
+func (w *wordEmbedder) ModelName() string             { return "words" }
+
+func TestRun(t *testing.T) {
+	var steps []Step
+	err := Run(context.Background(), &wordEmbedder{}, config.DefaultConfig(), func(s Step) {
+		steps = append(steps, s)
+	})
+	require.NoError(t, err)
+
+	require.Len(t, steps, 4)
+	for _, s := range steps {
+		assert.NoError(t, s.Err, s.Name)
+		assert.NotEmpty(t, s.Detail, s.Name)
+	}
+	assert.Contains(t, steps[1].Detail, "4 files")
+}
+
+func TestRunProviderError(t *testing.T) {
+	unavailable := errors.New("connection refused")
+
+	var steps []Step
+	err := Run(context.Background(), &wordEmbedder{err: unavailable}, config.DefaultConfig(), func(s Step) {
+		steps = append(steps, s)
+	})
+	assert.ErrorIs(t, err, unavailable)
+
+	// Stops at the first step
+	require.Len(t, steps, 1)
+	assert.Equal(t, "Embed", steps[0].Name)
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// storeIDByName is an SQL expression that resolves a store name or alias in
+// a namespace to the store's ID. Its parameters are given by nameArgs.
+const storeIDByName = `COALESCE(
+	(SELECT id FROM stores WHERE namespace = ? AND name = ?),
+	(SELECT store_id FROM store_aliases WHERE namespace = ? AND alias = ?))`
+
+// nameArgs returns the parameters of storeIDByName for a name in the
+// store's namespace.
+func (s *SQLiteStore) nameArgs(name string) []any {
+	return []any{s.namespace, name, s.namespace, name}
+}
+
+// rebuildPrefix starts the names of the temporary stores that forced
+// re-indexes build into before swapping their contents in. The prefix is
+// reserved: ListStores leaves such stores out, and no store can be renamed
+// or aliased to a name starting with it.
+const rebuildPrefix = "lgrep.rebuild:"
+
+// RebuildStoreName returns the name of the temporary store that a forced
+// re-index of the store called name builds into.
+func RebuildStoreName(name string) string {
+	return rebuildPrefix + name
+}
+
+// IsRebuildStore reports whether name is reserved for a temporary rebuild
+// store.
+func IsRebuildStore(name string) bool {
+	return strings.HasPrefix(name, rebuildPrefix)
+}
+
+// ErrReservedName is returned when a store name or alias uses the prefix
+// reserved for temporary rebuild stores.
+var ErrReservedName = fmt.Errorf("names starting with %q are reserved", rebuildPrefix)
+
+// ErrStoreNotFound is returned when a store name or alias does not exist.
+var ErrStoreNotFound = errors.New("store not found")
+
+// ErrNameInUse is returned when a name is already used by another store,
+// either as its name or as an alias.
+var ErrNameInUse = errors.New("name is already in use")
+
+// RenameStore renames a store without re-indexing it. The old name is kept
+// as an alias, so commands that pick a store by directory name still find
+// it. Q&A history, usage and metrics recorded under the old name move to the
+// new one.
+func (s *SQLiteStore) RenameStore(oldName, newName string) error {
+	return retryOnBusy(func() error {
+		return s.renameStore(oldName, newName)
+	})
+}
+
+// renameStore performs RenameStore without retrying.
+func (s *SQLiteStore) renameStore(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	storeID, current, err := s.lookupStore(tx, oldName)
+	if err != nil {
+		return err
+	}
+	if current == newName {
+		return nil
+	}
+	if IsRebuildStore(newName) {
+		return fmt.Errorf("%w: %s", ErrReservedName, newName)
+	}
+
+	// The new name may already be an alias of this store, but not of another
+	owner, _, err := s.lookupStore(tx, newName)
+	if err == nil && owner != storeID {
+		return fmt.Errorf("%w: %s", ErrNameInUse, newName)
+	}
+	if err != nil && !errors.Is(err, ErrStoreNotFound) {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM store_aliases WHERE namespace = ? AND alias = ?", s.namespace, newName); err != nil {
+		return fmt.Errorf("failed to remove alias: %w", err)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec("UPDATE stores SET name = ?, updated_at = ? WHERE id = ?", newName, now, storeID); err != nil {
+		return fmt.Errorf("failed to rename store: %w", err)
+	}
+
+	if _, err := tx.Exec("INSERT OR REPLACE INTO store_aliases (namespace, alias, store_id) VALUES (?, ?, ?)", s.namespace, current, storeID); err != nil {
+		return fmt.Errorf("failed to add alias: %w", err)
+	}
+
+	for _, table := range []string{"qa_history", "usage", "metrics"} {
+		query := fmt.Sprintf("UPDATE %s SET store_name = ? WHERE namespace = ? AND store_name = ?", table)
+		if _, err := tx.Exec(query, newName, s.namespace, current); err != nil {
+			return fmt.Errorf("failed to update %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// AddStoreAlias makes alias refer to the store called name.
+func (s *SQLiteStore) AddStoreAlias(name, alias string) error {
+	return retryOnBusy(func() error {
+		return s.addStoreAlias(name, alias)
+	})
+}
+
+// addStoreAlias performs AddStoreAlias without retrying.
+func (s *SQLiteStore) addStoreAlias(name, alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	storeID, _, err := s.lookupStore(tx, name)
+	if err != nil {
+		return err
+	}
+	if IsRebuildStore(alias) {
+		return fmt.Errorf("%w: %s", ErrReservedName, alias)
+	}
+
+	owner, _, err := s.lookupStore(tx, alias)
+	if err == nil {
+		if owner == storeID {
+			return nil
+		}
+		return fmt.Errorf("%w: %s", ErrNameInUse, alias)
+	}
+	if !errors.Is(err, ErrStoreNotFound) {
+		return err
+	}
+
+	if _, err := tx.Exec("INSERT INTO store_aliases (namespace, alias, store_id) VALUES (?, ?, ?)", s.namespace, alias, storeID); err != nil {
+		return fmt.Errorf("failed to add alias: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveStoreAlias removes an alias. The store itself is not affected.
+func (s *SQLiteStore) RemoveStoreAlias(alias string) error {
+	return retryOnBusy(func() error {
+		return s.removeStoreAlias(alias)
+	})
+}
+
+// removeStoreAlias performs RemoveStoreAlias without retrying.
+func (s *SQLiteStore) removeStoreAlias(alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.Exec("DELETE FROM store_aliases WHERE namespace = ? AND alias = ?", s.namespace, alias)
+	if err != nil {
+		return fmt.Errorf("failed to remove alias: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("alias not found: %s", alias)
+	}
+	return nil
+}
+
+// GetStoreAliases returns the aliases of a store in alphabetical order.
+func (s *SQLiteStore) GetStoreAliases(storeID int64) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query("SELECT alias FROM store_aliases WHERE store_id = ? ORDER BY alias", storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list aliases: %w", err)
+	}
+	defer rows.Close()
+
+	var aliases []string
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, fmt.Errorf("failed to scan alias: %w", err)
+		}
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, rows.Err()
+}
+
+// lookupStore resolves a store name or alias within a transaction, returning
+// the store's ID and current name.
+func (s *SQLiteStore) lookupStore(tx *sql.Tx, name string) (int64, string, error) {
+	var id int64
+	var current string
+	err := tx.QueryRow("SELECT id, name FROM stores WHERE id = "+storeIDByName, s.nameArgs(name)...).Scan(&id, &current)
+	if err == sql.ErrNoRows {
+		return 0, "", fmt.Errorf("%w: %s", ErrStoreNotFound, name)
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get store: %w", err)
+	}
+	return id, current, nil
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/mattn/go-sqlite3"
+)
+
+// BackupDatabase copies the database at dbPath to a new file at destPath
+// with SQLite's online backup API. Unlike copying the file, this gives a
+// consistent copy while watchers or an MCP server write to the database,
+// including changes still in its write-ahead log. The copy covers every
+// namespace.
+func BackupDatabase(ctx context.Context, dbPath, destPath string) error {
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("database not found: %w", err)
+	}
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("backup file already exists: %s", destPath)
+	}
+
+	src := fmt.Sprintf("file:%s?mode=ro&_busy_timeout=%d", dbPath, busyTimeout.Milliseconds())
+	dest := fmt.Sprintf("file:%s?_busy_timeout=%d", destPath, busyTimeout.Milliseconds())
+	if err := copyDatabase(ctx, src, dest); err != nil {
+		os.Remove(destPath)
+		return err
+	}
+
+	// The copy takes the database's journal mode; leave it a single file
+	db, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("PRAGMA journal_mode=DELETE"); err != nil {
+		return fmt.Errorf("failed to set journal mode of backup: %w", err)
+	}
+	return nil
+}
+
+// RestoreDatabase replaces the contents of the database at dbPath with the
+// backup at srcPath, using the online backup API so processes that have the
+// database open see the restored contents rather than a corrupt file. The
+// backup must be an lgrep database no newer than this version of lgrep; it
+// is migrated when next opened.
+func RestoreDatabase(ctx context.Context, srcPath, dbPath string) error {
+	src := fmt.Sprintf("file:%s?mode=ro&_busy_timeout=%d", srcPath, busyTimeout.Milliseconds())
+
+	if _, err := os.Stat(srcPath); err != nil {
+		return fmt.Errorf("backup not found: %w", err)
+	}
+	db, err := sql.Open("sqlite3", src)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	version, err := schemaVersion(db)
+	db.Close()
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to read backup: %w", err)
+	case version == 0:
+		return fmt.Errorf("not an lgrep database: %s", srcPath)
+	case version > currentSchemaVersion:
+		return fmt.Errorf("backup is from a newer version of lgrep (schema %d, this version supports %d)", version, currentSchemaVersion)
+	}
+
+	dest := fmt.Sprintf("file:%s?_busy_timeout=%d", dbPath, busyTimeout.Milliseconds())
+	return copyDatabase(ctx, src, dest)
+}
+
+// copyDatabase copies the main database of the src DSN over that of the
+// dest DSN, waiting while either is locked by another connection.
+func copyDatabase(ctx context.Context, src, dest string) error {
+	srcDB, err := sql.Open("sqlite3", src)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer srcDB.Close()
+	destDB, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer destDB.Close()
+
+	srcConn, err := srcDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer srcConn.Close()
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer destConn.Close()
+
+	return destConn.Raw(func(destRaw any) error {
+		return srcConn.Raw(func(srcRaw any) error {
+			backup, err := destRaw.(*sqlite3.SQLiteConn).Backup("main", srcRaw.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+			defer backup.Close()
+
+			// Copy all pages in one step, so writes by other processes
+			// cannot restart the copy. A step only fails to finish while
+			// a database is locked.
+			for {
+				done, err := backup.Step(-1)
+				if err != nil {
+					return fmt.Errorf("failed to copy database: %w", err)
+				}
+				if done {
+					break
+				}
+
+				log.Debug("Database locked, retrying backup")
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(busyRetryDelay):
+				}
+			}
+
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
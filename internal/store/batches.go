@@ -0,0 +1,86 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AddEmbeddingBatch records a submitted embedding batch.
+func (s *SQLiteStore) AddEmbeddingBatch(b *EmbeddingBatch) error {
+	return retryOnBusy(func() error {
+		return s.addEmbeddingBatch(b)
+	})
+}
+
+// addEmbeddingBatch performs AddEmbeddingBatch without retrying.
+func (s *SQLiteStore) addEmbeddingBatch(b *EmbeddingBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := json.Marshal(b.Files)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch files: %w", err)
+	}
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = time.Now().UTC()
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO embedding_batches (store_id, job_id, files, created_at)
+		VALUES (?, ?, ?, ?)
+	`, b.StoreID, b.JobID, string(files), b.CreatedAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to insert embedding batch: %w", err)
+	}
+
+	b.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// ListEmbeddingBatches returns the pending embedding batches of a store,
+// oldest first.
+func (s *SQLiteStore) ListEmbeddingBatches(storeID int64) ([]EmbeddingBatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT id, store_id, job_id, files, created_at
+		FROM embedding_batches WHERE store_id = ?
+		ORDER BY id
+	`, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embedding batches: %w", err)
+	}
+	defer rows.Close()
+
+	var batches []EmbeddingBatch
+	for rows.Next() {
+		var b EmbeddingBatch
+		var files, createdAt string
+		if err := rows.Scan(&b.ID, &b.StoreID, &b.JobID, &files, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding batch: %w", err)
+		}
+		if err := json.Unmarshal([]byte(files), &b.Files); err != nil {
+			return nil, fmt.Errorf("failed to decode batch files: %w", err)
+		}
+		b.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		batches = append(batches, b)
+	}
+
+	return batches, rows.Err()
+}
+
+// DeleteEmbeddingBatch removes a batch once its results are written or it
+// has failed.
+func (s *SQLiteStore) DeleteEmbeddingBatch(id int64) error {
+	return retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, err := s.db.Exec("DELETE FROM embedding_batches WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete embedding batch: %w", err)
+		}
+		return nil
+	})
+}
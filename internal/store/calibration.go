@@ -0,0 +1,232 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nickcecere/lgrep/internal/config"
+)
+
+const (
+	// calibrationSamples is the number of chunks compared pairwise to
+	// calibrate a store's scores.
+	calibrationSamples = 200
+
+	// minCalibrationSamples is the fewest chunks worth calibrating with;
+	// smaller stores use uncalibrated relevance.
+	minCalibrationSamples = 16
+
+	// recalibrateChange is the fraction by which the chunk count of a store
+	// must change before its scores are calibrated again.
+	recalibrateChange = 0.2
+)
+
+// CalibrateScores samples the chunk embeddings of a store, records the
+// distribution of their pairwise similarity under the store's distance
+// metric as its score calibration and returns it. A store with too few
+// chunks is not calibrated and nil is returned.
+//
+// Sampling is skipped, and the existing calibration returned, if the store
+// was calibrated with its current metric and its chunk count has changed by
+// less than recalibrateChange since.
+func (s *SQLiteStore) CalibrateScores(storeID int64) (*ScoreCalibration, error) {
+	metric, chunks, err := s.calibrationInputs(storeID)
+	if err != nil {
+		return nil, err
+	}
+	if c, err := s.GetScoreCalibration(storeID); err != nil {
+		return nil, err
+	} else if c != nil && c.Metric == metric && math.Abs(float64(chunks-c.Chunks)) < recalibrateChange*float64(c.Chunks) {
+		return c, nil
+	}
+
+	vectors, err := s.sampleVectors(storeID, calibrationSamples)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) < minCalibrationSamples {
+		return nil, nil
+	}
+
+	scores := make([]float64, 0, len(vectors)*(len(vectors)-1)/2)
+	for i := range vectors {
+		for j := i + 1; j < len(vectors); j++ {
+			scores = append(scores, similarity(metric, vectors[i], vectors[j]))
+		}
+	}
+	sort.Float64s(scores)
+
+	c := &ScoreCalibration{
+		StoreID:   storeID,
+		Samples:   len(vectors),
+		Chunks:    chunks,
+		Metric:    metric,
+		Baseline:  scores[len(scores)/2],
+		High:      scores[len(scores)*99/100],
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	err = retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		_, err := s.db.Exec(`
+			INSERT OR REPLACE INTO score_calibration (store_id, samples, chunks, metric, baseline, high, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, c.StoreID, c.Samples, c.Chunks, c.Metric, c.Baseline, c.High, c.UpdatedAt.Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to save score calibration: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetScoreCalibration returns the score calibration of a store, or nil if it
+// has not been calibrated.
+func (s *SQLiteStore) GetScoreCalibration(storeID int64) (*ScoreCalibration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c := &ScoreCalibration{StoreID: storeID}
+	var updatedAt string
+	err := s.db.QueryRow(`
+		SELECT samples, chunks, metric, baseline, high, updated_at FROM score_calibration WHERE store_id = ?
+	`, storeID).Scan(&c.Samples, &c.Chunks, &c.Metric, &c.Baseline, &c.High, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get score calibration: %w", err)
+	}
+	c.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	return c, nil
+}
+
+// calibrationInputs returns the distance metric and chunk count of a store.
+func (s *SQLiteStore) calibrationInputs(storeID int64) (config.DistanceMetric, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var metric config.DistanceMetric
+	var chunks int
+	err := s.db.QueryRow(`
+		SELECT distance_metric, (SELECT COUNT(*) FROM chunks c JOIN files f ON f.id = c.file_id WHERE f.store_id = stores.id)
+		FROM stores WHERE id = ?
+	`, storeID).Scan(&metric, &chunks)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read store for calibration: %w", err)
+	}
+	return metric, chunks, nil
+}
+
+// sampleVectors returns the embeddings of up to n chunks of a store, chosen
+// at random.
+func (s *SQLiteStore) sampleVectors(storeID int64, n int) ([][]float32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT embedding FROM chunk_vectors
+		WHERE chunk_id IN (
+			SELECT c.id FROM chunks c JOIN files f ON f.id = c.file_id
+			WHERE f.store_id = ?
+			ORDER BY random()
+			LIMIT ?
+		)
+	`, storeID, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample vectors: %w", err)
+	}
+	defer rows.Close()
+
+	var vectors [][]float32
+	for rows.Next() {
+		var blob []byte
+		if err := rows.Scan(&blob); err != nil {
+			return nil, fmt.Errorf("failed to scan vector: %w", err)
+		}
+		vectors = append(vectors, deserializeEmbedding(blob))
+	}
+	return vectors, rows.Err()
+}
+
+// Relevance maps a similarity score to a relevance from 0 to 100. A score at
+// the store's baseline, as similar as two unrelated chunks typically are,
+// or below maps to 0; one at the 99th percentile of unrelated chunks to 50;
+// and a perfect match to 100. Without a calibration, the score is scaled
+// directly.
+func (c *ScoreCalibration) Relevance(score float64) float64 {
+	if !c.valid() {
+		return math.Max(0, math.Min(100, score*100))
+	}
+	switch {
+	case score <= c.Baseline:
+		return 0
+	case score <= c.High:
+		return 50 * (score - c.Baseline) / (c.High - c.Baseline)
+	case score >= 1:
+		return 100
+	}
+	return 50 + 50*(score-c.High)/(1-c.High)
+}
+
+// MinScore returns the similarity score with the given relevance, so a
+// relevance threshold can be applied in the vector query.
+func (c *ScoreCalibration) MinScore(relevance float64) float64 {
+	relevance = math.Max(0, math.Min(100, relevance))
+	if !c.valid() {
+		return relevance / 100
+	}
+	if relevance <= 50 {
+		return c.Baseline + (c.High-c.Baseline)*relevance/50
+	}
+	return c.High + (1-c.High)*(relevance-50)/50
+}
+
+// valid reports whether the calibration can be used; a store of
+// near-identical chunks yields a degenerate one.
+func (c *ScoreCalibration) valid() bool {
+	return c != nil && c.Baseline < c.High && c.High < 1
+}
+
+// similarity returns the score Search gives b as a result for the query a
+// under metric.
+func similarity(metric config.DistanceMetric, a, b []float32) float64 {
+	switch metric {
+	case config.MetricL2:
+		var d2 float64
+		for i := range min(len(a), len(b)) {
+			d := float64(a[i]) - float64(b[i])
+			d2 += d * d
+		}
+		return 1 - d2/2
+	case config.MetricDot:
+		var dot float64
+		for i := range min(len(a), len(b)) {
+			dot += float64(a[i]) * float64(b[i])
+		}
+		return dot
+	}
+	return cosineSimilarity(a, b)
+}
+
+// cosineSimilarity returns the cosine similarity of a and b.
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range min(len(a), len(b)) {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// AddFeedback records a judgment of a search result.
+func (s *SQLiteStore) AddFeedback(f *Feedback) error {
+	return retryOnBusy(func() error {
+		return s.addFeedback(f)
+	})
+}
+
+// addFeedback performs AddFeedback without retrying.
+func (s *SQLiteStore) addFeedback(f *Feedback) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = time.Now().UTC()
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO feedback (store_id, relative_path, start_line, end_line, query, good, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, f.StoreID, f.RelativePath, f.StartLine, f.EndLine, f.Query, f.Good, f.CreatedAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to insert feedback: %w", err)
+	}
+
+	f.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// ListFeedback returns the judgments of a store's search results, oldest
+// first.
+func (s *SQLiteStore) ListFeedback(storeID int64) ([]Feedback, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT id, store_id, relative_path, start_line, end_line, query, good, created_at
+		FROM feedback WHERE store_id = ? ORDER BY id
+	`, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feedback: %w", err)
+	}
+	defer rows.Close()
+
+	var feedback []Feedback
+	for rows.Next() {
+		var f Feedback
+		var createdAt string
+		if err := rows.Scan(&f.ID, &f.StoreID, &f.RelativePath, &f.StartLine, &f.EndLine,
+			&f.Query, &f.Good, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feedback: %w", err)
+		}
+		f.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		feedback = append(feedback, f)
+	}
+
+	return feedback, rows.Err()
+}
+
+// CountFeedback returns the number of good and bad judgments of each file
+// of a store with any, keyed by relative path.
+func (s *SQLiteStore) CountFeedback(storeID int64) (map[string]FeedbackCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT relative_path, SUM(good), SUM(1 - good)
+		FROM feedback WHERE store_id = ? GROUP BY relative_path
+	`, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count feedback: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]FeedbackCount)
+	for rows.Next() {
+		var path string
+		var c FeedbackCount
+		if err := rows.Scan(&path, &c.Good, &c.Bad); err != nil {
+			return nil, fmt.Errorf("failed to scan feedback: %w", err)
+		}
+		counts[path] = c
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,55 @@
+package store
+
+import "fmt"
+
+// GetFileImports returns the imports recorded for a file, in the order they
+// were recorded.
+func (s *SQLiteStore) GetFileImports(fileID int64) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query("SELECT target FROM file_imports WHERE file_id = ? ORDER BY rowid", fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get imports: %w", err)
+	}
+	defer rows.Close()
+
+	var imports []string
+	for rows.Next() {
+		var target string
+		if err := rows.Scan(&target); err != nil {
+			return nil, fmt.Errorf("failed to scan import: %w", err)
+		}
+		imports = append(imports, target)
+	}
+	return imports, rows.Err()
+}
+
+// ListStoreImports returns the imports recorded for each file of a store,
+// by file ID.
+func (s *SQLiteStore) ListStoreImports(storeID int64) (map[int64][]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT i.file_id, i.target FROM file_imports i
+		JOIN files f ON f.id = i.file_id
+		WHERE f.store_id = ?
+		ORDER BY i.rowid
+	`, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list imports: %w", err)
+	}
+	defer rows.Close()
+
+	imports := make(map[int64][]string)
+	for rows.Next() {
+		var fileID int64
+		var target string
+		if err := rows.Scan(&fileID, &target); err != nil {
+			return nil, fmt.Errorf("failed to scan import: %w", err)
+		}
+		imports[fileID] = append(imports[fileID], target)
+	}
+	return imports, rows.Err()
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+// AddIndexRun records an index run. Host and PID default to the current
+// process, the start time to now and the status to RunRunning; a run added
+// with a finish time is recorded as complete.
+func (s *SQLiteStore) AddIndexRun(r *IndexRun) error {
+	return retryOnBusy(func() error {
+		return s.addIndexRun(r)
+	})
+}
+
+// addIndexRun performs AddIndexRun without retrying.
+func (s *SQLiteStore) addIndexRun(r *IndexRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Host == "" {
+		r.Host, _ = os.Hostname()
+	}
+	if r.PID == 0 {
+		r.PID = os.Getpid()
+	}
+	if r.StartedAt.IsZero() {
+		r.StartedAt = time.Now().UTC()
+	}
+	if r.Status == "" {
+		r.Status = RunRunning
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO index_runs (store_id, trigger, host, pid, started_at, finished_at, files, skipped, removed, errors, status, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, r.StoreID, r.Trigger, r.Host, r.PID, r.StartedAt.UTC().Format(time.RFC3339), formatOptionalTime(r.FinishedAt),
+		r.Files, r.Skipped, r.Removed, r.Errors, r.Status, r.Error)
+	if err != nil {
+		return fmt.Errorf("failed to insert index run: %w", err)
+	}
+
+	r.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// FinishIndexRun records the outcome of a run added with AddIndexRun. The
+// finish time defaults to now.
+func (s *SQLiteStore) FinishIndexRun(r *IndexRun) error {
+	return retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.FinishedAt.IsZero() {
+			r.FinishedAt = time.Now().UTC()
+		}
+
+		_, err := s.db.Exec(`
+			UPDATE index_runs SET finished_at = ?, files = ?, skipped = ?, removed = ?, errors = ?, status = ?, error = ?
+			WHERE id = ?
+		`, formatOptionalTime(r.FinishedAt), r.Files, r.Skipped, r.Removed, r.Errors, r.Status, r.Error, r.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update index run: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListIndexRuns returns the most recent index runs of a store, newest first.
+func (s *SQLiteStore) ListIndexRuns(storeID int64, limit int) ([]IndexRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `
+		SELECT id, store_id, trigger, host, pid, started_at, finished_at, files, skipped, removed, errors, status, error
+		FROM index_runs WHERE store_id = ? ORDER BY id DESC`
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := s.db.Query(query, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list index runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []IndexRun
+	for rows.Next() {
+		var r IndexRun
+		var startedAt string
+		var finishedAt sql.NullString
+		if err := rows.Scan(
+			&r.ID, &r.StoreID, &r.Trigger, &r.Host, &r.PID, &startedAt, &finishedAt,
+			&r.Files, &r.Skipped, &r.Removed, &r.Errors, &r.Status, &r.Error,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan index run: %w", err)
+		}
+		r.StartedAt, _ = time.Parse(time.RFC3339, startedAt)
+		if finishedAt.Valid {
+			r.FinishedAt, _ = time.Parse(time.RFC3339, finishedAt.String)
+		}
+		runs = append(runs, r)
+	}
+
+	return runs, rows.Err()
+}
+
+// formatOptionalTime formats t for storage, or returns NULL if it is zero.
+func formatOptionalTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(time.RFC3339), Valid: true}
+}
@@ -0,0 +1,357 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+)
+
+// ErrCorrupt is returned by RepairIntegrity for a database that SQLite
+// itself reports as corrupt.
+var ErrCorrupt = errors.New("database is corrupt")
+
+// CheckIntegrity runs SQLite's quick_check and looks for chunks and vectors
+// that lost their file or chunk, chunks without a vector, and a missing
+// vector table. It covers every namespace.
+func (s *SQLiteStore) CheckIntegrity() (*IntegrityReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.checkIntegrity()
+}
+
+// checkIntegrity performs CheckIntegrity; the caller holds s.mu.
+func (s *SQLiteStore) checkIntegrity() (*IntegrityReport, error) {
+	r := &IntegrityReport{}
+
+	rows, err := s.db.Query("PRAGMA quick_check")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check database: %w", err)
+	}
+	for rows.Next() {
+		var message string
+		if err := rows.Scan(&message); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to check database: %w", err)
+		}
+		if message != "ok" {
+			r.Corruption = append(r.Corruption, message)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check database: %w", err)
+	}
+	if len(r.Corruption) > 0 {
+		// Queries on a corrupt database can fail or mislead
+		return r, nil
+	}
+
+	hasVectors, err := hasVectorTable(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM chunks WHERE file_id NOT IN (SELECT id FROM files)").Scan(&r.OrphanChunks); err != nil {
+		return nil, fmt.Errorf("failed to count orphaned chunks: %w", err)
+	}
+	missing := "SELECT COUNT(*), COUNT(embedding) FROM chunks"
+	if hasVectors {
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM chunk_vectors WHERE chunk_id NOT IN (SELECT id FROM chunks)").Scan(&r.OrphanVectors); err != nil {
+			return nil, fmt.Errorf("failed to count orphaned vectors: %w", err)
+		}
+		missing += " WHERE id NOT IN (SELECT chunk_id FROM chunk_vectors)"
+	}
+	if err := s.db.QueryRow(missing).Scan(&r.MissingVectors, &r.StoredVectors); err != nil {
+		return nil, fmt.Errorf("failed to count chunks without vectors: %w", err)
+	}
+	r.MissingVectorTable = !hasVectors && r.MissingVectors > 0
+
+	return r, nil
+}
+
+// RepairIntegrity checks the database like CheckIntegrity and fixes what it
+// finds, returning the problems found. Orphaned chunks and vectors are
+// dropped and a missing vector table is created again. Chunks that lack a
+// vector get it back from their stored embedding; files with chunks that
+// have none are dropped from their store, so the next index run embeds them
+// again. A corrupt database is not touched; ErrCorrupt is returned instead.
+func (s *SQLiteStore) RepairIntegrity() (*IntegrityReport, error) {
+	var report *IntegrityReport
+	err := retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		r, err := s.checkIntegrity()
+		if err != nil {
+			return err
+		}
+		report = r
+		if len(r.Corruption) > 0 {
+			return fmt.Errorf("%w: %s", ErrCorrupt, r.Corruption[0])
+		}
+		if r.OK() {
+			return nil
+		}
+		return s.repair(r)
+	})
+	return report, err
+}
+
+// repair fixes the problems in r; the caller holds s.mu.
+func (s *SQLiteStore) repair(r *IntegrityReport) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if r.MissingVectorTable {
+		dimensions, err := storeDimensions(tx)
+		if err != nil {
+			return err
+		}
+		log.Info("Creating missing vector table", "dimensions", dimensions)
+		if _, err := tx.Exec(vectorTableSQL(dimensions)); err != nil {
+			return fmt.Errorf("failed to create vector table: %w", err)
+		}
+	}
+
+	dimensions, err := vectorTableDimensions(tx)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(restoreVectorsSQL, 4*dimensions); err != nil {
+		return fmt.Errorf("failed to restore vectors: %w", err)
+	}
+
+	// Files are indexed again once they are gone. Their chunks and vectors
+	// are then removed as orphans, without relying on foreign keys, which
+	// are what failed if there are orphans.
+	statements := []string{
+		"DELETE FROM files WHERE id IN (SELECT file_id FROM chunks WHERE id NOT IN (SELECT chunk_id FROM chunk_vectors))",
+		"DELETE FROM chunks WHERE file_id NOT IN (SELECT id FROM files)",
+		"DELETE FROM chunk_vectors WHERE chunk_id NOT IN (SELECT id FROM chunks)",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to repair database: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// restoreVectorsSQL writes the vectors of chunks that lack one from their
+// stored embeddings. Its argument is the size in bytes of the vector
+// table's embeddings; embeddings of another size are skipped.
+const restoreVectorsSQL = `
+	INSERT INTO chunk_vectors (chunk_id, store_id, embedding)
+	SELECT c.id, f.store_id, c.embedding FROM chunks c
+	JOIN files f ON f.id = c.file_id
+	WHERE c.embedding IS NOT NULL AND length(c.embedding) = ?
+		AND c.id NOT IN (SELECT chunk_id FROM chunk_vectors)
+`
+
+// RebuildVectors drops the vector table and creates it again from the
+// embeddings stored with the chunks, without calling the embedding
+// provider, e.g. when the vector table is corrupt. It returns the number of
+// vectors written. Chunks indexed before embeddings were stored are left
+// without a vector; RepairIntegrity drops their files so they are indexed
+// again.
+func (s *SQLiteStore) RebuildVectors() (int, error) {
+	var rebuilt int
+	err := retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		dimensions, err := vectorTableDimensions(tx)
+		if err != nil {
+			return err
+		}
+		if dimensions == 0 {
+			if dimensions, err = storeDimensions(tx); err != nil {
+				return err
+			}
+			if dimensions == 0 {
+				return nil // Nothing indexed
+			}
+		}
+
+		log.Info("Rebuilding vector table from stored embeddings", "dimensions", dimensions)
+		if _, err := tx.Exec("DROP TABLE IF EXISTS chunk_vectors"); err != nil {
+			return fmt.Errorf("failed to drop vector table: %w", err)
+		}
+		if _, err := tx.Exec(vectorTableSQL(dimensions)); err != nil {
+			return fmt.Errorf("failed to create vector table: %w", err)
+		}
+		result, err := tx.Exec(restoreVectorsSQL, 4*dimensions)
+		if err != nil {
+			return fmt.Errorf("failed to restore vectors: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		rebuilt = int(n)
+
+		return tx.Commit()
+	})
+	return rebuilt, err
+}
+
+// ResizeVectors recreates the vector table with vectors of the given
+// dimensions, made by resize from the embeddings stored with the chunks,
+// e.g. when a Matryoshka model's embeddings are truncated further. No
+// embedding provider is called. The stored embeddings are replaced too, and
+// every store is set to the new dimensions, since the stores share the
+// vector table; so they must all use the same model. It fails if a chunk
+// has no stored embedding or one with fewer dimensions. It returns the
+// number of vectors written.
+func (s *SQLiteStore) ResizeVectors(dimensions int, resize func([]float32) []float32) (int, error) {
+	var resized int
+	err := retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		var models, missing, short int
+		if err := tx.QueryRow("SELECT COUNT(DISTINCT embedding_provider || ' ' || embedding_model) FROM stores").Scan(&models); err != nil {
+			return fmt.Errorf("failed to read embedding models: %w", err)
+		}
+		if models > 1 {
+			return errors.New("cannot resize vectors: stores use different embedding models")
+		}
+		if err := tx.QueryRow("SELECT COUNT(*) - COUNT(embedding), COUNT(CASE WHEN length(embedding) < ? THEN 1 END) FROM chunks",
+			4*dimensions).Scan(&missing, &short); err != nil {
+			return fmt.Errorf("failed to check stored embeddings: %w", err)
+		}
+		if missing > 0 || short > 0 {
+			return fmt.Errorf("cannot resize vectors: %d chunks have no stored embedding and %d one of fewer than %d dimensions",
+				missing, short, dimensions)
+		}
+
+		log.Info("Resizing vectors from stored embeddings", "dimensions", dimensions)
+		if _, err := tx.Exec("DROP TABLE IF EXISTS chunk_vectors"); err != nil {
+			return fmt.Errorf("failed to drop vector table: %w", err)
+		}
+		if _, err := tx.Exec(vectorTableSQL(dimensions)); err != nil {
+			return fmt.Errorf("failed to create vector table: %w", err)
+		}
+
+		rows, err := tx.Query("SELECT c.id, f.store_id, c.embedding FROM chunks c JOIN files f ON f.id = c.file_id")
+		if err != nil {
+			return fmt.Errorf("failed to read stored embeddings: %w", err)
+		}
+		defer rows.Close()
+		resized = 0
+		for rows.Next() {
+			var chunkID, storeID int64
+			var blob []byte
+			if err := rows.Scan(&chunkID, &storeID, &blob); err != nil {
+				return fmt.Errorf("failed to read stored embedding: %w", err)
+			}
+			embedding := serializeEmbedding(resize(deserializeEmbedding(blob)))
+			if _, err := tx.Exec("UPDATE chunks SET embedding = ? WHERE id = ?", embedding, chunkID); err != nil {
+				return fmt.Errorf("failed to store embedding: %w", err)
+			}
+			if _, err := tx.Exec("INSERT INTO chunk_vectors (chunk_id, store_id, embedding) VALUES (?, ?, ?)", chunkID, storeID, embedding); err != nil {
+				return fmt.Errorf("failed to insert vector: %w", err)
+			}
+			resized++
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read stored embeddings: %w", err)
+		}
+
+		if _, err := tx.Exec("UPDATE stores SET embedding_dimensions = ?", dimensions); err != nil {
+			return fmt.Errorf("failed to update stores: %w", err)
+		}
+		// Scores of the resized vectors are calibrated again
+		if _, err := tx.Exec("DELETE FROM score_calibration"); err != nil {
+			return fmt.Errorf("failed to clear score calibration: %w", err)
+		}
+
+		return tx.Commit()
+	})
+	return resized, err
+}
+
+// storeDimensions returns the embedding dimensions of the stores, to create
+// the vector table with, or 0 if there are no stores. The table holds
+// vectors of one size, so stores of different dimensions are an error
+// rather than a guess that would leave the others unsearchable.
+func storeDimensions(tx *sql.Tx) (int, error) {
+	rows, err := tx.Query("SELECT DISTINCT embedding_dimensions FROM stores ORDER BY embedding_dimensions")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read embedding dimensions: %w", err)
+	}
+	defer rows.Close()
+
+	var dimensions []int
+	for rows.Next() {
+		var d int
+		if err := rows.Scan(&d); err != nil {
+			return 0, fmt.Errorf("failed to read embedding dimensions: %w", err)
+		}
+		dimensions = append(dimensions, d)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read embedding dimensions: %w", err)
+	}
+
+	switch len(dimensions) {
+	case 0:
+		return 0, nil
+	case 1:
+		return dimensions[0], nil
+	default:
+		return 0, fmt.Errorf("cannot create the vector table: stores have different embedding dimensions %v; delete and index again the stores of all but one", dimensions)
+	}
+}
+
+// vectorTableDimensions returns the dimensions of the vector table, or 0 if
+// there is none.
+func vectorTableDimensions(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}) (int, error) {
+	var tableSQL string
+	err := q.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name='chunk_vectors'").Scan(&tableSQL)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to check vector table: %w", err)
+	}
+
+	match := vectorDimensions.FindStringSubmatch(tableSQL)
+	if match == nil {
+		return 0, fmt.Errorf("failed to read vector dimensions from %q", tableSQL)
+	}
+	dimensions, _ := strconv.Atoi(match[1])
+	return dimensions, nil
+}
+
+// hasVectorTable reports whether the vector table exists.
+func hasVectorTable(db *sql.DB) (bool, error) {
+	var name string
+	err := db.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name='chunk_vectors'").Scan(&name)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check vector table: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// AddMetric records a local timing metric. Metrics are best-effort: on a
+// read-only store the metric is silently dropped.
+func (s *SQLiteStore) AddMetric(m *Metric) error {
+	if s.readOnly {
+		return nil
+	}
+	return retryOnBusy(func() error {
+		return s.addMetric(m)
+	})
+}
+
+// addMetric performs AddMetric without retrying.
+func (s *SQLiteStore) addMetric(m *Metric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now().UTC()
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO metrics (namespace, event, store_name, duration_ms, embed_ms, db_ms, count,
+			input_tokens, output_tokens, cost, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.namespace, m.Event, m.StoreName, m.Duration.Milliseconds(), m.Embed.Milliseconds(), m.DB.Milliseconds(),
+		m.Count, m.InputTokens, m.OutputTokens, m.Cost, m.CreatedAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to insert metric: %w", err)
+	}
+
+	m.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// SummarizeMetrics aggregates metrics recorded in the namespace since the
+// given time by event.
+func (s *SQLiteStore) SummarizeMetrics(since time.Time) ([]MetricSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.summarizeMetrics("event, ''", "", since)
+}
+
+// SummarizeMetricsByStore aggregates the metrics of one event recorded in
+// the namespace since the given time by store, ordered by store name.
+func (s *SQLiteStore) SummarizeMetricsByStore(event string, since time.Time) ([]MetricSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.summarizeMetrics("event, store_name", event, since)
+}
+
+// summarizeMetrics aggregates metrics grouped by groupBy, the event and the
+// store name or an empty string, keeping only event if it is set. The caller
+// must hold s.mu.
+func (s *SQLiteStore) summarizeMetrics(groupBy, event string, since time.Time) ([]MetricSummary, error) {
+	query := `
+		SELECT ` + groupBy + `, COUNT(*), AVG(duration_ms), AVG(embed_ms), AVG(db_ms), MAX(duration_ms), SUM(count),
+			SUM(input_tokens), SUM(output_tokens), SUM(cost)
+		FROM metrics WHERE namespace = ? AND created_at >= ?`
+	args := []any{s.namespace, since.UTC().Format(time.RFC3339)}
+	if event != "" {
+		query += ` AND event = ?`
+		args = append(args, event)
+	}
+	query += ` GROUP BY ` + groupBy + ` ORDER BY ` + groupBy
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []MetricSummary
+	for rows.Next() {
+		var m MetricSummary
+		var avgDuration, avgEmbed, avgDB float64
+		var maxDuration int64
+		if err := rows.Scan(&m.Event, &m.StoreName, &m.Runs, &avgDuration, &avgEmbed, &avgDB, &maxDuration, &m.TotalCount,
+			&m.InputTokens, &m.OutputTokens, &m.Cost); err != nil {
+			return nil, fmt.Errorf("failed to scan metric: %w", err)
+		}
+		m.AvgDuration = msDuration(avgDuration)
+		m.AvgEmbed = msDuration(avgEmbed)
+		m.AvgDB = msDuration(avgDB)
+		m.MaxDuration = time.Duration(maxDuration) * time.Millisecond
+		summaries = append(summaries, m)
+	}
+
+	return summaries, rows.Err()
+}
+
+// msDuration converts fractional milliseconds to a duration.
+func msDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
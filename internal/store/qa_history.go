@@ -0,0 +1,140 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// qaColumns is the column list shared by Q&A history queries.
+const qaColumns = `id, store_name, question, answer, provider, model, sources, context_hash, latency_ms, created_at`
+
+// AddQATranscript records a Q&A exchange in the history log.
+func (s *SQLiteStore) AddQATranscript(t *QATranscript) error {
+	return retryOnBusy(func() error {
+		return s.addQATranscript(t)
+	})
+}
+
+// addQATranscript performs AddQATranscript without retrying.
+func (s *SQLiteStore) addQATranscript(t *QATranscript) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sources, err := json.Marshal(t.Sources)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sources: %w", err)
+	}
+
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now().UTC()
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO qa_history (namespace, store_name, question, answer, provider, model, sources, context_hash, latency_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.namespace, t.StoreName, t.Question, t.Answer, t.Provider, t.Model, string(sources), t.ContextHash,
+		t.Latency.Milliseconds(), t.CreatedAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to insert transcript: %w", err)
+	}
+
+	t.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// GetQATranscript retrieves a Q&A transcript by ID.
+func (s *SQLiteStore) GetQATranscript(id int64) (*QATranscript, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row := s.db.QueryRow(`SELECT `+qaColumns+` FROM qa_history WHERE id = ? AND namespace = ?`, id, s.namespace)
+	t, err := scanQATranscript(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transcript: %w", err)
+	}
+
+	return t, nil
+}
+
+// ListQATranscripts returns the most recent Q&A transcripts, newest first.
+func (s *SQLiteStore) ListQATranscripts(limit int) ([]QATranscript, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + qaColumns + ` FROM qa_history WHERE namespace = ? ORDER BY id DESC`
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := s.db.Query(query, s.namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transcripts: %w", err)
+	}
+	defer rows.Close()
+
+	var transcripts []QATranscript
+	for rows.Next() {
+		t, err := scanQATranscript(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transcript: %w", err)
+		}
+		transcripts = append(transcripts, *t)
+	}
+
+	return transcripts, rows.Err()
+}
+
+// FindQATranscript returns the most recent transcript for the same store, model
+// and context hash, or nil if the question has not been answered before.
+func (s *SQLiteStore) FindQATranscript(storeName, model, contextHash string) (*QATranscript, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row := s.db.QueryRow(`
+		SELECT `+qaColumns+` FROM qa_history
+		WHERE namespace = ? AND store_name = ? AND model = ? AND context_hash = ?
+		ORDER BY id DESC LIMIT 1
+	`, s.namespace, storeName, model, contextHash)
+	t, err := scanQATranscript(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find transcript: %w", err)
+	}
+
+	return t, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanQATranscript scans a qa_history row selected with qaColumns.
+func scanQATranscript(row rowScanner) (*QATranscript, error) {
+	var t QATranscript
+	var sources, createdAt string
+	var latencyMs int64
+
+	if err := row.Scan(
+		&t.ID, &t.StoreName, &t.Question, &t.Answer,
+		&t.Provider, &t.Model, &sources, &t.ContextHash,
+		&latencyMs, &createdAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(sources), &t.Sources); err != nil {
+		return nil, fmt.Errorf("failed to parse sources: %w", err)
+	}
+	t.Latency = time.Duration(latencyMs) * time.Millisecond
+	t.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+
+	return &t, nil
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReplaceFileRelations replaces the relations between the files of a store.
+func (s *SQLiteStore) ReplaceFileRelations(storeID int64, relations []FileRelation) error {
+	return retryOnBusy(func() error {
+		return s.inTx(func(tx *sql.Tx) error {
+			_, err := tx.Exec(`
+				DELETE FROM file_relations
+				WHERE from_file_id IN (SELECT id FROM files WHERE store_id = ?)
+			`, storeID)
+			if err != nil {
+				return fmt.Errorf("failed to delete old relations: %w", err)
+			}
+			for _, r := range relations {
+				_, err := tx.Exec(`
+					INSERT OR IGNORE INTO file_relations (from_file_id, to_file_id) VALUES (?, ?)
+				`, r.FromFileID, r.ToFileID)
+				if err != nil {
+					return fmt.Errorf("failed to insert relation: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// UpdateFileRelations replaces the relations from the files fromFileIDs with
+// relations, which may also add relations from other files.
+func (s *SQLiteStore) UpdateFileRelations(fromFileIDs []int64, relations []FileRelation) error {
+	return retryOnBusy(func() error {
+		return s.inTx(func(tx *sql.Tx) error {
+			for _, id := range fromFileIDs {
+				if _, err := tx.Exec("DELETE FROM file_relations WHERE from_file_id = ?", id); err != nil {
+					return fmt.Errorf("failed to delete old relations: %w", err)
+				}
+			}
+			for _, r := range relations {
+				_, err := tx.Exec(`
+					INSERT OR IGNORE INTO file_relations (from_file_id, to_file_id) VALUES (?, ?)
+				`, r.FromFileID, r.ToFileID)
+				if err != nil {
+					return fmt.Errorf("failed to insert relation: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// GetRelatedFiles returns the files a file imports and the files that import
+// it, each ordered by path.
+func (s *SQLiteStore) GetRelatedFiles(fileID int64) (imports, importedBy []FileRecord, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	imports, err = s.relatedFiles(`
+		SELECT to_file_id FROM file_relations WHERE from_file_id = ?
+	`, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	importedBy, err = s.relatedFiles(`
+		SELECT from_file_id FROM file_relations WHERE to_file_id = ?
+	`, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return imports, importedBy, nil
+}
+
+// relatedFiles returns the files whose IDs idQuery selects for fileID.
+func (s *SQLiteStore) relatedFiles(idQuery string, fileID int64) ([]FileRecord, error) {
+	rows, err := s.db.Query(`
+		SELECT id, store_id, external_id, path, relative_path, hash, file_size, indexed_at, generated, language, modified_at
+		FROM files WHERE id IN (`+idQuery+`)
+		ORDER BY relative_path
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get related files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []FileRecord
+	for rows.Next() {
+		var record FileRecord
+		var indexedAt, modifiedAt string
+		if err := rows.Scan(
+			&record.ID, &record.StoreID, &record.ExternalID,
+			&record.Path, &record.RelativePath, &record.Hash,
+			&record.FileSize, &indexedAt, &record.Generated, &record.Language, &modifiedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		record.IndexedAt, _ = time.Parse(time.RFC3339, indexedAt)
+		record.ModifiedAt, _ = time.Parse(time.RFC3339, modifiedAt)
+		files = append(files, record)
+	}
+	return files, rows.Err()
+}
+
+// GetRelatedFileIDs returns the IDs of the files related to any of the given
+// files in either direction, other than the given files themselves.
+func (s *SQLiteStore) GetRelatedFileIDs(fileIDs []int64) ([]int64, error) {
+	if len(fileIDs) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	placeholders := strings.Repeat("?,", len(fileIDs))
+	placeholders = placeholders[:len(placeholders)-1]
+	args := make([]any, 0, len(fileIDs)*2)
+	for range 2 {
+		for _, id := range fileIDs {
+			args = append(args, id)
+		}
+	}
+
+	rows, err := s.db.Query(`
+		SELECT to_file_id FROM file_relations WHERE from_file_id IN (`+placeholders+`)
+		UNION
+		SELECT from_file_id FROM file_relations WHERE to_file_id IN (`+placeholders+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get related files: %w", err)
+	}
+	defer rows.Close()
+
+	given := make(map[int64]bool, len(fileIDs))
+	for _, id := range fileIDs {
+		given[id] = true
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan file ID: %w", err)
+		}
+		if !given[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// CountFileRelations returns the number of relations between the files of a
+// store.
+func (s *SQLiteStore) CountFileRelations(storeID int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM file_relations r
+		JOIN files f ON f.id = r.from_file_id
+		WHERE f.store_id = ?
+	`, storeID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count relations: %w", err)
+	}
+	return count, nil
+}
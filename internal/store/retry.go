@@ -0,0 +1,44 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	// maxBusyRetries is how many times a write is retried after SQLITE_BUSY.
+	maxBusyRetries = 5
+
+	// busyRetryDelay is the initial delay between retries; it doubles each time.
+	busyRetryDelay = 100 * time.Millisecond
+)
+
+// retryOnBusy runs fn, retrying with backoff while another connection holds
+// the database lock. The busy timeout covers most contention, but SQLite
+// returns SQLITE_BUSY immediately in some cases (e.g. a WAL checkpoint or a
+// stale read snapshot), which a retry resolves.
+func retryOnBusy(fn func() error) error {
+	delay := busyRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isBusy(err) || attempt >= maxBusyRetries {
+			return err
+		}
+
+		log.Debug("Database busy, retrying", "attempt", attempt+1, "delay", delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isBusy reports whether err is a SQLite busy or locked error.
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
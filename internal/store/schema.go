@@ -1,13 +1,17 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/charmbracelet/log"
 )
 
-const currentSchemaVersion = 1
+const currentSchemaVersion = 25
 
 // Schema definitions
 const schemaVersionTable = `
@@ -60,16 +64,225 @@ CREATE TABLE IF NOT EXISTS chunks (
 CREATE INDEX IF NOT EXISTS idx_chunks_file_id ON chunks(file_id);
 `
 
-// createVectorTable creates the sqlite-vec virtual table for the given dimensions.
-func createVectorTable(db *sql.DB, dimensions int) error {
-	query := fmt.Sprintf(`
+const qaHistoryTable = `
+CREATE TABLE IF NOT EXISTS qa_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	store_name TEXT NOT NULL,
+	question TEXT NOT NULL,
+	answer TEXT NOT NULL,
+	provider TEXT NOT NULL,
+	model TEXT NOT NULL,
+	sources TEXT NOT NULL,
+	context_hash TEXT NOT NULL,
+	latency_ms INTEGER NOT NULL,
+	created_at TEXT DEFAULT (datetime('now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_qa_history_cache ON qa_history(store_name, model, context_hash);
+`
+
+const usageTable = `
+CREATE TABLE IF NOT EXISTS usage (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	store_name TEXT NOT NULL,
+	operation TEXT NOT NULL,
+	provider TEXT NOT NULL,
+	model TEXT NOT NULL,
+	input_tokens INTEGER NOT NULL,
+	output_tokens INTEGER NOT NULL,
+	cost REAL NOT NULL,
+	created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_created_at ON usage(created_at);
+CREATE INDEX IF NOT EXISTS idx_usage_store_name ON usage(store_name);
+`
+
+const metricsTable = `
+CREATE TABLE IF NOT EXISTS metrics (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	event TEXT NOT NULL,
+	store_name TEXT NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	embed_ms INTEGER NOT NULL,
+	db_ms INTEGER NOT NULL,
+	count INTEGER NOT NULL,
+	created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_metrics_created_at ON metrics(created_at);
+`
+
+const storeAliasesTable = `
+CREATE TABLE IF NOT EXISTS store_aliases (
+	alias TEXT PRIMARY KEY,
+	store_id INTEGER NOT NULL REFERENCES stores(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_store_aliases_store_id ON store_aliases(store_id);
+`
+
+const embeddingBatchesTable = `
+CREATE TABLE IF NOT EXISTS embedding_batches (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	store_id INTEGER NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+	job_id TEXT NOT NULL,
+	files TEXT NOT NULL,
+	created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_embedding_batches_store_id ON embedding_batches(store_id);
+`
+
+const indexRunsTable = `
+CREATE TABLE IF NOT EXISTS index_runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	store_id INTEGER NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+	trigger TEXT NOT NULL,
+	host TEXT NOT NULL,
+	pid INTEGER NOT NULL,
+	started_at TEXT NOT NULL,
+	finished_at TEXT,
+	files INTEGER NOT NULL DEFAULT 0,
+	skipped INTEGER NOT NULL DEFAULT 0,
+	removed INTEGER NOT NULL DEFAULT 0,
+	errors INTEGER NOT NULL DEFAULT 0,
+	status TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_index_runs_store_id ON index_runs(store_id, id);
+`
+
+const scoreCalibrationTable = `
+CREATE TABLE IF NOT EXISTS score_calibration (
+	store_id INTEGER PRIMARY KEY REFERENCES stores(id) ON DELETE CASCADE,
+	samples INTEGER NOT NULL,
+	baseline REAL NOT NULL,
+	high REAL NOT NULL,
+	updated_at TEXT NOT NULL
+);
+`
+
+const chunkSymbolsTable = `
+CREATE TABLE IF NOT EXISTS chunk_symbols (
+	chunk_id INTEGER NOT NULL REFERENCES chunks(id) ON DELETE CASCADE,
+	name TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_chunk_symbols_name ON chunk_symbols(name);
+CREATE INDEX IF NOT EXISTS idx_chunk_symbols_chunk_id ON chunk_symbols(chunk_id);
+`
+
+const fileImportsTable = `
+CREATE TABLE IF NOT EXISTS file_imports (
+	file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+	target TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_imports_file_id ON file_imports(file_id);
+`
+
+const fileRelationsTable = `
+CREATE TABLE IF NOT EXISTS file_relations (
+	from_file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+	to_file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+	PRIMARY KEY(from_file_id, to_file_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_relations_to ON file_relations(to_file_id);
+`
+
+const searchLogTable = `
+CREATE TABLE IF NOT EXISTS search_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	store_id INTEGER NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+	query TEXT NOT NULL,
+	created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_log_store_id ON search_log(store_id, id);
+
+CREATE TABLE IF NOT EXISTS search_log_results (
+	search_id INTEGER NOT NULL REFERENCES search_log(id) ON DELETE CASCADE,
+	chunk_id INTEGER NOT NULL,
+	rank INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_log_results_chunk_id ON search_log_results(chunk_id);
+CREATE INDEX IF NOT EXISTS idx_search_log_results_search_id ON search_log_results(search_id);
+`
+
+const feedbackTable = `
+CREATE TABLE IF NOT EXISTS feedback (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	store_id INTEGER NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+	relative_path TEXT NOT NULL,
+	start_line INTEGER NOT NULL,
+	end_line INTEGER NOT NULL,
+	query TEXT NOT NULL,
+	good INTEGER NOT NULL,
+	created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_feedback_store_id ON feedback(store_id, relative_path);
+`
+
+const watchPendingTable = `
+CREATE TABLE IF NOT EXISTS watch_pending (
+	store_id INTEGER NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+	relative_path TEXT NOT NULL,
+	PRIMARY KEY (store_id, relative_path)
+);
+`
+
+const watchPausesTable = `
+CREATE TABLE IF NOT EXISTS watch_pauses (
+	store_id INTEGER PRIMARY KEY REFERENCES stores(id) ON DELETE CASCADE,
+	paused_at TEXT NOT NULL
+);
+`
+
+// namespacedTables recreate the stores and store_aliases tables with names
+// that are unique per namespace rather than globally.
+const namespacedTables = `
+CREATE TABLE stores_v9 (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	namespace TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL,
+	root_path TEXT NOT NULL,
+	embedding_provider TEXT NOT NULL,
+	embedding_model TEXT NOT NULL,
+	embedding_dimensions INTEGER NOT NULL,
+	created_at TEXT DEFAULT (datetime('now')),
+	updated_at TEXT DEFAULT (datetime('now')),
+	UNIQUE(namespace, name)
+);
+
+CREATE TABLE store_aliases_v9 (
+	namespace TEXT NOT NULL DEFAULT '',
+	alias TEXT NOT NULL,
+	store_id INTEGER NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+	PRIMARY KEY(namespace, alias)
+);
+`
+
+// vectorTableSQL returns the statement that creates the sqlite-vec virtual
+// table for the given dimensions. Vectors are partitioned by store, so a
+// search only examines the vectors of the store being searched.
+func vectorTableSQL(dimensions int) string {
+	return fmt.Sprintf(`
 		CREATE VIRTUAL TABLE IF NOT EXISTS chunk_vectors USING vec0(
 			chunk_id INTEGER PRIMARY KEY,
+			store_id INTEGER PARTITION KEY,
 			embedding float[%d] distance_metric=cosine
 		);
 	`, dimensions)
+}
 
-	_, err := db.Exec(query)
+// createVectorTable creates the sqlite-vec virtual table for the given dimensions.
+func createVectorTable(db *sql.DB, dimensions int) error {
+	_, err := db.Exec(vectorTableSQL(dimensions))
 	return err
 }
 
@@ -81,12 +294,9 @@ func initSchema(db *sql.DB) error {
 	}
 
 	// Check current version
-	var version int
-	err := db.QueryRow("SELECT version FROM schema_version ORDER BY version DESC LIMIT 1").Scan(&version)
-	if err == sql.ErrNoRows {
-		version = 0
-	} else if err != nil {
-		return fmt.Errorf("failed to check schema version: %w", err)
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
 	}
 
 	if version >= currentSchemaVersion {
@@ -103,9 +313,170 @@ func initSchema(db *sql.DB) error {
 		}
 	}
 
+	if version < 2 {
+		if err := migrateV2(db); err != nil {
+			return fmt.Errorf("failed to migrate to v2: %w", err)
+		}
+	}
+
+	if version < 3 {
+		if err := migrateV3(db); err != nil {
+			return fmt.Errorf("failed to migrate to v3: %w", err)
+		}
+	}
+
+	if version < 4 {
+		if err := migrateV4(db); err != nil {
+			return fmt.Errorf("failed to migrate to v4: %w", err)
+		}
+	}
+
+	if version < 5 {
+		if err := migrateV5(db); err != nil {
+			return fmt.Errorf("failed to migrate to v5: %w", err)
+		}
+	}
+
+	if version < 6 {
+		if err := migrateV6(db); err != nil {
+			return fmt.Errorf("failed to migrate to v6: %w", err)
+		}
+	}
+
+	if version < 7 {
+		if err := migrateV7(db); err != nil {
+			return fmt.Errorf("failed to migrate to v7: %w", err)
+		}
+	}
+
+	if version < 8 {
+		if err := migrateV8(db); err != nil {
+			return fmt.Errorf("failed to migrate to v8: %w", err)
+		}
+	}
+
+	if version < 9 {
+		if err := migrateV9(db); err != nil {
+			return fmt.Errorf("failed to migrate to v9: %w", err)
+		}
+	}
+
+	if version < 10 {
+		if err := migrateV10(db); err != nil {
+			return fmt.Errorf("failed to migrate to v10: %w", err)
+		}
+	}
+
+	if version < 11 {
+		if err := migrateV11(db); err != nil {
+			return fmt.Errorf("failed to migrate to v11: %w", err)
+		}
+	}
+
+	if version < 12 {
+		if err := migrateV12(db); err != nil {
+			return fmt.Errorf("failed to migrate to v12: %w", err)
+		}
+	}
+
+	if version < 13 {
+		if err := migrateV13(db); err != nil {
+			return fmt.Errorf("failed to migrate to v13: %w", err)
+		}
+	}
+
+	if version < 14 {
+		if err := migrateV14(db); err != nil {
+			return fmt.Errorf("failed to migrate to v14: %w", err)
+		}
+	}
+
+	if version < 15 {
+		if err := migrateV15(db); err != nil {
+			return fmt.Errorf("failed to migrate to v15: %w", err)
+		}
+	}
+
+	if version < 16 {
+		if err := migrateV16(db); err != nil {
+			return fmt.Errorf("failed to migrate to v16: %w", err)
+		}
+	}
+
+	if version < 17 {
+		if err := migrateV17(db); err != nil {
+			return fmt.Errorf("failed to migrate to v17: %w", err)
+		}
+	}
+
+	if version < 18 {
+		if err := migrateV18(db); err != nil {
+			return fmt.Errorf("failed to migrate to v18: %w", err)
+		}
+	}
+
+	if version < 19 {
+		if err := migrateV19(db); err != nil {
+			return fmt.Errorf("failed to migrate to v19: %w", err)
+		}
+	}
+
+	if version < 20 {
+		if err := migrateV20(db); err != nil {
+			return fmt.Errorf("failed to migrate to v20: %w", err)
+		}
+	}
+
+	if version < 21 {
+		if err := migrateV21(db); err != nil {
+			return fmt.Errorf("failed to migrate to v21: %w", err)
+		}
+	}
+
+	if version < 22 {
+		if err := migrateV22(db); err != nil {
+			return fmt.Errorf("failed to migrate to v22: %w", err)
+		}
+	}
+
+	if version < 23 {
+		if err := migrateV23(db); err != nil {
+			return fmt.Errorf("failed to migrate to v23: %w", err)
+		}
+	}
+
+	if version < 24 {
+		if err := migrateV24(db); err != nil {
+			return fmt.Errorf("failed to migrate to v24: %w", err)
+		}
+	}
+
+	if version < 25 {
+		if err := migrateV25(db); err != nil {
+			return fmt.Errorf("failed to migrate to v25: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// schemaVersion returns the schema version of the database, or 0 if it has
+// not been initialized.
+func schemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("SELECT version FROM schema_version ORDER BY version DESC LIMIT 1").Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to check schema version: %w", err)
+	}
+	return version, nil
+}
+
 // migrateV1 creates the initial schema.
 func migrateV1(db *sql.DB) error {
 	log.Debug("Applying migration v1")
@@ -129,6 +500,510 @@ func migrateV1(db *sql.DB) error {
 	return nil
 }
 
+// migrateV2 adds the Q&A history table.
+func migrateV2(db *sql.DB) error {
+	log.Debug("Applying migration v2")
+
+	if _, err := db.Exec(qaHistoryTable); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 2); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV3 adds the provider usage table.
+func migrateV3(db *sql.DB) error {
+	log.Debug("Applying migration v3")
+
+	if _, err := db.Exec(usageTable); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 3); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV4 adds the local metrics table.
+func migrateV4(db *sql.DB) error {
+	log.Debug("Applying migration v4")
+
+	if _, err := db.Exec(metricsTable); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 4); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV5 adds store aliases.
+func migrateV5(db *sql.DB) error {
+	log.Debug("Applying migration v5")
+
+	if _, err := db.Exec(storeAliasesTable); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 5); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV6 partitions the vector table by store. sqlite-vec cannot add a
+// column to an existing table, so the vectors are copied out, the table is
+// recreated and the vectors are copied back, all in one transaction.
+func migrateV6(db *sql.DB) error {
+	log.Debug("Applying migration v6")
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tableSQL string
+	err = tx.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name='chunk_vectors'").Scan(&tableSQL)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to check vector table: %w", err)
+	}
+
+	// Without a vector table there is nothing to move; it is created with
+	// the partition key when the first store is created
+	if err == nil {
+		match := vectorDimensions.FindStringSubmatch(tableSQL)
+		if match == nil {
+			return fmt.Errorf("failed to read vector dimensions from %q", tableSQL)
+		}
+		dimensions, _ := strconv.Atoi(match[1])
+
+		steps := []string{
+			`CREATE TEMP TABLE chunk_vectors_v5 AS
+				SELECT cv.chunk_id, f.store_id, cv.embedding
+				FROM chunk_vectors cv
+				JOIN chunks c ON c.id = cv.chunk_id
+				JOIN files f ON f.id = c.file_id`,
+			"DROP TABLE chunk_vectors",
+			vectorTableSQL(dimensions),
+			`INSERT INTO chunk_vectors (chunk_id, store_id, embedding)
+				SELECT chunk_id, store_id, embedding FROM chunk_vectors_v5`,
+			"DROP TABLE chunk_vectors_v5",
+		}
+		for _, step := range steps {
+			if _, err := tx.Exec(step); err != nil {
+				return fmt.Errorf("failed to partition vector table: %w", err)
+			}
+		}
+	}
+
+	if _, err := tx.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 6); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// migrateV7 stores the lines around each chunk, and optionally the whole
+// file, so result context does not depend on the file still being on disk.
+func migrateV7(db *sql.DB) error {
+	log.Debug("Applying migration v7")
+
+	columns := []string{
+		"ALTER TABLE chunks ADD COLUMN context_before TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE chunks ADD COLUMN context_after TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE files ADD COLUMN content TEXT",
+	}
+	for _, column := range columns {
+		if _, err := db.Exec(column); err != nil {
+			return fmt.Errorf("failed to add column: %w", err)
+		}
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 7); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV8 adds embedding batches submitted to a provider's batch API, so
+// an interrupted run can collect their results later.
+func migrateV8(db *sql.DB) error {
+	log.Debug("Applying migration v8")
+
+	if _, err := db.Exec(embeddingBatchesTable); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 8); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV9 adds namespaces. Store names and aliases become unique per
+// namespace, which SQLite can only do by recreating the tables; the Q&A
+// history, usage and metrics tables, which refer to stores by name, gain a
+// namespace column. Existing data moves to the default namespace.
+func migrateV9(db *sql.DB) error {
+	log.Debug("Applying migration v9")
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	// Dropping the old stores table would cascade to every file, so foreign
+	// keys are turned off while the tables are swapped. SQLite ignores the
+	// pragma inside a transaction.
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return fmt.Errorf("failed to disable foreign keys: %w", err)
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	steps := []string{
+		namespacedTables,
+		`INSERT INTO stores_v9 (id, name, root_path, embedding_provider, embedding_model, embedding_dimensions, created_at, updated_at)
+			SELECT id, name, root_path, embedding_provider, embedding_model, embedding_dimensions, created_at, updated_at FROM stores`,
+		`INSERT INTO store_aliases_v9 (alias, store_id) SELECT alias, store_id FROM store_aliases`,
+		"DROP TABLE store_aliases",
+		"DROP TABLE stores",
+		"ALTER TABLE stores_v9 RENAME TO stores",
+		"ALTER TABLE store_aliases_v9 RENAME TO store_aliases",
+		"CREATE INDEX IF NOT EXISTS idx_store_aliases_store_id ON store_aliases(store_id)",
+		"ALTER TABLE qa_history ADD COLUMN namespace TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE usage ADD COLUMN namespace TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE metrics ADD COLUMN namespace TEXT NOT NULL DEFAULT ''",
+	}
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step); err != nil {
+			return fmt.Errorf("failed to add namespaces: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 9); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// migrateV10 adds the history of index runs.
+func migrateV10(db *sql.DB) error {
+	log.Debug("Applying migration v10")
+
+	if _, err := db.Exec(indexRunsTable); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 10); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV11 adds per-store score calibration.
+func migrateV11(db *sql.DB) error {
+	log.Debug("Applying migration v11")
+
+	if _, err := db.Exec(scoreCalibrationTable); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 11); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV12 records the symbols each chunk defines. Chunks indexed before
+// have none until their files are re-indexed.
+func migrateV12(db *sql.DB) error {
+	log.Debug("Applying migration v12")
+
+	if _, err := db.Exec(chunkSymbolsTable); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 12); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV13 records the imports of each file, so related code can be found.
+// Files indexed before have none until they are re-indexed.
+func migrateV13(db *sql.DB) error {
+	log.Debug("Applying migration v13")
+
+	if _, err := db.Exec(fileImportsTable); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 13); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV14 adds the relations between files resolved from their imports.
+func migrateV14(db *sql.DB) error {
+	log.Debug("Applying migration v14")
+
+	if _, err := db.Exec(fileRelationsTable); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 14); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV15 records the LLM tokens and cost of each metric, so Q&A spend
+// can be tracked per store.
+func migrateV15(db *sql.DB) error {
+	log.Debug("Applying migration v15")
+
+	columns := []string{
+		"ALTER TABLE metrics ADD COLUMN input_tokens INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE metrics ADD COLUMN output_tokens INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE metrics ADD COLUMN cost REAL NOT NULL DEFAULT 0",
+	}
+	for _, column := range columns {
+		if _, err := db.Exec(column); err != nil {
+			return fmt.Errorf("failed to add column: %w", err)
+		}
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 15); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV16 flags vendored and generated files, which search leaves out
+// by default. Files indexed before are not flagged until re-indexed.
+func migrateV16(db *sql.DB) error {
+	log.Debug("Applying migration v16")
+
+	if _, err := db.Exec("ALTER TABLE files ADD COLUMN generated INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("failed to add column: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 16); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV17 records the language of each file, for per-language stats
+// and the search language boost. Files indexed before are left without one
+// until re-indexed.
+func migrateV17(db *sql.DB) error {
+	log.Debug("Applying migration v17")
+
+	if _, err := db.Exec("ALTER TABLE files ADD COLUMN language TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("failed to add column: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 17); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV18 records the modification time of each file, for the search
+// recency boost. Files indexed before fall back to their indexing time.
+func migrateV18(db *sql.DB) error {
+	log.Debug("Applying migration v18")
+
+	if _, err := db.Exec("ALTER TABLE files ADD COLUMN modified_at TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("failed to add column: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 18); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV19 adds the search log and the feedback on search results.
+func migrateV19(db *sql.DB) error {
+	log.Debug("Applying migration v19")
+
+	for _, table := range []string{searchLogTable, feedbackTable} {
+		if _, err := db.Exec(table); err != nil {
+			return fmt.Errorf("failed to create table: %w", err)
+		}
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 19); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV20 records the options, scores and paging of logged searches, so
+// later pages can be shown from the log.
+func migrateV20(db *sql.DB) error {
+	log.Debug("Applying migration v20")
+
+	columns := []string{
+		"ALTER TABLE search_log ADD COLUMN key TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE search_log ADD COLUMN shown INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE search_log_results ADD COLUMN score REAL NOT NULL DEFAULT 0",
+		"ALTER TABLE search_log_results ADD COLUMN relevance REAL NOT NULL DEFAULT 0",
+	}
+	for _, column := range columns {
+		if _, err := db.Exec(column); err != nil {
+			return fmt.Errorf("failed to add column: %w", err)
+		}
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 20); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV21 adds the table of file changes a watcher stopped before
+// handling, for the next watcher of the store.
+func migrateV21(db *sql.DB) error {
+	log.Debug("Applying migration v21")
+
+	if _, err := db.Exec(watchPendingTable); err != nil {
+		return fmt.Errorf("failed to create watch_pending table: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 21); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV22 adds the table of stores whose watchers are paused.
+func migrateV22(db *sql.DB) error {
+	log.Debug("Applying migration v22")
+
+	if _, err := db.Exec(watchPausesTable); err != nil {
+		return fmt.Errorf("failed to create watch_pauses table: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 22); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV23 keeps a copy of each chunk's embedding in the chunks table, so
+// the vector table can be rebuilt without calling the embedding provider.
+func migrateV23(db *sql.DB) error {
+	log.Debug("Applying migration v23")
+
+	hasVectors, err := hasVectorTable(db)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("ALTER TABLE chunks ADD COLUMN embedding BLOB"); err != nil {
+		return fmt.Errorf("failed to add column: %w", err)
+	}
+	if hasVectors {
+		if _, err := tx.Exec(`
+			UPDATE chunks SET embedding = (SELECT embedding FROM chunk_vectors WHERE chunk_id = chunks.id)
+		`); err != nil {
+			return fmt.Errorf("failed to copy embeddings: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 23); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// migrateV24 records the distance metric each store is searched with.
+// Existing stores keep cosine, the metric of the vector table.
+func migrateV24(db *sql.DB) error {
+	log.Debug("Applying migration v24")
+
+	if _, err := db.Exec("ALTER TABLE stores ADD COLUMN distance_metric TEXT NOT NULL DEFAULT 'cosine'"); err != nil {
+		return fmt.Errorf("failed to add column: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 24); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateV25 records the chunk count and distance metric each store's
+// scores were calibrated with, so calibration is only repeated when they
+// change. Existing calibrations are redone on the next index.
+func migrateV25(db *sql.DB) error {
+	log.Debug("Applying migration v25")
+
+	columns := []string{
+		"ALTER TABLE score_calibration ADD COLUMN chunks INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE score_calibration ADD COLUMN metric TEXT NOT NULL DEFAULT ''",
+	}
+	for _, column := range columns {
+		if _, err := db.Exec(column); err != nil {
+			return fmt.Errorf("failed to add column: %w", err)
+		}
+	}
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO schema_version (version) VALUES (?)", 25); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return nil
+}
+
+// vectorDimensions matches the dimensions in the vector table's definition.
+var vectorDimensions = regexp.MustCompile(`float\[(\d+)\]`)
+
 // ensureVectorTable ensures the vector table exists with the correct dimensions.
 // If dimensions change, we need to recreate the table.
 func ensureVectorTable(db *sql.DB, dimensions int) error {
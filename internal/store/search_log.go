@@ -0,0 +1,142 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// maxSearchLog is how many searches of each store the search log keeps.
+const maxSearchLog = 1000
+
+// AddSearchLog records a search and the results it returned. The log is
+// best-effort: on a read-only store the search is silently dropped, and
+// only the most recent maxSearchLog searches of each store are kept.
+func (s *SQLiteStore) AddSearchLog(e *SearchLogEntry) error {
+	if s.readOnly {
+		return nil
+	}
+	return retryOnBusy(func() error {
+		return s.addSearchLog(e)
+	})
+}
+
+// addSearchLog performs AddSearchLog without retrying.
+func (s *SQLiteStore) addSearchLog(e *SearchLogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO search_log (store_id, query, key, shown, created_at) VALUES (?, ?, ?, ?, ?)`,
+		e.StoreID, e.Query, e.Key, e.Shown, e.CreatedAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to insert search: %w", err)
+	}
+	e.ID, _ = result.LastInsertId()
+
+	for rank, r := range e.Results {
+		if _, err := tx.Exec(`INSERT INTO search_log_results (search_id, chunk_id, rank, score, relevance) VALUES (?, ?, ?, ?, ?)`,
+			e.ID, r.ChunkID, rank+1, r.Score, r.Relevance); err != nil {
+			return fmt.Errorf("failed to insert search result: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM search_log WHERE store_id = ? AND id NOT IN (
+			SELECT id FROM search_log WHERE store_id = ? ORDER BY id DESC LIMIT ?
+		)`, e.StoreID, e.StoreID, maxSearchLog); err != nil {
+		return fmt.Errorf("failed to prune search log: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetLastSearch returns the most recent logged search in the namespace with
+// its results, or nil if there is none.
+func (s *SQLiteStore) GetLastSearch() (*SearchLogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var e SearchLogEntry
+	var createdAt string
+	err := s.db.QueryRow(`
+		SELECT l.id, l.store_id, l.query, l.key, l.shown, l.created_at FROM search_log l
+		JOIN stores st ON st.id = l.store_id
+		WHERE st.namespace = ?
+		ORDER BY l.id DESC LIMIT 1
+	`, s.namespace).Scan(&e.ID, &e.StoreID, &e.Query, &e.Key, &e.Shown, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last search: %w", err)
+	}
+	e.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+
+	rows, err := s.db.Query(`
+		SELECT chunk_id, score, relevance FROM search_log_results
+		WHERE search_id = ? ORDER BY rank
+	`, e.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get search results: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r LoggedResult
+		if err := rows.Scan(&r.ChunkID, &r.Score, &r.Relevance); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		e.Results = append(e.Results, r)
+	}
+
+	return &e, rows.Err()
+}
+
+// SetSearchShown records how many results of a logged search have been
+// shown. Like the log itself, it does nothing on a read-only store.
+func (s *SQLiteStore) SetSearchShown(id int64, shown int) error {
+	if s.readOnly {
+		return nil
+	}
+	return retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, err := s.db.Exec(`UPDATE search_log SET shown = ? WHERE id = ?`, shown, id); err != nil {
+			return fmt.Errorf("failed to update search: %w", err)
+		}
+		return nil
+	})
+}
+
+// FindSearchQuery returns the query of the most recent logged search of the
+// store that returned the chunk, or "" if there is none.
+func (s *SQLiteStore) FindSearchQuery(storeID, chunkID int64) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var query string
+	err := s.db.QueryRow(`
+		SELECT l.query FROM search_log l
+		JOIN search_log_results r ON r.search_id = l.id
+		WHERE l.store_id = ? AND r.chunk_id = ?
+		ORDER BY l.id DESC LIMIT 1
+	`, storeID, chunkID).Scan(&query)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find search: %w", err)
+	}
+	return query, nil
+}
@@ -3,10 +3,13 @@ package store
 import (
 	"database/sql"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +17,8 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 
 	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+
+	"github.com/nickcecere/lgrep/internal/config"
 )
 
 func init() {
@@ -21,22 +26,59 @@ func init() {
 	sqlite_vec.Auto()
 }
 
+// busyTimeout is how long SQLite waits for a lock held by another process
+// (e.g. a watcher or MCP server indexing the same database) before failing.
+const busyTimeout = 5 * time.Second
+
 // SQLiteStore implements the Store interface using SQLite and sqlite-vec.
 type SQLiteStore struct {
-	db *sql.DB
-	mu sync.RWMutex
+	db       *sql.DB
+	mu       sync.RWMutex
+	readOnly bool
+
+	// namespace scopes store names, Q&A history, usage and metrics, so
+	// several users or teams can share one database without seeing each
+	// other's stores. The default namespace is empty.
+	namespace string
+}
+
+// Option configures a SQLiteStore.
+type Option func(*SQLiteStore)
+
+// WithNamespace opens the database in a namespace; see ValidateNamespace.
+func WithNamespace(namespace string) Option {
+	return func(s *SQLiteStore) {
+		s.namespace = namespace
+	}
+}
+
+// namespacePattern matches valid namespace names.
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidateNamespace returns an error if namespace is not a valid namespace
+// name: up to 64 letters, digits, dots, dashes and underscores, starting
+// with a letter or digit. The empty default namespace is valid.
+func ValidateNamespace(namespace string) error {
+	if namespace != "" && !namespacePattern.MatchString(namespace) {
+		return fmt.Errorf("invalid namespace %q: use letters, digits, '.', '-' and '_'", namespace)
+	}
+	return nil
 }
 
 // NewSQLiteStore creates a new SQLite store at the given path.
-func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
+func NewSQLiteStore(dbPath string, opts ...Option) (*SQLiteStore, error) {
 	// Ensure directory exists
 	dir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	// Open database with foreign keys enabled
-	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on&_journal_mode=WAL")
+	// Open database with foreign keys enabled. Transactions take the write
+	// lock up front so concurrent writers wait on the busy timeout instead of
+	// failing when a read transaction is upgraded.
+	dsn := fmt.Sprintf("%s?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate",
+		dbPath, busyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -49,7 +91,76 @@ func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 
 	log.Debug("Opened SQLite store", "path", dbPath)
 
-	return &SQLiteStore{db: db}, nil
+	s := &SQLiteStore{db: db}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// NewSQLiteStoreReadOnly opens an existing SQLite store for reading only.
+// Read-only stores never take write locks, so searches do not contend with
+// a watcher or MCP server indexing the same database. If the database does
+// not exist yet or needs migrating, it is opened read-write instead.
+func NewSQLiteStoreReadOnly(dbPath string, opts ...Option) (*SQLiteStore, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		log.Debug("Database not found, opening read-write", "path", dbPath)
+		return NewSQLiteStore(dbPath, opts...)
+	}
+
+	dsn := fmt.Sprintf("file:%s?mode=ro&_foreign_keys=on&_busy_timeout=%d", dbPath, busyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	version, err := schemaVersion(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if version < currentSchemaVersion {
+		db.Close()
+		log.Debug("Schema needs migrating, opening read-write", "version", version)
+		return NewSQLiteStore(dbPath, opts...)
+	}
+
+	log.Debug("Opened SQLite store read-only", "path", dbPath)
+
+	s := &SQLiteStore{db: db, readOnly: true}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Namespace returns the namespace the store was opened in.
+func (s *SQLiteStore) Namespace() string {
+	return s.namespace
+}
+
+// ListNamespaces returns the namespaces that contain stores, in
+// alphabetical order. The default namespace is listed as "".
+func (s *SQLiteStore) ListNamespaces() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query("SELECT DISTINCT namespace FROM stores ORDER BY namespace")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	defer rows.Close()
+
+	var namespaces []string
+	for rows.Next() {
+		var namespace string
+		if err := rows.Scan(&namespace); err != nil {
+			return nil, fmt.Errorf("failed to scan namespace: %w", err)
+		}
+		namespaces = append(namespaces, namespace)
+	}
+
+	return namespaces, rows.Err()
 }
 
 // Close closes the database connection.
@@ -69,9 +180,9 @@ func (s *SQLiteStore) CreateStore(name, rootPath string, provider EmbeddingProvi
 
 	now := time.Now().UTC().Format(time.RFC3339)
 	result, err := s.db.Exec(`
-		INSERT INTO stores (name, root_path, embedding_provider, embedding_model, embedding_dimensions, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, name, rootPath, string(provider), model, dimensions, now, now)
+		INSERT INTO stores (namespace, name, root_path, embedding_provider, embedding_model, embedding_dimensions, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.namespace, name, rootPath, string(provider), model, dimensions, now, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create store: %w", err)
 	}
@@ -89,12 +200,13 @@ func (s *SQLiteStore) CreateStore(name, rootPath string, provider EmbeddingProvi
 		EmbeddingProvider:   provider,
 		EmbeddingModel:      model,
 		EmbeddingDimensions: dimensions,
+		DistanceMetric:      config.MetricCosine,
 		CreatedAt:           createdAt,
 		UpdatedAt:           createdAt,
 	}, nil
 }
 
-// GetStore retrieves a store by name.
+// GetStore retrieves a store by name or alias.
 func (s *SQLiteStore) GetStore(name string) (*StoreRecord, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -104,11 +216,10 @@ func (s *SQLiteStore) GetStore(name string) (*StoreRecord, error) {
 	var provider string
 
 	err := s.db.QueryRow(`
-		SELECT id, name, root_path, embedding_provider, embedding_model, embedding_dimensions, created_at, updated_at
-		FROM stores WHERE name = ?
-	`, name).Scan(
+		SELECT id, name, root_path, embedding_provider, embedding_model, embedding_dimensions, distance_metric, created_at, updated_at
+		FROM stores WHERE id = `+storeIDByName, s.nameArgs(name)...).Scan(
 		&record.ID, &record.Name, &record.RootPath,
-		&provider, &record.EmbeddingModel, &record.EmbeddingDimensions,
+		&provider, &record.EmbeddingModel, &record.EmbeddingDimensions, &record.DistanceMetric,
 		&createdAt, &updatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -135,11 +246,11 @@ func (s *SQLiteStore) GetStoreByID(id int64) (*StoreRecord, error) {
 	var provider string
 
 	err := s.db.QueryRow(`
-		SELECT id, name, root_path, embedding_provider, embedding_model, embedding_dimensions, created_at, updated_at
-		FROM stores WHERE id = ?
-	`, id).Scan(
+		SELECT id, name, root_path, embedding_provider, embedding_model, embedding_dimensions, distance_metric, created_at, updated_at
+		FROM stores WHERE id = ? AND namespace = ?
+	`, id, s.namespace).Scan(
 		&record.ID, &record.Name, &record.RootPath,
-		&provider, &record.EmbeddingModel, &record.EmbeddingDimensions,
+		&provider, &record.EmbeddingModel, &record.EmbeddingDimensions, &record.DistanceMetric,
 		&createdAt, &updatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -156,14 +267,15 @@ func (s *SQLiteStore) GetStoreByID(id int64) (*StoreRecord, error) {
 	return &record, nil
 }
 
-// DeleteStore deletes a store and all its files/chunks.
+// DeleteStore deletes a store and all its files/chunks. The name may be an
+// alias.
 func (s *SQLiteStore) DeleteStore(name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Get store ID first
 	var storeID int64
-	err := s.db.QueryRow("SELECT id FROM stores WHERE name = ?", name).Scan(&storeID)
+	err := s.db.QueryRow("SELECT id FROM stores WHERE id = "+storeIDByName, s.nameArgs(name)...).Scan(&storeID)
 	if err == sql.ErrNoRows {
 		return nil // Store doesn't exist
 	}
@@ -192,15 +304,16 @@ func (s *SQLiteStore) DeleteStore(name string) error {
 	return nil
 }
 
-// ListStores returns all stores.
+// ListStores returns all stores in the namespace, leaving out the temporary
+// stores of forced re-indexes in progress.
 func (s *SQLiteStore) ListStores() ([]StoreRecord, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	rows, err := s.db.Query(`
-		SELECT id, name, root_path, embedding_provider, embedding_model, embedding_dimensions, created_at, updated_at
-		FROM stores ORDER BY name
-	`)
+		SELECT id, name, root_path, embedding_provider, embedding_model, embedding_dimensions, distance_metric, created_at, updated_at
+		FROM stores WHERE namespace = ? ORDER BY name
+	`, s.namespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list stores: %w", err)
 	}
@@ -214,12 +327,15 @@ func (s *SQLiteStore) ListStores() ([]StoreRecord, error) {
 
 		if err := rows.Scan(
 			&record.ID, &record.Name, &record.RootPath,
-			&provider, &record.EmbeddingModel, &record.EmbeddingDimensions,
+			&provider, &record.EmbeddingModel, &record.EmbeddingDimensions, &record.DistanceMetric,
 			&createdAt, &updatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan store: %w", err)
 		}
 
+		if IsRebuildStore(record.Name) {
+			continue
+		}
 		record.EmbeddingProvider = EmbeddingProvider(provider)
 		record.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 		record.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
@@ -232,12 +348,25 @@ func (s *SQLiteStore) ListStores() ([]StoreRecord, error) {
 
 // UpdateStoreTimestamp updates the store's updated_at timestamp.
 func (s *SQLiteStore) UpdateStoreTimestamp(id int64) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	return retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
 
-	now := time.Now().UTC().Format(time.RFC3339)
-	_, err := s.db.Exec("UPDATE stores SET updated_at = ? WHERE id = ?", now, id)
-	return err
+		now := time.Now().UTC().Format(time.RFC3339)
+		_, err := s.db.Exec("UPDATE stores SET updated_at = ? WHERE id = ?", now, id)
+		return err
+	})
+}
+
+// SetDistanceMetric sets the distance metric a store is searched with.
+func (s *SQLiteStore) SetDistanceMetric(id int64, metric config.DistanceMetric) error {
+	return retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		_, err := s.db.Exec("UPDATE stores SET distance_metric = ? WHERE id = ?", string(metric), id)
+		return err
+	})
 }
 
 // UpsertFile inserts or updates a file with its chunks and embeddings.
@@ -246,6 +375,13 @@ func (s *SQLiteStore) UpsertFile(storeID int64, file FileInput, chunks []Chunk,
 		return fmt.Errorf("chunks and embeddings count mismatch: %d != %d", len(chunks), len(embeddings))
 	}
 
+	return retryOnBusy(func() error {
+		return s.upsertFile(storeID, file, chunks, embeddings)
+	})
+}
+
+// upsertFile performs UpsertFile in a single transaction.
+func (s *SQLiteStore) upsertFile(storeID int64, file FileInput, chunks []Chunk, embeddings [][]float32) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -255,78 +391,299 @@ func (s *SQLiteStore) UpsertFile(storeID int64, file FileInput, chunks []Chunk,
 	}
 	defer tx.Rollback()
 
+	if err := upsertFileTx(tx, storeID, file, chunks, embeddings); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpsertFiles writes a batch of files in a single transaction, so a batch is
+// either committed or not visible at all. Each file is written under its own
+// savepoint: a file that fails is rolled back on its own and reported in an
+// *UpsertError while the rest of the batch is committed.
+func (s *SQLiteStore) UpsertFiles(storeID int64, files []FileUpsert) error {
+	failed := make(map[string]error)
+	var batch []FileUpsert
+	for _, f := range files {
+		if len(f.Chunks) != len(f.Embeddings) {
+			failed[f.File.ExternalID] = fmt.Errorf("chunks and embeddings count mismatch: %d != %d", len(f.Chunks), len(f.Embeddings))
+			continue
+		}
+		batch = append(batch, f)
+	}
+
+	err := retryOnBusy(func() error {
+		rolledBack, err := s.upsertFiles(storeID, batch)
+		for id, fileErr := range rolledBack {
+			failed[id] = fileErr
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return &UpsertError{Files: failed}
+	}
+	return nil
+}
+
+// upsertFiles performs UpsertFiles without retrying. It returns the files
+// that were rolled back once the rest of the batch is committed.
+func (s *SQLiteStore) upsertFiles(storeID int64, files []FileUpsert) (map[string]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rolledBack := make(map[string]error)
+	for _, f := range files {
+		if _, err := tx.Exec("SAVEPOINT upsert_file"); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+
+		if err := upsertFileTx(tx, storeID, f.File, f.Chunks, f.Embeddings); err != nil {
+			if _, rbErr := tx.Exec("ROLLBACK TO upsert_file"); rbErr != nil {
+				return nil, fmt.Errorf("failed to roll back savepoint: %w", rbErr)
+			}
+			rolledBack[f.File.ExternalID] = err
+		}
+
+		if _, err := tx.Exec("RELEASE upsert_file"); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return rolledBack, nil
+}
+
+// upsertFileTx writes a file with its chunks and embeddings within tx.
+func upsertFileTx(tx *sql.Tx, storeID int64, file FileInput, chunks []Chunk, embeddings [][]float32) error {
+	fileID, err := writeFileTx(tx, storeID, file)
+	if err != nil {
+		return err
+	}
+	return insertChunksTx(tx, storeID, fileID, chunks, embeddings)
+}
+
+// writeFileTx inserts or updates a file record within tx, removing any
+// chunks and vectors from a previous version. It returns the file ID.
+func writeFileTx(tx *sql.Tx, storeID int64, file FileInput) (int64, error) {
 	// Check if file exists
 	var existingFileID int64
-	err = tx.QueryRow("SELECT id FROM files WHERE store_id = ? AND external_id = ?", storeID, file.ExternalID).Scan(&existingFileID)
+	err := tx.QueryRow("SELECT id FROM files WHERE store_id = ? AND external_id = ?", storeID, file.ExternalID).Scan(&existingFileID)
 	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("failed to check existing file: %w", err)
+		return 0, fmt.Errorf("failed to check existing file: %w", err)
 	}
 
+	now := time.Now().UTC().Format(time.RFC3339)
+
 	// If file exists, delete old chunks and vectors
 	if existingFileID > 0 {
 		// Delete vectors for old chunks
 		_, err = tx.Exec("DELETE FROM chunk_vectors WHERE chunk_id IN (SELECT id FROM chunks WHERE file_id = ?)", existingFileID)
 		if err != nil {
-			return fmt.Errorf("failed to delete old vectors: %w", err)
+			return 0, fmt.Errorf("failed to delete old vectors: %w", err)
 		}
 
 		// Delete old chunks
 		_, err = tx.Exec("DELETE FROM chunks WHERE file_id = ?", existingFileID)
 		if err != nil {
-			return fmt.Errorf("failed to delete old chunks: %w", err)
+			return 0, fmt.Errorf("failed to delete old chunks: %w", err)
 		}
 
 		// Update file record
-		now := time.Now().UTC().Format(time.RFC3339)
 		_, err = tx.Exec(`
-			UPDATE files SET path = ?, relative_path = ?, hash = ?, file_size = ?, indexed_at = ?
+			UPDATE files SET path = ?, relative_path = ?, hash = ?, file_size = ?, content = ?, indexed_at = ?, generated = ?, language = ?, modified_at = ?
 			WHERE id = ?
-		`, file.Path, file.RelativePath, file.Hash, file.FileSize, now, existingFileID)
+		`, file.Path, file.RelativePath, file.Hash, file.FileSize, nullString(file.Content), now, file.Generated, file.Language, formatModTime(file.ModifiedAt), existingFileID)
 		if err != nil {
-			return fmt.Errorf("failed to update file: %w", err)
+			return 0, fmt.Errorf("failed to update file: %w", err)
 		}
-	} else {
-		// Insert new file
-		now := time.Now().UTC().Format(time.RFC3339)
-		result, err := tx.Exec(`
-			INSERT INTO files (store_id, external_id, path, relative_path, hash, file_size, indexed_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?)
-		`, storeID, file.ExternalID, file.Path, file.RelativePath, file.Hash, file.FileSize, now)
-		if err != nil {
-			return fmt.Errorf("failed to insert file: %w", err)
+		return existingFileID, writeImportsTx(tx, existingFileID, file.Imports)
+	}
+
+	// Insert new file
+	result, err := tx.Exec(`
+		INSERT INTO files (store_id, external_id, path, relative_path, hash, file_size, content, indexed_at, generated, language, modified_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, storeID, file.ExternalID, file.Path, file.RelativePath, file.Hash, file.FileSize, nullString(file.Content), now, file.Generated, file.Language, formatModTime(file.ModifiedAt))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert file: %w", err)
+	}
+	fileID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return fileID, writeImportsTx(tx, fileID, file.Imports)
+}
+
+// writeImportsTx replaces the imports recorded for a file within tx.
+func writeImportsTx(tx *sql.Tx, fileID int64, imports []string) error {
+	if _, err := tx.Exec("DELETE FROM file_imports WHERE file_id = ?", fileID); err != nil {
+		return fmt.Errorf("failed to delete old imports: %w", err)
+	}
+	for _, target := range imports {
+		if _, err := tx.Exec("INSERT INTO file_imports (file_id, target) VALUES (?, ?)", fileID, target); err != nil {
+			return fmt.Errorf("failed to insert import: %w", err)
 		}
-		existingFileID, _ = result.LastInsertId()
 	}
+	return nil
+}
 
-	// Insert chunks and vectors
+// insertChunksTx inserts chunks and their vectors for a file within tx.
+func insertChunksTx(tx *sql.Tx, storeID, fileID int64, chunks []Chunk, embeddings [][]float32) error {
 	for i, chunk := range chunks {
-		// Insert chunk
+		// Insert chunk, with a copy of its embedding to rebuild the vector
+		// table from
+		embeddingBlob := serializeEmbedding(embeddings[i])
 		result, err := tx.Exec(`
-			INSERT INTO chunks (file_id, chunk_index, content, start_line, end_line)
-			VALUES (?, ?, ?, ?, ?)
-		`, existingFileID, chunk.ChunkIndex, chunk.Content, chunk.StartLine, chunk.EndLine)
+			INSERT INTO chunks (file_id, chunk_index, content, start_line, end_line, context_before, context_after, embedding)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, fileID, chunk.ChunkIndex, chunk.Content, chunk.StartLine, chunk.EndLine, chunk.ContextBefore, chunk.ContextAfter, embeddingBlob)
 		if err != nil {
 			return fmt.Errorf("failed to insert chunk %d: %w", i, err)
 		}
 
 		chunkID, _ := result.LastInsertId()
 
+		for _, name := range chunk.Symbols {
+			if _, err := tx.Exec("INSERT INTO chunk_symbols (chunk_id, name) VALUES (?, ?)", chunkID, name); err != nil {
+				return fmt.Errorf("failed to insert symbol for chunk %d: %w", i, err)
+			}
+		}
+
 		// Insert vector
-		embeddingBlob := serializeEmbedding(embeddings[i])
 		_, err = tx.Exec(`
-			INSERT INTO chunk_vectors (chunk_id, embedding)
-			VALUES (?, ?)
-		`, chunkID, embeddingBlob)
+			INSERT INTO chunk_vectors (chunk_id, store_id, embedding)
+			VALUES (?, ?, ?)
+		`, chunkID, storeID, embeddingBlob)
 		if err != nil {
 			return fmt.Errorf("failed to insert vector for chunk %d: %w", i, err)
 		}
 	}
 
+	return nil
+}
+
+// GetFileContent returns the stored text of a file, or an empty string if
+// the file was indexed without storing its content.
+func (s *SQLiteStore) GetFileContent(fileID int64) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var content sql.NullString
+	err := s.db.QueryRow("SELECT content FROM files WHERE id = ?", fileID).Scan(&content)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get file content: %w", err)
+	}
+	return content.String, nil
+}
+
+// formatModTime formats a file modification time for the modified_at
+// column, leaving it empty if unknown.
+func formatModTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// BeginFile starts writing a file whose chunks are added in batches with
+// AppendChunks, replacing any previous version. The file is stored without
+// a hash until FinishFile, so a write that is interrupted part-way never
+// looks up to date. It returns the file ID.
+func (s *SQLiteStore) BeginFile(storeID int64, file FileInput) (int64, error) {
+	file.Hash = ""
+
+	var fileID int64
+	err := retryOnBusy(func() error {
+		return s.inTx(func(tx *sql.Tx) error {
+			var err error
+			fileID, err = writeFileTx(tx, storeID, file)
+			return err
+		})
+	})
+	return fileID, err
+}
+
+// AppendChunks adds chunks and their embeddings to a file started with
+// BeginFile.
+func (s *SQLiteStore) AppendChunks(fileID int64, chunks []Chunk, embeddings [][]float32) error {
+	if len(chunks) != len(embeddings) {
+		return fmt.Errorf("chunks and embeddings count mismatch: %d != %d", len(chunks), len(embeddings))
+	}
+
+	return retryOnBusy(func() error {
+		return s.inTx(func(tx *sql.Tx) error {
+			var storeID int64
+			if err := tx.QueryRow("SELECT store_id FROM files WHERE id = ?", fileID).Scan(&storeID); err != nil {
+				return fmt.Errorf("failed to get file: %w", err)
+			}
+			return insertChunksTx(tx, storeID, fileID, chunks, embeddings)
+		})
+	})
+}
+
+// FinishFile records the hash of a file started with BeginFile, marking it
+// as completely indexed.
+func (s *SQLiteStore) FinishFile(fileID int64, hash string) error {
+	return retryOnBusy(func() error {
+		return s.inTx(func(tx *sql.Tx) error {
+			_, err := tx.Exec("UPDATE files SET hash = ? WHERE id = ?", hash, fileID)
+			if err != nil {
+				return fmt.Errorf("failed to update file hash: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
+// inTx runs fn in a transaction, committing if it succeeds.
+func (s *SQLiteStore) inTx(fn func(tx *sql.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
 // DeleteFile deletes a file and its chunks/vectors.
 func (s *SQLiteStore) DeleteFile(storeID int64, externalID string) error {
+	return retryOnBusy(func() error {
+		return s.deleteFile(storeID, externalID)
+	})
+}
+
+// deleteFile performs DeleteFile without retrying.
+func (s *SQLiteStore) deleteFile(storeID int64, externalID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -361,15 +718,15 @@ func (s *SQLiteStore) GetFileByExternalID(storeID int64, externalID string) (*Fi
 	defer s.mu.RUnlock()
 
 	var record FileRecord
-	var indexedAt string
+	var indexedAt, modifiedAt string
 
 	err := s.db.QueryRow(`
-		SELECT id, store_id, external_id, path, relative_path, hash, file_size, indexed_at
+		SELECT id, store_id, external_id, path, relative_path, hash, file_size, indexed_at, generated, language, modified_at
 		FROM files WHERE store_id = ? AND external_id = ?
 	`, storeID, externalID).Scan(
 		&record.ID, &record.StoreID, &record.ExternalID,
 		&record.Path, &record.RelativePath, &record.Hash,
-		&record.FileSize, &indexedAt,
+		&record.FileSize, &indexedAt, &record.Generated, &record.Language, &modifiedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -379,6 +736,7 @@ func (s *SQLiteStore) GetFileByExternalID(storeID int64, externalID string) (*Fi
 	}
 
 	record.IndexedAt, _ = time.Parse(time.RFC3339, indexedAt)
+	record.ModifiedAt, _ = time.Parse(time.RFC3339, modifiedAt)
 	return &record, nil
 }
 
@@ -388,15 +746,15 @@ func (s *SQLiteStore) GetFileByHash(storeID int64, hash string) (*FileRecord, er
 	defer s.mu.RUnlock()
 
 	var record FileRecord
-	var indexedAt string
+	var indexedAt, modifiedAt string
 
 	err := s.db.QueryRow(`
-		SELECT id, store_id, external_id, path, relative_path, hash, file_size, indexed_at
+		SELECT id, store_id, external_id, path, relative_path, hash, file_size, indexed_at, generated, language, modified_at
 		FROM files WHERE store_id = ? AND hash = ?
 	`, storeID, hash).Scan(
 		&record.ID, &record.StoreID, &record.ExternalID,
 		&record.Path, &record.RelativePath, &record.Hash,
-		&record.FileSize, &indexedAt,
+		&record.FileSize, &indexedAt, &record.Generated, &record.Language, &modifiedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -406,17 +764,19 @@ func (s *SQLiteStore) GetFileByHash(storeID int64, hash string) (*FileRecord, er
 	}
 
 	record.IndexedAt, _ = time.Parse(time.RFC3339, indexedAt)
+	record.ModifiedAt, _ = time.Parse(time.RFC3339, modifiedAt)
 	return &record, nil
 }
 
-// ListFiles returns files for a store.
+// ListFiles returns files for a store, in relative path order.
 func (s *SQLiteStore) ListFiles(storeID int64, opts *ListFilesOptions) ([]FileRecord, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	where, args := fileFilters(storeID, opts)
 	query := `
-		SELECT id, store_id, external_id, path, relative_path, hash, file_size, indexed_at
-		FROM files WHERE store_id = ? ORDER BY relative_path
+		SELECT id, store_id, external_id, path, relative_path, hash, file_size, indexed_at, generated, language, modified_at
+		FROM files WHERE ` + where + ` ORDER BY relative_path
 	`
 
 	if opts != nil && opts.Limit > 0 {
@@ -426,7 +786,81 @@ func (s *SQLiteStore) ListFiles(storeID int64, opts *ListFilesOptions) ([]FileRe
 		}
 	}
 
-	rows, err := s.db.Query(query, storeID)
+	return s.queryFiles(query, args...)
+}
+
+// walkFilesPageSize is how many files WalkFiles reads at a time; a variable
+// so tests can page through a few files.
+var walkFilesPageSize = 500
+
+// WalkFiles calls fn for each file of a store matching the filters of opts,
+// in relative path order, stopping at the first error fn returns. Files are
+// read a page at a time, so big stores are never held in memory, and the
+// store is not locked while fn runs, so fn may modify it. The limit and
+// offset of opts are ignored.
+func (s *SQLiteStore) WalkFiles(storeID int64, opts *ListFilesOptions, fn func(FileRecord) error) error {
+	where, args := fileFilters(storeID, opts)
+	query := `
+		SELECT id, store_id, external_id, path, relative_path, hash, file_size, indexed_at, generated, language, modified_at
+		FROM files WHERE ` + where + ` AND (relative_path, id) > (?, ?)
+		ORDER BY relative_path, id
+		LIMIT ?
+	`
+
+	var afterPath string
+	var afterID int64
+	for {
+		s.mu.RLock()
+		files, err := s.queryFiles(query, append(args[:len(args):len(args)], afterPath, afterID, walkFilesPageSize)...)
+		s.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+		if len(files) < walkFilesPageSize {
+			return nil
+		}
+		last := files[len(files)-1]
+		afterPath, afterID = last.RelativePath, last.ID
+	}
+}
+
+// fileFilters returns the WHERE clause selecting the files of a store that
+// match the filters of opts, and its arguments.
+func fileFilters(storeID int64, opts *ListFilesOptions) (string, []any) {
+	where := "store_id = ?"
+	args := []any{storeID}
+	if opts == nil {
+		return where, args
+	}
+
+	if opts.PathPrefix != "" {
+		where += " AND substr(relative_path, 1, length(?)) = ?"
+		args = append(args, opts.PathPrefix, opts.PathPrefix)
+	}
+	if opts.Language != "" {
+		where += " AND language = ?"
+		args = append(args, opts.Language)
+	}
+	if !opts.ModifiedAfter.IsZero() {
+		where += " AND modified_at > ?"
+		args = append(args, formatModTime(opts.ModifiedAfter))
+	}
+	if opts.Hash != "" {
+		where += " AND hash = ?"
+		args = append(args, opts.Hash)
+	}
+	return where, args
+}
+
+// queryFiles runs a query selecting file columns and returns its files.
+func (s *SQLiteStore) queryFiles(query string, args ...any) ([]FileRecord, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list files: %w", err)
 	}
@@ -435,83 +869,352 @@ func (s *SQLiteStore) ListFiles(storeID int64, opts *ListFilesOptions) ([]FileRe
 	var files []FileRecord
 	for rows.Next() {
 		var record FileRecord
-		var indexedAt string
+		var indexedAt, modifiedAt string
 
 		if err := rows.Scan(
 			&record.ID, &record.StoreID, &record.ExternalID,
 			&record.Path, &record.RelativePath, &record.Hash,
-			&record.FileSize, &indexedAt,
+			&record.FileSize, &indexedAt, &record.Generated, &record.Language, &modifiedAt,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan file: %w", err)
 		}
 
 		record.IndexedAt, _ = time.Parse(time.RFC3339, indexedAt)
+		record.ModifiedAt, _ = time.Parse(time.RFC3339, modifiedAt)
 		files = append(files, record)
 	}
 
 	return files, rows.Err()
 }
 
-// Search performs a vector similarity search.
-func (s *SQLiteStore) Search(storeID int64, queryEmbedding []float32, topK int) ([]SearchResult, error) {
+// Search performs a vector similarity search, returning up to topK chunks
+// with a similarity score of at least minScore. Scores range from -1 to 1, so
+// a minScore of -1 returns the nearest chunks regardless of score.
+//
+// Chunks are ranked by the store's distance metric. Cosine and dot product
+// scores are the similarity of the embeddings; dot product is only used for
+// normalized embeddings, where it scores like cosine. An L2 distance d scores
+// 1 - d²/2, which equals cosine similarity for normalized embeddings, so
+// score thresholds mean the same for every metric.
+//
+// With opts.SkipContent, chunk content and stored context are not read, and
+// with opts.Paths or opts.PathPrefix only the chunks of those files are
+// searched.
+func (s *SQLiteStore) Search(storeID int64, queryEmbedding []float32, topK int, minScore float64, opts *SearchOptions) ([]SearchResult, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	var metric config.DistanceMetric
+	if err := s.db.QueryRow("SELECT distance_metric FROM stores WHERE id = ?", storeID).Scan(&metric); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to read distance metric: %w", err)
+	}
+
 	// Serialize the query embedding
 	queryBlob := serializeEmbedding(queryEmbedding)
 
-	// Perform vector search using sqlite-vec
-	// Note: sqlite-vec filters happen AFTER k results are selected from the vector index.
-	// To ensure we get topK results after filtering by store_id, we request more from
-	// the vector index (topK * 10) and let the SQL LIMIT clause enforce the final count.
-	kForVec := topK * 10
-	if kForVec > 1000 {
-		kForVec = 1000
+	chunkColumns := "c.content, c.start_line, c.end_line, c.context_before, c.context_after"
+	if opts != nil && opts.SkipContent {
+		chunkColumns = "'', c.start_line, c.end_line, '', ''"
+	}
+
+	// Perform vector search using sqlite-vec. Vectors are partitioned by
+	// store, so the k nearest neighbours are all from the searched store and
+	// the distance threshold only drops results scoring below minScore. The
+	// vector table measures cosine distance, so other metrics, and distances
+	// to the chunks of a few files, are computed chunk by chunk instead,
+	// ranking chunk IDs before the content of the top k is read.
+	var rows *sql.Rows
+	var err error
+	restricted := opts != nil && (opts.Paths != nil || opts.PathPrefix != "")
+	if metric == config.MetricL2 || metric == config.MetricDot || restricted {
+		if opts != nil && opts.Paths != nil && len(opts.Paths) == 0 {
+			return nil, nil
+		}
+		ranking, args, rankErr := rankingQuery(metric, storeID, queryEmbedding, minScore, opts)
+		if rankErr != nil {
+			return nil, rankErr
+		}
+		rows, err = s.db.Query(`
+			SELECT 
+				c.id, c.file_id, c.chunk_index, `+chunkColumns+`,
+				f.id, f.store_id, f.external_id, f.path, f.relative_path, f.hash, f.file_size, f.indexed_at,
+				f.generated, f.language, f.modified_at, r.distance
+			FROM (`+ranking+`) r
+			JOIN chunks c ON c.id = r.chunk_id
+			JOIN files f ON f.id = c.file_id
+			ORDER BY r.distance ASC
+		`, append(args, topK)...)
+	} else {
+		rows, err = s.db.Query(`
+			SELECT 
+				c.id, c.file_id, c.chunk_index, `+chunkColumns+`,
+				f.id, f.store_id, f.external_id, f.path, f.relative_path, f.hash, f.file_size, f.indexed_at,
+				f.generated, f.language, f.modified_at, cv.distance
+			FROM chunk_vectors cv
+			JOIN chunks c ON c.id = cv.chunk_id
+			JOIN files f ON f.id = c.file_id
+			WHERE cv.store_id = ?
+				AND cv.embedding MATCH ?
+				AND k = ?
+				AND cv.distance <= ?
+			ORDER BY cv.distance ASC
+		`, storeID, queryBlob, topK, 1-minScore)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+		var indexedAt, modifiedAt string
+
+		if err := rows.Scan(
+			&result.Chunk.ID, &result.Chunk.FileID, &result.Chunk.ChunkIndex,
+			&result.Chunk.Content, &result.Chunk.StartLine, &result.Chunk.EndLine,
+			&result.Chunk.ContextBefore, &result.Chunk.ContextAfter,
+			&result.File.ID, &result.File.StoreID, &result.File.ExternalID,
+			&result.File.Path, &result.File.RelativePath, &result.File.Hash,
+			&result.File.FileSize, &indexedAt,
+			&result.File.Generated, &result.File.Language, &modifiedAt, &result.Distance,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+
+		result.File.IndexedAt, _ = time.Parse(time.RFC3339, indexedAt)
+		result.File.ModifiedAt, _ = time.Parse(time.RFC3339, modifiedAt)
+		// Convert distance to similarity
+		if metric == config.MetricL2 {
+			result.Score = 1 - result.Distance*result.Distance/2
+		} else {
+			result.Score = 1 - result.Distance
+		}
+
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// rankingQuery returns a query, and its arguments but for the final LIMIT,
+// selecting the chunk_id and distance of the nearest chunks of a store
+// under metric, computed chunk by chunk. Dot product distances are 1 minus
+// the dot product, which sqlite-vec has no function for; it is derived from
+// L2 distances as (|a|² + |b|² - |a-b|²) / 2.
+func rankingQuery(metric config.DistanceMetric, storeID int64, queryEmbedding []float32, minScore float64, opts *SearchOptions) (string, []any, error) {
+	queryBlob := serializeEmbedding(queryEmbedding)
+	distance := "vec_distance_cosine(cv.embedding, ?)"
+	args := []any{queryBlob}
+	threshold := "distance <= ?"
+	maxDistance := 1 - minScore
+	switch metric {
+	case config.MetricL2:
+		distance = "vec_distance_l2(cv.embedding, ?)"
+		threshold = "distance * distance <= ?"
+		maxDistance = 2 * (1 - minScore)
+	case config.MetricDot:
+		var norm2 float64
+		for _, v := range queryEmbedding {
+			norm2 += float64(v) * float64(v)
+		}
+		zero := serializeEmbedding(make([]float32, len(queryEmbedding)))
+		distance = "1 - (vec_distance_l2(cv.embedding, ?) * vec_distance_l2(cv.embedding, ?) + ? - vec_distance_l2(cv.embedding, ?) * vec_distance_l2(cv.embedding, ?)) / 2"
+		args = []any{zero, zero, norm2, queryBlob, queryBlob}
+	}
+	// Embeddings that are not normalized can score below -1
+	if minScore <= -1 {
+		maxDistance = math.MaxFloat64
+	}
+
+	args = append(args, storeID)
+	filter := ""
+	if opts != nil && opts.Paths != nil {
+		paths, err := json.Marshal(opts.Paths)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to encode paths: %w", err)
+		}
+		filter += " AND f.relative_path IN (SELECT value FROM json_each(?))"
+		args = append(args, string(paths))
+	}
+	if opts != nil && opts.PathPrefix != "" {
+		dir := opts.PathPrefix + "/"
+		filter += " AND (f.relative_path = ? OR substr(f.relative_path, 1, length(?)) = ?)"
+		args = append(args, opts.PathPrefix, dir, dir)
 	}
+	from := "chunk_vectors cv"
+	if filter != "" {
+		from += `
+				JOIN chunks c ON c.id = cv.chunk_id
+				JOIN files f ON f.id = c.file_id`
+	}
+
+	return `
+			SELECT chunk_id, distance FROM (
+				SELECT cv.chunk_id, ` + distance + ` AS distance
+				FROM ` + from + `
+				WHERE cv.store_id = ?` + filter + `
+			)
+			WHERE ` + threshold + `
+			ORDER BY distance ASC
+			LIMIT ?
+		`, append(args, maxDistance), nil
+}
+
+// ListChunkVectors returns up to limit chunk embeddings of a store, in chunk
+// ID order starting after afterID, so every vector can be read a page at a
+// time.
+func (s *SQLiteStore) ListChunkVectors(storeID, afterID int64, limit int) ([]ChunkVector, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	rows, err := s.db.Query(`
-		SELECT 
+		SELECT chunk_id, embedding FROM chunk_vectors
+		WHERE store_id = ? AND chunk_id > ?
+		ORDER BY chunk_id
+		LIMIT ?
+	`, storeID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vectors: %w", err)
+	}
+	defer rows.Close()
+
+	var vectors []ChunkVector
+	for rows.Next() {
+		var v ChunkVector
+		var blob []byte
+		if err := rows.Scan(&v.ChunkID, &blob); err != nil {
+			return nil, fmt.Errorf("failed to scan vector: %w", err)
+		}
+		v.Embedding = deserializeEmbedding(blob)
+		vectors = append(vectors, v)
+	}
+	return vectors, rows.Err()
+}
+
+// GetFileVectors returns the chunk embeddings of a file, in chunk ID order.
+func (s *SQLiteStore) GetFileVectors(fileID int64) ([]ChunkVector, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT chunk_id, embedding FROM chunk_vectors
+		WHERE chunk_id IN (SELECT id FROM chunks WHERE file_id = ?)
+		ORDER BY chunk_id
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file vectors: %w", err)
+	}
+	defer rows.Close()
+
+	var vectors []ChunkVector
+	for rows.Next() {
+		var v ChunkVector
+		var blob []byte
+		if err := rows.Scan(&v.ChunkID, &blob); err != nil {
+			return nil, fmt.Errorf("failed to scan vector: %w", err)
+		}
+		v.Embedding = deserializeEmbedding(blob)
+		vectors = append(vectors, v)
+	}
+	return vectors, rows.Err()
+}
+
+// GetChunks returns the chunks with the given IDs and their files, in chunk
+// ID order. IDs that do not exist are skipped. Distance and Score are zero.
+func (s *SQLiteStore) GetChunks(chunkIDs []int64) ([]SearchResult, error) {
+	if len(chunkIDs) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	placeholders := strings.Repeat("?,", len(chunkIDs))
+	placeholders = placeholders[:len(placeholders)-1]
+	args := make([]any, len(chunkIDs))
+	for i, id := range chunkIDs {
+		args[i] = id
+	}
+
+	rows, err := s.db.Query(`
+		SELECT
 			c.id, c.file_id, c.chunk_index, c.content, c.start_line, c.end_line,
+			c.context_before, c.context_after,
 			f.id, f.store_id, f.external_id, f.path, f.relative_path, f.hash, f.file_size, f.indexed_at,
-			cv.distance
-		FROM chunk_vectors cv
-		JOIN chunks c ON c.id = cv.chunk_id
+			f.generated, f.language, f.modified_at
+		FROM chunks c
 		JOIN files f ON f.id = c.file_id
-		WHERE f.store_id = ?
-			AND cv.embedding MATCH ?
-			AND k = ?
-		ORDER BY cv.distance ASC
-		LIMIT ?
-	`, storeID, queryBlob, kForVec, topK)
+		WHERE c.id IN (`+placeholders+`)
+		ORDER BY c.id
+	`, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search: %w", err)
+		return nil, fmt.Errorf("failed to get chunks: %w", err)
 	}
 	defer rows.Close()
 
 	var results []SearchResult
 	for rows.Next() {
 		var result SearchResult
-		var indexedAt string
+		var indexedAt, modifiedAt string
 
 		if err := rows.Scan(
 			&result.Chunk.ID, &result.Chunk.FileID, &result.Chunk.ChunkIndex,
 			&result.Chunk.Content, &result.Chunk.StartLine, &result.Chunk.EndLine,
+			&result.Chunk.ContextBefore, &result.Chunk.ContextAfter,
 			&result.File.ID, &result.File.StoreID, &result.File.ExternalID,
 			&result.File.Path, &result.File.RelativePath, &result.File.Hash,
 			&result.File.FileSize, &indexedAt,
-			&result.Distance,
+			&result.File.Generated, &result.File.Language, &modifiedAt,
 		); err != nil {
-			return nil, fmt.Errorf("failed to scan search result: %w", err)
+			return nil, fmt.Errorf("failed to scan chunk: %w", err)
 		}
 
 		result.File.IndexedAt, _ = time.Parse(time.RFC3339, indexedAt)
-		result.Score = 1 - result.Distance // Convert distance to similarity
-
+		result.File.ModifiedAt, _ = time.Parse(time.RFC3339, modifiedAt)
 		results = append(results, result)
 	}
 
 	return results, rows.Err()
 }
 
+// GetChunkContent returns the content of the chunks with the given IDs, by
+// chunk ID, for results searched with SearchOptions.SkipContent. IDs that do
+// not exist are left out.
+func (s *SQLiteStore) GetChunkContent(chunkIDs []int64) (map[int64]string, error) {
+	if len(chunkIDs) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	placeholders := strings.Repeat("?,", len(chunkIDs))
+	placeholders = placeholders[:len(placeholders)-1]
+	args := make([]any, len(chunkIDs))
+	for i, id := range chunkIDs {
+		args[i] = id
+	}
+
+	rows, err := s.db.Query("SELECT id, content FROM chunks WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunk content: %w", err)
+	}
+	defer rows.Close()
+
+	content := make(map[int64]string, len(chunkIDs))
+	for rows.Next() {
+		var id int64
+		var text string
+		if err := rows.Scan(&id, &text); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk content: %w", err)
+		}
+		content[id] = text
+	}
+
+	return content, rows.Err()
+}
+
 // GetStats returns statistics for a store.
 func (s *SQLiteStore) GetStats(storeID int64) (*StoreStats, error) {
 	s.mu.RLock()
@@ -545,9 +1248,72 @@ func (s *SQLiteStore) GetStats(storeID int64) (*StoreStats, error) {
 		return nil, fmt.Errorf("failed to get chunk count: %w", err)
 	}
 
+	// Count the files of each language
+	rows, err := s.db.Query(`
+		SELECT language, COUNT(*) FROM files
+		WHERE store_id = ? GROUP BY language
+	`, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get language stats: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var language string
+		var count int
+		if err := rows.Scan(&language, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan language stats: %w", err)
+		}
+		if stats.Languages == nil {
+			stats.Languages = make(map[string]int)
+		}
+		stats.Languages[language] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get language stats: %w", err)
+	}
+
+	// Get recorded provider usage
+	usage, err := s.usageSummary(stats.StoreName, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	stats.Usage = *usage
+
 	return &stats, nil
 }
 
+// CountFileChunks returns the number of chunks of each file of a store, by
+// file ID. Files without chunks are left out.
+func (s *SQLiteStore) CountFileChunks(storeID int64) (map[int64]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT c.file_id, COUNT(*) FROM chunks c
+		JOIN files f ON f.id = c.file_id
+		WHERE f.store_id = ?
+		GROUP BY c.file_id
+	`, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count chunks: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int)
+	for rows.Next() {
+		var fileID int64
+		var count int
+		if err := rows.Scan(&fileID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk count: %w", err)
+		}
+		counts[fileID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count chunks: %w", err)
+	}
+	return counts, nil
+}
+
 // ClearStore removes all files and chunks from a store.
 func (s *SQLiteStore) ClearStore(storeID int64) error {
 	s.mu.Lock()
@@ -571,9 +1337,127 @@ func (s *SQLiteStore) ClearStore(storeID int64) error {
 		return fmt.Errorf("failed to delete files: %w", err)
 	}
 
+	if _, err := s.db.Exec("DELETE FROM score_calibration WHERE store_id = ?", storeID); err != nil {
+		return fmt.Errorf("failed to delete score calibration: %w", err)
+	}
+
 	return nil
 }
 
+// ReplaceStoreContents replaces the files, chunks and embeddings of the
+// store targetID with those of the store sourceID in a single transaction,
+// then deletes the source store. The target keeps its ID, name and aliases
+// but takes the source's embedding model.
+func (s *SQLiteStore) ReplaceStoreContents(targetID, sourceID int64) error {
+	return retryOnBusy(func() error {
+		return s.replaceStoreContents(targetID, sourceID)
+	})
+}
+
+// replaceStoreContents performs ReplaceStoreContents without retrying.
+func (s *SQLiteStore) replaceStoreContents(targetID, sourceID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		DELETE FROM chunk_vectors WHERE chunk_id IN (
+			SELECT c.id FROM chunks c
+			JOIN files f ON f.id = c.file_id
+			WHERE f.store_id = ?
+		)
+	`, targetID)
+	if err != nil {
+		return fmt.Errorf("failed to delete vectors: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM files WHERE store_id = ?", targetID); err != nil {
+		return fmt.Errorf("failed to delete files: %w", err)
+	}
+
+	if _, err := tx.Exec("UPDATE files SET store_id = ? WHERE store_id = ?", targetID, sourceID); err != nil {
+		return fmt.Errorf("failed to move files: %w", err)
+	}
+
+	// sqlite-vec cannot update a partition key, so re-insert the vectors
+	// under the target store
+	steps := []struct {
+		query string
+		args  []any
+	}{
+		{`CREATE TEMP TABLE moved_vectors AS
+			SELECT chunk_id, embedding FROM chunk_vectors WHERE chunk_id IN (
+				SELECT c.id FROM chunks c
+				JOIN files f ON f.id = c.file_id
+				WHERE f.store_id = ?
+			)`, []any{targetID}},
+		{"DELETE FROM chunk_vectors WHERE chunk_id IN (SELECT chunk_id FROM moved_vectors)", nil},
+		{"INSERT INTO chunk_vectors (chunk_id, store_id, embedding) SELECT chunk_id, ?, embedding FROM moved_vectors", []any{targetID}},
+		{"DROP TABLE moved_vectors", nil},
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step.query, step.args...); err != nil {
+			return fmt.Errorf("failed to move vectors: %w", err)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err = tx.Exec(`
+		UPDATE stores SET
+			embedding_provider = src.embedding_provider,
+			embedding_model = src.embedding_model,
+			embedding_dimensions = src.embedding_dimensions,
+			distance_metric = src.distance_metric,
+			updated_at = ?
+		FROM (SELECT embedding_provider, embedding_model, embedding_dimensions, distance_metric FROM stores WHERE id = ?) AS src
+		WHERE stores.id = ?
+	`, now, sourceID, targetID)
+	if err != nil {
+		return fmt.Errorf("failed to update store: %w", err)
+	}
+
+	// The new contents may come from another model
+	if _, err := tx.Exec("DELETE FROM score_calibration WHERE store_id = ?", targetID); err != nil {
+		return fmt.Errorf("failed to delete score calibration: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM stores WHERE id = ?", sourceID); err != nil {
+		return fmt.Errorf("failed to delete source store: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Vacuum rebuilds the database file to return space freed by deleted data
+// to the filesystem.
+func (s *SQLiteStore) Vacuum() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return nil
+}
+
+// deserializeEmbedding converts sqlite-vec bytes back to a float32 slice.
+func deserializeEmbedding(buf []byte) []float32 {
+	embedding := make([]float32, len(buf)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return embedding
+}
+
 // serializeEmbedding converts a float32 slice to bytes for sqlite-vec.
 func serializeEmbedding(embedding []float32) []byte {
 	buf := make([]byte, len(embedding)*4)
@@ -1,13 +1,20 @@
 package store
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/config"
 )
 
 func TestNewSQLiteStore(t *testing.T) {
@@ -97,6 +104,175 @@ func TestStoreDelete(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestStoreRenameAndAliases(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	rec, err := store.CreateStore("src", "/path/to/project/src", ProviderOllama, "model", 768)
+	require.NoError(t, err)
+	_, err = store.CreateStore("other", "/path/to/other", ProviderOllama, "model", 768)
+	require.NoError(t, err)
+	require.NoError(t, store.AddUsage(&UsageRecord{StoreName: "src", Operation: "index", Provider: "openai", Model: "m", InputTokens: 10, Cost: 0.5}))
+
+	// Renaming keeps the old name as an alias and moves recorded usage
+	require.NoError(t, store.RenameStore("src", "project"))
+
+	renamed, err := store.GetStore("project")
+	require.NoError(t, err)
+	require.NotNil(t, renamed)
+	assert.Equal(t, rec.ID, renamed.ID)
+
+	byAlias, err := store.GetStore("src")
+	require.NoError(t, err)
+	require.NotNil(t, byAlias)
+	assert.Equal(t, "project", byAlias.Name)
+
+	summary, err := store.GetUsageSummary("project", time.Time{})
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, summary.Cost, 1e-9)
+
+	// Names and aliases of other stores are taken
+	assert.ErrorIs(t, store.RenameStore("project", "other"), ErrNameInUse)
+	assert.ErrorIs(t, store.AddStoreAlias("other", "src"), ErrNameInUse)
+	assert.ErrorIs(t, store.RenameStore("missing", "new"), ErrStoreNotFound)
+
+	// Renaming back to an alias of the same store is allowed
+	require.NoError(t, store.AddStoreAlias("project", "proj"))
+	aliases, err := store.GetStoreAliases(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"proj", "src"}, aliases)
+
+	require.NoError(t, store.RenameStore("src", "proj"))
+	aliases, err = store.GetStoreAliases(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"project", "src"}, aliases)
+
+	// Removing an alias leaves the store
+	require.NoError(t, store.RemoveStoreAlias("src"))
+	assert.Error(t, store.RemoveStoreAlias("src"))
+	gone, err := store.GetStore("src")
+	require.NoError(t, err)
+	assert.Nil(t, gone)
+
+	// Deleting by alias deletes the store and its aliases
+	require.NoError(t, store.DeleteStore("project"))
+	deleted, err := store.GetStore("proj")
+	require.NoError(t, err)
+	assert.Nil(t, deleted)
+	aliases, err = store.GetStoreAliases(rec.ID)
+	require.NoError(t, err)
+	assert.Empty(t, aliases)
+}
+
+func TestNamespaces(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	teamA, err := NewSQLiteStore(dbPath, WithNamespace("team-a"))
+	require.NoError(t, err)
+	defer teamA.Close()
+	teamB, err := NewSQLiteStore(dbPath, WithNamespace("team-b"))
+	require.NoError(t, err)
+	defer teamB.Close()
+
+	// The same name can be used in each namespace
+	recA, err := teamA.CreateStore("api", "/a/api", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	recB, err := teamB.CreateStore("api", "/b/api", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	require.NoError(t, teamA.AddStoreAlias("api", "backend"))
+	require.NoError(t, teamA.AddUsage(&UsageRecord{StoreName: "api", Operation: "index", Provider: "openai", Model: "m", Cost: 0.5}))
+
+	got, err := teamB.GetStore("api")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, recB.ID, got.ID)
+
+	// Names, aliases, IDs and usage of other namespaces are not visible
+	alias, err := teamB.GetStore("backend")
+	require.NoError(t, err)
+	assert.Nil(t, alias)
+	byID, err := teamB.GetStoreByID(recA.ID)
+	require.NoError(t, err)
+	assert.Nil(t, byID)
+	summary, err := teamB.GetUsageSummary("", time.Time{})
+	require.NoError(t, err)
+	assert.Zero(t, summary.Cost)
+
+	stores, err := teamA.ListStores()
+	require.NoError(t, err)
+	require.Len(t, stores, 1)
+	assert.Equal(t, "/a/api", stores[0].RootPath)
+
+	require.NoError(t, teamB.DeleteStore("api"))
+	got, err = teamA.GetStore("api")
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+
+	namespaces, err := teamA.ListNamespaces()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-a"}, namespaces)
+
+	assert.NoError(t, ValidateNamespace(""))
+	assert.NoError(t, ValidateNamespace("team_a.dev"))
+	assert.Error(t, ValidateNamespace("../other"))
+	assert.Error(t, ValidateNamespace("-team"))
+}
+
+func TestMigrateNamespaces(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+
+	rec, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	file := FileInput{ExternalID: "a.go", Path: "/path/a.go", RelativePath: "a.go", Hash: "h", FileSize: 10}
+	require.NoError(t, store.UpsertFile(rec.ID, file, []Chunk{{Content: "a", StartLine: 1, EndLine: 1}}, [][]float32{{1, 0, 0, 0}}))
+	require.NoError(t, store.AddStoreAlias("test", "alias"))
+
+	// Recreate the tables as a v8 database had them
+	steps := []string{
+		"PRAGMA foreign_keys = OFF",
+		"CREATE TABLE stores_v8 AS SELECT id, name, root_path, embedding_provider, embedding_model, embedding_dimensions, created_at, updated_at FROM stores",
+		"CREATE TABLE aliases_v8 AS SELECT alias, store_id FROM store_aliases",
+		"DROP TABLE store_aliases",
+		"DROP TABLE stores",
+		storesTable,
+		storeAliasesTable,
+		"INSERT INTO stores SELECT * FROM stores_v8",
+		"INSERT INTO store_aliases SELECT * FROM aliases_v8",
+		"DROP TABLE stores_v8",
+		"DROP TABLE aliases_v8",
+		"ALTER TABLE qa_history DROP COLUMN namespace",
+		"ALTER TABLE usage DROP COLUMN namespace",
+		"ALTER TABLE metrics DROP COLUMN namespace",
+		"PRAGMA foreign_keys = ON",
+	}
+	conn, err := store.db.Conn(context.Background())
+	require.NoError(t, err)
+	for _, step := range steps {
+		_, err := conn.ExecContext(context.Background(), step)
+		require.NoError(t, err, step)
+	}
+	require.NoError(t, conn.Close())
+	require.NoError(t, migrateV9(store.db))
+	require.NoError(t, migrateV24(store.db))
+
+	// Stores, aliases and files survive in the default namespace
+	got, err := store.GetStore("alias")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, rec.ID, got.ID)
+	stats, err := store.GetStats(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.FileCount)
+
+	// Deleting the store still cascades to its files
+	require.NoError(t, store.DeleteStore("test"))
+	var files int
+	require.NoError(t, store.db.QueryRow("SELECT COUNT(*) FROM files").Scan(&files))
+	assert.Zero(t, files)
+}
+
 func TestFileUpsertAndGet(t *testing.T) {
 	store := setupTestStore(t)
 	defer store.Close()
@@ -188,6 +364,256 @@ func TestFileUpsertUpdate(t *testing.T) {
 	assert.Equal(t, int64(200), retrieved.FileSize)
 }
 
+func TestUpsertFiles(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	upsert := func(name string, chunks ...Chunk) FileUpsert {
+		f := FileUpsert{File: FileInput{ExternalID: name, Path: "/path/" + name, RelativePath: name, Hash: name, FileSize: 10}}
+		for _, c := range chunks {
+			f.Chunks = append(f.Chunks, c)
+			f.Embeddings = append(f.Embeddings, []float32{0.1, 0.2, 0.3, 0.4})
+		}
+		return f
+	}
+
+	mismatch := upsert("mismatch.go", Chunk{Content: "a", ChunkIndex: 0})
+	mismatch.Embeddings = nil
+
+	files := []FileUpsert{
+		upsert("a.go", Chunk{Content: "a", StartLine: 1, EndLine: 1, ChunkIndex: 0}),
+		// The duplicate chunk index fails after the file row is written
+		upsert("dup.go", Chunk{Content: "x", ChunkIndex: 0}, Chunk{Content: "y", ChunkIndex: 0}),
+		mismatch,
+		upsert("b.go", Chunk{Content: "b", StartLine: 1, EndLine: 1, ChunkIndex: 0}),
+	}
+
+	err = store.UpsertFiles(storeRecord.ID, files)
+	var upsertErr *UpsertError
+	require.ErrorAs(t, err, &upsertErr)
+	assert.Len(t, upsertErr.Files, 2)
+	assert.Contains(t, upsertErr.Files, "dup.go")
+	assert.Contains(t, upsertErr.Files, "mismatch.go")
+
+	// The other files were committed, and the failed file left nothing behind
+	stats, err := store.GetStats(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.FileCount)
+	assert.Equal(t, 2, stats.ChunkCount)
+
+	dup, err := store.GetFileByExternalID(storeRecord.ID, "dup.go")
+	require.NoError(t, err)
+	assert.Nil(t, dup)
+
+	// Re-upserting replaces existing files
+	require.NoError(t, store.UpsertFiles(storeRecord.ID, []FileUpsert{
+		upsert("a.go", Chunk{Content: "a1", ChunkIndex: 0}, Chunk{Content: "a2", ChunkIndex: 1}),
+	}))
+	stats, err = store.GetStats(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.FileCount)
+	assert.Equal(t, 3, stats.ChunkCount)
+}
+
+func TestLanguageStats(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	for _, f := range []FileInput{
+		{ExternalID: "a.go", RelativePath: "a.go", Language: "go"},
+		{ExternalID: "b.go", RelativePath: "b.go", Language: "go"},
+		{ExternalID: "app.py", RelativePath: "app.py", Language: "python"},
+		{ExternalID: "LICENSE", RelativePath: "LICENSE"},
+	} {
+		f.Path = "/path/" + f.RelativePath
+		require.NoError(t, store.UpsertFile(storeRecord.ID, f, []Chunk{{Content: f.RelativePath}}, [][]float32{{0.1, 0.2, 0.3, 0.4}}))
+	}
+
+	stats, err := store.GetStats(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"go": 2, "python": 1, "": 1}, stats.Languages)
+
+	file, err := store.GetFileByExternalID(storeRecord.ID, "app.py")
+	require.NoError(t, err)
+	assert.Equal(t, "python", file.Language)
+}
+
+func TestSearchLog(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+
+	last, err := store.GetLastSearch()
+	require.NoError(t, err)
+	assert.Nil(t, last)
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	require.NoError(t, store.AddSearchLog(&SearchLogEntry{StoreID: storeRecord.ID, Query: "jwt refresh", Results: []LoggedResult{{ChunkID: 7}, {ChunkID: 3}}}))
+	second := &SearchLogEntry{
+		StoreID: storeRecord.ID,
+		Query:   "token expiry",
+		Key:     "k",
+		Results: []LoggedResult{{ChunkID: 3, Score: 0.9, Relevance: 80}, {ChunkID: 5, Score: 0.7, Relevance: 40}},
+		Shown:   1,
+	}
+	require.NoError(t, store.AddSearchLog(second))
+
+	query, err := store.FindSearchQuery(storeRecord.ID, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "token expiry", query, "the most recent search wins")
+	query, err = store.FindSearchQuery(storeRecord.ID, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "jwt refresh", query)
+	query, err = store.FindSearchQuery(storeRecord.ID, 9)
+	require.NoError(t, err)
+	assert.Empty(t, query)
+
+	require.NoError(t, store.SetSearchShown(second.ID, 2))
+	last, err = store.GetLastSearch()
+	require.NoError(t, err)
+	require.NotNil(t, last)
+	assert.Equal(t, second.ID, last.ID)
+	assert.Equal(t, "k", last.Key)
+	assert.Equal(t, 2, last.Shown)
+	assert.Equal(t, second.Results, last.Results, "results keep their rank order")
+
+	// Other namespaces have their own last search
+	other, err := NewSQLiteStore(dbPath, WithNamespace("other"))
+	require.NoError(t, err)
+	defer other.Close()
+	last, err = other.GetLastSearch()
+	require.NoError(t, err)
+	assert.Nil(t, last)
+
+	// Each store keeps its own most recent searches, however searches of
+	// other stores interleave with them
+	busy, err := store.CreateStore("busy", "/busy", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	for i := 0; i < maxSearchLog; i++ {
+		require.NoError(t, store.AddSearchLog(&SearchLogEntry{StoreID: busy.ID, Query: "q"}))
+		require.NoError(t, store.AddSearchLog(&SearchLogEntry{StoreID: storeRecord.ID, Query: "q"}))
+	}
+	count := func(storeID int64) int {
+		var n int
+		require.NoError(t, store.db.QueryRow("SELECT COUNT(*) FROM search_log WHERE store_id = ?", storeID).Scan(&n))
+		return n
+	}
+	assert.Equal(t, maxSearchLog, count(storeRecord.ID))
+	assert.Equal(t, maxSearchLog, count(busy.ID))
+}
+
+func TestFeedback(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	for _, f := range []Feedback{
+		{RelativePath: "auth/jwt.go", StartLine: 1, EndLine: 20, Query: "jwt refresh", Good: true},
+		{RelativePath: "auth/jwt.go", StartLine: 21, EndLine: 40, Good: false},
+		{RelativePath: "auth/jwt.go", StartLine: 1, EndLine: 20, Good: true},
+		{RelativePath: "README.md", StartLine: 1, EndLine: 5, Good: false},
+	} {
+		f.StoreID = storeRecord.ID
+		require.NoError(t, store.AddFeedback(&f))
+		assert.NotZero(t, f.ID)
+	}
+
+	feedback, err := store.ListFeedback(storeRecord.ID)
+	require.NoError(t, err)
+	require.Len(t, feedback, 4)
+	assert.Equal(t, "jwt refresh", feedback[0].Query)
+	assert.True(t, feedback[0].Good)
+	assert.Equal(t, 21, feedback[1].StartLine)
+	assert.False(t, feedback[0].CreatedAt.IsZero())
+
+	counts, err := store.CountFeedback(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]FeedbackCount{"auth/jwt.go": {Good: 2, Bad: 1}, "README.md": {Bad: 1}}, counts)
+
+	// Feedback goes with its store
+	require.NoError(t, store.DeleteStore("test"))
+	counts, err = store.CountFeedback(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+}
+
+func TestFileModifiedAt(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	modified := time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+	for _, f := range []FileInput{
+		{ExternalID: "a.go", RelativePath: "a.go", ModifiedAt: modified},
+		{ExternalID: "b.go", RelativePath: "b.go"},
+	} {
+		f.Path = "/path/" + f.RelativePath
+		require.NoError(t, store.UpsertFile(storeRecord.ID, f, []Chunk{{Content: f.RelativePath}}, [][]float32{{0.1, 0.2, 0.3, 0.4}}))
+	}
+
+	file, err := store.GetFileByExternalID(storeRecord.ID, "a.go")
+	require.NoError(t, err)
+	assert.True(t, modified.Equal(file.ModifiedAt), "got %s", file.ModifiedAt)
+
+	file, err = store.GetFileByExternalID(storeRecord.ID, "b.go")
+	require.NoError(t, err)
+	assert.True(t, file.ModifiedAt.IsZero())
+}
+
+func TestIncrementalFileWrite(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	file := FileInput{ExternalID: "big.txt", Path: "/path/big.txt", RelativePath: "big.txt", Hash: "hash1", FileSize: 100}
+	emb := []float32{0.1, 0.2, 0.3, 0.4}
+
+	fileID, err := store.BeginFile(storeRecord.ID, file)
+	require.NoError(t, err)
+	require.NoError(t, store.AppendChunks(fileID, []Chunk{{Content: "a", ChunkIndex: 0}, {Content: "b", ChunkIndex: 1}}, [][]float32{emb, emb}))
+	require.NoError(t, store.AppendChunks(fileID, []Chunk{{Content: "c", ChunkIndex: 2}}, [][]float32{emb}))
+	assert.Error(t, store.AppendChunks(fileID, []Chunk{{Content: "d", ChunkIndex: 3}}, nil))
+
+	// Until the file is finished it has no hash, so it looks out of date
+	got, err := store.GetFileByExternalID(storeRecord.ID, "big.txt")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Empty(t, got.Hash)
+
+	require.NoError(t, store.FinishFile(fileID, file.Hash))
+	got, err = store.GetFileByExternalID(storeRecord.ID, "big.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hash1", got.Hash)
+
+	stats, err := store.GetStats(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.FileCount)
+	assert.Equal(t, 3, stats.ChunkCount)
+
+	// Beginning the file again replaces its chunks
+	fileID2, err := store.BeginFile(storeRecord.ID, file)
+	require.NoError(t, err)
+	assert.Equal(t, fileID, fileID2)
+	stats, err = store.GetStats(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.ChunkCount)
+}
+
 func TestFileDelete(t *testing.T) {
 	store := setupTestStore(t)
 	defer store.Close()
@@ -213,6 +639,62 @@ func TestFileDelete(t *testing.T) {
 	assert.Nil(t, deleted)
 }
 
+func TestWalkFiles(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	defer func(size int) { walkFilesPageSize = size }(walkFilesPageSize)
+	walkFilesPageSize = 2
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	files := []FileInput{
+		{RelativePath: "README.md", Language: "markdown", Hash: "h1", ModifiedAt: old},
+		{RelativePath: "cmd/main.go", Language: "go", Hash: "h2", ModifiedAt: recent},
+		{RelativePath: "internal/a.go", Language: "go", Hash: "h3", ModifiedAt: old},
+		{RelativePath: "internal/b.go", Language: "go", Hash: "h4", ModifiedAt: recent},
+		{RelativePath: "internal/c.sql", Language: "sql", Hash: "h3", ModifiedAt: recent},
+	}
+	for _, f := range files {
+		f.ExternalID, f.Path = f.RelativePath, "/path/"+f.RelativePath
+		require.NoError(t, store.UpsertFile(storeRecord.ID, f, []Chunk{{Content: "x", StartLine: 1, EndLine: 1}}, [][]float32{{1, 0, 0, 0}}))
+	}
+
+	walk := func(opts *ListFilesOptions) []string {
+		var paths []string
+		require.NoError(t, store.WalkFiles(storeRecord.ID, opts, func(f FileRecord) error {
+			paths = append(paths, f.RelativePath)
+			return nil
+		}))
+		return paths
+	}
+	assert.Equal(t, []string{"README.md", "cmd/main.go", "internal/a.go", "internal/b.go", "internal/c.sql"}, walk(nil))
+	assert.Equal(t, []string{"internal/b.go", "internal/c.sql"}, walk(&ListFilesOptions{PathPrefix: "internal/", ModifiedAfter: old}))
+	assert.Equal(t, []string{"cmd/main.go", "internal/a.go", "internal/b.go"}, walk(&ListFilesOptions{Language: "go"}))
+	assert.Equal(t, []string{"internal/a.go", "internal/c.sql"}, walk(&ListFilesOptions{Hash: "h3"}))
+
+	// ListFiles applies the same filters
+	listed, err := store.ListFiles(storeRecord.ID, &ListFilesOptions{Language: "go", Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, "internal/a.go", listed[0].RelativePath)
+
+	// The store may be modified while walking, and an error stops the walk
+	stop := errors.New("stop")
+	var seen int
+	err = store.WalkFiles(storeRecord.ID, nil, func(f FileRecord) error {
+		seen++
+		if seen == 3 {
+			return stop
+		}
+		return store.DeleteFile(storeRecord.ID, f.ExternalID)
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, []string{"internal/a.go", "internal/b.go", "internal/c.sql"}, walk(nil))
+}
+
 func TestVectorSearch(t *testing.T) {
 	store := setupTestStore(t)
 	defer store.Close()
@@ -242,7 +724,7 @@ func TestVectorSearch(t *testing.T) {
 
 	// Search for something similar to "north"
 	query := []float32{0.9, 0.1, 0, 0}
-	results, err := store.Search(storeRecord.ID, query, 3)
+	results, err := store.Search(storeRecord.ID, query, 3, -1, nil)
 	require.NoError(t, err)
 	require.Len(t, results, 3)
 
@@ -252,96 +734,1118 @@ func TestVectorSearch(t *testing.T) {
 	// Scores should be in descending order (most similar first)
 	assert.True(t, results[0].Score >= results[1].Score)
 	assert.True(t, results[1].Score >= results[2].Score)
+
+	// The score threshold drops "east" but keeps the rest
+	results, err = store.Search(storeRecord.ID, query, 3, 0.5, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for _, r := range results {
+		assert.GreaterOrEqual(t, r.Score, 0.5)
+	}
+
+	// Content can be skipped and read later for the results kept
+	results, err = store.Search(storeRecord.ID, query, 3, -1, &SearchOptions{SkipContent: true})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, "file1.go", results[0].File.ExternalID)
+	assert.Empty(t, results[0].Chunk.Content)
+	content, err := store.GetChunkContent([]int64{results[0].Chunk.ID, 9999})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]string{results[0].Chunk.ID: "content of file1.go"}, content)
 }
 
-func TestGetStats(t *testing.T) {
+func TestVectorSearchPartitionedByStore(t *testing.T) {
 	store := setupTestStore(t)
 	defer store.Close()
 
-	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	// A large store whose vectors are all closer to the query
+	big, err := store.CreateStore("big", "/big", ProviderOllama, "model", 4)
 	require.NoError(t, err)
-
-	// Insert multiple files
-	for i := 0; i < 3; i++ {
-		name := string(rune('a'+i)) + ".go"
-		file := FileInput{ExternalID: name, Path: "/path/" + name, RelativePath: name, Hash: "h", FileSize: int64(100 * (i + 1))}
-		chunks := []Chunk{
-			{Content: "c1", StartLine: 1, EndLine: 5, ChunkIndex: 0},
-			{Content: "c2", StartLine: 6, EndLine: 10, ChunkIndex: 1},
-		}
-		embeddings := [][]float32{{0.1, 0.2, 0.3, 0.4}, {0.5, 0.6, 0.7, 0.8}}
-		err := store.UpsertFile(storeRecord.ID, file, chunks, embeddings)
-		require.NoError(t, err)
+	for i := 0; i < 30; i++ {
+		name := fmt.Sprintf("file%d.go", i)
+		file := FileInput{ExternalID: name, Path: "/big/" + name, RelativePath: name, Hash: name, FileSize: 10}
+		chunks := []Chunk{{Content: name, StartLine: 1, EndLine: 1}}
+		require.NoError(t, store.UpsertFile(big.ID, file, chunks, [][]float32{{1, 0, 0, 0}}))
 	}
 
-	// Get stats
-	stats, err := store.GetStats(storeRecord.ID)
+	small, err := store.CreateStore("small", "/small", ProviderOllama, "model", 4)
 	require.NoError(t, err)
+	file := FileInput{ExternalID: "only.go", Path: "/small/only.go", RelativePath: "only.go", Hash: "h", FileSize: 10}
+	require.NoError(t, store.UpsertFile(small.ID, file, []Chunk{{Content: "only", StartLine: 1, EndLine: 1}}, [][]float32{{0.5, 0.5, 0, 0}}))
 
-	assert.Equal(t, 3, stats.FileCount)
-	assert.Equal(t, 6, stats.ChunkCount)         // 3 files * 2 chunks each
-	assert.Equal(t, int64(600), stats.TotalSize) // 100 + 200 + 300
+	// The small store's chunk is found even though it is not among the
+	// nearest vectors overall
+	results, err := store.Search(small.ID, []float32{1, 0, 0, 0}, 1, -1, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "only.go", results[0].File.ExternalID)
 }
 
-func TestClearStore(t *testing.T) {
+func TestVectorSearchL2(t *testing.T) {
 	store := setupTestStore(t)
 	defer store.Close()
 
 	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
 	require.NoError(t, err)
+	assert.Equal(t, config.MetricCosine, storeRecord.DistanceMetric)
 
-	// Insert a file
-	file := FileInput{ExternalID: "a.go", Path: "/path/a.go", RelativePath: "a.go", Hash: "h", FileSize: 100}
-	chunks := []Chunk{{Content: "x", StartLine: 1, EndLine: 1, ChunkIndex: 0}}
-	embeddings := [][]float32{{0.1, 0.2, 0.3, 0.4}}
-	err = store.UpsertFile(storeRecord.ID, file, chunks, embeddings)
+	// "far" points the same way as the query but is much longer
+	for name, embedding := range map[string][]float32{"far.go": {10, 0, 0, 0}, "near.go": {0.8, 0.3, 0, 0}} {
+		file := FileInput{ExternalID: name, Path: "/path/" + name, RelativePath: name, Hash: name, FileSize: 10}
+		require.NoError(t, store.UpsertFile(storeRecord.ID, file, []Chunk{{Content: name, StartLine: 1, EndLine: 1}}, [][]float32{embedding}))
+	}
+	other, err := store.CreateStore("other", "/other", ProviderOllama, "model", 4)
 	require.NoError(t, err)
+	file := FileInput{ExternalID: "same.go", Path: "/other/same.go", RelativePath: "same.go", Hash: "h", FileSize: 10}
+	require.NoError(t, store.UpsertFile(other.ID, file, []Chunk{{Content: "same", StartLine: 1, EndLine: 1}}, [][]float32{{1, 0, 0, 0}}))
 
-	// Clear store
-	err = store.ClearStore(storeRecord.ID)
+	query := []float32{1, 0, 0, 0}
+	results, err := store.Search(storeRecord.ID, query, 2, -1, nil)
 	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "far.go", results[0].File.ExternalID)
 
-	// Verify files are gone but store remains
-	files, err := store.ListFiles(storeRecord.ID, nil)
+	require.NoError(t, store.SetDistanceMetric(storeRecord.ID, config.MetricL2))
+	storeRecord, err = store.GetStore("test")
 	require.NoError(t, err)
-	assert.Empty(t, files)
+	assert.Equal(t, config.MetricL2, storeRecord.DistanceMetric)
 
-	retrieved, err := store.GetStore("test")
+	// By L2 distance the short vector is nearer, and only the searched
+	// store's vectors are considered
+	results, err = store.Search(storeRecord.ID, query, 2, -1, nil)
 	require.NoError(t, err)
-	assert.NotNil(t, retrieved)
+	require.Len(t, results, 2)
+	assert.Equal(t, "near.go", results[0].File.ExternalID)
+	assert.InDelta(t, math.Sqrt(0.04+0.09), results[0].Distance, 1e-6)
+	assert.InDelta(t, 1-(0.04+0.09)/2, results[0].Score, 1e-6)
+	assert.Equal(t, "far.go", results[1].File.ExternalID)
+
+	// The score threshold drops the distant vector
+	results, err = store.Search(storeRecord.ID, query, 2, 0.5, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "near.go", results[0].File.ExternalID)
 }
 
-func TestSerializeEmbedding(t *testing.T) {
-	embedding := []float32{1.0, 2.0, 3.0, 4.0}
-	serialized := serializeEmbedding(embedding)
+// TestVectorSearchDot tests that a dot product store ranks and scores chunks
+// by the dot product of the embeddings rather than their angle.
+func TestVectorSearchDot(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
 
-	// Each float32 is 4 bytes
-	assert.Len(t, serialized, 16)
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	require.NoError(t, store.SetDistanceMetric(storeRecord.ID, config.MetricDot))
+	for name, embedding := range map[string][]float32{"long.go": {2, 2, 0, 0}, "aligned.go": {0.9, 0, 0, 0}, "away.go": {-1, 0, 0, 0}} {
+		file := FileInput{ExternalID: name, Path: "/path/" + name, RelativePath: name, Hash: name, FileSize: 10}
+		require.NoError(t, store.UpsertFile(storeRecord.ID, file, []Chunk{{Content: name, StartLine: 1, EndLine: 1}}, [][]float32{embedding}))
+	}
 
-	// Verify it's little-endian
-	// 1.0f = 0x3f800000
-	assert.Equal(t, byte(0x00), serialized[0])
-	assert.Equal(t, byte(0x00), serialized[1])
-	assert.Equal(t, byte(0x80), serialized[2])
-	assert.Equal(t, byte(0x3f), serialized[3])
+	query := []float32{1, 0, 0, 0}
+	results, err := store.Search(storeRecord.ID, query, 10, -1, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, "long.go", results[0].Chunk.Content)
+	assert.InDelta(t, 2, results[0].Score, 1e-5)
+	assert.Equal(t, "aligned.go", results[1].Chunk.Content)
+	assert.InDelta(t, 0.9, results[1].Score, 1e-5)
+	assert.InDelta(t, -1, results[2].Score, 1e-5)
+
+	// The threshold and limit apply to the dot product, with and without a
+	// path restriction
+	results, err = store.Search(storeRecord.ID, query, 10, 0.5, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	results, err = store.Search(storeRecord.ID, query, 1, -1, &SearchOptions{Paths: []string{"aligned.go", "away.go"}})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "aligned.go", results[0].File.RelativePath)
 }
 
-func TestChunkEmbeddingMismatch(t *testing.T) {
+func TestVectorSearchPaths(t *testing.T) {
 	store := setupTestStore(t)
 	defer store.Close()
 
 	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
 	require.NoError(t, err)
+	for name, embedding := range map[string][]float32{"a.go": {1, 0, 0, 0}, "b.go": {0.6, 0.8, 0, 0}, "c.go": {0, 1, 0, 0}} {
+		file := FileInput{ExternalID: name, Path: "/path/" + name, RelativePath: name, Hash: name, FileSize: 10}
+		require.NoError(t, store.UpsertFile(storeRecord.ID, file, []Chunk{{Content: name, StartLine: 1, EndLine: 1}}, [][]float32{embedding}))
+	}
+	other, err := store.CreateStore("other", "/other", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	file := FileInput{ExternalID: "b.go", Path: "/other/b.go", RelativePath: "b.go", Hash: "h", FileSize: 10}
+	require.NoError(t, store.UpsertFile(other.ID, file, []Chunk{{Content: "other", StartLine: 1, EndLine: 1}}, [][]float32{{1, 0, 0, 0}}))
+
+	// Only the listed files of the searched store are scored, ranked and
+	// thresholded as a vector search would
+	query := []float32{1, 0, 0, 0}
+	opts := &SearchOptions{Paths: []string{"b.go", "c.go", "missing.go"}}
+	results, err := store.Search(storeRecord.ID, query, 10, -1, opts)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "b.go", results[0].Chunk.Content)
+	assert.InDelta(t, 0.6, results[0].Score, 1e-6)
+	assert.Equal(t, "c.go", results[1].File.RelativePath)
 
-	file := FileInput{ExternalID: "a.go", Path: "/path/a.go", RelativePath: "a.go", Hash: "h", FileSize: 100}
-	chunks := []Chunk{{Content: "x", StartLine: 1, EndLine: 1, ChunkIndex: 0}}
-	embeddings := [][]float32{} // Empty - mismatch!
+	results, err = store.Search(storeRecord.ID, query, 10, 0.5, opts)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "b.go", results[0].File.RelativePath)
 
-	err = store.UpsertFile(storeRecord.ID, file, chunks, embeddings)
-	assert.Error(t, err)
+	require.NoError(t, store.SetDistanceMetric(storeRecord.ID, config.MetricL2))
+	results, err = store.Search(storeRecord.ID, query, 1, -1, opts)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "b.go", results[0].File.RelativePath)
+
+	// An empty list matches nothing
+	results, err = store.Search(storeRecord.ID, query, 10, -1, &SearchOptions{Paths: []string{}})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+// TestVectorSearchPathPrefix tests that a path prefix restricts a search to
+// a file or the files under a directory, not to paths merely starting with it.
+func TestVectorSearchPathPrefix(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	for _, name := range []string{"internal/a.go", "internal/sub/b.go", "internals.go", "main.go"} {
+		file := FileInput{ExternalID: name, Path: "/path/" + name, RelativePath: name, Hash: name, FileSize: 10}
+		require.NoError(t, store.UpsertFile(storeRecord.ID, file, []Chunk{{Content: name, StartLine: 1, EndLine: 1}}, [][]float32{{1, 0, 0, 0}}))
+	}
+
+	search := func(prefix string) []string {
+		results, err := store.Search(storeRecord.ID, []float32{1, 0, 0, 0}, 10, -1, &SearchOptions{PathPrefix: prefix})
+		require.NoError(t, err)
+		var paths []string
+		for _, r := range results {
+			paths = append(paths, r.File.RelativePath)
+		}
+		return paths
+	}
+	assert.ElementsMatch(t, []string{"internal/a.go", "internal/sub/b.go"}, search("internal"))
+	assert.Equal(t, []string{"internal/sub/b.go"}, search("internal/sub"))
+	assert.Equal(t, []string{"main.go"}, search("main.go"))
+	assert.Empty(t, search("missing"))
+}
+
+func TestListChunkVectors(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	other, err := store.CreateStore("other", "/other", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("file%d.go", i)
+		file := FileInput{ExternalID: name, Path: "/path/" + name, RelativePath: name, Hash: name, FileSize: 10}
+		chunks := []Chunk{{Content: name, StartLine: 1, EndLine: 1}}
+		require.NoError(t, store.UpsertFile(storeRecord.ID, file, chunks, [][]float32{{float32(i), 1, 0, 0}}))
+	}
+	file := FileInput{ExternalID: "x.go", Path: "/other/x.go", RelativePath: "x.go", Hash: "x", FileSize: 10}
+	require.NoError(t, store.UpsertFile(other.ID, file, []Chunk{{Content: "x", StartLine: 1, EndLine: 1}}, [][]float32{{0, 0, 1, 0}}))
+
+	// Page through the store's vectors only
+	var all []ChunkVector
+	var after int64
+	for {
+		page, err := store.ListChunkVectors(storeRecord.ID, after, 2)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		assert.LessOrEqual(t, len(page), 2)
+		all = append(all, page...)
+		after = page[len(page)-1].ChunkID
+	}
+	require.Len(t, all, 5)
+	for i, v := range all {
+		assert.Equal(t, []float32{float32(i), 1, 0, 0}, v.Embedding)
+	}
+
+	// The chunks behind the vectors can be looked up by ID
+	chunks, err := store.GetChunks([]int64{all[3].ChunkID, all[1].ChunkID, 99999})
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "file1.go", chunks[0].File.RelativePath)
+	assert.Equal(t, "file3.go", chunks[1].File.RelativePath)
+	assert.Equal(t, all[3].ChunkID, chunks[1].Chunk.ID)
+
+	// Or by file
+	record, err := store.GetFileByExternalID(storeRecord.ID, "file2.go")
+	require.NoError(t, err)
+	vectors, err := store.GetFileVectors(record.ID)
+	require.NoError(t, err)
+	require.Len(t, vectors, 1)
+	assert.Equal(t, all[2], vectors[0])
+}
+
+func TestIndexRuns(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	rec, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	running := &IndexRun{StoreID: rec.ID, Trigger: TriggerCLI}
+	require.NoError(t, store.AddIndexRun(running))
+	assert.Equal(t, os.Getpid(), running.PID)
+
+	runs, err := store.ListIndexRuns(rec.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, RunRunning, runs[0].Status)
+	assert.True(t, runs[0].FinishedAt.IsZero())
+
+	running.Files, running.Errors, running.Status, running.Error = 4, 1, RunFailed, "boom"
+	require.NoError(t, store.FinishIndexRun(running))
+
+	// A run added with a finish time is complete
+	watch := &IndexRun{StoreID: rec.ID, Trigger: TriggerWatch, Files: 2, Status: RunOK, FinishedAt: time.Now()}
+	require.NoError(t, store.AddIndexRun(watch))
+
+	runs, err = store.ListIndexRuns(rec.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, TriggerWatch, runs[0].Trigger)
+	assert.Equal(t, RunFailed, runs[1].Status)
+	assert.Equal(t, 4, runs[1].Files)
+	assert.Equal(t, "boom", runs[1].Error)
+	assert.False(t, runs[1].FinishedAt.IsZero())
+
+	limited, err := store.ListIndexRuns(rec.ID, 1)
+	require.NoError(t, err)
+	assert.Len(t, limited, 1)
+
+	// Runs are deleted with their store
+	require.NoError(t, store.DeleteStore("test"))
+	runs, err = store.ListIndexRuns(rec.ID, 0)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+}
+
+func TestScoreCalibration(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	rec, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	// Too few chunks to calibrate
+	calibrated, err := store.CalibrateScores(rec.ID)
+	require.NoError(t, err)
+	assert.Nil(t, calibrated)
+
+	for i := range 20 {
+		name := fmt.Sprintf("file%d.go", i)
+		x := float32(i) / 20
+		err := store.UpsertFile(rec.ID, FileInput{ExternalID: name, Path: "/path/" + name, RelativePath: name, Hash: name},
+			[]Chunk{{Content: name, StartLine: 1, EndLine: 1}}, [][]float32{{1, x, 1 - x, x * x}})
+		require.NoError(t, err)
+	}
+
+	calibrated, err = store.CalibrateScores(rec.ID)
+	require.NoError(t, err)
+	require.NotNil(t, calibrated)
+	assert.Equal(t, 20, calibrated.Samples)
+	assert.Less(t, calibrated.Baseline, calibrated.High)
+
+	c, err := store.GetScoreCalibration(rec.ID)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.InDelta(t, calibrated.Baseline, c.Baseline, 1e-9)
+	assert.InDelta(t, calibrated.High, c.High, 1e-9)
+
+	// Relevance is anchored on the sampled distribution
+	assert.Equal(t, 0.0, c.Relevance(c.Baseline-0.1))
+	assert.InDelta(t, 50, c.Relevance(c.High), 1e-9)
+	assert.Equal(t, 100.0, c.Relevance(1))
+	for _, relevance := range []float64{10, 50, 75} {
+		assert.InDelta(t, relevance, c.Relevance(c.MinScore(relevance)), 1e-9)
+	}
+
+	// A few more chunks reuse the calibration, many more redo it
+	addChunks := func(from, to int) {
+		for i := from; i < to; i++ {
+			name := fmt.Sprintf("file%d.go", i)
+			x := float32(i) / 20
+			err := store.UpsertFile(rec.ID, FileInput{ExternalID: name, Path: "/path/" + name, RelativePath: name, Hash: name},
+				[]Chunk{{Content: name, StartLine: 1, EndLine: 1}}, [][]float32{{1, x, 1 - x, x * x}})
+			require.NoError(t, err)
+		}
+	}
+	addChunks(20, 22)
+	again, err := store.CalibrateScores(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 20, again.Chunks)
+	addChunks(22, 30)
+	again, err = store.CalibrateScores(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 30, again.Chunks)
+	assert.Equal(t, config.MetricCosine, again.Metric)
+
+	// Scores are sampled with the store's metric, as Search scores them
+	require.NoError(t, store.SetDistanceMetric(rec.ID, config.MetricL2))
+	l2, err := store.CalibrateScores(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, config.MetricL2, l2.Metric)
+	assert.NotEqual(t, again.Baseline, l2.Baseline)
+	require.NoError(t, store.SetDistanceMetric(rec.ID, config.MetricCosine))
+
+	// Without a calibration the score is scaled directly
+	var none *ScoreCalibration
+	assert.InDelta(t, 42, none.Relevance(0.42), 1e-9)
+	assert.InDelta(t, 0.42, none.MinScore(42), 1e-9)
+
+	// Calibrations are deleted with their store
+	require.NoError(t, store.DeleteStore("test"))
+	c, err = store.GetScoreCalibration(rec.ID)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+}
+
+func TestChunkSymbols(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	rec, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	count, err := store.CountSymbols(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	chunks := []Chunk{
+		{Content: "func ParseConfig() {}", StartLine: 1, EndLine: 1, Symbols: []string{"ParseConfig"}},
+		{Content: "func ParseArgs() {\n\tParseConfig()\n}", StartLine: 3, EndLine: 5, ChunkIndex: 1, Symbols: []string{"ParseArgs"}},
+		{Content: "func run() {\n\tParseConfigs()\n}", StartLine: 7, EndLine: 9, ChunkIndex: 2, Symbols: []string{"run"}},
+	}
+	file := FileInput{ExternalID: "main.go", Path: "/path/main.go", RelativePath: "main.go", Hash: "h1"}
+	embeddings := [][]float32{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}}
+	require.NoError(t, store.UpsertFile(rec.ID, file, chunks, embeddings))
+
+	count, err = store.CountSymbols(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// Exact names are case-sensitive
+	matches, err := store.FindSymbols(rec.ID, "ParseConfig", false, 0)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "ParseConfig", matches[0].Symbol)
+	assert.Equal(t, 1, matches[0].Chunk.StartLine)
+	assert.Equal(t, "main.go", matches[0].File.RelativePath)
+
+	matches, err = store.FindSymbols(rec.ID, "parseconfig", false, 0)
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+
+	// Prefixes match every symbol, ordered by name; wildcards are literal
+	matches, err = store.FindSymbols(rec.ID, "Parse", true, 0)
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	assert.Equal(t, "ParseArgs", matches[0].Symbol)
+	assert.Equal(t, "ParseConfig", matches[1].Symbol)
+
+	matches, err = store.FindSymbols(rec.ID, "Parse", true, 1)
+	require.NoError(t, err)
+	assert.Len(t, matches, 1)
+
+	matches, err = store.FindSymbols(rec.ID, "P*", true, 0)
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+
+	// References match whole identifiers only
+	refs, err := store.FindReferences(rec.ID, "ParseConfig", 0)
+	require.NoError(t, err)
+	require.Len(t, refs, 2)
+	assert.Equal(t, 1, refs[0].Chunk.StartLine)
+	assert.Equal(t, 3, refs[1].Chunk.StartLine)
+
+	// Re-indexing the file replaces its symbols
+	require.NoError(t, store.UpsertFile(rec.ID, file, chunks[:1], embeddings[:1]))
+	count, err = store.CountSymbols(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	require.NoError(t, store.DeleteFile(rec.ID, "main.go"))
+	count, err = store.CountSymbols(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestFileImports(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	rec, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	file := FileInput{ExternalID: "main.go", Path: "/path/main.go", RelativePath: "main.go", Hash: "h1",
+		Imports: []string{"fmt", "example.com/app/internal/store"}}
+	chunks := []Chunk{{Content: "package main", StartLine: 1, EndLine: 1}}
+	embeddings := [][]float32{{1, 0, 0, 0}}
+	require.NoError(t, store.UpsertFile(rec.ID, file, chunks, embeddings))
+
+	f, err := store.GetFileByExternalID(rec.ID, "main.go")
+	require.NoError(t, err)
+	imports, err := store.GetFileImports(f.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fmt", "example.com/app/internal/store"}, imports)
+
+	// Re-indexing replaces the imports
+	file.Imports = []string{"os"}
+	require.NoError(t, store.UpsertFile(rec.ID, file, chunks, embeddings))
+	imports, err = store.GetFileImports(f.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"os"}, imports)
+
+	file.Imports = nil
+	fileID, err := store.BeginFile(rec.ID, file)
+	require.NoError(t, err)
+	imports, err = store.GetFileImports(fileID)
+	require.NoError(t, err)
+	assert.Empty(t, imports)
+}
+
+func TestFileRelations(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	rec, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	ids := make(map[string]int64)
+	for _, relPath := range []string{"a.go", "b.go", "c.go"} {
+		file := FileInput{ExternalID: relPath, Path: "/path/" + relPath, RelativePath: relPath, Hash: relPath,
+			Imports: []string{"pkg/" + relPath}}
+		chunks := []Chunk{{Content: "package main", StartLine: 1, EndLine: 1}}
+		require.NoError(t, store.UpsertFile(rec.ID, file, chunks, [][]float32{{1, 0, 0, 0}}))
+		f, err := store.GetFileByExternalID(rec.ID, relPath)
+		require.NoError(t, err)
+		ids[relPath] = f.ID
+	}
+
+	imports, err := store.ListStoreImports(rec.ID)
+	require.NoError(t, err)
+	assert.Len(t, imports, 3)
+	assert.Equal(t, []string{"pkg/b.go"}, imports[ids["b.go"]])
+
+	// a.go imports b.go and c.go, which imports b.go
+	require.NoError(t, store.ReplaceFileRelations(rec.ID, []FileRelation{
+		{FromFileID: ids["a.go"], ToFileID: ids["c.go"]},
+		{FromFileID: ids["a.go"], ToFileID: ids["b.go"]},
+		{FromFileID: ids["c.go"], ToFileID: ids["b.go"]},
+	}))
+	count, err := store.CountFileRelations(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	paths := func(files []FileRecord) []string {
+		var p []string
+		for _, f := range files {
+			p = append(p, f.RelativePath)
+		}
+		return p
+	}
+	imported, importedBy, err := store.GetRelatedFiles(ids["c.go"])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b.go"}, paths(imported))
+	assert.Equal(t, []string{"a.go"}, paths(importedBy))
+
+	related, err := store.GetRelatedFileIDs([]int64{ids["a.go"], ids["c.go"]})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{ids["b.go"]}, related)
+
+	// Updating replaces the relations of the given files only
+	require.NoError(t, store.UpdateFileRelations([]int64{ids["c.go"]}, []FileRelation{
+		{FromFileID: ids["c.go"], ToFileID: ids["a.go"]},
+		{FromFileID: ids["b.go"], ToFileID: ids["c.go"]},
+	}))
+	imported, importedBy, err = store.GetRelatedFiles(ids["c.go"])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.go"}, paths(imported))
+	assert.Equal(t, []string{"a.go", "b.go"}, paths(importedBy))
+
+	// Replacing drops the old relations, and deleting a file drops its own
+	require.NoError(t, store.ReplaceFileRelations(rec.ID, []FileRelation{
+		{FromFileID: ids["a.go"], ToFileID: ids["b.go"]},
+		{FromFileID: ids["c.go"], ToFileID: ids["b.go"]},
+	}))
+	require.NoError(t, store.DeleteFile(rec.ID, "b.go"))
+	count, err = store.CountFileRelations(rec.ID)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestContainsIdentifier(t *testing.T) {
+	assert.True(t, ContainsIdentifier("x := Parse(y)", "Parse"))
+	assert.True(t, ContainsIdentifier("Parse", "Parse"))
+	assert.True(t, ContainsIdentifier("ParseAll(); Parse()", "Parse"))
+	assert.False(t, ContainsIdentifier("ParseAll()", "Parse"))
+	assert.False(t, ContainsIdentifier("reParse()", "Parse"))
+	assert.False(t, ContainsIdentifier("$Parse", "Parse"))
+	assert.False(t, ContainsIdentifier("anything", ""))
+}
+
+func TestEmbeddingBatches(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOpenAI, "model", 4)
+	require.NoError(t, err)
+
+	batch := &EmbeddingBatch{
+		StoreID: storeRecord.ID,
+		JobID:   "batch_abc",
+		Files:   []BatchFile{{RelativePath: "a.go", Hash: "h1", Chunks: 3}, {RelativePath: "b.go", Hash: "h2", Chunks: 1}},
+	}
+	require.NoError(t, store.AddEmbeddingBatch(batch))
+	assert.NotZero(t, batch.ID)
+	require.NoError(t, store.AddEmbeddingBatch(&EmbeddingBatch{StoreID: storeRecord.ID, JobID: "batch_def"}))
+
+	batches, err := store.ListEmbeddingBatches(storeRecord.ID)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+	assert.Equal(t, "batch_abc", batches[0].JobID)
+	assert.Equal(t, batch.Files, batches[0].Files)
+	assert.False(t, batches[0].CreatedAt.IsZero())
+
+	require.NoError(t, store.DeleteEmbeddingBatch(batch.ID))
+	batches, err = store.ListEmbeddingBatches(storeRecord.ID)
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, "batch_def", batches[0].JobID)
+
+	// Deleting the store drops its batches
+	require.NoError(t, store.DeleteStore("test"))
+	batches, err = store.ListEmbeddingBatches(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+}
+
+func TestPendingPaths(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOpenAI, "model", 4)
+	require.NoError(t, err)
+	other, err := store.CreateStore("other", "/other", ProviderOpenAI, "model", 4)
+	require.NoError(t, err)
+
+	require.NoError(t, store.AddPendingPaths(storeRecord.ID, []string{"b.go", "a.go"}))
+	require.NoError(t, store.AddPendingPaths(storeRecord.ID, []string{"a.go", "c/d.go"}))
+	require.NoError(t, store.AddPendingPaths(other.ID, []string{"x.go"}))
+
+	paths, err := store.TakePendingPaths(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.go", "b.go", "c/d.go"}, paths)
+
+	// Taking them forgets them, for this store only
+	paths, err = store.TakePendingPaths(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+	paths, err = store.TakePendingPaths(other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x.go"}, paths)
+}
+
+func TestWatchPause(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOpenAI, "model", 4)
+	require.NoError(t, err)
+
+	pausedAt, err := store.GetWatchPause(storeRecord.ID)
+	require.NoError(t, err)
+	assert.True(t, pausedAt.IsZero())
+
+	require.NoError(t, store.PauseWatch(storeRecord.ID))
+	pausedAt, err = store.GetWatchPause(storeRecord.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), pausedAt, time.Minute)
+
+	// Pausing again is fine
+	require.NoError(t, store.PauseWatch(storeRecord.ID))
+
+	require.NoError(t, store.ResumeWatch(storeRecord.ID))
+	pausedAt, err = store.GetWatchPause(storeRecord.ID)
+	require.NoError(t, err)
+	assert.True(t, pausedAt.IsZero())
+}
+
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	backupPath := filepath.Join(dir, "backup.db")
+	ctx := context.Background()
+
+	store, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+	_, err = store.CreateStore("kept", "/kept", ProviderOpenAI, "model", 4)
+	require.NoError(t, err)
+
+	// Backing up works while the database is open
+	require.NoError(t, BackupDatabase(ctx, dbPath, backupPath))
+	assert.Error(t, BackupDatabase(ctx, dbPath, backupPath), "existing backup is not overwritten")
+	_, err = os.Stat(backupPath + "-wal")
+	assert.True(t, os.IsNotExist(err), "backup is a single file")
+
+	require.NoError(t, store.DeleteStore("kept"))
+	_, err = store.CreateStore("added", "/added", ProviderOpenAI, "model", 4)
+	require.NoError(t, err)
+
+	// Restoring replaces the contents under the open store
+	require.NoError(t, RestoreDatabase(ctx, backupPath, dbPath))
+	stores, err := store.ListStores()
+	require.NoError(t, err)
+	require.Len(t, stores, 1)
+	assert.Equal(t, "kept", stores[0].Name)
+
+	notDB := filepath.Join(dir, "not.db")
+	require.NoError(t, os.WriteFile(notDB, []byte("hello"), 0644))
+	assert.Error(t, RestoreDatabase(ctx, notDB, dbPath))
+	assert.Error(t, RestoreDatabase(ctx, filepath.Join(dir, "missing.db"), dbPath))
+}
+
+func TestIntegrity(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		f := FileInput{ExternalID: name, RelativePath: name, Path: "/path/" + name, Language: "go"}
+		require.NoError(t, store.UpsertFile(storeRecord.ID, f, []Chunk{{Content: name}}, [][]float32{{0.1, 0.2, 0.3, 0.4}}))
+	}
+
+	report, err := store.CheckIntegrity()
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+
+	// Orphan a chunk by deleting its file without cascading, drop the
+	// vectors of the others, clear the stored embedding of one and add a
+	// vector without a chunk
+	ctx := context.Background()
+	conn, err := store.db.Conn(ctx)
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "DELETE FROM files WHERE relative_path = 'a.go'")
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	_, err = store.db.Exec("DELETE FROM chunk_vectors WHERE chunk_id IN (SELECT c.id FROM chunks c JOIN files f ON f.id = c.file_id WHERE f.relative_path IN ('b.go', 'c.go'))")
+	require.NoError(t, err)
+	_, err = store.db.Exec("UPDATE chunks SET embedding = NULL WHERE file_id = (SELECT id FROM files WHERE relative_path = 'c.go')")
+	require.NoError(t, err)
+	_, err = store.db.Exec("INSERT INTO chunk_vectors (chunk_id, store_id, embedding) VALUES (9999, ?, ?)",
+		storeRecord.ID, serializeEmbedding([]float32{1, 0, 0, 0}))
+	require.NoError(t, err)
+
+	report, err = store.CheckIntegrity()
+	require.NoError(t, err)
+	assert.Equal(t, &IntegrityReport{OrphanChunks: 1, OrphanVectors: 1, MissingVectors: 2, StoredVectors: 1}, report)
+	assert.False(t, report.OK())
+
+	// Repair drops the orphans, restores the vector from its stored
+	// embedding and drops the file without one, so it is indexed again
+	report, err = store.RepairIntegrity()
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	report, err = store.CheckIntegrity()
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+	files, err := store.ListFiles(storeRecord.ID, nil)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "b.go", files[0].RelativePath)
+
+	// A missing vector table is created again from the stored embeddings
+	_, err = store.db.Exec("DROP TABLE chunk_vectors")
+	require.NoError(t, err)
+	report, err = store.CheckIntegrity()
+	require.NoError(t, err)
+	assert.True(t, report.MissingVectorTable)
+	assert.Equal(t, 1, report.MissingVectors)
+	assert.Equal(t, 1, report.StoredVectors)
+	_, err = store.RepairIntegrity()
+	require.NoError(t, err)
+	report, err = store.CheckIntegrity()
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+	results, err := store.Search(storeRecord.ID, []float32{0.1, 0.2, 0.3, 0.4}, 5, -1, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "b.go", results[0].File.RelativePath)
+
+	// The vector table holds one size, so it is not created again for
+	// stores of different dimensions
+	_, err = store.CreateStore("wide", "/wide", ProviderOllama, "model", 8)
+	require.NoError(t, err)
+	_, err = store.db.Exec("DROP TABLE chunk_vectors")
+	require.NoError(t, err)
+	_, err = store.RepairIntegrity()
+	assert.ErrorContains(t, err, "different embedding dimensions [4 8]")
+	_, err = store.RebuildVectors()
+	assert.ErrorContains(t, err, "different embedding dimensions [4 8]")
+}
+
+func TestRebuildVectors(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	rebuilt, err := store.RebuildVectors()
+	require.NoError(t, err)
+	assert.Zero(t, rebuilt)
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	chunks := []Chunk{{Content: "a", StartLine: 1, EndLine: 1, ChunkIndex: 0}, {Content: "b", StartLine: 2, EndLine: 2, ChunkIndex: 1}}
+	embeddings := [][]float32{{1, 0, 0, 0}, {0, 1, 0, 0}}
+	file := FileInput{ExternalID: "a.go", Path: "/path/a.go", RelativePath: "a.go"}
+	require.NoError(t, store.UpsertFile(storeRecord.ID, file, chunks, embeddings))
+
+	// Lose the vectors, then rebuild them without the embedding provider
+	_, err = store.db.Exec("DELETE FROM chunk_vectors")
+	require.NoError(t, err)
+	rebuilt, err = store.RebuildVectors()
+	require.NoError(t, err)
+	assert.Equal(t, 2, rebuilt)
+
+	results, err := store.Search(storeRecord.ID, []float32{0, 1, 0, 0}, 1, -1, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "b", results[0].Chunk.Content)
+
+	report, err := store.CheckIntegrity()
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+}
+
+func TestResizeVectors(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	chunks := []Chunk{{Content: "a", StartLine: 1, EndLine: 1, ChunkIndex: 0}, {Content: "b", StartLine: 2, EndLine: 2, ChunkIndex: 1}}
+	embeddings := [][]float32{{1, 0, 0, 0}, {0, 1, 1, 0}}
+	file := FileInput{ExternalID: "a.go", Path: "/path/a.go", RelativePath: "a.go"}
+	require.NoError(t, store.UpsertFile(storeRecord.ID, file, chunks, embeddings))
+	firstTwo := func(embedding []float32) []float32 { return embedding[:2] }
+
+	_, err = store.ResizeVectors(8, firstTwo)
+	assert.ErrorContains(t, err, "2 one of fewer than 8 dimensions")
+
+	resized, err := store.ResizeVectors(2, firstTwo)
+	require.NoError(t, err)
+	assert.Equal(t, 2, resized)
+	storeRecord, err = store.GetStore("test")
+	require.NoError(t, err)
+	assert.Equal(t, 2, storeRecord.EmbeddingDimensions)
+	results, err := store.Search(storeRecord.ID, []float32{0, 1}, 1, -1, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "b", results[0].Chunk.Content)
+
+	// The stored embeddings are resized too, so rebuilds keep them
+	rebuilt, err := store.RebuildVectors()
+	require.NoError(t, err)
+	assert.Equal(t, 2, rebuilt)
+
+	// Stores of other models would lose their vectors
+	_, err = store.CreateStore("other", "/other", ProviderOpenAI, "model", 2)
+	require.NoError(t, err)
+	_, err = store.ResizeVectors(1, firstTwo)
+	assert.ErrorContains(t, err, "different embedding models")
+}
+
+func TestMigrateVectorPartitions(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	file := FileInput{ExternalID: "a.go", Path: "/path/a.go", RelativePath: "a.go", Hash: "h", FileSize: 10}
+	require.NoError(t, store.UpsertFile(storeRecord.ID, file, []Chunk{{Content: "a", StartLine: 1, EndLine: 1}}, [][]float32{{1, 0, 0, 0}}))
+
+	// Recreate the vector table as a v5 database had it
+	steps := []string{
+		"CREATE TABLE saved AS SELECT chunk_id, embedding FROM chunk_vectors",
+		"DROP TABLE chunk_vectors",
+		"CREATE VIRTUAL TABLE chunk_vectors USING vec0(chunk_id INTEGER PRIMARY KEY, embedding float[4] distance_metric=cosine)",
+		"INSERT INTO chunk_vectors (chunk_id, embedding) SELECT chunk_id, embedding FROM saved",
+		"DROP TABLE saved",
+	}
+	for _, step := range steps {
+		_, err := store.db.Exec(step)
+		require.NoError(t, err, step)
+	}
+	require.NoError(t, migrateV6(store.db))
+	require.NoError(t, store.Close())
+
+	store, err = NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+
+	results, err := store.Search(storeRecord.ID, []float32{1, 0, 0, 0}, 5, -1, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "a.go", results[0].File.ExternalID)
+}
+
+func TestGetStats(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	// Insert multiple files
+	for i := 0; i < 3; i++ {
+		name := string(rune('a'+i)) + ".go"
+		file := FileInput{ExternalID: name, Path: "/path/" + name, RelativePath: name, Hash: "h", FileSize: int64(100 * (i + 1))}
+		chunks := []Chunk{
+			{Content: "c1", StartLine: 1, EndLine: 5, ChunkIndex: 0},
+			{Content: "c2", StartLine: 6, EndLine: 10, ChunkIndex: 1},
+		}
+		embeddings := [][]float32{{0.1, 0.2, 0.3, 0.4}, {0.5, 0.6, 0.7, 0.8}}
+		err := store.UpsertFile(storeRecord.ID, file, chunks, embeddings)
+		require.NoError(t, err)
+	}
+
+	// Get stats
+	stats, err := store.GetStats(storeRecord.ID)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, stats.FileCount)
+	assert.Equal(t, 6, stats.ChunkCount)         // 3 files * 2 chunks each
+	assert.Equal(t, int64(600), stats.TotalSize) // 100 + 200 + 300
+}
+
+func TestCountFileChunks(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+	other, err := store.CreateStore("other", "/other", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	upsert := func(storeID int64, name string, n int) int64 {
+		var chunks []Chunk
+		var embeddings [][]float32
+		for i := 0; i < n; i++ {
+			chunks = append(chunks, Chunk{Content: "c", StartLine: i + 1, EndLine: i + 1, ChunkIndex: i})
+			embeddings = append(embeddings, []float32{0.1, 0.2, 0.3, 0.4})
+		}
+		file := FileInput{ExternalID: name, Path: "/path/" + name, RelativePath: name, Hash: name}
+		require.NoError(t, store.UpsertFile(storeID, file, chunks, embeddings))
+		f, err := store.GetFileByExternalID(storeID, name)
+		require.NoError(t, err)
+		return f.ID
+	}
+	a := upsert(storeRecord.ID, "a.go", 3)
+	b := upsert(storeRecord.ID, "b.go", 1)
+	upsert(storeRecord.ID, "empty.go", 0)
+	upsert(other.ID, "c.go", 2)
+
+	counts, err := store.CountFileChunks(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]int{a: 3, b: 1}, counts)
+}
+
+func TestClearStore(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	// Insert a file
+	file := FileInput{ExternalID: "a.go", Path: "/path/a.go", RelativePath: "a.go", Hash: "h", FileSize: 100}
+	chunks := []Chunk{{Content: "x", StartLine: 1, EndLine: 1, ChunkIndex: 0}}
+	embeddings := [][]float32{{0.1, 0.2, 0.3, 0.4}}
+	err = store.UpsertFile(storeRecord.ID, file, chunks, embeddings)
+	require.NoError(t, err)
+
+	// Clear store
+	err = store.ClearStore(storeRecord.ID)
+	require.NoError(t, err)
+
+	// Verify files are gone but store remains
+	files, err := store.ListFiles(storeRecord.ID, nil)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	retrieved, err := store.GetStore("test")
+	require.NoError(t, err)
+	assert.NotNil(t, retrieved)
+}
+
+func TestSerializeEmbedding(t *testing.T) {
+	embedding := []float32{1.0, 2.0, 3.0, 4.0}
+	serialized := serializeEmbedding(embedding)
+
+	// Each float32 is 4 bytes
+	assert.Len(t, serialized, 16)
+
+	// Verify it's little-endian
+	// 1.0f = 0x3f800000
+	assert.Equal(t, byte(0x00), serialized[0])
+	assert.Equal(t, byte(0x00), serialized[1])
+	assert.Equal(t, byte(0x80), serialized[2])
+	assert.Equal(t, byte(0x3f), serialized[3])
+}
+
+func TestChunkEmbeddingMismatch(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	storeRecord, err := store.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	file := FileInput{ExternalID: "a.go", Path: "/path/a.go", RelativePath: "a.go", Hash: "h", FileSize: 100}
+	chunks := []Chunk{{Content: "x", StartLine: 1, EndLine: 1, ChunkIndex: 0}}
+	embeddings := [][]float32{} // Empty - mismatch!
+
+	err = store.UpsertFile(storeRecord.ID, file, chunks, embeddings)
+	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "mismatch")
 }
 
+func TestQATranscripts(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	transcript := &QATranscript{
+		StoreName:   "test-project",
+		Question:    "How does auth work?",
+		Answer:      "It uses JWT tokens.",
+		Provider:    "ollama",
+		Model:       "llama3",
+		Sources:     []QASource{{RelativePath: "auth.go", StartLine: 1, EndLine: 10, Score: 0.8}},
+		ContextHash: "abc123",
+		Latency:     1500 * time.Millisecond,
+	}
+	require.NoError(t, store.AddQATranscript(transcript))
+	assert.NotZero(t, transcript.ID)
+
+	// Get by ID
+	retrieved, err := store.GetQATranscript(transcript.ID)
+	require.NoError(t, err)
+	require.NotNil(t, retrieved)
+	assert.Equal(t, "How does auth work?", retrieved.Question)
+	assert.Equal(t, 1500*time.Millisecond, retrieved.Latency)
+	assert.Equal(t, transcript.Sources, retrieved.Sources)
+
+	// Cache lookup
+	cached, err := store.FindQATranscript("test-project", "llama3", "abc123")
+	require.NoError(t, err)
+	require.NotNil(t, cached)
+	assert.Equal(t, transcript.ID, cached.ID)
+
+	missing, err := store.FindQATranscript("test-project", "other-model", "abc123")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	// List newest first
+	require.NoError(t, store.AddQATranscript(&QATranscript{StoreName: "test-project", Question: "second", ContextHash: "def"}))
+	list, err := store.ListQATranscripts(10)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "second", list[0].Question)
+}
+
+func TestUsage(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	rec, err := store.CreateStore("test-project", "/path", ProviderOpenAI, "text-embedding-3-small", 4)
+	require.NoError(t, err)
+
+	lastMonth := time.Now().AddDate(0, -1, -1)
+	require.NoError(t, store.AddUsage(&UsageRecord{StoreName: "test-project", Operation: "index", Provider: "openai", Model: "text-embedding-3-small", InputTokens: 1000, Cost: 0.5, CreatedAt: lastMonth}))
+	require.NoError(t, store.AddUsage(&UsageRecord{StoreName: "test-project", Operation: "search", Provider: "openai", Model: "text-embedding-3-small", InputTokens: 10, Cost: 0.25}))
+	require.NoError(t, store.AddUsage(&UsageRecord{StoreName: "other", Operation: "answer", Provider: "openai", Model: "gpt-4o", InputTokens: 100, OutputTokens: 50, Cost: 1}))
+
+	// Totals across all stores since yesterday
+	summary, err := store.GetUsageSummary("", time.Now().AddDate(0, 0, -1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(110), summary.InputTokens)
+	assert.Equal(t, int64(50), summary.OutputTokens)
+	assert.InDelta(t, 1.25, summary.Cost, 1e-9)
+
+	// Store stats include all recorded usage for the store
+	stats, err := store.GetStats(rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1010), stats.Usage.InputTokens)
+	assert.InDelta(t, 0.75, stats.Usage.Cost, 1e-9)
+}
+
+func TestMetrics(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	require.NoError(t, store.AddMetric(&Metric{Event: MetricSearch, StoreName: "a", Duration: 100 * time.Millisecond, Embed: 60 * time.Millisecond, DB: 20 * time.Millisecond, Count: 5}))
+	require.NoError(t, store.AddMetric(&Metric{Event: MetricSearch, StoreName: "a", Duration: 300 * time.Millisecond, Embed: 100 * time.Millisecond, DB: 40 * time.Millisecond, Count: 3}))
+	require.NoError(t, store.AddMetric(&Metric{Event: MetricIndex, StoreName: "a", Duration: 2 * time.Second, Count: 10}))
+	require.NoError(t, store.AddMetric(&Metric{Event: MetricWatch, StoreName: "a", Duration: time.Second, Count: 4, CreatedAt: time.Now().AddDate(0, 0, -10)}))
+
+	summaries, err := store.SummarizeMetrics(time.Now().AddDate(0, 0, -1))
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+
+	assert.Equal(t, MetricIndex, summaries[0].Event)
+	assert.Equal(t, 10, summaries[0].TotalCount)
+
+	search := summaries[1]
+	assert.Equal(t, MetricSearch, search.Event)
+	assert.Equal(t, 2, search.Runs)
+	assert.Equal(t, 200*time.Millisecond, search.AvgDuration)
+	assert.Equal(t, 80*time.Millisecond, search.AvgEmbed)
+	assert.Equal(t, 30*time.Millisecond, search.AvgDB)
+	assert.Equal(t, 300*time.Millisecond, search.MaxDuration)
+
+	// All time includes the older watcher batch
+	summaries, err = store.SummarizeMetrics(time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, summaries, 3)
+
+	// Answers total their tokens and cost, also per store
+	require.NoError(t, store.AddMetric(&Metric{Event: MetricAnswer, StoreName: "b", Duration: time.Second, Count: 5, InputTokens: 1000, OutputTokens: 200, Cost: 0.01}))
+	require.NoError(t, store.AddMetric(&Metric{Event: MetricAnswer, StoreName: "a", Duration: time.Second, Count: 5, InputTokens: 500, OutputTokens: 100}))
+	require.NoError(t, store.AddMetric(&Metric{Event: MetricAnswer, StoreName: "b", Duration: time.Second, Count: 5, InputTokens: 1000, OutputTokens: 300, Cost: 0.01}))
+
+	summaries, err = store.SummarizeMetrics(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, summaries, 4)
+	assert.Equal(t, MetricAnswer, summaries[0].Event)
+	assert.Equal(t, int64(2500), summaries[0].InputTokens)
+	assert.Equal(t, int64(600), summaries[0].OutputTokens)
+	assert.InDelta(t, 0.02, summaries[0].Cost, 1e-9)
+
+	byStore, err := store.SummarizeMetricsByStore(MetricAnswer, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, byStore, 2)
+	assert.Equal(t, "a", byStore[0].StoreName)
+	assert.Equal(t, 1, byStore[0].Runs)
+	assert.Equal(t, "b", byStore[1].StoreName)
+	assert.Equal(t, 2, byStore[1].Runs)
+	assert.Equal(t, int64(500), byStore[1].OutputTokens)
+}
+
 // Helper function to create a test store
 func setupTestStore(t *testing.T) *SQLiteStore {
 	tmpDir := t.TempDir()
@@ -366,3 +1870,114 @@ func normalizeVector(v []float32) []float32 {
 	}
 	return result
 }
+
+func TestReadOnlyStore(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	writer, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer writer.Close()
+
+	storeRecord, err := writer.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	reader, err := NewSQLiteStoreReadOnly(dbPath)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	// Reads work
+	got, err := reader.GetStore("test")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, storeRecord.ID, got.ID)
+
+	// Writes are rejected
+	err = reader.UpsertFile(storeRecord.ID, FileInput{ExternalID: "a.go", Path: "/path/a.go", RelativePath: "a.go", Hash: "h"},
+		[]Chunk{{Content: "x"}}, [][]float32{{0.1, 0.2, 0.3, 0.4}})
+	assert.Error(t, err)
+
+	// Best-effort metrics are dropped rather than failing
+	assert.NoError(t, reader.AddMetric(&Metric{Event: MetricSearch, StoreName: "test"}))
+}
+
+func TestReadOnlyStoreMissingDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "new.db")
+
+	// Falls back to creating the database
+	st, err := NewSQLiteStoreReadOnly(dbPath)
+	require.NoError(t, err)
+	defer st.Close()
+
+	stores, err := st.ListStores()
+	require.NoError(t, err)
+	assert.Empty(t, stores)
+}
+
+func TestConcurrentWriterAndReaders(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Separate connections simulate separate processes (watcher, MCP server, CLI)
+	writer, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer writer.Close()
+
+	secondWriter, err := NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer secondWriter.Close()
+
+	storeRecord, err := writer.CreateStore("test", "/path", ProviderOllama, "model", 4)
+	require.NoError(t, err)
+
+	reader, err := NewSQLiteStoreReadOnly(dbPath)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	const files = 50
+	errCh := make(chan error, 4*files)
+	done := make(chan struct{})
+
+	write := func(st *SQLiteStore, prefix string) {
+		for i := 0; i < files; i++ {
+			file := FileInput{
+				ExternalID:   fmt.Sprintf("%s%d.go", prefix, i),
+				Path:         fmt.Sprintf("/path/%s%d.go", prefix, i),
+				RelativePath: fmt.Sprintf("%s%d.go", prefix, i),
+				Hash:         "hash",
+			}
+			chunks := []Chunk{{Content: "content", StartLine: 1, EndLine: 1}}
+			if err := st.UpsertFile(storeRecord.ID, file, chunks, [][]float32{normalizeVector([]float32{1, float32(i), 0, 0})}); err != nil {
+				errCh <- err
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); write(writer, "a") }()
+	go func() { defer wg.Done(); write(secondWriter, "b") }()
+	go func() { wg.Wait(); close(done) }()
+
+	// Search continuously while the writers run
+	for searching := true; searching; {
+		select {
+		case <-done:
+			searching = false
+		default:
+		}
+		if _, err := reader.Search(storeRecord.ID, []float32{1, 0, 0, 0}, 5, -1, nil); err != nil {
+			errCh <- err
+		}
+		if _, err := reader.GetStats(storeRecord.ID); err != nil {
+			errCh <- err
+		}
+	}
+	close(errCh)
+
+	for err := range errCh {
+		t.Errorf("concurrent access failed: %v", err)
+	}
+
+	stats, err := reader.GetStats(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2*files, stats.FileCount)
+}
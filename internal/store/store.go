@@ -1,5 +1,11 @@
 package store
 
+import (
+	"time"
+
+	"github.com/nickcecere/lgrep/internal/config"
+)
+
 // Store defines the interface for vector storage operations.
 type Store interface {
 	// Store management
@@ -9,21 +15,105 @@ type Store interface {
 	DeleteStore(name string) error
 	ListStores() ([]StoreRecord, error)
 	UpdateStoreTimestamp(id int64) error
+	SetDistanceMetric(id int64, metric config.DistanceMetric) error
+	RenameStore(oldName, newName string) error
+
+	// Store aliases
+	AddStoreAlias(name, alias string) error
+	RemoveStoreAlias(alias string) error
+	GetStoreAliases(storeID int64) ([]string, error)
 
 	// File operations
 	UpsertFile(storeID int64, file FileInput, chunks []Chunk, embeddings [][]float32) error
+	UpsertFiles(storeID int64, files []FileUpsert) error
+	BeginFile(storeID int64, file FileInput) (int64, error)
+	AppendChunks(fileID int64, chunks []Chunk, embeddings [][]float32) error
+	FinishFile(fileID int64, hash string) error
 	DeleteFile(storeID int64, externalID string) error
 	GetFileByExternalID(storeID int64, externalID string) (*FileRecord, error)
 	GetFileByHash(storeID int64, hash string) (*FileRecord, error)
+	GetFileContent(fileID int64) (string, error)
 	ListFiles(storeID int64, opts *ListFilesOptions) ([]FileRecord, error)
+	WalkFiles(storeID int64, opts *ListFilesOptions, fn func(FileRecord) error) error
 
 	// Search
-	Search(storeID int64, queryEmbedding []float32, topK int) ([]SearchResult, error)
+	Search(storeID int64, queryEmbedding []float32, topK int, minScore float64, opts *SearchOptions) ([]SearchResult, error)
+	ListChunkVectors(storeID, afterID int64, limit int) ([]ChunkVector, error)
+	GetChunks(chunkIDs []int64) ([]SearchResult, error)
+	GetChunkContent(chunkIDs []int64) (map[int64]string, error)
+	GetFileVectors(fileID int64) ([]ChunkVector, error)
+
+	// Symbols
+	FindSymbols(storeID int64, name string, prefix bool, limit int) ([]SymbolMatch, error)
+	FindReferences(storeID int64, name string, limit int) ([]SearchResult, error)
+	CountSymbols(storeID int64) (int, error)
+	GetFileSymbols(fileIDs []int64) ([]SymbolMatch, error)
+	GetFileImports(fileID int64) ([]string, error)
+
+	// File relations
+	ListStoreImports(storeID int64) (map[int64][]string, error)
+	ReplaceFileRelations(storeID int64, relations []FileRelation) error
+	UpdateFileRelations(fromFileIDs []int64, relations []FileRelation) error
+	GetRelatedFiles(fileID int64) (imports, importedBy []FileRecord, err error)
+	GetRelatedFileIDs(fileIDs []int64) ([]int64, error)
+	CountFileRelations(storeID int64) (int, error)
 
 	// Stats
 	GetStats(storeID int64) (*StoreStats, error)
+	CountFileChunks(storeID int64) (map[int64]int, error)
+
+	// Q&A history
+	AddQATranscript(t *QATranscript) error
+	GetQATranscript(id int64) (*QATranscript, error)
+	ListQATranscripts(limit int) ([]QATranscript, error)
+	FindQATranscript(storeName, model, contextHash string) (*QATranscript, error)
+
+	// Search log and result feedback
+	AddSearchLog(e *SearchLogEntry) error
+	GetLastSearch() (*SearchLogEntry, error)
+	SetSearchShown(id int64, shown int) error
+	FindSearchQuery(storeID, chunkID int64) (string, error)
+	AddFeedback(f *Feedback) error
+	ListFeedback(storeID int64) ([]Feedback, error)
+	CountFeedback(storeID int64) (map[string]FeedbackCount, error)
+
+	// Provider usage
+	AddUsage(u *UsageRecord) error
+	GetUsageSummary(storeName string, since time.Time) (*UsageSummary, error)
+
+	// Local metrics
+	AddMetric(m *Metric) error
+	SummarizeMetrics(since time.Time) ([]MetricSummary, error)
+	SummarizeMetricsByStore(event string, since time.Time) ([]MetricSummary, error)
+
+	// Index run history
+	AddIndexRun(r *IndexRun) error
+	FinishIndexRun(r *IndexRun) error
+	ListIndexRuns(storeID int64, limit int) ([]IndexRun, error)
+
+	// Score calibration
+	CalibrateScores(storeID int64) (*ScoreCalibration, error)
+	GetScoreCalibration(storeID int64) (*ScoreCalibration, error)
+
+	// Pending embedding batches
+	AddEmbeddingBatch(b *EmbeddingBatch) error
+	ListEmbeddingBatches(storeID int64) ([]EmbeddingBatch, error)
+	DeleteEmbeddingBatch(id int64) error
+
+	// Watcher changes left over at shutdown, and pausing watchers
+	AddPendingPaths(storeID int64, paths []string) error
+	TakePendingPaths(storeID int64) ([]string, error)
+	PauseWatch(storeID int64) error
+	ResumeWatch(storeID int64) error
+	GetWatchPause(storeID int64) (time.Time, error)
 
 	// Maintenance
 	ClearStore(storeID int64) error
+	ReplaceStoreContents(targetID, sourceID int64) error
+	Vacuum() error
+	CheckIntegrity() (*IntegrityReport, error)
+	RepairIntegrity() (*IntegrityReport, error)
+	RebuildVectors() (int, error)
+	ResizeVectors(dimensions int, resize func([]float32) []float32) (int, error)
 	Close() error
 }
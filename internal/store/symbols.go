@@ -0,0 +1,229 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// symbolResultColumns are the chunk and file columns scanned by scanSymbolRow.
+const symbolResultColumns = `
+	c.id, c.file_id, c.chunk_index, c.content, c.start_line, c.end_line,
+	c.context_before, c.context_after,
+	f.id, f.store_id, f.external_id, f.path, f.relative_path, f.hash, f.file_size, f.indexed_at,
+	f.generated, f.language, f.modified_at`
+
+// FindSymbols returns the chunks of a store that define name, ordered by
+// symbol and path. With prefix, it returns the chunks defining any symbol
+// that starts with name. Names are matched case-sensitively. A limit of zero
+// or less returns all matches.
+func (s *SQLiteStore) FindSymbols(storeID int64, name string, prefix bool, limit int) ([]SymbolMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cond, arg := "s.name = ?", name
+	if prefix {
+		// GLOB is case-sensitive and can use the index for a prefix
+		cond, arg = "s.name GLOB ?", globEscape(name)+"*"
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := s.db.Query(`
+		SELECT s.name,`+symbolResultColumns+`
+		FROM chunk_symbols s
+		JOIN chunks c ON c.id = s.chunk_id
+		JOIN files f ON f.id = c.file_id
+		WHERE f.store_id = ? AND `+cond+`
+		ORDER BY s.name, f.relative_path, c.start_line
+		LIMIT ?
+	`, storeID, arg, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find symbols: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []SymbolMatch
+	for rows.Next() {
+		var m SymbolMatch
+		if err := scanSymbolRow(rows, &m.Symbol, &m.Chunk, &m.File); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// GetFileSymbols returns the symbols defined in the given files, with the
+// chunks defining them, ordered by file and line.
+func (s *SQLiteStore) GetFileSymbols(fileIDs []int64) ([]SymbolMatch, error) {
+	if len(fileIDs) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	placeholders := strings.Repeat("?,", len(fileIDs))
+	placeholders = placeholders[:len(placeholders)-1]
+	args := make([]any, len(fileIDs))
+	for i, id := range fileIDs {
+		args[i] = id
+	}
+
+	rows, err := s.db.Query(`
+		SELECT s.name,`+symbolResultColumns+`
+		FROM chunk_symbols s
+		JOIN chunks c ON c.id = s.chunk_id
+		JOIN files f ON f.id = c.file_id
+		WHERE f.id IN (`+placeholders+`)
+		ORDER BY f.relative_path, c.start_line
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file symbols: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []SymbolMatch
+	for rows.Next() {
+		var m SymbolMatch
+		if err := scanSymbolRow(rows, &m.Symbol, &m.Chunk, &m.File); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// FindReferences returns the chunks of a store whose content contains name as
+// a whole identifier, ordered by path and line. A limit of zero or less
+// returns all matches.
+func (s *SQLiteStore) FindReferences(storeID int64, name string, limit int) ([]SearchResult, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// LIKE narrows the candidates; it ignores ASCII case and word
+	// boundaries, which are checked below
+	rows, err := s.db.Query(`
+		SELECT `+symbolResultColumns+`
+		FROM chunks c
+		JOIN files f ON f.id = c.file_id
+		WHERE f.store_id = ? AND c.content LIKE ? ESCAPE '\'
+		ORDER BY f.relative_path, c.start_line
+	`, storeID, "%"+likeEscape(name)+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find references: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := scanSymbolRow(rows, nil, &r.Chunk, &r.File); err != nil {
+			return nil, err
+		}
+		if !ContainsIdentifier(r.Chunk.Content, name) {
+			continue
+		}
+		results = append(results, r)
+		if limit > 0 && len(results) >= limit {
+			break
+		}
+	}
+	return results, rows.Err()
+}
+
+// CountSymbols returns the number of symbol definitions recorded for a
+// store. Stores indexed before symbols were recorded have none.
+func (s *SQLiteStore) CountSymbols(storeID int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM chunk_symbols s
+		JOIN chunks c ON c.id = s.chunk_id
+		JOIN files f ON f.id = c.file_id
+		WHERE f.store_id = ?
+	`, storeID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count symbols: %w", err)
+	}
+	return count, nil
+}
+
+// ContainsIdentifier reports whether content contains name other than as
+// part of a longer identifier.
+func ContainsIdentifier(content, name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; ; {
+		j := strings.Index(content[i:], name)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(name)
+		if (start == 0 || !isIdentByte(content[start-1])) &&
+			(end == len(content) || !isIdentByte(content[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+// isIdentByte reports whether b can be part of an identifier.
+func isIdentByte(b byte) bool {
+	return b == '_' || b == '$' || b >= 0x80 ||
+		('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// scanSymbolRow scans a row of symbolResultColumns, preceded by the symbol
+// name if name is not nil.
+func scanSymbolRow(rows *sql.Rows, name *string, chunk *ChunkRecord, file *FileRecord) error {
+	var indexedAt, modifiedAt string
+	dest := []any{
+		&chunk.ID, &chunk.FileID, &chunk.ChunkIndex,
+		&chunk.Content, &chunk.StartLine, &chunk.EndLine,
+		&chunk.ContextBefore, &chunk.ContextAfter,
+		&file.ID, &file.StoreID, &file.ExternalID,
+		&file.Path, &file.RelativePath, &file.Hash,
+		&file.FileSize, &indexedAt,
+		&file.Generated, &file.Language, &modifiedAt,
+	}
+	if name != nil {
+		dest = append([]any{name}, dest...)
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return fmt.Errorf("failed to scan chunk: %w", err)
+	}
+	file.IndexedAt, _ = time.Parse(time.RFC3339, indexedAt)
+	file.ModifiedAt, _ = time.Parse(time.RFC3339, modifiedAt)
+	return nil
+}
+
+// globEscape escapes the GLOB wildcards in s so it matches literally.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[':
+			b.WriteString("[" + string(r) + "]")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// likeEscape escapes the LIKE wildcards in s for use with ESCAPE '\'.
+func likeEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
@@ -1,7 +1,12 @@
 // Package store provides vector storage and retrieval using SQLite and sqlite-vec.
 package store
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"github.com/nickcecere/lgrep/internal/config"
+)
 
 // EmbeddingProvider represents the provider used for embeddings.
 type EmbeddingProvider string
@@ -13,14 +18,15 @@ const (
 
 // StoreRecord represents a stored index (a project/directory that has been indexed).
 type StoreRecord struct {
-	ID                  int64             `json:"id"`
-	Name                string            `json:"name"`
-	RootPath            string            `json:"root_path"`
-	EmbeddingProvider   EmbeddingProvider `json:"embedding_provider"`
-	EmbeddingModel      string            `json:"embedding_model"`
-	EmbeddingDimensions int               `json:"embedding_dimensions"`
-	CreatedAt           time.Time         `json:"created_at"`
-	UpdatedAt           time.Time         `json:"updated_at"`
+	ID                  int64                 `json:"id"`
+	Name                string                `json:"name"`
+	RootPath            string                `json:"root_path"`
+	EmbeddingProvider   EmbeddingProvider     `json:"embedding_provider"`
+	EmbeddingModel      string                `json:"embedding_model"`
+	EmbeddingDimensions int                   `json:"embedding_dimensions"`
+	DistanceMetric      config.DistanceMetric `json:"distance_metric"`
+	CreatedAt           time.Time             `json:"created_at"`
+	UpdatedAt           time.Time             `json:"updated_at"`
 }
 
 // FileRecord represents an indexed file.
@@ -33,6 +39,9 @@ type FileRecord struct {
 	Hash         string    `json:"hash"`          // Content hash (xxh64:...)
 	FileSize     int64     `json:"file_size"`
 	IndexedAt    time.Time `json:"indexed_at"`
+	Generated    bool      `json:"generated,omitempty"` // Vendored or generated code
+	Language     string    `json:"language,omitempty"`  // As detected by fs.DetectLanguage
+	ModifiedAt   time.Time `json:"modified_at"`         // Zero if indexed before it was recorded
 }
 
 // ChunkRecord represents a chunk of a file.
@@ -43,14 +52,24 @@ type ChunkRecord struct {
 	Content    string `json:"content"`
 	StartLine  int    `json:"start_line"` // 1-indexed
 	EndLine    int    `json:"end_line"`   // 1-indexed
+
+	// ContextBefore and ContextAfter are the lines around the chunk when it
+	// was indexed, used when the file is no longer available.
+	ContextBefore string `json:"context_before,omitempty"`
+	ContextAfter  string `json:"context_after,omitempty"`
 }
 
 // Chunk represents a chunk to be stored (input for upsert).
 type Chunk struct {
-	Content    string `json:"content"`
-	StartLine  int    `json:"start_line"`
-	EndLine    int    `json:"end_line"`
-	ChunkIndex int    `json:"chunk_index"`
+	Content       string `json:"content"`
+	StartLine     int    `json:"start_line"`
+	EndLine       int    `json:"end_line"`
+	ChunkIndex    int    `json:"chunk_index"`
+	ContextBefore string `json:"context_before,omitempty"`
+	ContextAfter  string `json:"context_after,omitempty"`
+
+	// Symbols are the names defined in the chunk, for finding definitions.
+	Symbols []string `json:"symbols,omitempty"`
 }
 
 // FileInput represents file data for upserting.
@@ -60,14 +79,87 @@ type FileInput struct {
 	RelativePath string `json:"relative_path"`
 	Hash         string `json:"hash"`
 	FileSize     int64  `json:"file_size"`
+
+	// Content is the whole file text, stored only if set.
+	Content string `json:"content,omitempty"`
+
+	// Imports are the files and packages the file imports, as returned by
+	// fs.ExtractImports.
+	Imports []string `json:"imports,omitempty"`
+
+	// Generated marks vendored or generated code, as detected by
+	// fs.IsGenerated.
+	Generated bool `json:"generated,omitempty"`
+
+	// Language is the file's language, as detected by fs.DetectLanguage.
+	Language string `json:"language,omitempty"`
+
+	// ModifiedAt is the file's modification time, for the search recency
+	// boost.
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// FileUpsert is one file in a batch written by UpsertFiles.
+type FileUpsert struct {
+	File       FileInput
+	Chunks     []Chunk
+	Embeddings [][]float32
+}
+
+// UpsertError reports the files in a batch that UpsertFiles could not write,
+// keyed by external ID. The rest of the batch was committed.
+type UpsertError struct {
+	Files map[string]error
+}
+
+func (e *UpsertError) Error() string {
+	return fmt.Sprintf("failed to store %d file(s)", len(e.Files))
+}
+
+// SearchOptions contains options for a vector search.
+type SearchOptions struct {
+	// SkipContent leaves the content and stored context of the chunks
+	// empty, for callers that only rank results. GetChunkContent loads the
+	// content of the results that are kept.
+	SkipContent bool
+
+	// Paths, if not nil, restricts the search to the files with these
+	// relative paths. Their chunks are scored one by one instead of through
+	// the vector index, which is exact and quick for a few thousand files.
+	// An empty, non-nil slice matches nothing.
+	Paths []string
+
+	// PathPrefix, if set, restricts the search to the file with this
+	// relative path or, if it is a directory, the files under it. Like
+	// Paths, the chunks of those files are scored one by one.
+	PathPrefix string
 }
 
 // SearchResult represents a search result with chunk, file, and similarity score.
 type SearchResult struct {
 	Chunk    ChunkRecord `json:"chunk"`
 	File     FileRecord  `json:"file"`
-	Distance float64     `json:"distance"` // Cosine distance from sqlite-vec
-	Score    float64     `json:"score"`    // 1 - distance (similarity)
+	Distance float64     `json:"distance"` // Distance from sqlite-vec in the store's metric
+	Score    float64     `json:"score"`    // Similarity, from the distance
+}
+
+// FileRelation records that one file of a store imports another.
+type FileRelation struct {
+	FromFileID int64 `json:"from_file_id"`
+	ToFileID   int64 `json:"to_file_id"`
+}
+
+// SymbolMatch is a chunk that defines a symbol.
+type SymbolMatch struct {
+	Symbol string      `json:"symbol"`
+	Chunk  ChunkRecord `json:"chunk"`
+	File   FileRecord  `json:"file"`
+}
+
+// ChunkVector is the stored embedding of a chunk.
+type ChunkVector struct {
+	ChunkID   int64     `json:"chunk_id"`
+	Embedding []float32 `json:"embedding"`
 }
 
 // StoreStats contains statistics about a store.
@@ -77,10 +169,261 @@ type StoreStats struct {
 	FileCount  int    `json:"file_count"`
 	ChunkCount int    `json:"chunk_count"`
 	TotalSize  int64  `json:"total_size"` // Total file size in bytes
+
+	// Languages counts the files of each language, with files indexed
+	// before languages were recorded, or of no known language, under "".
+	Languages map[string]int `json:"languages,omitempty"`
+
+	// Usage is the recorded cloud provider usage for the store.
+	Usage UsageSummary `json:"usage"`
 }
 
-// ListFilesOptions contains options for listing files.
+// ListFilesOptions contains options for listing files. The filters are
+// combined; zero values do not filter.
 type ListFilesOptions struct {
 	Limit  int
 	Offset int
+
+	PathPrefix    string    // Relative path prefix, e.g. "internal/"
+	Language      string    // As detected by fs.DetectLanguage
+	ModifiedAfter time.Time // Files last modified after this time
+	Hash          string    // Content hash
+}
+
+// QASource identifies a chunk that was sent to the LLM as Q&A context.
+type QASource struct {
+	RelativePath string  `json:"relative_path"`
+	StartLine    int     `json:"start_line"`
+	EndLine      int     `json:"end_line"`
+	Score        float64 `json:"score"`
+	Relevance    float64 `json:"relevance,omitempty"` // Calibrated, 0-100
+}
+
+// QATranscript records a Q&A exchange in the history log.
+type QATranscript struct {
+	ID          int64         `json:"id"`
+	StoreName   string        `json:"store_name"`
+	Question    string        `json:"question"`
+	Answer      string        `json:"answer"`
+	Provider    string        `json:"provider"`
+	Model       string        `json:"model"`
+	Sources     []QASource    `json:"sources"`
+	ContextHash string        `json:"context_hash"` // Hash of the question and context sent to the LLM
+	Latency     time.Duration `json:"latency"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// UsageRecord records the tokens sent to a cloud provider by one operation.
+type UsageRecord struct {
+	ID           int64     `json:"id"`
+	StoreName    string    `json:"store_name"`
+	Operation    string    `json:"operation"` // index, search or answer
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	Cost         float64   `json:"cost"` // Estimated cost in US dollars
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UsageSummary totals provider usage over a period.
+type UsageSummary struct {
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// Metric events.
+const (
+	MetricSearch = "search" // A semantic search
+	MetricIndex  = "index"  // A full index run
+	MetricWatch  = "watch"  // A batch of watcher file events
+	MetricAnswer = "answer" // A generated Q&A answer
+)
+
+// Metric records the timing of one search, index run, watcher batch or
+// answer. Metrics never leave the local database.
+type Metric struct {
+	ID        int64         `json:"id"`
+	Event     string        `json:"event"`
+	StoreName string        `json:"store_name"`
+	Duration  time.Duration `json:"duration"`
+	Embed     time.Duration `json:"embed"` // Time spent generating embeddings
+	DB        time.Duration `json:"db"`    // Time spent in the database
+	Count     int           `json:"count"` // Results, files indexed, file events or answer sources
+
+	// LLM tokens and their estimated cost in US dollars, for answers.
+	// Unlike usage records, they include local providers.
+	InputTokens  int     `json:"input_tokens,omitempty"`
+	OutputTokens int     `json:"output_tokens,omitempty"`
+	Cost         float64 `json:"cost,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MetricSummary aggregates the metrics recorded for one event type, and for
+// one store when summarized by store.
+type MetricSummary struct {
+	Event        string        `json:"event"`
+	StoreName    string        `json:"store_name,omitempty"`
+	Runs         int           `json:"runs"`
+	AvgDuration  time.Duration `json:"avg_duration"`
+	AvgEmbed     time.Duration `json:"avg_embed"`
+	AvgDB        time.Duration `json:"avg_db"`
+	MaxDuration  time.Duration `json:"max_duration"`
+	TotalCount   int           `json:"total_count"`
+	InputTokens  int64         `json:"input_tokens,omitempty"`
+	OutputTokens int64         `json:"output_tokens,omitempty"`
+	Cost         float64       `json:"cost,omitempty"`
+}
+
+// Index run triggers, recording what started a run.
+const (
+	TriggerCLI     = "cli"     // lgrep index
+	TriggerSearch  = "search"  // Auto-indexing before a search
+	TriggerMCP     = "mcp"     // An MCP tool call
+	TriggerWatch   = "watch"   // A batch of watcher file events
+	TriggerReindex = "reindex" // The watcher's initial or periodic full run
+	TriggerStorm   = "storm"   // The watcher's full run after a burst of events
+)
+
+// Index run statuses.
+const (
+	RunRunning   = "running"
+	RunOK        = "ok"
+	RunFailed    = "failed"
+	RunCancelled = "cancelled"
+)
+
+// IndexRun records one index run of a store, or one batch of watcher
+// updates. A run is recorded when it starts, so a running run also shows
+// which process holds the store's index lock.
+type IndexRun struct {
+	ID      int64  `json:"id"`
+	StoreID int64  `json:"store_id"`
+	Trigger string `json:"trigger"`
+
+	// Host and PID identify the process that ran it.
+	Host string `json:"host"`
+	PID  int    `json:"pid"`
+
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"` // Zero while running
+
+	Files   int `json:"files"`   // Files indexed or checked
+	Skipped int `json:"skipped"` // Unchanged files
+	Removed int `json:"removed"` // Files removed from the store
+	Errors  int `json:"errors"`  // Files that failed
+
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ScoreCalibration describes the similarity scores of unrelated chunks in a
+// store, sampled when it is indexed. Raw scores mean different things for
+// different models, so they are mapped to a relevance from 0 to 100
+// relative to this distribution.
+type ScoreCalibration struct {
+	StoreID int64 `json:"store_id"`
+	Samples int   `json:"samples"` // Chunks sampled
+
+	// Chunks is the store's chunk count and Metric its distance metric when
+	// it was calibrated.
+	Chunks int                   `json:"chunks"`
+	Metric config.DistanceMetric `json:"metric"`
+
+	// Baseline is the median similarity of two sampled chunks and High the
+	// 99th percentile.
+	Baseline float64 `json:"baseline"`
+	High     float64 `json:"high"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EmbeddingBatch is a job submitted to an embedding provider's batch API
+// whose results have not been written to the store yet.
+type EmbeddingBatch struct {
+	ID        int64       `json:"id"`
+	StoreID   int64       `json:"store_id"`
+	JobID     string      `json:"job_id"` // The provider's batch ID
+	Files     []BatchFile `json:"files"`  // In the order their chunks were submitted
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// BatchFile is a file whose chunks were submitted in an embedding batch.
+type BatchFile struct {
+	RelativePath string `json:"relative_path"`
+	Hash         string `json:"hash"`
+	Chunks       int    `json:"chunks"`
+}
+
+// SearchLogEntry is a search recorded with the results it returned, so that
+// feedback on a result can be tied to the query that found it, and later
+// pages can be shown without searching again.
+type SearchLogEntry struct {
+	ID      int64  `json:"id"`
+	StoreID int64  `json:"store_id"`
+	Query   string `json:"query"`
+
+	// Key identifies the options the search ran with, so a page is only
+	// served from the log for the same search.
+	Key string `json:"key"`
+
+	Results   []LoggedResult `json:"results"` // In rank order
+	Shown     int            `json:"shown"`   // Results shown so far
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// LoggedResult is a result of a logged search.
+type LoggedResult struct {
+	ChunkID   int64   `json:"chunk_id"`
+	Score     float64 `json:"score"`
+	Relevance float64 `json:"relevance"`
+}
+
+// Feedback is a judgment of whether a search result was what the user was
+// looking for. It is kept by path rather than chunk so it outlives
+// re-indexing.
+type Feedback struct {
+	ID           int64     `json:"id"`
+	StoreID      int64     `json:"store_id"`
+	RelativePath string    `json:"relative_path"`
+	StartLine    int       `json:"start_line"`
+	EndLine      int       `json:"end_line"`
+	Query        string    `json:"query"` // Empty if the search was not recorded
+	Good         bool      `json:"good"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// FeedbackCount is the number of good and bad judgments of a file.
+type FeedbackCount struct {
+	Good int `json:"good"`
+	Bad  int `json:"bad"`
+}
+
+// IntegrityReport lists the problems found in the database by
+// CheckIntegrity.
+type IntegrityReport struct {
+	// Corruption holds the messages of SQLite's quick_check, which repair
+	// cannot fix; restore a backup or rebuild the database instead.
+	Corruption []string `json:"corruption,omitempty"`
+
+	// MissingVectorTable is set if chunks are indexed but the vector table
+	// is gone.
+	MissingVectorTable bool `json:"missing_vector_table,omitempty"`
+
+	OrphanChunks   int `json:"orphan_chunks"`   // Chunks of files that no longer exist
+	OrphanVectors  int `json:"orphan_vectors"`  // Vectors of chunks that no longer exist
+	MissingVectors int `json:"missing_vectors"` // Chunks without a vector, which searches never find
+
+	// StoredVectors counts the chunks without a vector whose embedding is
+	// stored with the chunk, so repair restores the vector without
+	// embedding the chunk again.
+	StoredVectors int `json:"stored_vectors"`
+}
+
+// OK reports whether no problems were found.
+func (r *IntegrityReport) OK() bool {
+	return len(r.Corruption) == 0 && !r.MissingVectorTable &&
+		r.OrphanChunks == 0 && r.OrphanVectors == 0 && r.MissingVectors == 0
 }
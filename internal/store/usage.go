@@ -0,0 +1,64 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// AddUsage records tokens sent to a cloud provider.
+func (s *SQLiteStore) AddUsage(u *UsageRecord) error {
+	return retryOnBusy(func() error {
+		return s.addUsage(u)
+	})
+}
+
+// addUsage performs AddUsage without retrying.
+func (s *SQLiteStore) addUsage(u *UsageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now().UTC()
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO usage (namespace, store_name, operation, provider, model, input_tokens, output_tokens, cost, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.namespace, u.StoreName, u.Operation, u.Provider, u.Model, u.InputTokens, u.OutputTokens, u.Cost,
+		u.CreatedAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to insert usage: %w", err)
+	}
+
+	u.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// GetUsageSummary totals usage recorded since the given time. An empty
+// storeName totals usage across all stores in the namespace.
+func (s *SQLiteStore) GetUsageSummary(storeName string, since time.Time) (*UsageSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.usageSummary(storeName, since)
+}
+
+// usageSummary performs GetUsageSummary; the caller must hold s.mu.
+func (s *SQLiteStore) usageSummary(storeName string, since time.Time) (*UsageSummary, error) {
+	query := `
+		SELECT COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0), COALESCE(SUM(cost), 0)
+		FROM usage WHERE namespace = ? AND created_at >= ?`
+	args := []any{s.namespace, since.UTC().Format(time.RFC3339)}
+	if storeName != "" {
+		query += ` AND store_name = ?`
+		args = append(args, storeName)
+	}
+
+	var summary UsageSummary
+	err := s.db.QueryRow(query, args...).Scan(&summary.InputTokens, &summary.OutputTokens, &summary.Cost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	return &summary, nil
+}
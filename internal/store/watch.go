@@ -0,0 +1,127 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AddPendingPaths records file changes a watcher of a store stopped before
+// handling, by relative path, so the next watcher can handle them.
+func (s *SQLiteStore) AddPendingPaths(storeID int64, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	return retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		for _, path := range paths {
+			if _, err := tx.Exec(`
+				INSERT OR IGNORE INTO watch_pending (store_id, relative_path) VALUES (?, ?)
+			`, storeID, path); err != nil {
+				return fmt.Errorf("failed to insert pending path: %w", err)
+			}
+		}
+
+		return tx.Commit()
+	})
+}
+
+// TakePendingPaths returns and forgets the file changes recorded with
+// AddPendingPaths for a store.
+func (s *SQLiteStore) TakePendingPaths(storeID int64) ([]string, error) {
+	var paths []string
+	err := retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		rows, err := tx.Query(`
+			SELECT relative_path FROM watch_pending WHERE store_id = ? ORDER BY relative_path
+		`, storeID)
+		if err != nil {
+			return fmt.Errorf("failed to list pending paths: %w", err)
+		}
+		paths = nil
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan pending path: %w", err)
+			}
+			paths = append(paths, path)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to list pending paths: %w", err)
+		}
+
+		if _, err := tx.Exec(`DELETE FROM watch_pending WHERE store_id = ?`, storeID); err != nil {
+			return fmt.Errorf("failed to delete pending paths: %w", err)
+		}
+
+		return tx.Commit()
+	})
+	return paths, err
+}
+
+// PauseWatch pauses the watchers of a store until ResumeWatch. They keep
+// queueing file changes but do not index them. Pausing a paused store keeps
+// the time it was first paused.
+func (s *SQLiteStore) PauseWatch(storeID int64) error {
+	return retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, err := s.db.Exec(`
+			INSERT OR IGNORE INTO watch_pauses (store_id, paused_at) VALUES (?, ?)
+		`, storeID, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return fmt.Errorf("failed to pause watch: %w", err)
+		}
+		return nil
+	})
+}
+
+// ResumeWatch resumes the watchers of a store paused with PauseWatch.
+func (s *SQLiteStore) ResumeWatch(storeID int64) error {
+	return retryOnBusy(func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, err := s.db.Exec(`DELETE FROM watch_pauses WHERE store_id = ?`, storeID); err != nil {
+			return fmt.Errorf("failed to resume watch: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetWatchPause returns when the watchers of a store were paused, or the
+// zero time if they are not.
+func (s *SQLiteStore) GetWatchPause(storeID int64) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var pausedAt string
+	err := s.db.QueryRow(`SELECT paused_at FROM watch_pauses WHERE store_id = ?`, storeID).Scan(&pausedAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get watch pause: %w", err)
+	}
+
+	t, _ := time.Parse(time.RFC3339, pausedAt)
+	return t, nil
+}
@@ -45,6 +45,9 @@ var (
 	ResultContent = lipgloss.NewStyle().
 			Foreground(ColorMuted).
 			PaddingLeft(2)
+	Match = lipgloss.NewStyle().
+		Bold(true).
+		Underline(true)
 
 	// Section styles
 	SectionTitle = lipgloss.NewStyle().
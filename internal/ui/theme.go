@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"os"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+	"github.com/muesli/termenv"
+)
+
+// Background values accepted by Configure.
+const (
+	BackgroundAuto  = "auto"
+	BackgroundDark  = "dark"
+	BackgroundLight = "light"
+)
+
+var (
+	background     = BackgroundAuto
+	darkBackground bool
+	detectOnce     sync.Once
+)
+
+// Configure applies the color settings. Colors are turned off when noColor
+// is set or the NO_COLOR environment variable is set (see no-color.org);
+// they are also off when stdout is not a terminal. bg is one of the
+// Background values and selects light or dark variants.
+func Configure(noColor bool, bg string) {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		lipgloss.SetColorProfile(termenv.Ascii)
+		log.SetColorProfile(termenv.Ascii)
+	}
+
+	switch bg {
+	case BackgroundDark, BackgroundLight:
+		background = bg
+	default:
+		background = BackgroundAuto
+	}
+}
+
+// ColorEnabled reports whether output may contain color.
+func ColorEnabled() bool {
+	return lipgloss.ColorProfile() != termenv.Ascii
+}
+
+// ColorProfile returns the color capability of the output.
+func ColorProfile() termenv.Profile {
+	return lipgloss.ColorProfile()
+}
+
+// Background returns the configured background, or BackgroundAuto.
+func Background() string {
+	return background
+}
+
+// DarkBackground reports whether the terminal has a dark background. With
+// the auto setting the terminal is asked once, the first time it matters.
+func DarkBackground() bool {
+	switch background {
+	case BackgroundDark:
+		return true
+	case BackgroundLight:
+		return false
+	}
+
+	detectOnce.Do(func() {
+		darkBackground = !ColorEnabled() || lipgloss.HasDarkBackground()
+	})
+	return darkBackground
+}
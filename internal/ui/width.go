@@ -0,0 +1,30 @@
+package ui
+
+import "github.com/mattn/go-runewidth"
+
+// ellipsis marks where truncated text was cut.
+const ellipsis = "..."
+
+// Width returns the number of terminal cells s takes up. Wide characters,
+// such as CJK ideographs and most emoji, take two cells.
+func Width(s string) int {
+	return runewidth.StringWidth(s)
+}
+
+// Truncate shortens s to at most width terminal cells, ending it with "..."
+// if it was cut. Characters are never split, so a wide character that does
+// not fit is dropped whole.
+func Truncate(s string, width int) string {
+	return runewidth.Truncate(s, width, ellipsis)
+}
+
+// TruncateStart shortens s to at most width terminal cells by cutting its
+// start, which it replaces with "...", for paths whose end matters most.
+func TruncateStart(s string, width int) string {
+	w := runewidth.StringWidth(s)
+	if w <= width {
+		return s
+	}
+	// A wide character straddling the cut is replaced by padding
+	return runewidth.TruncateLeft(s, w-width+len(ellipsis), ellipsis)
+}
@@ -0,0 +1,58 @@
+package ui
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTruncate tests that truncation counts terminal cells and never splits
+// a character.
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		width int
+		want  string
+	}{
+		{"fits", "hello", 5, "hello"},
+		{"ascii", "hello world", 8, "hello..."},
+		{"accents", "héllo wörld", 8, "héllo..."},
+		{"cjk", "日本語のテキスト", 9, "日本語..."},
+		{"cjk odd width", "日本語のテキスト", 10, "日本語..."},
+		{"emoji", "🚀🚀🚀🚀🚀", 7, "🚀🚀..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Truncate(tt.s, tt.width)
+			assert.Equal(t, tt.want, got)
+			assert.True(t, utf8.ValidString(got))
+			assert.LessOrEqual(t, Width(got), tt.width)
+		})
+	}
+}
+
+// TestTruncateStart tests that truncating the start of a path keeps its end
+// within the width.
+func TestTruncateStart(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		width int
+		want  string
+	}{
+		{"fits", "src/main.go", 11, "src/main.go"},
+		{"ascii", "internal/search/search.go", 12, "...search.go"},
+		{"cjk", "文書/日本語.md", 10, "...本語.md"},
+		{"cjk straddling", "文書/日本語.md", 9, "... 語.md"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateStart(tt.s, tt.width)
+			assert.Equal(t, tt.want, got)
+			assert.True(t, utf8.ValidString(got))
+			assert.LessOrEqual(t, Width(got), tt.width)
+		})
+	}
+}
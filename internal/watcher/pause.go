@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// SetPaused pauses or resumes indexing by every watcher of the store,
+// including those of other processes. Paused watchers keep queueing file
+// changes and handle them once resumed.
+func (w *Watcher) SetPaused(paused bool) error {
+	storeRecord, err := w.store.GetStore(w.storeName)
+	if err != nil {
+		return fmt.Errorf("failed to check store: %w", err)
+	}
+	if storeRecord == nil {
+		return fmt.Errorf("store not found: %s", w.storeName)
+	}
+
+	if paused {
+		return w.store.PauseWatch(storeRecord.ID)
+	}
+	return w.store.ResumeWatch(storeRecord.ID)
+}
+
+// pauseCheckInterval is how often a batch of file changes checks whether
+// indexing was paused while it is being handled.
+const pauseCheckInterval = time.Second
+
+// Paused reports whether indexing by watchers of the store is paused, as
+// set with SetPaused or 'lgrep watch pause'. The store is looked up again,
+// so a store rebuilt since is followed, and its ID is cached for
+// storePaused.
+func (w *Watcher) Paused() bool {
+	storeRecord, err := w.store.GetStore(w.storeName)
+	if err != nil || storeRecord == nil {
+		return false
+	}
+	w.storeID.Store(storeRecord.ID)
+	return w.storePaused(storeRecord.ID)
+}
+
+// storePaused reports whether indexing of the store with storeID is paused,
+// logging when that changed since the last check.
+func (w *Watcher) storePaused(storeID int64) bool {
+	pausedAt, err := w.store.GetWatchPause(storeID)
+	if err != nil {
+		log.Debug("Failed to check whether watching is paused", "error", err)
+		return false
+	}
+
+	paused := !pausedAt.IsZero()
+	if w.paused.Swap(paused) != paused {
+		if paused {
+			log.Info("Indexing paused, queueing file changes until resumed", "since", pausedAt.Local())
+		} else {
+			log.Info("Indexing resumed")
+			w.resumed()
+		}
+	}
+	return paused
+}
+
+// resumed reconciles the whole tree instead of handling each file if as
+// many changes queued up during the pause as make a storm.
+func (w *Watcher) resumed() {
+	w.debounceMu.Lock()
+	defer w.debounceMu.Unlock()
+
+	if w.stormEvents > 0 && len(w.debounce) >= w.stormEvents {
+		w.storm.active = true
+	}
+}
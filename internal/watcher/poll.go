@@ -0,0 +1,133 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultPollInterval is how often the tree is scanned when polling.
+const DefaultPollInterval = 10 * time.Second
+
+// fileState is what polling compares to detect a changed file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// WithPolling scans the tree for changes instead of using file system
+// events, which are unreliable on NFS, SMB and WSL2 mounts.
+func WithPolling() Option {
+	return func(w *Watcher) {
+		w.polling = true
+	}
+}
+
+// WithPollInterval sets how often the tree is scanned when polling, whether
+// chosen with WithPolling or because events are unavailable. Zero uses
+// DefaultPollInterval.
+func WithPollInterval(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.pollInterval = d
+	}
+}
+
+// poll scans the tree every poll interval and queues files that were
+// created, modified or removed since the previous scan. Blocks until ctx is
+// cancelled.
+func (w *Watcher) poll(ctx context.Context) error {
+	interval := w.pollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	log.Info("Polling for file changes", "root", w.root, "interval", interval)
+
+	stopped := make(chan struct{})
+	go w.processDebounced(ctx, stopped)
+	if w.reindexInterval > 0 {
+		go w.reindexPeriodically(ctx)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	previous := w.scan()
+	for {
+		select {
+		case <-ctx.Done():
+			<-stopped
+			return ctx.Err()
+		case <-ticker.C:
+			current := w.scan()
+			w.queueChanges(previous, current)
+			previous = current
+		}
+	}
+}
+
+// scan returns the state of every file the watcher would index.
+func (w *Watcher) scan() map[string]fileState {
+	ignorer := w.current.Load().ignorer
+	files := make(map[string]fileState)
+
+	_ = filepath.WalkDir(w.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip errors
+		}
+
+		name := d.Name()
+		relPath, _ := filepath.Rel(w.root, path)
+		if d.IsDir() {
+			if path == w.root {
+				return nil
+			}
+			if strings.HasPrefix(name, ".") || w.shouldSkipDir(name) || ignorer.MatchesPath(relPath+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.HasPrefix(name, ".") || ignorer.MatchesPath(relPath) || !w.isIndexableFile(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return files
+}
+
+// queueChanges queues the differences between two scans for processing, as
+// the file events they correspond to.
+func (w *Watcher) queueChanges(previous, current map[string]fileState) {
+	w.debounceMu.Lock()
+	defer w.debounceMu.Unlock()
+
+	changes := 0
+	for path, state := range current {
+		old, ok := previous[path]
+		switch {
+		case !ok:
+			w.debounce[path] = fsnotify.Create
+			changes++
+		case !old.modTime.Equal(state.modTime) || old.size != state.size:
+			w.debounce[path] = fsnotify.Write
+			changes++
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			w.debounce[path] = fsnotify.Remove
+			changes++
+		}
+	}
+	w.noteEvents(changes)
+}
@@ -0,0 +1,51 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/config"
+)
+
+// TestPollingScan tests that changes between scans are queued as the
+// events they correspond to, skipping ignored and non-indexable files.
+func TestPollingScan(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	write("main.go", "package main\n")
+	write("old.go", "package main\n")
+	write("node_modules/dep.js", "module.exports = {}\n")
+	write("gen/out.go", "package gen\n")
+	write(".hidden.go", "package main\n")
+	write("data.bin", "\x00\x01\x02")
+
+	cfg := config.DefaultConfig()
+	cfg.Ignore = []string{"gen/"}
+	w, err := New(root, "test", nil, nil, cfg, WithPolling())
+	require.NoError(t, err)
+
+	previous := w.scan()
+	assert.Len(t, previous, 2)
+
+	write("main.go", "package main\n\nfunc main() {}\n")
+	require.NoError(t, os.Chtimes(filepath.Join(root, "main.go"), time.Now(), time.Now().Add(time.Minute)))
+	write("lib/new.go", "package lib\n")
+	require.NoError(t, os.Remove(filepath.Join(root, "old.go")))
+
+	w.queueChanges(previous, w.scan())
+	assert.Equal(t, map[string]fsnotify.Op{
+		filepath.Join(root, "main.go"):    fsnotify.Write,
+		filepath.Join(root, "lib/new.go"): fsnotify.Create,
+		filepath.Join(root, "old.go"):     fsnotify.Remove,
+	}, w.debounce)
+}
@@ -0,0 +1,106 @@
+package watcher
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultShutdownGrace is how long a stopping watcher keeps handling queued
+// file changes before saving the rest for the next run.
+const DefaultShutdownGrace = 10 * time.Second
+
+// WithShutdownGrace sets how long the watcher keeps handling queued file
+// changes once its context is cancelled. Changes it does not get to are
+// saved in the store and handled by the next watcher of the store. Zero
+// saves them all without handling any.
+func WithShutdownGrace(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.shutdownGrace = d
+	}
+}
+
+// drain handles the queued file changes for at most the shutdown grace
+// period, ignoring any storm, and saves the rest.
+func (w *Watcher) drain() {
+	w.debounceMu.Lock()
+	w.storm = stormState{}
+	queued := len(w.debounce)
+	w.debounceMu.Unlock()
+	if queued == 0 {
+		return
+	}
+
+	if w.shutdownGrace > 0 {
+		log.Info("Handling queued file changes before stopping", "files", queued, "grace", w.shutdownGrace)
+		ctx, cancel := context.WithTimeout(context.Background(), w.shutdownGrace)
+		w.flushDebounced(ctx)
+		cancel()
+	}
+	w.savePending()
+}
+
+// requeue puts file changes a flush did not get to back in the queue.
+func (w *Watcher) requeue(events map[string]fsnotify.Op) {
+	w.debounceMu.Lock()
+	defer w.debounceMu.Unlock()
+
+	for path, op := range events {
+		w.debounce[path] |= op
+	}
+}
+
+// savePending moves the queued file changes to the store.
+func (w *Watcher) savePending() {
+	w.debounceMu.Lock()
+	var paths []string
+	for path := range w.debounce {
+		if relPath, err := filepath.Rel(w.root, path); err == nil {
+			paths = append(paths, filepath.ToSlash(relPath))
+		}
+	}
+	w.debounce = make(map[string]fsnotify.Op)
+	w.debounceMu.Unlock()
+	if len(paths) == 0 {
+		return
+	}
+
+	storeRecord, err := w.store.GetStore(w.storeName)
+	if err == nil && storeRecord != nil {
+		err = w.store.AddPendingPaths(storeRecord.ID, paths)
+	}
+	if err != nil || storeRecord == nil {
+		log.Warn("Failed to save queued file changes, the next index will pick them up", "files", len(paths), "error", err)
+		return
+	}
+	log.Info("Saved queued file changes for the next run", "files", len(paths))
+}
+
+// restorePending queues the file changes saved by the previous watcher of
+// the store.
+func (w *Watcher) restorePending() {
+	storeRecord, err := w.store.GetStore(w.storeName)
+	if err != nil || storeRecord == nil {
+		return
+	}
+	paths, err := w.store.TakePendingPaths(storeRecord.ID)
+	if err != nil {
+		log.Warn("Failed to load file changes saved by the previous run", "error", err)
+		return
+	}
+	if len(paths) == 0 {
+		return
+	}
+
+	log.Info("Handling file changes saved by the previous run", "files", len(paths))
+	w.debounceMu.Lock()
+	defer w.debounceMu.Unlock()
+	for _, relPath := range paths {
+		// What is on disk decides what to do, so the operation does not matter
+		w.debounce[filepath.Join(w.root, filepath.FromSlash(relPath))] |= fsnotify.Write
+	}
+	w.noteEvents(len(paths))
+}
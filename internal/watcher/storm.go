@@ -0,0 +1,62 @@
+package watcher
+
+import (
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Defaults for storm detection: a git checkout or branch switch touches
+// hundreds of files within a second or two, while editing rarely touches
+// more than a handful.
+const (
+	DefaultStormEvents = 100
+	DefaultStormWindow = 2 * time.Second
+)
+
+// stormState tracks the rate of file events to detect storms.
+type stormState struct {
+	windowStart time.Time // Start of the current counting window
+	count       int       // Events since windowStart
+	lastEvent   time.Time
+	active      bool
+}
+
+// WithStormThreshold treats events events within window as a storm, such
+// as a git checkout: instead of handling each file, the watcher waits until
+// no event arrives for window and then reconciles the whole tree in one
+// pass, comparing hashes with the store. Zero events disables it.
+func WithStormThreshold(events int, window time.Duration) Option {
+	return func(w *Watcher) {
+		w.stormEvents = events
+		w.stormWindow = window
+	}
+}
+
+// noteEvents counts n queued events towards storm detection. The caller
+// holds debounceMu.
+func (w *Watcher) noteEvents(n int) {
+	if w.stormEvents <= 0 || n == 0 {
+		return
+	}
+
+	now := time.Now()
+	s := &w.storm
+	if now.Sub(s.windowStart) > w.stormWindow {
+		s.windowStart = now
+		s.count = 0
+	}
+	s.count += n
+	s.lastEvent = now
+
+	if !s.active && s.count >= w.stormEvents {
+		s.active = true
+		log.Info("Burst of file events, waiting for it to end", "events", s.count, "within", w.stormWindow)
+	}
+}
+
+// stormEnded reports whether no event arrived for a storm window by now.
+// The caller holds debounceMu.
+func (w *Watcher) stormEnded(now time.Time) bool {
+	return now.Sub(w.storm.lastEvent) >= w.stormWindow
+}
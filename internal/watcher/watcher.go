@@ -3,14 +3,19 @@ package watcher
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/fsnotify/fsnotify"
+	gitignore "github.com/sabhiram/go-gitignore"
 
 	"github.com/nickcecere/lgrep/internal/config"
 	"github.com/nickcecere/lgrep/internal/embeddings"
@@ -26,15 +31,60 @@ type Watcher struct {
 	store     store.Store
 	embedder  embeddings.Service
 	indexer   *indexer.Indexer
-	cfg       *config.Config
+	current   atomic.Pointer[settings]
 
-	// debounce holds pending file events to batch process
+	// debounce holds the events of each file since the last flush, combined
 	debounce     map[string]fsnotify.Op
 	debounceMu   sync.Mutex
 	debounceTime time.Duration
 
 	// callback for status updates
 	onEvent func(event string, path string)
+
+	// reindexInterval is how often the whole tree is reconciled with the
+	// store, or zero to rely on file events alone
+	reindexInterval time.Duration
+
+	// polling scans the tree every pollInterval instead of using events
+	polling      bool
+	pollInterval time.Duration
+
+	// stormEvents events within stormWindow start a storm, such as a git
+	// checkout, which is reconciled in one pass once it ends; guarded by
+	// debounceMu
+	stormEvents int
+	stormWindow time.Duration
+	storm       stormState
+
+	// shutdownGrace bounds handling queued changes when stopping
+	shutdownGrace time.Duration
+
+	// paused is whether indexing was paused when last checked
+	paused atomic.Bool
+
+	// storeID is the ID of the store as last looked up by Paused
+	storeID atomic.Int64
+}
+
+// settings holds the configuration and the ignore patterns compiled from it,
+// replaced together when the configuration is reloaded.
+type settings struct {
+	cfg     *config.Config
+	ignorer *gitignore.GitIgnore
+	walker  *fs.FileWalker // judges files as indexing does
+}
+
+// newSettings returns the settings for cfg, for the tree at root.
+func newSettings(cfg *config.Config, root string) *settings {
+	walker, err := indexer.NewFileWalker(cfg, root)
+	if err != nil {
+		log.Warn("No files will be indexed on change", "error", err)
+	}
+	return &settings{
+		cfg:     cfg,
+		ignorer: gitignore.CompileIgnoreLines(cfg.Ignore...),
+		walker:  walker,
+	}
 }
 
 // Option configures the watcher.
@@ -54,6 +104,16 @@ func WithEventCallback(fn func(event string, path string)) Option {
 	}
 }
 
+// WithFullReindexInterval reconciles the whole tree with the store every d:
+// changed and previously failed files are indexed and deleted files are
+// removed. This catches changes that file events missed, e.g. on network
+// mounts.
+func WithFullReindexInterval(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.reindexInterval = d
+	}
+}
+
 // New creates a new file watcher.
 func New(root string, storeName string, st store.Store, emb embeddings.Service, cfg *config.Config, opts ...Option) (*Watcher, error) {
 	absRoot, err := filepath.Abs(root)
@@ -69,12 +129,17 @@ func New(root string, storeName string, st store.Store, emb embeddings.Service,
 		store:        st,
 		embedder:     emb,
 		indexer:      idx,
-		cfg:          cfg,
 		debounce:     make(map[string]fsnotify.Op),
 		debounceTime: 500 * time.Millisecond,
 		onEvent:      func(string, string) {}, // noop default
+		stormEvents:  DefaultStormEvents,
+		stormWindow:  DefaultStormWindow,
+
+		shutdownGrace: DefaultShutdownGrace,
 	}
 
+	w.current.Store(newSettings(cfg, w.root))
+
 	for _, opt := range opts {
 		opt(w)
 	}
@@ -82,27 +147,50 @@ func New(root string, storeName string, st store.Store, emb embeddings.Service,
 	return w, nil
 }
 
-// Start begins watching for file changes. Blocks until context is cancelled.
+// SetConfig replaces the configuration, e.g. after the config file was
+// reloaded. New ignore patterns apply to later events.
+func (w *Watcher) SetConfig(cfg *config.Config) {
+	w.current.Store(newSettings(cfg, w.root))
+	w.indexer.SetConfig(cfg)
+}
+
+// Start begins watching for file changes. Blocks until context is cancelled
+// and the queued changes are handled or saved for the next run, see
+// WithShutdownGrace. If file system events are unavailable for the tree,
+// e.g. because the inotify watch limit is reached, it polls for changes
+// instead.
 func (w *Watcher) Start(ctx context.Context) error {
+	if w.polling {
+		return w.poll(ctx)
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		return err
+		log.Warn("File system events unavailable, polling for changes instead", "error", err)
+		return w.poll(ctx)
 	}
 	defer watcher.Close()
 
 	// Add all directories recursively
 	if err := w.addDirectories(watcher); err != nil {
-		return err
+		log.Warn("File system events unavailable, polling for changes instead", "error", err)
+		return w.poll(ctx)
 	}
 
 	log.Info("Watching for file changes", "root", w.root)
 
 	// Start debounce processor
-	go w.processDebounced(ctx)
+	stopped := make(chan struct{})
+	go w.processDebounced(ctx, stopped)
+
+	if w.reindexInterval > 0 {
+		go w.reindexPeriodically(ctx)
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
+			<-stopped
 			return ctx.Err()
 
 		case event, ok := <-watcher.Events:
@@ -120,7 +208,10 @@ func (w *Watcher) Start(ctx context.Context) error {
 	}
 }
 
-// addDirectories recursively adds all directories to the watcher.
+// addDirectories recursively adds all directories to the watcher. It fails
+// if the root itself cannot be watched, or if the system's limit on watches
+// or open files is reached, so that the tree is polled instead of partly
+// watched.
 func (w *Watcher) addDirectories(watcher *fsnotify.Watcher) error {
 	return filepath.WalkDir(w.root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
@@ -141,12 +232,32 @@ func (w *Watcher) addDirectories(watcher *fsnotify.Watcher) error {
 		}
 
 		if err := watcher.Add(path); err != nil {
+			if path == w.root || watchLimitReached(err) {
+				return fmt.Errorf("failed to watch %s: %w%s", path, err, watchLimitHint(err))
+			}
 			log.Debug("Failed to watch directory", "path", path, "error", err)
 		}
 		return nil
 	})
 }
 
+// watchLimitReached reports whether err means the system ran out of
+// inotify watches (ENOSPC) or instances or open files (EMFILE).
+func watchLimitReached(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE)
+}
+
+// watchLimitHint returns how to raise the limit err ran into, or "".
+func watchLimitHint(err error) string {
+	switch {
+	case errors.Is(err, syscall.ENOSPC):
+		return " (the inotify watch limit is reached; raise it with 'sudo sysctl fs.inotify.max_user_watches=524288')"
+	case errors.Is(err, syscall.EMFILE):
+		return " (too many inotify instances or open files; raise 'sudo sysctl fs.inotify.max_user_instances=512' or 'ulimit -n')"
+	}
+	return ""
+}
+
 // shouldSkipDir returns true if directory should not be watched.
 func (w *Watcher) shouldSkipDir(name string) bool {
 	skipDirs := []string{
@@ -176,12 +287,17 @@ func (w *Watcher) handleEvent(event fsnotify.Event, watcher *fsnotify.Watcher) {
 	if strings.HasPrefix(filepath.Base(path), ".") {
 		return
 	}
+	ignorer := w.current.Load().ignorer
 
 	// For new directories, add to watcher
 	if event.Has(fsnotify.Create) {
 		if info, err := os.Stat(path); err == nil && info.IsDir() {
-			if !w.shouldSkipDir(filepath.Base(path)) {
-				watcher.Add(path)
+			if !w.shouldSkipDir(filepath.Base(path)) && !ignorer.MatchesPath(relPath+"/") {
+				if err := watcher.Add(path); err != nil {
+					log.Warn("Failed to watch new directory, its changes are only picked up by a full re-index",
+						"path", relPath, "error", fmt.Sprintf("%v%s", err, watchLimitHint(err)))
+					return
+				}
 				log.Debug("Added directory to watch", "path", relPath)
 			}
 			return
@@ -193,41 +309,35 @@ func (w *Watcher) handleEvent(event fsnotify.Event, watcher *fsnotify.Watcher) {
 		return
 	}
 
-	// Skip non-indexable files
-	if !w.isIndexableFile(path) {
+	// Skip ignored and non-indexable files. A removed file can only be
+	// judged by its name.
+	if ignorer.MatchesPath(relPath) {
+		return
+	}
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		if fs.DetectLanguage(path) == fs.LangUnknown {
+			return
+		}
+	} else if !w.isIndexableFile(path) {
 		return
 	}
 
-	// Add to debounce queue
+	// Add to debounce queue, combining bursts of events for the same file
 	w.debounceMu.Lock()
-	w.debounce[path] = event.Op
+	w.debounce[path] |= event.Op
+	w.noteEvents(1)
 	w.debounceMu.Unlock()
 }
 
-// isIndexableFile checks if a file should be indexed.
+// isIndexableFile checks if a file should be indexed, as indexing the tree
+// would judge it.
 func (w *Watcher) isIndexableFile(path string) bool {
-	// Check extension
-	ext := strings.ToLower(filepath.Ext(path))
-	if ext == "" {
+	walker := w.current.Load().walker
+	if walker == nil {
 		return false
 	}
-
-	// Check if it's a known language
-	lang := fs.DetectLanguage(path)
-	if lang == fs.LangUnknown {
-		return false
-	}
-
-	// Check file size
-	info, err := os.Stat(path)
-	if err != nil {
-		return false
-	}
-	if w.cfg.Indexing.MaxFileSize > 0 && info.Size() > int64(w.cfg.Indexing.MaxFileSize) {
-		return false
-	}
-
-	return true
+	include, _ := walker.Include(path)
+	return include
 }
 
 // GetStoreName returns the store name for this watcher.
@@ -235,14 +345,20 @@ func (w *Watcher) GetStoreName() string {
 	return w.storeName
 }
 
-// processDebounced processes debounced file events periodically.
-func (w *Watcher) processDebounced(ctx context.Context) {
+// processDebounced processes debounced file events periodically, starting
+// with those saved by the previous run. Once ctx is cancelled it drains the
+// queue and closes stopped.
+func (w *Watcher) processDebounced(ctx context.Context, stopped chan<- struct{}) {
+	defer close(stopped)
+	w.restorePending()
+
 	ticker := time.NewTicker(w.debounceTime)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
+			w.drain()
 			return
 		case <-ticker.C:
 			w.flushDebounced(ctx)
@@ -250,64 +366,222 @@ func (w *Watcher) processDebounced(ctx context.Context) {
 	}
 }
 
-// flushDebounced processes all pending debounced events.
+// flushDebounced processes all pending debounced events. During a storm
+// nothing is processed until it ends, and then the whole tree is reconciled
+// instead. While indexing is paused nothing is processed.
 func (w *Watcher) flushDebounced(ctx context.Context) {
 	w.debounceMu.Lock()
+	queued := len(w.debounce)
+	w.debounceMu.Unlock()
+	if queued == 0 || w.Paused() {
+		return
+	}
+
+	w.debounceMu.Lock()
+	if w.storm.active {
+		if !w.stormEnded(time.Now()) {
+			w.debounceMu.Unlock()
+			return
+		}
+		events := w.debounce
+		w.debounce = make(map[string]fsnotify.Op)
+		w.storm = stormState{}
+		w.debounceMu.Unlock()
+
+		// The changes are put back if the reconcile is cut short by ctx,
+		// so a stopping watcher saves them for the next run
+		log.Info("Burst of file events ended, reconciling instead of handling each file", "files", len(events))
+		if err := w.reindex(ctx, store.TriggerStorm); err != nil && ctx.Err() != nil {
+			w.requeue(events)
+		}
+		return
+	}
 	if len(w.debounce) == 0 {
 		w.debounceMu.Unlock()
 		return
 	}
 
-	// Copy and clear the map
-	events := make(map[string]fsnotify.Op)
-	for k, v := range w.debounce {
-		events[k] = v
-	}
+	// Take the map; changes not handled before ctx is cancelled are put
+	// back
+	events := w.debounce
 	w.debounce = make(map[string]fsnotify.Op)
 	w.debounceMu.Unlock()
-
-	// Process each event
+	count := len(events)
+
+	start := time.Now()
+	run := &store.IndexRun{Trigger: store.TriggerWatch, StartedAt: start.UTC()}
+	defer func() {
+		metric := &store.Metric{
+			Event:     store.MetricWatch,
+			StoreName: w.storeName,
+			Duration:  time.Since(start),
+			Count:     count,
+		}
+		if err := w.store.AddMetric(metric); err != nil {
+			log.Debug("Failed to record watch metric", "error", err)
+		}
+		w.recordRun(run)
+	}()
+
+	// Process each file. What is on disk now decides what to do, so the
+	// remove and create of an editor's atomic save become a single update,
+	// and a temporary file created and removed again is ignored. Pausing is
+	// checked every pauseCheckInterval rather than for each file.
+	nextPauseCheck := start.Add(pauseCheckInterval)
 	for path, op := range events {
-		select {
-		case <-ctx.Done():
+		paused := false
+		if now := time.Now(); now.After(nextPauseCheck) {
+			nextPauseCheck = now.Add(pauseCheckInterval)
+			paused = w.storePaused(w.storeID.Load())
+		}
+		if ctx.Err() != nil || paused {
+			w.requeue(events)
 			return
-		default:
 		}
+		delete(events, path)
 
 		relPath, _ := filepath.Rel(w.root, path)
 
-		if op.Has(fsnotify.Remove) || op.Has(fsnotify.Rename) {
+		if _, err := os.Stat(path); err != nil {
 			// File was deleted or renamed away
-			if err := w.handleDelete(ctx, path); err != nil {
+			removed, err := w.handleDelete(path)
+			switch {
+			case err != nil:
 				log.Error("Failed to handle delete", "path", relPath, "error", err)
-			} else {
+				run.Errors++
+				if run.Error == "" {
+					run.Error = fmt.Sprintf("%s: %v", relPath, err)
+				}
+			case removed:
+				run.Removed++
 				w.onEvent("delete", relPath)
 				log.Info("Removed from index", "file", relPath)
 			}
-		} else if op.Has(fsnotify.Create) || op.Has(fsnotify.Write) {
-			// File was created or modified
-			if err := w.handleModify(ctx, path); err != nil {
-				log.Error("Failed to handle modify", "path", relPath, "error", err)
-			} else {
-				w.onEvent("index", relPath)
-				log.Info("Indexed", "file", relPath)
+			continue
+		}
+
+		// File was created or modified
+		indexed, err := w.handleModify(ctx, path)
+		if err != nil && ctx.Err() != nil {
+			events[path] = op
+			w.requeue(events)
+			return
+		}
+		run.Files++
+		switch {
+		case err != nil:
+			log.Error("Failed to handle modify", "path", relPath, "error", err)
+			run.Errors++
+			if run.Error == "" {
+				run.Error = fmt.Sprintf("%s: %v", relPath, err)
 			}
+		case indexed:
+			w.onEvent("index", relPath)
+			log.Info("Indexed", "file", relPath)
+		default:
+			run.Skipped++
+			log.Debug("File content unchanged, skipping", "file", relPath, "events", op)
 		}
 	}
 }
 
-// handleModify re-indexes a modified or new file.
-func (w *Watcher) handleModify(ctx context.Context, path string) error {
-	// First delete any existing chunks for this file
-	relPath, _ := filepath.Rel(w.root, path)
-	_ = w.indexer.DeleteFile(w.storeName, relPath)
+// recordRun adds a batch of file events to the store's run history, unless
+// it changed nothing, so saves without changes do not crowd out real runs.
+func (w *Watcher) recordRun(run *store.IndexRun) {
+	if run.Files == run.Skipped && run.Removed == 0 && run.Errors == 0 {
+		return
+	}
+
+	storeRecord, err := w.store.GetStore(w.storeName)
+	if err != nil || storeRecord == nil {
+		return
+	}
+
+	run.StoreID = storeRecord.ID
+	run.FinishedAt = time.Now().UTC()
+	run.Status = store.RunOK
+	if run.Errors > 0 {
+		run.Status = store.RunFailed
+	}
+	if err := w.store.AddIndexRun(run); err != nil {
+		log.Debug("Failed to record watch run", "error", err)
+	}
+}
+
+// reindexPeriodically reconciles the tree with the store every
+// reindexInterval.
+func (w *Watcher) reindexPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(w.reindexInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if w.Paused() {
+				log.Info("Skipping full re-index while indexing is paused")
+				continue
+			}
+			_ = w.reindex(ctx, store.TriggerReindex)
+		}
+	}
+}
 
-	// Now index the file
+// reindex indexes changed files and removes deleted ones. If another
+// process is already indexing the store, it waits for that run to finish
+// and reuses its result instead. trigger records what started it in the
+// run history. Failures are logged and returned.
+func (w *Watcher) reindex(ctx context.Context, trigger string) error {
+	log.Info("Reconciling index with the file system", "root", w.root)
+
+	err := w.indexer.Index(ctx, indexer.IndexOptions{
+		StoreName:  w.storeName,
+		Path:       w.root,
+		BatchSize:  50,
+		Prune:      true,
+		LockPolicy: indexer.LockDelegate,
+		Trigger:    trigger,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("Full re-index failed", "error", err)
+		}
+		return err
+	}
+
+	p := w.indexer.Progress()
+	if p.Delegated {
+		log.Info("Full re-index done by another process", "store", w.storeName)
+		return nil
+	}
+	w.onEvent("reindex", "")
+	log.Info("Full re-index complete",
+		"indexed", p.ProcessedFiles-p.SkippedFiles,
+		"removed", p.PrunedFiles,
+		"errors", p.Errors,
+	)
+	return nil
+}
+
+// handleModify re-indexes a modified or new file, reporting whether its
+// content had changed.
+func (w *Watcher) handleModify(ctx context.Context, path string) (bool, error) {
 	return w.indexer.IndexSingleFile(ctx, w.storeName, w.root, path)
 }
 
-// handleDelete removes a file from the index.
-func (w *Watcher) handleDelete(ctx context.Context, path string) error {
+// handleDelete removes a file from the index, reporting whether it was
+// indexed.
+func (w *Watcher) handleDelete(path string) (bool, error) {
 	relPath, _ := filepath.Rel(w.root, path)
-	return w.indexer.DeleteFile(w.storeName, relPath)
+
+	storeRecord, err := w.store.GetStore(w.storeName)
+	if err != nil || storeRecord == nil {
+		return false, err
+	}
+	existing, err := w.store.GetFileByExternalID(storeRecord.ID, relPath)
+	if err != nil || existing == nil {
+		return false, err
+	}
+	return true, w.store.DeleteFile(storeRecord.ID, relPath)
 }
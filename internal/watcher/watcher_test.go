@@ -0,0 +1,361 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nickcecere/lgrep/internal/config"
+	"github.com/nickcecere/lgrep/internal/embeddings"
+	"github.com/nickcecere/lgrep/internal/store"
+)
+
+// countingEmbedder returns fixed embeddings and counts the texts embedded.
+type countingEmbedder struct {
+	texts int
+}
+
+func (c *countingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return c.EmbedQuery(ctx, text)
+}
+
+func (c *countingEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	c.texts++
+	return []float32{1, 0, 0, 0}, nil
+}
+
+func (c *countingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	result := make([][]float32, len(texts))
+	for i := range texts {
+		result[i], _ = c.EmbedQuery(ctx, texts[i])
+	}
+	return result, nil
+}
+
+func (c *countingEmbedder) Dimensions() int               { return 4 }
+func (c *countingEmbedder) Provider() embeddings.Provider { return embeddings.ProviderOllama }
+func (c *countingEmbedder) ModelName() string             { return "counting" }
+
+// TestFlushCoalescesEvents tests that files are processed by what is on
+// disk after a burst of events, and that saves without changes are skipped.
+func TestFlushCoalescesEvents(t *testing.T) {
+	root := t.TempDir()
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	var events []string
+	emb := &countingEmbedder{}
+	w, err := New(root, "test", st, emb, config.DefaultConfig(), WithEventCallback(func(event, path string) {
+		events = append(events, event+" "+path)
+	}))
+	require.NoError(t, err)
+
+	main := filepath.Join(root, "main.go")
+	flush := func(ops map[string]fsnotify.Op) {
+		events = nil
+		w.debounce = ops
+		w.flushDebounced(context.Background())
+	}
+
+	require.NoError(t, os.WriteFile(main, []byte("package main\n\nfunc main() {}\n"), 0644))
+	flush(map[string]fsnotify.Op{main: fsnotify.Create | fsnotify.Write})
+	assert.Equal(t, []string{"index main.go"}, events)
+	embedded := emb.texts
+	require.Positive(t, embedded)
+
+	// Saving the same content again embeds nothing, and is not recorded as
+	// a run
+	flush(map[string]fsnotify.Op{main: fsnotify.Write})
+	assert.Empty(t, events)
+	assert.Equal(t, embedded, emb.texts)
+	storeRecord, err := st.GetStore("test")
+	require.NoError(t, err)
+	runs, err := st.ListIndexRuns(storeRecord.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, store.TriggerWatch, runs[0].Trigger)
+	assert.Equal(t, 1, runs[0].Files)
+
+	// An atomic save renames the file away and creates it again
+	require.NoError(t, os.WriteFile(main, []byte("package main\n\nfunc main() { println() }\n"), 0644))
+	flush(map[string]fsnotify.Op{main: fsnotify.Rename | fsnotify.Create})
+	assert.Equal(t, []string{"index main.go"}, events)
+
+	// A temporary file created and removed again is ignored
+	flush(map[string]fsnotify.Op{filepath.Join(root, "main.go.tmp.go"): fsnotify.Create | fsnotify.Remove})
+	assert.Empty(t, events)
+
+	require.NoError(t, os.Remove(main))
+	flush(map[string]fsnotify.Op{main: fsnotify.Remove})
+	assert.Equal(t, []string{"delete main.go"}, events)
+}
+
+// TestStorm tests that a burst of events is not handled file by file, and
+// that the tree is reconciled in one run once the burst ends.
+func TestStorm(t *testing.T) {
+	root := t.TempDir()
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	var events []string
+	w, err := New(root, "test", st, &countingEmbedder{}, config.DefaultConfig(),
+		WithStormThreshold(3, 50*time.Millisecond),
+		WithEventCallback(func(event, path string) {
+			events = append(events, event+" "+path)
+		}))
+	require.NoError(t, err)
+
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.WriteFile(path, []byte("package "+name[:1]+"\n"), 0644))
+		w.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Create}, nil)
+	}
+	require.True(t, w.storm.active)
+
+	// Nothing is handled while events keep arriving
+	w.flushDebounced(context.Background())
+	assert.Empty(t, events)
+	assert.Len(t, w.debounce, 3)
+
+	time.Sleep(60 * time.Millisecond)
+	w.flushDebounced(context.Background())
+	assert.Equal(t, []string{"reindex "}, events)
+	assert.Empty(t, w.debounce)
+	assert.False(t, w.storm.active)
+
+	storeRecord, err := st.GetStore("test")
+	require.NoError(t, err)
+	require.NotNil(t, storeRecord)
+	runs, err := st.ListIndexRuns(storeRecord.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, store.TriggerStorm, runs[0].Trigger)
+	assert.Equal(t, 3, runs[0].Files)
+
+	// Events after the storm are handled one by one again
+	events = nil
+	path := filepath.Join(root, "a.go")
+	require.NoError(t, os.WriteFile(path, []byte("package a\n\nfunc A() {}\n"), 0644))
+	w.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Write}, nil)
+	w.flushDebounced(context.Background())
+	assert.Equal(t, []string{"index a.go"}, events)
+
+	// A reconcile cut short by shutdown keeps the changes, so they can be
+	// saved for the next run
+	events = nil
+	time.Sleep(60 * time.Millisecond)
+	for _, name := range []string{"b.go", "c.go", "d.go"} {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.WriteFile(path, []byte("package "+name[:1]+"\n\nvar X = 1\n"), 0644))
+		w.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Write}, nil)
+	}
+	require.True(t, w.storm.active)
+	time.Sleep(60 * time.Millisecond)
+	stopping, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.flushDebounced(stopping)
+	assert.Empty(t, events)
+	assert.Len(t, w.debounce, 3)
+	assert.False(t, w.storm.active)
+}
+
+// TestShutdown tests that queued changes are handled when the watcher
+// stops, and that those it has no time for are handled by the next watcher.
+func TestShutdown(t *testing.T) {
+	root := t.TempDir()
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	var events []string
+	onEvent := WithEventCallback(func(event, path string) {
+		events = append(events, event+" "+path)
+	})
+	stop := func(w *Watcher) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		stopped := make(chan struct{})
+		w.processDebounced(ctx, stopped)
+		<-stopped
+	}
+
+	a := filepath.Join(root, "a.go")
+	require.NoError(t, os.WriteFile(a, []byte("package a\n"), 0644))
+	w, err := New(root, "test", st, &countingEmbedder{}, config.DefaultConfig(), onEvent, WithDebounceTime(time.Hour))
+	require.NoError(t, err)
+	w.debounce[a] = fsnotify.Create
+	stop(w)
+	assert.Equal(t, []string{"index a.go"}, events)
+	assert.Empty(t, w.debounce)
+
+	// Without time to handle it, a change is saved for the next watcher
+	events = nil
+	b := filepath.Join(root, "sub", "b.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(b), 0755))
+	require.NoError(t, os.WriteFile(b, []byte("package sub\n"), 0644))
+	w, err = New(root, "test", st, &countingEmbedder{}, config.DefaultConfig(), onEvent,
+		WithDebounceTime(time.Hour), WithShutdownGrace(0))
+	require.NoError(t, err)
+	w.debounce[b] = fsnotify.Create
+	stop(w)
+	assert.Empty(t, events)
+
+	w, err = New(root, "test", st, &countingEmbedder{}, config.DefaultConfig(), onEvent, WithDebounceTime(time.Hour))
+	require.NoError(t, err)
+	stop(w)
+	assert.Equal(t, []string{"index sub/b.go"}, events)
+
+	storeRecord, err := st.GetStore("test")
+	require.NoError(t, err)
+	paths, err := st.TakePendingPaths(storeRecord.ID)
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+}
+
+// TestPause tests that changes are queued while indexing is paused and
+// handled once it is resumed.
+func TestPause(t *testing.T) {
+	root := t.TempDir()
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	var events []string
+	w, err := New(root, "test", st, &countingEmbedder{}, config.DefaultConfig(),
+		WithStormThreshold(2, time.Millisecond),
+		WithEventCallback(func(event, path string) {
+			events = append(events, event+" "+path)
+		}))
+	require.NoError(t, err)
+
+	// Pausing needs the store, so index a file first
+	a := filepath.Join(root, "a.go")
+	require.NoError(t, os.WriteFile(a, []byte("package a\n"), 0644))
+	w.debounce[a] = fsnotify.Create
+	w.flushDebounced(context.Background())
+	require.Equal(t, []string{"index a.go"}, events)
+
+	require.NoError(t, w.SetPaused(true))
+	assert.True(t, w.Paused())
+	assert.True(t, w.storePaused(w.storeID.Load()), "the store ID is cached")
+
+	events = nil
+	require.NoError(t, os.WriteFile(a, []byte("package a\n\nfunc A() {}\n"), 0644))
+	w.debounce[a] = fsnotify.Write
+	w.flushDebounced(context.Background())
+	assert.Empty(t, events)
+	assert.Len(t, w.debounce, 1)
+
+	require.NoError(t, w.SetPaused(false))
+	w.flushDebounced(context.Background())
+	assert.Equal(t, []string{"index a.go"}, events)
+
+	// Changes enough for a storm queued while paused are reconciled in one
+	// pass
+	require.NoError(t, w.SetPaused(true))
+	events = nil
+	for _, name := range []string{"b.go", "c.go"} {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.WriteFile(path, []byte("package "+name[:1]+"\n"), 0644))
+		w.debounce[path] = fsnotify.Create
+	}
+	w.flushDebounced(context.Background())
+	assert.Empty(t, events)
+
+	require.NoError(t, w.SetPaused(false))
+	w.flushDebounced(context.Background())
+	assert.Equal(t, []string{"reindex "}, events)
+}
+
+// TestWatchLimitHint tests that running out of inotify watches or instances
+// is recognized through wrapping and explained with the sysctl to raise.
+func TestWatchLimitHint(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		limit   bool
+		contain string
+	}{
+		{"watches", syscall.ENOSPC, true, "fs.inotify.max_user_watches"},
+		{"instances", fmt.Errorf("add: %w", syscall.EMFILE), true, "fs.inotify.max_user_instances"},
+		{"other", syscall.EACCES, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.limit, watchLimitReached(tt.err))
+			if tt.contain == "" {
+				assert.Empty(t, watchLimitHint(tt.err))
+			} else {
+				assert.Contains(t, watchLimitHint(tt.err), tt.contain)
+			}
+		})
+	}
+}
+
+// TestIsIndexableFile tests that files are judged on change as indexing the
+// tree would judge them.
+func TestIsIndexableFile(t *testing.T) {
+	root := t.TempDir()
+	for rel, content := range map[string]string{
+		"main.go":            "package main\n",
+		"notes":              "no extension\n",
+		"assets/logo.png":    "\x89PNG\r\n\x1a\n\x00\x00",
+		"assets/icon.svg":    "<svg></svg>\n",
+		"data.bin":           "\x00\x01\x02",
+		"node_modules/x.js":  "module.exports = {}\n",
+		"gen/out.go":         "package gen\n",
+		".hidden.go":         "package main\n",
+		"ignored/by/file.go": "package by\n",
+	} {
+		path := filepath.Join(root, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".gitignore"), []byte("ignored/\n"), 0644))
+
+	tests := []struct {
+		name  string
+		setup func(cfg *config.Config)
+		want  []string
+	}{
+		{"defaults", func(cfg *config.Config) {}, []string{"main.go", "notes"}},
+		{"text extensions", func(cfg *config.Config) {
+			cfg.Indexing.TextExtensions = []string{"svg"}
+		}, []string{"main.go", "notes", "assets/icon.svg"}},
+		{"binary names", func(cfg *config.Config) {
+			cfg.Indexing.IncludeBinaryNames = true
+		}, []string{"main.go", "notes", "assets/logo.png", "assets/icon.svg", "data.bin"}},
+		{"languages", func(cfg *config.Config) {
+			cfg.Indexing.Languages.Include = []string{"go"}
+		}, []string{"main.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Ignore = []string{"gen/"}
+			tt.setup(cfg)
+			w, err := New(root, "test", nil, nil, cfg)
+			require.NoError(t, err)
+
+			var got []string
+			require.NoError(t, filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() && w.isIndexableFile(path) {
+					rel, _ := filepath.Rel(root, path)
+					got = append(got, filepath.ToSlash(rel))
+				}
+				return err
+			}))
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+}